@@ -0,0 +1,115 @@
+// Command dnska-ctl manages a running dnska server through its HTTP API,
+// served on the health address of the server
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// zoneValidationReport is the response of the zone validation API
+type zoneValidationReport struct {
+	Zone       string `json:"zone"`
+	Valid      bool   `json:"valid"`
+	Violations []struct {
+		Check string `json:"check"`
+		Name  string `json:"name"`
+		Error string `json:"error"`
+	} `json:"violations"`
+	Error string `json:"error"`
+}
+
+// unicodeNames prints names with their U-labels, e.g. münchen.de
+var unicodeNames bool
+
+func main() {
+	var address string
+	flag.StringVar(&address, "addr", "127.0.0.1:8053", "HTTP address of the server (server.health_address)")
+	flag.BoolVar(&unicodeNames, "unicode", false, "print internationalized names in Unicode rather than as xn-- A-labels")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: dnska-ctl [-addr host:port] [-unicode] <command> [arguments]\n\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Commands:\n  validate-zone <zone>  check that the zone has an SOA, NS records and glue\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	switch flag.Arg(0) {
+	case "validate-zone":
+		if flag.NArg() != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		os.Exit(validateZone(client, address, flag.Arg(1)))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// validateZone asks the server to validate zone and prints its violations.
+// Zone names in Unicode are sent as A-labels. It returns the exit status: 0
+// for a valid zone, 1 otherwise and 2 for a zone name that does not parse
+func validateZone(client *http.Client, address, zone string) int {
+	zoneName, err := utils.ParseDomainName(zone)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid zone name %q: %v\n", zone, err)
+		return 2
+	}
+	zone = zoneName.String()
+
+	endpoint := fmt.Sprintf("http://%s/api/v1/zones/%s/validate", address, url.PathEscape(zone))
+	response, err := client.Post(endpoint, "application/json", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to reach the server: %v\n", err)
+		return 1
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read the response: %v\n", err)
+		return 1
+	}
+
+	var report zoneValidationReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		// Requests the server rejects are answered in plain text
+		fmt.Fprintf(os.Stderr, "Server returned %s: %s\n", response.Status, strings.TrimSpace(string(body)))
+		return 1
+	}
+
+	if report.Error != "" {
+		fmt.Fprintf(os.Stderr, "Failed to validate %s: %s\n", displayName(report.Zone), report.Error)
+	}
+	if report.Valid {
+		fmt.Printf("%s is valid\n", displayName(report.Zone))
+		return 0
+	}
+	for _, violation := range report.Violations {
+		fmt.Printf("%s\t%s\t%s\n", violation.Check, displayName(violation.Name), violation.Error)
+	}
+	return 1
+}
+
+// displayName returns name as it is printed: with U-labels when -unicode is
+// set, otherwise as the server reported it
+func displayName(name string) string {
+	if !unicodeNames {
+		return name
+	}
+	domainName, err := utils.ParseDomainName(name)
+	if err != nil {
+		return name
+	}
+	return domainName.UnicodeString()
+}
@@ -2,60 +2,139 @@ package main
 
 import (
 	"flag"
-	"log"
+	"fmt"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"syscall"
 
 	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/internal/logging"
 	"github.com/vadim-su/dnska/internal/server"
 )
 
+// logger is the logger of the main component
+var logger = logging.For("main")
+
 func main() {
 	var configFile string
+	var checkConfig bool
 	flag.StringVar(&configFile, "config", "dnska.yaml", "Configuration file path")
 	flag.StringVar(&configFile, "c", "dnska.yaml", "Configuration file path (shorthand)")
+	flag.BoolVar(&checkConfig, "check-config", false, "Check the configuration file, report every problem and exit")
 	flag.Parse()
 
-	var cfg *config.Config
-	var err error
+	if checkConfig {
+		os.Exit(check(configFile))
+	}
 
-	// If no config file specified, try default location
+	// The default file is optional, but one given explicitly must load
+	explicit := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "config" || f.Name == "c" {
+			explicit = true
+		}
+	})
 
 	absPath, _ := filepath.Abs(configFile)
-	cfg, err = config.LoadFromFile(configFile)
-	if err != nil {
-		log.Printf("Failed to load config from %s: %v, using defaults", absPath, err)
+	cfg, err := config.LoadFromFile(configFile)
+	if err != nil && explicit {
+		fatal("Failed to load configuration", "file", absPath, "error", err)
+	}
+	loadErr := err
+	if loadErr != nil {
 		cfg = config.DefaultConfig()
+	}
+
+	if err := server.ConfigureLogging(cfg.Logging); err != nil {
+		fatal("Failed to configure logging", "error", err)
+	}
+	defer logging.Close()
+
+	if loadErr != nil {
+		logger.Warn("Failed to load configuration, using defaults", "file", absPath, "error", loadErr)
 	} else {
-		log.Printf("Loaded configuration from %s", absPath)
+		logger.Info("Loaded configuration", "file", absPath)
 	}
 
 	srv, err := server.New(cfg)
 	if err != nil {
-		log.Fatalf("Failed to create DNS server: %v", err)
+		fatal("Failed to create DNS server", "error", err)
 	}
 
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	errChan := make(chan error, 1)
 	go func() {
 		errChan <- srv.Start()
 	}()
 
-	select {
-	case sig := <-sigChan:
-		log.Printf("Received signal %v, shutting down...", sig)
-		if err := srv.Close(); err != nil {
-			log.Printf("Error during shutdown: %v", err)
-		}
-	case err := <-errChan:
-		if err != nil {
-			log.Fatalf("Server error: %v", err)
+	for running := true; running; {
+		select {
+		case sig := <-sigChan:
+			if sig == syscall.SIGHUP {
+				reload(srv, configFile)
+				continue
+			}
+
+			logger.Info("Received signal, shutting down", "signal", sig.String())
+			if err := srv.Close(); err != nil {
+				logger.Error("Error during shutdown", "error", err)
+			}
+			running = false
+		case err := <-errChan:
+			if err != nil {
+				fatal("Server error", "error", err)
+			}
+			running = false
 		}
 	}
 
-	log.Println("Server stopped")
+	logger.Info("Server stopped")
+}
+
+// fatal logs msg with args at error level and exits with status 1
+func fatal(msg string, args ...any) {
+	logger.Error(msg, args...)
+	logging.Close()
+	os.Exit(1)
+}
+
+// reload applies the configuration file to the running server
+func reload(srv *server.Server, configFile string) {
+	logger.Info("Received SIGHUP, reloading configuration", "file", configFile)
+
+	cfg, err := config.LoadFromFile(configFile)
+	if err != nil {
+		logger.Error("Failed to reload configuration", "error", err)
+		return
+	}
+
+	if err := srv.Reload(cfg); err != nil {
+		logger.Error("Failed to reload configuration", "error", err)
+	}
+}
+
+// check loads the configuration file strictly and reports its problems,
+// returning the exit status: 0 when there are none, 1 otherwise
+func check(configFile string) int {
+	var problems []error
+	err := server.CheckConfigFile(configFile)
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		problems = joined.Unwrap()
+	} else if err != nil {
+		problems = []error{err}
+	}
+
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", configFile, problem)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "%s: %d problems found\n", configFile, len(problems))
+		return 1
+	}
+
+	fmt.Printf("%s: configuration OK\n", configFile)
+	return 0
 }
@@ -0,0 +1,93 @@
+// Package acl matches client addresses against lists of networks
+package acl
+
+import (
+	"fmt"
+	"net"
+)
+
+// ACL is a compiled list of IPv4 and IPv6 networks. Networks are held in a
+// binary trie per family, so matching an address walks at most one node per
+// prefix bit, however many networks are listed. An ACL is read-only once
+// built and safe for concurrent use
+type ACL struct {
+	v4, v6 *node
+}
+
+// node is a trie node; terminal marks the end of a listed prefix
+type node struct {
+	children [2]*node
+	terminal bool
+}
+
+// New compiles networks in CIDR notation. IPv4-mapped IPv6 networks match
+// the IPv4 addresses they map
+func New(networks []string) (*ACL, error) {
+	a := &ACL{v4: &node{}, v6: &node{}}
+	for _, network := range networks {
+		_, ipNet, err := net.ParseCIDR(network)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", network, err)
+		}
+		a.add(ipNet)
+	}
+	return a, nil
+}
+
+// add inserts the prefix of ipNet into the trie of its family
+func (a *ACL) add(ipNet *net.IPNet) {
+	ones, bits := ipNet.Mask.Size()
+	mapped := 8 * (net.IPv6len - net.IPv4len)
+	if ip4 := ipNet.IP.To4(); ip4 != nil && (bits == 8*net.IPv4len || ones >= mapped) {
+		if bits == 8*net.IPv6len {
+			ones -= mapped
+		}
+		insert(a.v4, ip4, ones)
+		return
+	}
+	insert(a.v6, ipNet.IP.To16(), ones)
+}
+
+// insert marks the first ones bits of ip as a listed prefix below root
+func insert(root *node, ip net.IP, ones int) {
+	current := root
+	for i := 0; i < ones && !current.terminal; i++ {
+		bit := ip[i/8] >> (7 - uint(i%8)) & 1
+		if current.children[bit] == nil {
+			current.children[bit] = &node{}
+		}
+		current = current.children[bit]
+	}
+	current.terminal = true
+	// Longer prefixes below are covered by this one
+	current.children = [2]*node{}
+}
+
+// Contains reports whether ip is in one of the networks. A nil ACL contains
+// no address
+func (a *ACL) Contains(ip net.IP) bool {
+	if a == nil {
+		return false
+	}
+
+	root := a.v6
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, root = ip4, a.v4
+	} else if ip = ip.To16(); ip == nil {
+		return false
+	}
+
+	current := root
+	for i := 0; ; i++ {
+		if current.terminal {
+			return true
+		}
+		if i == 8*len(ip) {
+			return false
+		}
+		current = current.children[ip[i/8]>>(7-uint(i%8))&1]
+		if current == nil {
+			return false
+		}
+	}
+}
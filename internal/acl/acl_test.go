@@ -0,0 +1,72 @@
+package acl
+
+import (
+	"net"
+	"testing"
+)
+
+func TestContains(t *testing.T) {
+	a, err := New([]string{"192.0.2.0/24", "198.51.100.7/32", "2001:db8::/32", "::ffff:203.0.113.0/120", "10.0.0.0/8", "10.1.0.0/16"})
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+
+	tests := []struct {
+		ip       string
+		expected bool
+	}{
+		{ip: "192.0.2.1", expected: true},
+		{ip: "192.0.2.255", expected: true},
+		{ip: "192.0.3.1", expected: false},
+		{ip: "198.51.100.7", expected: true},
+		{ip: "198.51.100.8", expected: false},
+		{ip: "10.200.0.1", expected: true},
+		{ip: "10.1.2.3", expected: true},
+		{ip: "::ffff:192.0.2.9", expected: true},
+		{ip: "203.0.113.50", expected: true},
+		{ip: "2001:db8:1::1", expected: true},
+		{ip: "2001:db9::1", expected: false},
+		{ip: "::1", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := a.Contains(net.ParseIP(tt.ip)); got != tt.expected {
+				t.Errorf("Contains(%s) = %v, expected %v", tt.ip, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestContainsEverything(t *testing.T) {
+	a, err := New([]string{"0.0.0.0/0", "::/0"})
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+	for _, ip := range []string{"192.0.2.1", "2001:db8::1"} {
+		if !a.Contains(net.ParseIP(ip)) {
+			t.Errorf("Contains(%s) = false, expected true", ip)
+		}
+	}
+}
+
+func TestContainsNothing(t *testing.T) {
+	var nilACL *ACL
+	if nilACL.Contains(net.ParseIP("192.0.2.1")) {
+		t.Error("nil ACL contains an address")
+	}
+
+	empty, err := New(nil)
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+	if empty.Contains(net.ParseIP("192.0.2.1")) || empty.Contains(nil) {
+		t.Error("empty ACL contains an address")
+	}
+}
+
+func TestNewInvalidNetwork(t *testing.T) {
+	if _, err := New([]string{"192.0.2.0/33"}); err == nil {
+		t.Error("New() expected error for an invalid network")
+	}
+}
@@ -0,0 +1,257 @@
+// Package blocklist matches query names against lists of blocked domains,
+// as used to block advertising and tracking domains
+package blocklist
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/logging"
+)
+
+// logger is the logger of the blocklist component
+var logger = logging.For("blocklist")
+
+// hostsOnlyNames are entries of hosts files that name the local machine and
+// must never be blocked
+var hostsOnlyNames = map[string]bool{
+	"localhost":             true,
+	"localhost.localdomain": true,
+	"local":                 true,
+	"broadcasthost":         true,
+	"ip6-localhost":         true,
+	"ip6-loopback":          true,
+	"ip6-localnet":          true,
+	"ip6-mcastprefix":       true,
+	"ip6-allnodes":          true,
+	"ip6-allrouters":        true,
+	"ip6-allhosts":          true,
+	"0.0.0.0":               true,
+}
+
+// Blocklist is a set of blocked domains loaded from files. A file lists one
+// entry per line, either in hosts format ("0.0.0.0 ads.example.com") or as a
+// bare domain. An entry "*.example.com" blocks every name below example.com
+// but not example.com itself. Text after '#' is a comment.
+//
+// Reload replaces the set when a file has changed, so a Blocklist is safe for
+// concurrent use while it is being reloaded.
+type Blocklist struct {
+	files []string
+
+	mu       sync.RWMutex
+	exact    map[string]struct{} // Blocked names
+	suffixes map[string]struct{} // Zones whose subdomains are blocked
+	versions map[string]fileVersion
+
+	hits atomic.Uint64
+}
+
+// fileVersion identifies the content of a file loaded into the set
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// equal reports whether v and other identify the same content
+func (v fileVersion) equal(other fileVersion) bool {
+	return v.modTime.Equal(other.modTime) && v.size == other.size
+}
+
+// New loads a blocklist from files
+func New(files []string) (*Blocklist, error) {
+	b := &Blocklist{files: files}
+	if err := b.load(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Blocked reports whether name or, for wildcard entries, one of its parent
+// zones is listed. Matching ignores case and a trailing dot. Every match is
+// counted as a hit
+func (b *Blocklist) Blocked(name string) bool {
+	name = normalizeName(name)
+	if name == "" {
+		return false
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	blocked := false
+	if _, ok := b.exact[name]; ok {
+		blocked = true
+	}
+
+	for parent := name; !blocked; {
+		dot := strings.IndexByte(parent, '.')
+		if dot < 0 {
+			break
+		}
+		parent = parent[dot+1:]
+		if _, ok := b.suffixes[parent]; ok {
+			blocked = true
+		}
+	}
+
+	if blocked {
+		b.hits.Add(1)
+	}
+	return blocked
+}
+
+// Hits returns the number of names blocked since the list was created
+func (b *Blocklist) Hits() uint64 {
+	return b.hits.Load()
+}
+
+// Len returns the number of entries in the list
+func (b *Blocklist) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return len(b.exact) + len(b.suffixes)
+}
+
+// Reload reloads the files when any of them changed since they were loaded
+// and reports whether it did. On error the current entries are kept
+func (b *Blocklist) Reload() (bool, error) {
+	b.mu.RLock()
+	current := b.versions
+	b.mu.RUnlock()
+
+	changed := false
+	for _, file := range b.files {
+		version, err := statFile(file)
+		if err != nil {
+			return false, err
+		}
+		if !version.equal(current[file]) {
+			changed = true
+			break
+		}
+	}
+
+	if !changed {
+		return false, nil
+	}
+	return true, b.load()
+}
+
+// Watch reloads the files every interval until ctx is done
+func (b *Blocklist) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := b.Reload()
+			if err != nil {
+				logger.Error("Failed to reload blocklist", "error", err)
+			} else if reloaded {
+				logger.Info("Blocklist reloaded", "entries", b.Len())
+			}
+		}
+	}
+}
+
+// load reads all files and replaces the entries
+func (b *Blocklist) load() error {
+	exact := make(map[string]struct{})
+	suffixes := make(map[string]struct{})
+	versions := make(map[string]fileVersion, len(b.files))
+
+	for _, file := range b.files {
+		version, err := loadFile(file, exact, suffixes)
+		if err != nil {
+			return err
+		}
+		versions[file] = version
+	}
+
+	b.mu.Lock()
+	b.exact = exact
+	b.suffixes = suffixes
+	b.versions = versions
+	b.mu.Unlock()
+
+	return nil
+}
+
+// loadFile adds the entries of file to exact and suffixes
+func loadFile(path string, exact, suffixes map[string]struct{}) (fileVersion, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return fileVersion{}, fmt.Errorf("failed to open blocklist: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fileVersion{}, fmt.Errorf("failed to open blocklist: %w", err)
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if comment := strings.IndexByte(line, '#'); comment >= 0 {
+			line = line[:comment]
+		}
+
+		fields := strings.Fields(line)
+		// Hosts format lists names after the address they resolve to
+		if len(fields) > 1 && net.ParseIP(fields[0]) != nil {
+			fields = fields[1:]
+		}
+
+		for _, field := range fields {
+			addEntry(field, exact, suffixes)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fileVersion{}, fmt.Errorf("failed to read blocklist %s: %w", path, err)
+	}
+
+	return fileVersion{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// addEntry adds a single blocklist entry
+func addEntry(entry string, exact, suffixes map[string]struct{}) {
+	if zone, ok := strings.CutPrefix(entry, "*."); ok {
+		if zone = normalizeName(zone); zone != "" {
+			suffixes[zone] = struct{}{}
+		}
+		return
+	}
+
+	name := normalizeName(entry)
+	if name == "" || hostsOnlyNames[name] {
+		return
+	}
+	exact[name] = struct{}{}
+}
+
+// statFile returns the current version of a file
+func statFile(path string) (fileVersion, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}, fmt.Errorf("failed to check blocklist: %w", err)
+	}
+	return fileVersion{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// normalizeName lowercases name and removes its trailing dot
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
@@ -0,0 +1,122 @@
+package blocklist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeList(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write blocklist: %v", err)
+	}
+}
+
+func TestBlocked(t *testing.T) {
+	dir := t.TempDir()
+	hosts := filepath.Join(dir, "hosts")
+	domains := filepath.Join(dir, "domains.txt")
+
+	writeList(t, hosts, `# Hosts style list
+127.0.0.1 localhost
+0.0.0.0 ads.example.com tracker.example.com # trailing comment
+:: ipv6.example.net
+`)
+	writeList(t, domains, `
+Telemetry.Example.ORG.
+*.doubleclick.test
+`)
+
+	list, err := New([]string{hosts, domains})
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected bool
+	}{
+		{name: "hosts entry", query: "ads.example.com", expected: true},
+		{name: "second name of a hosts entry", query: "tracker.example.com", expected: true},
+		{name: "ipv6 hosts entry", query: "ipv6.example.net", expected: true},
+		{name: "trailing dot", query: "ads.example.com.", expected: true},
+		{name: "case insensitive", query: "ADS.Example.Com", expected: true},
+		{name: "domain per line", query: "telemetry.example.org", expected: true},
+		{name: "exact entry does not block subdomains", query: "x.ads.example.com", expected: false},
+		{name: "exact entry does not block parent", query: "example.com", expected: false},
+		{name: "wildcard subdomain", query: "ad.doubleclick.test", expected: true},
+		{name: "wildcard deep subdomain", query: "a.b.doubleclick.test.", expected: true},
+		{name: "wildcard apex", query: "doubleclick.test", expected: false},
+		{name: "localhost is never blocked", query: "localhost", expected: false},
+		{name: "unlisted", query: "www.example.com", expected: false},
+		{name: "root", query: ".", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := list.Blocked(tt.query); got != tt.expected {
+				t.Errorf("Blocked(%q) = %v, expected %v", tt.query, got, tt.expected)
+			}
+		})
+	}
+
+	if hits := list.Hits(); hits != 8 {
+		t.Errorf("Hits() = %d, expected 8", hits)
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	writeList(t, path, "old.example.com\n")
+
+	list, err := New([]string{path})
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+
+	if reloaded, err := list.Reload(); err != nil || reloaded {
+		t.Fatalf("Reload() of an unchanged file = %v, %v", reloaded, err)
+	}
+
+	writeList(t, path, "new.example.com\nother.example.com\n")
+	// Make the change visible on file systems with coarse timestamps
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("failed to touch blocklist: %v", err)
+	}
+
+	reloaded, err := list.Reload()
+	if err != nil || !reloaded {
+		t.Fatalf("Reload() of a changed file = %v, %v", reloaded, err)
+	}
+
+	if list.Blocked("old.example.com") {
+		t.Error("entry removed from the file is still blocked")
+	}
+	if !list.Blocked("new.example.com") {
+		t.Error("entry added to the file is not blocked")
+	}
+	if list.Len() != 2 {
+		t.Errorf("Len() = %d, expected 2", list.Len())
+	}
+
+	// A file that disappeared keeps the loaded entries
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("failed to remove blocklist: %v", err)
+	}
+	if _, err := list.Reload(); err == nil {
+		t.Error("Reload() expected error for a missing file")
+	}
+	if !list.Blocked("new.example.com") {
+		t.Error("entries were dropped after a failed reload")
+	}
+}
+
+func TestNewMissingFile(t *testing.T) {
+	if _, err := New([]string{filepath.Join(t.TempDir(), "missing.txt")}); err == nil {
+		t.Error("New() expected error for a missing file")
+	}
+}
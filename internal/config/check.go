@@ -0,0 +1,155 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadFromFileStrict loads configuration from a YAML file like
+// LoadFromFile, except that keys the configuration does not have are
+// errors rather than ignored, so typos are caught. Every unknown key is
+// reported, the errors joined. The configuration decoded from the other keys
+// is returned along with those errors, so it can still be checked
+func LoadFromFileStrict(filename string) (*Config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	config := DefaultConfig()
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if document.Kind == 0 {
+		return config, nil // Empty file
+	}
+
+	unknown := unknownKeys(&document, reflect.TypeOf(config), "")
+	if err := document.Decode(config); err != nil {
+		return nil, errors.Join(append(unknown, fmt.Errorf("failed to parse config file: %w", err))...)
+	}
+
+	return config, errors.Join(unknown...)
+}
+
+// unknownKeys returns an error for every key of the mappings in node that
+// has no field in the type it is decoded into. The KnownFields option of
+// yaml.Decoder cannot be used, as it is lost by custom unmarshalers such as
+// the one of ServerConfig, and stops at the first unknown key
+func unknownKeys(node *yaml.Node, t reflect.Type, path string) []error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil
+		}
+		return unknownKeys(node.Content[0], t, path)
+	case yaml.AliasNode:
+		return unknownKeys(node.Alias, t, path)
+	}
+
+	var errs []error
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			name := key.Value
+			if path != "" {
+				name = path + "." + key.Value
+			}
+			field, ok := fields[key.Value]
+			if !ok {
+				errs = append(errs, fmt.Errorf("line %d: unknown key %s", key.Line, name))
+				continue
+			}
+			errs = append(errs, unknownKeys(value, field, name)...)
+		}
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && node.Kind == yaml.SequenceNode:
+		for i, element := range node.Content {
+			errs = append(errs, unknownKeys(element, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			errs = append(errs, unknownKeys(node.Content[i+1], t.Elem(), path+"."+node.Content[i].Value)...)
+		}
+	}
+	return errs
+}
+
+// yamlFields returns the types of the fields of struct type t by the keys
+// YAML decodes them from, including those of inlined structs
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		switch {
+		case name == "-":
+			continue
+		case strings.Contains(options, "inline"):
+			maps.Copy(fields, yamlFields(field.Type))
+			continue
+		case name == "":
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// CheckConfig validates config like ValidateConfig, but reports the
+// problem of every section instead of stopping at the first one. The files
+// of hosts, blocklists and zones must also exist. The checks of
+// Config.Validate are reported too when the sections find nothing, so a
+// configuration passing CheckConfig is accepted by the server
+func (v *Validator) CheckConfig(config *Config) []error {
+	if config == nil {
+		return []error{fmt.Errorf("configuration cannot be nil")}
+	}
+
+	var problems []error
+	for _, section := range v.sections(config) {
+		if err := section.validate(); err != nil {
+			problems = append(problems, fmt.Errorf("%s validation failed: %w", section.name, err))
+		}
+	}
+
+	type configFile struct{ kind, path string }
+	var files []configFile
+	for _, path := range config.Hosts.Files {
+		files = append(files, configFile{"hosts", path})
+	}
+	for _, path := range config.Blocklist.Files {
+		files = append(files, configFile{"blocklist", path})
+	}
+	for _, zone := range config.Zones.Files {
+		if zone.File != "" {
+			files = append(files, configFile{"zone " + zone.Zone, zone.File})
+		}
+	}
+	for _, file := range files {
+		if _, err := os.Stat(file.path); err != nil {
+			problems = append(problems, fmt.Errorf("%s file: %w", file.kind, err))
+		}
+	}
+
+	if len(problems) == 0 {
+		if err := config.Validate(); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	return problems
+}
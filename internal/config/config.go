@@ -1,55 +1,349 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
+	"net"
 	"os"
+	"runtime"
 	"time"
 
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the main configuration structure
 type Config struct {
-	Server   ServerConfig   `yaml:"server"`
-	Resolver ResolverConfig `yaml:"resolver"`
-	Storage  StorageConfig  `yaml:"storage"`
-	Logging  LoggingConfig  `yaml:"logging"`
-	Cache    CacheConfig    `yaml:"cache"`
+	Server    ServerConfig    `yaml:"server"`
+	Resolver  ResolverConfig  `yaml:"resolver"`
+	Storage   StorageConfig   `yaml:"storage"`
+	Logging   LoggingConfig   `yaml:"logging"`
+	Cache     CacheConfig     `yaml:"cache"`
+	DNS64     DNS64Config     `yaml:"dns64"`
+	Views     []ViewConfig    `yaml:"views,omitempty"`
+	Blocklist BlocklistConfig `yaml:"blocklist"`
+	Hosts     HostsConfig     `yaml:"hosts"`
+	Responses ResponsesConfig `yaml:"responses"`
+	Notify    NotifyConfig    `yaml:"notify"`
+	Secondary SecondaryConfig `yaml:"secondary"`
+	IDNA      IDNAConfig      `yaml:"idna"`
+	TSIG      TSIGConfig      `yaml:"tsig"`
+	Transfer  TransferConfig  `yaml:"transfer"`
+	Update    UpdateConfig    `yaml:"update"`
+	EDNS      EDNSConfig      `yaml:"edns"`
+	RRL       RRLConfig       `yaml:"rrl"`
+	Zones     ZonesConfig     `yaml:"zones"`
 }
 
 // ServerConfig holds server-specific configuration
 type ServerConfig struct {
-	Address        string        `yaml:"address"`
+	Addresses      []string      `yaml:"addresses,omitempty"` // Listen addresses, each gets a UDP and a TCP socket
+	Address        string        `yaml:"address"`             // Single listen address, used when Addresses is empty
+	BindFailure    string        `yaml:"bind_failure"`        // "fatal" stops the server when an address cannot be bound, "warn" logs it and serves the others
 	ReadTimeout    time.Duration `yaml:"read_timeout"`
 	WriteTimeout   time.Duration `yaml:"write_timeout"`
+	QueryTimeout   time.Duration `yaml:"query_timeout"` // Deadline for answering a single query, falls back to ReadTimeout
 	MaxConnections int           `yaml:"max_connections"`
+	NumWorkers     int           `yaml:"num_workers"`    // UDP sockets per address sharing the port via SO_REUSEPORT, not the goroutines answering them (Workers)
+	Workers        int           `yaml:"workers"`        // Goroutines answering UDP queries, 0 uses GOMAXPROCS; the sockets receiving them are NumWorkers
+	UDPQueueSize   int           `yaml:"udp_queue_size"` // UDP queries waiting for a worker, further ones are dropped
 	EnableTCP      bool          `yaml:"enable_tcp"`
 	EnableUDP      bool          `yaml:"enable_udp"`
+	EnableIPv6     bool          `yaml:"enable_ipv6"` // Also serve 0.0.0.0 addresses on [::]
 	EnableMetrics  bool          `yaml:"enable_metrics"`
 	EnableHealth   bool          `yaml:"enable_health"`
+	HealthAddress  string        `yaml:"health_address"`  // HTTP address of /healthz, /readyz, /stats and the zone API, empty disables them
+	HealthUpstream bool          `yaml:"health_upstream"` // /readyz also probes the first forward server
+	RecordImport   bool          `yaml:"record_import"`   // Serve POST /api/v1/records/import on the health address, which stores records without authentication and must be a loopback address
+	ZoneSnapshots  bool          `yaml:"zone_snapshots"`  // Serve /api/v1/zones/{zone}/snapshots on the health address, which restores zones without authentication and must be a loopback address
+	StatsWindow    time.Duration `yaml:"stats_window"`    // Span of the rolling query statistics on /stats, in whole minutes
+	StatsTopN      int           `yaml:"stats_top_n"`     // Names and clients listed in the query statistics
+	Parsing        ParsingConfig `yaml:"parsing"`
+	UseBufferPool  bool          `yaml:"use_buffer_pool"` // Read TCP queries into pooled buffers instead of allocating one per query; covers TCP only, UDP queries are always pooled
+
+	AllowQuery     []string               `yaml:"allow_query,omitempty"`      // Client networks queries are answered for, empty allows every client
+	DropRefused    bool                   `yaml:"drop_refused"`               // Drop queries of other clients instead of answering REFUSED
+	ZoneAllowQuery []ZoneAllowQueryConfig `yaml:"zone_allow_query,omitempty"` // Overrides AllowQuery for the names of a zone
+}
+
+// ZoneAllowQueryConfig lists the client networks queries for the names of a
+// zone are answered for, in place of the server-wide list. The most specific
+// zone applies
+type ZoneAllowQueryConfig struct {
+	Zone     string   `yaml:"zone"`
+	Networks []string `yaml:"networks"` // Client networks in CIDR notation, empty refuses every client
+}
+
+// ListenAddresses returns the addresses to listen on: Addresses when set,
+// otherwise the single Address
+func (c *ServerConfig) ListenAddresses() []string {
+	if len(c.Addresses) > 0 {
+		return c.Addresses
+	}
+	if c.Address != "" {
+		return []string{c.Address}
+	}
+	return nil
+}
+
+// healthOnLoopback reports whether the health address only accepts
+// connections from the local host. An empty address serves nothing
+func (c *ServerConfig) healthOnLoopback() bool {
+	if c.HealthAddress == "" {
+		return true
+	}
+	host, _, err := net.SplitHostPort(c.HealthAddress)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// UnmarshalYAML decodes the server section. A section listing addresses but
+// no scalar address sets Address to the first of them, so code reading
+// Address keeps working
+func (c *ServerConfig) UnmarshalYAML(node *yaml.Node) error {
+	type plain ServerConfig
+	if err := node.Decode((*plain)(c)); err != nil {
+		return err
+	}
+
+	hasAddress := false
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "address" {
+			hasAddress = true
+		}
+	}
+
+	if !hasAddress && len(c.Addresses) > 0 {
+		c.Address = c.Addresses[0]
+	}
+	return nil
+}
+
+// ParsingConfig holds the limits applied to incoming requests
+type ParsingConfig struct {
+	MaxQuestions   int  `yaml:"max_questions"`    // 0 disables the limit
+	MaxRecords     int  `yaml:"max_records"`      // Total across all sections, 0 disables the limit
+	MaxMessageSize int  `yaml:"max_message_size"` // Bytes, 0 disables the limit
+	Strict         bool `yaml:"strict"`           // Reject trailing data after the last record
+	SingleQuestion bool `yaml:"single_question"`  // With strict, reject more than one question
+	HostnameLabels bool `yaml:"hostname_labels"`  // With strict, allow only hostname characters in question names
 }
 
 // ResolverConfig holds resolver-specific configuration
 type ResolverConfig struct {
-	Timeout        time.Duration `yaml:"timeout"`
-	MaxRetries     int           `yaml:"max_retries"`
-	ForwardServers []string      `yaml:"forward_servers"`
-	RootServers    []string      `yaml:"root_servers"`
-	RecursionDepth int           `yaml:"recursion_depth"`
+	Mode              string        `yaml:"mode"` // "recursive" (or "iterative"), "forward", "stub"
+	Timeout           time.Duration `yaml:"timeout"`
+	MaxRetries        int           `yaml:"max_retries"`
+	ForwardServers    []string      `yaml:"forward_servers"`
+	RootServers       []string      `yaml:"root_servers"`
+	RecursionDepth    int           `yaml:"recursion_depth"`
+	MaxHops           int           `yaml:"max_hops"`           // Referrals followed for one name in recursive mode
+	MaxQueries        int           `yaml:"max_queries"`        // Name server queries sent for one question in recursive mode
+	CaseRandomization bool          `yaml:"case_randomization"` // Randomize forwarded query name case (DNS 0x20)
+	QNameMinimization bool          `yaml:"qname_minimization"` // Send name servers only the labels they need in recursive mode (RFC 7816)
+
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"`
+	UpstreamHealth UpstreamHealthConfig `yaml:"upstream_health"`
+}
+
+// CircuitBreakerConfig holds the thresholds of the circuit breaker of the
+// forward servers. Once they fail FailureThreshold times in a row within
+// FailureWindow, forwarded queries are answered with SERVFAIL without
+// querying them until Cooldown has passed and a probe query succeeds
+type CircuitBreakerConfig struct {
+	FailureThreshold int           `yaml:"failure_threshold"` // Consecutive timeouts or SERVFAILs opening the circuit, 0 disables the breaker
+	FailureWindow    time.Duration `yaml:"failure_window"`
+	Cooldown         time.Duration `yaml:"cooldown"`
+}
+
+// UpstreamHealthConfig holds the health tracking of the single forward
+// servers. A server failing FailureThreshold times in a row is marked down
+// and skipped for Cooldown unless every server is down; meanwhile it is
+// probed every ProbeInterval and marked up again once a probe succeeds
+type UpstreamHealthConfig struct {
+	FailureThreshold int           `yaml:"failure_threshold"` // Consecutive timeouts or SERVFAILs marking a server down, 0 disables tracking
+	Cooldown         time.Duration `yaml:"cooldown"`
+	ProbeInterval    time.Duration `yaml:"probe_interval"` // 0 disables probes
+	ProbeName        string        `yaml:"probe_name"`     // Name whose NS records probes ask for
 }
 
 // StorageConfig holds storage backend configuration
 type StorageConfig struct {
-	Type     string `yaml:"type"` // "memory", "surrealdb"
+	Type     string `yaml:"type"` // "memory", "file", "sqlite", "bolt", "surrealdb"
 	DSN      string `yaml:"dsn"`
 	MaxConns int    `yaml:"max_conns"`
+	AutoPTR  bool   `yaml:"auto_ptr"` // Maintain PTR records for stored A/AAAA records in stored reverse zones
+
+	AllowUnknownTypes bool `yaml:"allow_unknown_types"` // Store records of types without a typed representation, in the generic form of RFC 3597
+
+	QueryTimeout time.Duration `yaml:"query_timeout"` // Deadline of a single lookup while answering a query, 0 disables it
+}
+
+// DNS64Config holds the DNS64 (RFC 6147) configuration. AAAA queries for
+// names with only A records are answered with addresses synthesized under
+// Prefix
+type DNS64Config struct {
+	Prefix string        `yaml:"prefix"`  // IPv6 prefix such as 64:ff9b::/96, empty disables DNS64
+	MaxTTL time.Duration `yaml:"max_ttl"` // Upper bound of the TTL of synthesized records, 0 disables it
+}
+
+// ViewConfig defines a split-horizon view: clients in its networks are
+// answered from the records tagged with its name, falling back to the
+// default view for names the view does not hold
+type ViewConfig struct {
+	Name     string   `yaml:"name"`
+	Networks []string `yaml:"networks"` // Client networks in CIDR notation
+	Priority int      `yaml:"priority"` // Lower values are matched first, ties keep the listed order
+}
+
+// BlocklistConfig holds the configuration of domain blocking. Queries for
+// listed names are answered without resolving them
+type BlocklistConfig struct {
+	Files          []string      `yaml:"files"`           // Lists in hosts or domain-per-line format, none disables blocking
+	Response       string        `yaml:"response"`        // "nxdomain" (default) or "sink"
+	SinkIPv4       string        `yaml:"sink_ipv4"`       // A answer of the sink response
+	SinkIPv6       string        `yaml:"sink_ipv6"`       // AAAA answer of the sink response
+	TTL            time.Duration `yaml:"ttl"`             // TTL of sink answers
+	ReloadInterval time.Duration `yaml:"reload_interval"` // How often changed files are reloaded, 0 disables reloading
+}
+
+// HostsConfig lists hosts files whose names are answered authoritatively,
+// ahead of storage and the resolver
+type HostsConfig struct {
+	Files          []string      `yaml:"files"`           // Files in hosts format, none disables them
+	TTL            time.Duration `yaml:"ttl"`             // TTL of the answers
+	ReloadInterval time.Duration `yaml:"reload_interval"` // How often changed files are reloaded, 0 disables reloading
+}
+
+// ResponsesConfig controls how answers are found in stored zones, what
+// responses carry besides their answers and the TTLs of their records
+type ResponsesConfig struct {
+	WildcardExpansion bool          `yaml:"wildcard_expansion"` // Answer names without records from matching wildcard records
+	Minimal           bool          `yaml:"minimal"`            // Omit authority and additional records except the SOA of negative answers
+	MinTTL            time.Duration `yaml:"min_ttl"`            // Lower TTLs are raised to it, 0 disables it
+	MaxTTL            time.Duration `yaml:"max_ttl"`            // Higher TTLs are lowered to it, 0 disables it
+	AnyQueryMode      string        `yaml:"any_query_mode"`     // "all" records of the name, a "hinfo" record (RFC 8482) or "refused"
+}
+
+// NotifyConfig lists the secondary servers sent a NOTIFY (RFC 1996) when
+// records of a stored zone change
+type NotifyConfig struct {
+	Zones       []NotifyZoneConfig `yaml:"zones,omitempty"`
+	Delay       time.Duration      `yaml:"delay"`        // Changes within it are announced by one NOTIFY
+	Timeout     time.Duration      `yaml:"timeout"`      // Wait for the response to the first attempt, doubled for each retry
+	MaxAttempts int                `yaml:"max_attempts"` // Attempts per secondary before giving up
+}
+
+// NotifyZoneConfig names the secondaries of a zone
+type NotifyZoneConfig struct {
+	Zone        string   `yaml:"zone"`
+	Secondaries []string `yaml:"secondaries"` // host:port addresses
+}
+
+// SecondaryConfig lists the zones transferred (AXFR) from their primaries and
+// kept up to date through the SOA refresh timers and NOTIFY
+type SecondaryConfig struct {
+	Zones   []SecondaryZoneConfig `yaml:"zones,omitempty"`
+	Timeout time.Duration         `yaml:"timeout"` // Wait for each message from a primary
+}
+
+// IDNAConfig controls the handling of internationalized domain names
+type IDNAConfig struct {
+	Normalize bool `yaml:"normalize"` // Look up query names with Unicode labels in their ACE form (xn--)
+}
+
+// EDNSConfig controls the handling of EDNS(0) options
+type EDNSConfig struct {
+	ForwardECS bool          `yaml:"forward_ecs"` // Send the client subnet (ECS, RFC 7871) of queries to the forward servers
+	Cookies    CookiesConfig `yaml:"cookies"`
+}
+
+// CookiesConfig controls DNS Cookies (RFC 7873): queries carrying a client
+// cookie are answered with a server cookie computed from it, the client
+// address and a server secret. Clients sending back a valid server cookie
+// have proven their address and are exempt from response rate limiting
+type CookiesConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	Enforce        bool          `yaml:"enforce"`         // Answer UDP queries with a client cookie but no valid server cookie with BADCOOKIE
+	SecretRotation time.Duration `yaml:"secret_rotation"` // Interval the server secret is replaced at; cookies of the previous secret stay valid for another interval
+}
+
+// RRLConfig limits the rate of UDP responses to each /24 IPv4 and /48 IPv6
+// client network (response rate limiting), against amplification attacks
+type RRLConfig struct {
+	ResponsesPerSecond int           `yaml:"responses_per_second"` // Average rate allowed to a network, 0 disables limiting
+	SlipRate           int           `yaml:"slip_rate"`            // 1 in N limited responses is sent truncated instead of dropped, 0 drops all
+	WindowSize         time.Duration `yaml:"window_size"`          // Bursts of up to a window's worth of responses are allowed
+}
+
+// ZonesConfig lists the master files (RFC 1035 §5) loaded into storage and
+// the TTLs of their records that give none. A record without a TTL takes the
+// one of the last $TTL directive, else the default of its type, else
+// DefaultTTL
+type ZonesConfig struct {
+	Files      []ZoneFileConfig         `yaml:"files,omitempty"`
+	DefaultTTL time.Duration            `yaml:"default_ttl"`
+	TypeTTLs   map[string]time.Duration `yaml:"type_ttls,omitempty"` // Keyed by type mnemonic, such as SOA or MX
+}
+
+// maxTTL is the largest TTL a record can have (RFC 2181 §8)
+const maxTTL = (1<<31 - 1) * time.Second
+
+// ZoneFileConfig names the master file of a zone
+type ZoneFileConfig struct {
+	Zone string `yaml:"zone"` // Origin of the relative names in the file
+	File string `yaml:"file"`
+}
+
+// SecondaryZoneConfig names the primary of a zone and overrides its timers
+type SecondaryZoneConfig struct {
+	Zone    string        `yaml:"zone"`
+	Primary string        `yaml:"primary"` // ip:port address, NOTIFY is accepted from this IP only
+	Refresh time.Duration `yaml:"refresh"` // Overrides the SOA refresh interval, 0 keeps it
+	Retry   time.Duration `yaml:"retry"`   // Overrides the SOA retry interval, 0 keeps it
+	Key     string        `yaml:"key"`     // TSIG key the queries to the primary are signed with, empty sends them unsigned
+}
+
+// TSIGConfig lists the keys messages are signed with (TSIG, RFC 8945)
+type TSIGConfig struct {
+	Keys []TSIGKeyConfig `yaml:"keys,omitempty"`
+}
+
+// TSIGKeyConfig is a secret shared with another server
+type TSIGKeyConfig struct {
+	Name      string `yaml:"name"`
+	Algorithm string `yaml:"algorithm"` // "hmac-sha256" or "hmac-sha512"
+	Secret    string `yaml:"secret"`    // Base64, as printed by tsig-keygen
+}
+
+// TransferConfig controls the zone transfers (AXFR) served from storage
+type TransferConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Key     string `yaml:"key"` // TSIG key transfer requests must be signed with, empty serves unsigned ones
+}
+
+// UpdateConfig controls the dynamic updates (RFC 2136) of zones in storage
+type UpdateConfig struct {
+	Enabled  bool     `yaml:"enabled"`
+	Key      string   `yaml:"key"`                // TSIG key updates must be signed with, empty accepts unsigned ones
+	Networks []string `yaml:"networks,omitempty"` // Client networks in CIDR notation updates are accepted from, empty accepts any
 }
 
 // LoggingConfig holds logging configuration
 type LoggingConfig struct {
-	Level  string `yaml:"level"`  // "debug", "info", "warn", "error"
-	Format string `yaml:"format"` // "json", "text"
-	Output string `yaml:"output"` // "stdout", "stderr", or file path
+	Level      string            `yaml:"level"`                // "debug", "info", "warn", "error"
+	Format     string            `yaml:"format"`               // "json", "text"
+	Output     string            `yaml:"output"`               // "stdout", "stderr", or file path
+	MaxSizeMB  int               `yaml:"max_size_mb"`          // Size of a log file in megabytes before it is rotated, 0 disables rotation
+	MaxBackups int               `yaml:"max_backups"`          // Rotated log files kept
+	Components map[string]string `yaml:"components,omitempty"` // Levels of single components, such as resolver: debug
 }
 
 // CacheConfig holds cache configuration
@@ -65,29 +359,60 @@ func DefaultConfig() *Config {
 	return &Config{
 		Server: ServerConfig{
 			Address:        "127.0.0.1:53",
+			BindFailure:    "fatal",
 			ReadTimeout:    5 * time.Second,
 			WriteTimeout:   5 * time.Second,
+			QueryTimeout:   5 * time.Second,
 			MaxConnections: 1000,
+			NumWorkers:     runtime.NumCPU(),
+			Workers:        runtime.GOMAXPROCS(0),
+			UDPQueueSize:   1024,
 			EnableTCP:      true,
 			EnableUDP:      true,
+			EnableIPv6:     true,
 			EnableMetrics:  true,
 			EnableHealth:   true,
+			StatsWindow:    10 * time.Minute,
+			StatsTopN:      10,
+			Parsing: ParsingConfig{
+				MaxQuestions:   256,
+				MaxRecords:     10000,
+				MaxMessageSize: 65535,
+			},
 		},
 		Resolver: ResolverConfig{
-			Timeout:        5 * time.Second,
-			MaxRetries:     3,
-			ForwardServers: []string{"8.8.8.8:53", "8.8.4.4:53"},
-			RootServers:    []string{},
-			RecursionDepth: 10,
+			Mode:              "forward",
+			Timeout:           5 * time.Second,
+			MaxRetries:        3,
+			ForwardServers:    []string{"8.8.8.8:53", "8.8.4.4:53"},
+			RootServers:       []string{},
+			RecursionDepth:    10,
+			MaxHops:           20,
+			MaxQueries:        100,
+			QNameMinimization: true,
+			CircuitBreaker: CircuitBreakerConfig{
+				FailureThreshold: 5,
+				FailureWindow:    10 * time.Second,
+				Cooldown:         30 * time.Second,
+			},
+			UpstreamHealth: UpstreamHealthConfig{
+				FailureThreshold: 3,
+				Cooldown:         30 * time.Second,
+				ProbeInterval:    5 * time.Second,
+				ProbeName:        ".",
+			},
 		},
 		Storage: StorageConfig{
-			Type:     "memory",
-			MaxConns: 10,
+			Type:         "memory",
+			MaxConns:     10,
+			QueryTimeout: 500 * time.Millisecond,
 		},
 		Logging: LoggingConfig{
-			Level:  "info",
-			Format: "text",
-			Output: "stdout",
+			Level:      "info",
+			Format:     "text",
+			Output:     "stdout",
+			MaxSizeMB:  100,
+			MaxBackups: 3,
 		},
 		Cache: CacheConfig{
 			Enabled: true,
@@ -95,7 +420,79 @@ func DefaultConfig() *Config {
 			TTL:     300 * time.Second,
 			Type:    "lru",
 		},
+		DNS64: DNS64Config{
+			MaxTTL: 600 * time.Second,
+		},
+		Blocklist: BlocklistConfig{
+			Response:       "nxdomain",
+			SinkIPv4:       "0.0.0.0",
+			SinkIPv6:       "::",
+			TTL:            60 * time.Second,
+			ReloadInterval: time.Minute,
+		},
+		Hosts: HostsConfig{
+			TTL:            60 * time.Second,
+			ReloadInterval: time.Minute,
+		},
+		Responses: ResponsesConfig{
+			WildcardExpansion: true,
+			AnyQueryMode:      "all",
+		},
+		Notify: NotifyConfig{
+			Delay:       time.Second,
+			Timeout:     2 * time.Second,
+			MaxAttempts: 5,
+		},
+		Secondary: SecondaryConfig{
+			Timeout: 10 * time.Second,
+		},
+		EDNS: EDNSConfig{
+			Cookies: CookiesConfig{
+				Enabled:        true,
+				SecretRotation: time.Hour,
+			},
+		},
+		RRL: RRLConfig{
+			SlipRate:   2,
+			WindowSize: 15 * time.Second,
+		},
+		Zones: ZonesConfig{
+			DefaultTTL: time.Hour,
+		},
+	}
+}
+
+// Key returns the key named name, matched case-insensitively with or without
+// the trailing dot
+func (c TSIGConfig) Key(name string) (TSIGKeyConfig, bool) {
+	canonical := canonicalKeyName(name)
+	for _, key := range c.Keys {
+		if canonicalKeyName(key.Name) == canonical {
+			return key, true
+		}
+	}
+	return TSIGKeyConfig{}, false
+}
+
+// Keyring returns the decoded secrets of the keys by lowercased name with a
+// trailing dot. Keys whose secret does not decode are left out
+func (c TSIGConfig) Keyring() map[string][]byte {
+	keyring := make(map[string][]byte, len(c.Keys))
+	for _, key := range c.Keys {
+		if secret, err := base64.StdEncoding.DecodeString(key.Secret); err == nil {
+			keyring[canonicalKeyName(key.Name)] = secret
+		}
 	}
+	return keyring
+}
+
+// canonicalKeyName returns name lowercased with a trailing dot
+func canonicalKeyName(name string) string {
+	parsed, err := utils.ParseDomainName(name)
+	if err != nil {
+		return name
+	}
+	return parsed.CanonicalString()
 }
 
 // LoadFromFile loads configuration from a YAML file
@@ -132,16 +529,80 @@ func (c *Config) SaveToFile(filename string) error {
 // Validate validates the configuration
 func (c *Config) Validate() error {
 	// Validate server config
-	if c.Server.Address == "" {
+	if len(c.Server.ListenAddresses()) == 0 {
 		return fmt.Errorf("server address cannot be empty")
 	}
+	for _, address := range c.Server.Addresses {
+		if address == "" {
+			return fmt.Errorf("server address cannot be empty")
+		}
+	}
+	if c.Server.BindFailure != "" && c.Server.BindFailure != "fatal" && c.Server.BindFailure != "warn" {
+		return fmt.Errorf("invalid bind failure mode: %s", c.Server.BindFailure)
+	}
+	if c.Server.NumWorkers < 0 {
+		return fmt.Errorf("number of workers cannot be negative")
+	}
+	if c.Server.Workers < 0 {
+		return fmt.Errorf("number of query workers cannot be negative")
+	}
+	if c.Server.UDPQueueSize < 0 {
+		return fmt.Errorf("UDP queue size cannot be negative")
+	}
+	// The record import and zone snapshots are not authenticated, so only
+	// local clients may reach them
+	if c.Server.RecordImport && !c.Server.healthOnLoopback() {
+		return fmt.Errorf("record import requires a loopback health address, got %s", c.Server.HealthAddress)
+	}
+	if c.Server.ZoneSnapshots && !c.Server.healthOnLoopback() {
+		return fmt.Errorf("zone snapshots require a loopback health address, got %s", c.Server.HealthAddress)
+	}
+	if c.Server.StatsWindow < 0 {
+		return fmt.Errorf("stats window cannot be negative")
+	}
+	if c.Server.StatsTopN < 0 {
+		return fmt.Errorf("stats top N cannot be negative")
+	}
+	for _, network := range c.Server.AllowQuery {
+		if _, _, err := net.ParseCIDR(network); err != nil {
+			return fmt.Errorf("invalid allow-query network: %s", network)
+		}
+	}
+	for _, zone := range c.Server.ZoneAllowQuery {
+		if zone.Zone == "" {
+			return fmt.Errorf("allow-query zone cannot be empty")
+		}
+		for _, network := range zone.Networks {
+			if _, _, err := net.ParseCIDR(network); err != nil {
+				return fmt.Errorf("invalid allow-query network of zone %s: %s", zone.Zone, network)
+			}
+		}
+	}
 
-	// Validate resolver config - no type check needed anymore
+	// Validate resolver config
+	if c.Resolver.Mode != "" && c.Resolver.Mode != "recursive" && c.Resolver.Mode != "iterative" && c.Resolver.Mode != "forward" && c.Resolver.Mode != "stub" {
+		return fmt.Errorf("invalid resolver mode: %s", c.Resolver.Mode)
+	}
+	if c.Resolver.CircuitBreaker.FailureThreshold < 0 {
+		return fmt.Errorf("circuit breaker failure threshold cannot be negative")
+	}
+	if c.Resolver.CircuitBreaker.FailureThreshold > 0 && (c.Resolver.CircuitBreaker.FailureWindow <= 0 || c.Resolver.CircuitBreaker.Cooldown <= 0) {
+		return fmt.Errorf("circuit breaker failure window and cooldown must be positive")
+	}
+	if c.Resolver.UpstreamHealth.FailureThreshold < 0 {
+		return fmt.Errorf("upstream health failure threshold cannot be negative")
+	}
+	if c.Resolver.UpstreamHealth.FailureThreshold > 0 && (c.Resolver.UpstreamHealth.Cooldown <= 0 || c.Resolver.UpstreamHealth.ProbeInterval < 0) {
+		return fmt.Errorf("upstream health cooldown must be positive and probe interval cannot be negative")
+	}
 
 	// Validate storage config
-	if c.Storage.Type != "memory" && c.Storage.Type != "sqlite" && c.Storage.Type != "postgres" && c.Storage.Type != "redis" {
+	if c.Storage.Type != "memory" && c.Storage.Type != "file" && c.Storage.Type != "sqlite" && c.Storage.Type != "bolt" && c.Storage.Type != "postgres" && c.Storage.Type != "redis" {
 		return fmt.Errorf("invalid storage type: %s", c.Storage.Type)
 	}
+	if c.Storage.QueryTimeout < 0 {
+		return fmt.Errorf("storage query timeout cannot be negative")
+	}
 
 	// Validate logging config
 	if c.Logging.Level != "debug" && c.Logging.Level != "info" && c.Logging.Level != "warn" && c.Logging.Level != "error" {
@@ -152,35 +613,198 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid log format: %s", c.Logging.Format)
 	}
 
+	for component, level := range c.Logging.Components {
+		if level != "debug" && level != "info" && level != "warn" && level != "error" {
+			return fmt.Errorf("invalid log level of component %s: %s", component, level)
+		}
+	}
+
 	// Validate cache config
 	if c.Cache.Type != "lru" && c.Cache.Type != "lfu" && c.Cache.Type != "ttl" {
 		return fmt.Errorf("invalid cache type: %s", c.Cache.Type)
 	}
 
+	// Validate DNS64 config
+	if c.DNS64.Prefix != "" {
+		if _, err := utils.ParseDNS64Prefix(c.DNS64.Prefix); err != nil {
+			return err
+		}
+	}
+
+	// Validate TTL clamping
+	if c.Responses.MinTTL < 0 || c.Responses.MaxTTL < 0 {
+		return fmt.Errorf("response TTL bounds cannot be negative")
+	}
+	if c.Responses.MaxTTL > 0 && c.Responses.MinTTL > c.Responses.MaxTTL {
+		return fmt.Errorf("response min TTL %s exceeds max TTL %s", c.Responses.MinTTL, c.Responses.MaxTTL)
+	}
+	if mode := c.Responses.AnyQueryMode; mode != "" && mode != "all" && mode != "hinfo" && mode != "refused" {
+		return fmt.Errorf("invalid ANY query mode: %s", mode)
+	}
+
+	// Validate NOTIFY config
+	for _, zone := range c.Notify.Zones {
+		if zone.Zone == "" {
+			return fmt.Errorf("notify zone cannot be empty")
+		}
+		for _, secondary := range zone.Secondaries {
+			if _, _, err := net.SplitHostPort(secondary); err != nil {
+				return fmt.Errorf("invalid secondary address %q of notify zone %s: %w", secondary, zone.Zone, err)
+			}
+		}
+	}
+	if c.Notify.Delay < 0 || c.Notify.Timeout < 0 || c.Notify.MaxAttempts < 0 {
+		return fmt.Errorf("notify delay, timeout and attempts cannot be negative")
+	}
+
+	// Validate secondary zones
+	for _, zone := range c.Secondary.Zones {
+		if zone.Zone == "" {
+			return fmt.Errorf("secondary zone cannot be empty")
+		}
+		host, _, err := net.SplitHostPort(zone.Primary)
+		if err != nil || net.ParseIP(host) == nil {
+			return fmt.Errorf("invalid primary address %q of secondary zone %s", zone.Primary, zone.Zone)
+		}
+		if zone.Refresh < 0 || zone.Retry < 0 {
+			return fmt.Errorf("refresh and retry of secondary zone %s cannot be negative", zone.Zone)
+		}
+	}
+	if c.Secondary.Timeout < 0 {
+		return fmt.Errorf("secondary timeout cannot be negative")
+	}
+
+	// Validate TSIG keys and their uses
+	for _, key := range c.TSIG.Keys {
+		if _, err := utils.ParseDomainName(key.Name); err != nil || key.Name == "" {
+			return fmt.Errorf("invalid TSIG key name: %q", key.Name)
+		}
+		if key.Algorithm != "hmac-sha256" && key.Algorithm != "hmac-sha512" {
+			return fmt.Errorf("invalid algorithm of TSIG key %s: %s", key.Name, key.Algorithm)
+		}
+		if secret, err := base64.StdEncoding.DecodeString(key.Secret); err != nil || len(secret) == 0 {
+			return fmt.Errorf("invalid secret of TSIG key %s", key.Name)
+		}
+	}
+	if _, ok := c.TSIG.Key(c.Transfer.Key); c.Transfer.Key != "" && !ok {
+		return fmt.Errorf("unknown transfer TSIG key: %s", c.Transfer.Key)
+	}
+	for _, zone := range c.Secondary.Zones {
+		if _, ok := c.TSIG.Key(zone.Key); zone.Key != "" && !ok {
+			return fmt.Errorf("unknown TSIG key %s of secondary zone %s", zone.Key, zone.Zone)
+		}
+	}
+
+	// Validate dynamic updates, which must be restricted to a key or networks
+	if _, ok := c.TSIG.Key(c.Update.Key); c.Update.Key != "" && !ok {
+		return fmt.Errorf("unknown update TSIG key: %s", c.Update.Key)
+	}
+	if c.Update.Enabled && c.Update.Key == "" && len(c.Update.Networks) == 0 {
+		return fmt.Errorf("dynamic updates need a TSIG key or networks")
+	}
+	for _, network := range c.Update.Networks {
+		if _, _, err := net.ParseCIDR(network); err != nil {
+			return fmt.Errorf("invalid update network: %s", network)
+		}
+	}
+
+	// Validate DNS cookies
+	if c.EDNS.Cookies.Enabled && c.EDNS.Cookies.SecretRotation <= 0 {
+		return fmt.Errorf("cookie secret rotation must be positive")
+	}
+	if c.EDNS.Cookies.Enforce && !c.EDNS.Cookies.Enabled {
+		return fmt.Errorf("cookie enforcement needs cookies enabled")
+	}
+
+	// Validate response rate limiting
+	if c.RRL.ResponsesPerSecond < 0 {
+		return fmt.Errorf("RRL responses per second cannot be negative")
+	}
+	if c.RRL.SlipRate < 0 {
+		return fmt.Errorf("RRL slip rate cannot be negative")
+	}
+	if c.RRL.ResponsesPerSecond > 0 && c.RRL.WindowSize <= 0 {
+		return fmt.Errorf("RRL window size must be positive")
+	}
+
+	// Validate blocklist config
+	if c.Blocklist.Response != "" && c.Blocklist.Response != "nxdomain" && c.Blocklist.Response != "sink" {
+		return fmt.Errorf("invalid blocklist response: %s", c.Blocklist.Response)
+	}
+
+	// Validate hosts config
+	if c.Hosts.TTL < 0 {
+		return fmt.Errorf("hosts TTL cannot be negative")
+	}
+	if c.Hosts.ReloadInterval < 0 {
+		return fmt.Errorf("hosts reload interval cannot be negative")
+	}
+
+	// Validate zone files and the TTLs of their records
+	for _, zone := range c.Zones.Files {
+		if _, err := utils.ParseDomainName(zone.Zone); err != nil {
+			return fmt.Errorf("invalid zone of zone file %s: %w", zone.File, err)
+		}
+		if zone.File == "" {
+			return fmt.Errorf("zone %s has no zone file", zone.Zone)
+		}
+	}
+	if c.Zones.DefaultTTL < 0 || c.Zones.DefaultTTL > maxTTL {
+		return fmt.Errorf("invalid zones default TTL: %v", c.Zones.DefaultTTL)
+	}
+	for name, ttl := range c.Zones.TypeTTLs {
+		if _, ok := types.ParseDNSType(name); !ok {
+			return fmt.Errorf("unknown record type in zones type TTLs: %s", name)
+		}
+		if ttl < 0 || ttl > maxTTL {
+			return fmt.Errorf("invalid zones TTL of %s records: %v", name, ttl)
+		}
+	}
+
+	// Validate views
+	names := make(map[string]bool, len(c.Views))
+	for _, view := range c.Views {
+		if view.Name == "" || view.Name == "default" {
+			return fmt.Errorf("invalid view name: %q", view.Name)
+		}
+		if names[view.Name] {
+			return fmt.Errorf("duplicate view: %s", view.Name)
+		}
+		names[view.Name] = true
+
+		for _, network := range view.Networks {
+			if _, _, err := net.ParseCIDR(network); err != nil {
+				return fmt.Errorf("invalid network of view %s: %w", view.Name, err)
+			}
+		}
+	}
+
 	return nil
 }
 
 // GetServerAddress returns the server address with default port if not specified
 func (c *Config) GetServerAddress() string {
-	if c.Server.Address == "" {
+	addresses := c.Server.ListenAddresses()
+	if len(addresses) == 0 {
 		return "127.0.0.1:53"
 	}
-	return c.Server.Address
+	return addresses[0]
 }
 
-// IsResolverRecursive returns true if resolver type is recursive - deprecated, always false
+// IsResolverRecursive returns true if the resolver resolves names from the
+// root servers. "iterative" is another name of the recursive mode
 func (c *Config) IsResolverRecursive() bool {
-	return false
+	return c.Resolver.Mode == "recursive" || c.Resolver.Mode == "iterative"
 }
 
-// IsResolverForward returns true if resolver type is forward - deprecated, always true
+// IsResolverForward returns true if the resolver forwards queries to the forward servers
 func (c *Config) IsResolverForward() bool {
-	return true
+	return !c.IsResolverRecursive()
 }
 
-// IsResolverCache returns true if resolver type is cache - deprecated, always true
+// IsResolverCache returns true if resolved answers are cached
 func (c *Config) IsResolverCache() bool {
-	return true
+	return c.Resolver.Mode != "stub"
 }
 
 // IsStorageMemory returns true if storage type is memory
@@ -193,6 +817,11 @@ func (c *Config) IsStorageSQLite() bool {
 	return c.Storage.Type == "sqlite"
 }
 
+// IsStorageBolt returns true if storage type is bbolt
+func (c *Config) IsStorageBolt() bool {
+	return c.Storage.Type == "bolt"
+}
+
 // IsStoragePostgres returns true if storage type is PostgreSQL
 func (c *Config) IsStoragePostgres() bool {
 	return c.Storage.Type == "postgres"
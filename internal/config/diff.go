@@ -0,0 +1,90 @@
+package config
+
+import "reflect"
+
+// ConfigDiff reports which sections of two configurations differ
+type ConfigDiff struct {
+	Server    bool
+	Resolver  bool
+	Storage   bool
+	Logging   bool
+	Cache     bool
+	DNS64     bool
+	Views     bool
+	Blocklist bool
+	Hosts     bool
+	Responses bool
+	Notify    bool
+	Secondary bool
+	IDNA      bool
+	TSIG      bool
+	Transfer  bool
+	Update    bool
+	EDNS      bool
+	RRL       bool
+	Zones     bool
+}
+
+// Diff compares c with other section by section
+func (c *Config) Diff(other *Config) ConfigDiff {
+	return ConfigDiff{
+		Server:    !reflect.DeepEqual(c.Server, other.Server),
+		Resolver:  !reflect.DeepEqual(c.Resolver, other.Resolver),
+		Storage:   !reflect.DeepEqual(c.Storage, other.Storage),
+		Logging:   !reflect.DeepEqual(c.Logging, other.Logging),
+		Cache:     !reflect.DeepEqual(c.Cache, other.Cache),
+		DNS64:     !reflect.DeepEqual(c.DNS64, other.DNS64),
+		Views:     !reflect.DeepEqual(c.Views, other.Views),
+		Blocklist: !reflect.DeepEqual(c.Blocklist, other.Blocklist),
+		Hosts:     !reflect.DeepEqual(c.Hosts, other.Hosts),
+		Responses: !reflect.DeepEqual(c.Responses, other.Responses),
+		Notify:    !reflect.DeepEqual(c.Notify, other.Notify),
+		Secondary: !reflect.DeepEqual(c.Secondary, other.Secondary),
+		IDNA:      !reflect.DeepEqual(c.IDNA, other.IDNA),
+		TSIG:      !reflect.DeepEqual(c.TSIG, other.TSIG),
+		Transfer:  !reflect.DeepEqual(c.Transfer, other.Transfer),
+		Update:    !reflect.DeepEqual(c.Update, other.Update),
+		EDNS:      !reflect.DeepEqual(c.EDNS, other.EDNS),
+		RRL:       !reflect.DeepEqual(c.RRL, other.RRL),
+		Zones:     !reflect.DeepEqual(c.Zones, other.Zones),
+	}
+}
+
+// Sections returns the yaml names of the changed sections
+func (d ConfigDiff) Sections() []string {
+	var sections []string
+	for _, section := range []struct {
+		name    string
+		changed bool
+	}{
+		{"server", d.Server},
+		{"resolver", d.Resolver},
+		{"storage", d.Storage},
+		{"logging", d.Logging},
+		{"cache", d.Cache},
+		{"dns64", d.DNS64},
+		{"views", d.Views},
+		{"blocklist", d.Blocklist},
+		{"hosts", d.Hosts},
+		{"responses", d.Responses},
+		{"notify", d.Notify},
+		{"secondary", d.Secondary},
+		{"idna", d.IDNA},
+		{"tsig", d.TSIG},
+		{"transfer", d.Transfer},
+		{"update", d.Update},
+		{"edns", d.EDNS},
+		{"rrl", d.RRL},
+		{"zones", d.Zones},
+	} {
+		if section.changed {
+			sections = append(sections, section.name)
+		}
+	}
+	return sections
+}
+
+// Empty reports whether no section changed
+func (d ConfigDiff) Empty() bool {
+	return len(d.Sections()) == 0
+}
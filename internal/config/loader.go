@@ -103,6 +103,17 @@ func (l *Loader) loadFromEnv(config *Config) error {
 	// Server configuration
 	if addr := os.Getenv(l.envPrefix + "SERVER_ADDRESS"); addr != "" {
 		config.Server.Address = addr
+		config.Server.Addresses = nil
+	}
+	if addrs := os.Getenv(l.envPrefix + "SERVER_ADDRESSES"); addrs != "" {
+		config.Server.Addresses = strings.Split(addrs, ",")
+		for i, addr := range config.Server.Addresses {
+			config.Server.Addresses[i] = strings.TrimSpace(addr)
+		}
+		config.Server.Address = config.Server.Addresses[0]
+	}
+	if mode := os.Getenv(l.envPrefix + "SERVER_BIND_FAILURE"); mode != "" {
+		config.Server.BindFailure = mode
 	}
 	if timeout := os.Getenv(l.envPrefix + "SERVER_READ_TIMEOUT"); timeout != "" {
 		if d, err := time.ParseDuration(timeout); err == nil {
@@ -119,8 +130,50 @@ func (l *Loader) loadFromEnv(config *Config) error {
 			config.Server.MaxConnections = i
 		}
 	}
+	if addr := os.Getenv(l.envPrefix + "SERVER_HEALTH_ADDRESS"); addr != "" {
+		config.Server.HealthAddress = addr
+	}
+	if workers := os.Getenv(l.envPrefix + "SERVER_NUM_WORKERS"); workers != "" {
+		if i, err := strconv.Atoi(workers); err == nil {
+			config.Server.NumWorkers = i
+		}
+	}
+	if workers := os.Getenv(l.envPrefix + "SERVER_WORKERS"); workers != "" {
+		if i, err := strconv.Atoi(workers); err == nil {
+			config.Server.Workers = i
+		}
+	}
+	if size := os.Getenv(l.envPrefix + "SERVER_UDP_QUEUE_SIZE"); size != "" {
+		if i, err := strconv.Atoi(size); err == nil {
+			config.Server.UDPQueueSize = i
+		}
+	}
+	if window := os.Getenv(l.envPrefix + "SERVER_STATS_WINDOW"); window != "" {
+		if d, err := time.ParseDuration(window); err == nil {
+			config.Server.StatsWindow = d
+		}
+	}
+	if topN := os.Getenv(l.envPrefix + "SERVER_STATS_TOP_N"); topN != "" {
+		if i, err := strconv.Atoi(topN); err == nil {
+			config.Server.StatsTopN = i
+		}
+	}
+	if networks := os.Getenv(l.envPrefix + "SERVER_ALLOW_QUERY"); networks != "" {
+		config.Server.AllowQuery = strings.Split(networks, ",")
+		for i, network := range config.Server.AllowQuery {
+			config.Server.AllowQuery[i] = strings.TrimSpace(network)
+		}
+	}
+	if drop := os.Getenv(l.envPrefix + "SERVER_DROP_REFUSED"); drop != "" {
+		if b, err := strconv.ParseBool(drop); err == nil {
+			config.Server.DropRefused = b
+		}
+	}
 
-	// Resolver configuration - type no longer configurable
+	// Resolver configuration
+	if mode := os.Getenv(l.envPrefix + "RESOLVER_MODE"); mode != "" {
+		config.Resolver.Mode = mode
+	}
 	if timeout := os.Getenv(l.envPrefix + "RESOLVER_TIMEOUT"); timeout != "" {
 		if d, err := time.ParseDuration(timeout); err == nil {
 			config.Resolver.Timeout = d
@@ -140,6 +193,11 @@ func (l *Loader) loadFromEnv(config *Config) error {
 	if dsn := os.Getenv(l.envPrefix + "STORAGE_DSN"); dsn != "" {
 		config.Storage.DSN = dsn
 	}
+	if timeout := os.Getenv(l.envPrefix + "STORAGE_QUERY_TIMEOUT"); timeout != "" {
+		if d, err := time.ParseDuration(timeout); err == nil {
+			config.Storage.QueryTimeout = d
+		}
+	}
 
 	// Logging configuration
 	if level := os.Getenv(l.envPrefix + "LOG_LEVEL"); level != "" {
@@ -169,6 +227,145 @@ func (l *Loader) loadFromEnv(config *Config) error {
 		}
 	}
 
+	// Blocklist configuration
+	if files := os.Getenv(l.envPrefix + "BLOCKLIST_FILES"); files != "" {
+		config.Blocklist.Files = strings.Split(files, ",")
+		for i, file := range config.Blocklist.Files {
+			config.Blocklist.Files[i] = strings.TrimSpace(file)
+		}
+	}
+	if response := os.Getenv(l.envPrefix + "BLOCKLIST_RESPONSE"); response != "" {
+		config.Blocklist.Response = response
+	}
+
+	// Hosts configuration
+	if files := os.Getenv(l.envPrefix + "HOSTS_FILES"); files != "" {
+		config.Hosts.Files = strings.Split(files, ",")
+		for i, file := range config.Hosts.Files {
+			config.Hosts.Files[i] = strings.TrimSpace(file)
+		}
+	}
+
+	// DNS64 configuration
+	if prefix := os.Getenv(l.envPrefix + "DNS64_PREFIX"); prefix != "" {
+		config.DNS64.Prefix = prefix
+	}
+	if ttl := os.Getenv(l.envPrefix + "DNS64_MAX_TTL"); ttl != "" {
+		if d, err := time.ParseDuration(ttl); err == nil {
+			config.DNS64.MaxTTL = d
+		}
+	}
+
+	// Responses configuration
+	if wildcards := os.Getenv(l.envPrefix + "RESPONSES_WILDCARD_EXPANSION"); wildcards != "" {
+		if b, err := strconv.ParseBool(wildcards); err == nil {
+			config.Responses.WildcardExpansion = b
+		}
+	}
+	if minimal := os.Getenv(l.envPrefix + "RESPONSES_MINIMAL"); minimal != "" {
+		if b, err := strconv.ParseBool(minimal); err == nil {
+			config.Responses.Minimal = b
+		}
+	}
+	if ttl := os.Getenv(l.envPrefix + "RESPONSES_MIN_TTL"); ttl != "" {
+		if d, err := time.ParseDuration(ttl); err == nil {
+			config.Responses.MinTTL = d
+		}
+	}
+	if ttl := os.Getenv(l.envPrefix + "RESPONSES_MAX_TTL"); ttl != "" {
+		if d, err := time.ParseDuration(ttl); err == nil {
+			config.Responses.MaxTTL = d
+		}
+	}
+	if mode := os.Getenv(l.envPrefix + "RESPONSES_ANY_QUERY_MODE"); mode != "" {
+		config.Responses.AnyQueryMode = mode
+	}
+
+	// NOTIFY configuration
+	if delay := os.Getenv(l.envPrefix + "NOTIFY_DELAY"); delay != "" {
+		if d, err := time.ParseDuration(delay); err == nil {
+			config.Notify.Delay = d
+		}
+	}
+	if timeout := os.Getenv(l.envPrefix + "NOTIFY_TIMEOUT"); timeout != "" {
+		if d, err := time.ParseDuration(timeout); err == nil {
+			config.Notify.Timeout = d
+		}
+	}
+	if attempts := os.Getenv(l.envPrefix + "NOTIFY_MAX_ATTEMPTS"); attempts != "" {
+		if i, err := strconv.Atoi(attempts); err == nil {
+			config.Notify.MaxAttempts = i
+		}
+	}
+
+	// Secondary zone configuration
+	if timeout := os.Getenv(l.envPrefix + "SECONDARY_TIMEOUT"); timeout != "" {
+		if d, err := time.ParseDuration(timeout); err == nil {
+			config.Secondary.Timeout = d
+		}
+	}
+
+	// IDNA configuration
+	if normalize := os.Getenv(l.envPrefix + "IDNA_NORMALIZE"); normalize != "" {
+		if b, err := strconv.ParseBool(normalize); err == nil {
+			config.IDNA.Normalize = b
+		}
+	}
+
+	// EDNS configuration
+	if forward := os.Getenv(l.envPrefix + "EDNS_FORWARD_ECS"); forward != "" {
+		if b, err := strconv.ParseBool(forward); err == nil {
+			config.EDNS.ForwardECS = b
+		}
+	}
+	if enabled := os.Getenv(l.envPrefix + "EDNS_COOKIES_ENABLED"); enabled != "" {
+		if b, err := strconv.ParseBool(enabled); err == nil {
+			config.EDNS.Cookies.Enabled = b
+		}
+	}
+	if enforce := os.Getenv(l.envPrefix + "EDNS_COOKIES_ENFORCE"); enforce != "" {
+		if b, err := strconv.ParseBool(enforce); err == nil {
+			config.EDNS.Cookies.Enforce = b
+		}
+	}
+
+	// Response rate limiting configuration
+	if rate := os.Getenv(l.envPrefix + "RRL_RESPONSES_PER_SECOND"); rate != "" {
+		if i, err := strconv.Atoi(rate); err == nil {
+			config.RRL.ResponsesPerSecond = i
+		}
+	}
+	if slip := os.Getenv(l.envPrefix + "RRL_SLIP_RATE"); slip != "" {
+		if i, err := strconv.Atoi(slip); err == nil {
+			config.RRL.SlipRate = i
+		}
+	}
+	if window := os.Getenv(l.envPrefix + "RRL_WINDOW_SIZE"); window != "" {
+		if d, err := time.ParseDuration(window); err == nil {
+			config.RRL.WindowSize = d
+		}
+	}
+
+	// Zone transfer configuration
+	if enabled := os.Getenv(l.envPrefix + "TRANSFER_ENABLED"); enabled != "" {
+		if b, err := strconv.ParseBool(enabled); err == nil {
+			config.Transfer.Enabled = b
+		}
+	}
+	if key := os.Getenv(l.envPrefix + "TRANSFER_KEY"); key != "" {
+		config.Transfer.Key = key
+	}
+
+	// Dynamic update configuration
+	if enabled := os.Getenv(l.envPrefix + "UPDATE_ENABLED"); enabled != "" {
+		if b, err := strconv.ParseBool(enabled); err == nil {
+			config.Update.Enabled = b
+		}
+	}
+	if key := os.Getenv(l.envPrefix + "UPDATE_KEY"); key != "" {
+		config.Update.Key = key
+	}
+
 	return nil
 }
 
@@ -1,11 +1,15 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
 	"net"
 	"net/url"
 	"strconv"
 	"strings"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
 )
 
 // Validator handles configuration validation
@@ -16,58 +20,99 @@ func NewValidator() *Validator {
 	return &Validator{}
 }
 
-// ValidateConfig performs comprehensive validation of the configuration
+// ValidateConfig performs comprehensive validation of the configuration,
+// returning the first problem found
 func (v *Validator) ValidateConfig(config *Config) error {
 	if config == nil {
 		return fmt.Errorf("configuration cannot be nil")
 	}
 
-	// Validate server configuration
-	if err := v.ValidateServerConfig(&config.Server); err != nil {
-		return fmt.Errorf("server config validation failed: %w", err)
-	}
-
-	// Validate resolver configuration
-	if err := v.ValidateResolverConfig(&config.Resolver); err != nil {
-		return fmt.Errorf("resolver config validation failed: %w", err)
-	}
-
-	// Validate storage configuration
-	if err := v.ValidateStorageConfig(&config.Storage); err != nil {
-		return fmt.Errorf("storage config validation failed: %w", err)
+	for _, section := range v.sections(config) {
+		if err := section.validate(); err != nil {
+			return fmt.Errorf("%s validation failed: %w", section.name, err)
+		}
 	}
+	return nil
+}
 
-	// Validate logging configuration
-	if err := v.ValidateLoggingConfig(&config.Logging); err != nil {
-		return fmt.Errorf("logging config validation failed: %w", err)
-	}
+// configSection is a part of the configuration validated on its own
+type configSection struct {
+	name     string
+	validate func() error
+}
 
-	// Validate cache configuration
-	if err := v.ValidateCacheConfig(&config.Cache); err != nil {
-		return fmt.Errorf("cache config validation failed: %w", err)
+// sections returns the parts of config in the order they are validated
+func (v *Validator) sections(config *Config) []configSection {
+	return []configSection{
+		{"server config", func() error { return v.ValidateServerConfig(&config.Server) }},
+		{"resolver config", func() error { return v.ValidateResolverConfig(&config.Resolver) }},
+		{"storage config", func() error { return v.ValidateStorageConfig(&config.Storage) }},
+		{"logging config", func() error { return v.ValidateLoggingConfig(&config.Logging) }},
+		{"cache config", func() error { return v.ValidateCacheConfig(&config.Cache) }},
+		{"dns64 config", func() error { return v.ValidateDNS64Config(&config.DNS64) }},
+		{"blocklist config", func() error { return v.ValidateBlocklistConfig(&config.Blocklist) }},
+		{"hosts config", func() error { return v.ValidateHostsConfig(&config.Hosts) }},
+		{"responses config", func() error { return v.ValidateResponsesConfig(&config.Responses) }},
+		{"notify config", func() error { return v.ValidateNotifyConfig(&config.Notify) }},
+		{"secondary config", func() error {
+			if err := v.ValidateSecondaryConfig(&config.Secondary); err != nil {
+				return err
+			}
+			for _, zone := range config.Secondary.Zones {
+				if _, ok := config.TSIG.Key(zone.Key); zone.Key != "" && !ok {
+					return fmt.Errorf("unknown TSIG key %s of zone %s", zone.Key, zone.Zone)
+				}
+			}
+			return nil
+		}},
+		{"tsig config", func() error { return v.ValidateTSIGConfig(&config.TSIG) }},
+		{"transfer config", func() error { return v.ValidateTransferConfig(&config.Transfer, &config.TSIG) }},
+		{"update config", func() error { return v.ValidateUpdateConfig(&config.Update, &config.TSIG) }},
+		{"edns config", func() error { return v.ValidateEDNSConfig(&config.EDNS) }},
+		{"rrl config", func() error { return v.ValidateRRLConfig(&config.RRL) }},
+		{"zones config", func() error { return v.ValidateZonesConfig(&config.Zones) }},
+		{"views", func() error { return v.ValidateViews(config.Views) }},
 	}
-
-	return nil
 }
 
 // ValidateServerConfig validates server-specific configuration
 func (v *Validator) ValidateServerConfig(config *ServerConfig) error {
-	// Validate address
-	if config.Address == "" {
+	// Validate addresses
+	addresses := config.ListenAddresses()
+	if len(addresses) == 0 {
 		return fmt.Errorf("server address cannot be empty")
 	}
 
-	host, port, err := net.SplitHostPort(config.Address)
-	if err != nil {
-		return fmt.Errorf("invalid server address format: %w", err)
+	seen := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		if err := v.validateListenAddress(address); err != nil {
+			return err
+		}
+		if seen[address] {
+			return fmt.Errorf("duplicate server address: %s", address)
+		}
+		seen[address] = true
 	}
 
-	if net.ParseIP(host) == nil && host != "localhost" && host != "" {
-		return fmt.Errorf("invalid server host: %s", host)
+	switch config.BindFailure {
+	case "", "fatal", "warn":
+	default:
+		return fmt.Errorf("invalid bind failure mode: %s (must be fatal or warn)", config.BindFailure)
 	}
 
-	if portNum, err := strconv.Atoi(port); err != nil || portNum < 1 || portNum > 65535 {
-		return fmt.Errorf("invalid server port: %s", port)
+	if config.HealthAddress != "" {
+		if _, _, err := net.SplitHostPort(config.HealthAddress); err != nil {
+			return fmt.Errorf("invalid health address format: %w", err)
+		}
+	}
+
+	// The record import and zone snapshots are not authenticated, so only
+	// local clients may reach them
+	if config.RecordImport && !config.healthOnLoopback() {
+		return fmt.Errorf("record import requires a loopback health address, got %s", config.HealthAddress)
+	}
+	if config.ZoneSnapshots && !config.healthOnLoopback() {
+		return fmt.Errorf("zone snapshots require a loopback health address, got %s", config.HealthAddress)
 	}
 
 	// Validate timeouts
@@ -82,13 +127,74 @@ func (v *Validator) ValidateServerConfig(config *ServerConfig) error {
 	if config.MaxConnections < 0 {
 		return fmt.Errorf("max connections cannot be negative")
 	}
+	if config.NumWorkers < 0 {
+		return fmt.Errorf("number of workers cannot be negative")
+	}
+	if config.Workers < 0 {
+		return fmt.Errorf("number of query workers cannot be negative")
+	}
+	if config.UDPQueueSize < 0 {
+		return fmt.Errorf("UDP queue size cannot be negative")
+	}
+
+	// Validate query statistics
+	if config.StatsWindow < 0 {
+		return fmt.Errorf("stats window cannot be negative")
+	}
+	if config.StatsTopN < 0 {
+		return fmt.Errorf("stats top N cannot be negative")
+	}
+
+	// Validate parsing limits
+	if config.Parsing.MaxQuestions < 0 {
+		return fmt.Errorf("max questions cannot be negative")
+	}
+	if config.Parsing.MaxRecords < 0 {
+		return fmt.Errorf("max records cannot be negative")
+	}
+	if config.Parsing.MaxMessageSize < 0 {
+		return fmt.Errorf("max message size cannot be negative")
+	}
+
+	// Validate query access control
+	for _, network := range config.AllowQuery {
+		if _, _, err := net.ParseCIDR(network); err != nil {
+			return fmt.Errorf("invalid allow-query network %s: %w", network, err)
+		}
+	}
+	zones := make(map[string]bool, len(config.ZoneAllowQuery))
+	for _, zone := range config.ZoneAllowQuery {
+		if zone.Zone == "" {
+			return fmt.Errorf("allow-query zone cannot be empty")
+		}
+		name := strings.ToLower(strings.TrimSuffix(zone.Zone, "."))
+		if zones[name] {
+			return fmt.Errorf("duplicate allow-query zone: %s", zone.Zone)
+		}
+		zones[name] = true
+		for _, network := range zone.Networks {
+			if _, _, err := net.ParseCIDR(network); err != nil {
+				return fmt.Errorf("invalid allow-query network %s of zone %s: %w", network, zone.Zone, err)
+			}
+		}
+	}
 
 	return nil
 }
 
 // ValidateResolverConfig validates resolver-specific configuration
 func (v *Validator) ValidateResolverConfig(config *ResolverConfig) error {
-	// No type validation needed - always using cached forward resolver
+	// Validate mode
+	validModes := map[string]bool{
+		"":          true,
+		"recursive": true,
+		"iterative": true,
+		"forward":   true,
+		"stub":      true,
+	}
+	if !validModes[config.Mode] {
+		return fmt.Errorf("invalid resolver mode: %s", config.Mode)
+	}
 
 	// Validate timeout
 	if config.Timeout <= 0 {
@@ -122,6 +228,47 @@ func (v *Validator) ValidateResolverConfig(config *ResolverConfig) error {
 		return fmt.Errorf("recursion depth too high: %d (max recommended: 50)", config.RecursionDepth)
 	}
 
+	// Validate max hops
+	if config.MaxHops < 0 {
+		return fmt.Errorf("max hops cannot be negative")
+	}
+
+	// Validate max queries
+	if config.MaxQueries < 0 {
+		return fmt.Errorf("max queries cannot be negative")
+	}
+
+	// Validate circuit breaker
+	if config.CircuitBreaker.FailureThreshold < 0 {
+		return fmt.Errorf("circuit breaker failure threshold cannot be negative")
+	}
+	if config.CircuitBreaker.FailureThreshold > 0 {
+		if config.CircuitBreaker.FailureWindow <= 0 {
+			return fmt.Errorf("circuit breaker failure window must be positive")
+		}
+		if config.CircuitBreaker.Cooldown <= 0 {
+			return fmt.Errorf("circuit breaker cooldown must be positive")
+		}
+	}
+
+	// Validate upstream health tracking
+	if config.UpstreamHealth.FailureThreshold < 0 {
+		return fmt.Errorf("upstream health failure threshold cannot be negative")
+	}
+	if config.UpstreamHealth.FailureThreshold > 0 {
+		if config.UpstreamHealth.Cooldown <= 0 {
+			return fmt.Errorf("upstream health cooldown must be positive")
+		}
+		if config.UpstreamHealth.ProbeInterval < 0 {
+			return fmt.Errorf("upstream health probe interval cannot be negative")
+		}
+		if name := config.UpstreamHealth.ProbeName; name != "" && name != "." {
+			if _, err := utils.NewDomainNameFromString(name); err != nil {
+				return fmt.Errorf("invalid upstream health probe name %q: %w", name, err)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -130,16 +277,22 @@ func (v *Validator) ValidateStorageConfig(config *StorageConfig) error {
 	// Validate storage type
 	validTypes := map[string]bool{
 		"memory":   true,
+		"file":     true,
 		"sqlite":   true,
+		"bolt":     true,
 		"postgres": true,
 		"redis":    true,
 	}
 	if !validTypes[config.Type] {
-		return fmt.Errorf("invalid storage type: %s (must be memory, sqlite, postgres, or redis)", config.Type)
+		return fmt.Errorf("invalid storage type: %s (must be memory, file, sqlite, bolt, postgres, or redis)", config.Type)
 	}
 
 	// Validate DSN based on storage type
 	switch config.Type {
+	case "file":
+		if config.DSN == "" {
+			return fmt.Errorf("DSN required for file storage: path of the records file")
+		}
 	case "sqlite":
 		if config.DSN == "" {
 			return fmt.Errorf("DSN required for SQLite storage")
@@ -147,6 +300,10 @@ func (v *Validator) ValidateStorageConfig(config *StorageConfig) error {
 		if !strings.HasSuffix(config.DSN, ".db") && !strings.Contains(config.DSN, ":memory:") {
 			return fmt.Errorf("invalid SQLite DSN format")
 		}
+	case "bolt":
+		if config.DSN == "" {
+			return fmt.Errorf("DSN required for bbolt storage: path of the database file")
+		}
 	case "postgres":
 		if config.DSN == "" {
 			return fmt.Errorf("DSN required for PostgreSQL storage")
@@ -167,6 +324,9 @@ func (v *Validator) ValidateStorageConfig(config *StorageConfig) error {
 	if config.MaxConns < 0 {
 		return fmt.Errorf("max connections cannot be negative")
 	}
+	if config.QueryTimeout < 0 {
+		return fmt.Errorf("storage query timeout cannot be negative")
+	}
 
 	return nil
 }
@@ -198,6 +358,19 @@ func (v *Validator) ValidateLoggingConfig(config *LoggingConfig) error {
 		return fmt.Errorf("log output cannot be empty")
 	}
 
+	if config.MaxSizeMB < 0 {
+		return fmt.Errorf("log max_size_mb cannot be negative")
+	}
+	if config.MaxBackups < 0 {
+		return fmt.Errorf("log max_backups cannot be negative")
+	}
+
+	for component, level := range config.Components {
+		if !validLevels[level] {
+			return fmt.Errorf("invalid log level of component %s: %s (must be debug, info, warn, or error)", component, level)
+		}
+	}
+
 	return nil
 }
 
@@ -226,6 +399,318 @@ func (v *Validator) ValidateCacheConfig(config *CacheConfig) error {
 	return nil
 }
 
+// ValidateDNS64Config validates DNS64-specific configuration
+func (v *Validator) ValidateDNS64Config(config *DNS64Config) error {
+	if config.Prefix != "" {
+		if _, err := utils.ParseDNS64Prefix(config.Prefix); err != nil {
+			return err
+		}
+	}
+
+	if config.MaxTTL < 0 {
+		return fmt.Errorf("DNS64 max TTL cannot be negative")
+	}
+
+	return nil
+}
+
+// ValidateResponsesConfig validates response-specific configuration
+func (v *Validator) ValidateResponsesConfig(config *ResponsesConfig) error {
+	if config.MinTTL < 0 {
+		return fmt.Errorf("response min TTL cannot be negative")
+	}
+	if config.MaxTTL < 0 {
+		return fmt.Errorf("response max TTL cannot be negative")
+	}
+	if config.MaxTTL > 0 && config.MinTTL > config.MaxTTL {
+		return fmt.Errorf("response min TTL %s exceeds max TTL %s", config.MinTTL, config.MaxTTL)
+	}
+
+	switch config.AnyQueryMode {
+	case "", "all", "hinfo", "refused":
+	default:
+		return fmt.Errorf("invalid ANY query mode: %s (must be all, hinfo or refused)", config.AnyQueryMode)
+	}
+
+	return nil
+}
+
+// ValidateNotifyConfig validates the zones and secondaries sent NOTIFY
+// messages
+func (v *Validator) ValidateNotifyConfig(config *NotifyConfig) error {
+	zones := make(map[string]bool, len(config.Zones))
+	for _, zone := range config.Zones {
+		if zone.Zone == "" {
+			return fmt.Errorf("notify zone cannot be empty")
+		}
+		name := strings.ToLower(strings.TrimSuffix(zone.Zone, "."))
+		if zones[name] {
+			return fmt.Errorf("duplicate notify zone: %s", zone.Zone)
+		}
+		zones[name] = true
+
+		if len(zone.Secondaries) == 0 {
+			return fmt.Errorf("notify zone %s has no secondaries", zone.Zone)
+		}
+		for _, secondary := range zone.Secondaries {
+			if _, _, err := net.SplitHostPort(secondary); err != nil {
+				return fmt.Errorf("invalid secondary address %q of notify zone %s: %w", secondary, zone.Zone, err)
+			}
+		}
+	}
+
+	if config.Delay < 0 {
+		return fmt.Errorf("notify delay cannot be negative")
+	}
+	if config.Timeout < 0 {
+		return fmt.Errorf("notify timeout cannot be negative")
+	}
+	if config.MaxAttempts < 0 {
+		return fmt.Errorf("notify max attempts cannot be negative")
+	}
+
+	return nil
+}
+
+// ValidateSecondaryConfig validates the secondary zones and their primaries
+func (v *Validator) ValidateSecondaryConfig(config *SecondaryConfig) error {
+	zones := make(map[string]bool, len(config.Zones))
+	for _, zone := range config.Zones {
+		if zone.Zone == "" {
+			return fmt.Errorf("secondary zone cannot be empty")
+		}
+		name := strings.ToLower(strings.TrimSuffix(zone.Zone, "."))
+		if zones[name] {
+			return fmt.Errorf("duplicate secondary zone: %s", zone.Zone)
+		}
+		zones[name] = true
+
+		host, _, err := net.SplitHostPort(zone.Primary)
+		if err != nil {
+			return fmt.Errorf("invalid primary address %q of secondary zone %s: %w", zone.Primary, zone.Zone, err)
+		}
+		if net.ParseIP(host) == nil {
+			return fmt.Errorf("primary address %q of secondary zone %s must be an IP address", zone.Primary, zone.Zone)
+		}
+
+		if zone.Refresh < 0 {
+			return fmt.Errorf("refresh of secondary zone %s cannot be negative", zone.Zone)
+		}
+		if zone.Retry < 0 {
+			return fmt.Errorf("retry of secondary zone %s cannot be negative", zone.Zone)
+		}
+	}
+
+	if config.Timeout < 0 {
+		return fmt.Errorf("secondary timeout cannot be negative")
+	}
+
+	return nil
+}
+
+// ValidateTSIGConfig validates the names, algorithms and secrets of the TSIG
+// keys
+func (v *Validator) ValidateTSIGConfig(config *TSIGConfig) error {
+	names := make(map[string]bool, len(config.Keys))
+	for _, key := range config.Keys {
+		if !v.isValidDomainName(key.Name) {
+			return fmt.Errorf("invalid TSIG key name: %q", key.Name)
+		}
+		name := canonicalKeyName(key.Name)
+		if names[name] {
+			return fmt.Errorf("duplicate TSIG key: %s", key.Name)
+		}
+		names[name] = true
+
+		if key.Algorithm != "hmac-sha256" && key.Algorithm != "hmac-sha512" {
+			return fmt.Errorf("invalid algorithm of TSIG key %s: %s (must be hmac-sha256 or hmac-sha512)", key.Name, key.Algorithm)
+		}
+		secret, err := base64.StdEncoding.DecodeString(key.Secret)
+		if err != nil {
+			return fmt.Errorf("secret of TSIG key %s is not valid base64: %w", key.Name, err)
+		}
+		if len(secret) == 0 {
+			return fmt.Errorf("secret of TSIG key %s cannot be empty", key.Name)
+		}
+	}
+
+	return nil
+}
+
+// ValidateTransferConfig validates the zone transfer configuration against
+// the configured TSIG keys
+func (v *Validator) ValidateTransferConfig(config *TransferConfig, keys *TSIGConfig) error {
+	if _, ok := keys.Key(config.Key); config.Key != "" && !ok {
+		return fmt.Errorf("unknown TSIG key: %s", config.Key)
+	}
+
+	return nil
+}
+
+// ValidateUpdateConfig validates the dynamic update configuration against
+// the configured TSIG keys
+func (v *Validator) ValidateUpdateConfig(config *UpdateConfig, keys *TSIGConfig) error {
+	if _, ok := keys.Key(config.Key); config.Key != "" && !ok {
+		return fmt.Errorf("unknown TSIG key: %s", config.Key)
+	}
+	if config.Enabled && config.Key == "" && len(config.Networks) == 0 {
+		return fmt.Errorf("a TSIG key or networks are required to accept updates")
+	}
+	for _, network := range config.Networks {
+		if _, _, err := net.ParseCIDR(network); err != nil {
+			return fmt.Errorf("invalid network %s: %w", network, err)
+		}
+	}
+
+	return nil
+}
+
+// ValidateEDNSConfig validates the EDNS(0) options configuration
+func (v *Validator) ValidateEDNSConfig(config *EDNSConfig) error {
+	if config.Cookies.Enabled && config.Cookies.SecretRotation <= 0 {
+		return fmt.Errorf("cookie secret rotation must be positive")
+	}
+	if config.Cookies.Enforce && !config.Cookies.Enabled {
+		return fmt.Errorf("cookie enforcement needs cookies enabled")
+	}
+
+	return nil
+}
+
+// ValidateRRLConfig validates the response rate limiting configuration
+func (v *Validator) ValidateRRLConfig(config *RRLConfig) error {
+	if config.ResponsesPerSecond < 0 {
+		return fmt.Errorf("responses per second cannot be negative")
+	}
+	if config.SlipRate < 0 {
+		return fmt.Errorf("slip rate cannot be negative")
+	}
+	if config.ResponsesPerSecond > 0 && config.WindowSize <= 0 {
+		return fmt.Errorf("window size must be positive")
+	}
+
+	return nil
+}
+
+// ValidateZonesConfig validates the zone files and the TTLs of their records
+func (v *Validator) ValidateZonesConfig(config *ZonesConfig) error {
+	seen := make(map[string]bool, len(config.Files))
+	for _, zone := range config.Files {
+		name, err := utils.ParseDomainName(zone.Zone)
+		if err != nil {
+			return fmt.Errorf("invalid zone %q: %w", zone.Zone, err)
+		}
+		if zone.File == "" {
+			return fmt.Errorf("zone %s has no file", zone.Zone)
+		}
+		if seen[name.CanonicalString()] {
+			return fmt.Errorf("duplicate zone: %s", zone.Zone)
+		}
+		seen[name.CanonicalString()] = true
+	}
+
+	if config.DefaultTTL < 0 || config.DefaultTTL > maxTTL {
+		return fmt.Errorf("default TTL must be between 0 and %v", maxTTL)
+	}
+	for name, ttl := range config.TypeTTLs {
+		if _, ok := types.ParseDNSType(name); !ok {
+			return fmt.Errorf("unknown record type: %s", name)
+		}
+		if ttl < 0 || ttl > maxTTL {
+			return fmt.Errorf("TTL of %s records must be between 0 and %v", name, maxTTL)
+		}
+	}
+
+	return nil
+}
+
+// ValidateBlocklistConfig validates blocklist-specific configuration
+func (v *Validator) ValidateBlocklistConfig(config *BlocklistConfig) error {
+	if config.Response != "" && config.Response != "nxdomain" && config.Response != "sink" {
+		return fmt.Errorf("invalid blocklist response: %s (must be nxdomain or sink)", config.Response)
+	}
+
+	if config.Response == "sink" {
+		if ip := net.ParseIP(config.SinkIPv4); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("invalid sink IPv4 address: %s", config.SinkIPv4)
+		}
+		if ip := net.ParseIP(config.SinkIPv6); ip == nil || ip.To4() != nil {
+			return fmt.Errorf("invalid sink IPv6 address: %s", config.SinkIPv6)
+		}
+	}
+
+	if config.TTL < 0 {
+		return fmt.Errorf("blocklist TTL cannot be negative")
+	}
+	if config.ReloadInterval < 0 {
+		return fmt.Errorf("blocklist reload interval cannot be negative")
+	}
+
+	return nil
+}
+
+// ValidateHostsConfig validates hosts-specific configuration
+func (v *Validator) ValidateHostsConfig(config *HostsConfig) error {
+	if config.TTL < 0 {
+		return fmt.Errorf("hosts TTL cannot be negative")
+	}
+	if config.ReloadInterval < 0 {
+		return fmt.Errorf("hosts reload interval cannot be negative")
+	}
+
+	return nil
+}
+
+// ValidateViews validates the split-horizon views
+func (v *Validator) ValidateViews(views []ViewConfig) error {
+	names := make(map[string]bool, len(views))
+	for _, view := range views {
+		if view.Name == "" {
+			return fmt.Errorf("view name cannot be empty")
+		}
+		if view.Name == "default" {
+			return fmt.Errorf("view name %q is reserved for records without a view", view.Name)
+		}
+		if names[view.Name] {
+			return fmt.Errorf("duplicate view: %s", view.Name)
+		}
+		names[view.Name] = true
+
+		if len(view.Networks) == 0 {
+			return fmt.Errorf("view %s has no networks", view.Name)
+		}
+		for _, network := range view.Networks {
+			if _, _, err := net.ParseCIDR(network); err != nil {
+				return fmt.Errorf("invalid network of view %s: %w", view.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateListenAddress validates an address the server listens on
+func (v *Validator) validateListenAddress(address string) error {
+	if address == "" {
+		return fmt.Errorf("server address cannot be empty")
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid server address format: %w", err)
+	}
+
+	if net.ParseIP(host) == nil && host != "localhost" && host != "" {
+		return fmt.Errorf("invalid server host: %s", host)
+	}
+
+	if portNum, err := strconv.Atoi(port); err != nil || portNum < 1 || portNum > 65535 {
+		return fmt.Errorf("invalid server port: %s", port)
+	}
+
+	return nil
+}
+
 // validateServerAddress validates a DNS server address
 func (v *Validator) validateServerAddress(address string) error {
 	if address == "" {
@@ -0,0 +1,121 @@
+// Package cookie issues and checks the server cookies of DNS Cookies
+// (RFC 7873), with which clients prove that the address their queries come
+// from is not spoofed
+package cookie
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/logging"
+)
+
+// logger is the logger of the cookie component
+var logger = logging.For("cookie")
+
+// ServerCookieSize is the size of the server cookies an Issuer issues
+const ServerCookieSize = 16
+
+// secretSize is the size of the secrets server cookies are computed with
+const secretSize = 32
+
+// Issuer computes server cookies as an HMAC-SHA256 of the client cookie and
+// the client address under a server secret. The secret is replaced by
+// Rotate; cookies computed with the secret before it stay valid until the
+// next rotation, so clients have a rotation interval to pick up a new
+// cookie. An Issuer is safe for concurrent use
+type Issuer struct {
+	mu       sync.RWMutex
+	current  []byte
+	previous []byte // Nil until the first rotation
+}
+
+// New returns an issuer with a random secret
+func New() (*Issuer, error) {
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+	return &Issuer{current: secret}, nil
+}
+
+// Issue returns the server cookie of clientCookie for the client at ip
+func (i *Issuer) Issue(clientCookie []byte, ip net.IP) []byte {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return compute(i.current, clientCookie, ip)
+}
+
+// Valid reports whether serverCookie was issued for clientCookie and the
+// client at ip with the current or the previous secret
+func (i *Issuer) Valid(clientCookie, serverCookie []byte, ip net.IP) bool {
+	if len(serverCookie) != ServerCookieSize {
+		return false
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if hmac.Equal(serverCookie, compute(i.current, clientCookie, ip)) {
+		return true
+	}
+	return i.previous != nil && hmac.Equal(serverCookie, compute(i.previous, clientCookie, ip))
+}
+
+// Rotate replaces the secret with a new random one, keeping the current
+// secret to validate cookies issued with it
+func (i *Issuer) Rotate() error {
+	secret, err := newSecret()
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.previous, i.current = i.current, secret
+	return nil
+}
+
+// Watch rotates the secret every interval until ctx is done
+func (i *Issuer) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := i.Rotate(); err != nil {
+				logger.Error("Failed to rotate the server cookie secret", "error", err)
+			}
+		}
+	}
+}
+
+// compute returns the server cookie of clientCookie for the client at ip
+// under secret. IPv4 addresses are hashed in their 16-byte form, so a
+// client gets the same cookie however its address is represented
+func compute(secret, clientCookie []byte, ip net.IP) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(clientCookie)
+	mac.Write(ip.To16())
+	return mac.Sum(nil)[:ServerCookieSize]
+}
+
+// newSecret returns a random secret
+func newSecret() ([]byte, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate cookie secret: %w", err)
+	}
+	return secret, nil
+}
@@ -0,0 +1,75 @@
+package cookie
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIssuer_Valid(t *testing.T) {
+	issuer, err := New()
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+	client := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	ip := net.ParseIP("192.0.2.1")
+
+	server := issuer.Issue(client, ip)
+	if len(server) != ServerCookieSize {
+		t.Fatalf("Issue() returned %d bytes, want %d", len(server), ServerCookieSize)
+	}
+	if !issuer.Valid(client, server, ip) {
+		t.Error("the issued cookie is not valid")
+	}
+	if !issuer.Valid(client, server, ip.To4()) {
+		t.Error("the issued cookie is not valid for the 4-byte form of the address")
+	}
+
+	for name, valid := range map[string]bool{
+		"other address":       issuer.Valid(client, server, net.ParseIP("192.0.2.2")),
+		"other client cookie": issuer.Valid([]byte{8, 7, 6, 5, 4, 3, 2, 1}, server, ip),
+		"altered cookie":      issuer.Valid(client, append(append([]byte{}, server[:ServerCookieSize-1]...), server[ServerCookieSize-1]^1), ip),
+		"short cookie":        issuer.Valid(client, server[:8], ip),
+	} {
+		if valid {
+			t.Errorf("%s: cookie accepted", name)
+		}
+	}
+
+	other, _ := New()
+	if other.Valid(client, server, ip) {
+		t.Error("a cookie of another secret is accepted")
+	}
+}
+
+func TestIssuer_Rotate(t *testing.T) {
+	issuer, err := New()
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+	client := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	ip := net.ParseIP("2001:db8::1")
+
+	old := issuer.Issue(client, ip)
+	if err := issuer.Rotate(); err != nil {
+		t.Fatalf("Rotate() returned error: %v", err)
+	}
+
+	// Cookies of the previous secret stay valid for a rotation
+	fresh := issuer.Issue(client, ip)
+	if string(fresh) == string(old) {
+		t.Error("the rotated secret issues the same cookie")
+	}
+	if !issuer.Valid(client, old, ip) || !issuer.Valid(client, fresh, ip) {
+		t.Error("cookies of the current and previous secrets must both be valid")
+	}
+
+	if err := issuer.Rotate(); err != nil {
+		t.Fatalf("Rotate() returned error: %v", err)
+	}
+	if issuer.Valid(client, old, ip) {
+		t.Error("a cookie two secrets old is accepted")
+	}
+	if !issuer.Valid(client, fresh, ip) {
+		t.Error("a cookie of the previous secret is rejected")
+	}
+}
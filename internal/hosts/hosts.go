@@ -0,0 +1,210 @@
+// Package hosts answers names from files in hosts format (hosts(5)), as
+// used for local overrides of names served from storage or resolved
+package hosts
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/logging"
+)
+
+// logger is the logger of the hosts component
+var logger = logging.For("hosts")
+
+// Hosts maps names to the addresses listed for them in hosts files. A line
+// holds an IPv4 or IPv6 address followed by one or more names; a name listed
+// on several lines gets all of their addresses. Text after '#' is a comment.
+//
+// Reload replaces the entries when a file has changed, so Hosts is safe for
+// concurrent use while it is being reloaded.
+type Hosts struct {
+	files []string
+
+	mu        sync.RWMutex
+	addresses map[string][]net.IP // Addresses by lowercased name without a trailing dot
+	versions  map[string]fileVersion
+}
+
+// fileVersion identifies the content of a loaded file
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// equal reports whether v and other identify the same content
+func (v fileVersion) equal(other fileVersion) bool {
+	return v.modTime.Equal(other.modTime) && v.size == other.size
+}
+
+// New loads the entries of files
+func New(files []string) (*Hosts, error) {
+	h := &Hosts{files: files}
+	if err := h.load(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Lookup returns the addresses listed for name, in the order they appear in
+// the files, and whether name is listed at all. Matching ignores case and a
+// trailing dot
+func (h *Hosts) Lookup(name string) ([]net.IP, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	addresses, ok := h.addresses[normalizeName(name)]
+	return addresses, ok
+}
+
+// Len returns the number of names listed
+func (h *Hosts) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.addresses)
+}
+
+// Reload reloads the files when any of them changed since they were loaded
+// and reports whether it did. On error the current entries are kept
+func (h *Hosts) Reload() (bool, error) {
+	h.mu.RLock()
+	current := h.versions
+	h.mu.RUnlock()
+
+	changed := false
+	for _, file := range h.files {
+		version, err := statFile(file)
+		if err != nil {
+			return false, err
+		}
+		if !version.equal(current[file]) {
+			changed = true
+			break
+		}
+	}
+
+	if !changed {
+		return false, nil
+	}
+	return true, h.load()
+}
+
+// Watch reloads the files every interval until ctx is done
+func (h *Hosts) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := h.Reload()
+			if err != nil {
+				logger.Error("Failed to reload hosts files", "error", err)
+			} else if reloaded {
+				logger.Info("Hosts files reloaded", "names", h.Len())
+			}
+		}
+	}
+}
+
+// load reads all files and replaces the entries
+func (h *Hosts) load() error {
+	addresses := make(map[string][]net.IP)
+	versions := make(map[string]fileVersion, len(h.files))
+
+	for _, file := range h.files {
+		version, err := loadFile(file, addresses)
+		if err != nil {
+			return err
+		}
+		versions[file] = version
+	}
+
+	h.mu.Lock()
+	h.addresses = addresses
+	h.versions = versions
+	h.mu.Unlock()
+
+	return nil
+}
+
+// loadFile adds the entries of file to addresses. Lines whose address does
+// not parse are skipped
+func loadFile(path string, addresses map[string][]net.IP) (fileVersion, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return fileVersion{}, fmt.Errorf("failed to open hosts file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fileVersion{}, fmt.Errorf("failed to open hosts file: %w", err)
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if comment := strings.IndexByte(line, '#'); comment >= 0 {
+			line = line[:comment]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		ip := net.ParseIP(fields[0])
+		if ip == nil {
+			continue
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+
+		for _, field := range fields[1:] {
+			name := normalizeName(field)
+			if name == "" || containsIP(addresses[name], ip) {
+				continue
+			}
+			addresses[name] = append(addresses[name], ip)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fileVersion{}, fmt.Errorf("failed to read hosts file %s: %w", path, err)
+	}
+
+	return fileVersion{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// containsIP reports whether ips holds ip
+func containsIP(ips []net.IP, ip net.IP) bool {
+	for _, listed := range ips {
+		if listed.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// statFile returns the current version of a file
+func statFile(path string) (fileVersion, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}, fmt.Errorf("failed to check hosts file: %w", err)
+	}
+	return fileVersion{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// normalizeName lowercases name and removes its trailing dot
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
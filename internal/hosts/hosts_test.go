@@ -0,0 +1,117 @@
+package hosts
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeHosts(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write hosts file: %v", err)
+	}
+}
+
+func ipStrings(ips []net.IP) []string {
+	out := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		out = append(out, ip.String())
+	}
+	return out
+}
+
+func TestLookup(t *testing.T) {
+	extra := filepath.Join(t.TempDir(), "hosts")
+	writeHosts(t, extra, "203.0.113.5 db.example.test other.example.test\n")
+
+	h, err := New([]string{filepath.Join("testdata", "hosts"), extra})
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+		listed   bool
+	}{
+		{name: "multiple lines", query: "app.example.test", expected: []string{"192.0.2.10", "192.0.2.11", "2001:db8::10"}, listed: true},
+		{name: "case and trailing dot", query: "APP.example.test.", expected: []string{"192.0.2.10", "192.0.2.11", "2001:db8::10"}, listed: true},
+		{name: "alias", query: "app", expected: []string{"192.0.2.10"}, listed: true},
+		{name: "IPv6 only", query: "ip6-localhost", expected: []string{"::1"}, listed: true},
+		{name: "both families", query: "localhost", expected: []string{"127.0.0.1", "::1"}, listed: true},
+		{name: "multiple files", query: "db.example.test", expected: []string{"198.51.100.7", "203.0.113.5"}, listed: true},
+		{name: "second file", query: "other.example.test", expected: []string{"203.0.113.5"}, listed: true},
+		{name: "invalid address", query: "broken.example.test", expected: []string{}},
+		{name: "unlisted", query: "www.example.test", expected: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addresses, listed := h.Lookup(tt.query)
+			if listed != tt.listed {
+				t.Errorf("Lookup(%q) listed = %v, expected %v", tt.query, listed, tt.listed)
+			}
+			if got := ipStrings(addresses); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Lookup(%q) = %v, expected %v", tt.query, got, tt.expected)
+			}
+		})
+	}
+
+	if h.Len() != 6 {
+		t.Errorf("Len() = %d, expected 6", h.Len())
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	writeHosts(t, path, "192.0.2.1 old.example.test\n")
+
+	h, err := New([]string{path})
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+
+	if reloaded, err := h.Reload(); err != nil || reloaded {
+		t.Fatalf("Reload() of an unchanged file = %v, %v", reloaded, err)
+	}
+
+	writeHosts(t, path, "192.0.2.2 new.example.test\n")
+	// Make the change visible on file systems with coarse timestamps
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("failed to touch hosts file: %v", err)
+	}
+
+	reloaded, err := h.Reload()
+	if err != nil || !reloaded {
+		t.Fatalf("Reload() of a changed file = %v, %v", reloaded, err)
+	}
+	if _, listed := h.Lookup("old.example.test"); listed {
+		t.Error("entry removed from the file is still listed")
+	}
+	if addresses, _ := h.Lookup("new.example.test"); len(addresses) != 1 || !addresses[0].Equal(net.ParseIP("192.0.2.2")) {
+		t.Errorf("Lookup() of the added entry = %v", addresses)
+	}
+
+	// A file that disappeared keeps the loaded entries
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("failed to remove hosts file: %v", err)
+	}
+	if _, err := h.Reload(); err == nil {
+		t.Error("Reload() expected error for a missing file")
+	}
+	if _, listed := h.Lookup("new.example.test"); !listed {
+		t.Error("entries were dropped after a failed reload")
+	}
+}
+
+func TestNewMissingFile(t *testing.T) {
+	if _, err := New([]string{filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Error("New() expected error for a missing file")
+	}
+}
@@ -0,0 +1,205 @@
+// Package logging is the facade the server logs through: leveled,
+// structured records written as text or JSON by log/slog, with the level
+// settable per component
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Config holds the settings of the logging facade
+type Config struct {
+	Level      string            // Level of components without their own: "debug", "info", "warn" or "error"
+	Format     string            // "text" or "json"
+	Output     string            // "stdout", "stderr" or a file path
+	MaxSize    int64             // Bytes a log file grows to before it is rotated, 0 disables rotation
+	MaxBackups int               // Rotated log files kept
+	Components map[string]string // Levels of single components by component name
+}
+
+// settings is the configuration loggers write with
+type settings struct {
+	handler    slog.Handler // Writes every level, the loggers filter
+	level      slog.Level
+	components map[string]slog.Level
+}
+
+// levelOf returns the level component logs at
+func (s *settings) levelOf(component string) slog.Level {
+	if level, ok := s.components[component]; ok {
+		return level
+	}
+	return s.level
+}
+
+var (
+	current atomic.Pointer[settings]
+
+	mu     sync.Mutex // Serializes Configure
+	output io.Closer  // Log file of the current settings, if any
+)
+
+func init() {
+	current.Store(&settings{
+		handler: slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}),
+		level:   slog.LevelInfo,
+	})
+}
+
+// For returns the logger of component. Its records carry the component
+// name, and it follows the settings of the latest Configure, so loggers may
+// be created before the configuration is known
+func For(component string) *slog.Logger {
+	return slog.New(&componentHandler{component: component})
+}
+
+// Configure applies config to every logger, including the standard log
+// package. A log file of the previous configuration is closed
+func Configure(config Config) error {
+	level, err := ParseLevel(config.Level)
+	if err != nil {
+		return err
+	}
+	components := make(map[string]slog.Level, len(config.Components))
+	for component, name := range config.Components {
+		if components[component], err = ParseLevel(name); err != nil {
+			return fmt.Errorf("component %s: %w", component, err)
+		}
+	}
+
+	var writer io.Writer
+	var closer io.Closer
+	switch config.Output {
+	case "", "stdout":
+		writer = os.Stdout
+	case "stderr":
+		writer = os.Stderr
+	default:
+		file, err := openRotatingFile(config.Output, config.MaxSize, config.MaxBackups)
+		if err != nil {
+			return fmt.Errorf("failed to open log file: %w", err)
+		}
+		writer, closer = file, file
+	}
+
+	options := &slog.HandlerOptions{Level: slog.LevelDebug}
+	var handler slog.Handler
+	switch config.Format {
+	case "", "text":
+		handler = slog.NewTextHandler(writer, options)
+	case "json":
+		handler = slog.NewJSONHandler(writer, options)
+	default:
+		if closer != nil {
+			closer.Close()
+		}
+		return fmt.Errorf("invalid log format: %s", config.Format)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	current.Store(&settings{handler: handler, level: level, components: components})
+	// Libraries logging through the standard log package go to the facade too
+	log.SetFlags(0)
+	log.SetOutput(&stdlibWriter{logger: For("stdlib")})
+
+	previous := output
+	output = closer
+	if previous != nil {
+		return previous.Close()
+	}
+	return nil
+}
+
+// Close closes the log file, if any, and writes further records to stderr
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	current.Store(&settings{
+		handler: slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}),
+		level:   current.Load().level,
+	})
+	if output == nil {
+		return nil
+	}
+	err := output.Close()
+	output = nil
+	return err
+}
+
+// ParseLevel returns the level named "debug", "info", "warn" or "error"
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("invalid log level: %s", name)
+	}
+}
+
+// componentHandler writes the records of a component with the current
+// settings, dropping those below the level of the component
+type componentHandler struct {
+	component string
+	wrap      []func(slog.Handler) slog.Handler // Attributes and groups added by With and WithGroup
+}
+
+// Enabled reports whether the component logs at level
+func (h *componentHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= current.Load().levelOf(h.component)
+}
+
+// Handle writes record with the component name and the attributes of h
+func (h *componentHandler) Handle(ctx context.Context, record slog.Record) error {
+	handler := current.Load().handler.WithAttrs([]slog.Attr{slog.String("component", h.component)})
+	for _, wrap := range h.wrap {
+		handler = wrap(handler)
+	}
+	return handler.Handle(ctx, record)
+}
+
+// WithAttrs returns a handler adding attrs to the records of h
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+}
+
+// WithGroup returns a handler qualifying the attributes of later records
+// of h with name
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+// with returns a copy of h applying wrap as well
+func (h *componentHandler) with(wrap func(slog.Handler) slog.Handler) *componentHandler {
+	return &componentHandler{
+		component: h.component,
+		wrap:      append(h.wrap[:len(h.wrap):len(h.wrap)], wrap),
+	}
+}
+
+// stdlibWriter logs the lines the standard log package writes at info level
+type stdlibWriter struct {
+	logger *slog.Logger
+}
+
+// Write logs p as one record
+func (w *stdlibWriter) Write(p []byte) (int, error) {
+	w.logger.Info(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
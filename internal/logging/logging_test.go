@@ -0,0 +1,153 @@
+package logging
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// configureFile configures the facade to write to a file in a temporary
+// directory and returns its path
+func configureFile(t *testing.T, config Config) string {
+	t.Helper()
+
+	config.Output = filepath.Join(t.TempDir(), "dnska.log")
+	if err := Configure(config); err != nil {
+		t.Fatalf("Configure() returned error: %v", err)
+	}
+	t.Cleanup(func() { Close() })
+	return config.Output
+}
+
+// readRecords returns the JSON records written to the file at path
+func readRecords(t *testing.T, path string) []map[string]any {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open log file: %v", err)
+	}
+	defer file.Close()
+
+	var records []map[string]any
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestConfigure_JSONFields(t *testing.T) {
+	logger := For("server")
+	path := configureFile(t, Config{Level: "info", Format: "json"})
+
+	logger.Info("Answered query", "client", "192.0.2.1", "qname", "example.com.", "duration", 3*time.Millisecond)
+
+	records := readRecords(t, path)
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+	record := records[0]
+	for key, want := range map[string]any{
+		"level":     "INFO",
+		"msg":       "Answered query",
+		"component": "server",
+		"client":    "192.0.2.1",
+		"qname":     "example.com.",
+	} {
+		if got := record[key]; got != want {
+			t.Errorf("record[%q] = %v, want %v", key, got, want)
+		}
+	}
+	if _, ok := record["duration"]; !ok {
+		t.Error("record has no duration")
+	}
+	if _, ok := record["time"]; !ok {
+		t.Error("record has no time")
+	}
+}
+
+func TestConfigure_LevelFiltering(t *testing.T) {
+	server, resolver, storage := For("server"), For("resolver"), For("storage")
+	path := configureFile(t, Config{
+		Level:      "warn",
+		Format:     "json",
+		Components: map[string]string{"resolver": "debug", "storage": "error"},
+	})
+
+	server.Info("server info")
+	server.Warn("server warn")
+	resolver.Debug("resolver debug")
+	storage.Warn("storage warn")
+	storage.Error("storage error")
+
+	var got []string
+	for _, record := range readRecords(t, path) {
+		got = append(got, record["msg"].(string))
+	}
+	want := []string{"server warn", "resolver debug", "storage error"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("logged %v, want %v", got, want)
+	}
+}
+
+func TestConfigure_With(t *testing.T) {
+	logger := For("resolver").With("server", "192.0.2.53:53").WithGroup("query")
+	path := configureFile(t, Config{Level: "debug", Format: "json"})
+
+	logger.Debug("Sent query", "qname", "example.com.")
+
+	records := readRecords(t, path)
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+	if records[0]["server"] != "192.0.2.53:53" || records[0]["component"] != "resolver" {
+		t.Errorf("record = %v, want server and component attributes", records[0])
+	}
+	if group, ok := records[0]["query"].(map[string]any); !ok || group["qname"] != "example.com." {
+		t.Errorf("record = %v, want qname in the query group", records[0])
+	}
+}
+
+func TestConfigure_StandardLog(t *testing.T) {
+	path := configureFile(t, Config{Level: "info", Format: "json"})
+
+	log.Printf("message from %s", "a library")
+
+	records := readRecords(t, path)
+	if len(records) != 1 || records[0]["msg"] != "message from a library" || records[0]["component"] != "stdlib" {
+		t.Errorf("records = %v, want the standard log message", records)
+	}
+}
+
+func TestConfigure_Invalid(t *testing.T) {
+	for _, config := range []Config{
+		{Level: "verbose", Format: "text", Output: "stderr"},
+		{Level: "info", Format: "xml", Output: "stderr"},
+		{Level: "info", Format: "text", Output: "stderr", Components: map[string]string{"server": "loud"}},
+	} {
+		if err := Configure(config); err == nil {
+			t.Errorf("Configure(%+v) succeeded, want an error", config)
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, name := range []string{"debug", "info", "warn", "error", "DEBUG"} {
+		if _, err := ParseLevel(name); err != nil {
+			t.Errorf("ParseLevel(%q) returned error: %v", name, err)
+		}
+	}
+	if _, err := ParseLevel("trace"); err == nil {
+		t.Error("ParseLevel(trace) succeeded, want an error")
+	}
+}
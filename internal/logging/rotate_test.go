@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dnska.log")
+	file, err := openRotatingFile(path, 20, 2)
+	if err != nil {
+		t.Fatalf("openRotatingFile() returned error: %v", err)
+	}
+	defer file.Close()
+
+	for _, line := range []string{"first line\n", "second line\n", "third line\n", "fourth line\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() returned error: %v", err)
+		}
+	}
+
+	for name, want := range map[string]string{
+		path:        "fourth line\n",
+		path + ".1": "third line\n",
+		path + ".2": "second line\n",
+	} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		if string(data) != want {
+			t.Errorf("%s holds %q, want %q", filepath.Base(name), data, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("more than 2 backups kept: %v", err)
+	}
+}
+
+func TestRotatingFile_Appends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dnska.log")
+	if err := os.WriteFile(path, []byte("earlier\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := openRotatingFile(path, 0, 0)
+	if err != nil {
+		t.Fatalf("openRotatingFile() returned error: %v", err)
+	}
+	file.Write([]byte(strings.Repeat("x", 100) + "\n"))
+	file.Close()
+
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "earlier\n") || len(data) != 109 {
+		t.Errorf("log file holds %q, want the earlier line followed by the new one", data)
+	}
+	if _, err := file.Write([]byte("late\n")); err == nil {
+		t.Error("Write() after Close() succeeded")
+	}
+}
@@ -0,0 +1,158 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// ErrCircuitOpen is the cause of the errors returned without querying the
+// forward servers while the circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitState is the state of a circuit breaker
+type CircuitState int
+
+const (
+	CircuitClosed   CircuitState = 0 // Queries go upstream
+	CircuitOpen     CircuitState = 1 // Queries fail without going upstream
+	CircuitHalfOpen CircuitState = 2 // One probe query goes upstream, others fail
+)
+
+// String returns the name of the state
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerConfig holds the thresholds of the circuit breaker of a
+// forward resolver
+type CircuitBreakerConfig struct {
+	FailureThreshold int           // Consecutive failures opening the circuit, 0 disables the breaker
+	FailureWindow    time.Duration // Time within which the failures must occur
+	Cooldown         time.Duration // Time the circuit stays open before a probe query
+}
+
+// circuitBreaker fails queries fast while the forward servers keep failing.
+// FailureThreshold consecutive failures within FailureWindow open the
+// circuit; after Cooldown one probe query is let through, closing the
+// circuit if it succeeds and opening it again otherwise
+type circuitBreaker struct {
+	config CircuitBreakerConfig
+	now    func() time.Time
+
+	mu           sync.Mutex // Guards the fields below
+	state        CircuitState
+	failures     int       // Consecutive failures since firstFailure
+	firstFailure time.Time // First failure of the current run
+	openedAt     time.Time
+	probing      bool // A probe query is in flight in the half-open state
+}
+
+// newCircuitBreaker returns a closed circuit breaker, or nil when config
+// disables it
+func newCircuitBreaker(config CircuitBreakerConfig) *circuitBreaker {
+	if config.FailureThreshold <= 0 {
+		return nil
+	}
+	return &circuitBreaker{config: config, now: time.Now}
+}
+
+// allow reports whether a query may go upstream. A half-open circuit lets
+// the first query through as its probe
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.config.Cooldown {
+		b.state = CircuitHalfOpen
+	}
+
+	switch b.state {
+	case CircuitClosed:
+		return true
+	case CircuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return false
+	}
+}
+
+// record updates the circuit with the outcome of a query allow let through.
+// A query abandoned by its caller counts as neither success nor failure
+func (b *circuitBreaker) record(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	probe := b.state == CircuitHalfOpen && b.probing
+	if probe {
+		b.probing = false
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		return
+	case !isUpstreamFailure(err):
+		b.state = CircuitClosed
+		b.failures = 0
+		return
+	}
+
+	now := b.now()
+	if probe {
+		b.state = CircuitOpen
+		b.openedAt = now
+		return
+	}
+
+	if b.failures == 0 || now.Sub(b.firstFailure) > b.config.FailureWindow {
+		b.failures = 0
+		b.firstFailure = now
+	}
+	b.failures++
+	if b.state == CircuitClosed && b.failures >= b.config.FailureThreshold {
+		b.state = CircuitOpen
+		b.openedAt = now
+		b.failures = 0
+	}
+}
+
+// State returns the state of the circuit, reporting an open circuit whose
+// cooldown has passed as half-open
+func (b *circuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.config.Cooldown {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// isUpstreamFailure reports whether err shows the forward servers failing:
+// no reply, or SERVFAIL. Other response codes such as NXDOMAIN are answers
+func isUpstreamFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var resolutionErr *ResolutionError
+	if errors.As(err, &resolutionErr) {
+		return resolutionErr.Type == types.RCODE_SERVER_FAILURE
+	}
+	return true
+}
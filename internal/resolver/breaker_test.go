@@ -0,0 +1,141 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	breaker := newCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 3, FailureWindow: 10 * time.Second, Cooldown: 30 * time.Second})
+	breaker.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	timeout := errors.New("i/o timeout")
+	fail := func() {
+		t.Helper()
+		if !breaker.allow() {
+			t.Fatalf("query refused in state %s", breaker.State())
+		}
+		breaker.record(ctx, timeout)
+	}
+
+	// Failures spread wider than the window, or broken by an answer, do not open it
+	fail()
+	fail()
+	now = now.Add(11 * time.Second)
+	fail()
+	breaker.allow()
+	breaker.record(ctx, NewResolutionError(types.RCODE_NAME_ERROR, "server returned error", nil))
+	fail()
+	fail()
+	if state := breaker.State(); state != CircuitClosed {
+		t.Fatalf("state = %s, expected closed", state)
+	}
+
+	fail()
+	if state := breaker.State(); state != CircuitOpen {
+		t.Fatalf("state = %s after %d failures, expected open", state, 3)
+	}
+	if breaker.allow() {
+		t.Fatal("open circuit let a query through")
+	}
+
+	// After the cooldown one probe goes through; its failure opens the circuit again
+	now = now.Add(30 * time.Second)
+	if state := breaker.State(); state != CircuitHalfOpen {
+		t.Fatalf("state = %s after the cooldown, expected half-open", state)
+	}
+	if !breaker.allow() || breaker.allow() {
+		t.Fatal("half-open circuit did not let exactly one probe through")
+	}
+	breaker.record(ctx, NewResolutionError(types.RCODE_SERVER_FAILURE, "server returned error", nil))
+	if state := breaker.State(); state != CircuitOpen {
+		t.Fatalf("state = %s after a failed probe, expected open", state)
+	}
+
+	// A probe abandoned by its caller leaves the circuit half-open
+	now = now.Add(30 * time.Second)
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	breaker.allow()
+	breaker.record(canceled, context.Canceled)
+	if state := breaker.State(); state != CircuitHalfOpen {
+		t.Fatalf("state = %s after an abandoned probe, expected half-open", state)
+	}
+
+	if !breaker.allow() {
+		t.Fatal("half-open circuit refused a new probe")
+	}
+	breaker.record(ctx, nil)
+	if state := breaker.State(); state != CircuitClosed {
+		t.Fatalf("state = %s after a successful probe, expected closed", state)
+	}
+}
+
+func TestForwardResolver_CircuitBreaker(t *testing.T) {
+	upstream, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start fake upstream: %v", err)
+	}
+	defer upstream.Close()
+
+	// Upstream failing every query
+	var packets atomic.Int32
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := upstream.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			packets.Add(1)
+
+			query, err := message.NewDNSResponse(buf[:n])
+			if err != nil || len(query.Questions) != 1 {
+				continue
+			}
+			reply := message.NewResponse(query.Header.ID).
+				WithFlags(types.FLAG_QR_RESPONSE | types.FLAG_RCODE_SERVER_FAILURE).
+				AddQuestion(query.Questions[0]).
+				Build()
+			upstream.WriteTo(reply.ToBytes(), addr)
+		}
+	}()
+
+	config := DefaultResolverConfig()
+	config.ForwardServers = []string{upstream.LocalAddr().String()}
+	config.MaxRetries = 0
+	config.CircuitBreaker = CircuitBreakerConfig{FailureThreshold: 2, FailureWindow: time.Minute, Cooldown: time.Minute}
+
+	forwarder, err := NewForwardResolver(config)
+	if err != nil {
+		t.Fatalf("failed to create forward resolver: %v", err)
+	}
+	question := createQuestion(t, "www.example.com")
+
+	for range 2 {
+		if _, err := forwarder.Resolve(context.Background(), question); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("Resolve() error = %v, expected the upstream failure", err)
+		}
+	}
+	if state, ok := forwarder.CircuitState(); !ok || state != CircuitOpen {
+		t.Fatalf("CircuitState() = %s, %v, expected open", state, ok)
+	}
+
+	_, err = forwarder.Resolve(context.Background(), question)
+	var resolutionErr *ResolutionError
+	if !errors.Is(err, ErrCircuitOpen) || !errors.As(err, &resolutionErr) || resolutionErr.Type != types.RCODE_SERVER_FAILURE {
+		t.Errorf("Resolve() error = %v, expected a SERVFAIL with the circuit open", err)
+	}
+	if got := packets.Load(); got != 2 {
+		t.Errorf("upstream received %d queries, expected 2", got)
+	}
+}
@@ -4,21 +4,65 @@ import (
 	"context"
 	"crypto/md5"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
 )
 
+// cacheLookupKey is the context key of the CacheLookup a query counts its
+// cache lookups in
+type cacheLookupKey struct{}
+
+// CacheLookup counts the questions of a query answered from the cache and
+// those that missed it
+type CacheLookup struct {
+	Hits   atomic.Uint64
+	Misses atomic.Uint64
+}
+
+// WithCacheLookup returns a context under which cache resolvers count their
+// hits and misses in lookup
+func WithCacheLookup(ctx context.Context, lookup *CacheLookup) context.Context {
+	return context.WithValue(ctx, cacheLookupKey{}, lookup)
+}
+
+// countLookup records a hit or a miss in the CacheLookup of ctx, if any
+func countLookup(ctx context.Context, hit bool) {
+	lookup, ok := ctx.Value(cacheLookupKey{}).(*CacheLookup)
+	if !ok {
+		return
+	}
+	if hit {
+		lookup.Hits.Add(1)
+	} else {
+		lookup.Misses.Add(1)
+	}
+}
+
 // Resolve performs DNS resolution with caching
 func (r *CacheResolver) Resolve(ctx context.Context, question message.DNSQuestion) ([]message.DNSAnswer, error) {
-	var cacheKey string
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
-	// Check cache first
+	var cacheKey string
 	if r.config.CacheEnabled {
 		cacheKey = r.generateCacheKey(question)
+	}
+
+	// Answers for a client subnet may not suit other clients
+	subnet := ClientSubnet(ctx)
+
+	// Check cache first
+	if r.config.CacheEnabled && subnet == nil {
 		if entry := r.getFromCache(cacheKey); entry != nil {
-			return entry.Answers, nil
+			countLookup(ctx, true)
+			return entry.remainingAnswers(question, time.Now()), nil
 		}
+		countLookup(ctx, false)
 	}
 
 	// Cache miss - resolve using underlying resolver
@@ -28,8 +72,8 @@ func (r *CacheResolver) Resolve(ctx context.Context, question message.DNSQuestio
 	}
 
 	// Cache the result if caching is enabled
-	if r.config.CacheEnabled && len(answers) > 0 {
-		r.putInCache(cacheKey, answers)
+	if r.config.CacheEnabled && len(answers) > 0 && (subnet == nil || subnet.ScopePrefixLength == 0) {
+		r.putInCache(cacheKey, question, answers)
 	}
 
 	return answers, nil
@@ -53,7 +97,9 @@ func (r *CacheResolver) ResolveAll(ctx context.Context, questions []message.DNSQ
 // Close closes the resolver and cleans up resources
 func (r *CacheResolver) Close() error {
 	// Clear cache
+	r.mu.Lock()
 	r.cache = make(map[string]*CacheEntry)
+	r.mu.Unlock()
 
 	// Close underlying resolver
 	if r.resolver != nil {
@@ -65,11 +111,10 @@ func (r *CacheResolver) Close() error {
 
 // generateCacheKey generates a unique cache key for a DNS question
 func (r *CacheResolver) generateCacheKey(question message.DNSQuestion) string {
-	// Create a unique key from question name, type, and class
-	questionName := question.Name.String()
-	questionType := uint16(question.Type[0])<<8 | uint16(question.Type[1])
-	questionClass := uint16(question.Class[0])<<8 | uint16(question.Class[1])
-	keyData := fmt.Sprintf("%s|%d|%d", questionName, questionType, questionClass)
+	// Create a unique key from question name, type, and class. Names are
+	// compared case-insensitively, so every case of a name shares one entry
+	questionName := question.Name.CanonicalString()
+	keyData := fmt.Sprintf("%s|%d|%d", questionName, question.Qtype(), question.Qclass())
 
 	// Use MD5 hash for consistent key length
 	hash := md5.Sum([]byte(keyData))
@@ -78,6 +123,9 @@ func (r *CacheResolver) generateCacheKey(question message.DNSQuestion) string {
 
 // getFromCache retrieves an entry from the cache if it exists and hasn't expired
 func (r *CacheResolver) getFromCache(key string) *CacheEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	entry, exists := r.cache[key]
 	if !exists {
 		return nil
@@ -94,20 +142,94 @@ func (r *CacheResolver) getFromCache(key string) *CacheEntry {
 }
 
 // putInCache stores an entry in the cache with appropriate TTL
-func (r *CacheResolver) putInCache(key string, answers []message.DNSAnswer) {
-	// Use configured cache TTL (simplified - doesn't extract TTL from answers)
+func (r *CacheResolver) putInCache(key string, question message.DNSQuestion, answers []message.DNSAnswer) {
+	// Never keep answers longer than the smallest TTL among them
 	minTTL := r.config.CacheTTL
+	for _, answer := range answers {
+		minTTL = min(minTTL, time.Duration(answer.TTL())*time.Second)
+	}
 
+	now := time.Now()
 	entry := &CacheEntry{
+		Question:  question,
 		Answers:   answers,
-		ExpiresAt: time.Now().Add(minTTL),
+		StoredAt:  now,
+		ExpiresAt: now.Add(minTTL),
 	}
 
+	r.mu.Lock()
 	r.cache[key] = entry
+	r.mu.Unlock()
+}
+
+// Evict removes the cached entries that may hold records of name with the
+// given type: entries asked for that name and type, and entries whose answers
+// contain the name, e.g. as part of a CNAME chain. A recordType of 0 or CNAME
+// matches questions of every type. It returns the number of removed entries
+func (r *CacheResolver) Evict(name string, recordType types.DNSType) int {
+	domainName, err := utils.NewDomainNameFromString(name)
+	if err != nil {
+		return 0
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	removed := 0
+	for key, entry := range r.cache {
+		if entry.holds(*domainName, recordType) {
+			delete(r.cache, key)
+			removed++
+		}
+	}
+
+	return removed
+}
+
+// holds reports whether the entry may contain records of name and recordType
+func (e *CacheEntry) holds(name utils.DomainName, recordType types.DNSType) bool {
+	questionType := e.Question.Qtype()
+	if recordType != 0 && recordType != types.TYPE_CNAME && questionType != recordType {
+		return false
+	}
+
+	if e.Question.Name.Equal(name) {
+		return true
+	}
+
+	for _, answer := range e.Answers {
+		if answer.Name().Equal(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// remainingAnswers returns copies of the cached answers with their TTLs
+// reduced by the time the entry has spent in the cache. Answers owned by the
+// cached question name take the name in the case question asks for it, as
+// the entry may have been stored for a question in another case
+func (e *CacheEntry) remainingAnswers(question message.DNSQuestion, now time.Time) []message.DNSAnswer {
+	elapsed := uint32(now.Sub(e.StoredAt) / time.Second)
+
+	answers := make([]message.DNSAnswer, len(e.Answers))
+	for i, answer := range e.Answers {
+		if answer.Name().Equal(question.Name) {
+			answer = answer.WithName(question.Name)
+		}
+		answer.SetTTL(answer.TTL() - min(answer.TTL(), elapsed))
+		answers[i] = answer
+	}
+
+	return answers
 }
 
 // GetCacheStats returns statistics about the cache
 func (r *CacheResolver) GetCacheStats() CacheStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	now := time.Now()
 	totalEntries := len(r.cache)
 	validEntries := 0
@@ -137,6 +259,9 @@ type CacheStats struct {
 
 // CleanExpiredEntries removes all expired entries from the cache
 func (r *CacheResolver) CleanExpiredEntries() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	now := time.Now()
 	removed := 0
 
@@ -161,6 +286,8 @@ func (r *CacheResolver) EnableCache(enabled bool) {
 
 	// If disabling cache, clear existing entries
 	if !enabled {
+		r.mu.Lock()
 		r.cache = make(map[string]*CacheEntry)
+		r.mu.Unlock()
 	}
 }
@@ -0,0 +1,165 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+func TestCacheResolver_DecrementsTTL(t *testing.T) {
+	mock := &MockResolver{name: "upstream", answers: []message.DNSAnswer{createTestAnswer()}}
+	config := DefaultResolverConfig()
+	cache := NewCacheResolver(config, mock)
+	question := createTestQuestion()
+
+	if _, err := cache.Resolve(context.Background(), question); err != nil {
+		t.Fatalf("first resolve failed: %v", err)
+	}
+
+	// Pretend the entry was stored 100 seconds ago
+	for _, entry := range cache.cache {
+		entry.StoredAt = entry.StoredAt.Add(-100 * time.Second)
+	}
+
+	answers, err := cache.Resolve(context.Background(), question)
+	if err != nil {
+		t.Fatalf("cached resolve failed: %v", err)
+	}
+
+	if mock.callCount != 1 {
+		t.Errorf("expected 1 upstream call, got %d", mock.callCount)
+	}
+	if len(answers) != 1 {
+		t.Fatalf("expected 1 answer, got %d", len(answers))
+	}
+	if ttl := answers[0].TTL(); ttl != 200 {
+		t.Errorf("expected remaining TTL 200, got %d", ttl)
+	}
+	if ttl := mock.answers[0].TTL(); ttl != 300 {
+		t.Errorf("cached TTL changed the upstream answer: %d", ttl)
+	}
+}
+
+func TestCacheResolver_ExpiresAtSmallestTTL(t *testing.T) {
+	answer := createTestAnswer()
+	answer.SetTTL(30)
+
+	mock := &MockResolver{name: "upstream", answers: []message.DNSAnswer{createTestAnswer(), answer}}
+	config := DefaultResolverConfig()
+	cache := NewCacheResolver(config, mock)
+
+	if _, err := cache.Resolve(context.Background(), createTestQuestion()); err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+
+	for _, entry := range cache.cache {
+		if lifetime := entry.ExpiresAt.Sub(entry.StoredAt); lifetime != 30*time.Second {
+			t.Errorf("expected entry to live 30s, got %v", lifetime)
+		}
+	}
+}
+
+func TestCacheResolver_CaseInsensitive(t *testing.T) {
+	mock := &MockResolver{name: "upstream", answers: []message.DNSAnswer{createTestAnswer()}}
+	cache := NewCacheResolver(DefaultResolverConfig(), mock)
+
+	lower := createTestQuestion()
+	upper := lower
+	upperName, err := utils.ParseDomainName("EXAMPLE.Com.")
+	if err != nil {
+		t.Fatalf("failed to parse name: %v", err)
+	}
+	upper.Name = upperName
+
+	if _, err := cache.Resolve(context.Background(), lower); err != nil {
+		t.Fatalf("first resolve failed: %v", err)
+	}
+	answers, err := cache.Resolve(context.Background(), upper)
+	if err != nil {
+		t.Fatalf("second resolve failed: %v", err)
+	}
+
+	if mock.callCount != 1 {
+		t.Errorf("expected 1 upstream call, got %d", mock.callCount)
+	}
+	if stats := cache.GetCacheStats(); stats.TotalEntries != 1 {
+		t.Errorf("expected 1 cache entry, got %d", stats.TotalEntries)
+	}
+	if len(answers) != 1 {
+		t.Fatalf("expected 1 answer, got %d", len(answers))
+	}
+	if name := answers[0].Name(); name.String() != "EXAMPLE.Com." {
+		t.Errorf("answer owner = %s, expected the question case EXAMPLE.Com.", name.String())
+	}
+
+	// The cached answer keeps the case it was stored with
+	answers, err = cache.Resolve(context.Background(), lower)
+	if err != nil {
+		t.Fatalf("third resolve failed: %v", err)
+	}
+	if name := answers[0].Name(); name.String() != "example.com." {
+		t.Errorf("answer owner = %s, expected example.com.", name.String())
+	}
+}
+
+func TestCacheResolver_Evict(t *testing.T) {
+	mock := &MockResolver{name: "upstream", answers: []message.DNSAnswer{createTestAnswer()}}
+	cache := NewCacheResolver(DefaultResolverConfig(), mock)
+	question := createTestQuestion()
+
+	if _, err := cache.Resolve(context.Background(), question); err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		evictName  string
+		evictType  types.DNSType
+		wantRemove int
+	}{
+		{name: "other name", evictName: "www.example.com.", evictType: types.TYPE_A, wantRemove: 0},
+		{name: "other type", evictName: "example.com.", evictType: types.TYPE_MX, wantRemove: 0},
+		{name: "same name and type in another case", evictName: "EXAMPLE.com.", evictType: types.TYPE_A, wantRemove: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if removed := cache.Evict(tt.evictName, tt.evictType); removed != tt.wantRemove {
+				t.Errorf("Evict removed %d entries, want %d", removed, tt.wantRemove)
+			}
+		})
+	}
+
+	if _, err := cache.Resolve(context.Background(), question); err != nil {
+		t.Fatalf("resolve after eviction failed: %v", err)
+	}
+	if mock.callCount != 2 {
+		t.Errorf("expected the evicted entry to be resolved upstream again, got %d calls", mock.callCount)
+	}
+
+	// Deleting every type of the name evicts the entry as well
+	if removed := cache.Evict("example.com", 0); removed != 1 {
+		t.Errorf("Evict of all types removed %d entries, want 1", removed)
+	}
+}
+
+func TestCacheResolver_CountsLookups(t *testing.T) {
+	mock := &MockResolver{name: "upstream", answers: []message.DNSAnswer{createTestAnswer()}}
+	cache := NewCacheResolver(DefaultResolverConfig(), mock)
+
+	var lookup CacheLookup
+	ctx := WithCacheLookup(context.Background(), &lookup)
+	for range 3 {
+		if _, err := cache.Resolve(ctx, createTestQuestion()); err != nil {
+			t.Fatalf("resolve failed: %v", err)
+		}
+	}
+
+	if hits, misses := lookup.Hits.Load(), lookup.Misses.Load(); hits != 2 || misses != 1 {
+		t.Errorf("expected 2 hits and 1 miss, got %d hits and %d misses", hits, misses)
+	}
+}
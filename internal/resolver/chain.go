@@ -3,7 +3,6 @@ package resolver
 import (
 	"context"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/vadim-su/dnska/pkg/dns/message"
@@ -111,11 +110,8 @@ func (r *ChainResolver) Resolve(ctx context.Context, question message.DNSQuestio
 			defer cancel()
 		}
 
-		// Log attempt if configured
-		if r.config.CacheEnabled { // Using CacheEnabled as a proxy for verbose logging
-			log.Printf("[ChainResolver] Trying resolver %s (%d/%d)",
-				resolverPolicy.Name, i+1, len(r.resolvers))
-		}
+		logger.Debug("Trying chained resolver",
+			"resolver", resolverPolicy.Name, "position", i+1, "resolvers", len(r.resolvers))
 
 		// Try resolution with this resolver
 		answers, err := resolverPolicy.Resolver.Resolve(resolverCtx, question)
@@ -124,11 +120,7 @@ func (r *ChainResolver) Resolve(ctx context.Context, question message.DNSQuestio
 			// Successful resolution
 			allAnswers = append(allAnswers, answers...)
 
-			// Log success
-			if r.config.CacheEnabled {
-				log.Printf("[ChainResolver] Resolver %s succeeded with %d answers",
-					resolverPolicy.Name, len(answers))
-			}
+			logger.Debug("Chained resolver succeeded", "resolver", resolverPolicy.Name, "answers", len(answers))
 
 			// Return immediately if we don't need to try all resolvers
 			if !r.shouldContinueAfterSuccess() {
@@ -138,11 +130,7 @@ func (r *ChainResolver) Resolve(ctx context.Context, question message.DNSQuestio
 			// Handle error
 			lastError = err
 
-			// Log error
-			if r.config.CacheEnabled {
-				log.Printf("[ChainResolver] Resolver %s failed: %v",
-					resolverPolicy.Name, err)
-			}
+			logger.Debug("Chained resolver failed", "resolver", resolverPolicy.Name, "error", err)
 
 			// Check if we should continue to next resolver
 			if !resolverPolicy.SkipOnError {
@@ -188,7 +176,7 @@ func (r *ChainResolver) ResolveAll(ctx context.Context, questions []message.DNSQ
 		answers, err := r.Resolve(ctx, question)
 		if err != nil {
 			// Continue with other questions even if one fails
-			log.Printf("[ChainResolver] Failed to resolve question %v: %v", question, err)
+			logger.Debug("Failed to resolve question", "qname", question.Name.String(), "error", err)
 			continue
 		}
 		allAnswers = append(allAnswers, answers...)
@@ -57,11 +57,7 @@ func createTestQuestion() message.DNSQuestion {
 	// Create domain name with proper format (length-prefixed labels)
 	domainBytes := []byte{7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0}
 	domainName, _, _ := utils.NewDomainName(domainBytes)
-	return message.DNSQuestion{
-		Name:  *domainName,
-		Type:  types.DnsTypeClassToBytes(types.TYPE_A),
-		Class: types.DnsTypeClassToBytes(types.CLASS_IN),
-	}
+	return message.NewDNSQuestion(*domainName, types.TYPE_A, types.CLASS_IN)
 }
 
 func createTestAnswer() message.DNSAnswer {
@@ -2,28 +2,71 @@ package resolver
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"net"
 	"time"
 
+	"github.com/vadim-su/dnska/pkg/dns/edns"
 	"github.com/vadim-su/dnska/pkg/dns/message"
 	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
 )
 
-// Resolve performs DNS resolution for the given question by forwarding to configured servers
+// Resolve performs DNS resolution for the given question by forwarding to configured servers.
+// While the circuit breaker is open the question fails without a query
 func (r *ForwardResolver) Resolve(ctx context.Context, question message.DNSQuestion) ([]message.DNSAnswer, error) {
+	if r.breaker == nil {
+		return r.forward(ctx, question)
+	}
+
+	if !r.breaker.allow() {
+		return nil, NewResolutionError(types.RCODE_SERVER_FAILURE, "forward servers are failing", ErrCircuitOpen)
+	}
+	answers, err := r.forward(ctx, question)
+	r.breaker.record(ctx, err)
+	return answers, err
+}
+
+// CircuitState returns the state of the circuit breaker, or false when the
+// breaker is disabled
+func (r *ForwardResolver) CircuitState() (CircuitState, bool) {
+	if r.breaker == nil {
+		return CircuitClosed, false
+	}
+	return r.breaker.State(), true
+}
+
+// forward asks the forward servers in turn until one answers, skipping
+// those that are down while others are not
+func (r *ForwardResolver) forward(ctx context.Context, question message.DNSQuestion) ([]message.DNSAnswer, error) {
 	var lastErr error
 
+	servers := r.servers
+	if r.health != nil {
+		servers = r.health.order(servers)
+	}
+
 	// Try each forward server
-	for _, server := range r.servers {
+	for _, server := range servers {
 		answers, err := r.resolveWithServer(ctx, question, server)
+		if r.health != nil {
+			r.health.record(ctx, server, err)
+		}
 		if err == nil {
 			return answers, nil
 		}
 		lastErr = err
+
+		// Abandon the query once the caller is no longer waiting for it
+		if ctx.Err() != nil {
+			break
+		}
 	}
 
-	return nil, fmt.Errorf("all forward servers failed, last error: %w", lastErr)
+	// No forward server answered, which must not be taken for NXDOMAIN
+	return nil, NewResolutionError(types.RCODE_SERVER_FAILURE, "all forward servers failed", lastErr)
 }
 
 // ResolveAll performs DNS resolution for multiple questions
@@ -43,22 +86,105 @@ func (r *ForwardResolver) ResolveAll(ctx context.Context, questions []message.DN
 
 // Close closes the resolver and cleans up resources
 func (r *ForwardResolver) Close() error {
-	if r.client != nil {
-		return r.client.Close()
+	return nil
+}
+
+// Probe checks that the first forward server answers a query for the root
+// NS records. Any reply counts regardless of its response code, as it shows
+// the server is reachable
+func (r *ForwardResolver) Probe(ctx context.Context) error {
+	if len(r.servers) == 0 {
+		return errors.New("no forward servers configured")
+	}
+
+	query, err := nsQuery(".")
+	if err != nil {
+		return err
+	}
+	if _, err := r.sendQuery(ctx, query, r.servers[0]); err != nil {
+		return fmt.Errorf("forward server %s: %w", r.servers[0], err)
+	}
+	return nil
+}
+
+// UpstreamStatuses returns the health of the forward servers in the
+// configured order, or false when health tracking is disabled
+func (r *ForwardResolver) UpstreamStatuses() ([]UpstreamStatus, bool) {
+	if r.health == nil {
+		return nil, false
+	}
+	return r.health.statuses(r.servers), true
+}
+
+// probeUpstream asks a down forward server for the NS records of the probe
+// name. It fails when the server does not reply or replies SERVFAIL
+func (r *ForwardResolver) probeUpstream(ctx context.Context, server string) error {
+	query, err := nsQuery(r.config.UpstreamHealth.ProbeName)
+	if err != nil {
+		return err
+	}
+
+	response, err := r.sendQuery(ctx, query, server)
+	if err != nil {
+		return err
+	}
+	if rcode := response.Header.Flags.Rcode(); rcode == types.RCODE_SERVER_FAILURE {
+		return NewResolutionError(rcode, "server returned error", nil)
 	}
 	return nil
 }
 
+// nsQuery returns a query with a random ID for the NS records of name, the
+// root when name is empty
+func nsQuery(name string) (*message.DNSResponse, error) {
+	var domainName *utils.DomainName
+	var err error
+	if name == "" || name == "." {
+		domainName, _, err = utils.NewDomainName([]byte{0})
+	} else {
+		domainName, err = utils.NewDomainNameFromString(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query := message.GenerateDNSQuery(0, []message.DNSQuestion{message.NewDNSQuestion(*domainName, types.TYPE_NS, types.CLASS_IN)})
+	if query.Header.ID, err = randomQueryID(); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
 // resolveWithServer attempts to resolve a question with a specific server
 func (r *ForwardResolver) resolveWithServer(ctx context.Context, question message.DNSQuestion, server string) ([]message.DNSAnswer, error) {
-	// Create DNS query
+	// Create DNS query; the client's ID is never sent upstream
 	query := message.GenerateDNSQuery(0, []message.DNSQuestion{question})
 
+	// The client subnet goes upstream without the scope of earlier answers
+	subnet := ClientSubnet(ctx)
+	if subnet != nil {
+		query.AddClientSubnet(&edns.ClientSubnet{
+			Family:             subnet.Family,
+			SourcePrefixLength: subnet.SourcePrefixLength,
+			Address:            subnet.Address,
+		})
+	}
+
 	// Send query with retries
 	var response *message.DNSResponse
 	var err error
 
 	for attempt := 0; attempt <= r.config.MaxRetries; attempt++ {
+		// Every attempt gets a fresh unpredictable ID
+		query.Header.ID, err = randomQueryID()
+		if err != nil {
+			return nil, err
+		}
+
+		if r.config.CaseRandomization {
+			query.Questions[0].Name = question.Name.RandomizeCase()
+		}
+
 		response, err = r.sendQuery(ctx, query, server)
 		if err == nil {
 			break
@@ -77,58 +203,189 @@ func (r *ForwardResolver) resolveWithServer(ctx context.Context, question messag
 		}
 	}
 
-	// Check response code
-	if response.Header.Flags&types.FLAG_RCODE_NO_ERROR != types.FLAG_RCODE_NO_ERROR {
-		return nil, NewResolutionError(types.DNSRCode(response.Header.Flags&0xF), "server returned error", nil)
+	// A server failing to resolve counts as failing; other codes are answers
+	if rcode := response.Header.Flags.Rcode(); rcode == types.RCODE_SERVER_FAILURE {
+		return nil, NewResolutionError(rcode, "server returned error", nil)
+	}
+
+	if r.config.CaseRandomization {
+		restoreOwnerCase(response.Answers, query.Questions[0].Name, question.Name)
+	}
+
+	// An answer without the option does not depend on the client subnet
+	if subnet != nil {
+		subnet.ScopePrefixLength = 0
+		if echoed := response.ClientSubnet(); echoed != nil {
+			subnet.ScopePrefixLength = echoed.ScopePrefixLength
+		}
 	}
 
 	return response.Answers, nil
 }
 
-// sendQuery sends a DNS query to a server and returns the response
+// sendQuery sends a DNS query to a forward server and returns the response
 func (r *ForwardResolver) sendQuery(ctx context.Context, query *message.DNSResponse, server string) (*message.DNSResponse, error) {
-	// Resolve server address
+	return exchange(ctx, query, server, r.config.Timeout, r.config.CaseRandomization)
+}
+
+// exchange sends a DNS query to a server and returns the response.
+// The query goes out from a fresh ephemeral port and only a reply from the
+// queried server carrying the same ID and question is accepted (with
+// exactCase the question name must match exactly); anything else
+// is discarded while waiting continues until the deadline. The socket
+// deadlines follow the context deadline, or timeout without one, and the
+// query is abandoned as soon as the context is cancelled
+func exchange(ctx context.Context, query *message.DNSResponse, server string, timeout time.Duration, exactCase bool) (*message.DNSResponse, error) {
 	serverAddr, err := net.ResolveUDPAddr("udp", server)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve server address %s: %w", server, err)
 	}
 
+	var listenConfig net.ListenConfig
+	conn, err := listenConfig.ListenPacket(ctx, "udp", ":0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open socket for server %s: %w", server, err)
+	}
+	defer conn.Close()
+
 	// Set deadline for the operation
 	deadline, ok := ctx.Deadline()
 	if !ok {
-		deadline = time.Now().Add(r.config.Timeout)
+		deadline = time.Now().Add(timeout)
 	}
 
-	err = r.client.SetWriteDeadline(deadline)
-	if err != nil {
-		return nil, fmt.Errorf("failed to set write deadline: %w", err)
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, fmt.Errorf("failed to set deadline: %w", err)
 	}
 
+	// Unblock pending socket operations when the context is cancelled
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
 	// Send query
 	queryBytes := query.ToBytesWithCompression()
-	_, err = r.client.WriteToUDP(queryBytes, serverAddr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send query: %w", err)
+	if _, err := conn.WriteTo(queryBytes, serverAddr); err != nil {
+		return nil, queryError(ctx, "failed to send query", err)
 	}
 
-	// Set read deadline
-	err = r.client.SetReadDeadline(deadline)
-	if err != nil {
-		return nil, fmt.Errorf("failed to set read deadline: %w", err)
+	buffer := make([]byte, 4096)
+	for {
+		// Receive response
+		size, from, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return nil, queryError(ctx, "failed to receive response", err)
+		}
+
+		if !sameUDPAddr(from, serverAddr) {
+			continue
+		}
+
+		// Parse response
+		response, err := message.NewDNSResponse(buffer[:size])
+		if err != nil || !matchesQuery(query, response, exactCase) {
+			continue
+		}
+
+		// Compression pointers inside RDATA refer to the upstream message
+		for _, section := range []*[]message.DNSAnswer{&response.Answers, &response.AuthorityRecords, &response.AdditionalRecords} {
+			if *section, err = rebaseAnswers(*section, buffer[:size]); err != nil {
+				return nil, fmt.Errorf("failed to decode response: %w", err)
+			}
+		}
+
+		return response, nil
 	}
+}
 
-	// Receive response
-	buffer := make([]byte, 4096)
-	size, _, err := r.client.ReadFromUDP(buffer)
-	if err != nil {
-		return nil, fmt.Errorf("failed to receive response: %w", err)
+// randomQueryID returns a query ID from a cryptographically secure source
+func randomQueryID() (uint16, error) {
+	var id [2]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return 0, fmt.Errorf("failed to generate query ID: %w", err)
 	}
+	return uint16(id[0])<<8 | uint16(id[1]), nil
+}
 
-	// Parse response
-	response, err := message.NewDNSResponse(buffer[:size])
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+// sameUDPAddr reports whether a datagram came from the expected peer
+func sameUDPAddr(from net.Addr, expected *net.UDPAddr) bool {
+	udpAddr, ok := from.(*net.UDPAddr)
+	return ok && udpAddr.Port == expected.Port && udpAddr.IP.Equal(expected.IP)
+}
+
+// matchesQuery reports whether response answers query: it must be a response
+// with the same ID and echo the question. Names are compared
+// case-insensitively unless exactCase is set
+func matchesQuery(query, response *message.DNSResponse, exactCase bool) bool {
+	if response.Header.ID != query.Header.ID || !response.Header.Flags.IsResponse() {
+		return false
+	}
+
+	if len(response.Questions) != len(query.Questions) {
+		return false
+	}
+
+	for i, question := range query.Questions {
+		echoed := response.Questions[i]
+		if echoed.Qtype() != question.Qtype() || echoed.Qclass() != question.Qclass() {
+			return false
+		}
+
+		if exactCase && !echoed.Name.EqualExact(question.Name) || !echoed.Name.Equal(question.Name) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// restoreOwnerCase gives answers owned by the randomly cased query name the
+// name as the client asked for it, so the 0x20 encoding never leaks to clients
+func restoreOwnerCase(answers []message.DNSAnswer, sent, original utils.DomainName) {
+	for i, answer := range answers {
+		if answer.Name().EqualExact(sent) {
+			answers[i] = answer.WithName(original)
+		}
+	}
+}
+
+// rebaseAnswers re-encodes the RDATA of upstream answers without references
+// to the upstream message, so names are compressed against our own response
+// when it is written. Types without a typed representation are kept verbatim
+func rebaseAnswers(answers []message.DNSAnswer, upstreamMessage []byte) ([]message.DNSAnswer, error) {
+	result := make([]message.DNSAnswer, 0, len(answers))
+
+	for _, answer := range answers {
+		record, err := message.DecodeRData(answer, upstreamMessage)
+		switch {
+		case errors.Is(err, message.ErrUnsupportedRData):
+			result = append(result, answer)
+		case err != nil:
+			return nil, err
+		default:
+			result = append(result, *message.NewDNSAnswerFromParts(
+				answer.Name(), answer.Type(), answer.Class(), answer.TTL(), record.Data()))
+		}
+	}
+
+	return result, nil
+}
+
+// queryError wraps a socket error, reporting the context error instead when
+// the failure was caused by the context being done
+func queryError(ctx context.Context, msg string, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("%s: %w", msg, ctxErr)
+	}
+
+	// The socket deadline may fire just before the context notices it expired
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+			return fmt.Errorf("%s: %w", msg, context.DeadlineExceeded)
+		}
 	}
 
-	return response, nil
+	return fmt.Errorf("%s: %w", msg, err)
 }
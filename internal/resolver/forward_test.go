@@ -0,0 +1,397 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/edns"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// buildUpstreamReply answers query with a single A record for the given question name
+func buildUpstreamReply(t *testing.T, id uint16, question message.DNSQuestion, ip net.IP) []byte {
+	t.Helper()
+
+	answer, err := message.NewAAnswer(question.Name, ip, 60, question.Class)
+	if err != nil {
+		t.Fatalf("failed to build answer: %v", err)
+	}
+
+	return message.NewResponse(id).
+		WithFlags(types.FLAG_QR_RESPONSE).
+		AddQuestion(question).
+		AddAnswer(*answer).
+		Build().
+		ToBytes()
+}
+
+func TestForwardResolver_DiscardsMismatchedResponses(t *testing.T) {
+	upstream, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start fake upstream: %v", err)
+	}
+	defer upstream.Close()
+
+	spoofer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to open spoofing socket: %v", err)
+	}
+	defer spoofer.Close()
+
+	poisoned := net.IPv4(6, 6, 6, 6)
+	genuine := net.IPv4(192, 0, 2, 99)
+
+	go func() {
+		buf := make([]byte, 512)
+		n, addr, err := upstream.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		query, err := message.NewDNSResponse(buf[:n])
+		if err != nil || len(query.Questions) != 1 {
+			return
+		}
+		id, question := query.Header.ID, query.Questions[0]
+
+		otherName, _ := utils.NewDomainNameFromString("evil.example")
+		otherQuestion := question
+		otherQuestion.Name = *otherName
+
+		otherType := message.NewDNSQuestion(question.Name, types.TYPE_AAAA, types.CLASS_IN)
+
+		// Upper-case echo of the question must still be accepted
+		upperName, _ := utils.NewDomainNameFromString("WWW.EXAMPLE.COM")
+		upperQuestion := question
+		upperQuestion.Name = *upperName
+
+		upstream.WriteTo(buildUpstreamReply(t, id+1, question, poisoned), addr)
+		upstream.WriteTo(buildUpstreamReply(t, id, otherQuestion, poisoned), addr)
+		upstream.WriteTo(buildUpstreamReply(t, id, otherType, poisoned), addr)
+		spoofer.WriteTo(buildUpstreamReply(t, id, question, poisoned), addr)
+		upstream.WriteTo([]byte{0xDE, 0xAD}, addr)
+		upstream.WriteTo(buildUpstreamReply(t, id, upperQuestion, genuine), addr)
+	}()
+
+	config := DefaultResolverConfig()
+	config.ForwardServers = []string{upstream.LocalAddr().String()}
+	config.MaxRetries = 0
+
+	forwarder, err := NewForwardResolver(config)
+	if err != nil {
+		t.Fatalf("failed to create forwarder: %v", err)
+	}
+	defer forwarder.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	answers, err := forwarder.Resolve(ctx, createQuestion(t, "www.example.com"))
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+
+	if len(answers) != 1 {
+		t.Fatalf("expected 1 answer, got %d", len(answers))
+	}
+	if !bytes.Equal(answers[0].Data(), genuine.To4()) {
+		t.Errorf("accepted a mismatched response: got %v, want %v", net.IP(answers[0].Data()), genuine)
+	}
+}
+
+func TestForwardResolver_RandomizesQueryID(t *testing.T) {
+	upstream, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start fake upstream: %v", err)
+	}
+	defer upstream.Close()
+
+	ids := make(chan uint16, 8)
+	ports := make(chan int, 8)
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := upstream.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			query, err := message.NewDNSResponse(buf[:n])
+			if err != nil {
+				continue
+			}
+			ids <- query.Header.ID
+			ports <- addr.(*net.UDPAddr).Port
+			upstream.WriteTo(buildUpstreamReply(t, query.Header.ID, query.Questions[0], net.IPv4(192, 0, 2, 1)), addr)
+		}
+	}()
+
+	config := DefaultResolverConfig()
+	config.ForwardServers = []string{upstream.LocalAddr().String()}
+
+	forwarder, err := NewForwardResolver(config)
+	if err != nil {
+		t.Fatalf("failed to create forwarder: %v", err)
+	}
+	defer forwarder.Close()
+
+	const queries = 8
+	seenIDs := make(map[uint16]bool)
+	seenPorts := make(map[int]bool)
+	for range queries {
+		if _, err := forwarder.Resolve(context.Background(), createQuestion(t, "www.example.com")); err != nil {
+			t.Fatalf("resolve failed: %v", err)
+		}
+		seenIDs[<-ids] = true
+		seenPorts[<-ports] = true
+	}
+
+	// Eight 16-bit random IDs colliding down to two is vanishingly unlikely
+	if len(seenIDs) < 3 {
+		t.Errorf("query IDs look predictable: %v", seenIDs)
+	}
+	if len(seenPorts) < 3 {
+		t.Errorf("source ports are reused: %v", seenPorts)
+	}
+}
+
+func TestForwardResolver_PassesThroughUnknownTypes(t *testing.T) {
+	upstream, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start fake upstream: %v", err)
+	}
+	defer upstream.Close()
+
+	// RDATA of an unknown type must not be read as names, even when it
+	// looks like a compression pointer
+	rdata := []byte{0xC0, 0x0C, 0x0A, 0x00, 0x00, 0x01}
+	go func() {
+		buf := make([]byte, 512)
+		n, addr, err := upstream.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		query, err := message.NewDNSResponse(buf[:n])
+		if err != nil || len(query.Questions) != 1 {
+			return
+		}
+		question := query.Questions[0]
+		answer := message.NewDNSAnswerFromParts(question.Name, question.Qtype(), types.CLASS_IN, 60, rdata)
+		reply := message.NewResponse(query.Header.ID).
+			WithFlags(types.FLAG_QR_RESPONSE).
+			AddQuestion(question).
+			AddAnswer(*answer).
+			Build().
+			ToBytes()
+		upstream.WriteTo(reply, addr)
+	}()
+
+	config := DefaultResolverConfig()
+	config.ForwardServers = []string{upstream.LocalAddr().String()}
+	config.MaxRetries = 0
+
+	forwarder, err := NewForwardResolver(config)
+	if err != nil {
+		t.Fatalf("failed to create forwarder: %v", err)
+	}
+	defer forwarder.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	question := createQuestion(t, "private.example.com")
+	question = message.NewDNSQuestion(question.Name, types.DNSType(65280), types.CLASS_IN)
+	answers, err := forwarder.Resolve(ctx, question)
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+
+	if len(answers) != 1 {
+		t.Fatalf("expected 1 answer, got %d", len(answers))
+	}
+	if answers[0].Type() != types.DNSType(65280) || !bytes.Equal(answers[0].Data(), rdata) {
+		t.Errorf("answer is %s %X, expected TYPE65280 %X", answers[0].Type().Mnemonic(), answers[0].Data(), rdata)
+	}
+}
+
+// swapCase inverts the case of every ASCII letter
+func swapCase(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z':
+			return r - 'a' + 'A'
+		case 'A' <= r && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return r
+	}, name)
+}
+
+func createQuestion(t *testing.T, name string) message.DNSQuestion {
+	t.Helper()
+
+	domainName, err := utils.NewDomainNameFromString(name)
+	if err != nil {
+		t.Fatalf("invalid name %q: %v", name, err)
+	}
+
+	return message.NewDNSQuestion(*domainName, types.TYPE_A, types.CLASS_IN)
+}
+
+func TestForwardResolver_CaseRandomization(t *testing.T) {
+	upstream, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start fake upstream: %v", err)
+	}
+	defer upstream.Close()
+
+	poisoned := net.IPv4(6, 6, 6, 6)
+	genuine := net.IPv4(192, 0, 2, 99)
+
+	sent := make(chan string, 16)
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := upstream.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			query, err := message.NewDNSResponse(buf[:n])
+			if err != nil || len(query.Questions) != 1 {
+				continue
+			}
+			question := query.Questions[0]
+			sent <- question.Name.String()
+
+			// An echo that does not preserve the randomized case must be rejected
+			swapped, _ := utils.NewDomainNameFromString(swapCase(question.Name.String()))
+			swappedQuestion := question
+			swappedQuestion.Name = *swapped
+
+			upstream.WriteTo(buildUpstreamReply(t, query.Header.ID, swappedQuestion, poisoned), addr)
+			upstream.WriteTo(buildUpstreamReply(t, query.Header.ID, question, genuine), addr)
+		}
+	}()
+
+	config := DefaultResolverConfig()
+	config.ForwardServers = []string{upstream.LocalAddr().String()}
+	config.MaxRetries = 0
+	config.CaseRandomization = true
+
+	forwarder, err := NewForwardResolver(config)
+	if err != nil {
+		t.Fatalf("failed to create forwarder: %v", err)
+	}
+	defer forwarder.Close()
+
+	question := createQuestion(t, "www.example.com")
+
+	randomized := false
+	for range 8 {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		answers, err := forwarder.Resolve(ctx, question)
+		cancel()
+		if err != nil {
+			t.Fatalf("resolve failed: %v", err)
+		}
+
+		if name := <-sent; name != strings.ToLower(name) {
+			randomized = true
+		}
+
+		if len(answers) != 1 {
+			t.Fatalf("expected 1 answer, got %d", len(answers))
+		}
+		if !bytes.Equal(answers[0].Data(), genuine.To4()) {
+			t.Errorf("accepted a response with the wrong case: got %v", net.IP(answers[0].Data()))
+		}
+
+		// The client sees its own spelling of the name, not the 0x20 one
+		owner := answers[0].Name()
+		if !owner.EqualExact(question.Name) {
+			t.Errorf("got owner %s, want %s", owner.String(), question.Name.String())
+		}
+	}
+
+	// Eight queries of 13 letters all staying lower case is practically impossible
+	if !randomized {
+		t.Error("query name case was never randomized")
+	}
+}
+
+func TestForwardResolver_ClientSubnet(t *testing.T) {
+	upstream, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start fake upstream: %v", err)
+	}
+	defer upstream.Close()
+
+	// The upstream answers with a /16 scope and reports the subnets it got
+	received := make(chan *edns.ClientSubnet, 4)
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := upstream.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			query, err := message.NewDNSResponse(buf[:n])
+			if err != nil {
+				continue
+			}
+			subnet := query.ClientSubnet()
+			received <- subnet
+
+			answer, _ := message.NewAAnswer(query.Questions[0].Name, net.IPv4(192, 0, 2, 1), 60, query.Questions[0].Class)
+			response := message.NewResponse(query.Header.ID).
+				WithFlags(types.FLAG_QR_RESPONSE).
+				AddQuestion(query.Questions...).
+				AddAnswer(*answer).
+				Build()
+			if subnet != nil {
+				scoped := *subnet
+				scoped.ScopePrefixLength = 16
+				response.AddClientSubnet(&scoped)
+			}
+			upstream.WriteTo(response.ToBytes(), addr)
+		}
+	}()
+
+	config := DefaultResolverConfig()
+	config.ForwardServers = []string{upstream.LocalAddr().String()}
+	config.CacheEnabled = true
+	forwarder, err := NewForwardResolver(config)
+	if err != nil {
+		t.Fatalf("failed to create forwarder: %v", err)
+	}
+	cached := NewCacheResolver(config, NewSingleflightResolver(config, forwarder))
+	defer cached.Close()
+
+	subnet := edns.NewClientSubnet(net.ParseIP("198.51.100.7"), 24)
+	for range 2 {
+		if _, err := cached.Resolve(WithClientSubnet(context.Background(), subnet), createQuestion(t, "www.example.com")); err != nil {
+			t.Fatalf("resolve failed: %v", err)
+		}
+		sent := <-received
+		if sent == nil || sent.String() != "198.51.100.0/24/0" {
+			t.Errorf("upstream got client subnet %v, want 198.51.100.0/24/0", sent)
+		}
+	}
+	if subnet.ScopePrefixLength != 16 {
+		t.Errorf("scope prefix length = %d, want the upstream's 16", subnet.ScopePrefixLength)
+	}
+
+	// Without a subnet nothing is forwarded, and the scoped answers were
+	// not cached
+	if _, err := cached.Resolve(context.Background(), createQuestion(t, "www.example.com")); err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	if sent := <-received; sent != nil {
+		t.Errorf("upstream got client subnet %s without one", sent)
+	}
+}
@@ -1,9 +1,14 @@
 package resolver
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	_ "embed"
+	"errors"
 	"fmt"
 	"net"
+	"strings"
 	"time"
 
 	"github.com/vadim-su/dnska/pkg/dns/message"
@@ -11,9 +16,25 @@ import (
 	"github.com/vadim-su/dnska/pkg/dns/utils"
 )
 
+// rootHints is the IANA root hints file (named.root)
+//
+//go:embed root.hints
+var rootHints []byte
+
+// serverTimeout bounds a query to a single name server, so an unresponsive
+// server leaves time to try the other servers of a zone
+const serverTimeout = 2 * time.Second
+
+// queryBudget counts the name server queries a resolution may still send.
+// It is shared by the resolutions nested in it, so a question cannot make
+// the resolver send an unbounded number of queries
+type queryBudget struct {
+	remaining int
+}
+
 // Resolve performs DNS resolution for the given question using recursive resolution
 func (r *RecursiveResolver) Resolve(ctx context.Context, question message.DNSQuestion) ([]message.DNSAnswer, error) {
-	return r.resolveRecursive(ctx, question, r.rootServer, 0)
+	return r.resolve(ctx, question, &queryBudget{remaining: r.maxQueries}, 0)
 }
 
 // ResolveAll performs DNS resolution for multiple questions
@@ -31,108 +52,481 @@ func (r *RecursiveResolver) ResolveAll(ctx context.Context, questions []message.
 	return allAnswers, nil
 }
 
+// SetQNameMinimization sets whether the servers of a zone are only sent as
+// much of a question name as they need to refer it further (RFC 7816),
+// rather than the full name
+func (r *RecursiveResolver) SetQNameMinimization(enabled bool) {
+	r.qnameMinimization.Store(enabled)
+}
+
+// QNameMinimizationStats counts the outcomes of minimized queries
+type QNameMinimizationStats struct {
+	Minimized uint64 // Minimized queries answered
+	FellBack  uint64 // Minimized queries denied or failed, asked again with the full name
+}
+
+// GetQNameMinimizationStats returns the outcomes of the minimized queries
+// sent so far
+func (r *RecursiveResolver) GetQNameMinimizationStats() QNameMinimizationStats {
+	return QNameMinimizationStats{
+		Minimized: r.minimized.Load(),
+		FellBack:  r.minimizationFallbacks.Load(),
+	}
+}
+
 // Close closes the resolver and cleans up resources
 func (r *RecursiveResolver) Close() error {
-	if r.client != nil {
-		return r.client.Close()
-	}
 	return nil
 }
 
-// resolveRecursive performs recursive DNS resolution
-func (r *RecursiveResolver) resolveRecursive(ctx context.Context, question message.DNSQuestion, server string, depth int) ([]message.DNSAnswer, error) {
-	// Check recursion depth limit
-	if depth >= r.config.RecursionDepth {
+// resolve queries the servers of the closest known zone of the question name
+// and follows referrals until a server answers authoritatively. depth counts
+// the resolutions of name server addresses and CNAME targets nested in the
+// resolution of the original question, all of which draw on budget.
+//
+// With QNAME minimization the servers of a zone are asked for the NS records
+// of the name one label below the zone instead of the full question, adding
+// a label at a time until a referral or the full name is reached. A name
+// denied with the SOA of the zone has nothing below it (RFC 8020), so the
+// walk stops there; other denials and errors are taken for servers that do
+// not cope with minimized names, which are asked the full name instead
+func (r *RecursiveResolver) resolve(ctx context.Context, question message.DNSQuestion, budget *queryBudget, depth int) ([]message.DNSAnswer, error) {
+	if depth > r.config.RecursionDepth {
 		return nil, NewResolutionError(types.RCODE_SERVER_FAILURE, "recursion depth limit exceeded", nil)
 	}
 
-	// Create DNS query
-	query := message.GenerateDNSQuery(uint16(depth), []message.DNSQuestion{question})
+	zone, servers := r.closestServers(question.Name)
+	minimize := r.qnameMinimization.Load()
+	revealed := len(zone.Labels) + 1 // Labels of the name sent to the servers of zone
 
-	// Send query to server
-	response, err := r.sendQuery(ctx, query, server)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query server %s: %w", server, err)
+	for hop := 0; hop < r.maxHops; {
+		asked := question
+		minimized := minimize && revealed < len(question.Name.Labels)
+		if minimized {
+			asked = minimizedQuestion(question, revealed)
+		}
+
+		response, err := r.queryServers(ctx, asked, servers, budget)
+		if err != nil {
+			return nil, err
+		}
+
+		rcode := response.Header.Flags.Rcode()
+		if minimized {
+			if !minimizedAnswer(response) {
+				r.minimizationFallbacks.Add(1)
+				minimize = false
+				continue
+			}
+			r.minimized.Add(1)
+		}
+
+		switch rcode {
+		case types.RCODE_NO_ERROR:
+		case types.RCODE_NAME_ERROR:
+			return nil, NewResolutionError(rcode, "domain not found", nil)
+		default:
+			return nil, NewResolutionError(rcode, "server returned error", nil)
+		}
+
+		if len(response.Answers) > 0 && !minimized {
+			return r.followCNAME(ctx, question, response.Answers, budget, depth)
+		}
+
+		child, nameServers, err := referral(response, asked.Name, zone)
+		if err != nil {
+			return nil, err
+		}
+		if len(nameServers) == 0 {
+			// No zone cut at the minimized name, reveal another label
+			if minimized {
+				revealed++
+				continue
+			}
+
+			// The name exists but has no records of the type
+			if response.Header.Flags.IsAuthoritative() || hasSOA(response.AuthorityRecords) {
+				return nil, nil
+			}
+			return nil, NewResolutionError(types.RCODE_SERVER_FAILURE,
+				fmt.Sprintf("lame response from servers of %s", zone.String()), nil)
+		}
+
+		r.cacheReferral(response, child, nameServers, zone)
+
+		servers, err = r.serverAddresses(ctx, nameServers, budget, depth)
+		if err != nil {
+			return nil, err
+		}
+		zone = child
+		revealed = len(zone.Labels) + 1
+		hop++
 	}
 
-	// Check response code
-	if response.Header.Flags&types.FLAG_RCODE_NO_ERROR != types.FLAG_RCODE_NO_ERROR {
-		return nil, NewResolutionError(types.DNSRCode(response.Header.Flags&0xF), "server returned error", nil)
+	return nil, NewResolutionError(types.RCODE_SERVER_FAILURE,
+		fmt.Sprintf("no answer for %s within %d referrals", question.Name.String(), r.maxHops), nil)
+}
+
+// minimizedAnswer reports whether a response to a minimized question can be
+// relied on: an answer, or a denial carrying the SOA of the zone. Servers
+// that do not know empty non-terminals deny the names above the question
+// without one, and others fail queries for NS records they do not expect
+func minimizedAnswer(response *message.DNSResponse) bool {
+	switch response.Header.Flags.Rcode() {
+	case types.RCODE_NO_ERROR:
+		return true
+	case types.RCODE_NAME_ERROR:
+		return hasSOA(response.AuthorityRecords)
+	default:
+		return false
 	}
+}
 
-	// If we have answers, return them
-	if len(response.Answers) > 0 {
-		return response.Answers, nil
+// minimizedQuestion asks for the NS records of the name made of the last
+// labels labels of the question name (RFC 7816)
+func minimizedQuestion(question message.DNSQuestion, labels int) message.DNSQuestion {
+	name := question.Name
+	name.Labels = name.Labels[len(name.Labels)-labels:]
+
+	return message.NewDNSQuestion(name, types.TYPE_NS, question.Qclass())
+}
+
+// queryServers asks the servers in turn until one of them responds, giving
+// up once budget is spent
+func (r *RecursiveResolver) queryServers(ctx context.Context, question message.DNSQuestion, servers []string, budget *queryBudget) (*message.DNSResponse, error) {
+	var lastErr error
+
+	for _, server := range servers {
+		if budget.remaining <= 0 {
+			return nil, NewResolutionError(types.RCODE_SERVER_FAILURE,
+				fmt.Sprintf("no answer within %d name server queries", r.maxQueries), lastErr)
+		}
+		budget.remaining--
+
+		response, err := r.queryServer(ctx, question, server)
+		if err == nil {
+			return response, nil
+		}
+		lastErr = err
+
+		// Abandon the query once the caller is no longer waiting for it
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return nil, fmt.Errorf("all name servers failed, last error: %w", lastErr)
+}
+
+// queryServer sends an iterative query for question to a single server
+func (r *RecursiveResolver) queryServer(ctx context.Context, question message.DNSQuestion, server string) (*message.DNSResponse, error) {
+	query := message.GenerateDNSQuery(0, []message.DNSQuestion{question})
+	query.Header.Flags = types.NewFlagBuilder(query.Header.Flags).SetRD(false).Build()
+
+	var err error
+	if query.Header.ID, err = randomQueryID(); err != nil {
+		return nil, err
+	}
+
+	if r.config.CaseRandomization {
+		query.Questions[0].Name = question.Name.RandomizeCase()
+	}
+
+	serverCtx, cancel := context.WithTimeout(ctx, serverTimeout)
+	defer cancel()
+
+	response, err := exchange(serverCtx, query, server, r.config.Timeout, r.config.CaseRandomization)
+	if err != nil {
+		return nil, fmt.Errorf("server %s: %w", server, err)
 	}
 
-	// If we have authoritative answers but no answers, domain doesn't exist
-	if response.Header.Flags&types.FLAG_AA_AUTHORITATIVE == types.FLAG_AA_AUTHORITATIVE {
-		return nil, NewResolutionError(types.RCODE_NAME_ERROR, "domain not found", nil)
+	if r.config.CaseRandomization {
+		restoreOwnerCase(response.Answers, query.Questions[0].Name, question.Name)
 	}
 
-	// For now, return server failure as we don't have authority/additional record parsing
-	// This is a simplified implementation
-	return nil, NewResolutionError(types.RCODE_SERVER_FAILURE, "no authoritative servers found", nil)
+	return response, nil
 }
 
-// sendQuery sends a DNS query to a server and returns the response
-func (r *RecursiveResolver) sendQuery(ctx context.Context, query *message.DNSResponse, server string) (*message.DNSResponse, error) {
-	// Resolve server address
-	serverAddr, err := net.ResolveUDPAddr("udp", server)
+// followCNAME completes answers ending in a CNAME whose target the answering
+// server left unresolved by resolving the target
+func (r *RecursiveResolver) followCNAME(ctx context.Context, question message.DNSQuestion, answers []message.DNSAnswer, budget *queryBudget, depth int) ([]message.DNSAnswer, error) {
+	questionType := question.Qtype()
+	if questionType == types.TYPE_CNAME {
+		return answers, nil
+	}
+
+	var target *utils.DomainName
+	for _, answer := range answers {
+		switch answer.Type() {
+		case questionType:
+			return answers, nil
+		case types.TYPE_CNAME:
+			name, _, err := utils.NewDomainName(answer.Data())
+			if err != nil {
+				return nil, fmt.Errorf("invalid CNAME target: %w", err)
+			}
+			target = name
+		}
+	}
+
+	if target == nil {
+		return answers, nil
+	}
+
+	next := question
+	next.Name = *target
+	targetAnswers, err := r.resolve(ctx, next, budget, depth+1)
 	if err != nil {
-		return nil, fmt.Errorf("failed to resolve server address %s: %w", server, err)
+		return nil, err
+	}
+
+	return append(answers, targetAnswers...), nil
+}
+
+// serverAddresses returns the addresses of the name servers, resolving the
+// names of servers without cached addresses when needed
+func (r *RecursiveResolver) serverAddresses(ctx context.Context, nameServers []string, budget *queryBudget, depth int) ([]string, error) {
+	if servers := r.cachedServers(nameServers); len(servers) > 0 {
+		return servers, nil
+	}
+
+	var lastErr error
+	for _, nameServer := range nameServers {
+		question, err := createNSQuestion(nameServer)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		answers, err := r.resolve(ctx, question, budget, depth+1)
+		if err != nil {
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		var servers []string
+		for _, answer := range answers {
+			if answer.Type() == types.TYPE_A && answer.Name().Equal(question.Name) {
+				servers = append(servers, net.JoinHostPort(net.IP(answer.Data()).String(), r.port))
+			}
+		}
+		if len(servers) > 0 {
+			r.cacheAddresses(question.Name, answers)
+			return servers, nil
+		}
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("no addresses found")
 	}
+	return nil, NewResolutionError(types.RCODE_SERVER_FAILURE, "failed to resolve name servers", lastErr)
+}
+
+// closestServers returns the deepest zone enclosing name with cached server
+// addresses and those addresses, or the root zone and the root servers
+func (r *RecursiveResolver) closestServers(name utils.DomainName) (utils.DomainName, []string) {
+	for zone := name; len(zone.Labels) > 0; zone = zone.Parent() {
+		r.mu.Lock()
+		item, ok := r.lookup(r.delegations, zone)
+		r.mu.Unlock()
+
+		if !ok {
+			continue
+		}
+		if servers := r.cachedServers(item.values); len(servers) > 0 {
+			return zone, servers
+		}
+	}
+
+	return utils.DomainName{Labels: []utils.Label{}}, r.roots
+}
 
-	// Set deadline for the operation
-	deadline, ok := ctx.Deadline()
+// cachedServers returns the cached addresses of the name servers
+func (r *RecursiveResolver) cachedServers(nameServers []string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var servers []string
+	for _, nameServer := range nameServers {
+		name, err := utils.NewDomainNameFromString(nameServer)
+		if err != nil {
+			continue
+		}
+		if item, ok := r.lookup(r.addresses, *name); ok {
+			for _, ip := range item.values {
+				servers = append(servers, net.JoinHostPort(ip, r.port))
+			}
+		}
+	}
+	return servers
+}
+
+// lookup returns the unexpired item cached for name, dropping an expired
+// one. The caller must hold r.mu
+func (r *RecursiveResolver) lookup(cache map[string]cacheItem, name utils.DomainName) (cacheItem, bool) {
+	key := cacheName(name)
+	item, ok := cache[key]
 	if !ok {
-		deadline = time.Now().Add(r.config.Timeout)
+		return cacheItem{}, false
 	}
+	if time.Now().After(item.expiresAt) {
+		delete(cache, key)
+		return cacheItem{}, false
+	}
+	return item, true
+}
 
-	err = r.client.SetWriteDeadline(deadline)
-	if err != nil {
-		return nil, fmt.Errorf("failed to set write deadline: %w", err)
+// cacheReferral caches the name servers of the delegated zone and the
+// addresses of those servers given as glue. Glue is only trusted for names
+// within zone, the zone of the server that sent the referral
+func (r *RecursiveResolver) cacheReferral(response *message.DNSResponse, child utils.DomainName, nameServers []string, zone utils.DomainName) {
+	ttl := uint32(0)
+	for _, record := range response.AuthorityRecords {
+		if record.Type() == types.TYPE_NS && record.Name().Equal(child) && (ttl == 0 || record.TTL() < ttl) {
+			ttl = record.TTL()
+		}
 	}
 
-	// Send query
-	queryBytes := query.ToBytesWithCompression()
-	_, err = r.client.WriteToUDP(queryBytes, serverAddr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send query: %w", err)
+	r.mu.Lock()
+	r.delegations[cacheName(child)] = cacheItem{values: nameServers, expiresAt: expiry(ttl)}
+	r.mu.Unlock()
+
+	for _, nameServer := range nameServers {
+		name, err := utils.NewDomainNameFromString(nameServer)
+		if err != nil || !name.IsSubdomainOf(zone) {
+			continue
+		}
+		r.cacheAddresses(*name, response.AdditionalRecords)
 	}
+}
 
-	// Set read deadline
-	err = r.client.SetReadDeadline(deadline)
-	if err != nil {
-		return nil, fmt.Errorf("failed to set read deadline: %w", err)
+// cacheAddresses caches the A records of name found among records
+func (r *RecursiveResolver) cacheAddresses(name utils.DomainName, records []message.DNSAnswer) {
+	var ips []string
+	ttl := uint32(0)
+	for _, record := range records {
+		if record.Type() != types.TYPE_A || !record.Name().Equal(name) {
+			continue
+		}
+		ips = append(ips, net.IP(record.Data()).String())
+		if ttl == 0 || record.TTL() < ttl {
+			ttl = record.TTL()
+		}
 	}
 
-	// Receive response
-	buffer := make([]byte, 4096)
-	size, _, err := r.client.ReadFromUDP(buffer)
-	if err != nil {
-		return nil, fmt.Errorf("failed to receive response: %w", err)
+	if len(ips) == 0 {
+		return
 	}
 
-	// Parse response
-	response, err := message.NewDNSResponse(buffer[:size])
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	r.mu.Lock()
+	r.addresses[cacheName(name)] = cacheItem{values: ips, expiresAt: expiry(ttl)}
+	r.mu.Unlock()
+}
+
+// referral extracts the delegation a response refers name to: the zone
+// named by its authority NS records and the names of its servers. A
+// referral must lead below zone, the zone of the server that sent it,
+// otherwise following it could loop
+func referral(response *message.DNSResponse, name, zone utils.DomainName) (utils.DomainName, []string, error) {
+	var child utils.DomainName
+	var nameServers []string
+
+	for _, record := range response.AuthorityRecords {
+		if record.Type() != types.TYPE_NS || !name.IsSubdomainOf(record.Name()) {
+			continue
+		}
+		if nameServers != nil && !record.Name().Equal(child) {
+			continue
+		}
+
+		target, _, err := utils.NewDomainName(record.Data())
+		if err != nil {
+			return child, nil, fmt.Errorf("invalid NS record: %w", err)
+		}
+		child = record.Name()
+		nameServers = append(nameServers, target.String())
 	}
 
-	return response, nil
+	if nameServers != nil && (len(child.Labels) <= len(zone.Labels) || !child.IsSubdomainOf(zone)) {
+		return child, nil, NewResolutionError(types.RCODE_SERVER_FAILURE,
+			fmt.Sprintf("referral loop detected: servers of %s referred to %s", zone.String(), child.String()), nil)
+	}
+
+	return child, nameServers, nil
+}
+
+// hasSOA reports whether records contain an SOA record, as negative
+// answers do
+func hasSOA(records []message.DNSAnswer) bool {
+	for _, record := range records {
+		if record.Type() == types.TYPE_SOA {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheName returns the key name is cached under
+func cacheName(name utils.DomainName) string {
+	return name.CanonicalString()
+}
+
+// expiry returns the time a record with ttl cached now expires
+func expiry(ttl uint32) time.Time {
+	return time.Now().Add(time.Duration(ttl) * time.Second)
+}
+
+// parseRootHints returns the addresses of the root servers listed in a root
+// hints file, IPv4 addresses first, each joined with port
+func parseRootHints(data []byte, port string) ([]string, error) {
+	var ipv4, ipv6 []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if comment := strings.IndexByte(line, ';'); comment >= 0 {
+			line = line[:comment]
+		}
+
+		// Owner, TTL, type and value
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			continue
+		}
+
+		switch strings.ToUpper(fields[2]) {
+		case "A", "AAAA":
+			ip := net.ParseIP(fields[3])
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q of %s", fields[3], fields[0])
+			}
+			address := net.JoinHostPort(ip.String(), port)
+			if ip.To4() != nil {
+				ipv4 = append(ipv4, address)
+			} else {
+				ipv6 = append(ipv6, address)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ipv4)+len(ipv6) == 0 {
+		return nil, errors.New("no root server addresses")
+	}
+	return append(ipv4, ipv6...), nil
 }
 
 // Helper function to create a DNS question for NS resolution
 func createNSQuestion(nsName string) (message.DNSQuestion, error) {
-	domainName, _, err := utils.NewDomainName([]byte(nsName))
+	domainName, err := utils.NewDomainNameFromString(nsName)
 	if err != nil {
 		return message.DNSQuestion{}, err
 	}
 
-	return message.DNSQuestion{
-		Name:  *domainName,
-		Type:  types.DnsTypeClassToBytes(types.TYPE_A),
-		Class: types.DnsTypeClassToBytes(types.CLASS_IN),
-	}, nil
+	return message.NewDNSQuestion(*domainName, types.TYPE_A, types.CLASS_IN), nil
 }
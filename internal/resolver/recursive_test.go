@@ -0,0 +1,583 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// fakeNameServer answers queries with responses built by a handler
+type fakeNameServer struct {
+	conn    net.PacketConn
+	queries atomic.Int32
+}
+
+// startNameServers starts a name server per handler on consecutive loopback
+// addresses sharing one port, as referrals carry no port. It returns the
+// servers and the port
+func startNameServers(t *testing.T, handlers ...func(question message.DNSQuestion) *message.ResponseBuilder) ([]*fakeNameServer, string) {
+	t.Helper()
+
+	for attempt := 0; attempt < 10; attempt++ {
+		servers, port, err := listenNameServers(len(handlers))
+		if err != nil {
+			continue
+		}
+
+		for i, server := range servers {
+			t.Cleanup(func() { server.conn.Close() })
+			go server.serve(handlers[i])
+		}
+		return servers, port
+	}
+
+	t.Fatal("failed to start fake name servers")
+	return nil, ""
+}
+
+// listenNameServers binds count sockets on 127.0.0.1, 127.0.0.2, ... with the
+// port of the first one
+func listenNameServers(count int) ([]*fakeNameServer, string, error) {
+	var servers []*fakeNameServer
+	port := "0"
+
+	for i := 0; i < count; i++ {
+		conn, err := net.ListenPacket("udp", net.JoinHostPort(net.IPv4(127, 0, 0, byte(i+1)).String(), port))
+		if err != nil {
+			for _, server := range servers {
+				server.conn.Close()
+			}
+			return nil, "", err
+		}
+		port = strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port)
+		servers = append(servers, &fakeNameServer{conn: conn})
+	}
+
+	return servers, port, nil
+}
+
+// serve answers queries until the socket is closed
+func (s *fakeNameServer) serve(handler func(question message.DNSQuestion) *message.ResponseBuilder) {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		query, err := message.NewDNSResponse(buf[:n])
+		if err != nil || len(query.Questions) != 1 {
+			continue
+		}
+		s.queries.Add(1)
+
+		response := handler(query.Questions[0]).Build()
+		response.Header.ID = query.Header.ID
+		s.conn.WriteTo(response.ToBytes(), addr)
+	}
+}
+
+func mustDomainName(t *testing.T, name string) utils.DomainName {
+	t.Helper()
+
+	domainName, err := utils.NewDomainNameFromString(name)
+	if err != nil {
+		t.Fatalf("invalid domain name %q: %v", name, err)
+	}
+	return *domainName
+}
+
+// referralTo refers question to zone served by nameServer at address.
+// A nil address leaves out the glue
+func referralTo(t *testing.T, question message.DNSQuestion, zone, nameServer string, address net.IP) *message.ResponseBuilder {
+	t.Helper()
+
+	serverName := mustDomainName(t, nameServer)
+	ns := message.NewDNSAnswerFromParts(mustDomainName(t, zone), types.TYPE_NS, types.CLASS_IN, 3600, serverName.ToBytes())
+
+	builder := message.NewResponse(0).
+		WithFlags(types.FLAG_QR_RESPONSE).
+		AddQuestion(question).
+		AddAuthority(*ns)
+
+	if address != nil {
+		glue, err := message.NewAAnswer(serverName, address, 3600, question.Class)
+		if err != nil {
+			t.Fatalf("failed to build glue: %v", err)
+		}
+		builder.AddAdditional(*glue)
+	}
+	return builder
+}
+
+// answerWith answers question authoritatively with an A record
+func answerWith(t *testing.T, question message.DNSQuestion, ip net.IP) *message.ResponseBuilder {
+	t.Helper()
+
+	answer, err := message.NewAAnswer(question.Name, ip, 300, question.Class)
+	if err != nil {
+		t.Fatalf("failed to build answer: %v", err)
+	}
+
+	return message.NewResponse(0).
+		WithFlags(types.FLAG_QR_RESPONSE | types.FLAG_AA_AUTHORITATIVE).
+		AddQuestion(question).
+		AddAnswer(*answer)
+}
+
+func newTestRecursiveResolver(t *testing.T, port string, maxHops int) *RecursiveResolver {
+	t.Helper()
+
+	r, err := NewRecursiveResolver(&ResolverConfig{
+		Timeout:        time.Second,
+		RootServers:    []string{net.JoinHostPort("127.0.0.1", port)},
+		RecursionDepth: 5,
+		MaxHops:        maxHops,
+	})
+	if err != nil {
+		t.Fatalf("NewRecursiveResolver() returned error: %v", err)
+	}
+	r.port = port
+	return r
+}
+
+func aQuestion(t *testing.T, name string) message.DNSQuestion {
+	t.Helper()
+
+	return message.NewDNSQuestion(mustDomainName(t, name), types.TYPE_A, types.CLASS_IN)
+}
+
+func TestRecursiveResolver_FollowsReferrals(t *testing.T) {
+	tldAddress := net.IPv4(127, 0, 0, 2)
+	authAddress := net.IPv4(127, 0, 0, 3)
+
+	servers, port := startNameServers(t,
+		func(q message.DNSQuestion) *message.ResponseBuilder {
+			return referralTo(t, q, "test.", "ns.nic.test.", tldAddress)
+		},
+		func(q message.DNSQuestion) *message.ResponseBuilder {
+			return referralTo(t, q, "example.test.", "ns1.example.test.", authAddress)
+		},
+		func(q message.DNSQuestion) *message.ResponseBuilder {
+			return answerWith(t, q, net.IPv4(192, 0, 2, 10))
+		},
+	)
+
+	r := newTestRecursiveResolver(t, port, DefaultMaxHops)
+	ctx := context.Background()
+
+	answers, err := r.Resolve(ctx, aQuestion(t, "www.example.test"))
+	if err != nil {
+		t.Fatalf("Resolve() returned error: %v", err)
+	}
+	if len(answers) != 1 || !net.IP(answers[0].Data()).Equal(net.IPv4(192, 0, 2, 10)) {
+		t.Fatalf("Resolve() = %v, expected a single A record for 192.0.2.10", answers)
+	}
+
+	// The delegation of example.test is cached and queried directly
+	if _, err := r.Resolve(ctx, aQuestion(t, "mail.example.test")); err != nil {
+		t.Fatalf("Resolve() returned error: %v", err)
+	}
+
+	for i, expected := range []int32{1, 1, 2} {
+		if got := servers[i].queries.Load(); got != expected {
+			t.Errorf("server %d received %d queries, expected %d", i+1, got, expected)
+		}
+	}
+}
+
+func TestRecursiveResolver_ResolvesGluelessNameServers(t *testing.T) {
+	authAddress := net.IPv4(127, 0, 0, 2)
+
+	_, port := startNameServers(t,
+		func(q message.DNSQuestion) *message.ResponseBuilder {
+			if q.Name.Equal(mustDomainName(t, "ns.dns.test")) {
+				return answerWith(t, q, authAddress)
+			}
+			// Delegation to a server outside the zone, without glue
+			return referralTo(t, q, "example.test.", "ns.dns.test.", nil)
+		},
+		func(q message.DNSQuestion) *message.ResponseBuilder {
+			return answerWith(t, q, net.IPv4(192, 0, 2, 20))
+		},
+	)
+
+	r := newTestRecursiveResolver(t, port, DefaultMaxHops)
+
+	answers, err := r.Resolve(context.Background(), aQuestion(t, "www.example.test"))
+	if err != nil {
+		t.Fatalf("Resolve() returned error: %v", err)
+	}
+	if len(answers) != 1 || !net.IP(answers[0].Data()).Equal(net.IPv4(192, 0, 2, 20)) {
+		t.Fatalf("Resolve() = %v, expected a single A record for 192.0.2.20", answers)
+	}
+}
+
+func TestRecursiveResolver_RestartsAtCNAMETarget(t *testing.T) {
+	otherAddress := net.IPv4(127, 0, 0, 2)
+
+	servers, port := startNameServers(t,
+		func(q message.DNSQuestion) *message.ResponseBuilder {
+			if q.Name.IsSubdomainOf(mustDomainName(t, "other.test")) {
+				return referralTo(t, q, "other.test.", "ns.other.test.", otherAddress)
+			}
+			// An alias into a zone the server does not serve
+			target := mustDomainName(t, "www.other.test")
+			cname := message.NewDNSAnswerFromParts(q.Name, types.TYPE_CNAME, types.CLASS_IN, 300, target.ToBytes())
+			return message.NewResponse(0).
+				WithFlags(types.FLAG_QR_RESPONSE | types.FLAG_AA_AUTHORITATIVE).
+				AddQuestion(q).
+				AddAnswer(*cname)
+		},
+		func(q message.DNSQuestion) *message.ResponseBuilder {
+			return answerWith(t, q, net.IPv4(192, 0, 2, 40))
+		},
+	)
+
+	r := newTestRecursiveResolver(t, port, DefaultMaxHops)
+
+	answers, err := r.Resolve(context.Background(), aQuestion(t, "www.example.test"))
+	if err != nil {
+		t.Fatalf("Resolve() returned error: %v", err)
+	}
+	if len(answers) != 2 || answers[0].Type() != types.TYPE_CNAME || !net.IP(answers[1].Data()).Equal(net.IPv4(192, 0, 2, 40)) {
+		t.Fatalf("Resolve() = %v, expected the CNAME followed by the A record for 192.0.2.40", answers)
+	}
+	if got := servers[1].queries.Load(); got != 1 {
+		t.Errorf("server of other.test received %d queries, expected 1", got)
+	}
+}
+
+func TestRecursiveResolver_QueryLimit(t *testing.T) {
+	authAddress := net.IPv4(127, 0, 0, 2)
+
+	_, port := startNameServers(t,
+		func(q message.DNSQuestion) *message.ResponseBuilder {
+			if q.Name.Equal(mustDomainName(t, "ns.dns.test")) {
+				return answerWith(t, q, authAddress)
+			}
+			return referralTo(t, q, "example.test.", "ns.dns.test.", nil)
+		},
+		func(q message.DNSQuestion) *message.ResponseBuilder {
+			return answerWith(t, q, net.IPv4(192, 0, 2, 20))
+		},
+	)
+
+	// The referral and the address of the glue-less server use up the
+	// budget before the authoritative server is asked
+	r := newTestRecursiveResolver(t, port, DefaultMaxHops)
+	r.maxQueries = 2
+
+	_, err := r.Resolve(context.Background(), aQuestion(t, "www.example.test"))
+
+	var resolutionErr *ResolutionError
+	if !errors.As(err, &resolutionErr) || resolutionErr.Type != types.RCODE_SERVER_FAILURE {
+		t.Fatalf("Resolve() error = %v, expected a server failure", err)
+	}
+
+	// A fresh question gets a fresh budget; the address is cached by now
+	if _, err := r.Resolve(context.Background(), aQuestion(t, "www.example.test")); err != nil {
+		t.Errorf("Resolve() with the server address cached returned error: %v", err)
+	}
+}
+
+func TestRecursiveResolver_Failures(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxHops  int
+		handlers func(t *testing.T) []func(message.DNSQuestion) *message.ResponseBuilder
+	}{
+		{
+			name:    "referral loop",
+			maxHops: DefaultMaxHops,
+			handlers: func(t *testing.T) []func(message.DNSQuestion) *message.ResponseBuilder {
+				refer := func(q message.DNSQuestion) *message.ResponseBuilder {
+					return referralTo(t, q, "test.", "ns.nic.test.", net.IPv4(127, 0, 0, 2))
+				}
+				return []func(message.DNSQuestion) *message.ResponseBuilder{refer, refer}
+			},
+		},
+		{
+			name:    "upward referral",
+			maxHops: DefaultMaxHops,
+			handlers: func(t *testing.T) []func(message.DNSQuestion) *message.ResponseBuilder {
+				return []func(message.DNSQuestion) *message.ResponseBuilder{
+					func(q message.DNSQuestion) *message.ResponseBuilder {
+						return referralTo(t, q, "example.test.", "ns.example.test.", net.IPv4(127, 0, 0, 2))
+					},
+					func(q message.DNSQuestion) *message.ResponseBuilder {
+						return referralTo(t, q, "test.", "ns.nic.test.", net.IPv4(127, 0, 0, 1))
+					},
+				}
+			},
+		},
+		{
+			name:    "hop limit",
+			maxHops: 1,
+			handlers: func(t *testing.T) []func(message.DNSQuestion) *message.ResponseBuilder {
+				return []func(message.DNSQuestion) *message.ResponseBuilder{
+					func(q message.DNSQuestion) *message.ResponseBuilder {
+						return referralTo(t, q, "test.", "ns.nic.test.", net.IPv4(127, 0, 0, 2))
+					},
+					func(q message.DNSQuestion) *message.ResponseBuilder {
+						return answerWith(t, q, net.IPv4(192, 0, 2, 30))
+					},
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, port := startNameServers(t, tt.handlers(t)...)
+			r := newTestRecursiveResolver(t, port, tt.maxHops)
+
+			_, err := r.Resolve(context.Background(), aQuestion(t, "www.example.test"))
+
+			var resolutionErr *ResolutionError
+			if !errors.As(err, &resolutionErr) || resolutionErr.Type != types.RCODE_SERVER_FAILURE {
+				t.Fatalf("Resolve() error = %v, expected a server failure", err)
+			}
+		})
+	}
+}
+
+// questionLog records the questions fake name servers were asked
+type questionLog struct {
+	mu    sync.Mutex
+	asked []string
+}
+
+// record wraps handler to log each question as its name and type
+func (l *questionLog) record(handler func(message.DNSQuestion) *message.ResponseBuilder) func(message.DNSQuestion) *message.ResponseBuilder {
+	return func(q message.DNSQuestion) *message.ResponseBuilder {
+		questionType := q.Qtype()
+		l.mu.Lock()
+		l.asked = append(l.asked, q.Name.String()+" "+questionType.String())
+		l.mu.Unlock()
+		return handler(q)
+	}
+}
+
+// questions returns the logged questions
+func (l *questionLog) questions() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.asked...)
+}
+
+func TestRecursiveResolver_QNameMinimization(t *testing.T) {
+	tldAddress := net.IPv4(127, 0, 0, 2)
+	authAddress := net.IPv4(127, 0, 0, 3)
+
+	var asked questionLog
+	_, port := startNameServers(t,
+		asked.record(func(q message.DNSQuestion) *message.ResponseBuilder {
+			return referralTo(t, q, "test.", "ns.nic.test.", tldAddress)
+		}),
+		asked.record(func(q message.DNSQuestion) *message.ResponseBuilder {
+			return referralTo(t, q, "example.test.", "ns1.example.test.", authAddress)
+		}),
+		asked.record(func(q message.DNSQuestion) *message.ResponseBuilder {
+			// sub.example.test is an empty non-terminal of the zone
+			if q.Name.Equal(mustDomainName(t, "sub.example.test")) {
+				return message.NewResponse(0).
+					WithFlags(types.FLAG_QR_RESPONSE | types.FLAG_AA_AUTHORITATIVE).
+					AddQuestion(q)
+			}
+			return answerWith(t, q, net.IPv4(192, 0, 2, 50))
+		}),
+	)
+
+	r := newTestRecursiveResolver(t, port, DefaultMaxHops)
+	r.SetQNameMinimization(true)
+
+	answers, err := r.Resolve(context.Background(), aQuestion(t, "www.sub.example.test"))
+	if err != nil {
+		t.Fatalf("Resolve() returned error: %v", err)
+	}
+	if len(answers) != 1 || !net.IP(answers[0].Data()).Equal(net.IPv4(192, 0, 2, 50)) {
+		t.Fatalf("Resolve() = %v, expected a single A record for 192.0.2.50", answers)
+	}
+
+	expected := []string{"test. NS", "example.test. NS", "sub.example.test. NS", "www.sub.example.test. A"}
+	if got := asked.questions(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("servers were asked %v, expected %v", got, expected)
+	}
+	if stats := r.GetQNameMinimizationStats(); stats.Minimized != 3 || stats.FellBack != 0 {
+		t.Errorf("GetQNameMinimizationStats() = %+v, expected 3 minimized queries", stats)
+	}
+}
+
+func TestRecursiveResolver_QNameMinimizationFallback(t *testing.T) {
+	tldAddress := net.IPv4(127, 0, 0, 2)
+
+	var asked questionLog
+	_, port := startNameServers(t,
+		asked.record(func(q message.DNSQuestion) *message.ResponseBuilder {
+			return referralTo(t, q, "test.", "ns.nic.test.", tldAddress)
+		}),
+		asked.record(func(q message.DNSQuestion) *message.ResponseBuilder {
+			// A server denying the names above the ones it holds
+			if !q.Name.Equal(mustDomainName(t, "www.example.test")) {
+				return message.NewResponse(0).
+					WithFlags(types.FLAG_QR_RESPONSE | types.FLAG_AA_AUTHORITATIVE).
+					AddQuestion(q).
+					SetRcode(types.RCODE_NAME_ERROR)
+			}
+			return answerWith(t, q, net.IPv4(192, 0, 2, 60))
+		}),
+	)
+
+	r := newTestRecursiveResolver(t, port, DefaultMaxHops)
+	r.SetQNameMinimization(true)
+
+	answers, err := r.Resolve(context.Background(), aQuestion(t, "www.example.test"))
+	if err != nil {
+		t.Fatalf("Resolve() returned error: %v", err)
+	}
+	if len(answers) != 1 || !net.IP(answers[0].Data()).Equal(net.IPv4(192, 0, 2, 60)) {
+		t.Fatalf("Resolve() = %v, expected a single A record for 192.0.2.60", answers)
+	}
+
+	expected := []string{"test. NS", "example.test. NS", "www.example.test. A"}
+	if got := asked.questions(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("servers were asked %v, expected %v", got, expected)
+	}
+	if stats := r.GetQNameMinimizationStats(); stats.Minimized != 1 || stats.FellBack != 1 {
+		t.Errorf("GetQNameMinimizationStats() = %+v, expected 1 minimized query and 1 fallback", stats)
+	}
+}
+
+// deniedBy denies question authoritatively with the SOA of zone
+func deniedBy(t *testing.T, question message.DNSQuestion, zone string) *message.ResponseBuilder {
+	t.Helper()
+
+	soa := records.NewSOARecord(zone, "ns1."+zone, "hostmaster."+zone,
+		1, time.Hour, 15*time.Minute, 7*24*time.Hour, 5*time.Minute, 300)
+	return message.NewResponse(0).
+		WithFlags(types.FLAG_QR_RESPONSE | types.FLAG_AA_AUTHORITATIVE).
+		AddQuestion(question).
+		AddAuthority(*message.NewDNSAnswerFromParts(mustDomainName(t, zone), types.TYPE_SOA, types.CLASS_IN, 300, soa.Data())).
+		SetRcode(types.RCODE_NAME_ERROR)
+}
+
+func TestRecursiveResolver_QNameMinimizationStopsAtDenial(t *testing.T) {
+	tldAddress := net.IPv4(127, 0, 0, 2)
+
+	var asked questionLog
+	_, port := startNameServers(t,
+		asked.record(func(q message.DNSQuestion) *message.ResponseBuilder {
+			return referralTo(t, q, "test.", "ns.nic.test.", tldAddress)
+		}),
+		asked.record(func(q message.DNSQuestion) *message.ResponseBuilder {
+			return deniedBy(t, q, "test.")
+		}),
+	)
+
+	r := newTestRecursiveResolver(t, port, DefaultMaxHops)
+	r.SetQNameMinimization(true)
+
+	_, err := r.Resolve(context.Background(), aQuestion(t, "www.sub.missing.test"))
+	var resolutionErr *ResolutionError
+	if !errors.As(err, &resolutionErr) || resolutionErr.Type != types.RCODE_NAME_ERROR {
+		t.Fatalf("Resolve() returned error %v, expected NXDOMAIN", err)
+	}
+
+	// Nothing exists below a denied name (RFC 8020)
+	expected := []string{"test. NS", "missing.test. NS"}
+	if got := asked.questions(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("servers were asked %v, expected %v", got, expected)
+	}
+	if stats := r.GetQNameMinimizationStats(); stats.Minimized != 2 || stats.FellBack != 0 {
+		t.Errorf("GetQNameMinimizationStats() = %+v, expected 2 minimized queries", stats)
+	}
+}
+
+func TestRecursiveResolver_QNameMinimizationFallbackOnErrors(t *testing.T) {
+	for _, rcode := range []types.DNSRCode{types.RCODE_SERVER_FAILURE, types.RCODE_REFUSED, types.RCODE_FORMAT_ERROR, types.RCODE_NOT_IMPLEMENTED} {
+		t.Run(rcode.String(), func(t *testing.T) {
+			tldAddress := net.IPv4(127, 0, 0, 2)
+
+			var asked questionLog
+			_, port := startNameServers(t,
+				asked.record(func(q message.DNSQuestion) *message.ResponseBuilder {
+					return referralTo(t, q, "test.", "ns.nic.test.", tldAddress)
+				}),
+				asked.record(func(q message.DNSQuestion) *message.ResponseBuilder {
+					// A server failing the NS queries it does not expect
+					if q.Qtype() == types.TYPE_NS {
+						return message.NewResponse(0).
+							WithFlags(types.FLAG_QR_RESPONSE).
+							AddQuestion(q).
+							SetRcode(rcode)
+					}
+					return answerWith(t, q, net.IPv4(192, 0, 2, 70))
+				}),
+			)
+
+			r := newTestRecursiveResolver(t, port, DefaultMaxHops)
+			r.SetQNameMinimization(true)
+
+			answers, err := r.Resolve(context.Background(), aQuestion(t, "www.example.test"))
+			if err != nil {
+				t.Fatalf("Resolve() returned error: %v", err)
+			}
+			if len(answers) != 1 || !net.IP(answers[0].Data()).Equal(net.IPv4(192, 0, 2, 70)) {
+				t.Fatalf("Resolve() = %v, expected a single A record for 192.0.2.70", answers)
+			}
+
+			expected := []string{"test. NS", "example.test. NS", "www.example.test. A"}
+			if got := asked.questions(); !reflect.DeepEqual(got, expected) {
+				t.Errorf("servers were asked %v, expected %v", got, expected)
+			}
+			if stats := r.GetQNameMinimizationStats(); stats.Minimized != 1 || stats.FellBack != 1 {
+				t.Errorf("GetQNameMinimizationStats() = %+v, expected 1 minimized query and 1 fallback", stats)
+			}
+		})
+	}
+}
+
+func TestNewRecursiveResolver_QNameMinimization(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		r, err := NewRecursiveResolver(&ResolverConfig{QNameMinimization: enabled})
+		if err != nil {
+			t.Fatalf("NewRecursiveResolver() returned error: %v", err)
+		}
+		if got := r.qnameMinimization.Load(); got != enabled {
+			t.Errorf("QNameMinimization %v: minimization enabled = %v", enabled, got)
+		}
+	}
+}
+
+func TestParseRootHints(t *testing.T) {
+	servers, err := parseRootHints(rootHints, "53")
+	if err != nil {
+		t.Fatalf("parseRootHints() returned error: %v", err)
+	}
+
+	if len(servers) != 26 {
+		t.Fatalf("parseRootHints() returned %d servers, expected 26", len(servers))
+	}
+	if servers[0] != "198.41.0.4:53" {
+		t.Errorf("first root server = %s, expected 198.41.0.4:53", servers[0])
+	}
+	if servers[25] != "[2001:dc3::35]:53" {
+		t.Errorf("last root server = %s, expected [2001:dc3::35]:53", servers[25])
+	}
+
+	if _, err := parseRootHints([]byte("; no records\n"), "53"); err == nil {
+		t.Error("parseRootHints() expected error for hints without addresses")
+	}
+}
@@ -2,13 +2,19 @@ package resolver
 
 import (
 	"context"
-	"net"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/vadim-su/dnska/internal/logging"
 	"github.com/vadim-su/dnska/pkg/dns/message"
 	"github.com/vadim-su/dnska/pkg/dns/types"
 )
 
+// logger is the logger of the resolver component
+var logger = logging.For("resolver")
+
 // Resolver defines the interface for DNS resolution strategies
 type Resolver interface {
 	// Resolve performs DNS resolution for the given question
@@ -31,15 +37,31 @@ type ResolutionResult struct {
 
 // ResolverConfig holds configuration for resolvers
 type ResolverConfig struct {
-	Timeout        time.Duration // Timeout for resolution attempts
-	MaxRetries     int           // Maximum number of retries
-	CacheEnabled   bool          // Whether caching is enabled
-	CacheTTL       time.Duration // Default TTL for cached records
-	ForwardServers []string      // List of forward DNS servers
-	RootServers    []string      // List of root DNS servers
-	RecursionDepth int           // Maximum recursion depth
+	Timeout           time.Duration // Timeout for resolution attempts
+	MaxRetries        int           // Maximum number of retries
+	CacheEnabled      bool          // Whether caching is enabled
+	CacheTTL          time.Duration // Default TTL for cached records
+	ForwardServers    []string      // List of forward DNS servers
+	RootServers       []string      // List of root DNS servers
+	RecursionDepth    int           // Maximum recursion depth
+	MaxHops           int           // Maximum referrals followed for one name
+	MaxQueries        int           // Maximum name server queries sent for one question
+	CaseRandomization bool          // Randomize upstream query name case (DNS 0x20)
+	QNameMinimization bool          // Send name servers only the labels they need (RFC 7816)
+
+	CircuitBreaker CircuitBreakerConfig // Fails forwarded queries fast while the forward servers keep failing
+	UpstreamHealth UpstreamHealthConfig // Skips forward servers that keep failing
 }
 
+// DefaultMaxHops is the number of referrals a recursive resolution follows
+// when ResolverConfig.MaxHops is not set
+const DefaultMaxHops = 20
+
+// DefaultMaxQueries is the number of name server queries a recursive
+// resolution sends, including those for name server addresses and CNAME
+// targets, when ResolverConfig.MaxQueries is not set
+const DefaultMaxQueries = 100
+
 // DefaultResolverConfig returns a default resolver configuration
 func DefaultResolverConfig() *ResolverConfig {
 	return &ResolverConfig{
@@ -50,42 +72,84 @@ func DefaultResolverConfig() *ResolverConfig {
 		ForwardServers: []string{"8.8.8.8:53", "8.8.4.4:53"}, // Google DNS
 		RootServers:    []string{},
 		RecursionDepth: 10,
+		MaxHops:        DefaultMaxHops,
+		MaxQueries:     DefaultMaxQueries,
 	}
 }
 
-// RecursiveResolver implements recursive DNS resolution
+// RecursiveResolver implements iterative DNS resolution without a forwarder.
+// Resolution starts at the root servers and follows referrals down to the
+// authoritative servers of a name. The name servers and addresses learnt from
+// referrals are cached until their TTL expires
 type RecursiveResolver struct {
 	config     *ResolverConfig
-	rootServer string
-	client     *net.UDPConn
+	roots      []string // Root server addresses
+	port       string   // Port name servers from referrals are queried on
+	maxHops    int
+	maxQueries int
+
+	mu          sync.Mutex           // Guards delegations and addresses
+	delegations map[string]cacheItem // Name server names by zone
+	addresses   map[string]cacheItem // IP addresses by name server name
+
+	qnameMinimization     atomic.Bool   // Send servers only the labels they need (RFC 7816)
+	minimized             atomic.Uint64 // Minimized queries answered
+	minimizationFallbacks atomic.Uint64 // Minimized queries denied or failed and asked again with the full name
 }
 
-// NewRecursiveResolver creates a new recursive resolver
+// cacheItem holds values of cached records with the time they expire
+type cacheItem struct {
+	values    []string
+	expiresAt time.Time
+}
+
+// NewRecursiveResolver creates a new recursive resolver. Without configured
+// root servers the bundled IANA root hints are used
 func NewRecursiveResolver(config *ResolverConfig) (*RecursiveResolver, error) {
 	if config == nil {
 		config = DefaultResolverConfig()
 	}
 
-	// Create UDP connection for DNS queries
-	conn, err := net.ListenUDP("udp", nil)
-	if err != nil {
-		return nil, err
+	roots := config.RootServers
+	if len(roots) == 0 {
+		var err error
+		if roots, err = parseRootHints(rootHints, "53"); err != nil {
+			return nil, fmt.Errorf("failed to load root hints: %w", err)
+		}
+	}
+
+	maxHops := config.MaxHops
+	if maxHops <= 0 {
+		maxHops = DefaultMaxHops
+	}
+
+	maxQueries := config.MaxQueries
+	if maxQueries <= 0 {
+		maxQueries = DefaultMaxQueries
 	}
 
 	resolver := &RecursiveResolver{
-		config:     config,
-		rootServer: config.RootServers[0], // Use first root server
-		client:     conn,
+		config:      config,
+		roots:       roots,
+		port:        "53",
+		maxHops:     maxHops,
+		maxQueries:  maxQueries,
+		delegations: make(map[string]cacheItem),
+		addresses:   make(map[string]cacheItem),
 	}
+	resolver.qnameMinimization.Store(config.QNameMinimization)
 
 	return resolver, nil
 }
 
-// ForwardResolver implements forwarding DNS resolution
+// ForwardResolver implements forwarding DNS resolution.
+// Every upstream query uses its own socket so that concurrent queries and
+// cancellations do not interfere with each other
 type ForwardResolver struct {
 	config  *ResolverConfig
 	servers []string
-	client  *net.UDPConn
+	breaker *circuitBreaker // Nil when disabled
+	health  *upstreamHealth // Nil when disabled
 }
 
 // NewForwardResolver creates a new forward resolver
@@ -94,16 +158,12 @@ func NewForwardResolver(config *ResolverConfig) (*ForwardResolver, error) {
 		config = DefaultResolverConfig()
 	}
 
-	conn, err := net.ListenUDP("udp", nil)
-	if err != nil {
-		return nil, err
-	}
-
 	resolver := &ForwardResolver{
 		config:  config,
 		servers: config.ForwardServers,
-		client:  conn,
+		breaker: newCircuitBreaker(config.CircuitBreaker),
 	}
+	resolver.health = newUpstreamHealth(config.UpstreamHealth, config.ForwardServers, resolver.probeUpstream)
 
 	return resolver, nil
 }
@@ -111,13 +171,16 @@ func NewForwardResolver(config *ResolverConfig) (*ForwardResolver, error) {
 // CacheResolver implements caching DNS resolution
 type CacheResolver struct {
 	config   *ResolverConfig
+	mu       sync.Mutex // Guards cache
 	cache    map[string]*CacheEntry
 	resolver Resolver // Underlying resolver to use when cache misses
 }
 
 // CacheEntry represents a cached DNS resolution result
 type CacheEntry struct {
+	Question  message.DNSQuestion
 	Answers   []message.DNSAnswer
+	StoredAt  time.Time
 	ExpiresAt time.Time
 }
 
@@ -130,6 +193,38 @@ func NewCacheResolver(config *ResolverConfig, underlying Resolver) *CacheResolve
 	}
 }
 
+// SingleflightResolver shares one resolution between concurrent identical
+// questions. The first question starts resolving through the underlying
+// resolver, questions asked while it is in flight wait for its result
+type SingleflightResolver struct {
+	config    *ResolverConfig
+	mu        sync.Mutex // Guards flights
+	flights   map[string]*flight
+	resolver  Resolver
+	coalesced atomic.Uint64 // Callers that joined a flight instead of starting one
+}
+
+// flight is a resolution shared by the callers asking the same question
+type flight struct {
+	done    chan struct{} // Closed once answers and err are set
+	answers []message.DNSAnswer
+	err     error
+}
+
+// NewSingleflightResolver creates a resolver deduplicating the questions
+// passed to underlying
+func NewSingleflightResolver(config *ResolverConfig, underlying Resolver) *SingleflightResolver {
+	if config == nil {
+		config = DefaultResolverConfig()
+	}
+
+	return &SingleflightResolver{
+		config:   config,
+		flights:  make(map[string]*flight),
+		resolver: underlying,
+	}
+}
+
 // ResolutionError represents an error during DNS resolution
 type ResolutionError struct {
 	Type    types.DNSRCode
@@ -145,6 +240,11 @@ func (e *ResolutionError) Error() string {
 	return e.Message
 }
 
+// Unwrap returns the cause of the error
+func (e *ResolutionError) Unwrap() error {
+	return e.Cause
+}
+
 // NewResolutionError creates a new resolution error
 func NewResolutionError(rcode types.DNSRCode, message string, cause error) *ResolutionError {
 	return &ResolutionError{
@@ -0,0 +1,126 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"slices"
+
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// Resolve joins the resolution of question in flight, starting one when
+// there is none. Every caller stops waiting when its own context is done.
+// Questions asked for a client subnet are resolved on their own
+func (r *SingleflightResolver) Resolve(ctx context.Context, question message.DNSQuestion) ([]message.DNSAnswer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if ClientSubnet(ctx) != nil {
+		return r.resolver.Resolve(ctx, question)
+	}
+
+	key := flightKey(question)
+
+	r.mu.Lock()
+	f, ok := r.flights[key]
+	if ok {
+		r.coalesced.Add(1)
+	} else {
+		f = &flight{done: make(chan struct{})}
+		r.flights[key] = f
+		go r.fly(ctx, key, question, f)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-f.done:
+		if f.err != nil {
+			return nil, f.err
+		}
+		// Callers own their answers, e.g. to rewrite TTLs
+		return slices.Clone(f.answers), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// StampedesPrevented returns the number of callers that joined a resolution
+// in flight rather than sending their own query upstream, such as the
+// callers missing the cache together when a popular entry expires
+func (r *SingleflightResolver) StampedesPrevented() uint64 {
+	return r.coalesced.Load()
+}
+
+// DeduplicatedQueries returns the number of questions r answered by joining
+// an identical resolution in flight, saving an upstream query each, and
+// whether r deduplicates questions at all. A cache in front of the
+// deduplicating resolver is looked through
+func DeduplicatedQueries(r Resolver) (uint64, bool) {
+	if cache, ok := r.(*CacheResolver); ok {
+		r = cache.resolver
+	}
+	flights, ok := r.(*SingleflightResolver)
+	if !ok {
+		return 0, false
+	}
+	return flights.StampedesPrevented(), true
+}
+
+// ResolveAll performs DNS resolution for multiple questions
+func (r *SingleflightResolver) ResolveAll(ctx context.Context, questions []message.DNSQuestion) ([]message.DNSAnswer, error) {
+	var allAnswers []message.DNSAnswer
+
+	for _, question := range questions {
+		answers, err := r.Resolve(ctx, question)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve question %v: %w", question, err)
+		}
+		allAnswers = append(allAnswers, answers...)
+	}
+
+	return allAnswers, nil
+}
+
+// Close closes the underlying resolver
+func (r *SingleflightResolver) Close() error {
+	if r.resolver != nil {
+		return r.resolver.Close()
+	}
+	return nil
+}
+
+// fly resolves question for the callers waiting on f. The resolution is not
+// canceled with the caller that started it, as others may still wait for
+// it, but is bounded by the resolver timeout. A panic of the underlying
+// resolver fails the flight instead of the server
+func (r *SingleflightResolver) fly(ctx context.Context, key string, question message.DNSQuestion, f *flight) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			logger.Error("Recovered from panic resolving question", "qname", question.Name.String(), "panic", recovered, "stack", string(debug.Stack()))
+			f.answers = nil
+			f.err = NewResolutionError(types.RCODE_SERVER_FAILURE, "resolver panicked", fmt.Errorf("%v", recovered))
+		}
+
+		r.mu.Lock()
+		delete(r.flights, key)
+		r.mu.Unlock()
+		close(f.done)
+	}()
+
+	ctx = context.WithoutCancel(ctx)
+	if r.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.config.Timeout)
+		defer cancel()
+	}
+
+	f.answers, f.err = r.resolver.Resolve(ctx, question)
+}
+
+// flightKey identifies identical questions. The name keeps its case, as the
+// owner names of answers follow the case of the question
+func flightKey(question message.DNSQuestion) string {
+	return fmt.Sprintf("%s|%d|%d", question.Name.String(), question.Qtype(), question.Qclass())
+}
@@ -0,0 +1,294 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/message"
+)
+
+// blockingResolver answers once release is closed, counting its calls
+type blockingResolver struct {
+	release chan struct{}
+	calls   atomic.Int32
+	resolve func() ([]message.DNSAnswer, error)
+}
+
+func (b *blockingResolver) Resolve(ctx context.Context, question message.DNSQuestion) ([]message.DNSAnswer, error) {
+	b.calls.Add(1)
+	<-b.release
+	return b.resolve()
+}
+
+func (b *blockingResolver) ResolveAll(ctx context.Context, questions []message.DNSQuestion) ([]message.DNSAnswer, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (b *blockingResolver) Close() error {
+	return nil
+}
+
+func TestSingleflightResolver_SharesUpstreamQuery(t *testing.T) {
+	upstream, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start fake upstream: %v", err)
+	}
+	defer upstream.Close()
+
+	// Slow upstream counting the queries it receives
+	var packets atomic.Int32
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := upstream.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			packets.Add(1)
+
+			query, err := message.NewDNSResponse(buf[:n])
+			if err != nil || len(query.Questions) != 1 {
+				continue
+			}
+			time.Sleep(200 * time.Millisecond)
+			upstream.WriteTo(buildUpstreamReply(t, query.Header.ID, query.Questions[0], net.IPv4(192, 0, 2, 1)), addr)
+		}
+	}()
+
+	config := DefaultResolverConfig()
+	config.ForwardServers = []string{upstream.LocalAddr().String()}
+	config.Timeout = 2 * time.Second
+
+	forwarder, err := NewForwardResolver(config)
+	if err != nil {
+		t.Fatalf("failed to create forward resolver: %v", err)
+	}
+	r := NewSingleflightResolver(config, forwarder)
+	question := createQuestion(t, "www.example.com")
+
+	const clients = 20
+	results := make([][]message.DNSAnswer, clients)
+	errs := make([]error, clients)
+
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = r.Resolve(context.Background(), question)
+		}()
+	}
+	wg.Wait()
+
+	if got := packets.Load(); got != 1 {
+		t.Errorf("upstream received %d queries, expected 1", got)
+	}
+	for i := range clients {
+		if errs[i] != nil {
+			t.Fatalf("client %d: Resolve() returned error: %v", i, errs[i])
+		}
+		if len(results[i]) != 1 || !net.IP(results[i][0].Data()).Equal(net.IPv4(192, 0, 2, 1)) {
+			t.Fatalf("client %d: unexpected answers %v", i, results[i])
+		}
+	}
+
+	// Every client owns its copy of the answers
+	results[0][0].SetTTL(1)
+	if results[1][0].TTL() == 1 {
+		t.Error("clients share the backing array of their answers")
+	}
+}
+
+func TestSingleflightResolver_Failures(t *testing.T) {
+	upstreamErr := errors.New("upstream failed")
+
+	tests := []struct {
+		name    string
+		resolve func() ([]message.DNSAnswer, error)
+	}{
+		{name: "error", resolve: func() ([]message.DNSAnswer, error) { return nil, upstreamErr }},
+		{name: "panic", resolve: func() ([]message.DNSAnswer, error) { panic("broken resolver") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			underlying := &blockingResolver{release: make(chan struct{}), resolve: tt.resolve}
+			r := NewSingleflightResolver(DefaultResolverConfig(), underlying)
+			question := createQuestion(t, "www.example.com")
+
+			const clients = 5
+			errs := make(chan error, clients)
+			for range clients {
+				go func() {
+					_, err := r.Resolve(context.Background(), question)
+					errs <- err
+				}()
+			}
+
+			// Let every client join the flight before it fails
+			deadline := time.Now().Add(time.Second)
+			for underlying.calls.Load() == 0 && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			time.Sleep(50 * time.Millisecond)
+			close(underlying.release)
+
+			for range clients {
+				if err := <-errs; err == nil {
+					t.Error("Resolve() returned no error")
+				}
+			}
+			if calls := underlying.calls.Load(); calls != 1 {
+				t.Errorf("underlying resolver called %d times, expected 1", calls)
+			}
+
+			r.mu.Lock()
+			inFlight := len(r.flights)
+			r.mu.Unlock()
+			if inFlight != 0 {
+				t.Errorf("%d flights left in flight", inFlight)
+			}
+		})
+	}
+}
+
+func TestSingleflightResolver_CallerContext(t *testing.T) {
+	underlying := &blockingResolver{
+		release: make(chan struct{}),
+		resolve: func() ([]message.DNSAnswer, error) {
+			return []message.DNSAnswer{createTestAnswer()}, nil
+		},
+	}
+	r := NewSingleflightResolver(DefaultResolverConfig(), underlying)
+	question := createTestQuestion()
+
+	// The caller starting the flight gives up, a later caller gets the answer
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := r.Resolve(ctx, question); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Resolve() error = %v, expected a deadline error", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		answers, err := r.Resolve(context.Background(), question)
+		if err == nil && len(answers) != 1 {
+			err = errors.New("expected 1 answer")
+		}
+		done <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	close(underlying.release)
+
+	if err := <-done; err != nil {
+		t.Errorf("Resolve() returned error: %v", err)
+	}
+	if calls := underlying.calls.Load(); calls != 1 {
+		t.Errorf("underlying resolver called %d times, expected 1", calls)
+	}
+}
+
+func TestSingleflightResolver_ExpiredCacheEntry(t *testing.T) {
+	underlying := &blockingResolver{
+		release: make(chan struct{}),
+		resolve: func() ([]message.DNSAnswer, error) {
+			return []message.DNSAnswer{createTestAnswer()}, nil
+		},
+	}
+	flights := NewSingleflightResolver(DefaultResolverConfig(), underlying)
+	cache := NewCacheResolver(DefaultResolverConfig(), flights)
+	question := createTestQuestion()
+
+	// An entry that has just expired
+	cache.putInCache(cache.generateCacheKey(question), question, []message.DNSAnswer{createTestAnswer()})
+	for _, entry := range cache.cache {
+		entry.ExpiresAt = time.Now().Add(-time.Second)
+	}
+
+	const clients = 50
+	errs := make(chan error, clients)
+	for range clients {
+		go func() {
+			answers, err := cache.Resolve(context.Background(), question)
+			if err == nil && len(answers) != 1 {
+				err = errors.New("expected 1 answer")
+			}
+			errs <- err
+		}()
+	}
+
+	// Hold the refresh until every client has missed the cache
+	deadline := time.Now().Add(time.Second)
+	for flights.StampedesPrevented() < clients-1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(underlying.release)
+
+	for range clients {
+		if err := <-errs; err != nil {
+			t.Errorf("Resolve() returned error: %v", err)
+		}
+	}
+	if calls := underlying.calls.Load(); calls != 1 {
+		t.Errorf("underlying resolver called %d times, expected 1", calls)
+	}
+	if prevented := flights.StampedesPrevented(); prevented != clients-1 {
+		t.Errorf("StampedesPrevented() = %d, expected %d", prevented, clients-1)
+	}
+}
+
+func TestDeduplicatedQueries(t *testing.T) {
+	flights := NewSingleflightResolver(DefaultResolverConfig(), &blockingResolver{})
+	flights.coalesced.Store(3)
+
+	if count, ok := DeduplicatedQueries(NewCacheResolver(DefaultResolverConfig(), flights)); !ok || count != 3 {
+		t.Errorf("DeduplicatedQueries(cache) = %d, %v, expected 3, true", count, ok)
+	}
+	if _, ok := DeduplicatedQueries(NewCacheResolver(DefaultResolverConfig(), &blockingResolver{})); ok {
+		t.Error("DeduplicatedQueries() reported a resolver that does not deduplicate")
+	}
+}
+
+func BenchmarkSingleflightResolver_Concurrent(b *testing.B) {
+	const clients = 100
+	question := createTestQuestion()
+
+	for b.Loop() {
+		underlying := &blockingResolver{
+			release: make(chan struct{}),
+			resolve: func() ([]message.DNSAnswer, error) {
+				return []message.DNSAnswer{createTestAnswer()}, nil
+			},
+		}
+		flights := NewSingleflightResolver(DefaultResolverConfig(), underlying)
+
+		var wg sync.WaitGroup
+		for range clients {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := flights.Resolve(context.Background(), question); err != nil {
+					b.Errorf("Resolve() returned error: %v", err)
+				}
+			}()
+		}
+
+		// Hold the upstream query until every client has asked
+		for flights.StampedesPrevented() < clients-1 {
+			runtime.Gosched()
+		}
+		close(underlying.release)
+		wg.Wait()
+
+		if calls := underlying.calls.Load(); calls != 1 {
+			b.Fatalf("underlying resolver called %d times, expected 1", calls)
+		}
+	}
+}
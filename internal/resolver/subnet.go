@@ -0,0 +1,26 @@
+package resolver
+
+import (
+	"context"
+
+	"github.com/vadim-su/dnska/pkg/dns/edns"
+)
+
+// clientSubnetKey is the context key of the client subnet (ECS, RFC 7871)
+// forward resolvers send upstream
+type clientSubnetKey struct{}
+
+// WithClientSubnet returns a context under which forward resolvers send
+// subnet upstream with their queries and set its scope prefix length to that
+// of the upstream answer. Answers for a client subnet bypass the in-flight
+// deduplication and are only cached when their scope is zero, valid for
+// every client
+func WithClientSubnet(ctx context.Context, subnet *edns.ClientSubnet) context.Context {
+	return context.WithValue(ctx, clientSubnetKey{}, subnet)
+}
+
+// ClientSubnet returns the client subnet of ctx, or nil
+func ClientSubnet(ctx context.Context) *edns.ClientSubnet {
+	subnet, _ := ctx.Value(clientSubnetKey{}).(*edns.ClientSubnet)
+	return subnet
+}
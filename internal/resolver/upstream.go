@@ -0,0 +1,207 @@
+package resolver
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// upstreamWindowSize is the number of recent queries the success and
+// failure counts of a forward server cover
+const upstreamWindowSize = 100
+
+// UpstreamState is the health state of a forward server
+type UpstreamState int
+
+const (
+	UpstreamUp   UpstreamState = 0 // Queried in the configured order
+	UpstreamDown UpstreamState = 1 // Skipped while other servers are up
+)
+
+// String returns the name of the state
+func (s UpstreamState) String() string {
+	switch s {
+	case UpstreamUp:
+		return "up"
+	case UpstreamDown:
+		return "down"
+	default:
+		return "unknown"
+	}
+}
+
+// UpstreamHealthConfig holds the thresholds of the health tracking of the
+// forward servers of a forward resolver
+type UpstreamHealthConfig struct {
+	FailureThreshold int           // Consecutive failures marking a server down, 0 disables tracking
+	Cooldown         time.Duration // Time a down server is skipped before queries try it again
+	ProbeInterval    time.Duration // Time between probe queries of a down server, 0 disables probing
+	ProbeName        string        // Name whose NS records probes ask for, the root when empty
+}
+
+// UpstreamStatus is the health of a forward server
+type UpstreamStatus struct {
+	Server              string
+	State               UpstreamState
+	ConsecutiveFailures int
+	RecentSuccesses     int       // Successful queries among the last upstreamWindowSize
+	RecentFailures      int       // Failed queries among the last upstreamWindowSize
+	DownSince           time.Time // Zero while the server is up
+}
+
+// upstreamHealth tracks the outcome of the queries sent to each forward
+// server. FailureThreshold consecutive failures mark a server down: it is
+// skipped for Cooldown unless every server is down, and probed every
+// ProbeInterval meanwhile. Any successful query or probe marks it up again
+type upstreamHealth struct {
+	config UpstreamHealthConfig
+	now    func() time.Time
+	probe  func(ctx context.Context, server string) error
+
+	mu        sync.Mutex // Guards upstreams
+	upstreams map[string]*upstream
+}
+
+// upstream is the health of one forward server
+type upstream struct {
+	down        bool
+	downSince   time.Time
+	lastProbe   time.Time
+	probing     bool // A probe query is in flight
+	consecutive int  // Failures since the last success
+
+	outcomes [upstreamWindowSize]bool // Ring of recent outcomes, true for success
+	next     int                      // Position of the next outcome in the ring
+	count    int                      // Outcomes in the ring
+}
+
+// newUpstreamHealth returns a tracker of servers, all of them up, or nil
+// when config disables tracking. Down servers are probed with probe
+func newUpstreamHealth(config UpstreamHealthConfig, servers []string, probe func(ctx context.Context, server string) error) *upstreamHealth {
+	if config.FailureThreshold <= 0 {
+		return nil
+	}
+
+	h := &upstreamHealth{
+		config:    config,
+		now:       time.Now,
+		probe:     probe,
+		upstreams: make(map[string]*upstream, len(servers)),
+	}
+	for _, server := range servers {
+		h.upstreams[server] = &upstream{}
+	}
+	return h
+}
+
+// order returns the servers a query should try, in the configured order:
+// those that are up or whose cooldown has passed, or every server when
+// there are none. Probes of the down servers that are due are started
+func (h *upstreamHealth) order(servers []string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	available := make([]string, 0, len(servers))
+	for _, server := range servers {
+		u := h.upstreams[server]
+		if !u.down || now.Sub(u.downSince) >= h.config.Cooldown {
+			available = append(available, server)
+		}
+		if u.down && !u.probing && h.config.ProbeInterval > 0 && now.Sub(u.lastProbe) >= h.config.ProbeInterval {
+			u.probing = true
+			u.lastProbe = now
+			go h.runProbe(server)
+		}
+	}
+
+	if len(available) == 0 {
+		return servers
+	}
+	return available
+}
+
+// runProbe queries a down server, marking it up when it answers
+func (h *upstreamHealth) runProbe(server string) {
+	err := h.probe(context.Background(), server)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	u := h.upstreams[server]
+	u.probing = false
+	if err == nil && u.down {
+		h.markUp(server, u, "probe succeeded")
+	}
+}
+
+// record updates the health of server with the outcome of a query. A query
+// abandoned by its caller counts as neither success nor failure
+func (h *upstreamHealth) record(ctx context.Context, server string, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	u := h.upstreams[server]
+	success := !isUpstreamFailure(err)
+	u.outcomes[u.next] = success
+	u.next = (u.next + 1) % upstreamWindowSize
+	u.count = min(u.count+1, upstreamWindowSize)
+
+	if success {
+		u.consecutive = 0
+		if u.down {
+			h.markUp(server, u, "query succeeded")
+		}
+		return
+	}
+
+	u.consecutive++
+	if u.consecutive >= h.config.FailureThreshold {
+		if !u.down {
+			logger.Warn("Forward server is down", "server", server, "consecutive_failures", u.consecutive, "error", err)
+		}
+		// A failure after the cooldown restarts it
+		u.down = true
+		u.downSince = h.now()
+	}
+}
+
+// markUp marks a down server up. The caller must hold h.mu
+func (h *upstreamHealth) markUp(server string, u *upstream, reason string) {
+	logger.Info("Forward server is up again", "server", server, "reason", reason)
+	u.down = false
+	u.downSince = time.Time{}
+	u.consecutive = 0
+}
+
+// statuses returns the health of servers
+func (h *upstreamHealth) statuses(servers []string) []UpstreamStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	result := make([]UpstreamStatus, 0, len(servers))
+	for _, server := range servers {
+		u := h.upstreams[server]
+		status := UpstreamStatus{
+			Server:              server,
+			ConsecutiveFailures: u.consecutive,
+			DownSince:           u.downSince,
+		}
+		if u.down {
+			status.State = UpstreamDown
+		}
+		for i := range u.count {
+			if u.outcomes[i] {
+				status.RecentSuccesses++
+			} else {
+				status.RecentFailures++
+			}
+		}
+		result = append(result, status)
+	}
+	return result
+}
@@ -0,0 +1,215 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+func TestUpstreamHealth(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	probes := make(chan string, 4)
+	var probeFails atomic.Bool
+	probeFails.Store(true)
+	probe := func(ctx context.Context, server string) error {
+		probes <- server
+		if probeFails.Load() {
+			return errors.New("i/o timeout")
+		}
+		return nil
+	}
+
+	servers := []string{"a", "b"}
+	health := newUpstreamHealth(UpstreamHealthConfig{FailureThreshold: 2, Cooldown: 30 * time.Second, ProbeInterval: 5 * time.Second}, servers, probe)
+	health.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	timeout := errors.New("i/o timeout")
+	servfail := NewResolutionError(types.RCODE_SERVER_FAILURE, "server returned error", nil)
+	state := func(server string) UpstreamState {
+		t.Helper()
+		for _, status := range health.statuses(servers) {
+			if status.Server == server {
+				return status.State
+			}
+		}
+		t.Fatalf("no status of %s", server)
+		return 0
+	}
+	expectOrder := func(expected ...string) {
+		t.Helper()
+		if got := health.order(servers); !slices.Equal(got, expected) {
+			t.Fatalf("order() = %v, expected %v", got, expected)
+		}
+	}
+
+	// Failures broken by an answer, abandoned queries and NXDOMAIN do not count
+	health.record(ctx, "a", timeout)
+	health.record(ctx, "a", NewResolutionError(types.RCODE_NAME_ERROR, "server returned error", nil))
+	health.record(ctx, "a", timeout)
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	health.record(canceled, "a", context.Canceled)
+	if got := state("a"); got != UpstreamUp {
+		t.Fatalf("state = %s, expected up", got)
+	}
+
+	health.record(ctx, "a", servfail)
+	if got := state("a"); got != UpstreamDown {
+		t.Fatalf("state = %s after 2 failures, expected down", got)
+	}
+	expectOrder("b")
+	if server := <-probes; server != "a" {
+		t.Fatalf("probed %s, expected the down server a", server)
+	}
+
+	// With every server down, all of them are tried
+	health.record(ctx, "b", timeout)
+	health.record(ctx, "b", timeout)
+	expectOrder("a", "b")
+	<-probes
+
+	// After the cooldown a down server is tried again; an answer marks it up
+	now = now.Add(30 * time.Second)
+	expectOrder("a", "b")
+	<-probes
+	<-probes
+	health.record(ctx, "b", nil)
+	if got := state("b"); got != UpstreamUp {
+		t.Fatalf("state = %s after an answer, expected up", got)
+	}
+
+	// A successful probe marks a server up before its cooldown ends
+	health.record(ctx, "a", timeout)
+	now = now.Add(5 * time.Second)
+	probeFails.Store(false)
+	expectOrder("b")
+	<-probes
+	for deadline := time.Now().Add(time.Second); state("a") != UpstreamUp; {
+		if time.Now().After(deadline) {
+			t.Fatal("server a stayed down after a successful probe")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	expectOrder("a", "b")
+
+	statuses := health.statuses(servers)
+	if statuses[0].RecentSuccesses != 1 || statuses[0].RecentFailures != 4 || statuses[1].RecentSuccesses != 1 || statuses[1].RecentFailures != 2 {
+		t.Errorf("statuses() = %+v, expected the outcomes of the queries", statuses)
+	}
+}
+
+func TestForwardResolver_UpstreamFailover(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+
+	// The first upstream fails while failing is set, the second always answers
+	var packets [2]atomic.Int32
+	addresses := make([]string, 2)
+	for i := range addresses {
+		upstream, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed to start fake upstream: %v", err)
+		}
+		defer upstream.Close()
+		addresses[i] = upstream.LocalAddr().String()
+
+		go func() {
+			buf := make([]byte, 512)
+			for {
+				n, addr, err := upstream.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+				packets[i].Add(1)
+
+				query, err := message.NewDNSResponse(buf[:n])
+				if err != nil || len(query.Questions) != 1 {
+					continue
+				}
+				flags := types.FLAG_QR_RESPONSE
+				if i == 0 && failing.Load() {
+					flags |= types.FLAG_RCODE_SERVER_FAILURE
+				}
+				reply := message.NewResponse(query.Header.ID).WithFlags(flags).AddQuestion(query.Questions[0]).Build()
+				upstream.WriteTo(reply.ToBytes(), addr)
+			}
+		}()
+	}
+
+	config := DefaultResolverConfig()
+	config.ForwardServers = addresses
+	config.MaxRetries = 0
+	config.UpstreamHealth = UpstreamHealthConfig{FailureThreshold: 2, Cooldown: time.Minute, ProbeInterval: 50 * time.Millisecond}
+
+	forwarder, err := NewForwardResolver(config)
+	if err != nil {
+		t.Fatalf("failed to create forward resolver: %v", err)
+	}
+	defer forwarder.Close()
+	question := createQuestion(t, "www.example.com")
+
+	resolve := func() {
+		t.Helper()
+		if _, err := forwarder.Resolve(context.Background(), question); err != nil {
+			t.Fatalf("Resolve() returned error: %v", err)
+		}
+	}
+	states := func() [2]UpstreamState {
+		statuses, ok := forwarder.UpstreamStatuses()
+		if !ok || len(statuses) != 2 {
+			t.Fatalf("UpstreamStatuses() = %v, %v, expected both servers", statuses, ok)
+		}
+		return [2]UpstreamState{statuses[0].State, statuses[1].State}
+	}
+
+	// The failing server is asked first until it is marked down
+	resolve()
+	resolve()
+	if got := states(); got != [2]UpstreamState{UpstreamDown, UpstreamUp} {
+		t.Fatalf("states = %v, expected the first server down", got)
+	}
+	if got := packets[1].Load(); got != 2 {
+		t.Fatalf("second server received %d queries, expected 2", got)
+	}
+
+	// Probes keep failing while the server does; queries skip it
+	time.Sleep(100 * time.Millisecond)
+	asked := packets[0].Load()
+	for range 3 {
+		resolve()
+	}
+	if got := packets[1].Load(); got != 5 {
+		t.Errorf("second server received %d queries, expected 5", got)
+	}
+	if got := states(); got[0] != UpstreamDown {
+		t.Fatalf("state = %s while failing, expected down", got[0])
+	}
+
+	// Once it recovers, a probe marks it up and queries return to it
+	failing.Store(false)
+	deadline := time.Now().Add(2 * time.Second)
+	for states()[0] != UpstreamUp {
+		if time.Now().After(deadline) {
+			t.Fatal("recovered server was not marked up")
+		}
+		resolve()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if packets[0].Load() <= asked {
+		t.Error("down server was never probed")
+	}
+
+	before := packets[1].Load()
+	resolve()
+	if got := packets[1].Load(); got != before {
+		t.Errorf("second server was asked while the first is up again")
+	}
+}
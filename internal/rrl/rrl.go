@@ -0,0 +1,174 @@
+// Package rrl limits the rate of UDP responses sent to client networks
+// (response rate limiting), so the server is of little use for amplifying
+// attacks on spoofed source addresses
+package rrl
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// Action is what to do with a response to a client
+type Action int
+
+const (
+	// Send sends the response
+	Send Action = iota
+	// Slip sends the response truncated, with TC set and no records, so
+	// legitimate clients retry over TCP
+	Slip
+	// Drop sends nothing
+	Drop
+)
+
+// String returns the name of the action
+func (a Action) String() string {
+	switch a {
+	case Send:
+		return "send"
+	case Slip:
+		return "slip"
+	case Drop:
+		return "drop"
+	}
+	return "unknown"
+}
+
+// Limiter tracks the responses sent to each /24 IPv4 and /48 IPv6 network
+// in a leaky bucket draining at the allowed rate. A bucket holds a window's
+// worth of responses; while it is over that, responses slip, and while it
+// is over twice that, they are dropped except for every slip rate-th one,
+// which slips. A Limiter is safe for concurrent use
+type Limiter struct {
+	rate     float64 // Responses per second
+	capacity float64 // Responses a bucket holds before limiting starts
+	slipRate int
+	window   time.Duration
+	v4Prefix int // Prefix length of the IPv4 networks responses are counted for
+	v6Prefix int // Prefix length of the IPv6 networks responses are counted for
+	now      func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[[net.IPv6len]byte]*bucket
+	lastSweep time.Time
+}
+
+// bucket is the leaky bucket of a client network
+type bucket struct {
+	level   float64   // Responses not yet drained
+	updated time.Time // When level was last drained
+	limited int       // Responses limited in a row, counting towards the slip rate
+}
+
+// New returns a limiter allowing responsesPerSecond responses per second to
+// a client network on average, in bursts of up to a window's worth. One in
+// slipRate of the responses that would be dropped slips instead; 0 drops
+// them all
+func New(responsesPerSecond, slipRate int, window time.Duration) *Limiter {
+	rate := float64(responsesPerSecond)
+	return &Limiter{
+		rate:     rate,
+		capacity: max(rate*window.Seconds(), 1),
+		slipRate: slipRate,
+		window:   window,
+		v4Prefix: 24,
+		v6Prefix: 48,
+		now:      time.Now,
+		buckets:  make(map[[net.IPv6len]byte]*bucket),
+	}
+}
+
+// NewPerAddress returns a limiter like New that counts the responses to
+// every client address on its own rather than to its network
+func NewPerAddress(responsesPerSecond, slipRate int, window time.Duration) *Limiter {
+	l := New(responsesPerSecond, slipRate, window)
+	l.v4Prefix, l.v6Prefix = 32, 128
+	return l
+}
+
+// Check counts a response to the client at ip and returns what to do with it
+func (l *Limiter) Check(ip net.IP) Action {
+	key, ok := l.networkKey(ip)
+	if !ok {
+		return Send
+	}
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b := l.buckets[key]
+	if b == nil {
+		b = &bucket{updated: now}
+		l.buckets[key] = b
+	}
+	b.drain(now, l.rate)
+
+	// Past the drop threshold the level stops rising, so a network recovers
+	// within a window of the attack ending
+	b.level = min(b.level+1, 2*l.capacity+1)
+
+	switch {
+	case b.level <= l.capacity:
+		b.limited = 0
+		return Send
+	case b.level <= 2*l.capacity:
+		return Slip
+	}
+
+	b.limited++
+	if l.slipRate > 0 && b.limited%l.slipRate == 0 {
+		return Slip
+	}
+	return Drop
+}
+
+// Len returns the number of client networks tracked
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.buckets)
+}
+
+// drain empties the bucket at rate for the time since it was last drained
+func (b *bucket) drain(now time.Time, rate float64) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.level = max(b.level-elapsed*rate, 0)
+	}
+	b.updated = now
+}
+
+// sweep forgets the networks whose buckets have emptied, at most once a
+// window. The caller must hold l.mu
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		b.drain(now, l.rate)
+		if b.level == 0 {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// networkKey returns the key of the IPv4 or IPv6 network of ip, a /24 or
+// /48 one unless the limiter counts single addresses
+func (l *Limiter) networkKey(ip net.IP) ([net.IPv6len]byte, bool) {
+	var key [net.IPv6len]byte
+	if ip4 := ip.To4(); ip4 != nil {
+		copy(key[:], ip4.Mask(net.CIDRMask(l.v4Prefix, 32)).To16())
+		return key, true
+	}
+	if ip16 := ip.To16(); ip16 != nil {
+		copy(key[:], ip16.Mask(net.CIDRMask(l.v6Prefix, 128)))
+		return key, true
+	}
+	return key, false
+}
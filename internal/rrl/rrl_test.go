@@ -0,0 +1,111 @@
+package rrl
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestLimiter returns a limiter on a clock advanced by the returned func
+func newTestLimiter(responsesPerSecond, slipRate int, window time.Duration) (*Limiter, func(time.Duration)) {
+	l := New(responsesPerSecond, slipRate, window)
+	now := time.Unix(1700000000, 0)
+	l.now = func() time.Time { return now }
+	return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+// checkAll checks a response to ip count times
+func checkAll(l *Limiter, ip string, count int) []Action {
+	actions := make([]Action, 0, count)
+	for range count {
+		actions = append(actions, l.Check(net.ParseIP(ip)))
+	}
+	return actions
+}
+
+func TestLimiter_Check(t *testing.T) {
+	tests := []struct {
+		name     string
+		slipRate int
+		expected []Action
+	}{
+		{name: "slip every other", slipRate: 2, expected: []Action{Send, Send, Slip, Slip, Drop, Slip, Drop, Slip}},
+		{name: "slip all", slipRate: 1, expected: []Action{Send, Send, Slip, Slip, Slip, Slip, Slip, Slip}},
+		{name: "drop all", slipRate: 0, expected: []Action{Send, Send, Slip, Slip, Drop, Drop, Drop, Drop}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, _ := newTestLimiter(2, tt.slipRate, time.Second)
+			if got := checkAll(l, "192.0.2.1", len(tt.expected)); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Check() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLimiter_Networks(t *testing.T) {
+	l, _ := newTestLimiter(1, 0, time.Second)
+
+	// Addresses of a /24 or /48 network share a bucket
+	if got := checkAll(l, "192.0.2.1", 1)[0]; got != Send {
+		t.Errorf("first response = %v, expected send", got)
+	}
+	if got := checkAll(l, "192.0.2.200", 1)[0]; got != Slip {
+		t.Errorf("response to the same /24 = %v, expected slip", got)
+	}
+	if got := checkAll(l, "192.0.3.1", 1)[0]; got != Send {
+		t.Errorf("response to another /24 = %v, expected send", got)
+	}
+
+	checkAll(l, "2001:db8:1:2::1", 1)
+	if got := checkAll(l, "2001:db8:1:ffff::1", 1)[0]; got != Slip {
+		t.Errorf("response to the same /48 = %v, expected slip", got)
+	}
+	if got := checkAll(l, "2001:db8:2::1", 1)[0]; got != Send {
+		t.Errorf("response to another /48 = %v, expected send", got)
+	}
+	if l.Len() != 4 {
+		t.Errorf("Len() = %d, expected 4", l.Len())
+	}
+}
+
+func TestLimiter_PerAddress(t *testing.T) {
+	l := NewPerAddress(1, 0, time.Second)
+	l.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	for _, ip := range []string{"192.0.2.1", "192.0.2.200", "2001:db8::1", "2001:db8::2"} {
+		if got := checkAll(l, ip, 1)[0]; got != Send {
+			t.Errorf("first response to %s = %v, expected send", ip, got)
+		}
+	}
+	if got := checkAll(l, "192.0.2.1", 1)[0]; got != Slip {
+		t.Errorf("second response to the same address = %v, expected slip", got)
+	}
+	if l.Len() != 4 {
+		t.Errorf("Len() = %d, expected 4", l.Len())
+	}
+}
+
+func TestLimiter_Recovers(t *testing.T) {
+	l, advance := newTestLimiter(10, 0, time.Second)
+
+	checkAll(l, "192.0.2.1", 1000)
+	if got := checkAll(l, "192.0.2.1", 1)[0]; got != Drop {
+		t.Fatalf("response during the flood = %v, expected drop", got)
+	}
+
+	// The bucket drains a window's worth in a window
+	advance(2*time.Second + 100*time.Millisecond)
+	if got := checkAll(l, "192.0.2.1", 1)[0]; got != Send {
+		t.Errorf("response after the flood = %v, expected send", got)
+	}
+
+	// Idle networks are forgotten
+	advance(time.Minute)
+	checkAll(l, "198.51.100.1", 1)
+	if l.Len() != 1 {
+		t.Errorf("Len() = %d, expected only the active network", l.Len())
+	}
+}
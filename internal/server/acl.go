@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"net"
+
+	"github.com/vadim-su/dnska/internal/acl"
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// queryACL holds the compiled allow-query networks of the server and of zones
+type queryACL struct {
+	admitted *acl.ACL            // Clients of any list, nil admits every client
+	allow    *acl.ACL            // Server-wide list, nil allows every client
+	zones    map[string]*acl.ACL // Zone lists by normalized zone name
+}
+
+// newQueryACL compiles the allow-query networks, nil when none are configured
+func newQueryACL(cfg config.ServerConfig) (*queryACL, error) {
+	if len(cfg.AllowQuery) == 0 && len(cfg.ZoneAllowQuery) == 0 {
+		return nil, nil
+	}
+
+	q := &queryACL{zones: make(map[string]*acl.ACL, len(cfg.ZoneAllowQuery))}
+	admitted := append([]string(nil), cfg.AllowQuery...)
+	for _, zone := range cfg.ZoneAllowQuery {
+		list, err := acl.New(zone.Networks)
+		if err != nil {
+			return nil, err
+		}
+		q.zones[normalizeZone(zone.Zone)] = list
+		admitted = append(admitted, zone.Networks...)
+	}
+
+	if len(cfg.AllowQuery) > 0 {
+		var err error
+		if q.allow, err = acl.New(cfg.AllowQuery); err != nil {
+			return nil, err
+		}
+		if q.admitted, err = acl.New(admitted); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+// admits reports whether queries of the client at ip may be allowed by any
+// list, so they are worth parsing
+func (q *queryACL) admits(ip net.IP) bool {
+	return q.admitted == nil || q.admitted.Contains(ip)
+}
+
+// allows reports whether the client at ip may query name: the list of the
+// closest zone enclosing name decides, or the server-wide one outside them
+func (q *queryACL) allows(name string, ip net.IP) bool {
+	list := q.allow
+	if zone, ok := closestZone(name, func(zone string) bool {
+		_, ok := q.zones[zone]
+		return ok
+	}); ok {
+		list = q.zones[zone]
+	}
+	return list == nil || list.Contains(ip)
+}
+
+// admitQuery checks the client of the request in data against the
+// allow-query networks before the request is parsed beyond its header. For
+// a query that is not admitted it returns the REFUSED response to send, nil
+// when the query is dropped instead
+func (s *Server) admitQuery(data []byte, clientAddr net.Addr) (bool, []byte) {
+	if s.queryACL == nil {
+		return true, nil
+	}
+
+	// Malformed requests are left to the parser
+	header, err := message.ParseHeader(data)
+	if err != nil || header.Flags.Opcode() != types.OPCODE_QUERY || s.queryACL.admits(addrIP(clientAddr)) {
+		return true, nil
+	}
+
+	if s.config.Server.DropRefused || header.Flags.IsResponse() {
+		return false, nil
+	}
+	return false, message.NewResponse(header.ID).
+		WithFlags(message.PrepareResponseFlags(header.Flags)).
+		SetRcode(types.RCODE_REFUSED).
+		Build().
+		ToBytes()
+}
+
+// queryAccess refuses queries for names the client may not query under the
+// allow-query networks of zones, passing other requests on
+func (s *Server) queryAccess(next Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+		request := query.Request
+		if request.Header.Flags.Opcode() != types.OPCODE_QUERY {
+			return next.Handle(ctx, query)
+		}
+
+		ip := addrIP(query.ClientAddr)
+		for _, question := range request.Questions {
+			if !s.queryACL.allows(question.Name.String(), ip) {
+				return s.createErrorResponse(request, types.RCODE_REFUSED), nil
+			}
+		}
+
+		return next.Handle(ctx, query)
+	})
+}
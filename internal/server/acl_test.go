@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// exchange answers the query in data from clientAddr the way the listeners
+// do, returning nil when the query is dropped
+func exchange(t *testing.T, s *Server, data []byte, clientAddr net.Addr) *message.DNSResponse {
+	t.Helper()
+
+	if admitted, refusal := s.admitQuery(data, clientAddr); !admitted {
+		if refusal == nil {
+			return nil
+		}
+		response, err := message.NewDNSResponse(refusal)
+		if err != nil {
+			t.Fatalf("failed to parse refusal: %v", err)
+		}
+		return response
+	}
+
+	request, err := message.NewDNSRequest(data)
+	if err != nil {
+		t.Fatalf("failed to parse request: %v", err)
+	}
+	response, err := s.handler.Handle(context.Background(), s.newQueryContext(request, clientAddr))
+	if err != nil {
+		t.Fatalf("Handle() returned error: %v", err)
+	}
+	return response
+}
+
+func TestServer_AllowQuery(t *testing.T) {
+	store, err := storage.NewMemoryStorage(nil)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+	for _, record := range []records.DNSRecord{
+		records.NewARecord("www.example.com.", net.ParseIP("192.0.2.1"), 300),
+		records.NewARecord("db.internal.example.com.", net.ParseIP("10.0.0.5"), 300),
+		records.NewARecord("www.public.internal.example.com.", net.ParseIP("192.0.2.2"), 300),
+	} {
+		if err := store.PutRecord(context.Background(), record); err != nil {
+			t.Fatalf("failed to store record: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		domain string
+		client net.Addr
+		drop   bool
+		rcode  types.DNSRCode
+	}{
+		{name: "allowed IPv4 client", domain: "www.example.com", client: &net.UDPAddr{IP: net.ParseIP("192.0.2.7")}, rcode: types.RCODE_NO_ERROR},
+		{name: "allowed IPv6 client", domain: "www.example.com", client: &net.TCPAddr{IP: net.ParseIP("2001:db8::7")}, rcode: types.RCODE_NO_ERROR},
+		{name: "other client", domain: "www.example.com", client: &net.UDPAddr{IP: net.ParseIP("203.0.113.1")}, rcode: types.RCODE_REFUSED},
+		{name: "other client dropped", domain: "www.example.com", client: &net.UDPAddr{IP: net.ParseIP("203.0.113.1")}, drop: true},
+		{name: "zone client outside the zone", domain: "www.example.com", client: &net.UDPAddr{IP: net.ParseIP("10.1.2.3")}, rcode: types.RCODE_REFUSED},
+		{name: "zone client", domain: "db.internal.example.com", client: &net.UDPAddr{IP: net.ParseIP("10.1.2.3")}, rcode: types.RCODE_NO_ERROR},
+		{name: "server client in the zone", domain: "db.internal.example.com", client: &net.UDPAddr{IP: net.ParseIP("192.0.2.7")}, rcode: types.RCODE_REFUSED},
+		{name: "most specific zone", domain: "www.public.internal.example.com", client: &net.UDPAddr{IP: net.ParseIP("198.51.100.1")}, rcode: types.RCODE_NO_ERROR},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.Server.AllowQuery = []string{"192.0.2.0/24", "2001:db8::/32"}
+			cfg.Server.DropRefused = tt.drop
+			cfg.Server.ZoneAllowQuery = []config.ZoneAllowQueryConfig{
+				{Zone: "internal.example.com.", Networks: []string{"10.0.0.0/8"}},
+				{Zone: "Public.Internal.Example.com", Networks: []string{"198.51.100.0/24"}},
+			}
+			queryACL, err := newQueryACL(cfg.Server)
+			if err != nil {
+				t.Fatalf("newQueryACL() returned error: %v", err)
+			}
+			s := &Server{config: cfg, storage: store, queryACL: queryACL, stats: newStatsCollector(0, 0)}
+			s.handler = s.buildHandler()
+
+			query := message.GenerateDNSQuery(1234, []message.DNSQuestion{message.NewDNSQuestion(mustDomainName(tt.domain), types.TYPE_A, types.CLASS_IN)})
+			response := exchange(t, s, query.ToBytes(), tt.client)
+
+			if tt.drop {
+				if response != nil {
+					t.Fatalf("expected the query to be dropped, got %s", response.Header.Flags.Rcode())
+				}
+				return
+			}
+			if response == nil {
+				t.Fatal("query was dropped")
+			}
+			if response.Header.ID != 1234 || !response.Header.Flags.IsResponse() {
+				t.Errorf("response header = %+v", response.Header)
+			}
+			if rcode := response.Header.Flags.Rcode(); rcode != tt.rcode {
+				t.Errorf("rcode = %s, expected %s", rcode, tt.rcode)
+			}
+			if answered := len(response.Answers) > 0; answered != (tt.rcode == types.RCODE_NO_ERROR) {
+				t.Errorf("got %d answers", len(response.Answers))
+			}
+		})
+	}
+}
+
+func TestNewQueryACL(t *testing.T) {
+	if q, err := newQueryACL(config.ServerConfig{}); q != nil || err != nil {
+		t.Errorf("newQueryACL() without networks = %v, %v", q, err)
+	}
+	if _, err := newQueryACL(config.ServerConfig{AllowQuery: []string{"192.0.2.0/33"}}); err == nil {
+		t.Error("newQueryACL() expected error for an invalid network")
+	}
+
+	// Zone lists alone admit every client to parsing
+	q, err := newQueryACL(config.ServerConfig{ZoneAllowQuery: []config.ZoneAllowQueryConfig{{Zone: "example.com", Networks: []string{"10.0.0.0/8"}}}})
+	if err != nil {
+		t.Fatalf("newQueryACL() returned error: %v", err)
+	}
+	if !q.admits(net.ParseIP("198.51.100.1")) || !q.allows("www.example.net.", net.ParseIP("198.51.100.1")) {
+		t.Error("client outside the zone lists is refused")
+	}
+	if q.allows("www.example.com.", net.ParseIP("198.51.100.1")) {
+		t.Error("client outside the zone list may query the zone")
+	}
+}
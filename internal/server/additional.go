@@ -0,0 +1,85 @@
+package server
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// maxAdditionalRecords caps the additional section glue is added to
+const maxAdditionalRecords = 10
+
+// populateAdditional appends the stored A and AAAA records of the targets of
+// NS, MX and SRV answers to additional, skipping records it already holds, until
+// it holds maxAdditionalRecords. Minimal responses get no glue
+func (s *Server) populateAdditional(ctx context.Context, answers, additional []message.DNSAnswer, view string) []message.DNSAnswer {
+	if s.config.Responses.Minimal {
+		return additional
+	}
+
+	for _, answer := range answers {
+		target, ok := answerTarget(answer)
+		if !ok {
+			continue
+		}
+
+		for _, addressType := range []types.DNSType{types.TYPE_A, types.TYPE_AAAA} {
+			addresses, err := s.lookupRecords(ctx, target.String(), addressType, view)
+			if err != nil {
+				continue
+			}
+
+			for _, address := range recordAnswers(addresses) {
+				if len(additional) >= maxAdditionalRecords {
+					return additional
+				}
+				if !containsAnswer(additional, address) {
+					additional = append(additional, address)
+				}
+			}
+		}
+	}
+
+	return additional
+}
+
+// answerTarget returns the name server of an NS answer, the mail server of
+// an MX answer or the target of an SRV answer
+func answerTarget(answer message.DNSAnswer) (*utils.DomainName, bool) {
+	data := answer.Data()
+	switch answer.Type() {
+	case types.TYPE_NS:
+	case types.TYPE_MX:
+		if len(data) < 2 {
+			return nil, false
+		}
+		data = data[2:]
+	case types.TYPE_SRV:
+		if len(data) < 6 {
+			return nil, false
+		}
+		data = data[6:]
+	default:
+		return nil, false
+	}
+
+	target, _, err := utils.NewDomainName(data)
+	if err != nil {
+		return nil, false
+	}
+	return target, true
+}
+
+// containsAnswer reports whether answers holds a record with the owner, type
+// and data of answer
+func containsAnswer(answers []message.DNSAnswer, answer message.DNSAnswer) bool {
+	for _, existing := range answers {
+		if existing.Type() == answer.Type() && existing.Name().Equal(answer.Name()) && bytes.Equal(existing.Data(), answer.Data()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+func TestServer_AdditionalGlue(t *testing.T) {
+	zone := []records.DNSRecord{
+		records.NewSOARecord("example.com.", "ns1.example.com.", "hostmaster.example.com.", 1,
+			time.Hour, 10*time.Minute, 24*time.Hour, 5*time.Minute, 3600),
+		records.NewMXRecord("example.com.", "mail.example.com.", 10, 300),
+		records.NewMXRecord("example.com.", "mail.example.com.", 20, 300),
+		records.NewARecord("mail.example.com.", net.ParseIP("192.0.2.25"), 300),
+		records.NewSRVRecord("service.example.com.", "sip.example.com.", 10, 20, 5060, 300),
+		records.NewAAAARecord("sip.example.com.", net.ParseIP("2001:db8::5060"), 300),
+	}
+
+	tests := []struct {
+		name       string
+		qname      string
+		qtype      types.DNSType
+		minimal    bool
+		additional map[string]types.DNSType
+	}{
+		{"MX", "example.com.", types.TYPE_MX, false, map[string]types.DNSType{"mail.example.com.": types.TYPE_A}},
+		{"SRV", "service.example.com.", types.TYPE_SRV, false, map[string]types.DNSType{"sip.example.com.": types.TYPE_AAAA}},
+		{"minimal", "example.com.", types.TYPE_MX, true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newUDPTestServer(t, zone)
+			s.config.Responses.Minimal = tt.minimal
+
+			query := message.GenerateDNSQuery(1, []message.DNSQuestion{
+				message.NewDNSQuestion(mustDomainName(tt.qname), tt.qtype, types.CLASS_IN),
+			})
+			response, err := message.NewDNSResponse(s.answerUDP(query.ToBytes(), &net.UDPAddr{IP: net.ParseIP("192.0.2.7")}, nil))
+			if err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+			if len(response.Answers) == 0 {
+				t.Fatalf("no answers for %s %s", tt.qname, tt.qtype)
+			}
+
+			// Both MX records share one target, whose address is added once
+			var glue []message.DNSAnswer
+			for _, record := range response.AdditionalRecords {
+				if record.Type() != types.TYPE_OPT {
+					glue = append(glue, record)
+				}
+			}
+			if len(glue) != len(tt.additional) {
+				t.Fatalf("additional section = %v, expected %v", glue, tt.additional)
+			}
+			for _, record := range glue {
+				name := record.Name()
+				if want, ok := tt.additional[name.String()]; !ok || record.Type() != want {
+					t.Errorf("unexpected additional record %s %s", name.String(), record.Type())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"sort"
+
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// rfc8482HINFO is the HINFO RDATA answering ANY queries in the "hinfo" mode:
+// CPU "RFC8482" and an empty OS (RFC 8482 §4.2)
+var rfc8482HINFO = []byte("\x07RFC8482\x00")
+
+// hasAnyQuestion reports whether request asks for records of type ANY
+func hasAnyQuestion(request *message.DNSRequest) bool {
+	for _, question := range request.Questions {
+		if question.Qtype() == types.TYPE_ANY {
+			return true
+		}
+	}
+	return false
+}
+
+// answerAny answers an ANY question for name from its stored records in
+// view: all of them ordered by type, or a single HINFO record in the "hinfo"
+// mode. It returns nil when name has no records
+func (s *Server) answerAny(ctx context.Context, question message.DNSQuestion, name, view string) ([]message.DNSAnswer, error) {
+	// Type 0 selects records of all types
+	stored, err := s.lookupRecords(ctx, name, 0, view)
+	if err != nil || len(stored) == 0 {
+		return nil, err
+	}
+
+	if s.config.Responses.AnyQueryMode == "hinfo" {
+		ttl := stored[0].TTL()
+		for _, record := range stored[1:] {
+			ttl = min(ttl, record.TTL())
+		}
+		answer, err := message.NewDNSAnswer(question.Name.ToBytes(), question.Qclass(), types.TYPE_HINFO, ttl, rfc8482HINFO)
+		if err != nil {
+			return nil, err
+		}
+		return []message.DNSAnswer{*answer}, nil
+	}
+
+	// Storage returns the types in no particular order
+	sort.SliceStable(stored, func(i, j int) bool {
+		return stored[i].Type() < stored[j].Type()
+	})
+	return s.recordsToAnswers(stored, question)
+}
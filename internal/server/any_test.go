@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+func TestServer_AnyQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   string
+		qname  string
+		rcode  types.DNSRCode
+		answer []types.DNSType
+	}{
+		{name: "all", mode: "all", qname: "example.org.", rcode: types.RCODE_NO_ERROR, answer: []types.DNSType{types.TYPE_NS, types.TYPE_SOA}},
+		{name: "all of a name", mode: "all", qname: "www.example.org.", rcode: types.RCODE_NO_ERROR, answer: []types.DNSType{types.TYPE_A, types.TYPE_A}},
+		{name: "default mode", qname: "alias.example.org.", rcode: types.RCODE_NO_ERROR, answer: []types.DNSType{types.TYPE_CNAME}},
+		{name: "unknown name", mode: "all", qname: "missing.example.org.", rcode: types.RCODE_NAME_ERROR},
+		{name: "hinfo", mode: "hinfo", qname: "example.org.", rcode: types.RCODE_NO_ERROR, answer: []types.DNSType{types.TYPE_HINFO}},
+		{name: "hinfo for an unknown name", mode: "hinfo", qname: "missing.example.org.", rcode: types.RCODE_NAME_ERROR},
+		{name: "refused", mode: "refused", qname: "example.org.", rcode: types.RCODE_REFUSED},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store, err := storage.NewMemoryStorage(nil)
+			if err != nil {
+				t.Fatalf("failed to create storage: %v", err)
+			}
+			defer store.Close()
+			if err := store.BatchPutRecords(ctx, updateZone()); err != nil {
+				t.Fatalf("failed to store records: %v", err)
+			}
+
+			cfg := config.DefaultConfig()
+			if tt.mode != "" {
+				cfg.Responses.AnyQueryMode = tt.mode
+			}
+			s := &Server{config: cfg, storage: store}
+
+			query := message.GenerateDNSQuery(1234, []message.DNSQuestion{message.NewDNSQuestion(mustDomainName(tt.qname), types.TYPE_ANY, types.CLASS_IN)})
+			request, err := message.NewDNSRequest(query.ToBytes())
+			if err != nil {
+				t.Fatalf("failed to parse request: %v", err)
+			}
+
+			response, err := s.processRequest(ctx, request, storage.DefaultView)
+			if err != nil {
+				t.Fatalf("processRequest() returned error: %v", err)
+			}
+			if rcode := response.Header.Flags.Rcode(); rcode != tt.rcode {
+				t.Errorf("rcode = %s, expected %s", rcode, tt.rcode)
+			}
+			if len(response.Answers) != len(tt.answer) {
+				t.Fatalf("got %d answers, expected %d", len(response.Answers), len(tt.answer))
+			}
+			for i, answer := range response.Answers {
+				if answer.Type() != tt.answer[i] {
+					t.Errorf("answer %d has type %s, expected %s", i, answer.Type(), tt.answer[i])
+				}
+				if name := answer.Name(); name.String() != tt.qname {
+					t.Errorf("answer %d is owned by %s, expected %s", i, name.String(), tt.qname)
+				}
+			}
+			if tt.mode == "hinfo" && len(response.Answers) == 1 {
+				if data := string(response.Answers[0].Data()); data != "\x07RFC8482\x00" {
+					t.Errorf("HINFO data = %q", data)
+				}
+				// The lowest TTL of the name's records
+				if ttl := response.Answers[0].TTL(); ttl != 3600 {
+					t.Errorf("HINFO TTL = %d, expected 3600", ttl)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// authoritySections returns the authority and additional records of a
+// positive answer to question: the NS records of the stored zone enclosing
+// its name and the addresses of those servers, unless minimal responses are
+// configured. Names outside stored zones get neither
+func (s *Server) authoritySections(ctx context.Context, question message.DNSQuestion, view string) ([]message.DNSAnswer, []message.DNSAnswer) {
+	if s.config.Responses.Minimal {
+		return nil, nil
+	}
+
+	zone, soa := s.enclosingZone(ctx, s.lookupName(question.Name), view)
+	if soa == nil {
+		return nil, nil
+	}
+
+	nameServers, err := s.lookupRecords(ctx, zone.String(), types.TYPE_NS, view)
+	if err != nil {
+		return nil, nil
+	}
+
+	var additional []message.DNSAnswer
+	for _, nameServer := range nameServers {
+		target, _, err := utils.NewDomainName(nameServer.Data())
+		if err != nil {
+			continue
+		}
+
+		for _, addressType := range []types.DNSType{types.TYPE_A, types.TYPE_AAAA} {
+			addresses, err := s.lookupRecords(ctx, target.String(), addressType, view)
+			if err == nil {
+				additional = append(additional, recordAnswers(addresses)...)
+			}
+		}
+	}
+
+	return recordAnswers(nameServers), additional
+}
+
+// attachAuthority adds the SOA of the stored zone enclosing qname to the
+// authority section of the negative response (RFC 2308 §3), with its TTL
+// lowered to the SOA minimum, the TTL negative answers are cached for. A
+// qname holding records of other types, or covered by a wildcard, gets a
+// NODATA response: NOERROR instead of NXDOMAIN. Names outside stored zones
+// are left alone
+func (s *Server) attachAuthority(ctx context.Context, response *message.DNSResponse, qname utils.DomainName, view string) {
+	_, soa := s.enclosingZone(ctx, qname, view)
+	if soa == nil {
+		return
+	}
+
+	for i, answer := range recordAnswers(soa) {
+		ttl := answer.TTL()
+		if record, ok := soaOf(soa[i]); ok {
+			ttl = min(ttl, uint32(record.Minimum()/time.Second))
+		}
+		answer.SetTTL(clampTTL(ttl, s.config.Responses.MinTTL, s.config.Responses.MaxTTL))
+		response.AuthorityRecords = append(response.AuthorityRecords, answer)
+	}
+	response.Header.AuthorityRecordCount = uint16(len(response.AuthorityRecords))
+
+	flags := response.Header.Flags
+	if flags.Rcode() == types.RCODE_NAME_ERROR && s.nameExists(ctx, qname, view) {
+		response.Header.Flags = types.NewFlagBuilder(flags).SetRcode(uint8(types.RCODE_NO_ERROR)).Build()
+	}
+}
+
+// nameExists reports whether name holds stored records of any type or is
+// covered by a wildcard
+func (s *Server) nameExists(ctx context.Context, name utils.DomainName, view string) bool {
+	if existing, err := s.lookupRecords(ctx, name.String(), 0, view); err == nil && len(existing) > 0 {
+		return true
+	}
+	return len(s.coveringWildcard(ctx, name, view)) > 0
+}
+
+// enclosingZone returns the closest enclosing zone of name held in storage
+// and its SOA records, or nil records when name is in no stored zone
+func (s *Server) enclosingZone(ctx context.Context, name utils.DomainName, view string) (utils.DomainName, []records.DNSRecord) {
+	for zone := name; ; zone = zone.Parent() {
+		soa, err := s.lookupRecords(ctx, zone.String(), types.TYPE_SOA, view)
+		if err == nil && len(soa) > 0 {
+			return zone, soa
+		}
+		if len(zone.Labels) == 0 {
+			return zone, nil
+		}
+	}
+}
+
+// recordAnswers converts stored records to answers owned by the record names
+func recordAnswers(storedRecords []records.DNSRecord) []message.DNSAnswer {
+	answers := make([]message.DNSAnswer, 0, len(storedRecords))
+
+	for _, record := range storedRecords {
+		owner, err := utils.NewDomainNameFromString(record.Name())
+		if err != nil {
+			logger.Warn("Failed to create answer for record", "name", record.Name(), "error", err)
+			continue
+		}
+		answers = append(answers, *message.NewDNSAnswerFromParts(*owner, record.Type(), record.Class(), record.TTL(), record.Data()))
+	}
+
+	return answers
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/vadim-su/dnska/internal/blocklist"
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// newBlocklist loads the configured blocklists, nil when none are configured
+func newBlocklist(cfg config.BlocklistConfig) (*blocklist.Blocklist, error) {
+	if len(cfg.Files) == 0 {
+		return nil, nil
+	}
+
+	list, err := blocklist.New(cfg.Files)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Blocklist initialized", "entries", list.Len(), "files", cfg.Files)
+	return list, nil
+}
+
+// blockedAnswers answers a question for a blocked name. The NXDOMAIN
+// response has no answers; the sink response answers A and AAAA questions
+// with the sink addresses
+func (s *Server) blockedAnswers(question message.DNSQuestion, questionType types.DNSType) ([]message.DNSAnswer, error) {
+	cfg := s.config.Blocklist
+	if cfg.Response != "sink" {
+		return nil, nil
+	}
+
+	var data []byte
+	switch questionType {
+	case types.TYPE_A:
+		data = net.ParseIP(cfg.SinkIPv4).To4()
+	case types.TYPE_AAAA:
+		data = net.ParseIP(cfg.SinkIPv6).To16()
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	answer, err := message.NewDNSAnswer(question.Name.ToBytes(), types.CLASS_IN, questionType, uint32(cfg.TTL.Seconds()), data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sink answer: %w", err)
+	}
+	return []message.DNSAnswer{*answer}, nil
+}
+
+// BlockedQueries returns the number of queries answered from the blocklist
+func (s *Server) BlockedQueries() uint64 {
+	if s.blocklist == nil {
+		return 0
+	}
+	return s.blocklist.Hits()
+}
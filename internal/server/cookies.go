@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"net"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/internal/cookie"
+	"github.com/vadim-su/dnska/pkg/dns/edns"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// cookieValue is the QueryContext key set for queries carrying a valid
+// server cookie, whose client address is therefore not spoofed
+const cookieValue = "cookie"
+
+// newCookieIssuer creates the issuer of server cookies, nil when DNS
+// cookies are disabled
+func newCookieIssuer(cfg config.CookiesConfig) (*cookie.Issuer, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	logger.Info("DNS cookies initialized", "enforce", cfg.Enforce, "secret_rotation", cfg.SecretRotation)
+	return cookie.New()
+}
+
+// cookies handles the COOKIE option (RFC 7873) of queries: every response
+// to a query with a client cookie carries a fresh server cookie for it.
+// When cookies are enforced, UDP queries without a valid server cookie are
+// answered with BADCOOKIE instead, so the client retries with the cookie
+// (RFC 7873 §5.2.3 and §5.2.4). Queries without a COOKIE option pass
+func (s *Server) cookies(next Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+		request := query.Request
+		if request.Cookie == nil {
+			return next.Handle(ctx, query)
+		}
+
+		ip := addrIP(query.ClientAddr)
+		valid := len(request.Cookie.Server) > 0 && s.cookieIssuer.Valid(request.Cookie.Client, request.Cookie.Server, ip)
+		issued := &edns.Cookie{Client: request.Cookie.Client, Server: s.cookieIssuer.Issue(request.Cookie.Client, ip)}
+
+		if _, udp := query.ClientAddr.(*net.UDPAddr); !valid && udp && s.config.EDNS.Cookies.Enforce {
+			response := message.NewResponseFor(request).Build()
+			response.AddCookie(issued)
+			response.SetRcode(types.RCODE_BADCOOKIE)
+			return response, nil
+		}
+
+		if valid {
+			query.Set(cookieValue, true)
+		}
+
+		response, err := next.Handle(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		response.AddCookie(issued)
+		return response, nil
+	})
+}
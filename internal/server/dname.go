@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// maxDNAMESubstitutions bounds the DNAME records followed for one question,
+// which stops DNAMEs redirecting into each other from looping
+const maxDNAMESubstitutions = 8
+
+// answerDNAME answers question from the DNAME record of an ancestor of name
+// (RFC 6672 §3.1). The answers hold the DNAME, a CNAME synthesized from name
+// to name with the DNAME owner replaced by its target, and the answers for
+// that target, which may in turn lie below another DNAME. It returns nil
+// when no DNAME covers name
+func (s *Server) answerDNAME(ctx context.Context, question message.DNSQuestion, name utils.DomainName, view string) ([]message.DNSAnswer, error) {
+	var answers []message.DNSAnswer
+	current := name
+	for substitutions := 0; ; substitutions++ {
+		owner, dname := s.coveringDNAME(ctx, current, view)
+		if dname == nil {
+			break
+		}
+		if substitutions == maxDNAMESubstitutions {
+			return answers, fmt.Errorf("more than %d DNAME substitutions for %s", maxDNAMESubstitutions, name.String())
+		}
+
+		target, err := utils.ParseDomainName(dname.Target())
+		if err != nil {
+			return nil, fmt.Errorf("invalid DNAME target of %s: %w", owner.String(), err)
+		}
+		// The labels of current below the owner are kept in front of the target
+		prefix := current.Labels[:len(current.Labels)-len(owner.Labels)]
+		synthesized := utils.DomainName{Labels: append(slices.Clone(prefix), target.Labels...)}
+		if synthesized.EncodedLength() > utils.MAX_DOMAIN_NAME_LENGTH {
+			return nil, fmt.Errorf("DNAME substitution of %s makes a name over %d bytes", current.String(), utils.MAX_DOMAIN_NAME_LENGTH)
+		}
+
+		dnameAnswer, err := message.NewDNSAnswer(owner.ToBytes(), question.Qclass(), types.TYPE_DNAME, dname.TTL(), dname.Data())
+		if err != nil {
+			return nil, err
+		}
+		// The synthesized CNAME has the TTL of the DNAME (RFC 6672 §3.1)
+		cnameAnswer, err := message.NewDNSAnswer(current.ToBytes(), question.Qclass(), types.TYPE_CNAME, dname.TTL(), synthesized.ToBytes())
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, *dnameAnswer, *cnameAnswer)
+		current = synthesized
+	}
+	if len(answers) == 0 || question.Qtype() == types.TYPE_CNAME {
+		return answers, nil
+	}
+
+	// Resolution continues with the synthesized name, which no DNAME covers
+	targetQuestion := question
+	targetQuestion.Name = current
+	targetAnswers, err := s.resolveQuestion(ctx, targetQuestion, view)
+	return append(answers, targetAnswers...), err
+}
+
+// coveringDNAME returns the DNAME record of the closest ancestor of name
+// holding one, along with that ancestor. The owner of a DNAME is not covered
+// by it, only the names below
+func (s *Server) coveringDNAME(ctx context.Context, name utils.DomainName, view string) (utils.DomainName, *records.DNAMERecord) {
+	for ancestor := name.Parent(); len(ancestor.Labels) > 0; ancestor = ancestor.Parent() {
+		dnames, err := s.lookupRecords(ctx, ancestor.String(), types.TYPE_DNAME, view)
+		if err != nil {
+			return utils.DomainName{}, nil
+		}
+		for _, record := range dnames {
+			if dname, ok := dnameOf(record); ok {
+				return ancestor, dname
+			}
+		}
+	}
+	return utils.DomainName{}, nil
+}
+
+// dnameOf returns the DNAME record record is, unwrapping view tags
+func dnameOf(record records.DNSRecord) (*records.DNAMERecord, bool) {
+	if wrapped, ok := record.(interface{ Unwrap() records.DNSRecord }); ok {
+		record = wrapped.Unwrap()
+	}
+	dname, ok := record.(*records.DNAMERecord)
+	return dname, ok
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// synthesizeDNS64 builds AAAA records for name from its A records in view
+// (RFC 6147 §5.1). It returns nil when DNS64 is disabled or name has no A
+// records
+func (s *Server) synthesizeDNS64(ctx context.Context, name, view string) []records.DNSRecord {
+	if s.dns64Prefix == nil {
+		return nil
+	}
+
+	aRecords, err := s.lookupRecords(ctx, name, types.TYPE_A, view)
+	if err != nil || len(aRecords) == 0 {
+		return nil
+	}
+
+	synthesized := make([]records.DNSRecord, 0, len(aRecords))
+	for _, record := range aRecords {
+		ip, err := utils.EmbedIPv4(s.dns64Prefix, net.IP(record.Data()))
+		if err != nil {
+			logger.Warn("Failed to synthesize AAAA record", "name", record.Name(), "error", err)
+			continue
+		}
+		synthesized = append(synthesized, records.NewAAAARecord(record.Name(), ip, dns64TTL(record.TTL(), s.config.DNS64.MaxTTL)))
+	}
+
+	if len(synthesized) > 0 {
+		logger.Debug("Synthesized DNS64 response", "qname", name, "prefix", s.dns64Prefix.String(), "records", len(synthesized))
+	}
+	return synthesized
+}
+
+// dns64TTL returns the TTL of a record synthesized from an A record with
+// ttl, bounded by maxTTL unless it is zero
+func dns64TTL(ttl uint32, maxTTL time.Duration) uint32 {
+	if maxTTL <= 0 {
+		return ttl
+	}
+	return min(ttl, uint32(maxTTL/time.Second))
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// rrsigAnswers returns the stored RRSIG records covering the RRsets
+// answering question, for clients that set the DO bit (RFC 4035 §3.1.1).
+// The server does not sign: zones are served with the signatures they were
+// loaded with
+func (s *Server) rrsigAnswers(ctx context.Context, question message.DNSQuestion, answers []message.DNSAnswer, view string) []message.DNSAnswer {
+	// Signatures are answers themselves for RRSIG and ANY questions
+	if len(answers) == 0 || question.Qtype() == types.TYPE_RRSIG || question.Qtype() == types.TYPE_ANY {
+		return nil
+	}
+
+	name := s.lookupName(question.Name)
+	stored, err := s.lookupRecords(ctx, name.String(), types.TYPE_RRSIG, view)
+	if err != nil || len(stored) == 0 {
+		return nil
+	}
+
+	covered := make(map[types.DNSType]bool, 1)
+	for _, answer := range answers {
+		covered[answer.Type()] = true
+	}
+
+	var matching []records.DNSRecord
+	for _, record := range stored {
+		if rrsig, ok := rrsigOf(record); ok && covered[rrsig.TypeCovered()] {
+			matching = append(matching, record)
+		}
+	}
+
+	signatures, _ := s.recordsToAnswers(matching, question)
+	return signatures
+}
+
+// rrsigOf returns the RRSIG record behind record, unwrapping view records
+func rrsigOf(record records.DNSRecord) (*records.RRSIGRecord, bool) {
+	if wrapped, ok := record.(interface{ Unwrap() records.DNSRecord }); ok {
+		record = wrapped.Unwrap()
+	}
+	rrsig, ok := record.(*records.RRSIGRecord)
+	return rrsig, ok
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// signedZone returns the records of updateZone with a zone key and
+// signatures over the addresses of www.example.org. and the key
+func signedZone(t *testing.T) []records.DNSRecord {
+	t.Helper()
+	expiration := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	inception := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	key, err := records.NewDNSKEYRecord("example.org.", records.DNSKEYFlagZone|records.DNSKEYFlagSEP, 3, 13, []byte{0x01, 0x02, 0x03, 0x04}, 3600)
+	if err != nil {
+		t.Fatalf("failed to create DNSKEY: %v", err)
+	}
+	zone := append(updateZone(), key)
+	for _, signed := range []struct {
+		name        string
+		typeCovered types.DNSType
+		labels      uint8
+	}{
+		{"www.example.org.", types.TYPE_A, 3},
+		{"www.example.org.", types.TYPE_AAAA, 3},
+		{"example.org.", types.TYPE_DNSKEY, 2},
+	} {
+		rrsig, err := records.NewRRSIGRecord(signed.name, signed.typeCovered, 13, signed.labels, 300,
+			expiration, inception, key.KeyTag(), "example.org.", []byte{0xDE, 0xAD, 0xBE, 0xEF}, 300)
+		if err != nil {
+			t.Fatalf("failed to create RRSIG: %v", err)
+		}
+		zone = append(zone, rrsig)
+	}
+	return zone
+}
+
+func TestServer_DNSSECRecords(t *testing.T) {
+	s := newUDPTestServer(t, signedZone(t))
+	client := &net.UDPAddr{IP: net.ParseIP("192.0.2.7")}
+
+	tests := []struct {
+		name     string
+		qname    string
+		qtype    types.DNSType
+		dnssecOK bool
+		expected map[types.DNSType]int // Answer counts by type
+	}{
+		{name: "without DO", qname: "www.example.org.", qtype: types.TYPE_A, expected: map[types.DNSType]int{types.TYPE_A: 2}},
+		{name: "with DO", qname: "www.example.org.", qtype: types.TYPE_A, dnssecOK: true, expected: map[types.DNSType]int{types.TYPE_A: 2, types.TYPE_RRSIG: 1}},
+		{name: "key with DO", qname: "example.org.", qtype: types.TYPE_DNSKEY, dnssecOK: true, expected: map[types.DNSType]int{types.TYPE_DNSKEY: 1, types.TYPE_RRSIG: 1}},
+		{name: "unsigned RRset", qname: "example.org.", qtype: types.TYPE_NS, dnssecOK: true, expected: map[types.DNSType]int{types.TYPE_NS: 1}},
+		{name: "signatures", qname: "www.example.org.", qtype: types.TYPE_RRSIG, expected: map[types.DNSType]int{types.TYPE_RRSIG: 2}},
+		{name: "signatures with DO", qname: "www.example.org.", qtype: types.TYPE_RRSIG, dnssecOK: true, expected: map[types.DNSType]int{types.TYPE_RRSIG: 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := message.GenerateDNSQuery(1234, []message.DNSQuestion{
+				message.NewDNSQuestion(mustDomainName(tt.qname), tt.qtype, types.CLASS_IN),
+			})
+			if tt.dnssecOK {
+				query.AdditionalRecords = append(query.AdditionalRecords,
+					*message.NewDNSAnswerFromParts(utils.DomainName{}, types.TYPE_OPT, 1232, message.EDNSFlagDO, nil))
+				query.Header.AdditionalRecordCount++
+			}
+
+			response := exchange(t, s, query.ToBytes(), client)
+			counts := make(map[types.DNSType]int)
+			for _, answer := range response.Answers {
+				counts[answer.Type()]++
+				if answer.Type() != types.TYPE_RRSIG || tt.qtype == types.TYPE_RRSIG {
+					continue
+				}
+				// The type covered leads the RRSIG RDATA
+				if covered := types.DnsTypeClassFromBytes[types.DNSType]([2]byte(answer.Data())); covered != tt.qtype {
+					t.Errorf("RRSIG answer covers %s, expected %s", covered, tt.qtype)
+				}
+			}
+			if len(counts) != len(tt.expected) {
+				t.Fatalf("answers by type = %v, expected %v", counts, tt.expected)
+			}
+			for recordType, count := range tt.expected {
+				if counts[recordType] != count {
+					t.Errorf("answers by type = %v, expected %v", counts, tt.expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,307 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/resolver"
+	"github.com/vadim-su/dnska/internal/storage"
+)
+
+const (
+	// healthCheckTimeout bounds each check of a readiness probe
+	healthCheckTimeout = 2 * time.Second
+
+	healthStatusOK   = "ok"
+	healthStatusFail = "fail"
+)
+
+// healthCheck is the outcome of checking one component
+type healthCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// healthReport is the JSON body of the health endpoints
+type healthReport struct {
+	Status  string                 `json:"status"`
+	Failing []string               `json:"failing,omitempty"`
+	Checks  map[string]healthCheck `json:"checks"`
+}
+
+// statsReport is the JSON body of /stats
+type statsReport struct {
+	Server       serverStatsReport    `json:"server"`
+	Storage      *storageStatsReport  `json:"storage,omitempty"` // Absent when the storage keeps no statistics
+	StorageError string               `json:"storage_error,omitempty"`
+	Resolver     *resolverStatsReport `json:"resolver,omitempty"` // Absent without a resolver
+	Window       string               `json:"window"`
+	Queries      QueryStats           `json:"queries"`
+}
+
+type serverStatsReport struct {
+	Running        bool   `json:"running"`
+	Address        string `json:"address"`
+	Type           string `json:"type"`
+	BlockedQueries uint64 `json:"blocked_queries"`
+}
+
+type resolverStatsReport struct {
+	DeduplicatedQueries uint64                 `json:"deduplicated_queries"`            // Questions that joined an identical one in flight, since the resolver was built
+	CircuitBreakerState *resolver.CircuitState `json:"circuit_breaker_state,omitempty"` // 0 closed, 1 open, 2 half-open; absent without forward servers or breaker
+	Upstreams           []upstreamStatsReport  `json:"upstreams,omitempty"`             // Absent without forward servers or health tracking
+}
+
+// upstreamStatsReport is the health of a forward server
+type upstreamStatsReport struct {
+	Server              string `json:"server"`
+	State               string `json:"state"` // "up" or "down"
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	RecentSuccesses     int    `json:"recent_successes"` // Among the last 100 queries
+	RecentFailures      int    `json:"recent_failures"`
+	DownSince           int64  `json:"down_since,omitempty"` // Unix time, absent while up
+}
+
+type storageStatsReport struct {
+	TotalRecords  int            `json:"total_records"`
+	TotalZones    int            `json:"total_zones"`
+	RecordTypes   map[string]int `json:"record_types"`
+	LastUpdated   int64          `json:"last_updated"`
+	DroppedEvents uint64         `json:"dropped_events"`
+}
+
+// add records the result of checking component
+func (r *healthReport) add(component string, err error) {
+	if err == nil {
+		r.Checks[component] = healthCheck{Status: healthStatusOK}
+		return
+	}
+
+	r.Status = healthStatusFail
+	r.Failing = append(r.Failing, component)
+	r.Checks[component] = healthCheck{Status: healthStatusFail, Error: err.Error()}
+}
+
+// startHealth serves /healthz, /readyz, /stats, the zone validation API, the
+// zone file TTL policy and, when enabled, the record import and zone
+// snapshots over HTTP when health checks are enabled and an address is
+// configured
+func (s *Server) startHealth() error {
+	cfg := s.config.Server
+	if !cfg.EnableHealth || cfg.HealthAddress == "" {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", cfg.HealthAddress)
+	if err != nil {
+		return fmt.Errorf("failed to start health listener on %s: %w", cfg.HealthAddress, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	mux.HandleFunc("/stats", s.handleStats)
+	mux.HandleFunc("POST /api/v1/zones/{zone}/validate", s.handleValidateZone)
+	mux.HandleFunc("GET /config/ttl", s.handleTTLPolicy)
+	if cfg.RecordImport {
+		mux.HandleFunc("POST /api/v1/records/import", s.handleImportRecords)
+	}
+	if cfg.ZoneSnapshots {
+		mux.HandleFunc("POST /api/v1/zones/{zone}/snapshots", s.handleCreateSnapshot)
+		mux.HandleFunc("GET /api/v1/zones/{zone}/snapshots", s.handleListSnapshots)
+		mux.HandleFunc("POST /api/v1/zones/{zone}/snapshots/{id}/restore", s.handleRestoreSnapshot)
+		mux.HandleFunc("DELETE /api/v1/zones/{zone}/snapshots/{id}", s.handleDeleteSnapshot)
+	}
+
+	healthServer := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: healthCheckTimeout,
+	}
+
+	s.mu.Lock()
+	s.healthServer = healthServer
+	s.healthListener = listener
+	s.mu.Unlock()
+
+	go func() {
+		if err := healthServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Error("Health listener failed", "error", err)
+		}
+	}()
+
+	logger.Info("Health checks listening", "url", "http://"+listener.Addr().String())
+	return nil
+}
+
+// HealthAddress returns the address the health endpoints are bound to, or
+// an empty string when they are not served
+func (s *Server) HealthAddress() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.healthListener == nil {
+		return ""
+	}
+	return s.healthListener.Addr().String()
+}
+
+// handleHealthz reports the server alive while its DNS sockets are bound
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, s.livenessReport())
+}
+
+// handleReadyz reports the server ready to answer queries: its sockets are
+// bound, the storage responds and, when configured, so does the upstream
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	report := s.livenessReport()
+
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	s.componentsMu.RLock()
+	store, forwarder := s.storage, s.forwarder
+	s.componentsMu.RUnlock()
+
+	report.add("storage", store.Ping(ctx))
+
+	if s.config.Server.HealthUpstream && forwarder != nil {
+		report.add("upstream", forwarder.Probe(ctx))
+	}
+
+	writeHealthReport(w, report)
+}
+
+// handleStats reports the server, its storage and resolver and the queries
+// answered within the statistics window
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	serverStats := s.GetStats()
+	queryStats := s.QueryStats()
+	report := statsReport{
+		Server: serverStatsReport{
+			Running:        serverStats.Running,
+			Address:        serverStats.Address,
+			Type:           serverStats.Type,
+			BlockedQueries: serverStats.BlockedQueries,
+		},
+		Window:  queryStats.Window.String(),
+		Queries: queryStats,
+	}
+
+	s.componentsMu.RLock()
+	store, dnsResolver, forwarder := s.storage, s.resolver, s.forwarder
+	s.componentsMu.RUnlock()
+
+	if dnsResolver != nil {
+		report.Resolver = &resolverStatsReport{}
+		report.Resolver.DeduplicatedQueries, _ = resolver.DeduplicatedQueries(dnsResolver)
+		if forwarder != nil {
+			if state, ok := forwarder.CircuitState(); ok {
+				report.Resolver.CircuitBreakerState = &state
+			}
+			statuses, _ := forwarder.UpstreamStatuses()
+			for _, status := range statuses {
+				upstream := upstreamStatsReport{
+					Server:              status.Server,
+					State:               status.State.String(),
+					ConsecutiveFailures: status.ConsecutiveFailures,
+					RecentSuccesses:     status.RecentSuccesses,
+					RecentFailures:      status.RecentFailures,
+				}
+				if !status.DownSince.IsZero() {
+					upstream.DownSince = status.DownSince.Unix()
+				}
+				report.Resolver.Upstreams = append(report.Resolver.Upstreams, upstream)
+			}
+		}
+	}
+
+	if withStats, ok := store.(storage.StorageWithStats); ok {
+		ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+		defer cancel()
+
+		storageStats, err := withStats.GetStats(ctx)
+		if err != nil {
+			report.StorageError = err.Error()
+		} else {
+			report.Storage = &storageStatsReport{
+				TotalRecords:  storageStats.TotalRecords,
+				TotalZones:    storageStats.TotalZones,
+				RecordTypes:   storageStats.RecordTypes,
+				LastUpdated:   storageStats.LastUpdated,
+				DroppedEvents: storageStats.DroppedEvents,
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(report)
+}
+
+// livenessReport checks that a socket is bound for every enabled protocol
+func (s *Server) livenessReport() *healthReport {
+	report := &healthReport{
+		Status: healthStatusOK,
+		Checks: make(map[string]healthCheck),
+	}
+
+	s.mu.RLock()
+	udpBound := len(s.udpConns) > 0
+	tcpBound := len(s.tcpListeners) > 0
+	s.mu.RUnlock()
+
+	if s.config.Server.EnableUDP {
+		report.add("udp", boundError(udpBound))
+	}
+	if s.config.Server.EnableTCP {
+		report.add("tcp", boundError(tcpBound))
+	}
+
+	return report
+}
+
+// boundError returns an error unless a listener is bound
+func boundError(bound bool) error {
+	if !bound {
+		return fmt.Errorf("listener is not bound")
+	}
+	return nil
+}
+
+// writeHealthReport writes report as JSON with 200 when every check passed
+// and 503 otherwise
+func writeHealthReport(w http.ResponseWriter, report *healthReport) {
+	status := http.StatusOK
+	if report.Status != healthStatusOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// closeHealth stops serving the health endpoints
+func (s *Server) closeHealth() error {
+	s.mu.Lock()
+	healthServer := s.healthServer
+	s.healthServer = nil
+	s.healthListener = nil
+	s.mu.Unlock()
+
+	if healthServer == nil {
+		return nil
+	}
+	return healthServer.Close()
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/internal/hosts"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// newHosts loads the configured hosts files, nil when none are configured
+func newHosts(cfg config.HostsConfig) (*hosts.Hosts, error) {
+	if len(cfg.Files) == 0 {
+		return nil, nil
+	}
+
+	entries, err := hosts.New(cfg.Files)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Hosts files initialized", "names", entries.Len(), "files", cfg.Files)
+	return entries, nil
+}
+
+// hostsFile answers address questions for names listed in the hosts files
+// authoritatively, ahead of storage and the resolver. A listed name without
+// addresses of the asked family gets an empty NOERROR answer. Requests with
+// other questions are passed on
+func (s *Server) hostsFile(next Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+		request := query.Request
+		if request.Header.Flags.Opcode() != types.OPCODE_QUERY || len(request.Questions) == 0 {
+			return next.Handle(ctx, query)
+		}
+
+		var answers []message.DNSAnswer
+		for _, question := range request.Questions {
+			questionType := question.Qtype()
+			if questionType != types.TYPE_A && questionType != types.TYPE_AAAA {
+				return next.Handle(ctx, query)
+			}
+			addresses, listed := s.hosts.Lookup(question.Name.String())
+			if !listed {
+				return next.Handle(ctx, query)
+			}
+
+			ttl := uint32(s.config.Hosts.TTL.Seconds())
+			for _, ip := range addresses {
+				if (ip.To4() != nil) != (questionType == types.TYPE_A) {
+					continue
+				}
+				answer, err := message.NewAAnswer(question.Name, ip, ttl, question.Class)
+				if err != nil {
+					return nil, fmt.Errorf("failed to create hosts answer: %w", err)
+				}
+				answers = append(answers, *answer)
+			}
+		}
+
+		flags := types.NewFlagBuilder(message.PrepareResponseFlags(request.Header.Flags)).SetAA(true).Build()
+		response := message.NewResponseFor(request).
+			WithFlags(flags).
+			AddAnswer(answers...).
+			Build()
+		s.clampTTLs(response)
+		return response, nil
+	})
+}
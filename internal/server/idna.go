@@ -0,0 +1,24 @@
+package server
+
+import (
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// lookupName returns the name records of name are stored under: its ACE
+// form when IDNA normalization is configured, otherwise name itself. Names
+// without an ACE form are looked up as they are
+func (s *Server) lookupName(name utils.DomainName) utils.DomainName {
+	if !s.config.IDNA.Normalize {
+		return name
+	}
+
+	ace, err := utils.ToACE(name.String())
+	if err != nil {
+		return name
+	}
+	normalized, err := utils.ParseDomainName(ace)
+	if err != nil {
+		return name
+	}
+	return normalized
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/internal/zoneimport"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+)
+
+// recordImportReport is the JSON body of /api/v1/records/import
+type recordImportReport struct {
+	Imported int                 `json:"imported"`
+	Errors   []recordImportError `json:"errors,omitempty"`
+	Error    string              `json:"error,omitempty"` // Set when the records could not be stored
+}
+
+// recordImportError describes an invalid record of an import
+type recordImportError struct {
+	Row   int    `json:"row"`
+	Name  string `json:"name,omitempty"`
+	Error string `json:"error"`
+}
+
+// handleImportRecords stores the records in the body, a JSON array
+// (application/json) or CSV lines (text/csv, with header=present when the
+// first line names the columns). Records without a TTL take the one the
+// zones TTL policy gives their type. Nothing is stored unless every record
+// is valid: invalid ones are reported with 400, and 503 means the storage
+// failed
+func (s *Server) handleImportRecords(w http.ResponseWriter, r *http.Request) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		http.Error(w, "invalid content type: "+err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	s.componentsMu.RLock()
+	policy := newTTLPolicy(s.config.Zones)
+	store := s.storage
+	s.componentsMu.RUnlock()
+
+	var recordList []records.DNSRecord
+	switch mediaType {
+	case "application/json":
+		recordList, err = zoneimport.ImportJSON(r.Body, policy)
+	case "text/csv":
+		recordList, err = zoneimport.ImportCSV(r.Body, params["header"] == "present", policy)
+	default:
+		http.Error(w, "unsupported content type "+mediaType+", expected application/json or text/csv", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var rowErrors zoneimport.RowErrors
+	switch {
+	case errors.As(err, &rowErrors):
+		report := recordImportReport{}
+		for _, rowErr := range rowErrors {
+			report.Errors = append(report.Errors, recordImportError{Row: rowErr.Row, Name: rowErr.Name, Error: rowErr.Err.Error()})
+		}
+		writeImportReport(w, http.StatusBadRequest, report)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := store.BatchPutRecords(r.Context(), recordList); err != nil {
+		status := http.StatusServiceUnavailable
+		if errors.Is(err, storage.ErrInvalidRecord) || errors.Is(err, storage.ErrInvalidName) || errors.Is(err, storage.ErrInvalidTTL) {
+			status = http.StatusBadRequest
+		}
+		writeImportReport(w, status, recordImportReport{Error: err.Error()})
+		return
+	}
+
+	logger.Info("Imported records", "records", len(recordList), "client", r.RemoteAddr)
+	writeImportReport(w, http.StatusOK, recordImportReport{Imported: len(recordList)})
+}
+
+// writeImportReport sends report as the JSON body of a response with status
+func writeImportReport(w http.ResponseWriter, status int, report recordImportReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
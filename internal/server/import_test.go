@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+func TestServer_HandleImportRecords(t *testing.T) {
+	s := newUDPTestServer(t, nil)
+	s.config.Zones = config.ZonesConfig{
+		DefaultTTL: 10 * time.Minute,
+		TypeTTLs:   map[string]time.Duration{"MX": time.Hour},
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		imported    int
+		errors      int
+	}{
+		{
+			name:        "json",
+			contentType: "application/json",
+			body:        `[{"name":"www.example.com","type":"A","data":"192.0.2.1"},{"name":"example.com","type":"MX","data":"10 mail.example.com."}]`,
+			status:      http.StatusOK,
+			imported:    2,
+		},
+		{
+			name:        "csv",
+			contentType: "text/csv; header=present",
+			body:        "name,type,class,ttl,data\nmail.example.com,A,IN,60,192.0.2.25\n",
+			status:      http.StatusOK,
+			imported:    1,
+		},
+		{
+			name:        "invalid rows",
+			contentType: "text/csv",
+			body:        "ok.example.com,A,IN,60,192.0.2.2\nbad.example.com,A,IN,60,bad\n",
+			status:      http.StatusBadRequest,
+			errors:      1,
+		},
+		{
+			name:        "malformed json",
+			contentType: "application/json",
+			body:        `{"name":"www.example.com"}`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "unsupported type",
+			contentType: "text/plain",
+			body:        "www.example.com A 192.0.2.1",
+			status:      http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/v1/records/import", strings.NewReader(tt.body))
+			request.Header.Set("Content-Type", tt.contentType)
+			recorder := httptest.NewRecorder()
+			s.handleImportRecords(recorder, request)
+
+			if recorder.Code != tt.status {
+				t.Fatalf("status = %d, expected %d: %s", recorder.Code, tt.status, recorder.Body.String())
+			}
+			if recorder.Header().Get("Content-Type") != "application/json" {
+				return
+			}
+			var report recordImportReport
+			if err := json.NewDecoder(recorder.Body).Decode(&report); err != nil {
+				t.Fatalf("failed to decode report: %v", err)
+			}
+			if report.Imported != tt.imported || len(report.Errors) != tt.errors {
+				t.Errorf("report = %+v, expected %d imported and %d errors", report, tt.imported, tt.errors)
+			}
+		})
+	}
+
+	ctx := context.Background()
+	if mx, err := s.storage.GetRecord(ctx, "example.com.", types.TYPE_MX); err != nil || mx.TTL() != 3600 {
+		t.Errorf("GetRecord(example.com. MX) = %v, %v, expected the policy TTL 3600", mx, err)
+	}
+	if _, err := s.storage.GetRecord(ctx, "ok.example.com.", types.TYPE_A); err == nil {
+		t.Error("a record of an import with invalid rows was stored")
+	}
+}
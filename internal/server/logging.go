@@ -0,0 +1,33 @@
+package server
+
+import (
+	"log/slog"
+	"net"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/internal/logging"
+)
+
+// logger is the logger of the server component
+var logger = logging.For("server")
+
+// ConfigureLogging applies the logging section of the configuration to
+// every logger of the process
+func ConfigureLogging(cfg config.LoggingConfig) error {
+	return logging.Configure(logging.Config{
+		Level:      cfg.Level,
+		Format:     cfg.Format,
+		Output:     cfg.Output,
+		MaxSize:    int64(cfg.MaxSizeMB) << 20,
+		MaxBackups: cfg.MaxBackups,
+		Components: cfg.Components,
+	})
+}
+
+// clientAttr returns the address of the client a record is about
+func clientAttr(addr net.Addr) slog.Attr {
+	if addr == nil {
+		return slog.String("client", "")
+	}
+	return slog.String("client", addr.String())
+}
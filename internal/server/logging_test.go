@@ -0,0 +1,86 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/internal/logging"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+func TestConfigureLogging_QueryRecords(t *testing.T) {
+	answering := HandlerFunc(func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+		return message.NewResponseFor(query.Request).SetRcode(types.RCODE_NAME_ERROR).Build(), nil
+	})
+	s := &Server{config: config.DefaultConfig(), stats: newStatsCollector(0, 0)}
+	handler := Chain(answering, s.statsRecorder)
+
+	for _, level := range []string{"info", "debug"} {
+		t.Run(level, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "dnska.log")
+			cfg := config.DefaultConfig().Logging
+			cfg.Format = "json"
+			cfg.Output = path
+			cfg.Components = map[string]string{"server": level}
+			if err := ConfigureLogging(cfg); err != nil {
+				t.Fatalf("ConfigureLogging() returned error: %v", err)
+			}
+			t.Cleanup(func() { logging.Close() })
+
+			query := newTestQuery(t, "example.com")
+			query.ClientAddr = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 7), Port: 5353}
+			query.Received = time.Now()
+			if _, err := handler.Handle(context.Background(), query); err != nil {
+				t.Fatalf("Handle() returned error: %v", err)
+			}
+
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatalf("failed to open log file: %v", err)
+			}
+			defer file.Close()
+
+			var records []map[string]any
+			for scanner := bufio.NewScanner(file); scanner.Scan(); {
+				var record map[string]any
+				if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+					t.Fatalf("log line %q is not JSON: %v", scanner.Text(), err)
+				}
+				records = append(records, record)
+			}
+
+			if level == "info" {
+				if len(records) != 0 {
+					t.Errorf("logged %v at info level, want nothing", records)
+				}
+				return
+			}
+			if len(records) != 1 {
+				t.Fatalf("logged %d records, want 1", len(records))
+			}
+			record := records[0]
+			for key, want := range map[string]any{
+				"level":     "DEBUG",
+				"component": "server",
+				"client":    "192.0.2.7",
+				"qname":     "example.com.",
+				"rcode":     "NXDOMAIN",
+			} {
+				if got := record[key]; got != want {
+					t.Errorf("record[%q] = %v, want %v", key, got, want)
+				}
+			}
+			if _, ok := record["duration"]; !ok {
+				t.Error("record has no duration")
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"net"
+	"runtime/debug"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// QueryContext carries a request through the handler chain
+type QueryContext struct {
+	Request    *message.DNSRequest
+	ClientAddr net.Addr
+	View       string    // Split-horizon view of the client
+	Received   time.Time // When the request was read
+
+	values map[string]any
+}
+
+// Set stores per-request metadata under key
+func (q *QueryContext) Set(key string, value any) {
+	if q.values == nil {
+		q.values = make(map[string]any)
+	}
+	q.values[key] = value
+}
+
+// Value returns the metadata stored under key, or nil
+func (q *QueryContext) Value(key string) any {
+	return q.values[key]
+}
+
+// Handler answers queries. A handler in a chain either answers a query
+// itself or passes it on to the next handler
+type Handler interface {
+	Handle(ctx context.Context, query *QueryContext) (*message.DNSResponse, error)
+}
+
+// HandlerFunc adapts a function to the Handler interface
+type HandlerFunc func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error)
+
+// Handle calls f(ctx, query)
+func (f HandlerFunc) Handle(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+	return f(ctx, query)
+}
+
+// Middleware wraps a handler with behaviour applied to every query
+type Middleware func(next Handler) Handler
+
+// Chain wraps handler with middlewares. The first middleware is the
+// outermost one and sees every query first
+func Chain(handler Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// buildHandler builds the chain queries are answered by: query statistics,
+// panic recovery, DNS cookies, zone allow-query networks, TSIG signatures,
+// client subnets, secondary zones, zone transfers, dynamic updates, then
+// hosts files and the blocklist, then storage and the (caching) resolver
+func (s *Server) buildHandler() Handler {
+	middlewares := []Middleware{s.statsRecorder, s.recoverer}
+	if s.cookieIssuer != nil {
+		middlewares = append(middlewares, s.cookies)
+	}
+	if s.queryACL != nil && len(s.queryACL.zones) > 0 {
+		middlewares = append(middlewares, s.queryAccess)
+	}
+	if len(s.config.TSIG.Keys) > 0 {
+		middlewares = append(middlewares, s.signatures)
+	}
+	middlewares = append(middlewares, s.clientSubnets)
+	if s.secondaries != nil {
+		middlewares = append(middlewares, s.secondaryZones)
+	}
+	if s.config.Transfer.Enabled {
+		middlewares = append(middlewares, s.zoneTransfers)
+	}
+	if s.config.Update.Enabled {
+		middlewares = append(middlewares, s.updates)
+	}
+	if s.hosts != nil {
+		middlewares = append(middlewares, s.hostsFile)
+	}
+	if s.blocklist != nil {
+		middlewares = append(middlewares, s.blocker)
+	}
+
+	return Chain(HandlerFunc(func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+		return s.processRequest(ctx, query.Request, query.View)
+	}), middlewares...)
+}
+
+// newQueryContext prepares a parsed request of the client at clientAddr for
+// the handler chain. The view is that of the client subnet of the request,
+// if it has one
+func (s *Server) newQueryContext(request *message.DNSRequest, clientAddr net.Addr) *QueryContext {
+	return &QueryContext{
+		Request:    request,
+		ClientAddr: clientAddr,
+		View:       s.clientView(subnetAddr(request, clientAddr)),
+		Received:   time.Now(),
+	}
+}
+
+// recoverer answers SERVFAIL instead of crashing the server when a handler
+// further down the chain panics
+func (s *Server) recoverer(next Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, query *QueryContext) (response *message.DNSResponse, err error) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				logger.Error("Recovered from panic answering query", clientAttr(query.ClientAddr), "panic", recovered, "stack", string(debug.Stack()))
+				response, err = s.createErrorResponse(query.Request, types.RCODE_SERVER_FAILURE), nil
+			}
+		}()
+
+		return next.Handle(ctx, query)
+	})
+}
+
+// blocker answers requests asking for blocked names from the blocklist
+// without passing them on. Other questions of such a request stay
+// unanswered
+func (s *Server) blocker(next Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+		var blocked []message.DNSQuestion
+		for _, question := range query.Request.Questions {
+			if s.blocklist.Blocked(question.Name.String()) {
+				blocked = append(blocked, question)
+			}
+		}
+		if len(blocked) == 0 {
+			return next.Handle(ctx, query)
+		}
+
+		query.Set("blocked", true)
+
+		var answers []message.DNSAnswer
+		for _, question := range blocked {
+			questionType := question.Qtype()
+			questionAnswers, err := s.blockedAnswers(question, questionType)
+			if err != nil {
+				return nil, err
+			}
+			answers = append(answers, questionAnswers...)
+		}
+
+		return s.buildResponse(query.Request, answers, nil, nil), nil
+	})
+}
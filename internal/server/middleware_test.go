@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vadim-su/dnska/internal/blocklist"
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+func newTestQuery(t *testing.T, name string) *QueryContext {
+	t.Helper()
+
+	domainName, err := utils.NewDomainNameFromString(name)
+	if err != nil {
+		t.Fatalf("invalid domain name %q: %v", name, err)
+	}
+
+	query := message.GenerateDNSQuery(1234, []message.DNSQuestion{message.NewDNSQuestion(*domainName, types.TYPE_A, types.CLASS_IN)})
+
+	request, err := message.NewDNSRequest(query.ToBytes())
+	if err != nil {
+		t.Fatalf("failed to parse request: %v", err)
+	}
+	return &QueryContext{Request: request}
+}
+
+// recording returns a middleware appending name to calls before passing
+// the query on
+func recording(calls *[]string, name string) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+			*calls = append(*calls, name)
+			return next.Handle(ctx, query)
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	s := &Server{config: config.DefaultConfig()}
+
+	var calls []string
+	answer := HandlerFunc(func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+		calls = append(calls, "handler")
+		return s.buildResponse(query.Request, nil, nil, nil), nil
+	})
+
+	shortCircuit := func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+			calls = append(calls, "short-circuit")
+			return s.createErrorResponse(query.Request, types.RCODE_REFUSED), nil
+		})
+	}
+
+	tests := []struct {
+		name          string
+		middlewares   []Middleware
+		expectedCalls []string
+		expectedRcode types.DNSRCode
+	}{
+		{
+			name:          "no middlewares",
+			expectedCalls: []string{"handler"},
+			expectedRcode: types.RCODE_NAME_ERROR,
+		},
+		{
+			name:          "outermost first",
+			middlewares:   []Middleware{recording(&calls, "first"), recording(&calls, "second")},
+			expectedCalls: []string{"first", "second", "handler"},
+			expectedRcode: types.RCODE_NAME_ERROR,
+		},
+		{
+			name:          "short-circuit skips the rest of the chain",
+			middlewares:   []Middleware{recording(&calls, "first"), shortCircuit, recording(&calls, "second")},
+			expectedCalls: []string{"first", "short-circuit"},
+			expectedRcode: types.RCODE_REFUSED,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+
+			response, err := Chain(answer, tt.middlewares...).Handle(context.Background(), newTestQuery(t, "example.com"))
+			if err != nil {
+				t.Fatalf("Handle() returned error: %v", err)
+			}
+
+			if !reflect.DeepEqual(calls, tt.expectedCalls) {
+				t.Errorf("calls = %v, expected %v", calls, tt.expectedCalls)
+			}
+			if rcode := response.Header.Flags.Rcode(); rcode != tt.expectedRcode {
+				t.Errorf("rcode = %s, expected %s", rcode, tt.expectedRcode)
+			}
+		})
+	}
+}
+
+func TestRecoverer(t *testing.T) {
+	s := &Server{config: config.DefaultConfig()}
+	panicking := HandlerFunc(func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+		panic("broken handler")
+	})
+
+	response, err := Chain(panicking, s.recoverer).Handle(context.Background(), newTestQuery(t, "example.com"))
+	if err != nil {
+		t.Fatalf("Handle() returned error: %v", err)
+	}
+
+	if rcode := response.Header.Flags.Rcode(); rcode != types.RCODE_SERVER_FAILURE {
+		t.Errorf("rcode = %s, expected SERVFAIL", rcode)
+	}
+	if response.Header.ID != 1234 {
+		t.Errorf("response ID = %d, expected 1234", response.Header.ID)
+	}
+}
+
+func TestBlocker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte("ads.example.com\n"), 0o600); err != nil {
+		t.Fatalf("failed to write blocklist: %v", err)
+	}
+	list, err := blocklist.New([]string{path})
+	if err != nil {
+		t.Fatalf("failed to load blocklist: %v", err)
+	}
+
+	s := &Server{config: config.DefaultConfig(), blocklist: list}
+
+	passed := false
+	next := HandlerFunc(func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+		passed = true
+		return s.createErrorResponse(query.Request, types.RCODE_REFUSED), nil
+	})
+	handler := Chain(next, s.blocker)
+
+	tests := []struct {
+		name          string
+		query         string
+		expectPassed  bool
+		expectedRcode types.DNSRCode
+	}{
+		{name: "blocked name", query: "ads.example.com", expectPassed: false, expectedRcode: types.RCODE_NAME_ERROR},
+		{name: "allowed name", query: "www.example.com", expectPassed: true, expectedRcode: types.RCODE_REFUSED},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passed = false
+			query := newTestQuery(t, tt.query)
+
+			response, err := handler.Handle(context.Background(), query)
+			if err != nil {
+				t.Fatalf("Handle() returned error: %v", err)
+			}
+
+			if passed != tt.expectPassed {
+				t.Errorf("query passed on = %v, expected %v", passed, tt.expectPassed)
+			}
+			if rcode := response.Header.Flags.Rcode(); rcode != tt.expectedRcode {
+				t.Errorf("rcode = %s, expected %s", rcode, tt.expectedRcode)
+			}
+			if blocked := query.Value("blocked") != nil; blocked == tt.expectPassed {
+				t.Errorf("blocked metadata set = %v, expected %v", blocked, !tt.expectPassed)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// errNotifyRejected reports a secondary answering a NOTIFY with an error,
+// which sending it again would not change
+var errNotifyRejected = errors.New("NOTIFY rejected")
+
+// notifier sends NOTIFY messages (RFC 1996) to the secondaries of stored
+// zones when their records change. The changes of a zone within the delay
+// are announced by a single NOTIFY
+type notifier struct {
+	zones       map[string][]string // Lowercased zone names with a trailing dot to their secondaries
+	delay       time.Duration
+	timeout     time.Duration
+	maxAttempts int
+	lookupSOA   func(ctx context.Context, zone string) []message.DNSAnswer
+
+	mu      sync.Mutex
+	pending map[string]bool // Zones with a NOTIFY scheduled
+}
+
+// newNotifier returns a notifier for the configured zones, or nil when no
+// zone has secondaries. lookupSOA returns the SOA records sent along with
+// the NOTIFY of a zone
+func newNotifier(cfg config.NotifyConfig, lookupSOA func(ctx context.Context, zone string) []message.DNSAnswer) *notifier {
+	zones := make(map[string][]string, len(cfg.Zones))
+	for _, zone := range cfg.Zones {
+		if len(zone.Secondaries) > 0 {
+			zones[normalizeZone(zone.Zone)] = zone.Secondaries
+		}
+	}
+	if len(zones) == 0 {
+		return nil
+	}
+
+	return &notifier{
+		zones:       zones,
+		delay:       cfg.Delay,
+		timeout:     cfg.Timeout,
+		maxAttempts: max(cfg.MaxAttempts, 1),
+		lookupSOA:   lookupSOA,
+		pending:     make(map[string]bool),
+	}
+}
+
+// normalizeZone returns the canonical form of a zone name: lowercased with a
+// trailing dot. Names that do not parse are lowercased as they are
+func normalizeZone(zone string) string {
+	name, err := utils.ParseDomainName(zone)
+	if err != nil {
+		zone = strings.ToLower(zone)
+		if !strings.HasSuffix(zone, ".") {
+			zone += "."
+		}
+		return zone
+	}
+	return name.CanonicalString()
+}
+
+// watchNotify sends NOTIFY messages for the changes of the current storage
+// to the configured secondaries
+func (s *Server) watchNotify(ctx context.Context) {
+	n := newNotifier(s.config.Notify, s.zoneSOA)
+	if n == nil {
+		return
+	}
+
+	events, err := s.storage.Subscribe(ctx)
+	if err != nil {
+		logger.Warn("Storage change notifications unavailable, secondaries are not notified of changes", "error", err)
+		return
+	}
+
+	go n.watch(ctx, events)
+}
+
+// zoneSOA returns the stored SOA records of zone as answers
+func (s *Server) zoneSOA(ctx context.Context, zone string) []message.DNSAnswer {
+	s.componentsMu.RLock()
+	defer s.componentsMu.RUnlock()
+
+	soa, err := s.lookupRecords(ctx, zone, types.TYPE_SOA, storage.DefaultView)
+	if err != nil {
+		return nil
+	}
+	return recordAnswers(soa)
+}
+
+// watch schedules a NOTIFY for every change reported on events that falls
+// in a configured zone, until events is closed
+func (n *notifier) watch(ctx context.Context, events <-chan storage.ChangeEvent) {
+	for event := range events {
+		if zone, ok := n.zoneOf(event.Name); ok {
+			n.schedule(ctx, zone)
+		}
+	}
+}
+
+// zoneOf returns the closest configured zone enclosing name
+func (n *notifier) zoneOf(name string) (string, bool) {
+	return closestZone(name, func(zone string) bool {
+		_, ok := n.zones[zone]
+		return ok
+	})
+}
+
+// closestZone returns the closest zone enclosing name for which known
+// reports true. Zones are passed to known normalized
+func closestZone(name string, known func(zone string) bool) (string, bool) {
+	name = normalizeZone(name)
+	for {
+		if known(name) {
+			return name, true
+		}
+		if name == "." {
+			return "", false
+		}
+		_, parent, found := strings.Cut(name, ".")
+		if !found || parent == "" {
+			parent = "."
+		}
+		name = parent
+	}
+}
+
+// schedule sends a NOTIFY for zone once the delay passed, unless one is
+// already scheduled. A change made while a NOTIFY is being sent schedules
+// another one, so secondaries never miss the last change
+func (n *notifier) schedule(ctx context.Context, zone string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.pending[zone] {
+		return
+	}
+	n.pending[zone] = true
+
+	time.AfterFunc(n.delay, func() {
+		n.mu.Lock()
+		delete(n.pending, zone)
+		n.mu.Unlock()
+
+		if ctx.Err() == nil {
+			n.notifyZone(ctx, zone)
+		}
+	})
+}
+
+// notifyZone sends a NOTIFY for zone to each of its secondaries
+func (n *notifier) notifyZone(ctx context.Context, zone string) {
+	name, err := utils.NewDomainNameFromString(zone)
+	if err != nil {
+		logger.Error("Failed to notify secondaries", "zone", zone, "error", err)
+		return
+	}
+	soa := n.lookupSOA(ctx, zone)
+
+	var wg sync.WaitGroup
+	for _, secondary := range n.zones[zone] {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := n.notify(ctx, *name, soa, secondary); err != nil {
+				logger.Warn("Failed to notify secondary of changes", "secondary", secondary, "zone", zone, "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// notify sends a NOTIFY for zone to secondary until it is answered, waiting
+// twice as long for the response to each retry
+func (n *notifier) notify(ctx context.Context, zone utils.DomainName, soa []message.DNSAnswer, secondary string) error {
+	timeout := n.timeout
+	for attempt := 1; ; attempt++ {
+		query := message.GenerateNotify(uint16(rand.Uint32()), zone, soa)
+		err := sendNotify(ctx, query, secondary, timeout)
+		if err == nil || errors.Is(err, errNotifyRejected) {
+			return err
+		}
+
+		if attempt >= n.maxAttempts || ctx.Err() != nil {
+			return fmt.Errorf("no response after %d attempts: %w", attempt, err)
+		}
+		timeout *= 2
+	}
+}
+
+// sendNotify sends query to secondary and waits up to timeout for the
+// response carrying its ID, opcode and question
+func sendNotify(ctx context.Context, query *message.DNSResponse, secondary string, timeout time.Duration) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", secondary)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	if _, err := conn.Write(query.ToBytes()); err != nil {
+		return err
+	}
+
+	buffer := make([]byte, maxBufferSize)
+	for {
+		size, err := conn.Read(buffer)
+		if err != nil {
+			return err
+		}
+
+		response, err := message.NewDNSResponse(buffer[:size])
+		if err != nil || !answersNotify(query, response) {
+			continue
+		}
+
+		if rcode := response.Header.Flags.Rcode(); rcode != types.RCODE_NO_ERROR {
+			return fmt.Errorf("%w with %s", errNotifyRejected, rcode)
+		}
+		return nil
+	}
+}
+
+// answersNotify reports whether response is the response to the NOTIFY query
+func answersNotify(query, response *message.DNSResponse) bool {
+	if response.Header.ID != query.Header.ID || !response.Header.Flags.IsResponse() {
+		return false
+	}
+	if response.Header.Flags.Opcode() != types.OPCODE_NOTIFY {
+		return false
+	}
+	if len(response.Questions) != 1 {
+		return false
+	}
+
+	question := response.Questions[0]
+	return question.Qtype() == query.Questions[0].Qtype() && question.Name.Equal(query.Questions[0].Name)
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// fakeSecondary records the NOTIFY messages it receives and answers them
+// once it has ignored the first drop of them
+type fakeSecondary struct {
+	conn  net.PacketConn
+	drop  int
+	rcode types.DNSRCode
+
+	mu       sync.Mutex
+	received []*message.DNSResponse
+}
+
+func startFakeSecondary(t *testing.T, drop int, rcode types.DNSRCode) *fakeSecondary {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start fake secondary: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	secondary := &fakeSecondary{conn: conn, drop: drop, rcode: rcode}
+	go secondary.serve()
+	return secondary
+}
+
+func (f *fakeSecondary) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := f.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		query, err := message.NewDNSResponse(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		f.mu.Lock()
+		f.received = append(f.received, query)
+		answer := len(f.received) > f.drop
+		f.mu.Unlock()
+
+		if answer {
+			response := message.NewResponse(query.Header.ID).
+				WithFlags(types.NewFlagBuilder(query.Header.Flags).SetQR(true).Build()).
+				AddQuestion(query.Questions...).
+				SetRcode(f.rcode).
+				Build()
+			f.conn.WriteTo(response.ToBytes(), addr)
+		}
+	}
+}
+
+func (f *fakeSecondary) messages() []*message.DNSResponse {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*message.DNSResponse(nil), f.received...)
+}
+
+func (f *fakeSecondary) address() string {
+	return f.conn.LocalAddr().String()
+}
+
+func newTestNotifier(secondaries []string, maxAttempts int) *notifier {
+	soa := message.NewSOAAnswer(mustDomainName("example.com"), message.SOARData{
+		MNAME:  mustDomainName("ns1.example.com"),
+		RNAME:  mustDomainName("hostmaster.example.com"),
+		Serial: 2024010102,
+	}, 3600)
+
+	return newNotifier(config.NotifyConfig{
+		Zones:       []config.NotifyZoneConfig{{Zone: "Example.com", Secondaries: secondaries}},
+		Delay:       50 * time.Millisecond,
+		Timeout:     50 * time.Millisecond,
+		MaxAttempts: maxAttempts,
+	}, func(ctx context.Context, zone string) []message.DNSAnswer {
+		return []message.DNSAnswer{*soa}
+	})
+}
+
+func mustDomainName(name string) utils.DomainName {
+	domainName, err := utils.NewDomainNameFromString(name)
+	if err != nil {
+		panic(err)
+	}
+	return *domainName
+}
+
+func TestNotifier_SendsNotify(t *testing.T) {
+	secondary := startFakeSecondary(t, 0, types.RCODE_NO_ERROR)
+	n := newTestNotifier([]string{secondary.address()}, 3)
+
+	if err := n.notify(context.Background(), mustDomainName("example.com"), n.lookupSOA(context.Background(), "example.com."), secondary.address()); err != nil {
+		t.Fatalf("notify() returned error: %v", err)
+	}
+
+	received := secondary.messages()
+	if len(received) != 1 {
+		t.Fatalf("secondary received %d messages, expected 1", len(received))
+	}
+
+	notify := received[0]
+	if opcode := notify.Header.Flags.Opcode(); opcode != types.OPCODE_NOTIFY {
+		t.Errorf("opcode = %s, expected NOTIFY", opcode)
+	}
+	if notify.Header.Flags.IsResponse() || !notify.Header.Flags.IsAuthoritative() {
+		t.Errorf("unexpected flags %s", notify.Header.Flags)
+	}
+	if len(notify.Questions) != 1 || notify.Questions[0].Name.String() != "example.com." ||
+		notify.Questions[0].Qtype() != types.TYPE_SOA {
+		t.Errorf("unexpected questions %v", notify.Questions)
+	}
+	if len(notify.Answers) != 1 || notify.Answers[0].Type() != types.TYPE_SOA {
+		t.Errorf("expected the SOA in the answer section, got %v", notify.Answers)
+	}
+}
+
+func TestNotifier_Retries(t *testing.T) {
+	tests := []struct {
+		name        string
+		drop        int
+		rcode       types.DNSRCode
+		expectedErr string
+		expectedN   int
+	}{
+		{name: "answered after two retries", drop: 2, expectedN: 3},
+		{name: "never answered", drop: 10, expectedErr: "no response after 3 attempts", expectedN: 3},
+		{name: "rejected", rcode: types.RCODE_NOT_IMPLEMENTED, expectedErr: "NOTIFY rejected with NOTIMP", expectedN: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secondary := startFakeSecondary(t, tt.drop, tt.rcode)
+			n := newTestNotifier([]string{secondary.address()}, 3)
+
+			start := time.Now()
+			err := n.notify(context.Background(), mustDomainName("example.com"), nil, secondary.address())
+			elapsed := time.Since(start)
+
+			if tt.expectedErr == "" && err != nil {
+				t.Fatalf("notify() returned error: %v", err)
+			}
+			if tt.expectedErr != "" && (err == nil || !strings.Contains(err.Error(), tt.expectedErr)) {
+				t.Fatalf("notify() error = %v, expected it to contain %q", err, tt.expectedErr)
+			}
+
+			received := secondary.messages()
+			if len(received) != tt.expectedN {
+				t.Fatalf("secondary received %d messages, expected %d", len(received), tt.expectedN)
+			}
+			// Retries carry fresh IDs
+			if len(received) == 3 && received[0].Header.ID == received[1].Header.ID && received[1].Header.ID == received[2].Header.ID {
+				t.Error("retries reuse the message ID")
+			}
+
+			// Waits of 50ms and 100ms precede the third attempt
+			if tt.expectedN == 3 && elapsed < 150*time.Millisecond {
+				t.Errorf("three attempts took %v, expected the timeout to double", elapsed)
+			}
+		})
+	}
+}
+
+func TestNotifier_CoalescesChanges(t *testing.T) {
+	secondary := startFakeSecondary(t, 0, types.RCODE_NO_ERROR)
+	other := startFakeSecondary(t, 0, types.RCODE_NO_ERROR)
+	n := newTestNotifier([]string{secondary.address(), other.address()}, 3)
+
+	events := make(chan storage.ChangeEvent)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.watch(ctx, events)
+
+	// A burst of changes in the zone and one outside it
+	for _, name := range []string{"www.example.com.", "mail.example.com.", "example.com.", "WWW.Example.com.", "example.org."} {
+		events <- storage.ChangeEvent{Operation: storage.ChangeOperationPut, Name: name, RecordType: types.TYPE_A}
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	for _, s := range []*fakeSecondary{secondary, other} {
+		if received := s.messages(); len(received) != 1 {
+			t.Errorf("secondary %s received %d messages, expected 1", s.address(), len(received))
+		}
+	}
+
+	// A later change is announced again
+	events <- storage.ChangeEvent{Operation: storage.ChangeOperationDelete, Name: "www.example.com.", RecordType: types.TYPE_A}
+	time.Sleep(200 * time.Millisecond)
+	close(events)
+
+	if received := secondary.messages(); len(received) != 2 {
+		t.Errorf("secondary received %d messages after a second change, expected 2", len(received))
+	}
+}
+
+func TestNotifier_ZoneOf(t *testing.T) {
+	n := newNotifier(config.NotifyConfig{Zones: []config.NotifyZoneConfig{
+		{Zone: "example.com", Secondaries: []string{"192.0.2.53:53"}},
+		{Zone: "sub.example.com.", Secondaries: []string{"192.0.2.54:53"}},
+	}}, nil)
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{name: "example.com.", expected: "example.com."},
+		{name: "www.example.com", expected: "example.com."},
+		{name: "a.b.SUB.example.com.", expected: "sub.example.com."},
+		{name: "example.org.", expected: ""},
+		{name: "com.", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zone, _ := n.zoneOf(tt.name)
+			if zone != tt.expected {
+				t.Errorf("zoneOf(%q) = %q, want %q", tt.name, zone, tt.expected)
+			}
+		})
+	}
+
+	if newNotifier(config.NotifyConfig{}, nil) != nil {
+		t.Error("newNotifier() returned a notifier without zones")
+	}
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// Reload applies cfg to the running server. Only the sections that changed
+// are applied: storage is reconnected, the resolver and its cache are
+// rebuilt, and zone files, DNS64, views, blocklists, hosts files, NOTIFY
+// secondaries, secondary zones, DNS cookies and the response rate limiter
+// are reloaded. Changes to the server section, such as listen addresses,
+// take effect on restart.
+//
+// Every new component is built before any of them is put in use, so when
+// one fails the server keeps running with the previous configuration.
+// Queries being answered finish with the components they started with
+func (s *Server) Reload(cfg *config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	s.mu.RLock()
+	closed := s.closed
+	s.mu.RUnlock()
+	if closed {
+		return errors.New("server is closed")
+	}
+
+	diff := s.config.Diff(cfg)
+	if diff.Empty() {
+		logger.Info("Configuration unchanged, nothing to reload")
+		return nil
+	}
+
+	next := *s.config
+	var built []func() error // Closes the components built so far
+	fail := func(err error) error {
+		for _, closeComponent := range built {
+			closeComponent()
+		}
+		return fmt.Errorf("failed to reload configuration, keeping the previous one: %w", err)
+	}
+
+	store := s.storage
+	if diff.Storage {
+		next.Storage = cfg.Storage
+		newStore, err := s.newStorage(next.Storage)
+		if err != nil {
+			return fail(err)
+		}
+		store = newStore
+		built = append(built, newStore.Close)
+	}
+
+	resolver, forwarder := s.resolver, s.forwarder
+	if diff.Resolver || diff.Cache {
+		next.Resolver = cfg.Resolver
+		next.Cache = cfg.Cache
+		newResolver, newForwarder, err := newResolver(&next)
+		if err != nil {
+			return fail(err)
+		}
+		resolver, forwarder = newResolver, newForwarder
+		built = append(built, newResolver.Close)
+	}
+
+	dns64Prefix := s.dns64Prefix
+	if diff.DNS64 {
+		next.DNS64 = cfg.DNS64
+		dns64Prefix = nil
+		if next.DNS64.Prefix != "" {
+			prefix, err := utils.ParseDNS64Prefix(next.DNS64.Prefix)
+			if err != nil {
+				return fail(err)
+			}
+			dns64Prefix = prefix
+		}
+	}
+
+	views := s.views
+	if diff.Views {
+		next.Views = cfg.Views
+		newViews, err := newViews(next.Views)
+		if err != nil {
+			return fail(err)
+		}
+		views = newViews
+	}
+
+	list := s.blocklist
+	if diff.Blocklist {
+		next.Blocklist = cfg.Blocklist
+		newList, err := newBlocklist(next.Blocklist)
+		if err != nil {
+			return fail(err)
+		}
+		list = newList
+	}
+
+	entries := s.hosts
+	if diff.Hosts {
+		next.Hosts = cfg.Hosts
+		newEntries, err := newHosts(next.Hosts)
+		if err != nil {
+			return fail(err)
+		}
+		entries = newEntries
+	}
+
+	if diff.Logging {
+		next.Logging = cfg.Logging
+	}
+	if diff.Responses {
+		next.Responses = cfg.Responses
+	}
+	if diff.Notify {
+		next.Notify = cfg.Notify
+	}
+
+	if diff.IDNA {
+		next.IDNA = cfg.IDNA
+	}
+	if diff.Transfer {
+		next.Transfer = cfg.Transfer
+	}
+	if diff.Update {
+		next.Update = cfg.Update
+	}
+	issuer := s.cookieIssuer
+	if diff.EDNS {
+		next.EDNS = cfg.EDNS
+		// A running issuer keeps its secrets, so issued cookies stay valid
+		if !next.EDNS.Cookies.Enabled {
+			issuer = nil
+		} else if issuer == nil {
+			newIssuer, err := newCookieIssuer(next.EDNS.Cookies)
+			if err != nil {
+				return fail(err)
+			}
+			issuer = newIssuer
+		}
+	}
+
+	limiter := s.limiter
+	if diff.RRL {
+		next.RRL = cfg.RRL
+		limiter = newLimiter(next.RRL)
+	}
+
+	// Secondary zones sign their queries with the TSIG keys
+	zones := s.secondaries
+	if diff.Secondary || diff.TSIG {
+		next.Secondary = cfg.Secondary
+		next.TSIG = cfg.TSIG
+		zones = newSecondaries(next.Secondary, next.TSIG)
+	}
+
+	// Zone files are loaded last, as the current storage serves them at
+	// once. A new storage is given them too
+	if diff.Storage || diff.Zones {
+		next.Zones = cfg.Zones
+		if err := loadZoneFiles(s.ctx, store, next.Zones); err != nil {
+			return fail(err)
+		}
+	}
+
+	oldStore, oldResolver := s.storage, s.resolver
+
+	// The server section is read without the lock and never reloaded
+	s.componentsMu.Lock()
+	s.config.Resolver = next.Resolver
+	s.config.Storage = next.Storage
+	s.config.Logging = next.Logging
+	s.config.Cache = next.Cache
+	s.config.DNS64 = next.DNS64
+	s.config.Views = next.Views
+	s.config.Blocklist = next.Blocklist
+	s.config.Hosts = next.Hosts
+	s.config.Responses = next.Responses
+	s.config.Notify = next.Notify
+	s.config.Secondary = next.Secondary
+	s.config.IDNA = next.IDNA
+	s.config.TSIG = next.TSIG
+	s.config.Transfer = next.Transfer
+	s.config.Update = next.Update
+	s.config.EDNS = next.EDNS
+	s.config.RRL = next.RRL
+	s.config.Zones = next.Zones
+	s.storage = store
+	s.resolver, s.forwarder = resolver, forwarder
+	s.dns64Prefix = dns64Prefix
+	s.views = views
+	s.blocklist = list
+	s.hosts = entries
+	s.secondaries = zones
+	s.limiter = limiter
+	s.cookieIssuer = issuer
+	s.handler = s.buildHandler()
+	s.componentsMu.Unlock()
+
+	s.startWatchers()
+
+	if diff.Logging {
+		if err := ConfigureLogging(next.Logging); err != nil {
+			logger.Error("Failed to apply logging configuration", "error", err)
+		}
+	}
+
+	for _, section := range diff.Sections() {
+		if section == "server" {
+			logger.Warn("Configuration section changed, restart to apply it", "section", section)
+			continue
+		}
+		logger.Info("Reloaded configuration section", "section", section)
+	}
+
+	if oldResolver != resolver {
+		if err := oldResolver.Close(); err != nil {
+			logger.Error("Failed to close previous resolver", "error", err)
+		}
+	}
+	if oldStore != store {
+		if err := oldStore.Close(); err != nil {
+			logger.Error("Failed to close previous storage", "error", err)
+		}
+	}
+
+	return nil
+}
+
+// startWatchers starts the background work of the current storage, resolver,
+// blocklist, hosts files, notifier and secondary zones, stopping that of the components they replaced
+func (s *Server) startWatchers() {
+	if s.stopWatchers != nil {
+		s.stopWatchers()
+	}
+
+	var ctx context.Context
+	ctx, s.stopWatchers = context.WithCancel(s.ctx)
+
+	s.watchStorage(ctx)
+	s.watchNotify(ctx)
+	s.watchSecondaries(ctx)
+
+	if s.blocklist != nil && s.config.Blocklist.ReloadInterval > 0 {
+		go s.blocklist.Watch(ctx, s.config.Blocklist.ReloadInterval)
+	}
+	if s.hosts != nil && s.config.Hosts.ReloadInterval > 0 {
+		go s.hosts.Watch(ctx, s.config.Hosts.ReloadInterval)
+	}
+	if s.cookieIssuer != nil {
+		go s.cookieIssuer.Watch(ctx, s.config.EDNS.Cookies.SecretRotation)
+	}
+}
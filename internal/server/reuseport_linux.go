@@ -0,0 +1,22 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le
+
+package server
+
+import "syscall"
+
+// soReusePort is SO_REUSEPORT on Linux, which the frozen syscall package
+// does not define for every architecture
+const soReusePort = 0xf
+
+// setReusePort enables SO_REUSEPORT so several sockets can bind the same
+// address and have the kernel spread incoming packets across them
+func setReusePort(network, address string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
@@ -0,0 +1,14 @@
+//go:build !linux || mips || mipsle || mips64 || mips64le
+
+package server
+
+import (
+	"errors"
+	"syscall"
+)
+
+// setReusePort reports that SO_REUSEPORT is unavailable. Only Linux spreads
+// packets for one UDP address across sockets, elsewhere a single socket is used
+func setReusePort(network, address string, c syscall.RawConn) error {
+	return errors.New("SO_REUSEPORT is not supported on this platform")
+}
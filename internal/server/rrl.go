@@ -0,0 +1,36 @@
+package server
+
+import (
+	"net"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/internal/rrl"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+)
+
+// newLimiter creates the response rate limiter, nil when limiting is disabled
+func newLimiter(cfg config.RRLConfig) *rrl.Limiter {
+	if cfg.ResponsesPerSecond <= 0 {
+		return nil
+	}
+
+	logger.Info("Response rate limiting initialized", "responses_per_second", cfg.ResponsesPerSecond, "slip", cfg.SlipRate)
+	return rrl.New(cfg.ResponsesPerSecond, cfg.SlipRate, cfg.WindowSize)
+}
+
+// limitResponse applies response rate limiting to a UDP response to the
+// client at clientAddr. It returns the response to send, nil to drop it
+func limitResponse(limiter *rrl.Limiter, response *message.DNSResponse, clientAddr *net.UDPAddr) *message.DNSResponse {
+	if limiter == nil {
+		return response
+	}
+
+	switch limiter.Check(clientAddr.IP) {
+	case rrl.Slip:
+		// Stripped of all records, the response makes the client retry over TCP
+		return response.TruncateTo(0)
+	case rrl.Drop:
+		return nil
+	}
+	return response
+}
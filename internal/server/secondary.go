@@ -0,0 +1,547 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/tsig"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// defaultSecondaryRetry is the wait before retrying a zone that was never
+// transferred and thus has no SOA retry interval
+const defaultSecondaryRetry = time.Minute
+
+// secondaries keeps the configured secondary zones in sync with their
+// primaries: a zone is transferred (AXFR) whenever the serial of the
+// primary's SOA is newer than the stored one, checked every SOA refresh
+// interval and on NOTIFY from the primary
+type secondaries struct {
+	zones   map[string]*secondaryZone // Lowercased zone names with a trailing dot
+	timeout time.Duration
+}
+
+// secondaryZone is a zone transferred from its primary
+type secondaryZone struct {
+	name    string
+	primary string        // ip:port address
+	refresh time.Duration // Overrides the SOA refresh interval when set
+	retry   time.Duration // Overrides the SOA retry interval when set
+	key     *zoneKey      // Signs the queries to the primary when set
+	check   chan struct{} // Asks for a refresh check; holds one request so NOTIFYs coalesce
+
+	mu      sync.Mutex
+	expires time.Time // Zero until the zone is loaded
+	stale   bool      // Expiry was logged
+}
+
+// zoneKey is a TSIG key the queries of a secondary zone are signed with
+type zoneKey struct {
+	name      string
+	algorithm tsig.TSIGAlgorithm
+	secret    []byte
+}
+
+// newSecondaries returns the configured secondary zones, or nil without any.
+// keys holds the TSIG keys the zones refer to
+func newSecondaries(cfg config.SecondaryConfig, keys config.TSIGConfig) *secondaries {
+	if len(cfg.Zones) == 0 {
+		return nil
+	}
+
+	zones := make(map[string]*secondaryZone, len(cfg.Zones))
+	for _, zone := range cfg.Zones {
+		name := normalizeZone(zone.Zone)
+		zones[name] = &secondaryZone{
+			name:    name,
+			primary: zone.Primary,
+			refresh: zone.Refresh,
+			retry:   zone.Retry,
+			key:     newZoneKey(keys, zone.Key),
+			check:   make(chan struct{}, 1),
+		}
+	}
+
+	return &secondaries{zones: zones, timeout: cfg.Timeout}
+}
+
+// newZoneKey returns the key named name, or nil when name is empty or the key
+// is unusable
+func newZoneKey(keys config.TSIGConfig, name string) *zoneKey {
+	if name == "" {
+		return nil
+	}
+	key, ok := keys.Key(name)
+	if !ok {
+		return nil
+	}
+	algorithm, err := tsig.ParseAlgorithm(key.Algorithm)
+	if err != nil {
+		return nil
+	}
+	secret, ok := keys.Keyring()[canonicalKeyName(key.Name)]
+	if !ok {
+		return nil
+	}
+	return &zoneKey{name: canonicalKeyName(key.Name), algorithm: algorithm, secret: secret}
+}
+
+// watchSecondaries keeps the secondary zones in the current storage up to
+// date until ctx is done
+func (s *Server) watchSecondaries(ctx context.Context) {
+	if s.secondaries == nil {
+		return
+	}
+
+	for _, zone := range s.secondaries.zones {
+		go s.secondaries.maintain(ctx, s.storage, zone)
+	}
+}
+
+// zoneOf returns the closest secondary zone enclosing name, or nil
+func (sec *secondaries) zoneOf(name string) *secondaryZone {
+	zone, ok := closestZone(name, func(zone string) bool {
+		_, ok := sec.zones[zone]
+		return ok
+	})
+	if !ok {
+		return nil
+	}
+	return sec.zones[zone]
+}
+
+// maintain checks zone against its primary on start, every refresh interval
+// and when asked to, until ctx is done. Failed checks are retried after the
+// retry interval
+func (sec *secondaries) maintain(ctx context.Context, store storage.Storage, zone *secondaryZone) {
+	// A zone kept by persistent storage is served until it expires
+	if soa := storedSOA(ctx, store, zone.name); soa != nil {
+		zone.loaded(time.Now(), soa.Expire())
+	}
+
+	for {
+		wait := sec.refreshZone(ctx, store, zone)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-zone.check:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// refreshZone transfers zone when the primary holds a newer serial than
+// storage and returns the wait until the next check
+func (sec *secondaries) refreshZone(ctx context.Context, store storage.Storage, zone *secondaryZone) time.Duration {
+	stored := storedSOA(ctx, store, zone.name)
+
+	primary, err := querySOA(ctx, zone.primary, zone.name, zone.key, sec.timeout)
+	if err != nil {
+		logger.Warn("Failed to check secondary zone against its primary", "zone", zone.name, "primary", zone.primary, "error", err)
+		return zone.failed(time.Now(), stored)
+	}
+
+	if stored == nil || serialNewer(primary.Serial(), stored.Serial()) {
+		transferred, err := sec.transfer(ctx, store, zone)
+		if err != nil {
+			logger.Warn("Failed to transfer secondary zone", "zone", zone.name, "primary", zone.primary, "error", err)
+			return zone.failed(time.Now(), stored)
+		}
+		stored = transferred
+	}
+
+	zone.loaded(time.Now(), stored.Expire())
+	if zone.refresh > 0 {
+		return zone.refresh
+	}
+	return stored.Refresh()
+}
+
+// loaded marks zone as current until expire from now
+func (z *secondaryZone) loaded(now time.Time, expire time.Duration) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	z.expires = now.Add(expire)
+	if z.stale {
+		logger.Info("Secondary zone is current again", "zone", z.name)
+	}
+	z.stale = false
+}
+
+// failed records a failed check of zone and returns the wait before the
+// next attempt
+func (z *secondaryZone) failed(now time.Time, stored *records.SOARecord) time.Duration {
+	z.mu.Lock()
+	if !z.expires.IsZero() && !now.Before(z.expires) && !z.stale {
+		logger.Error("Secondary zone expired, answering it with SERVFAIL until it is transferred", "zone", z.name)
+		z.stale = true
+	}
+	z.mu.Unlock()
+
+	switch {
+	case z.retry > 0:
+		return z.retry
+	case stored != nil:
+		return stored.Retry()
+	default:
+		return defaultSecondaryRetry
+	}
+}
+
+// expired reports whether zone is not loaded or its expire interval passed
+// since it was last confirmed against its primary
+func (z *secondaryZone) expired(now time.Time) bool {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	return z.expires.IsZero() || !now.Before(z.expires)
+}
+
+// requestCheck asks for zone to be checked against its primary right away
+func (z *secondaryZone) requestCheck() {
+	select {
+	case z.check <- struct{}{}:
+	default:
+	}
+}
+
+// storedSOA returns the stored SOA record of zone, or nil
+func storedSOA(ctx context.Context, store storage.Storage, zone string) *records.SOARecord {
+	stored, err := store.GetRecords(ctx, zone, types.TYPE_SOA)
+	if err != nil {
+		return nil
+	}
+	for _, record := range stored {
+		if soa, ok := soaOf(record); ok {
+			return soa
+		}
+	}
+	return nil
+}
+
+// soaOf returns the SOA record behind record, unwrapping view records
+func soaOf(record records.DNSRecord) (*records.SOARecord, bool) {
+	if wrapped, ok := record.(interface{ Unwrap() records.DNSRecord }); ok {
+		record = wrapped.Unwrap()
+	}
+	soa, ok := record.(*records.SOARecord)
+	return soa, ok
+}
+
+// serialNewer reports whether serial a is newer than b in the sequence space
+// arithmetic of RFC 1982
+func serialNewer(a, b uint32) bool {
+	return int32(a-b) > 0
+}
+
+// transfer replaces the stored records of zone with those transferred from
+// its primary in a single transaction and returns the new SOA. Names below
+// another secondary zone are left alone
+func (sec *secondaries) transfer(ctx context.Context, store storage.Storage, zone *secondaryZone) (*records.SOARecord, error) {
+	transferred, soa, err := axfr(ctx, zone.primary, zone.name, zone.key, sec.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := store.ListRecordsByZone(ctx, zone.name)
+	if err != nil && !errors.Is(err, storage.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to list stored records: %w", err)
+	}
+
+	current := make([]records.DNSRecord, 0, len(stored))
+	for _, record := range stored {
+		if sec.zoneOf(record.Name()) == zone {
+			current = append(current, record)
+		}
+	}
+
+	diff := storage.DiffRecordSets(current, transferred)
+	if err := storage.ApplyDiff(ctx, store, diff); err != nil {
+		return nil, fmt.Errorf("failed to store transferred records: %w", err)
+	}
+
+	logger.Info("Transferred secondary zone", "zone", zone.name, "serial", soa.Serial(), "primary", zone.primary, "changes", diff.Summary())
+	// The zone is served as the primary has it, but its flaws are reported
+	for _, err := range storage.ValidateZone(ctx, store, zone.name) {
+		logger.Warn("Secondary zone is invalid", "zone", zone.name, "error", err)
+	}
+	return soa, nil
+}
+
+// querySOA asks primary for the SOA record of zone over TCP, signing the
+// query with key when set
+func querySOA(ctx context.Context, primary, zone string, key *zoneKey, timeout time.Duration) (*records.SOARecord, error) {
+	conn, query, err := sendZoneQuery(ctx, primary, zone, types.TYPE_SOA, key, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	response, data, err := readZoneResponse(conn, query, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := query.done(); err != nil {
+		return nil, err
+	}
+
+	for _, answer := range response.Answers {
+		if answer.Type() != types.TYPE_SOA || !answer.Name().Equal(query.Questions[0].Name) {
+			continue
+		}
+		record, err := message.DecodeRData(answer, data)
+		if err != nil {
+			return nil, err
+		}
+		if soa, ok := record.(*records.SOARecord); ok {
+			return soa, nil
+		}
+	}
+
+	return nil, errors.New("primary returned no SOA record")
+}
+
+// axfr transfers zone from primary (RFC 5936) and returns its records and
+// SOA. The transfer ends with the SOA record it started with; records of
+// types without a typed representation, and names outside zone, are skipped.
+// With key set, the request and the transfer are signed with it
+func axfr(ctx context.Context, primary, zone string, key *zoneKey, timeout time.Duration) ([]records.DNSRecord, *records.SOARecord, error) {
+	conn, query, err := sendZoneQuery(ctx, primary, zone, types.TYPE_AXFR, key, timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+
+	var (
+		transferred []records.DNSRecord
+		soa         *records.SOARecord
+		skipped     int
+	)
+	for {
+		response, data, err := readZoneResponse(conn, query, timeout)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, answer := range response.Answers {
+			if soa == nil && answer.Type() != types.TYPE_SOA {
+				return nil, nil, errors.New("transfer does not start with an SOA record")
+			}
+
+			record, err := message.DecodeRData(answer, data)
+			if errors.Is(err, message.ErrUnsupportedRData) {
+				skipped++
+				continue
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid record in transfer: %w", err)
+			}
+
+			if soa == nil {
+				if soa, _ = record.(*records.SOARecord); soa == nil {
+					return nil, nil, errors.New("transfer does not start with an SOA record")
+				}
+				transferred = append(transferred, record)
+				continue
+			}
+			if answer.Type() == types.TYPE_SOA {
+				if err := query.done(); err != nil {
+					return nil, nil, err
+				}
+				if skipped > 0 {
+					logger.Warn("Skipped records of unsupported types in zone transfer", "zone", zone, "records", skipped)
+				}
+				return transferred, soa, nil
+			}
+
+			if name := answer.Name(); name.IsSubdomainOf(query.Questions[0].Name) {
+				transferred = append(transferred, record)
+			}
+		}
+	}
+}
+
+// zoneQuery is a query sent to a primary along with the verification of the
+// signed responses to it
+type zoneQuery struct {
+	*message.DNSResponse
+	stream *tsig.Stream // Nil for unsigned queries
+}
+
+// verify checks the signature of data, the next message answering the query
+func (q *zoneQuery) verify(data []byte) error {
+	if q.stream == nil {
+		return nil
+	}
+	if err := q.stream.Verify(data, time.Now()); err != nil {
+		return fmt.Errorf("invalid response signature: %w", err)
+	}
+	return nil
+}
+
+// done checks that the last message answering the query was signed
+func (q *zoneQuery) done() error {
+	if q.stream == nil {
+		return nil
+	}
+	if err := q.stream.Done(); err != nil {
+		return fmt.Errorf("invalid response signature: %w", err)
+	}
+	return nil
+}
+
+// sendZoneQuery connects to primary over TCP and asks for the records of
+// zone of recordType, signing the query with key when set
+func sendZoneQuery(ctx context.Context, primary, zone string, recordType types.DNSType, key *zoneKey, timeout time.Duration) (net.Conn, *zoneQuery, error) {
+	name, err := utils.NewDomainNameFromString(zone)
+	if err != nil {
+		return nil, nil, err
+	}
+	query := &zoneQuery{DNSResponse: message.GenerateDNSQuery(uint16(rand.Uint32()), []message.DNSQuestion{message.NewDNSQuestion(*name, recordType, types.CLASS_IN)})}
+
+	if key != nil {
+		record, err := tsig.NewTSIGRecord(key.name, key.algorithm, key.secret, uint64(time.Now().Unix()), tsig.DefaultFudge, nil, query.Header.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := query.SignTSIG(record, key.secret, nil); err != nil {
+			return nil, nil, err
+		}
+		query.stream = tsig.NewStream(map[string][]byte{key.name: key.secret}, record.MAC)
+	}
+
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", primary)
+	if err != nil {
+		return nil, nil, err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+
+	data := query.ToBytesWithCompression()
+	frame := append([]byte{byte(len(data) >> 8), byte(len(data))}, data...)
+	conn.SetWriteDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(frame); err != nil {
+		stop()
+		conn.Close()
+		return nil, nil, err
+	}
+
+	return &stoppingConn{Conn: conn, stop: stop}, query, nil
+}
+
+// readZoneResponse reads the next message answering query from conn,
+// waiting up to timeout for it
+func readZoneResponse(conn net.Conn, query *zoneQuery, timeout time.Duration) (*message.DNSResponse, []byte, error) {
+	conn.SetReadDeadline(time.Now().Add(timeout))
+
+	length := make([]byte, 2)
+	if _, err := io.ReadFull(conn, length); err != nil {
+		return nil, nil, err
+	}
+	data := make([]byte, int(length[0])<<8|int(length[1]))
+	if _, err := io.ReadFull(conn, data); err != nil {
+		return nil, nil, err
+	}
+
+	response, err := message.NewDNSResponse(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if response.Header.ID != query.Header.ID || !response.Header.Flags.IsResponse() {
+		return nil, nil, errors.New("unexpected message from primary")
+	}
+	if rcode := response.Header.Flags.Rcode(); rcode != types.RCODE_NO_ERROR {
+		if record := response.TSIG(); record != nil && record.Error != 0 {
+			return nil, nil, fmt.Errorf("primary answered %s with TSIG error %d", rcode, record.Error)
+		}
+		return nil, nil, fmt.Errorf("primary answered %s", rcode)
+	}
+	if err := query.verify(data); err != nil {
+		return nil, nil, err
+	}
+
+	return response, data, nil
+}
+
+// stoppingConn stops the cancellation of its connection once it is closed
+type stoppingConn struct {
+	net.Conn
+	stop func() bool
+}
+
+func (c *stoppingConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
+
+// secondaryZones answers NOTIFY messages for secondary zones and queries for
+// names in expired secondary zones with SERVFAIL, passing other requests on
+func (s *Server) secondaryZones(next Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+		request := query.Request
+		if request.Header.Flags.Opcode() == types.OPCODE_NOTIFY {
+			return s.receiveNotify(query), nil
+		}
+
+		now := time.Now()
+		for _, question := range request.Questions {
+			if zone := s.secondaries.zoneOf(question.Name.String()); zone != nil && zone.expired(now) {
+				return s.createErrorResponse(request, types.RCODE_SERVER_FAILURE), nil
+			}
+		}
+
+		return next.Handle(ctx, query)
+	})
+}
+
+// receiveNotify answers a NOTIFY (RFC 1996 §3.7) for a secondary zone sent by
+// its primary and has the zone checked right away. NOTIFY messages for other
+// zones or from other sources are refused
+func (s *Server) receiveNotify(query *QueryContext) *message.DNSResponse {
+	request := query.Request
+	flags := types.NewFlagBuilder(request.Header.Flags).SetQR(true).SetAA(true).Build()
+	respond := func(rcode types.DNSRCode) *message.DNSResponse {
+		return message.NewResponseFor(request).
+			WithFlags(flags).
+			SetRcode(rcode).
+			Build()
+	}
+
+	if len(request.Questions) != 1 {
+		return respond(types.RCODE_FORMAT_ERROR)
+	}
+
+	name := request.Questions[0].Name.String()
+	zone := s.secondaries.zoneOf(name)
+	if zone == nil || zone.name != normalizeZone(name) {
+		logger.Warn("Refused NOTIFY for a zone that is not a secondary zone", clientAttr(query.ClientAddr), "zone", name)
+		return respond(types.RCODE_REFUSED)
+	}
+
+	primary, _, _ := net.SplitHostPort(zone.primary)
+	if source := addrIP(query.ClientAddr); source == nil || !source.Equal(net.ParseIP(primary)) {
+		logger.Warn("Refused NOTIFY from another server than the primary", clientAttr(query.ClientAddr), "zone", zone.name, "primary", zone.primary)
+		return respond(types.RCODE_REFUSED)
+	}
+
+	zone.requestCheck()
+	return respond(types.RCODE_NO_ERROR)
+}
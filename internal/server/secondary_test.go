@@ -0,0 +1,322 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// fakePrimary serves the SOA and a transfer of its zone over TCP, streaming
+// the transfer in two messages
+type fakePrimary struct {
+	listener net.Listener
+
+	mu         sync.Mutex
+	records    []records.DNSRecord // Starting with the SOA
+	soaQueries int
+	transfers  int
+}
+
+func startFakePrimary(t *testing.T, zoneRecords []records.DNSRecord) *fakePrimary {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start fake primary: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	primary := &fakePrimary{listener: listener, records: zoneRecords}
+	go primary.serve()
+	return primary
+}
+
+func (f *fakePrimary) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.answer(conn)
+	}
+}
+
+func (f *fakePrimary) answer(conn net.Conn) {
+	defer conn.Close()
+
+	var length uint16
+	if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+		return
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(conn, data); err != nil {
+		return
+	}
+	query, err := message.NewDNSResponse(data)
+	if err != nil {
+		return
+	}
+
+	f.mu.Lock()
+	zone := recordAnswers(f.records)
+	var messages [][]message.DNSAnswer
+	if query.Questions[0].Qtype() == types.TYPE_AXFR {
+		f.transfers++
+		half := len(zone) / 2
+		messages = [][]message.DNSAnswer{zone[:half], append(zone[half:], zone[0])}
+	} else {
+		f.soaQueries++
+		messages = [][]message.DNSAnswer{zone[:1]}
+	}
+	f.mu.Unlock()
+
+	for _, answers := range messages {
+		response := message.NewResponse(query.Header.ID).
+			WithFlags(types.NewFlagBuilder(0).SetQR(true).SetAA(true).Build()).
+			AddQuestion(query.Questions...).
+			AddAnswer(answers...).
+			Build().
+			ToBytesWithCompression()
+		binary.Write(conn, binary.BigEndian, uint16(len(response)))
+		conn.Write(response)
+	}
+}
+
+func (f *fakePrimary) setRecords(zoneRecords []records.DNSRecord) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.records = zoneRecords
+}
+
+func (f *fakePrimary) counts() (soaQueries, transfers int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.soaQueries, f.transfers
+}
+
+func primaryZone(serial uint32, wwwAddress string) []records.DNSRecord {
+	return []records.DNSRecord{
+		records.NewSOARecord("example.org.", "ns1.example.org.", "hostmaster.example.org.",
+			serial, time.Hour, 10*time.Minute, 24*time.Hour, 5*time.Minute, 3600),
+		records.NewNSRecord("example.org.", "ns1.example.org.", 3600),
+		records.NewARecord("ns1.example.org.", net.ParseIP("192.0.2.53"), 3600),
+		records.NewARecord("www.example.org.", net.ParseIP(wwwAddress), 300),
+		records.NewMXRecord("example.org.", "mail.example.org.", 10, 300),
+		records.NewTXTRecord("example.org.", []string{"v=spf1 mx -all"}, 300),
+	}
+}
+
+// waitFor polls condition until it holds or a second passed
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within a second")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func storedAddress(store storage.Storage, name string) string {
+	stored, err := store.GetRecords(context.Background(), name, types.TYPE_A)
+	if err != nil || len(stored) != 1 {
+		return ""
+	}
+	return net.IP(stored[0].Data()).String()
+}
+
+func TestSecondaries_TransferAndRefresh(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store, err := storage.NewMemoryStorage(nil)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	// A record the primary no longer has and one outside the zone
+	if err := store.BatchPutRecords(ctx, []records.DNSRecord{
+		records.NewARecord("old.example.org.", net.ParseIP("192.0.2.99"), 300),
+		records.NewARecord("www.example.com.", net.ParseIP("198.51.100.1"), 300),
+	}); err != nil {
+		t.Fatalf("failed to store records: %v", err)
+	}
+
+	primary := startFakePrimary(t, primaryZone(1, "192.0.2.1"))
+	sec := newSecondaries(config.SecondaryConfig{
+		Zones:   []config.SecondaryZoneConfig{{Zone: "Example.org", Primary: primary.listener.Addr().String()}},
+		Timeout: time.Second,
+	}, config.TSIGConfig{})
+	zone := sec.zoneOf("www.example.org.")
+	if !zone.expired(time.Now()) {
+		t.Fatal("zone not transferred yet is not expired")
+	}
+
+	go sec.maintain(ctx, store, zone)
+
+	waitFor(t, func() bool { return storedAddress(store, "www.example.org.") == "192.0.2.1" })
+	if zone.expired(time.Now()) {
+		t.Error("transferred zone is expired")
+	}
+	if address := storedAddress(store, "old.example.org."); address != "" {
+		t.Errorf("record missing from the transfer is still stored: %s", address)
+	}
+	if address := storedAddress(store, "www.example.com."); address != "198.51.100.1" {
+		t.Errorf("record outside the zone was changed: %q", address)
+	}
+	for _, recordType := range []types.DNSType{types.TYPE_SOA, types.TYPE_NS, types.TYPE_MX, types.TYPE_TXT} {
+		if stored, err := store.GetRecords(ctx, "example.org.", recordType); err != nil || len(stored) != 1 {
+			t.Errorf("expected one transferred %s record, got %v (%v)", recordType, stored, err)
+		}
+	}
+
+	// A NOTIFY without a new serial transfers nothing
+	zone.requestCheck()
+	waitFor(t, func() bool { soaQueries, _ := primary.counts(); return soaQueries == 2 })
+	if _, transfers := primary.counts(); transfers != 1 {
+		t.Errorf("primary served %d transfers, expected 1", transfers)
+	}
+
+	// A new serial is transferred
+	primary.setRecords(primaryZone(2, "192.0.2.2"))
+	zone.requestCheck()
+	waitFor(t, func() bool { return storedAddress(store, "www.example.org.") == "192.0.2.2" })
+	if soa := storedSOA(ctx, store, "example.org."); soa == nil || soa.Serial() != 2 {
+		t.Errorf("stored SOA = %v, expected serial 2", soa)
+	}
+}
+
+func TestSecondaries_Expiry(t *testing.T) {
+	s := &Server{config: config.DefaultConfig(), secondaries: newSecondaries(config.SecondaryConfig{
+		Zones: []config.SecondaryZoneConfig{{Zone: "example.org", Primary: "192.0.2.1:53"}},
+	}, config.TSIGConfig{})}
+	zone := s.secondaries.zoneOf("example.org.")
+
+	handler := s.secondaryZones(HandlerFunc(func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+		return s.createErrorResponse(query.Request, types.RCODE_NO_ERROR), nil
+	}))
+	rcode := func(name string) types.DNSRCode {
+		response, err := handler.Handle(context.Background(), newTestQuery(t, name))
+		if err != nil {
+			t.Fatalf("Handle() returned error: %v", err)
+		}
+		return response.Header.Flags.Rcode()
+	}
+
+	tests := []struct {
+		name     string
+		loadedAt time.Time
+		query    string
+		expected types.DNSRCode
+	}{
+		{name: "not loaded", query: "www.example.org", expected: types.RCODE_SERVER_FAILURE},
+		{name: "current", loadedAt: time.Now(), query: "www.example.org", expected: types.RCODE_NO_ERROR},
+		{name: "expired", loadedAt: time.Now().Add(-25 * time.Hour), query: "www.example.org", expected: types.RCODE_SERVER_FAILURE},
+		{name: "outside the zone", loadedAt: time.Now().Add(-25 * time.Hour), query: "www.example.com", expected: types.RCODE_NO_ERROR},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zone.expires = time.Time{}
+			if !tt.loadedAt.IsZero() {
+				zone.loaded(tt.loadedAt, 24*time.Hour)
+			}
+
+			if got := rcode(tt.query); got != tt.expected {
+				t.Errorf("rcode = %s, expected %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReceiveNotify(t *testing.T) {
+	s := &Server{config: config.DefaultConfig(), secondaries: newSecondaries(config.SecondaryConfig{
+		Zones: []config.SecondaryZoneConfig{{Zone: "example.org", Primary: "192.0.2.1:53"}},
+	}, config.TSIGConfig{})}
+	zone := s.secondaries.zoneOf("example.org.")
+	handler := s.secondaryZones(HandlerFunc(func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+		t.Fatal("NOTIFY passed on")
+		return nil, nil
+	}))
+
+	tests := []struct {
+		name     string
+		zone     string
+		source   string
+		expected types.DNSRCode
+	}{
+		{name: "from the primary", zone: "example.org", source: "192.0.2.1", expected: types.RCODE_NO_ERROR},
+		{name: "from another source", zone: "example.org", source: "192.0.2.66", expected: types.RCODE_REFUSED},
+		{name: "for a name in the zone", zone: "www.example.org", source: "192.0.2.1", expected: types.RCODE_REFUSED},
+		{name: "for another zone", zone: "example.com", source: "192.0.2.1", expected: types.RCODE_REFUSED},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := message.NewDNSRequest(message.GenerateNotify(4321, mustDomainName(tt.zone), nil).ToBytes())
+			if err != nil {
+				t.Fatalf("failed to parse NOTIFY: %v", err)
+			}
+
+			response, err := handler.Handle(context.Background(), &QueryContext{
+				Request:    request,
+				ClientAddr: &net.UDPAddr{IP: net.ParseIP(tt.source), Port: 53},
+			})
+			if err != nil {
+				t.Fatalf("Handle() returned error: %v", err)
+			}
+
+			flags := response.Header.Flags
+			if rcode := flags.Rcode(); rcode != tt.expected {
+				t.Errorf("rcode = %s, expected %s", rcode, tt.expected)
+			}
+			if response.Header.ID != 4321 || !flags.IsResponse() || flags.Opcode() != types.OPCODE_NOTIFY {
+				t.Errorf("unexpected response header %+v", response.Header)
+			}
+
+			select {
+			case <-zone.check:
+				if tt.expected != types.RCODE_NO_ERROR {
+					t.Error("refused NOTIFY requested a check")
+				}
+			default:
+				if tt.expected == types.RCODE_NO_ERROR {
+					t.Error("NOTIFY did not request a check")
+				}
+			}
+		})
+	}
+}
+
+func TestSerialNewer(t *testing.T) {
+	tests := []struct {
+		a, b     uint32
+		expected bool
+	}{
+		{a: 2, b: 1, expected: true},
+		{a: 1, b: 1, expected: false},
+		{a: 1, b: 2, expected: false},
+		{a: 0, b: 0xFFFFFFFF, expected: true},
+		{a: 0xFFFFFFFF, b: 0, expected: false},
+		{a: 2024010101, b: 2023123199, expected: true},
+	}
+
+	for _, tt := range tests {
+		if got := serialNewer(tt.a, tt.b); got != tt.expected {
+			t.Errorf("serialNewer(%d, %d) = %v, expected %v", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
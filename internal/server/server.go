@@ -2,18 +2,30 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
 	"net"
+	"net/http"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/vadim-su/dnska/internal/blocklist"
 	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/internal/cookie"
+	"github.com/vadim-su/dnska/internal/hosts"
 	"github.com/vadim-su/dnska/internal/resolver"
+	"github.com/vadim-su/dnska/internal/rrl"
 	"github.com/vadim-su/dnska/internal/storage"
 	"github.com/vadim-su/dnska/pkg/dns/message"
 	"github.com/vadim-su/dnska/pkg/dns/records"
 	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
 )
 
 const (
@@ -21,13 +33,53 @@ const (
 	maxBufferSize     = 4096
 )
 
-type Server struct {
-	config   *config.Config
-	storage  storage.Storage
-	resolver resolver.Resolver
+// requestBuffers holds the buffers requests are read into: every UDP
+// request, and TCP requests with UseBufferPool. A buffer belongs to the
+// request read into it until its response is sent, as the parsed request
+// refers to its bytes
+var requestBuffers = message.NewBufferPool(maxBufferSize)
+
+// responseBuffers holds the buffers UDP responses are serialized into
+var responseBuffers = sync.Pool{
+	New: func() any {
+		buffer := make([]byte, 0, defaultBufferSize)
+		return &buffer
+	},
+}
 
-	udpConn     *net.UDPConn
-	tcpListener *net.TCPListener
+type Server struct {
+	config       *config.Config
+	storage      storage.Storage
+	resolver     resolver.Resolver
+	forwarder    *resolver.ForwardResolver // Probed by the readiness check
+	parseOptions message.ParseOptions
+	queryACL     *queryACL  // Nil unless allow-query networks are configured
+	dns64Prefix  *net.IPNet // Nil unless DNS64 is enabled
+	views        []view     // Split-horizon views in the order clients are matched
+	blocklist    *blocklist.Blocklist
+	hosts        *hosts.Hosts   // Nil without hosts files
+	secondaries  *secondaries   // Nil without secondary zones
+	limiter      *rrl.Limiter   // Nil unless response rate limiting is enabled
+	cookieIssuer *cookie.Issuer // Nil unless DNS cookies are enabled
+	formErrLimit *rrl.Limiter   // Limits the FORMERR responses to each client, nil for none
+	handler      Handler        // Chain every query is answered by
+	stats        *statsCollector
+
+	// componentsMu guards the components above against Reload replacing
+	// them; it is held for reading while a query is answered
+	componentsMu sync.RWMutex
+	reloadMu     sync.Mutex         // Serializes Reload
+	updateMu     sync.Mutex         // Serializes dynamic updates
+	stopWatchers context.CancelFunc // Stops the background work of the components
+
+	udpConns     []*net.UDPConn
+	tcpListeners []*net.TCPListener
+
+	udpQueue       chan udpPacket // Received UDP queries waiting for a worker
+	droppedPackets atomic.Uint64  // UDP queries dropped with the queue full
+
+	healthServer   *http.Server
+	healthListener net.Listener
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -50,23 +102,77 @@ func New(cfg *config.Config) (*Server, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	s := &Server{
-		config:  cfg,
-		ctx:     ctx,
-		cancel:  cancel,
-		started: false,
-		closed:  false,
+		config:       cfg,
+		parseOptions: newParseOptions(cfg.Server.Parsing),
+		formErrLimit: rrl.NewPerAddress(formErrPerSecond, 0, time.Second),
+		stats:        newStatsCollector(cfg.Server.StatsWindow, cfg.Server.StatsTopN),
+		ctx:          ctx,
+		cancel:       cancel,
+		started:      false,
+		closed:       false,
+	}
+
+	if cfg.DNS64.Prefix != "" {
+		prefix, err := utils.ParseDNS64Prefix(cfg.DNS64.Prefix)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("invalid configuration: %w", err)
+		}
+		s.dns64Prefix = prefix
+	}
+
+	queryACL, err := newQueryACL(cfg.Server)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
+	s.queryACL = queryACL
 
-	if err := s.initStorage(); err != nil {
+	views, err := newViews(cfg.Views)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	s.views = views
+
+	if s.storage, err = s.newStorage(cfg.Storage); err != nil {
 		cancel()
 		return nil, fmt.Errorf("failed to initialize storage: %w", err)
 	}
 
-	if err := s.initResolver(); err != nil {
+	if err := loadZoneFiles(ctx, s.storage, cfg.Zones); err != nil {
+		s.storage.Close()
+		cancel()
+		return nil, fmt.Errorf("failed to load zone files: %w", err)
+	}
+
+	if s.resolver, s.forwarder, err = newResolver(cfg); err != nil {
 		cancel()
 		return nil, fmt.Errorf("failed to initialize resolver: %w", err)
 	}
 
+	if s.blocklist, err = newBlocklist(cfg.Blocklist); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to initialize blocklist: %w", err)
+	}
+
+	if s.hosts, err = newHosts(cfg.Hosts); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to initialize hosts files: %w", err)
+	}
+
+	s.secondaries = newSecondaries(cfg.Secondary, cfg.TSIG)
+	s.limiter = newLimiter(cfg.RRL)
+
+	if s.cookieIssuer, err = newCookieIssuer(cfg.EDNS.Cookies); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to initialize DNS cookies: %w", err)
+	}
+
+	s.handler = s.buildHandler()
+
+	s.startWatchers()
+
 	return s, nil
 }
 
@@ -74,61 +180,148 @@ func NewWithDefaults() (*Server, error) {
 	return New(config.DefaultConfig())
 }
 
-func (s *Server) initStorage() error {
+// newParseOptions maps the configured request limits onto message.ParseOptions
+func newParseOptions(cfg config.ParsingConfig) message.ParseOptions {
+	return message.ParseOptions{
+		MaxQuestions:    cfg.MaxQuestions,
+		MaxTotalRecords: cfg.MaxRecords,
+		MaxMessageSize:  cfg.MaxMessageSize,
+		Strict:          cfg.Strict,
+		SingleQuestion:  cfg.SingleQuestion,
+		HostnameLabels:  cfg.HostnameLabels,
+	}
+}
+
+// newStorage creates the storage backend described by cfg
+func (s *Server) newStorage(cfg config.StorageConfig) (storage.Storage, error) {
+	var store storage.Storage
 	var err error
 
-	switch s.config.Storage.Type {
+	validationConfig := &storage.ValidationConfig{
+		Enabled:           true,
+		AllowUnderscore:   true,
+		AllowUnknownTypes: cfg.AllowUnknownTypes,
+	}
+
+	switch cfg.Type {
 	case "memory":
-		validationConfig := &storage.ValidationConfig{
-			Enabled:         true,
-			AllowUnderscore: true,
-		}
-		s.storage, err = storage.NewMemoryStorage(validationConfig)
+		store, err = storage.NewMemoryStorage(validationConfig)
 	case "surrealdb":
 		storageConfig := &storage.StorageConfig{
 			Type:             storage.StorageTypeSurrealDB,
-			ConnectionString: s.config.Storage.DSN,
-			ValidationConfig: &storage.ValidationConfig{
-				Enabled:         true,
-				AllowUnderscore: true,
-			},
+			ConnectionString: cfg.DSN,
+			ValidationConfig: validationConfig,
 		}
-		s.storage, err = storage.NewSurrealDBStorage(s.ctx, storageConfig)
-	default:
-		validationConfig := &storage.ValidationConfig{
-			Enabled:         true,
-			AllowUnderscore: true,
+		store, err = storage.NewSurrealDBStorage(s.ctx, storageConfig)
+	case "file":
+		store, err = storage.NewFileStorage(cfg.DSN, storage.DefaultFlushInterval, validationConfig)
+	case "sqlite":
+		storageConfig := &storage.StorageConfig{
+			Type:             storage.StorageTypeSQLite,
+			ConnectionString: cfg.DSN,
+			ValidationConfig: validationConfig,
+		}
+		store, err = storage.NewSQLiteStorage(s.ctx, storageConfig)
+	case "bolt":
+		storageConfig := &storage.StorageConfig{
+			Type:             storage.StorageTypeBolt,
+			ConnectionString: cfg.DSN,
+			ValidationConfig: validationConfig,
 		}
-		s.storage, err = storage.NewMemoryStorage(validationConfig)
+		store, err = storage.NewBoltDBStorage(storageConfig)
+	default:
+		store, err = storage.NewMemoryStorage(validationConfig)
 	}
 
 	if err != nil {
-		return fmt.Errorf("failed to create %s storage: %w", s.config.Storage.Type, err)
+		return nil, fmt.Errorf("failed to create %s storage: %w", cfg.Type, err)
 	}
 
-	log.Printf("Storage initialized: %s", s.config.Storage.Type)
-	return nil
+	if cfg.AutoPTR {
+		store = storage.NewAutoPTRStorage(store)
+	}
+
+	logger.Info("Storage initialized", "type", cfg.Type)
+	return store, nil
 }
 
-func (s *Server) initResolver() error {
+// newResolver creates the resolver described by cfg and, unless it
+// resolves recursively, the forward resolver it queries. Concurrent identical
+// questions share one resolution
+func newResolver(cfg *config.Config) (resolver.Resolver, *resolver.ForwardResolver, error) {
 	resolverConfig := &resolver.ResolverConfig{
-		Timeout:        s.config.Resolver.Timeout,
-		MaxRetries:     s.config.Resolver.MaxRetries,
-		CacheTTL:       s.config.Cache.TTL,
-		ForwardServers: s.config.Resolver.ForwardServers,
-		RootServers:    s.config.Resolver.RootServers,
-		RecursionDepth: s.config.Resolver.RecursionDepth,
+		Timeout:           cfg.Resolver.Timeout,
+		MaxRetries:        cfg.Resolver.MaxRetries,
+		CacheTTL:          cfg.Cache.TTL,
+		ForwardServers:    cfg.Resolver.ForwardServers,
+		RootServers:       cfg.Resolver.RootServers,
+		RecursionDepth:    cfg.Resolver.RecursionDepth,
+		MaxHops:           cfg.Resolver.MaxHops,
+		MaxQueries:        cfg.Resolver.MaxQueries,
+		CaseRandomization: cfg.Resolver.CaseRandomization,
+		QNameMinimization: cfg.Resolver.QNameMinimization,
+		CircuitBreaker: resolver.CircuitBreakerConfig{
+			FailureThreshold: cfg.Resolver.CircuitBreaker.FailureThreshold,
+			FailureWindow:    cfg.Resolver.CircuitBreaker.FailureWindow,
+			Cooldown:         cfg.Resolver.CircuitBreaker.Cooldown,
+		},
+		UpstreamHealth: resolver.UpstreamHealthConfig{
+			FailureThreshold: cfg.Resolver.UpstreamHealth.FailureThreshold,
+			Cooldown:         cfg.Resolver.UpstreamHealth.Cooldown,
+			ProbeInterval:    cfg.Resolver.UpstreamHealth.ProbeInterval,
+			ProbeName:        cfg.Resolver.UpstreamHealth.ProbeName,
+		},
+	}
+
+	switch cfg.Resolver.Mode {
+	case "recursive", "iterative":
+		recursiveResolver, err := resolver.NewRecursiveResolver(resolverConfig)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create recursive resolver: %w", err)
+		}
+
+		logger.Info("Resolver initialized", "mode", "recursive", "cached", true)
+		return resolver.NewCacheResolver(resolverConfig, resolver.NewSingleflightResolver(resolverConfig, recursiveResolver)), nil, nil
+
+	case "stub":
+		// The forward servers resolve and cache on our behalf
+		forwardResolver, err := resolver.NewForwardResolver(resolverConfig)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create forward resolver: %w", err)
+		}
+
+		logger.Info("Resolver initialized", "mode", "stub", "servers", cfg.Resolver.ForwardServers)
+		return resolver.NewSingleflightResolver(resolverConfig, forwardResolver), forwardResolver, nil
 	}
 
 	forwardResolver, err := resolver.NewForwardResolver(resolverConfig)
 	if err != nil {
-		return fmt.Errorf("failed to create forward resolver: %w", err)
+		return nil, nil, fmt.Errorf("failed to create forward resolver: %w", err)
 	}
 
-	s.resolver = resolver.NewCacheResolver(resolverConfig, forwardResolver)
+	logger.Info("Resolver initialized", "mode", "forward", "cached", true, "servers", cfg.Resolver.ForwardServers)
+	return resolver.NewCacheResolver(resolverConfig, resolver.NewSingleflightResolver(resolverConfig, forwardResolver)), forwardResolver, nil
+}
 
-	log.Printf("Resolver initialized: cached forward resolver with servers %v", s.config.Resolver.ForwardServers)
-	return nil
+// watchStorage evicts cached answers for names whose records change in
+// storage until ctx is done
+func (s *Server) watchStorage(ctx context.Context) {
+	cache, ok := s.resolver.(*resolver.CacheResolver)
+	if !ok {
+		return
+	}
+
+	events, err := s.storage.Subscribe(ctx)
+	if err != nil {
+		logger.Warn("Storage change notifications unavailable, cache entries expire by TTL only", "error", err)
+		return
+	}
+
+	go func() {
+		for event := range events {
+			cache.Evict(event.Name, event.RecordType)
+		}
+	}()
 }
 
 func (s *Server) Start() error {
@@ -145,78 +338,292 @@ func (s *Server) Start() error {
 	s.mu.Unlock()
 
 	if s.config.Server.EnableUDP {
-		if err := s.startUDP(); err != nil {
-			return fmt.Errorf("failed to start UDP server: %w", err)
-		}
+		s.startUDPWorkers()
 	}
 
-	if s.config.Server.EnableTCP {
-		if err := s.startTCP(); err != nil {
-			s.mu.Lock()
-			udpConn := s.udpConn
-			s.mu.Unlock()
-			if udpConn != nil {
-				udpConn.Close()
+	bound := 0
+	for _, address := range s.config.Server.ListenAddresses() {
+		if err := s.listen(address); err != nil {
+			if s.config.Server.BindFailure != "warn" {
+				s.closeListeners()
+				return err
 			}
-			return fmt.Errorf("failed to start TCP server: %w", err)
+			logger.Warn("Not listening on address", "address", address, "error", err)
+			continue
 		}
+		bound++
+	}
+	if bound == 0 {
+		s.closeListeners()
+		return fmt.Errorf("failed to listen on any of %s", strings.Join(s.config.Server.ListenAddresses(), ", "))
+	}
+
+	if err := s.startHealth(); err != nil {
+		s.closeListeners()
+		return err
 	}
 
-	log.Printf("DNS server started on %s (UDP: %v, TCP: %v)",
-		s.config.Server.Address,
-		s.config.Server.EnableUDP,
-		s.config.Server.EnableTCP)
+	logger.Info("DNS server started",
+		"addresses", s.ListenAddresses(),
+		"udp", s.config.Server.EnableUDP,
+		"tcp", s.config.Server.EnableTCP)
 
 	s.wg.Wait()
 	return nil
 }
 
-func (s *Server) startUDP() error {
-	addr, err := net.ResolveUDPAddr("udp", s.config.Server.Address)
+// listen opens the enabled sockets for one listen address. When the address
+// asks for any port, the other sockets bind the port the first one was
+// given so all of them share it. The IPv4 wildcard address 0.0.0.0 is served
+// on IPv4 only, plus the IPv6 wildcard address [::] when IPv6 is enabled.
+// When one of the sockets fails, those opened for the address are closed
+func (s *Server) listen(address string) error {
+	s.mu.RLock()
+	udpCount, tcpCount := len(s.udpConns), len(s.tcpListeners)
+	s.mu.RUnlock()
+
+	err := s.listenAddress(address)
+	if err != nil {
+		s.closeListenersFrom(udpCount, tcpCount)
+	}
+	return err
+}
+
+// listenAddress opens the sockets of listen for address
+func (s *Server) listenAddress(address string) error {
+	if host, _, err := net.SplitHostPort(address); err != nil || host != net.IPv4zero.String() {
+		_, err := s.listenFamily("", address)
+		return err
+	}
+
+	// Go would listen on 0.0.0.0 with a dual-stack socket, so ask for IPv4
+	// explicitly to let EnableIPv6 decide about IPv6 clients
+	address, err := s.listenFamily("4", address)
+	if err != nil || !s.config.Server.EnableIPv6 {
+		return err
+	}
+
+	// Listening on "udp6" and "tcp6" sets IPV6_V6ONLY, so the IPv6 sockets
+	// leave IPv4 traffic to the sockets above instead of conflicting with
+	// them. SO_REUSEPORT groups are per address family on Linux, so IPv6
+	// gets its own set of worker sockets
+	_, port, _ := net.SplitHostPort(address)
+	_, err = s.listenFamily("6", net.JoinHostPort(net.IPv6unspecified.String(), port))
+	return err
+}
+
+// listenFamily opens the enabled sockets for address on the "udp" and "tcp"
+// networks suffixed with family, e.g. "4" for IPv4 only. It returns address
+// with the port the sockets were bound to
+func (s *Server) listenFamily(family, address string) (string, error) {
+	if s.config.Server.EnableUDP {
+		udpConn, err := s.startUDP("udp"+family, address)
+		if err != nil {
+			return "", fmt.Errorf("failed to start UDP server on %s: %w", address, err)
+		}
+		address = withBoundPort(address, udpConn.LocalAddr().(*net.UDPAddr).Port)
+	}
+
+	if s.config.Server.EnableTCP {
+		tcpListener, err := s.startTCP("tcp"+family, address)
+		if err != nil {
+			return "", fmt.Errorf("failed to start TCP server on %s: %w", address, err)
+		}
+		address = withBoundPort(address, tcpListener.Addr().(*net.TCPAddr).Port)
+	}
+
+	return address, nil
+}
+
+// withBoundPort replaces the port of address with the port a socket was
+// bound to when address asks for any port
+func withBoundPort(address string, port int) string {
+	host, addressPort, err := net.SplitHostPort(address)
+	if err != nil || addressPort != "0" {
+		return address
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// startUDP opens the UDP sockets for address, one per configured worker.
+// Extra sockets share the port through SO_REUSEPORT; where that is not
+// available a single socket is used. It returns the first socket
+func (s *Server) startUDP(network, address string) (*net.UDPConn, error) {
+	workers := max(s.config.Server.NumWorkers, 1)
+	if workers == 1 {
+		return s.listenUDP(network, address, net.ListenConfig{})
+	}
+
+	reusePort := net.ListenConfig{Control: setReusePort}
+	first, err := s.listenUDP(network, address, reusePort)
 	if err != nil {
-		return fmt.Errorf("failed to resolve UDP address: %w", err)
+		logger.Warn("Cannot use SO_REUSEPORT, falling back to a single UDP socket", "address", address, "error", err)
+		return s.listenUDP(network, address, net.ListenConfig{})
+	}
+
+	// The remaining sockets must bind the port the first one got
+	address = withBoundPort(address, first.LocalAddr().(*net.UDPAddr).Port)
+	for i := 1; i < workers; i++ {
+		if _, err := s.listenUDP(network, address, reusePort); err != nil {
+			return nil, err
+		}
 	}
 
-	udpConn, err := net.ListenUDP("udp", addr)
+	return first, nil
+}
+
+// listenUDP opens a UDP socket with listenConfig and starts its receive loop
+func (s *Server) listenUDP(network, address string, listenConfig net.ListenConfig) (*net.UDPConn, error) {
+	packetConn, err := listenConfig.ListenPacket(s.ctx, network, address)
 	if err != nil {
-		return fmt.Errorf("failed to listen on UDP: %w", err)
+		return nil, fmt.Errorf("failed to listen on UDP: %w", err)
 	}
+	udpConn := packetConn.(*net.UDPConn)
 
 	s.mu.Lock()
-	s.udpConn = udpConn
+	s.udpConns = append(s.udpConns, udpConn)
 	s.mu.Unlock()
 
 	s.wg.Add(1)
-	go s.handleUDP()
+	go s.handleUDP(udpConn)
 
-	return nil
+	return udpConn, nil
 }
 
-func (s *Server) startTCP() error {
-	addr, err := net.ResolveTCPAddr("tcp", s.config.Server.Address)
+func (s *Server) startTCP(network, address string) (*net.TCPListener, error) {
+	addr, err := net.ResolveTCPAddr(network, address)
 	if err != nil {
-		return fmt.Errorf("failed to resolve TCP address: %w", err)
+		return nil, fmt.Errorf("failed to resolve TCP address: %w", err)
 	}
 
-	tcpListener, err := net.ListenTCP("tcp", addr)
+	tcpListener, err := net.ListenTCP(network, addr)
 	if err != nil {
-		return fmt.Errorf("failed to listen on TCP: %w", err)
+		return nil, fmt.Errorf("failed to listen on TCP: %w", err)
 	}
 
 	s.mu.Lock()
-	s.tcpListener = tcpListener
+	s.tcpListeners = append(s.tcpListeners, tcpListener)
 	s.mu.Unlock()
 
 	s.wg.Add(1)
-	go s.handleTCP()
+	go s.handleTCP(tcpListener)
 
-	return nil
+	return tcpListener, nil
+}
+
+// closeListeners closes every open socket, including the health listener,
+// and returns the errors of the sockets that failed to close
+func (s *Server) closeListeners() []error {
+	s.mu.Lock()
+	udpConns := s.udpConns
+	tcpListeners := s.tcpListeners
+	s.udpConns = nil
+	s.tcpListeners = nil
+	s.mu.Unlock()
+
+	var errs []error
+	for _, udpConn := range udpConns {
+		if err := udpConn.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close UDP connection %s: %w", udpConn.LocalAddr(), err))
+		}
+	}
+	for _, tcpListener := range tcpListeners {
+		if err := tcpListener.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close TCP listener %s: %w", tcpListener.Addr(), err))
+		}
+	}
+	if err := s.closeHealth(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close health listener: %w", err))
+	}
+
+	return errs
+}
+
+// closeListenersFrom closes the UDP sockets and TCP listeners opened after
+// the first udpCount and tcpCount of them
+func (s *Server) closeListenersFrom(udpCount, tcpCount int) {
+	s.mu.Lock()
+	udpConns := s.udpConns[udpCount:]
+	tcpListeners := s.tcpListeners[tcpCount:]
+	s.udpConns = s.udpConns[:udpCount:udpCount]
+	s.tcpListeners = s.tcpListeners[:tcpCount:tcpCount]
+	s.mu.Unlock()
+
+	for _, udpConn := range udpConns {
+		udpConn.Close()
+	}
+	for _, tcpListener := range tcpListeners {
+		tcpListener.Close()
+	}
+}
+
+// ListenAddresses returns the addresses the server is bound to, one per
+// configured address, with the ports actually assigned. It is empty until
+// the server has started
+func (s *Server) ListenAddresses() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var addresses []string
+	if len(s.udpConns) > 0 {
+		for _, udpConn := range s.udpConns {
+			// Worker sockets share the address of the first socket
+			if address := udpConn.LocalAddr().String(); !slices.Contains(addresses, address) {
+				addresses = append(addresses, address)
+			}
+		}
+		return addresses
+	}
+
+	for _, tcpListener := range s.tcpListeners {
+		addresses = append(addresses, tcpListener.Addr().String())
+	}
+	return addresses
 }
 
-func (s *Server) handleUDP() {
+// udpPacket is a UDP query waiting in the queue for a worker
+type udpPacket struct {
+	conn       *net.UDPConn // Socket the query arrived on and is answered from
+	buffer     *[]byte      // Pooled buffer holding the query
+	size       int
+	clientAddr *net.UDPAddr
+}
+
+// startUDPWorkers starts the goroutines answering the queries the UDP
+// sockets receive. The sockets share the workers and their queue
+func (s *Server) startUDPWorkers() {
+	workers := s.config.Server.Workers
+	if workers == 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	s.udpQueue = make(chan udpPacket, s.config.Server.UDPQueueSize)
+	for range workers {
+		s.wg.Add(1)
+		go s.udpWorker()
+	}
+}
+
+// udpWorker answers queued UDP queries until the server stops
+func (s *Server) udpWorker() {
 	defer s.wg.Done()
 
-	buf := make([]byte, maxBufferSize)
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case packet := <-s.udpQueue:
+			s.handleUDPRequest(packet.conn, (*packet.buffer)[:packet.size], packet.clientAddr)
+			requestBuffers.Put(packet.buffer)
+		}
+	}
+}
+
+// handleUDP receives the queries of udpConn and queues them for the
+// workers. Queries arriving while the queue is full are dropped rather
+// than holding up the socket
+func (s *Server) handleUDP(udpConn *net.UDPConn) {
+	defer s.wg.Done()
 
 	for {
 		select {
@@ -226,53 +633,124 @@ func (s *Server) handleUDP() {
 		}
 
 		if s.config.Server.ReadTimeout > 0 {
-			s.udpConn.SetReadDeadline(time.Now().Add(s.config.Server.ReadTimeout))
+			udpConn.SetReadDeadline(time.Now().Add(s.config.Server.ReadTimeout))
 		}
 
-		n, clientAddr, err := s.udpConn.ReadFromUDP(buf)
+		buffer := requestBuffers.Get(maxBufferSize)
+		n, clientAddr, err := udpConn.ReadFromUDP(*buffer)
 		if err != nil {
+			requestBuffers.Put(buffer)
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 				continue
 			}
-			if s.ctx.Err() != nil {
+			if s.ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
 				return
 			}
-			log.Printf("UDP read error: %v", err)
+			logger.Error("UDP read failed", "error", err)
 			continue
 		}
 
-		s.wg.Add(1)
-		go s.handleUDPRequest(buf[:n], clientAddr)
+		select {
+		case s.udpQueue <- udpPacket{conn: udpConn, buffer: buffer, size: n, clientAddr: clientAddr}:
+		default:
+			s.droppedPackets.Add(1)
+			requestBuffers.Put(buffer)
+		}
 	}
 }
 
-func (s *Server) handleUDPRequest(data []byte, clientAddr *net.UDPAddr) {
-	defer s.wg.Done()
+func (s *Server) handleUDPRequest(udpConn *net.UDPConn, data []byte, clientAddr *net.UDPAddr) {
+	if admitted, refusal := s.admitQuery(data, clientAddr); !admitted {
+		if refusal != nil {
+			s.writeUDP(udpConn, refusal, clientAddr)
+		}
+		return
+	}
+
+	buffer := responseBuffers.Get().(*[]byte)
+	defer responseBuffers.Put(buffer)
+
+	if wire := s.answerUDP(data, clientAddr, (*buffer)[:0]); wire != nil {
+		*buffer = wire
+		s.writeUDP(udpConn, wire, clientAddr)
+	}
+}
 
-	request, err := message.NewDNSRequest(data)
+// answerUDP answers the admitted UDP request in data, appending the
+// serialized response to buffer. It returns nil when no response is sent
+func (s *Server) answerUDP(data []byte, clientAddr *net.UDPAddr, buffer []byte) []byte {
+	request, err := message.NewDNSRequestWithOptions(data, s.parseOptions)
 	if err != nil {
-		log.Printf("Failed to parse DNS request from %s: %v", clientAddr, err)
-		return
+		logger.Debug("Failed to parse DNS request", clientAttr(clientAddr), "error", err)
+		return s.formatError(data, clientAddr, buffer)
 	}
 
-	response, err := s.processRequest(request)
+	ctx, cancel := s.queryContext()
+	defer cancel()
+
+	query := s.newQueryContext(request, clientAddr)
+	s.componentsMu.RLock()
+	response, err := s.handler.Handle(ctx, query)
+	limiter := s.limiter
+	s.componentsMu.RUnlock()
 	if err != nil {
-		log.Printf("Failed to process request from %s: %v", clientAddr, err)
+		logger.Warn("Failed to process request", clientAttr(clientAddr), "error", err)
 		response = s.createErrorResponse(request, types.RCODE_SERVER_FAILURE)
 	}
 
-	responseBytes := response.ToBytesWithCompression()
+	// A valid server cookie proves the client address is not spoofed
+	if query.Value(cookieValue) == nil {
+		if response = limitResponse(limiter, response, clientAddr); response == nil {
+			return nil
+		}
+	}
+
+	// Never send a datagram larger than the client accepts; TC makes it retry over TCP
+	wire := response.AppendBytesWithCompression(buffer)
+	if maxSize := request.UDPPayloadSize(); len(wire) > maxSize {
+		wire = response.TruncateTo(maxSize).AppendBytesWithCompression(wire[:0])
+	}
+	return wire
+}
+
+// formErrPerSecond is the number of FORMERR responses sent to a client
+// address per second, so malformed queries with spoofed sources are of
+// little use for reflection attacks
+const formErrPerSecond = 10
+
+// formatError answers the request in data, which failed to parse, with
+// FORMERR (RFC 1035 §4.1.1), appending the response to buffer. Nothing is
+// sent when not even the header can be read, when data is a response, or
+// when the client has been sent formErrPerSecond FORMERR responses in the
+// last second; nil is returned then
+func (s *Server) formatError(data []byte, clientAddr net.Addr, buffer []byte) []byte {
+	header, err := message.NewDNSRequestPartial(data)
+	if err != nil || header.Flags.IsResponse() {
+		return nil
+	}
+	if s.formErrLimit != nil && s.formErrLimit.Check(addrIP(clientAddr)) != rrl.Send {
+		return nil
+	}
 
+	return message.NewResponse(header.ID).
+		WithFlags(message.PrepareResponseFlags(header.Flags)).
+		SetRcode(types.RCODE_FORMAT_ERROR).
+		Build().
+		AppendBytesWithCompression(buffer)
+}
+
+// writeUDP sends the response in data to the client at clientAddr
+func (s *Server) writeUDP(udpConn *net.UDPConn, data []byte, clientAddr *net.UDPAddr) {
 	if s.config.Server.WriteTimeout > 0 {
-		s.udpConn.SetWriteDeadline(time.Now().Add(s.config.Server.WriteTimeout))
+		udpConn.SetWriteDeadline(time.Now().Add(s.config.Server.WriteTimeout))
 	}
 
-	if _, err := s.udpConn.WriteToUDP(responseBytes, clientAddr); err != nil {
-		log.Printf("Failed to send response to %s: %v", clientAddr, err)
+	if _, err := udpConn.WriteToUDP(data, clientAddr); err != nil {
+		logger.Warn("Failed to send response", clientAttr(clientAddr), "error", err)
 	}
 }
 
-func (s *Server) handleTCP() {
+func (s *Server) handleTCP(tcpListener *net.TCPListener) {
 	defer s.wg.Done()
 
 	for {
@@ -282,12 +760,12 @@ func (s *Server) handleTCP() {
 		default:
 		}
 
-		conn, err := s.tcpListener.AcceptTCP()
+		conn, err := tcpListener.AcceptTCP()
 		if err != nil {
-			if s.ctx.Err() != nil {
+			if s.ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
 				return
 			}
-			log.Printf("TCP accept error: %v", err)
+			logger.Error("TCP accept failed", "error", err)
 			continue
 		}
 
@@ -305,33 +783,67 @@ func (s *Server) handleTCPConnection(conn *net.TCPConn) {
 	}
 
 	lengthBuf := make([]byte, 2)
-	if _, err := conn.Read(lengthBuf); err != nil {
-		log.Printf("Failed to read message length: %v", err)
+	if _, err := io.ReadFull(conn, lengthBuf); err != nil {
+		logger.Debug("Failed to read message length", clientAttr(conn.RemoteAddr()), "error", err)
 		return
 	}
 
 	length := (uint16(lengthBuf[0]) << 8) | uint16(lengthBuf[1])
 
-	data := make([]byte, length)
-	if _, err := conn.Read(data); err != nil {
-		log.Printf("Failed to read message data: %v", err)
+	var pool *message.BufferPool // Nil unless TCP queries are read into pooled buffers
+	var buffer *[]byte
+	if s.config.Server.UseBufferPool {
+		pool = requestBuffers
+		buffer = pool.Get(int(length))
+	} else {
+		data := make([]byte, length)
+		buffer = &data
+	}
+	// A short read would leave the bytes of an earlier query in a pooled
+	// buffer, so the query is dropped unless all of it arrives
+	data := *buffer
+	if _, err := io.ReadFull(conn, data); err != nil {
+		logger.Debug("Failed to read message data", clientAttr(conn.RemoteAddr()), "error", err)
+		pool.Put(buffer)
+		return
+	}
+
+	if admitted, refusal := s.admitQuery(data, conn.RemoteAddr()); !admitted {
+		if refusal != nil {
+			s.writeTCP(conn, refusal)
+		}
+		pool.Put(buffer)
 		return
 	}
 
-	request, err := message.NewDNSRequest(data)
+	request, err := message.NewPooledDNSRequest(pool, buffer, s.parseOptions)
 	if err != nil {
-		log.Printf("Failed to parse DNS request: %v", err)
+		logger.Debug("Failed to parse DNS request", clientAttr(conn.RemoteAddr()), "error", err)
+		if wire := s.formatError(data, conn.RemoteAddr(), nil); wire != nil {
+			s.writeTCP(conn, wire)
+		}
+		pool.Put(buffer)
 		return
 	}
+	defer request.Release()
+
+	ctx, cancel := s.queryContext()
+	defer cancel()
 
-	response, err := s.processRequest(request)
+	s.componentsMu.RLock()
+	response, err := s.handler.Handle(ctx, s.newQueryContext(request, conn.RemoteAddr()))
+	s.componentsMu.RUnlock()
 	if err != nil {
-		log.Printf("Failed to process request: %v", err)
+		logger.Warn("Failed to process request", clientAttr(conn.RemoteAddr()), "error", err)
 		response = s.createErrorResponse(request, types.RCODE_SERVER_FAILURE)
 	}
 
-	responseBytes := response.ToBytesWithCompression()
-	responseLength := uint16(len(responseBytes))
+	s.writeTCP(conn, response.ToBytesWithCompression())
+}
+
+// writeTCP sends the response in data prefixed with its length
+func (s *Server) writeTCP(conn *net.TCPConn, data []byte) {
+	responseLength := uint16(len(data))
 
 	if s.config.Server.WriteTimeout > 0 {
 		conn.SetWriteDeadline(time.Now().Add(s.config.Server.WriteTimeout))
@@ -339,58 +851,150 @@ func (s *Server) handleTCPConnection(conn *net.TCPConn) {
 
 	responseLengthBuf := []byte{byte(responseLength >> 8), byte(responseLength)}
 	if _, err := conn.Write(responseLengthBuf); err != nil {
-		log.Printf("Failed to write response length: %v", err)
+		logger.Warn("Failed to write response length", clientAttr(conn.RemoteAddr()), "error", err)
 		return
 	}
 
-	if _, err := conn.Write(responseBytes); err != nil {
-		log.Printf("Failed to write response data: %v", err)
+	if _, err := conn.Write(data); err != nil {
+		logger.Warn("Failed to write response data", clientAttr(conn.RemoteAddr()), "error", err)
+	}
+}
+
+// queryContext returns the context bounding the handling of a single query.
+// It is cancelled when the query timeout elapses or the server shuts down
+func (s *Server) queryContext() (context.Context, context.CancelFunc) {
+	timeout := s.config.Server.QueryTimeout
+	if timeout <= 0 {
+		timeout = s.config.Server.ReadTimeout
+	}
+	if timeout <= 0 {
+		return context.WithCancel(s.ctx)
 	}
+	return context.WithTimeout(s.ctx, timeout)
 }
 
-func (s *Server) processRequest(request *message.DNSRequest) (*message.DNSResponse, error) {
+// processRequest answers a request of a client assigned to view
+func (s *Server) processRequest(ctx context.Context, request *message.DNSRequest, view string) (*message.DNSResponse, error) {
+	if s.config.Responses.AnyQueryMode == "refused" && hasAnyQuestion(request) {
+		return s.createErrorResponse(request, types.RCODE_REFUSED), nil
+	}
+
 	answers := make([]message.DNSAnswer, 0)
+	var authority, additional []message.DNSAnswer
 
 	for _, question := range request.Questions {
-		questionAnswers, err := s.resolveQuestion(question)
+		questionAnswers, err := s.resolveQuestion(ctx, question, view)
 		if err != nil {
-			log.Printf("Failed to resolve question %s: %v", question.Name.String(), err)
-			continue
+			// A query that ran out of time must not be reported as NXDOMAIN
+			if ctx.Err() != nil || errors.Is(err, context.DeadlineExceeded) {
+				return nil, fmt.Errorf("query for %s timed out: %w", question.Name.String(), err)
+			}
+			// Nor must one whose records could not be looked up
+			if errors.Is(err, storage.ErrStorageUnavailable) {
+				return nil, fmt.Errorf("query for %s failed: %w", question.Name.String(), err)
+			}
+			// Nor one the resolver failed, e.g. with its circuit breaker open
+			if isServerFailure(err) {
+				return nil, fmt.Errorf("query for %s failed: %w", question.Name.String(), err)
+			}
+			logger.Debug("Failed to resolve question", "qname", question.Name.String(), "error", err)
 		}
 		answers = append(answers, questionAnswers...)
-	}
+		if request.DNSSECOK() {
+			answers = append(answers, s.rrsigAnswers(ctx, question, questionAnswers, view)...)
+		}
 
-	response := message.GenerateDNSResponse(
-		request.Header.ID,
-		request.Header.Flags,
-		request.Questions,
-		answers,
-	)
+		if len(questionAnswers) > 0 {
+			questionAuthority, questionAdditional := s.authoritySections(ctx, question, view)
+			authority = append(authority, questionAuthority...)
+			additional = append(additional, questionAdditional...)
+		}
+	}
+	additional = s.populateAdditional(ctx, answers, additional, view)
 
+	response := s.buildResponse(request, answers, authority, additional)
 	if len(answers) == 0 {
-		// Set NXDOMAIN flag in response
-		response.Header.Flags |= types.DNSFlag(types.RCODE_NAME_ERROR)
+		for _, question := range request.Questions {
+			s.attachAuthority(ctx, response, s.lookupName(question.Name), view)
+		}
 	}
-
 	return response, nil
 }
 
-func (s *Server) resolveQuestion(question message.DNSQuestion) ([]message.DNSAnswer, error) {
-	// Convert question type bytes to DNSType
-	questionType := types.DNSType(uint16(question.Type[0])<<8 | uint16(question.Type[1]))
-	questionName := question.Name.String()
+// isServerFailure reports whether err is a resolution error reporting
+// SERVFAIL
+func isServerFailure(err error) bool {
+	var resolutionErr *resolver.ResolutionError
+	return errors.As(err, &resolutionErr) && resolutionErr.Type == types.RCODE_SERVER_FAILURE
+}
+
+// buildResponse builds the response to request with its sections. A response
+// without answers reports NXDOMAIN. Record TTLs are clamped to the configured
+// bounds in the response only, so the cache keeps the original TTLs
+func (s *Server) buildResponse(request *message.DNSRequest, answers, authority, additional []message.DNSAnswer) *message.DNSResponse {
+	flags := message.PrepareResponseFlags(request.Header.Flags)
+	builder := message.NewResponseFor(request).
+		AddAnswer(answers...).
+		AddAuthority(authority...).
+		AddAdditional(additional...)
+
+	// Keep NOTIMP set for unsupported opcodes
+	if len(answers) == 0 && flags.Rcode() == types.RCODE_NO_ERROR {
+		builder.SetRcode(types.RCODE_NAME_ERROR)
+	}
+
+	response := builder.Build()
+	s.clampTTLs(response)
+	return response
+}
+
+func (s *Server) resolveQuestion(ctx context.Context, question message.DNSQuestion, view string) ([]message.DNSAnswer, error) {
+	questionType := question.Qtype()
+	name := s.lookupName(question.Name)
+	questionName := name.String()
+
+	// ANY is answered from the records of all types of the name
+	if questionType == types.TYPE_ANY {
+		if answers, err := s.answerAny(ctx, question, questionName, view); err != nil || len(answers) > 0 {
+			return answers, err
+		}
+	}
 
 	// Try to get records from storage first (for authoritative zones)
-	storageRecords, err := s.storage.GetRecords(s.ctx, questionName, questionType)
+	storageRecords, err := s.lookupRecords(ctx, questionName, questionType, view)
 	if err == nil && len(storageRecords) > 0 {
 		return s.recordsToAnswers(storageRecords, question)
 	}
+	// Without its records the storage cannot tell the name does not exist
+	if errors.Is(err, storage.ErrStorageUnavailable) || errors.Is(err, context.DeadlineExceeded) {
+		return nil, err
+	}
+
+	// Names below a DNAME are answered from the target of the DNAME
+	if dnameAnswers, err := s.answerDNAME(ctx, question, name, view); err != nil || len(dnameAnswers) > 0 {
+		return dnameAnswers, err
+	}
+
+	// Answers synthesized from a wildcard are owned by the question name
+	if wildcardRecords := s.lookupWildcard(ctx, name, questionType, view); len(wildcardRecords) > 0 {
+		return s.recordsToAnswers(wildcardRecords, question)
+	}
+
+	// Without AAAA records, answer from the A records through DNS64
+	if questionType == types.TYPE_AAAA {
+		if synthesized := s.synthesizeDNS64(ctx, questionName, view); len(synthesized) > 0 {
+			return s.recordsToAnswers(synthesized, question)
+		}
+	}
 
 	// If no records in storage and resolver is configured, use resolver
 	if s.resolver != nil {
-		resolverCtx, cancel := context.WithTimeout(s.ctx, s.config.Resolver.Timeout)
+		resolverCtx, cancel := context.WithTimeout(ctx, s.config.Resolver.Timeout)
 		defer cancel()
 
+		// Resolvers may keep the question, e.g. in their cache, after the
+		// buffer the request was parsed from is reused
+		question.Name = question.Name.Clone()
 		answers, err := s.resolver.Resolve(resolverCtx, question)
 		if err != nil {
 			return nil, fmt.Errorf("resolver failed: %w", err)
@@ -405,18 +1009,15 @@ func (s *Server) recordsToAnswers(storageRecords []records.DNSRecord, question m
 	answers := make([]message.DNSAnswer, 0, len(storageRecords))
 
 	for _, record := range storageRecords {
-		// Convert question.Class bytes to DNSClass
-		questionClass := types.DNSClass(uint16(question.Class[0])<<8 | uint16(question.Class[1]))
-
 		answer, err := message.NewDNSAnswer(
 			question.Name.ToBytes(),
-			questionClass,
+			question.Qclass(),
 			record.Type(),
 			record.TTL(),
 			record.Data(),
 		)
 		if err != nil {
-			log.Printf("Failed to create answer for record %s: %v", record.Name(), err)
+			logger.Warn("Failed to create answer for record", "name", record.Name(), "error", err)
 			continue
 		}
 		answers = append(answers, *answer)
@@ -426,15 +1027,9 @@ func (s *Server) recordsToAnswers(storageRecords []records.DNSRecord, question m
 }
 
 func (s *Server) createErrorResponse(request *message.DNSRequest, rcode types.DNSRCode) *message.DNSResponse {
-	response := message.GenerateDNSResponse(
-		request.Header.ID,
-		request.Header.Flags,
-		request.Questions,
-		[]message.DNSAnswer{},
-	)
-	// Set the response code in flags
-	response.Header.Flags = (response.Header.Flags & 0xFFF0) | types.DNSFlag(rcode)
-	return response
+	return message.NewResponseFor(request).
+		SetRcode(rcode).
+		Build()
 }
 
 func (s *Server) Close() error {
@@ -444,27 +1039,15 @@ func (s *Server) Close() error {
 		return fmt.Errorf("server already closed")
 	}
 	s.closed = true
-
-	// Keep references to connections while holding the lock
-	udpConn := s.udpConn
-	tcpListener := s.tcpListener
 	s.mu.Unlock()
 
 	s.cancel()
 
-	var errs []error
+	errs := s.closeListeners()
 
-	if udpConn != nil {
-		if err := udpConn.Close(); err != nil {
-			errs = append(errs, fmt.Errorf("failed to close UDP connection: %w", err))
-		}
-	}
-
-	if tcpListener != nil {
-		if err := tcpListener.Close(); err != nil {
-			errs = append(errs, fmt.Errorf("failed to close TCP listener: %w", err))
-		}
-	}
+	// Let a reload in progress finish before closing its components
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
 
 	if s.resolver != nil {
 		if err := s.resolver.Close(); err != nil {
@@ -499,6 +1082,9 @@ func (s *Server) Close() error {
 
 // AddRecord adds a DNS record to the storage (for dynamic updates)
 func (s *Server) AddRecord(record records.DNSRecord) error {
+	s.componentsMu.RLock()
+	defer s.componentsMu.RUnlock()
+
 	if s.storage == nil {
 		return fmt.Errorf("storage not initialized")
 	}
@@ -507,6 +1093,9 @@ func (s *Server) AddRecord(record records.DNSRecord) error {
 
 // RemoveRecord removes a DNS record from storage
 func (s *Server) RemoveRecord(name string, recordType types.DNSType) error {
+	s.componentsMu.RLock()
+	defer s.componentsMu.RUnlock()
+
 	if s.storage == nil {
 		return fmt.Errorf("storage not initialized")
 	}
@@ -515,6 +1104,9 @@ func (s *Server) RemoveRecord(name string, recordType types.DNSType) error {
 
 // GetStorage returns the storage backend (for external management)
 func (s *Server) GetStorage() storage.Storage {
+	s.componentsMu.RLock()
+	defer s.componentsMu.RUnlock()
+
 	return s.storage
 }
 
@@ -525,14 +1117,41 @@ func (s *Server) IsRunning() bool {
 }
 
 func (s *Server) GetConfig() *config.Config {
+	s.componentsMu.RLock()
+	defer s.componentsMu.RUnlock()
+
 	return s.config
 }
 
 func (s *Server) GetStats() ServerStats {
+	s.componentsMu.RLock()
+	defer s.componentsMu.RUnlock()
+
 	return ServerStats{
 		Running: s.IsRunning(),
-		Address: s.config.Server.Address,
-		Type:    "cached-forward", // Always using cached forward resolver
+		Address: s.config.GetServerAddress(),
+		Type:    s.resolverType(),
+
+		BlockedQueries: s.BlockedQueries(),
+		DroppedPackets: s.DroppedPackets(),
+	}
+}
+
+// DroppedPackets returns the number of UDP queries dropped because every
+// worker was busy and the queue was full
+func (s *Server) DroppedPackets() uint64 {
+	return s.droppedPackets.Load()
+}
+
+// resolverType names the configured resolution strategy
+func (s *Server) resolverType() string {
+	switch s.config.Resolver.Mode {
+	case "recursive", "iterative":
+		return "cached-recursive"
+	case "stub":
+		return "stub"
+	default:
+		return "cached-forward"
 	}
 }
 
@@ -540,4 +1159,7 @@ type ServerStats struct {
 	Running bool
 	Address string
 	Type    string
+
+	BlockedQueries uint64 // Queries answered from the blocklist
+	DroppedPackets uint64 // UDP queries dropped with the worker queue full
 }
@@ -0,0 +1,608 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/internal/resolver"
+	"github.com/vadim-su/dnska/internal/rrl"
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/internal/zoneimport"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// keepingResolver keeps the questions it is asked, as a cache does
+type keepingResolver struct {
+	questions []message.DNSQuestion
+}
+
+func (k *keepingResolver) Resolve(ctx context.Context, question message.DNSQuestion) ([]message.DNSAnswer, error) {
+	k.questions = append(k.questions, question)
+	return nil, nil
+}
+
+func (k *keepingResolver) ResolveAll(ctx context.Context, questions []message.DNSQuestion) ([]message.DNSAnswer, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (k *keepingResolver) Close() error {
+	return nil
+}
+
+// newUDPTestServer returns a server answering from records with the full
+// handler chain
+func newUDPTestServer(tb testing.TB, recordList []records.DNSRecord) *Server {
+	tb.Helper()
+
+	store, err := storage.NewMemoryStorage(nil)
+	if err != nil {
+		tb.Fatalf("failed to create storage: %v", err)
+	}
+	tb.Cleanup(func() { store.Close() })
+	if err := store.BatchPutRecords(context.Background(), recordList); err != nil {
+		tb.Fatalf("failed to store records: %v", err)
+	}
+
+	s := &Server{ctx: context.Background(), config: config.DefaultConfig(), storage: store, stats: newStatsCollector(0, 0)}
+	s.handler = s.buildHandler()
+	return s
+}
+
+func TestServer_AnswerUDP(t *testing.T) {
+	s := newUDPTestServer(t, updateZone())
+	client := &net.UDPAddr{IP: net.ParseIP("192.0.2.7"), Port: 5353}
+
+	query := message.GenerateDNSQuery(1234, []message.DNSQuestion{
+		message.NewDNSQuestion(mustDomainName("www.example.org."), types.TYPE_A, types.CLASS_IN),
+	})
+	buffer := make([]byte, 0, 16)
+
+	wire := s.answerUDP(query.ToBytes(), client, buffer)
+	response, err := message.NewDNSResponse(wire)
+	if err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(response.Answers) != 2 {
+		t.Errorf("got %d answers, expected 2", len(response.Answers))
+	}
+
+	// The response is appended to the buffer, growing it as needed
+	if again := s.answerUDP(query.ToBytes(), client, wire[:0]); &again[0] != &wire[0] {
+		t.Error("response was not written to the grown buffer")
+	}
+}
+
+func TestServer_ServesUnknownTypes(t *testing.T) {
+	zone := "$ORIGIN example.org.\n" +
+		"@ 3600 IN SOA ns1 hostmaster 1 3600 600 604800 300\n" +
+		"private 300 IN TYPE65280 \\# 4 0A000001\n"
+	recordList, err := zoneimport.ReadZone(strings.NewReader(zone), "example.org.", zoneimport.TTLPolicy{})
+	if err != nil {
+		t.Fatalf("failed to read zone: %v", err)
+	}
+
+	s := newUDPTestServer(t, nil)
+	if err := s.storage.BatchPutRecords(context.Background(), recordList); !errors.Is(err, storage.ErrInvalidRecord) {
+		t.Fatalf("storing an unknown type without allow_unknown_types returned %v", err)
+	}
+
+	store, err := s.newStorage(config.StorageConfig{Type: "memory", AllowUnknownTypes: true})
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+	if err := store.BatchPutRecords(context.Background(), recordList); err != nil {
+		t.Fatalf("failed to store records: %v", err)
+	}
+	s.storage = store
+
+	client := &net.UDPAddr{IP: net.ParseIP("192.0.2.7"), Port: 5353}
+	for _, tt := range []struct {
+		qtype   types.DNSType
+		answers int
+	}{
+		{types.DNSType(65280), 1},
+		{types.DNSType(65281), 0},
+		{types.TYPE_A, 0},
+	} {
+		query := message.GenerateDNSQuery(1234, []message.DNSQuestion{
+			message.NewDNSQuestion(mustDomainName("private.example.org."), tt.qtype, types.CLASS_IN),
+		})
+		response, err := message.NewDNSResponse(s.answerUDP(query.ToBytes(), client, nil))
+		if err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		if rcode := response.Header.Flags.Rcode(); rcode != types.RCODE_NO_ERROR {
+			t.Errorf("%s query returned %s, expected NOERROR", tt.qtype.Mnemonic(), rcode)
+		}
+		if len(response.Answers) != tt.answers {
+			t.Fatalf("%s query returned %d answers, expected %d", tt.qtype.Mnemonic(), len(response.Answers), tt.answers)
+		}
+		if tt.answers == 1 {
+			answer := response.Answers[0]
+			if answer.Type() != tt.qtype || !bytes.Equal(answer.Data(), []byte{0x0a, 0x00, 0x00, 0x01}) {
+				t.Errorf("answer is %s %X, expected TYPE65280 0A000001", answer.Type().Mnemonic(), answer.Data())
+			}
+		}
+	}
+}
+
+func TestServer_FormatErrorForMalformedQueries(t *testing.T) {
+	s := newUDPTestServer(t, updateZone())
+	s.parseOptions = message.ParseOptions{MaxMessageSize: 512}
+	client := &net.UDPAddr{IP: net.ParseIP("192.0.2.7"), Port: 5353}
+
+	query := message.GenerateDNSQuery(0xBEEF, []message.DNSQuestion{
+		message.NewDNSQuestion(mustDomainName("www.example.org."), types.TYPE_A, types.CLASS_IN),
+	}).ToBytes()
+	oversized := append(append([]byte{}, query...), make([]byte, 600)...)
+
+	for name, data := range map[string][]byte{
+		"truncated question": query[:len(query)-3],
+		"oversized":          oversized,
+	} {
+		wire := s.answerUDP(data, client, nil)
+		if wire == nil {
+			t.Errorf("%s query was not answered", name)
+			continue
+		}
+		response, err := message.NewDNSResponse(wire)
+		if err != nil {
+			t.Fatalf("failed to parse response to %s query: %v", name, err)
+		}
+		if response.Header.ID != 0xBEEF || !response.Header.Flags.IsResponse() {
+			t.Errorf("response to %s query has ID %#x and flags %v", name, response.Header.ID, response.Header.Flags)
+		}
+		if rcode := response.Header.Flags.Rcode(); rcode != types.RCODE_FORMAT_ERROR {
+			t.Errorf("%s query returned %s, expected FORMERR", name, rcode)
+		}
+	}
+
+	response := append([]byte{}, query[:len(query)-3]...)
+	response[2] |= 0x80 // QR
+	for name, data := range map[string][]byte{
+		"short header":       query[:11],
+		"malformed response": response,
+	} {
+		if wire := s.answerUDP(data, client, nil); wire != nil {
+			t.Errorf("%s was answered", name)
+		}
+	}
+}
+
+func TestServer_FormatErrorRateLimit(t *testing.T) {
+	s := newUDPTestServer(t, nil)
+	s.formErrLimit = rrl.NewPerAddress(formErrPerSecond, 0, time.Second)
+	malformed := message.GenerateDNSQuery(1234, []message.DNSQuestion{
+		message.NewDNSQuestion(mustDomainName("www.example.org."), types.TYPE_A, types.CLASS_IN),
+	}).ToBytes()[:14]
+
+	answered := 0
+	client := &net.UDPAddr{IP: net.ParseIP("192.0.2.7"), Port: 5353}
+	for range 2 * formErrPerSecond {
+		if s.answerUDP(malformed, client, nil) != nil {
+			answered++
+		}
+	}
+	if answered != formErrPerSecond {
+		t.Errorf("answered %d malformed queries, expected %d", answered, formErrPerSecond)
+	}
+
+	// Other clients have limits of their own
+	if s.answerUDP(malformed, &net.UDPAddr{IP: net.ParseIP("192.0.2.8"), Port: 5353}, nil) == nil {
+		t.Error("malformed query of another client was not answered")
+	}
+}
+
+func TestServer_ResolverQuestionOutlivesRequest(t *testing.T) {
+	keeping := &keepingResolver{}
+	s := newUDPTestServer(t, nil)
+	s.resolver = keeping
+
+	data := message.GenerateDNSQuery(1234, []message.DNSQuestion{
+		message.NewDNSQuestion(mustDomainName("www.example.net."), types.TYPE_A, types.CLASS_IN),
+	}).ToBytes()
+	s.answerUDP(data, &net.UDPAddr{IP: net.ParseIP("192.0.2.7")}, nil)
+
+	// The next request is read into the same buffer
+	for i := 12; i < len(data); i++ {
+		data[i] = 'x'
+	}
+
+	if len(keeping.questions) != 1 {
+		t.Fatalf("resolver asked %d questions, expected 1", len(keeping.questions))
+	}
+	if name := keeping.questions[0].Name.String(); name != "www.example.net." {
+		t.Errorf("kept question changed with the request buffer: %s", name)
+	}
+}
+
+// gatedResolver answers with one address once release is closed, counting
+// its calls
+type gatedResolver struct {
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (g *gatedResolver) Resolve(ctx context.Context, question message.DNSQuestion) ([]message.DNSAnswer, error) {
+	g.calls.Add(1)
+	<-g.release
+	answer := message.NewDNSAnswerFromParts(question.Name, types.TYPE_A, types.CLASS_IN, 300, net.IPv4(192, 0, 2, 1).To4())
+	return []message.DNSAnswer{*answer}, nil
+}
+
+func (g *gatedResolver) ResolveAll(ctx context.Context, questions []message.DNSQuestion) ([]message.DNSAnswer, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (g *gatedResolver) Close() error {
+	return nil
+}
+
+func TestServer_DeduplicatedQueriesKeepTheirIDs(t *testing.T) {
+	upstream := &gatedResolver{release: make(chan struct{})}
+	s := newUDPTestServer(t, nil)
+	s.resolver = resolver.NewSingleflightResolver(nil, upstream)
+
+	const clients = 10
+	ids := make(chan uint16, clients)
+	for i := range clients {
+		go func() {
+			query := message.GenerateDNSQuery(uint16(1000+i), []message.DNSQuestion{
+				message.NewDNSQuestion(mustDomainName("www.example.net."), types.TYPE_A, types.CLASS_IN),
+			})
+			response, err := message.NewDNSResponse(s.answerUDP(query.ToBytes(), &net.UDPAddr{IP: net.ParseIP("192.0.2.7")}, nil))
+			if err != nil || len(response.Answers) != 1 || response.Header.ID != query.Header.ID {
+				t.Errorf("query %d: response %v, %v, expected one answer with its ID", query.Header.ID, response, err)
+			}
+			ids <- query.Header.ID
+		}()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if deduplicated, _ := resolver.DeduplicatedQueries(s.resolver); deduplicated == clients-1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(upstream.release)
+
+	for range clients {
+		<-ids
+	}
+	if calls := upstream.calls.Load(); calls != 1 {
+		t.Errorf("resolver called %d times, expected 1", calls)
+	}
+}
+
+func TestServer_ConcurrentQueriesKeepTheirIDs(t *testing.T) {
+	s := newUDPTestServer(t, updateZone())
+
+	queries := []struct {
+		id   uint16
+		name string
+	}{
+		{0x1111, "www.example.org."},
+		{0x2222, "alias.example.org."},
+	}
+
+	var wg sync.WaitGroup
+	for _, q := range queries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				query := message.GenerateDNSQuery(q.id, []message.DNSQuestion{
+					message.NewDNSQuestion(mustDomainName(q.name), types.TYPE_A, types.CLASS_IN),
+				})
+				response, err := message.NewDNSResponse(s.answerUDP(query.ToBytes(), &net.UDPAddr{IP: net.ParseIP("192.0.2.7")}, nil))
+				if err != nil {
+					t.Errorf("query %#04x: failed to parse response: %v", q.id, err)
+					return
+				}
+				if response.Header.ID != q.id || len(response.Questions) != 1 || response.Questions[0].Name.String() != q.name {
+					t.Errorf("query %#04x for %s: response ID %#04x for %v", q.id, q.name, response.Header.ID, response.Questions)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// unavailableStorage fails every lookup as a storage reconnecting to its
+// backend does
+type unavailableStorage struct {
+	storage.Storage
+}
+
+func (unavailableStorage) GetRecords(ctx context.Context, name string, recordType types.DNSType) ([]records.DNSRecord, error) {
+	return nil, storage.ErrStorageUnavailable
+}
+
+func TestServer_StorageUnavailable(t *testing.T) {
+	s := newUDPTestServer(t, updateZone())
+	s.storage = unavailableStorage{s.storage}
+	s.resolver = &keepingResolver{}
+
+	query := message.GenerateDNSQuery(1234, []message.DNSQuestion{
+		message.NewDNSQuestion(mustDomainName("www.example.org."), types.TYPE_A, types.CLASS_IN),
+	})
+	response, err := message.NewDNSResponse(s.answerUDP(query.ToBytes(), &net.UDPAddr{IP: net.ParseIP("192.0.2.7")}, nil))
+	if err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if rcode := response.Header.Flags.Rcode(); rcode != types.RCODE_SERVER_FAILURE {
+		t.Errorf("got %s, expected SERVFAIL", rcode)
+	}
+}
+
+// slowStorage answers lookups only once their context is done
+type slowStorage struct {
+	storage.Storage
+}
+
+func (slowStorage) GetRecords(ctx context.Context, name string, recordType types.DNSType) ([]records.DNSRecord, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestServer_StorageQueryTimeout(t *testing.T) {
+	s := newUDPTestServer(t, updateZone())
+	s.storage = slowStorage{s.storage}
+	s.config.Storage.QueryTimeout = 20 * time.Millisecond
+
+	query := message.GenerateDNSQuery(1234, []message.DNSQuestion{
+		message.NewDNSQuestion(mustDomainName("www.example.org."), types.TYPE_A, types.CLASS_IN),
+	})
+	start := time.Now()
+	response, err := message.NewDNSResponse(s.answerUDP(query.ToBytes(), &net.UDPAddr{IP: net.ParseIP("192.0.2.7")}, nil))
+	if err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if rcode := response.Header.Flags.Rcode(); rcode != types.RCODE_SERVER_FAILURE {
+		t.Errorf("got %s, expected SERVFAIL", rcode)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("answered after %s, expected the storage query timeout", elapsed)
+	}
+}
+
+// serveUDP starts the workers of s and a UDP socket on a free port, and
+// returns a client connected to it
+func serveUDP(t *testing.T, s *Server) *net.UDPConn {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.ctx = ctx
+	s.startUDPWorkers()
+	udpConn, err := s.listenUDP("udp", "127.0.0.1:0", net.ListenConfig{})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		udpConn.Close()
+		s.wg.Wait()
+	})
+
+	client, err := net.DialUDP("udp", nil, udpConn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// sendQuery sends an A query for name with id through client
+func sendQuery(t *testing.T, client *net.UDPConn, id uint16, name string) {
+	t.Helper()
+	query := message.GenerateDNSQuery(id, []message.DNSQuestion{
+		message.NewDNSQuestion(mustDomainName(name), types.TYPE_A, types.CLASS_IN),
+	})
+	if _, err := client.Write(query.ToBytes()); err != nil {
+		t.Fatalf("failed to send query: %v", err)
+	}
+}
+
+// receiveID reads the next response from client and returns its ID
+func receiveID(t *testing.T, client *net.UDPConn) uint16 {
+	t.Helper()
+	return receiveResponse(t, client).Header.ID
+}
+
+// receiveResponse reads the next response from client
+func receiveResponse(t *testing.T, client *net.UDPConn) *message.DNSResponse {
+	t.Helper()
+	buffer := make([]byte, 512)
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := client.Read(buffer)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	response, err := message.NewDNSResponse(buffer[:n])
+	if err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	return response
+}
+
+func TestServer_UDPWorkers(t *testing.T) {
+	s := newUDPTestServer(t, append(updateZone(),
+		records.NewARecord("slow.example.org.", net.ParseIP("192.0.2.9"), 300)))
+	s.config.Server.Workers = 4
+
+	// Queries for slow.example.org. are held until released
+	release := make(chan struct{})
+	next := s.handler
+	s.handler = HandlerFunc(func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+		if query.Request.Questions[0].Name.String() == "slow.example.org." {
+			<-release
+		}
+		return next.Handle(ctx, query)
+	})
+	client := serveUDP(t, s)
+
+	sendQuery(t, client, 1, "slow.example.org.")
+	pending := make(map[uint16]bool)
+	for id := uint16(2); id <= 10; id++ {
+		sendQuery(t, client, id, "www.example.org.")
+		pending[id] = true
+	}
+
+	// The other queries are answered while the slow one is held, in
+	// whatever order the workers finish them
+	for range len(pending) {
+		id := receiveID(t, client)
+		if !pending[id] {
+			t.Fatalf("unexpected response %d while the slow query is held", id)
+		}
+		delete(pending, id)
+	}
+
+	close(release)
+	if id := receiveID(t, client); id != 1 {
+		t.Errorf("got response %d, expected the slow query 1", id)
+	}
+}
+
+func TestServer_UDPQueueFull(t *testing.T) {
+	s := newUDPTestServer(t, updateZone())
+	s.config.Server.Workers = 1
+	s.config.Server.UDPQueueSize = 1
+
+	release := make(chan struct{})
+	next := s.handler
+	s.handler = HandlerFunc(func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+		<-release
+		return next.Handle(ctx, query)
+	})
+	client := serveUDP(t, s)
+	defer close(release)
+
+	// One query is held by the worker and one waits in the queue, so at
+	// least three of the five are dropped
+	for id := uint16(1); id <= 5; id++ {
+		sendQuery(t, client, id, "www.example.org.")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for s.DroppedPackets() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("dropped %d queries, expected at least 3", s.DroppedPackets())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stats := s.GetStats(); stats.DroppedPackets != s.DroppedPackets() {
+		t.Errorf("GetStats() reports %d dropped queries, expected %d", stats.DroppedPackets, s.DroppedPackets())
+	}
+}
+
+func TestServer_CircuitOpenServerFailure(t *testing.T) {
+	upstream, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start fake upstream: %v", err)
+	}
+	defer upstream.Close()
+
+	// Upstream failing every query
+	var packets atomic.Int32
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := upstream.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			packets.Add(1)
+
+			query, err := message.NewDNSResponse(buf[:n])
+			if err != nil || len(query.Questions) != 1 {
+				continue
+			}
+			reply := message.NewResponse(query.Header.ID).
+				WithFlags(types.FLAG_QR_RESPONSE | types.FLAG_RCODE_SERVER_FAILURE).
+				AddQuestion(query.Questions[0]).
+				Build()
+			upstream.WriteTo(reply.ToBytes(), addr)
+		}
+	}()
+
+	resolverConfig := resolver.DefaultResolverConfig()
+	resolverConfig.ForwardServers = []string{upstream.LocalAddr().String()}
+	resolverConfig.MaxRetries = 0
+	resolverConfig.CircuitBreaker = resolver.CircuitBreakerConfig{FailureThreshold: 1, FailureWindow: time.Minute, Cooldown: time.Minute}
+	forwarder, err := resolver.NewForwardResolver(resolverConfig)
+	if err != nil {
+		t.Fatalf("failed to create forward resolver: %v", err)
+	}
+
+	s := newUDPTestServer(t, updateZone())
+	s.resolver = forwarder
+	client := serveUDP(t, s)
+
+	// The first query opens the circuit, the second fails without a query
+	for id := uint16(1); id <= 2; id++ {
+		sendQuery(t, client, id, "www.example.com.")
+		if rcode := receiveResponse(t, client).Header.Flags.Rcode(); rcode != types.RCODE_SERVER_FAILURE {
+			t.Errorf("query %d: got %s, expected SERVFAIL", id, rcode)
+		}
+	}
+	if state, _ := forwarder.CircuitState(); state != resolver.CircuitOpen {
+		t.Errorf("CircuitState() = %s, expected open", state)
+	}
+	if got := packets.Load(); got != 1 {
+		t.Errorf("upstream received %d queries, expected 1", got)
+	}
+}
+
+func TestServer_UnreachableUpstreamServerFailure(t *testing.T) {
+	// Queries to port 0 fail at once, as they do without a route to the
+	// upstream, rather than running into the query deadline
+	resolverConfig := resolver.DefaultResolverConfig()
+	resolverConfig.ForwardServers = []string{"127.0.0.1:0"}
+	resolverConfig.MaxRetries = 0
+	forwarder, err := resolver.NewForwardResolver(resolverConfig)
+	if err != nil {
+		t.Fatalf("failed to create forward resolver: %v", err)
+	}
+
+	s := newUDPTestServer(t, updateZone())
+	s.resolver = forwarder
+	client := serveUDP(t, s)
+
+	start := time.Now()
+	sendQuery(t, client, 1, "www.example.com.")
+	if rcode := receiveResponse(t, client).Header.Flags.Rcode(); rcode != types.RCODE_SERVER_FAILURE {
+		t.Errorf("got %s, expected SERVFAIL", rcode)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("answered after %s, expected the failed query to be answered at once", elapsed)
+	}
+}
+
+func BenchmarkHandleQuery(b *testing.B) {
+	s := newUDPTestServer(b, updateZone())
+	client := &net.UDPAddr{IP: net.ParseIP("192.0.2.7"), Port: 5353}
+	data := message.GenerateDNSQuery(1234, []message.DNSQuestion{
+		message.NewDNSQuestion(mustDomainName("www.example.org."), types.TYPE_A, types.CLASS_IN),
+	}).ToBytes()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		buffer := responseBuffers.Get().(*[]byte)
+		*buffer = s.answerUDP(data, client, (*buffer)[:0])
+		responseBuffers.Put(buffer)
+	}
+}
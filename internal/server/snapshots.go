@@ -0,0 +1,168 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// snapshotReport describes a zone snapshot in the JSON bodies of
+// /api/v1/zones/{zone}/snapshots
+type snapshotReport struct {
+	ID        string    `json:"id"`
+	Zone      string    `json:"zone"`
+	Records   int       `json:"records"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// snapshotListReport is the JSON body of GET /api/v1/zones/{zone}/snapshots
+type snapshotListReport struct {
+	Zone      string           `json:"zone"`
+	Snapshots []snapshotReport `json:"snapshots"`
+}
+
+// handleCreateSnapshot saves the records of the zone, answering 201 with
+// the new snapshot, or 404 when the zone has no records
+func (s *Server) handleCreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	zone, store, ok := s.snapshotZone(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := store.SnapshotZone(r.Context(), zone)
+	if err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+
+	snapshot, err := findSnapshot(r, store, zone, id)
+	if err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+
+	logger.Info("Saved zone snapshot", "zone", zone, "snapshot", id, "records", snapshot.Records)
+	writeSnapshotJSON(w, http.StatusCreated, newSnapshotReport(snapshot))
+}
+
+// handleListSnapshots lists the snapshots of the zone, oldest first
+func (s *Server) handleListSnapshots(w http.ResponseWriter, r *http.Request) {
+	zone, store, ok := s.snapshotZone(w, r)
+	if !ok {
+		return
+	}
+
+	snapshots, err := store.ListSnapshots(r.Context(), zone)
+	if err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+
+	report := snapshotListReport{Zone: zone, Snapshots: make([]snapshotReport, len(snapshots))}
+	for i, snapshot := range snapshots {
+		report.Snapshots[i] = newSnapshotReport(snapshot)
+	}
+	writeSnapshotJSON(w, http.StatusOK, report)
+}
+
+// handleRestoreSnapshot replaces the records of the zone with those of one
+// of its snapshots, which is kept
+func (s *Server) handleRestoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	zone, store, ok := s.snapshotZone(w, r)
+	if !ok {
+		return
+	}
+
+	snapshot, err := findSnapshot(r, store, zone, r.PathValue("id"))
+	if err == nil {
+		err = store.RestoreSnapshot(r.Context(), snapshot.ID)
+	}
+	if err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+
+	logger.Info("Restored zone from snapshot", "zone", zone, "snapshot", snapshot.ID, "client", r.RemoteAddr)
+	writeSnapshotJSON(w, http.StatusOK, newSnapshotReport(snapshot))
+}
+
+// handleDeleteSnapshot removes a snapshot of the zone
+func (s *Server) handleDeleteSnapshot(w http.ResponseWriter, r *http.Request) {
+	zone, store, ok := s.snapshotZone(w, r)
+	if !ok {
+		return
+	}
+
+	snapshot, err := findSnapshot(r, store, zone, r.PathValue("id"))
+	if err == nil {
+		err = store.DeleteSnapshot(r.Context(), snapshot.ID)
+	}
+	if err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// snapshotZone returns the zone of a snapshot request and the storage,
+// answering 400 when the zone name is invalid
+func (s *Server) snapshotZone(w http.ResponseWriter, r *http.Request) (string, storage.Storage, bool) {
+	zone, err := utils.ParseDomainName(r.PathValue("zone"))
+	if err != nil {
+		http.Error(w, "invalid zone name: "+err.Error(), http.StatusBadRequest)
+		return "", nil, false
+	}
+
+	s.componentsMu.RLock()
+	store := s.storage
+	s.componentsMu.RUnlock()
+
+	return zone.CanonicalString(), store, true
+}
+
+// findSnapshot returns the snapshot of zone with the ID, so a snapshot can
+// only be used under the zone it was taken of
+func findSnapshot(r *http.Request, store storage.Storage, zone, id string) (storage.SnapshotInfo, error) {
+	snapshots, err := store.ListSnapshots(r.Context(), zone)
+	if err != nil {
+		return storage.SnapshotInfo{}, err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.ID == id {
+			return snapshot, nil
+		}
+	}
+	return storage.SnapshotInfo{}, storage.ErrSnapshotNotFound
+}
+
+// newSnapshotReport converts a snapshot for a JSON body
+func newSnapshotReport(snapshot storage.SnapshotInfo) snapshotReport {
+	return snapshotReport{ID: snapshot.ID, Zone: snapshot.Zone, Records: snapshot.Records, CreatedAt: snapshot.CreatedAt.UTC()}
+}
+
+// writeSnapshotError answers with the status matching err: 404 for a
+// missing snapshot or a zone without records, 400 for an invalid zone and
+// 503 when the storage failed
+func writeSnapshotError(w http.ResponseWriter, err error) {
+	status := http.StatusServiceUnavailable
+	switch {
+	case errors.Is(err, storage.ErrSnapshotNotFound), errors.Is(err, storage.ErrRecordNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrInvalidZone), errors.Is(err, storage.ErrInvalidName):
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// writeSnapshotJSON sends body as the JSON body of a response with status
+func writeSnapshotJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+func TestServer_ZoneSnapshots(t *testing.T) {
+	s := newUDPTestServer(t, updateZone())
+	ctx := context.Background()
+
+	// serve routes a request as the health mux would
+	serve := func(method, zone, id, action string) *httptest.ResponseRecorder {
+		target := "/api/v1/zones/" + zone + "/snapshots"
+		if id != "" {
+			target += "/" + id + action
+		}
+		request := httptest.NewRequest(method, target, nil)
+		request.SetPathValue("zone", zone)
+		request.SetPathValue("id", id)
+
+		recorder := httptest.NewRecorder()
+		switch {
+		case method == http.MethodPost && id == "":
+			s.handleCreateSnapshot(recorder, request)
+		case method == http.MethodGet:
+			s.handleListSnapshots(recorder, request)
+		case method == http.MethodPost:
+			s.handleRestoreSnapshot(recorder, request)
+		default:
+			s.handleDeleteSnapshot(recorder, request)
+		}
+		return recorder
+	}
+
+	recorder := serve(http.MethodPost, "example.org", "", "")
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, expected %d: %s", recorder.Code, http.StatusCreated, recorder.Body)
+	}
+	var created snapshotReport
+	if err := json.NewDecoder(recorder.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode snapshot: %v", err)
+	}
+	if created.ID == "" || created.Zone != "example.org." || created.Records != len(updateZone()) {
+		t.Errorf("snapshot = %+v, expected zone example.org. with %d records", created, len(updateZone()))
+	}
+
+	if recorder := serve(http.MethodPost, "example.net", "", ""); recorder.Code != http.StatusNotFound {
+		t.Errorf("snapshot of a zone without records status = %d, expected %d", recorder.Code, http.StatusNotFound)
+	}
+
+	// A bad import replaces an address, and restoring brings it back
+	bad := records.NewARecord("www.example.org.", net.ParseIP("198.51.100.1"), 300)
+	if err := s.storage.ReplaceRRSet(ctx, "www.example.org.", types.TYPE_A, []records.DNSRecord{bad}); err != nil {
+		t.Fatalf("failed to replace records: %v", err)
+	}
+	if recorder := serve(http.MethodPost, "example.com", created.ID, "/restore"); recorder.Code != http.StatusNotFound {
+		t.Errorf("restore under another zone status = %d, expected %d", recorder.Code, http.StatusNotFound)
+	}
+	if recorder := serve(http.MethodPost, "example.org", created.ID, "/restore"); recorder.Code != http.StatusOK {
+		t.Fatalf("restore status = %d, expected %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+	}
+
+	restored, err := s.storage.ListRecordsByZone(ctx, "example.org.")
+	if err != nil {
+		t.Fatalf("failed to list records: %v", err)
+	}
+	if diff := storage.DiffRecordSets(updateZone(), restored); !diff.Empty() {
+		t.Errorf("restored zone differs from the snapshot: %s", diff.Summary())
+	}
+
+	recorder = serve(http.MethodGet, "example.org", "", "")
+	var list snapshotListReport
+	if err := json.NewDecoder(recorder.Body).Decode(&list); err != nil {
+		t.Fatalf("failed to decode snapshots: %v", err)
+	}
+	if len(list.Snapshots) != 1 || list.Snapshots[0].ID != created.ID {
+		t.Errorf("snapshots = %+v, expected %s", list.Snapshots, created.ID)
+	}
+
+	if recorder := serve(http.MethodDelete, "example.org", created.ID, ""); recorder.Code != http.StatusNoContent {
+		t.Errorf("delete status = %d, expected %d", recorder.Code, http.StatusNoContent)
+	}
+	if recorder := serve(http.MethodPost, "example.org", created.ID, "/restore"); recorder.Code != http.StatusNotFound {
+		t.Errorf("restore of a deleted snapshot status = %d, expected %d", recorder.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,319 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"hash/maphash"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/resolver"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+const (
+	// statsBucketWidth is the span of time one bucket of the query
+	// statistics counts
+	statsBucketWidth = time.Minute
+
+	// statsShards is the number of separately locked shards of a counter map
+	statsShards = 16
+
+	// statsMaxShardKeys caps the distinct keys a shard counts per bucket, so
+	// a flood of random names or spoofed clients cannot grow it unbounded
+	statsMaxShardKeys = 1024
+)
+
+// statsSeed seeds the hash spreading counter keys over shards
+var statsSeed = maphash.MakeSeed()
+
+// QueryStats summarizes the queries answered within the statistics window
+type QueryStats struct {
+	Window      time.Duration     `json:"-"`
+	Queries     uint64            `json:"queries"`
+	ByType      map[string]uint64 `json:"by_type"`  // Questions by type
+	ByRcode     map[string]uint64 `json:"by_rcode"` // Responses by RCODE, SERVFAIL for failed queries
+	CacheHits   uint64            `json:"cache_hits"`
+	CacheMisses uint64            `json:"cache_misses"`
+	TopNames    []StatsCount      `json:"top_names"`   // Most queried names, most queries first
+	TopClients  []StatsCount      `json:"top_clients"` // Client IPs sending the most queries
+}
+
+// StatsCount is a name or a client with the number of its queries
+type StatsCount struct {
+	Key   string `json:"key"`
+	Count uint64 `json:"count"`
+}
+
+// statsSample is what the statistics count of one answered query
+type statsSample struct {
+	types       []types.DNSType
+	names       []string
+	client      string
+	rcode       types.DNSRCode
+	cacheHits   uint64
+	cacheMisses uint64
+}
+
+// statsCollector keeps rolling query statistics in a ring of per-minute
+// buckets. Counting takes no lock but those of the counter shards the keys
+// of a query fall in; a bucket is only locked to be reset for a new minute
+type statsCollector struct {
+	buckets []statsBucket
+	topN    int
+}
+
+// statsBucket counts the queries of one minute
+type statsBucket struct {
+	mu     sync.Mutex   // Serializes resetting the bucket with reading it
+	minute atomic.Int64 // Minute since the epoch the counters belong to
+
+	queries     atomic.Uint64
+	rcodes      [32]atomic.Uint64 // By RCODE, including the extended ones up to BADCOOKIE
+	cacheHits   atomic.Uint64
+	cacheMisses atomic.Uint64
+	types       counterMap
+	names       counterMap
+	clients     counterMap
+}
+
+// counterMap counts string keys in shards locked separately
+type counterMap struct {
+	shards [statsShards]counterShard
+}
+
+type counterShard struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+// newStatsCollector returns a collector covering window, rounded up to
+// whole minutes, that lists the topN names and clients
+func newStatsCollector(window time.Duration, topN int) *statsCollector {
+	size := int((window + statsBucketWidth - 1) / statsBucketWidth)
+	if size < 1 {
+		size = 1
+	}
+	return &statsCollector{
+		buckets: make([]statsBucket, size),
+		topN:    topN,
+	}
+}
+
+// window returns the span of time the statistics cover
+func (c *statsCollector) window() time.Duration {
+	return time.Duration(len(c.buckets)) * statsBucketWidth
+}
+
+// record counts a query answered at now
+func (c *statsCollector) record(now time.Time, sample statsSample) {
+	bucket := c.bucket(now)
+
+	bucket.queries.Add(1)
+	bucket.rcodes[sample.rcode&0x1F].Add(1)
+	bucket.cacheHits.Add(sample.cacheHits)
+	bucket.cacheMisses.Add(sample.cacheMisses)
+	for _, recordType := range sample.types {
+		bucket.types.add(typeName(recordType))
+	}
+	for _, name := range sample.names {
+		bucket.names.add(name)
+	}
+	if sample.client != "" {
+		bucket.clients.add(sample.client)
+	}
+}
+
+// bucket returns the bucket counting the minute of now, resetting it when
+// it still holds the counters of an earlier lap of the ring
+func (c *statsCollector) bucket(now time.Time) *statsBucket {
+	minute := now.UnixNano() / int64(statsBucketWidth)
+	bucket := &c.buckets[minute%int64(len(c.buckets))]
+	if bucket.minute.Load() < minute {
+		bucket.reset(minute)
+	}
+	return bucket
+}
+
+// reset clears the counters of the bucket for minute unless another query
+// already did
+func (b *statsBucket) reset(minute int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.minute.Load() >= minute {
+		return
+	}
+
+	b.queries.Store(0)
+	for i := range b.rcodes {
+		b.rcodes[i].Store(0)
+	}
+	b.cacheHits.Store(0)
+	b.cacheMisses.Store(0)
+	b.types.reset()
+	b.names.reset()
+	b.clients.reset()
+	b.minute.Store(minute)
+}
+
+// snapshot sums the buckets within the window ending at now
+func (c *statsCollector) snapshot(now time.Time) QueryStats {
+	current := now.UnixNano() / int64(statsBucketWidth)
+	stats := QueryStats{
+		Window:  c.window(),
+		ByType:  make(map[string]uint64),
+		ByRcode: make(map[string]uint64),
+	}
+	names := make(map[string]uint64)
+	clients := make(map[string]uint64)
+
+	for i := range c.buckets {
+		bucket := &c.buckets[i]
+		bucket.mu.Lock()
+
+		minute := bucket.minute.Load()
+		if minute > current || current-minute >= int64(len(c.buckets)) {
+			bucket.mu.Unlock()
+			continue
+		}
+
+		stats.Queries += bucket.queries.Load()
+		for rcode := range bucket.rcodes {
+			if count := bucket.rcodes[rcode].Load(); count > 0 {
+				stats.ByRcode[rcodeName(types.DNSRCode(rcode))] += count
+			}
+		}
+		stats.CacheHits += bucket.cacheHits.Load()
+		stats.CacheMisses += bucket.cacheMisses.Load()
+		bucket.types.sumInto(stats.ByType)
+		bucket.names.sumInto(names)
+		bucket.clients.sumInto(clients)
+
+		bucket.mu.Unlock()
+	}
+
+	stats.TopNames = topCounts(names, c.topN)
+	stats.TopClients = topCounts(clients, c.topN)
+	return stats
+}
+
+// add counts one occurrence of key. Keys new to a full shard are dropped
+func (m *counterMap) add(key string) {
+	shard := &m.shards[maphash.String(statsSeed, key)%statsShards]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if shard.counts == nil {
+		shard.counts = make(map[string]uint64)
+	}
+	if _, ok := shard.counts[key]; ok || len(shard.counts) < statsMaxShardKeys {
+		shard.counts[key]++
+	}
+}
+
+// reset forgets every key
+func (m *counterMap) reset() {
+	for i := range m.shards {
+		shard := &m.shards[i]
+		shard.mu.Lock()
+		shard.counts = nil
+		shard.mu.Unlock()
+	}
+}
+
+// sumInto adds the counts of every key to totals
+func (m *counterMap) sumInto(totals map[string]uint64) {
+	for i := range m.shards {
+		shard := &m.shards[i]
+		shard.mu.Lock()
+		for key, count := range shard.counts {
+			totals[key] += count
+		}
+		shard.mu.Unlock()
+	}
+}
+
+// topCounts returns the n keys with the highest counts, ties broken by key
+func topCounts(counts map[string]uint64, n int) []StatsCount {
+	top := make([]StatsCount, 0, len(counts))
+	for key, count := range counts {
+		top = append(top, StatsCount{Key: key, Count: count})
+	}
+	slices.SortFunc(top, func(a, b StatsCount) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// typeName returns the mnemonic of recordType, or TYPEnnn for types
+// without one
+func typeName(recordType types.DNSType) string {
+	if name := recordType.String(); name != "UNKNOWN" {
+		return name
+	}
+	return fmt.Sprintf("TYPE%d", uint16(recordType))
+}
+
+// rcodeName returns the mnemonic of rcode, or RCODEnn for codes without one
+func rcodeName(rcode types.DNSRCode) string {
+	if name := rcode.String(); name != "UNKNOWN" {
+		return name
+	}
+	return fmt.Sprintf("RCODE%d", uint16(rcode))
+}
+
+// QueryStats returns the statistics of the queries answered within the
+// configured window
+func (s *Server) QueryStats() QueryStats {
+	return s.stats.snapshot(time.Now())
+}
+
+// statsRecorder counts every query in the query statistics once the rest
+// of the chain answered it. A query failing with an error counts as SERVFAIL
+func (s *Server) statsRecorder(next Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+		var lookup resolver.CacheLookup
+		response, err := next.Handle(resolver.WithCacheLookup(ctx, &lookup), query)
+
+		sample := statsSample{
+			rcode:       types.RCODE_SERVER_FAILURE,
+			cacheHits:   lookup.Hits.Load(),
+			cacheMisses: lookup.Misses.Load(),
+		}
+		if err == nil && response != nil {
+			sample.rcode = response.Rcode()
+		}
+		if ip := addrIP(query.ClientAddr); ip != nil {
+			sample.client = ip.String()
+		}
+		for _, question := range query.Request.Questions {
+			sample.types = append(sample.types, question.Qtype())
+			sample.names = append(sample.names, question.Name.CanonicalString())
+		}
+		now := time.Now()
+		s.stats.record(now, sample)
+
+		if logger.Enabled(ctx, slog.LevelDebug) {
+			logger.DebugContext(ctx, "Answered query",
+				"client", sample.client,
+				"qname", strings.Join(sample.names, " "),
+				"rcode", sample.rcode.String(),
+				"duration", now.Sub(query.Received))
+		}
+
+		return response, err
+	})
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// statsStart is a minute boundary the synthetic queries are counted from
+var statsStart = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// querySample returns the sample of an A query for name from client
+func querySample(name, client string, rcode types.DNSRCode) statsSample {
+	return statsSample{
+		types:  []types.DNSType{types.TYPE_A},
+		names:  []string{name},
+		client: client,
+		rcode:  rcode,
+	}
+}
+
+func TestStatsCollector_Counts(t *testing.T) {
+	c := newStatsCollector(5*time.Minute, 10)
+
+	c.record(statsStart, querySample("www.example.com.", "192.0.2.1", types.RCODE_NO_ERROR))
+	c.record(statsStart.Add(time.Second), statsSample{
+		types:       []types.DNSType{types.TYPE_AAAA, types.DNSType(65280)},
+		names:       []string{"www.example.com.", "mail.example.com."},
+		client:      "192.0.2.2",
+		rcode:       types.RCODE_NAME_ERROR,
+		cacheHits:   1,
+		cacheMisses: 1,
+	})
+	c.record(statsStart.Add(2*time.Minute), querySample("www.example.com.", "192.0.2.1", types.RCODE_SERVER_FAILURE))
+
+	stats := c.snapshot(statsStart.Add(2 * time.Minute))
+
+	if stats.Window != 5*time.Minute {
+		t.Errorf("Window = %v, want 5m", stats.Window)
+	}
+	if stats.Queries != 3 {
+		t.Errorf("Queries = %d, want 3", stats.Queries)
+	}
+	if expected := map[string]uint64{"A": 2, "AAAA": 1, "TYPE65280": 1}; !reflect.DeepEqual(stats.ByType, expected) {
+		t.Errorf("ByType = %v, want %v", stats.ByType, expected)
+	}
+	if expected := map[string]uint64{"NOERROR": 1, "NXDOMAIN": 1, "SERVFAIL": 1}; !reflect.DeepEqual(stats.ByRcode, expected) {
+		t.Errorf("ByRcode = %v, want %v", stats.ByRcode, expected)
+	}
+	if stats.CacheHits != 1 || stats.CacheMisses != 1 {
+		t.Errorf("cache hits/misses = %d/%d, want 1/1", stats.CacheHits, stats.CacheMisses)
+	}
+}
+
+func TestStatsCollector_Rotation(t *testing.T) {
+	c := newStatsCollector(3*time.Minute, 10)
+
+	for minute := range 5 {
+		now := statsStart.Add(time.Duration(minute) * time.Minute)
+		for range minute + 1 {
+			c.record(now, querySample("www.example.com.", "192.0.2.1", types.RCODE_NO_ERROR))
+		}
+	}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected uint64
+	}{
+		// Minutes 2, 3 and 4 hold 3, 4 and 5 queries; minutes 0 and 1 were
+		// overwritten by 3 and 4
+		{name: "last minute of the window", now: statsStart.Add(4*time.Minute + 30*time.Second), expected: 12},
+		{name: "one minute later", now: statsStart.Add(5 * time.Minute), expected: 9},
+		{name: "window passed", now: statsStart.Add(7 * time.Minute), expected: 0},
+		{name: "clock behind the buckets", now: statsStart.Add(3 * time.Minute), expected: 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if queries := c.snapshot(tt.now).Queries; queries != tt.expected {
+				t.Errorf("Queries = %d, want %d", queries, tt.expected)
+			}
+		})
+	}
+
+	// A new lap of the ring starts the reused bucket from zero
+	c.record(statsStart.Add(5*time.Minute), querySample("www.example.com.", "192.0.2.1", types.RCODE_NO_ERROR))
+	if queries := c.snapshot(statsStart.Add(5 * time.Minute)).Queries; queries != 10 {
+		t.Errorf("Queries after reusing a bucket = %d, want 10", queries)
+	}
+}
+
+func TestStatsCollector_TopN(t *testing.T) {
+	c := newStatsCollector(10*time.Minute, 2)
+
+	queries := []struct {
+		name   string
+		client string
+		count  int
+	}{
+		{name: "a.example.com.", client: "192.0.2.1", count: 3},
+		{name: "b.example.com.", client: "192.0.2.2", count: 5},
+		{name: "c.example.com.", client: "192.0.2.3", count: 3},
+		{name: "d.example.com.", client: "192.0.2.4", count: 1},
+	}
+	for i, q := range queries {
+		// Spread the queries of a name over two buckets
+		for n := range q.count {
+			now := statsStart.Add(time.Duration(i+n%2) * time.Minute)
+			c.record(now, querySample(q.name, q.client, types.RCODE_NO_ERROR))
+		}
+	}
+
+	stats := c.snapshot(statsStart.Add(5 * time.Minute))
+
+	expectedNames := []StatsCount{{Key: "b.example.com.", Count: 5}, {Key: "a.example.com.", Count: 3}}
+	if !reflect.DeepEqual(stats.TopNames, expectedNames) {
+		t.Errorf("TopNames = %v, want %v", stats.TopNames, expectedNames)
+	}
+	expectedClients := []StatsCount{{Key: "192.0.2.2", Count: 5}, {Key: "192.0.2.1", Count: 3}}
+	if !reflect.DeepEqual(stats.TopClients, expectedClients) {
+		t.Errorf("TopClients = %v, want %v", stats.TopClients, expectedClients)
+	}
+}
+
+func TestStatsCollector_CapsKeys(t *testing.T) {
+	c := newStatsCollector(time.Minute, 1)
+
+	for i := range statsShards * statsMaxShardKeys * 2 {
+		c.record(statsStart, querySample(fmt.Sprintf("host%d.example.com.", i), "192.0.2.1", types.RCODE_NO_ERROR))
+	}
+
+	names := make(map[string]uint64)
+	c.buckets[0].names.sumInto(names)
+	if len(names) > statsShards*statsMaxShardKeys {
+		t.Errorf("bucket counts %d names, want at most %d", len(names), statsShards*statsMaxShardKeys)
+	}
+	if queries := c.snapshot(statsStart).Queries; queries != statsShards*statsMaxShardKeys*2 {
+		t.Errorf("Queries = %d, want %d", queries, statsShards*statsMaxShardKeys*2)
+	}
+}
+
+func TestStatsCollector_Concurrent(t *testing.T) {
+	c := newStatsCollector(2*time.Minute, 10)
+
+	var wg sync.WaitGroup
+	for worker := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 1000 {
+				now := statsStart.Add(time.Duration(i/500) * time.Minute)
+				c.record(now, querySample("www.example.com.", fmt.Sprintf("192.0.2.%d", worker), types.RCODE_NO_ERROR))
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := c.snapshot(statsStart.Add(time.Minute))
+	if stats.Queries != 8000 {
+		t.Errorf("Queries = %d, want 8000", stats.Queries)
+	}
+	if len(stats.TopClients) != 8 {
+		t.Errorf("TopClients lists %d clients, want 8", len(stats.TopClients))
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"net"
+
+	"github.com/vadim-su/dnska/internal/resolver"
+	"github.com/vadim-su/dnska/pkg/dns/edns"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// clientSubnets handles the EDNS Client Subnet option (ECS, RFC 7871) of
+// queries: the subnet is sent on to the forward servers when configured,
+// and echoed in the response with the scope the answer is valid for
+func (s *Server) clientSubnets(next Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+		request := query.Request
+		subnet := request.ClientSubnet
+		if subnet == nil || request.Header.Flags.Opcode() != types.OPCODE_QUERY {
+			return next.Handle(ctx, query)
+		}
+
+		// The resolver records the scope of the upstream answer in its copy
+		var forwarded *edns.ClientSubnet
+		if s.config.EDNS.ForwardECS && subnet.SourcePrefixLength > 0 {
+			forwarded = &edns.ClientSubnet{
+				Family:             subnet.Family,
+				SourcePrefixLength: subnet.SourcePrefixLength,
+				Address:            subnet.Address,
+			}
+			ctx = resolver.WithClientSubnet(ctx, forwarded)
+		}
+
+		response, err := next.Handle(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		echoed := *subnet
+		echoed.ScopePrefixLength = s.subnetScope(subnet, forwarded)
+		response.AddClientSubnet(&echoed)
+		return response, nil
+	})
+}
+
+// subnetScope returns the prefix length of the client networks an answer
+// for subnet is valid for: that of the upstream answer when the subnet was
+// forwarded, the whole subnet when it selected the view, and every network
+// otherwise
+func (s *Server) subnetScope(subnet, forwarded *edns.ClientSubnet) uint8 {
+	switch {
+	case forwarded != nil && forwarded.ScopePrefixLength > 0:
+		return forwarded.ScopePrefixLength
+	case len(s.views) > 0:
+		return subnet.SourcePrefixLength
+	}
+	return 0
+}
+
+// subnetAddr returns the address clients are assigned to views by: that of
+// the client subnet of request, if it has one, or clientAddr. A zero source
+// prefix length asks for the subnet not to be used (RFC 7871 §7.1.2)
+func subnetAddr(request *message.DNSRequest, clientAddr net.Addr) net.Addr {
+	if subnet := request.ClientSubnet; subnet != nil && subnet.SourcePrefixLength > 0 {
+		return &net.IPAddr{IP: subnet.IP()}
+	}
+	return clientAddr
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/internal/resolver"
+	"github.com/vadim-su/dnska/pkg/dns/edns"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// subnetQuery returns a query for name carrying subnet, parsed as the server
+// would
+func subnetQuery(t *testing.T, name string, subnet *edns.ClientSubnet) *message.DNSRequest {
+	t.Helper()
+
+	query := message.GenerateDNSQuery(1234, []message.DNSQuestion{message.NewDNSQuestion(mustDomainName(name), types.TYPE_A, types.CLASS_IN)})
+	if subnet != nil {
+		query.AddClientSubnet(subnet)
+	}
+
+	request, err := message.NewDNSRequest(query.ToBytes())
+	if err != nil {
+		t.Fatalf("failed to parse request: %v", err)
+	}
+	return request
+}
+
+func TestClientSubnets(t *testing.T) {
+	views, err := newViews([]config.ViewConfig{{Name: "lan", Networks: []string{"10.0.0.0/8"}}})
+	if err != nil {
+		t.Fatalf("failed to create views: %v", err)
+	}
+	public := &net.UDPAddr{IP: net.ParseIP("198.51.100.1"), Port: 53}
+
+	tests := []struct {
+		name     string
+		views    []view
+		forward  bool
+		subnet   *edns.ClientSubnet
+		view     string
+		echoed   string
+		upstream uint8 // Scope of the upstream answer
+	}{
+		{name: "without a subnet", views: views, view: "default"},
+		{name: "subnet selects the view", views: views, subnet: edns.NewClientSubnet(net.ParseIP("10.1.2.3"), 24), view: "lan", echoed: "10.1.2.0/24/24"},
+		{name: "opted out", views: views, subnet: edns.NewClientSubnet(net.ParseIP("10.1.2.3"), 0), view: "default", echoed: "0.0.0.0/0/0"},
+		{name: "without views", subnet: edns.NewClientSubnet(net.ParseIP("10.1.2.3"), 24), view: "default", echoed: "10.1.2.0/24/0"},
+		{
+			name:     "forwarded",
+			forward:  true,
+			subnet:   edns.NewClientSubnet(net.ParseIP("2001:db8::1"), 56),
+			view:     "default",
+			echoed:   "2001:db8::/56/48",
+			upstream: 48,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.EDNS.ForwardECS = tt.forward
+			s := &Server{config: cfg, views: tt.views}
+
+			query := s.newQueryContext(subnetQuery(t, "www.example.com", tt.subnet), public)
+			if query.View != tt.view {
+				t.Errorf("view = %s, expected %s", query.View, tt.view)
+			}
+
+			next := HandlerFunc(func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+				// Stands in for the forward resolver
+				if forwarded := resolver.ClientSubnet(ctx); forwarded != nil {
+					forwarded.ScopePrefixLength = tt.upstream
+				} else if tt.forward {
+					t.Error("subnet was not passed to the resolver")
+				}
+				return s.createErrorResponse(query.Request, types.RCODE_NO_ERROR), nil
+			})
+			response, err := Chain(next, s.clientSubnets).Handle(context.Background(), query)
+			if err != nil {
+				t.Fatalf("Handle() returned error: %v", err)
+			}
+
+			echoed := response.ClientSubnet()
+			if tt.echoed == "" {
+				if echoed != nil {
+					t.Errorf("response carries client subnet %s", echoed)
+				}
+				return
+			}
+			if echoed == nil || echoed.String() != tt.echoed {
+				t.Errorf("echoed client subnet = %v, expected %s", echoed, tt.echoed)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/tsig"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// maxTransferSize is the largest zone transfer served: transfers are sent in
+// a single TCP message, which leaves room for a TSIG record
+const maxTransferSize = 65535 - 512
+
+// zoneTransfers serves AXFR requests (RFC 5936) for zones with a stored SOA
+// over TCP, passing other requests on. When a transfer key is configured,
+// requests must be signed with it (TSIG, RFC 8945); the signatures
+// middleware verifies them and signs the transfers
+func (s *Server) zoneTransfers(next Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+		request := query.Request
+		if len(request.Questions) != 1 {
+			return next.Handle(ctx, query)
+		}
+		question := request.Questions[0]
+		if question.Qtype() != types.TYPE_AXFR {
+			return next.Handle(ctx, query)
+		}
+
+		return s.transferZone(ctx, query)
+	})
+}
+
+// transferZone answers an AXFR request with the whole zone in one message
+func (s *Server) transferZone(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+	request := query.Request
+	zone := request.Questions[0].Name
+
+	if _, ok := query.ClientAddr.(*net.TCPAddr); !ok {
+		return s.createErrorResponse(request, types.RCODE_REFUSED), nil
+	}
+
+	if !s.transferAllowed(query) {
+		logger.Warn("Refused zone transfer not signed with the transfer key", "zone", zone.String(), clientAttr(query.ClientAddr))
+		return s.createErrorResponse(request, types.RCODE_REFUSED), nil
+	}
+
+	soa := storedSOA(ctx, s.storage, zone.String())
+	if soa == nil {
+		logger.Warn("Refused transfer of a zone that is not stored", "zone", zone.String(), clientAttr(query.ClientAddr))
+		return s.createErrorResponse(request, types.RCODE_NOT_AUTH), nil
+	}
+
+	stored, err := s.storage.ListRecordsByZone(ctx, zone.String())
+	if err != nil && !errors.Is(err, storage.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to list records of zone %s: %w", zone.String(), err)
+	}
+
+	zoneRecords := []records.DNSRecord{soa}
+	for _, record := range stored {
+		if record.Type() == types.TYPE_SOA || storage.RecordView(record) != storage.DefaultView {
+			continue
+		}
+		if name, err := utils.ParseDomainName(record.Name()); err == nil && name.IsSubdomainOf(zone) {
+			zoneRecords = append(zoneRecords, record)
+		}
+	}
+	zoneRecords = append(zoneRecords, soa)
+
+	flags := types.NewFlagBuilder(message.PrepareResponseFlags(request.Header.Flags)).SetAA(true).Build()
+	response := message.NewResponseFor(request).
+		WithFlags(flags).
+		AddAnswer(recordAnswers(zoneRecords)...).
+		Build()
+	if size := len(response.ToBytesWithCompression()); size > maxTransferSize {
+		logger.Error("Failed to transfer zone that does not fit in a single message", "zone", zone.String(), clientAttr(query.ClientAddr), "bytes", size)
+		return s.createErrorResponse(request, types.RCODE_SERVER_FAILURE), nil
+	}
+
+	logger.Info("Transferred zone", "zone", zone.String(), "serial", soa.Serial(), clientAttr(query.ClientAddr))
+	return response, nil
+}
+
+// transferAllowed reports whether a transfer may be served to query: with a
+// transfer key configured, the request must have been signed with it
+func (s *Server) transferAllowed(query *QueryContext) bool {
+	if s.config.Transfer.Key == "" {
+		return true
+	}
+
+	record, _ := query.Value(tsigValue).(*tsig.TSIGRecord)
+	return record != nil && record.KeyName.CanonicalString() == canonicalKeyName(s.config.Transfer.Key)
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/tsig"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// tsigValue is the QueryContext key of the verified TSIG record of a signed
+// request
+const tsigValue = "tsig"
+
+// signatures verifies signed requests (TSIG, RFC 8945) with the configured
+// keys and signs the responses to them. Requests whose signature does not
+// verify are answered NOTAUTH with the TSIG error; unsigned requests are
+// passed on as they are
+func (s *Server) signatures(next Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+		request := query.Request
+		record := request.TSIG()
+		if record == nil {
+			return next.Handle(ctx, query)
+		}
+
+		if err := s.verifyRequest(request, record); err != nil {
+			logger.Warn("Rejected signed request", clientAttr(query.ClientAddr), "error", err)
+			return s.tsigErrorResponse(request, record, err), nil
+		}
+		query.Set(tsigValue, record)
+
+		response, err := next.Handle(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		return s.signResponse(response, record)
+	})
+}
+
+// verifyRequest checks the signature of request, whose TSIG record is
+// record, with the configured key of the same name and algorithm
+func (s *Server) verifyRequest(request *message.DNSRequest, record *tsig.TSIGRecord) error {
+	if err := request.ValidateTSIG(s.config.TSIG.Keyring()); err != nil {
+		return err
+	}
+
+	key, _ := s.config.TSIG.Key(record.KeyName.String())
+	if algorithm, _ := tsig.ParseAlgorithm(key.Algorithm); record.Algorithm != algorithm {
+		return fmt.Errorf("%w: %s with %s", tsig.ErrBadKey, record.KeyName.String(), record.Algorithm)
+	}
+	return nil
+}
+
+// tsigErrorResponse answers a request whose signature failed verification
+// with err. The response carries an unsigned TSIG record with the error
+// (RFC 8945 §5.3.2), or is FORMERR when the TSIG record is malformed
+func (s *Server) tsigErrorResponse(request *message.DNSRequest, record *tsig.TSIGRecord, err error) *message.DNSResponse {
+	code := tsig.ErrorCode(err)
+	if code == 0 {
+		return s.createErrorResponse(request, types.RCODE_FORMAT_ERROR)
+	}
+
+	response := s.createErrorResponse(request, types.RCODE_NOT_AUTH)
+	response.AddTSIG(&tsig.TSIGRecord{
+		KeyName:    record.KeyName,
+		Algorithm:  record.Algorithm,
+		TimeSigned: uint64(time.Now().Unix()),
+		Fudge:      record.Fudge,
+		OriginalID: record.OriginalID,
+		Error:      code,
+	})
+	return response
+}
+
+// signResponse signs the response to a request signed with request, using
+// the same key
+func (s *Server) signResponse(response *message.DNSResponse, request *tsig.TSIGRecord) (*message.DNSResponse, error) {
+	secret := s.config.TSIG.Keyring()[request.KeyName.CanonicalString()]
+	record, err := tsig.NewTSIGRecord(request.KeyName.String(), request.Algorithm, secret, uint64(time.Now().Unix()), tsig.DefaultFudge, nil, response.Header.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign response: %w", err)
+	}
+	if err := response.SignTSIG(record, secret, request.MAC); err != nil {
+		return nil, fmt.Errorf("failed to sign response: %w", err)
+	}
+	return response, nil
+}
+
+// canonicalKeyName returns the name of a TSIG key lowercased with a trailing
+// dot, as used in keyrings
+func canonicalKeyName(name string) string {
+	return normalizeZone(name)
+}
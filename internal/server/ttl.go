@@ -0,0 +1,39 @@
+package server
+
+import (
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// clampTTLs bounds the TTLs of the records of response by the configured
+// response minimum and maximum. OPT records are left alone, their TTL field
+// carries EDNS flags
+func (s *Server) clampTTLs(response *message.DNSResponse) {
+	minTTL, maxTTL := s.config.Responses.MinTTL, s.config.Responses.MaxTTL
+	if minTTL <= 0 && maxTTL <= 0 {
+		return
+	}
+
+	for _, section := range [][]message.DNSAnswer{response.Answers, response.AuthorityRecords, response.AdditionalRecords} {
+		for i := range section {
+			if section[i].Type() == types.TYPE_OPT {
+				continue
+			}
+			section[i].SetTTL(clampTTL(section[i].TTL(), minTTL, maxTTL))
+		}
+	}
+}
+
+// clampTTL raises ttl to minTTL and lowers it to maxTTL, ignoring bounds that
+// are zero
+func clampTTL(ttl uint32, minTTL, maxTTL time.Duration) uint32 {
+	if minTTL > 0 {
+		ttl = max(ttl, uint32(minTTL/time.Second))
+	}
+	if maxTTL > 0 {
+		ttl = min(ttl, uint32(maxTTL/time.Second))
+	}
+	return ttl
+}
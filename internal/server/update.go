@@ -0,0 +1,425 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/tsig"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// updates answers dynamic updates (RFC 2136) of zones with a stored SOA,
+// passing other requests on. Updates must be signed with the update key, if
+// one is configured, and come from the update networks, if any are listed
+func (s *Server) updates(next Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, query *QueryContext) (*message.DNSResponse, error) {
+		request := query.Request
+		if request.Header.Flags.Opcode() != types.OPCODE_UPDATE {
+			return next.Handle(ctx, query)
+		}
+
+		if !s.updateAllowed(query) {
+			logger.Warn("Refused update from a client not allowed to update zones", clientAttr(query.ClientAddr))
+			return updateResponse(request, types.RCODE_REFUSED), nil
+		}
+
+		update, err := message.NewUpdateMessage(request)
+		if err != nil {
+			logger.Warn("Rejected update", clientAttr(query.ClientAddr), "error", err)
+			return updateResponse(request, types.RCODE_FORMAT_ERROR), nil
+		}
+
+		rcode, err := s.handleUpdate(ctx, update, query.ClientAddr)
+		if err != nil {
+			return nil, err
+		}
+		return updateResponse(request, rcode), nil
+	})
+}
+
+// updateAllowed reports whether query may update zones: signed with the
+// update key, if one is configured, and sent from an update network, if any
+// are configured
+func (s *Server) updateAllowed(query *QueryContext) bool {
+	if s.config.Update.Key != "" {
+		record, _ := query.Value(tsigValue).(*tsig.TSIGRecord)
+		if record == nil || record.KeyName.CanonicalString() != canonicalKeyName(s.config.Update.Key) {
+			return false
+		}
+	}
+	if len(s.config.Update.Networks) == 0 {
+		return true
+	}
+
+	ip := addrIP(query.ClientAddr)
+	for _, network := range s.config.Update.Networks {
+		if _, ipNet, err := net.ParseCIDR(network); err == nil && ip != nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// updateResponse answers an update with rcode, echoing its zone section
+func updateResponse(request *message.DNSRequest, rcode types.DNSRCode) *message.DNSResponse {
+	flags := types.NewFlagBuilder(request.Header.Flags).SetQR(true).Build()
+	return message.NewResponseFor(request).
+		WithFlags(flags).
+		SetRcode(rcode).
+		Build()
+}
+
+// handleUpdate checks the prerequisites of update against its zone and
+// applies the updates (RFC 2136 §3) in a single transaction, incrementing the
+// SOA serial unless the update replaced the SOA itself. It returns the RCODE
+// the update is answered with; updates are serialized
+func (s *Server) handleUpdate(ctx context.Context, update *message.DNSUpdateMessage, clientAddr net.Addr) (types.DNSRCode, error) {
+	zone := update.Zone.Name
+	zoneName := normalizeZone(zone.String())
+	if class := update.Zone.Qclass(); class != types.CLASS_IN {
+		logger.Warn("Refused update of another class", "zone", zoneName, clientAttr(clientAddr), "class", class.String())
+		return types.RCODE_NOT_AUTH, nil
+	}
+	if s.secondaries != nil && s.secondaries.zones[zoneName] != nil {
+		logger.Warn("Refused update of secondary zone", "zone", zoneName, clientAttr(clientAddr))
+		return types.RCODE_REFUSED, nil
+	}
+
+	s.updateMu.Lock()
+	defer s.updateMu.Unlock()
+
+	soa := storedSOA(ctx, s.storage, zoneName)
+	if soa == nil {
+		logger.Warn("Refused update of a zone that is not stored", "zone", zoneName, clientAttr(clientAddr))
+		return types.RCODE_NOT_AUTH, nil
+	}
+
+	stored, err := s.storage.ListRecordsByZone(ctx, zoneName)
+	if err != nil && !errors.Is(err, storage.ErrRecordNotFound) {
+		return 0, fmt.Errorf("failed to list records of zone %s: %w", zoneName, err)
+	}
+
+	// Updates apply to the records without a view; the others are kept
+	var current, viewRecords []records.DNSRecord
+	for _, record := range stored {
+		if name, err := utils.ParseDomainName(record.Name()); err != nil || !name.IsSubdomainOf(zone) {
+			continue
+		}
+		if storage.RecordView(record) != storage.DefaultView {
+			viewRecords = append(viewRecords, record)
+			continue
+		}
+		current = append(current, record)
+	}
+
+	if rcode := checkPrerequisites(update, zone, current); rcode != types.RCODE_NO_ERROR {
+		logger.Info("Rejected update whose prerequisites failed", "zone", zoneName, clientAttr(clientAddr), "rcode", rcode.String())
+		return rcode, nil
+	}
+	if rcode := prescanUpdates(update, zone); rcode != types.RCODE_NO_ERROR {
+		logger.Warn("Rejected update", "zone", zoneName, clientAttr(clientAddr), "rcode", rcode.String())
+		return rcode, nil
+	}
+	updated, rcode := applyUpdates(update, zone, current)
+	if rcode != types.RCODE_NO_ERROR {
+		logger.Warn("Rejected update", "zone", zoneName, clientAttr(clientAddr), "rcode", rcode.String())
+		return rcode, nil
+	}
+
+	diff := storage.DiffRecordSets(current, updated)
+	if diff.Empty() {
+		return types.RCODE_NO_ERROR, nil
+	}
+	if !updatesSOA(diff) {
+		updated = incrementSerial(updated)
+	}
+
+	oldSet := append(append([]records.DNSRecord(nil), viewRecords...), current...)
+	newSet := append(append([]records.DNSRecord(nil), viewRecords...), updated...)
+	diff = storage.DiffRecordSets(oldSet, newSet)
+	if err := storage.ApplyDiff(ctx, s.storage, diff); err != nil {
+		return 0, fmt.Errorf("failed to update zone %s: %w", zoneName, err)
+	}
+
+	logger.Info("Updated zone", "zone", zoneName, clientAttr(clientAddr), "changes", diff.Summary())
+	return types.RCODE_NO_ERROR, nil
+}
+
+// checkPrerequisites checks the prerequisite section of update against the
+// records of zone (RFC 2136 §3.2), returning NOERROR when all of them hold
+func checkPrerequisites(update *message.DNSUpdateMessage, zone utils.DomainName, current []records.DNSRecord) types.DNSRCode {
+	// Value-dependent prerequisites are compared by RRset once all are read
+	var expected []records.DNSRecord
+	for _, prerequisite := range update.Prerequisites {
+		if prerequisite.TTL() != 0 {
+			return types.RCODE_FORMAT_ERROR
+		}
+		name := prerequisite.Name()
+		if !name.IsSubdomainOf(zone) {
+			return types.RCODE_NOT_ZONE
+		}
+
+		switch prerequisite.Class() {
+		case types.CLASS_ANY:
+			if len(prerequisite.Data()) != 0 {
+				return types.RCODE_FORMAT_ERROR
+			}
+			if prerequisite.Type() == types.TYPE_ANY {
+				if !nameInUse(current, name.String()) {
+					return types.RCODE_NAME_ERROR
+				}
+			} else if len(rrset(current, name.String(), prerequisite.Type())) == 0 {
+				return types.RCODE_NXRRSET
+			}
+		case types.CLASS_NONE:
+			if len(prerequisite.Data()) != 0 {
+				return types.RCODE_FORMAT_ERROR
+			}
+			if prerequisite.Type() == types.TYPE_ANY {
+				if nameInUse(current, name.String()) {
+					return types.RCODE_YXDOMAIN
+				}
+			} else if len(rrset(current, name.String(), prerequisite.Type())) != 0 {
+				return types.RCODE_YXRRSET
+			}
+		case types.CLASS_IN:
+			record, err := update.DecodeRecord(prerequisite)
+			if errors.Is(err, message.ErrUnsupportedRData) {
+				// Records of types that cannot be stored never exist
+				return types.RCODE_NXRRSET
+			}
+			if err != nil {
+				return types.RCODE_FORMAT_ERROR
+			}
+			expected = append(expected, record)
+		default:
+			return types.RCODE_FORMAT_ERROR
+		}
+	}
+
+	for _, record := range expected {
+		if !sameRRset(rrset(expected, record.Name(), record.Type()), rrset(current, record.Name(), record.Type())) {
+			return types.RCODE_NXRRSET
+		}
+	}
+	return types.RCODE_NO_ERROR
+}
+
+// prescanUpdates checks the update section of update for records outside
+// zone and malformed deletions (RFC 2136 §3.4.1) before any is applied
+func prescanUpdates(update *message.DNSUpdateMessage, zone utils.DomainName) types.DNSRCode {
+	for _, record := range update.Updates {
+		if name := record.Name(); !name.IsSubdomainOf(zone) {
+			return types.RCODE_NOT_ZONE
+		}
+
+		switch record.Class() {
+		case types.CLASS_IN:
+			if record.Type() == types.TYPE_ANY || isMetaType(record.Type()) {
+				return types.RCODE_FORMAT_ERROR
+			}
+		case types.CLASS_ANY:
+			if record.TTL() != 0 || len(record.Data()) != 0 || isMetaType(record.Type()) {
+				return types.RCODE_FORMAT_ERROR
+			}
+		case types.CLASS_NONE:
+			if record.TTL() != 0 || record.Type() == types.TYPE_ANY || isMetaType(record.Type()) {
+				return types.RCODE_FORMAT_ERROR
+			}
+		default:
+			return types.RCODE_FORMAT_ERROR
+		}
+	}
+	return types.RCODE_NO_ERROR
+}
+
+// applyUpdates returns the records of zone after the update section of update
+// is applied to current (RFC 2136 §3.4.2). The SOA and NS records of the apex
+// are only replaced, never deleted
+func applyUpdates(update *message.DNSUpdateMessage, zone utils.DomainName, current []records.DNSRecord) ([]records.DNSRecord, types.DNSRCode) {
+	apex := normalizeZone(zone.String())
+	updated := append([]records.DNSRecord(nil), current...)
+
+	for _, rr := range update.Updates {
+		owner := rr.Name()
+		name := owner.String()
+		atApex := normalizeZone(name) == apex
+
+		switch rr.Class() {
+		case types.CLASS_IN:
+			record, err := update.DecodeRecord(rr)
+			if errors.Is(err, message.ErrUnsupportedRData) {
+				return nil, types.RCODE_NOT_IMPLEMENTED
+			}
+			if err != nil {
+				return nil, types.RCODE_FORMAT_ERROR
+			}
+			updated = addRecord(updated, record, atApex)
+		case types.CLASS_ANY:
+			updated = removeRecords(updated, func(stored records.DNSRecord) bool {
+				if !sameName(stored.Name(), name) {
+					return false
+				}
+				if atApex && (stored.Type() == types.TYPE_SOA || stored.Type() == types.TYPE_NS) {
+					return false
+				}
+				return rr.Type() == types.TYPE_ANY || stored.Type() == rr.Type()
+			})
+		case types.CLASS_NONE:
+			if rr.Type() == types.TYPE_SOA {
+				continue
+			}
+			record, err := update.DecodeRecord(rr)
+			if errors.Is(err, message.ErrUnsupportedRData) {
+				continue
+			}
+			if err != nil {
+				return nil, types.RCODE_FORMAT_ERROR
+			}
+			if atApex && rr.Type() == types.TYPE_NS && len(rrset(updated, name, types.TYPE_NS)) <= 1 {
+				continue
+			}
+			updated = removeRecords(updated, func(stored records.DNSRecord) bool {
+				return storage.SameRecord(stored, record)
+			})
+		}
+	}
+
+	return updated, types.RCODE_NO_ERROR
+}
+
+// addRecord adds record to the set, replacing a record with the same RDATA.
+// CNAME records do not coexist with other types: adding one to a name with
+// other records, or another type to an alias, is ignored. A name has a single
+// DNAME, which replaces the previous one (RFC 6672 §2.4). An SOA replaces
+// the apex one when its serial is newer and is ignored elsewhere
+func addRecord(set []records.DNSRecord, record records.DNSRecord, atApex bool) []records.DNSRecord {
+	name := record.Name()
+	switch record.Type() {
+	case types.TYPE_SOA:
+		soa, _ := soaOf(record)
+		current := rrset(set, name, types.TYPE_SOA)
+		if !atApex || len(current) == 0 {
+			return set
+		}
+		if stored, ok := soaOf(current[0]); !ok || !serialNewer(soa.Serial(), stored.Serial()) {
+			return set
+		}
+		set = removeRecords(set, func(stored records.DNSRecord) bool {
+			return stored.Type() == types.TYPE_SOA && sameName(stored.Name(), name)
+		})
+		return append(set, record)
+	case types.TYPE_CNAME:
+		for _, stored := range set {
+			if sameName(stored.Name(), name) && stored.Type() != types.TYPE_CNAME {
+				return set
+			}
+		}
+		// A name has a single CNAME record
+		set = removeRecords(set, func(stored records.DNSRecord) bool {
+			return stored.Type() == types.TYPE_CNAME && sameName(stored.Name(), name)
+		})
+		return append(set, record)
+	case types.TYPE_DNAME:
+		if len(rrset(set, name, types.TYPE_CNAME)) != 0 {
+			return set
+		}
+		set = removeRecords(set, func(stored records.DNSRecord) bool {
+			return stored.Type() == types.TYPE_DNAME && sameName(stored.Name(), name)
+		})
+		return append(set, record)
+	default:
+		if len(rrset(set, name, types.TYPE_CNAME)) != 0 {
+			return set
+		}
+	}
+
+	set = removeRecords(set, func(stored records.DNSRecord) bool {
+		return storage.SameRecord(stored, record)
+	})
+	return append(set, record)
+}
+
+// updatesSOA reports whether diff replaces the SOA record
+func updatesSOA(diff storage.RecordDiff) bool {
+	for _, record := range diff.Added {
+		if record.Type() == types.TYPE_SOA {
+			return true
+		}
+	}
+	return false
+}
+
+// incrementSerial returns the set with the serial of its SOA record
+// incremented
+func incrementSerial(set []records.DNSRecord) []records.DNSRecord {
+	incremented := make([]records.DNSRecord, 0, len(set))
+	for _, record := range set {
+		if soa, ok := soaOf(record); ok {
+			record = records.NewSOARecord(soa.Name(), soa.PrimaryNS(), soa.Responsible(), soa.Serial()+1,
+				soa.Refresh(), soa.Retry(), soa.Expire(), soa.Minimum(), soa.TTL())
+		}
+		incremented = append(incremented, record)
+	}
+	return incremented
+}
+
+// isMetaType reports whether records of type t only exist in messages and
+// cannot be added to a zone
+func isMetaType(t types.DNSType) bool {
+	switch t {
+	case types.TYPE_AXFR, types.TYPE_OPT, types.TYPE_TSIG:
+		return true
+	}
+	return false
+}
+
+// sameName reports whether two owner names are equal, ignoring case and the
+// trailing dot
+func sameName(a, b string) bool {
+	return normalizeZone(a) == normalizeZone(b)
+}
+
+// nameInUse reports whether the set has records owned by name
+func nameInUse(set []records.DNSRecord, name string) bool {
+	for _, record := range set {
+		if sameName(record.Name(), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// rrset returns the records of the set with the given name and type
+func rrset(set []records.DNSRecord, name string, recordType types.DNSType) []records.DNSRecord {
+	var matching []records.DNSRecord
+	for _, record := range set {
+		if record.Type() == recordType && sameName(record.Name(), name) {
+			matching = append(matching, record)
+		}
+	}
+	return matching
+}
+
+// sameRRset reports whether two RRsets hold the same records, ignoring TTLs
+func sameRRset(a, b []records.DNSRecord) bool {
+	diff := storage.DiffRecordSets(a, b)
+	return len(diff.Added) == 0 && len(diff.Removed) == 0
+}
+
+// removeRecords returns the set without the records remove reports true for
+func removeRecords(set []records.DNSRecord, remove func(record records.DNSRecord) bool) []records.DNSRecord {
+	kept := make([]records.DNSRecord, 0, len(set))
+	for _, record := range set {
+		if !remove(record) {
+			kept = append(kept, record)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,315 @@
+package server
+
+import (
+	"context"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+func updateZone() []records.DNSRecord {
+	return []records.DNSRecord{
+		records.NewSOARecord("example.org.", "ns1.example.org.", "hostmaster.example.org.", 10,
+			time.Hour, 10*time.Minute, 24*time.Hour, 5*time.Minute, 3600),
+		records.NewNSRecord("example.org.", "ns1.example.org.", 3600),
+		records.NewARecord("www.example.org.", net.ParseIP("192.0.2.1"), 300),
+		records.NewARecord("www.example.org.", net.ParseIP("192.0.2.2"), 300),
+		records.NewCNAMERecord("alias.example.org.", "www.example.org.", 300),
+	}
+}
+
+// deletion returns an update or prerequisite entry without RDATA
+func deletion(name string, recordType types.DNSType, class types.DNSClass) message.DNSAnswer {
+	return *message.NewDNSAnswerFromParts(mustDomainName(name), recordType, class, 0, nil)
+}
+
+// prerequisite returns a value-dependent prerequisite for record
+func prerequisite(record records.DNSRecord) message.DNSAnswer {
+	answer := recordAnswers([]records.DNSRecord{record})[0]
+	answer.SetTTL(0)
+	return answer
+}
+
+// storedRecords lists the records of name and type as strings
+func storedRecords(t *testing.T, store storage.Storage, name string, recordType types.DNSType) []string {
+	t.Helper()
+	stored, _ := store.GetRecords(context.Background(), name, recordType)
+	list := make([]string, 0, len(stored))
+	for _, record := range stored {
+		list = append(list, record.String())
+	}
+	sort.Strings(list)
+	return list
+}
+
+func TestServer_HandleUpdate(t *testing.T) {
+	www := records.NewARecord("www.example.org.", net.ParseIP("192.0.2.1"), 300)
+	added := records.NewARecord("new.example.org.", net.ParseIP("192.0.2.3"), 600)
+
+	tests := []struct {
+		name          string
+		zone          string
+		prerequisites []message.DNSAnswer
+		updates       []message.DNSAnswer
+		rcode         types.DNSRCode
+		check         func(t *testing.T, store storage.Storage)
+		serial        uint32
+	}{
+		{
+			name:    "add record",
+			zone:    "example.org.",
+			updates: recordAnswers([]records.DNSRecord{added}),
+			rcode:   types.RCODE_NO_ERROR,
+			serial:  11,
+			check: func(t *testing.T, store storage.Storage) {
+				if got := storedRecords(t, store, "new.example.org.", types.TYPE_A); len(got) != 1 || got[0] != added.String() {
+					t.Errorf("stored %v, expected %s", got, added)
+				}
+			},
+		},
+		{
+			name:          "name not in use",
+			zone:          "example.org.",
+			prerequisites: []message.DNSAnswer{deletion("new.example.org.", types.TYPE_ANY, types.CLASS_NONE)},
+			updates:       recordAnswers([]records.DNSRecord{added}),
+			rcode:         types.RCODE_NO_ERROR,
+			serial:        11,
+		},
+		{
+			name:          "name in use",
+			zone:          "example.org.",
+			prerequisites: []message.DNSAnswer{deletion("www.example.org.", types.TYPE_ANY, types.CLASS_NONE)},
+			updates:       recordAnswers([]records.DNSRecord{added}),
+			rcode:         types.RCODE_YXDOMAIN,
+			serial:        10,
+		},
+		{
+			name:          "name does not exist",
+			zone:          "example.org.",
+			prerequisites: []message.DNSAnswer{deletion("new.example.org.", types.TYPE_ANY, types.CLASS_ANY)},
+			rcode:         types.RCODE_NAME_ERROR,
+			serial:        10,
+		},
+		{
+			name:          "RRset exists",
+			zone:          "example.org.",
+			prerequisites: []message.DNSAnswer{deletion("www.example.org.", types.TYPE_A, types.CLASS_ANY)},
+			updates:       []message.DNSAnswer{deletion("www.example.org.", types.TYPE_A, types.CLASS_ANY)},
+			rcode:         types.RCODE_NO_ERROR,
+			serial:        11,
+			check: func(t *testing.T, store storage.Storage) {
+				if got := storedRecords(t, store, "www.example.org.", types.TYPE_A); len(got) != 0 {
+					t.Errorf("deleted RRset is still stored: %v", got)
+				}
+			},
+		},
+		{
+			name:          "RRset does not exist",
+			zone:          "example.org.",
+			prerequisites: []message.DNSAnswer{deletion("www.example.org.", types.TYPE_AAAA, types.CLASS_ANY)},
+			rcode:         types.RCODE_NXRRSET,
+			serial:        10,
+		},
+		{
+			name:          "RRset does not match",
+			zone:          "example.org.",
+			prerequisites: []message.DNSAnswer{prerequisite(www)},
+			updates:       recordAnswers([]records.DNSRecord{added}),
+			rcode:         types.RCODE_NXRRSET,
+			serial:        10,
+		},
+		{
+			name: "RRset matches",
+			zone: "example.org.",
+			prerequisites: []message.DNSAnswer{
+				prerequisite(www),
+				prerequisite(records.NewARecord("www.example.org.", net.ParseIP("192.0.2.2"), 60)),
+			},
+			updates: recordAnswers([]records.DNSRecord{added}),
+			rcode:   types.RCODE_NO_ERROR,
+			serial:  11,
+		},
+		{
+			name:    "delete record",
+			zone:    "example.org.",
+			updates: []message.DNSAnswer{*message.NewDNSAnswerFromParts(mustDomainName("www.example.org."), types.TYPE_A, types.CLASS_NONE, 0, www.Data())},
+			rcode:   types.RCODE_NO_ERROR,
+			serial:  11,
+			check: func(t *testing.T, store storage.Storage) {
+				got := storedRecords(t, store, "www.example.org.", types.TYPE_A)
+				if len(got) != 1 || got[0] != records.NewARecord("www.example.org.", net.ParseIP("192.0.2.2"), 300).String() {
+					t.Errorf("stored %v, expected only 192.0.2.2", got)
+				}
+			},
+		},
+		{
+			name:    "delete all records of the apex",
+			zone:    "example.org.",
+			updates: []message.DNSAnswer{deletion("example.org.", types.TYPE_ANY, types.CLASS_ANY)},
+			rcode:   types.RCODE_NO_ERROR,
+			serial:  10,
+			check: func(t *testing.T, store storage.Storage) {
+				if got := storedRecords(t, store, "example.org.", types.TYPE_NS); len(got) != 1 {
+					t.Errorf("apex NS records were deleted: %v", got)
+				}
+			},
+		},
+		{
+			name:    "delete all records of a name",
+			zone:    "example.org.",
+			updates: []message.DNSAnswer{deletion("alias.example.org.", types.TYPE_ANY, types.CLASS_ANY)},
+			rcode:   types.RCODE_NO_ERROR,
+			serial:  11,
+			check: func(t *testing.T, store storage.Storage) {
+				if got := storedRecords(t, store, "alias.example.org.", types.TYPE_CNAME); len(got) != 0 {
+					t.Errorf("deleted records are still stored: %v", got)
+				}
+			},
+		},
+		{
+			name:    "record next to an alias",
+			zone:    "example.org.",
+			updates: recordAnswers([]records.DNSRecord{records.NewARecord("alias.example.org.", net.ParseIP("192.0.2.4"), 300)}),
+			rcode:   types.RCODE_NO_ERROR,
+			serial:  10,
+			check: func(t *testing.T, store storage.Storage) {
+				if got := storedRecords(t, store, "alias.example.org.", types.TYPE_A); len(got) != 0 {
+					t.Errorf("record was added next to a CNAME: %v", got)
+				}
+			},
+		},
+		{
+			name:    "DNAME at an alias",
+			zone:    "example.org.",
+			updates: recordAnswers([]records.DNSRecord{records.NewDNAMERecord("alias.example.org.", "example.net.", 300)}),
+			rcode:   types.RCODE_NO_ERROR,
+			serial:  10,
+			check: func(t *testing.T, store storage.Storage) {
+				if got := storedRecords(t, store, "alias.example.org.", types.TYPE_DNAME); len(got) != 0 {
+					t.Errorf("DNAME was added next to a CNAME: %v", got)
+				}
+			},
+		},
+		{
+			name: "second DNAME replaces the first",
+			zone: "example.org.",
+			updates: recordAnswers([]records.DNSRecord{
+				records.NewDNAMERecord("old.example.org.", "example.net.", 300),
+				records.NewDNAMERecord("old.example.org.", "example.com.", 300),
+			}),
+			rcode:  types.RCODE_NO_ERROR,
+			serial: 11,
+			check: func(t *testing.T, store storage.Storage) {
+				got := storedRecords(t, store, "old.example.org.", types.TYPE_DNAME)
+				if len(got) != 1 || got[0] != "old.example.org. 300 IN DNAME example.com." {
+					t.Errorf("stored DNAME records = %v, expected the second one only", got)
+				}
+			},
+		},
+		{
+			name: "new SOA",
+			zone: "example.org.",
+			updates: recordAnswers([]records.DNSRecord{records.NewSOARecord("example.org.", "ns1.example.org.", "hostmaster.example.org.", 20,
+				time.Hour, 10*time.Minute, 24*time.Hour, 5*time.Minute, 3600)}),
+			rcode:  types.RCODE_NO_ERROR,
+			serial: 20,
+		},
+		{
+			name:    "name outside the zone",
+			zone:    "example.org.",
+			updates: recordAnswers([]records.DNSRecord{records.NewARecord("www.example.com.", net.ParseIP("192.0.2.5"), 300)}),
+			rcode:   types.RCODE_NOT_ZONE,
+			serial:  10,
+		},
+		{
+			name:    "deletion with a TTL",
+			zone:    "example.org.",
+			updates: []message.DNSAnswer{*message.NewDNSAnswerFromParts(mustDomainName("www.example.org."), types.TYPE_A, types.CLASS_ANY, 300, nil)},
+			rcode:   types.RCODE_FORMAT_ERROR,
+			serial:  10,
+		},
+		{
+			name:    "unknown zone",
+			zone:    "example.net.",
+			updates: recordAnswers([]records.DNSRecord{records.NewARecord("www.example.net.", net.ParseIP("192.0.2.5"), 300)}),
+			rcode:   types.RCODE_NOT_AUTH,
+			serial:  10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store, err := storage.NewMemoryStorage(nil)
+			if err != nil {
+				t.Fatalf("failed to create storage: %v", err)
+			}
+			defer store.Close()
+			if err := store.BatchPutRecords(ctx, updateZone()); err != nil {
+				t.Fatalf("failed to store records: %v", err)
+			}
+
+			s := &Server{config: config.DefaultConfig(), storage: store}
+			query := message.GenerateUpdate(1234, mustDomainName(tt.zone), tt.prerequisites, tt.updates)
+			update, err := message.ParseUpdateMessage(query.ToBytesWithCompression())
+			if err != nil {
+				t.Fatalf("failed to parse update: %v", err)
+			}
+
+			rcode, err := s.handleUpdate(ctx, update, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			if err != nil {
+				t.Fatalf("handleUpdate() returned error: %v", err)
+			}
+			if rcode != tt.rcode {
+				t.Errorf("handleUpdate() = %s, expected %s", rcode, tt.rcode)
+			}
+			if soa := storedSOA(ctx, store, "example.org."); soa == nil || soa.Serial() != tt.serial {
+				t.Errorf("stored SOA = %v, expected serial %d", soa, tt.serial)
+			}
+			if tt.check != nil {
+				tt.check(t, store)
+			}
+		})
+	}
+}
+
+func TestServer_UpdateAllowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		networks []string
+		client   net.Addr
+		allowed  bool
+	}{
+		{name: "any network", client: &net.UDPAddr{IP: net.ParseIP("198.51.100.1")}, allowed: true},
+		{name: "listed network", networks: []string{"192.0.2.0/24"}, client: &net.TCPAddr{IP: net.ParseIP("192.0.2.7")}, allowed: true},
+		{name: "other network", networks: []string{"192.0.2.0/24"}, client: &net.UDPAddr{IP: net.ParseIP("198.51.100.1")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.Update.Networks = tt.networks
+			s := &Server{config: cfg}
+
+			if allowed := s.updateAllowed(&QueryContext{ClientAddr: tt.client}); allowed != tt.allowed {
+				t.Errorf("updateAllowed() = %v, expected %v", allowed, tt.allowed)
+			}
+		})
+	}
+
+	t.Run("unsigned with a key", func(t *testing.T) {
+		cfg := config.DefaultConfig()
+		cfg.Update.Key = "update-key"
+		s := &Server{config: cfg}
+		if s.updateAllowed(&QueryContext{ClientAddr: &net.UDPAddr{IP: net.ParseIP("192.0.2.7")}}) {
+			t.Error("unsigned update allowed with an update key")
+		}
+	})
+}
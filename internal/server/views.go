@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// view is a split-horizon view clients are assigned to by their address
+type view struct {
+	name     string
+	networks []*net.IPNet
+}
+
+// newViews parses the configured views in the order they are matched
+func newViews(cfgs []config.ViewConfig) ([]view, error) {
+	ordered := make([]config.ViewConfig, len(cfgs))
+	copy(ordered, cfgs)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Priority < ordered[j].Priority
+	})
+
+	views := make([]view, 0, len(ordered))
+	for _, cfg := range ordered {
+		v := view{name: cfg.Name}
+		for _, network := range cfg.Networks {
+			_, ipNet, err := net.ParseCIDR(network)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network of view %s: %w", cfg.Name, err)
+			}
+			v.networks = append(v.networks, ipNet)
+		}
+		views = append(views, v)
+	}
+
+	return views, nil
+}
+
+// clientView returns the view serving a client, the default view when the
+// client belongs to none
+func (s *Server) clientView(addr net.Addr) string {
+	if len(s.views) == 0 {
+		return storage.DefaultView
+	}
+
+	ip := addrIP(addr)
+	if ip == nil {
+		return storage.DefaultView
+	}
+
+	for _, v := range s.views {
+		for _, network := range v.networks {
+			if network.Contains(ip) {
+				return v.name
+			}
+		}
+	}
+
+	return storage.DefaultView
+}
+
+// addrIP returns the IP address of a UDP, TCP or IP client address, or nil
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP
+	case *net.TCPAddr:
+		return a.IP
+	case *net.IPAddr:
+		return a.IP
+	}
+	return nil
+}
+
+// lookupRecords returns the stored records of name and type for clients of
+// view, falling back to the default view when view holds none. The lookup
+// is bounded by the storage query timeout
+func (s *Server) lookupRecords(ctx context.Context, name string, recordType types.DNSType, view string) ([]records.DNSRecord, error) {
+	if timeout := s.config.Storage.QueryTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if len(s.views) == 0 {
+		return s.storage.GetRecords(ctx, name, recordType)
+	}
+
+	if view != storage.DefaultView {
+		viewRecords, err := s.storage.QueryRecords(ctx, storage.QueryOptions{Name: name, RecordType: recordType, View: view})
+		if err != nil || len(viewRecords) > 0 {
+			return viewRecords, err
+		}
+	}
+
+	return s.storage.QueryRecords(ctx, storage.QueryOptions{Name: name, RecordType: recordType, View: storage.DefaultView})
+}
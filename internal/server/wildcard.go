@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// lookupWildcard returns the records of recordType of the wildcard covering
+// name (RFC 4592), owned by the wildcard name, e.g. *.example.com for
+// www.example.com. Names holding records of any type are not covered.
+//
+// Candidates replace the leftmost labels of name with "*", one more label at
+// each step. The search stops at the first wildcard found, which answers
+// even without records of recordType, and at the first ancestor holding
+// records, such as the zone apex, as wildcards above it do not cover name
+func (s *Server) lookupWildcard(ctx context.Context, name utils.DomainName, recordType types.DNSType, view string) []records.DNSRecord {
+	var matching []records.DNSRecord
+	for _, record := range s.coveringWildcard(ctx, name, view) {
+		if record.Type() == recordType {
+			matching = append(matching, record)
+		}
+	}
+	return matching
+}
+
+// coveringWildcard returns the records of all types of the wildcard covering
+// name, or nil when no wildcard covers it
+func (s *Server) coveringWildcard(ctx context.Context, name utils.DomainName, view string) []records.DNSRecord {
+	if !s.config.Responses.WildcardExpansion || len(name.Labels) == 0 {
+		return nil
+	}
+
+	if existing, err := s.lookupRecords(ctx, name.String(), 0, view); err != nil || len(existing) > 0 {
+		return nil
+	}
+
+	for ancestor := name.Parent(); len(ancestor.Labels) > 0; ancestor = ancestor.Parent() {
+		wildcardRecords, err := s.lookupRecords(ctx, "*."+ancestor.String(), 0, view)
+		if err != nil {
+			return nil
+		}
+		if len(wildcardRecords) > 0 {
+			return wildcardRecords
+		}
+
+		if existing, err := s.lookupRecords(ctx, ancestor.String(), 0, view); err != nil || len(existing) > 0 {
+			return nil
+		}
+	}
+
+	return nil
+}
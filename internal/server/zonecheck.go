@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// zoneValidationReport is the JSON body of /api/v1/zones/{zone}/validate
+type zoneValidationReport struct {
+	Zone       string                `json:"zone"`
+	Valid      bool                  `json:"valid"`
+	Violations []zoneViolationReport `json:"violations,omitempty"`
+	Error      string                `json:"error,omitempty"` // Set when the zone could not be checked
+}
+
+// zoneViolationReport describes one violation of a zone validity rule
+type zoneViolationReport struct {
+	Check string `json:"check"`
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// zoneChecks names the rules ValidateZone checks in reports
+var zoneChecks = []struct {
+	err   error
+	check string
+}{
+	{storage.ErrZoneNoSOA, "soa_missing"},
+	{storage.ErrZoneMultipleSOA, "soa_multiple"},
+	{storage.ErrZoneNoNS, "ns_missing"},
+	{storage.ErrZoneMissingGlue, "glue_missing"},
+	{storage.ErrZoneMNAMENotNS, "mname_not_ns"},
+}
+
+// handleValidateZone checks the validity of the zone named in the path and
+// reports its violations. The report is sent with 200 whether the zone is
+// valid or not; 503 means its records could not be looked up
+func (s *Server) handleValidateZone(w http.ResponseWriter, r *http.Request) {
+	zone, err := utils.ParseDomainName(r.PathValue("zone"))
+	if err != nil {
+		http.Error(w, "invalid zone name: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	s.componentsMu.RLock()
+	store := s.storage
+	s.componentsMu.RUnlock()
+
+	report := zoneValidationReport{Zone: zone.CanonicalString(), Valid: true}
+	status := http.StatusOK
+	for _, err := range storage.ValidateZone(ctx, store, report.Zone) {
+		report.Valid = false
+
+		var violation *storage.ZoneError
+		if !errors.As(err, &violation) {
+			report.Error = err.Error()
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		report.Violations = append(report.Violations, zoneViolationReport{
+			Check: zoneCheckName(violation.Err),
+			Name:  violation.Name,
+			Error: violation.Err.Error(),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// zoneCheckName returns the name of the rule err violates
+func zoneCheckName(err error) string {
+	for _, zoneCheck := range zoneChecks {
+		if errors.Is(err, zoneCheck.err) {
+			return zoneCheck.check
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vadim-su/dnska/pkg/dns/records"
+)
+
+func TestServer_HandleValidateZone(t *testing.T) {
+	tests := []struct {
+		name       string
+		zone       string
+		records    []records.DNSRecord
+		status     int
+		valid      bool
+		violations []zoneViolationReport
+	}{
+		{
+			name:    "valid",
+			zone:    "example.org",
+			records: append(updateZone(), records.NewARecord("ns1.example.org.", net.ParseIP("192.0.2.53"), 3600)),
+			status:  http.StatusOK,
+			valid:   true,
+		},
+		{
+			name:    "missing glue",
+			zone:    "example.org.",
+			records: updateZone(),
+			status:  http.StatusOK,
+			violations: []zoneViolationReport{
+				{Check: "glue_missing", Name: "ns1.example.org.", Error: "in-zone name server has no A or AAAA records"},
+			},
+		},
+		{
+			name:   "unknown zone",
+			zone:   "example.net.",
+			status: http.StatusOK,
+			violations: []zoneViolationReport{
+				{Check: "soa_missing", Name: "example.net.", Error: "zone has no SOA record"},
+				{Check: "ns_missing", Name: "example.net.", Error: "zone has no NS records at its apex"},
+			},
+		},
+		{name: "invalid name", zone: "a..b", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newUDPTestServer(t, tt.records)
+			mux := http.NewServeMux()
+			mux.HandleFunc("POST /api/v1/zones/{zone}/validate", s.handleValidateZone)
+
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/zones/"+tt.zone+"/validate", nil))
+			if recorder.Code != tt.status {
+				t.Fatalf("status = %d, expected %d: %s", recorder.Code, tt.status, recorder.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var report zoneValidationReport
+			if err := json.NewDecoder(recorder.Body).Decode(&report); err != nil {
+				t.Fatalf("failed to decode report: %v", err)
+			}
+			if report.Valid != tt.valid {
+				t.Errorf("valid = %v, expected %v", report.Valid, tt.valid)
+			}
+			if len(report.Violations) != len(tt.violations) {
+				t.Fatalf("violations = %+v, expected %+v", report.Violations, tt.violations)
+			}
+			for i, violation := range report.Violations {
+				if violation != tt.violations[i] {
+					t.Errorf("violation %d = %+v, expected %+v", i, violation, tt.violations[i])
+				}
+			}
+		})
+	}
+}
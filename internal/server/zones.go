@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/internal/zoneimport"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// ttlPolicyReport is the JSON body of /config/ttl, TTLs in seconds
+type ttlPolicyReport struct {
+	DefaultTTL uint32            `json:"default_ttl"`
+	TypeTTLs   map[string]uint32 `json:"type_ttls"`
+}
+
+// newTTLPolicy maps the configured TTLs onto the policy zone files are
+// read with
+func newTTLPolicy(cfg config.ZonesConfig) zoneimport.TTLPolicy {
+	policy := zoneimport.TTLPolicy{
+		Default: uint32(cfg.DefaultTTL.Seconds()),
+		Types:   make(map[types.DNSType]uint32, len(cfg.TypeTTLs)),
+	}
+	for name, ttl := range cfg.TypeTTLs {
+		if recordType, ok := types.ParseDNSType(name); ok {
+			policy.Types[recordType] = uint32(ttl.Seconds())
+		}
+	}
+	return policy
+}
+
+// loadZoneFiles stores the records of the configured zone files in store.
+// Records of a zone missing from its file are removed, except those below
+// another configured zone and those of other views. Zones that load are
+// checked and their flaws logged, as for secondary zones
+func loadZoneFiles(ctx context.Context, store storage.Storage, cfg config.ZonesConfig) error {
+	zones := make(map[string]bool, len(cfg.Files))
+	for _, zone := range cfg.Files {
+		zones[normalizeZone(zone.Zone)] = true
+	}
+	policy := newTTLPolicy(cfg)
+
+	for _, zone := range cfg.Files {
+		name := normalizeZone(zone.Zone)
+		loaded, err := readZoneFile(zone.File, name, policy)
+		if err != nil {
+			return err
+		}
+
+		stored, err := store.ListRecordsByZone(ctx, name)
+		if err != nil && !errors.Is(err, storage.ErrRecordNotFound) {
+			return fmt.Errorf("failed to list stored records of zone %s: %w", name, err)
+		}
+
+		current := make([]records.DNSRecord, 0, len(stored))
+		for _, record := range stored {
+			closest, _ := closestZone(record.Name(), func(zone string) bool { return zones[zone] })
+			if closest == name && storage.RecordView(record) == storage.DefaultView {
+				current = append(current, record)
+			}
+		}
+
+		diff := storage.DiffRecordSets(current, loaded)
+		if err := storage.ApplyDiff(ctx, store, diff); err != nil {
+			return fmt.Errorf("failed to store zone %s from %s: %w", name, zone.File, err)
+		}
+
+		logger.Info("Loaded zone", "zone", name, "file", zone.File, "changes", diff.Summary())
+		for _, err := range storage.ValidateZone(ctx, store, name) {
+			logger.Warn("Zone is invalid", "zone", name, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// CheckConfig reports every problem of cfg found without starting the
+// server: those of config.Validator.CheckConfig, and zone files that do not
+// parse
+func CheckConfig(cfg *config.Config) []error {
+	problems := config.NewValidator().CheckConfig(cfg)
+	if cfg == nil {
+		return problems
+	}
+
+	policy := newTTLPolicy(cfg.Zones)
+	for _, zone := range cfg.Zones.Files {
+		if _, err := os.Stat(zone.File); err != nil {
+			continue // Reported as missing
+		}
+		if _, err := readZoneFile(zone.File, normalizeZone(zone.Zone), policy); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	return problems
+}
+
+// CheckConfigFile reports every problem of the configuration file at path,
+// joined: the keys LoadFromFileStrict does not know, and those CheckConfig
+// finds in the configuration decoded from the others
+func CheckConfigFile(path string) error {
+	cfg, err := config.LoadFromFileStrict(path)
+	var problems []error
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		problems = joined.Unwrap()
+	} else if err != nil {
+		problems = []error{err}
+	}
+	if cfg != nil {
+		problems = append(problems, CheckConfig(cfg)...)
+	}
+	return errors.Join(problems...)
+}
+
+// readZoneFile reads the records of zone from the master file at path
+func readZoneFile(path, zone string, policy zoneimport.TTLPolicy) ([]records.DNSRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zone file: %w", err)
+	}
+	defer file.Close()
+
+	recordList, err := zoneimport.ReadZone(file, zone, policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zone file %s: %w", path, err)
+	}
+	return recordList, nil
+}
+
+// handleTTLPolicy reports the TTLs given to zone file records without one
+func (s *Server) handleTTLPolicy(w http.ResponseWriter, r *http.Request) {
+	s.componentsMu.RLock()
+	policy := newTTLPolicy(s.config.Zones)
+	s.componentsMu.RUnlock()
+
+	report := ttlPolicyReport{
+		DefaultTTL: policy.Default,
+		TypeTTLs:   make(map[string]uint32, len(policy.Types)),
+	}
+	for recordType, ttl := range policy.Types {
+		report.TypeTTLs[recordType.Mnemonic()] = ttl
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+func TestLoadZoneFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "example.org.zone")
+	zone := strings.Join([]string{
+		"@ IN SOA ns1 hostmaster 2024010101 3600 600 604800 300",
+		"  IN NS ns1",
+		"  IN MX 10 ns1",
+		"ns1 IN A 192.0.2.53",
+	}, "\n")
+	if err := os.WriteFile(path, []byte(zone), 0o644); err != nil {
+		t.Fatalf("failed to write zone file: %v", err)
+	}
+
+	store, err := storage.NewMemoryStorage(nil)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	// A record missing from the file is removed, one below another zone kept
+	ctx := context.Background()
+	stale := records.NewARecord("old.example.org.", net.ParseIP("192.0.2.1"), 60)
+	other := records.NewARecord("www.sub.example.org.", net.ParseIP("192.0.2.2"), 60)
+	if err := store.BatchPutRecords(ctx, []records.DNSRecord{stale, other}); err != nil {
+		t.Fatalf("failed to store records: %v", err)
+	}
+
+	cfg := config.ZonesConfig{
+		Files: []config.ZoneFileConfig{
+			{Zone: "example.org", File: path},
+			{Zone: "sub.example.org", File: filepath.Join(dir, "missing.zone")},
+		},
+		DefaultTTL: 5 * time.Minute,
+		TypeTTLs:   map[string]time.Duration{"SOA": time.Hour, "mx": 2 * time.Hour},
+	}
+	if err := loadZoneFiles(ctx, store, cfg); err == nil {
+		t.Fatal("loadZoneFiles() succeeded with a missing file")
+	}
+
+	cfg.Files[1].File = filepath.Join(dir, "sub.example.org.zone")
+	if err := os.WriteFile(cfg.Files[1].File, []byte("www IN A 192.0.2.2 ; kept\n"), 0o644); err != nil {
+		t.Fatalf("failed to write zone file: %v", err)
+	}
+	if err := loadZoneFiles(ctx, store, cfg); err != nil {
+		t.Fatalf("loadZoneFiles() returned error: %v", err)
+	}
+
+	expected := []struct {
+		name       string
+		recordType types.DNSType
+		ttl        uint32
+	}{
+		{"example.org.", types.TYPE_SOA, 3600},
+		{"example.org.", types.TYPE_NS, 300},
+		{"example.org.", types.TYPE_MX, 7200},
+		{"ns1.example.org.", types.TYPE_A, 300},
+		{"www.sub.example.org.", types.TYPE_A, 300},
+	}
+	for _, want := range expected {
+		record, err := store.GetRecord(ctx, want.name, want.recordType)
+		if err != nil {
+			t.Errorf("GetRecord(%s, %s) returned error: %v", want.name, want.recordType, err)
+			continue
+		}
+		if record.TTL() != want.ttl {
+			t.Errorf("%s %s TTL = %d, expected %d", want.name, want.recordType, record.TTL(), want.ttl)
+		}
+	}
+
+	if _, err := store.GetRecord(ctx, "old.example.org.", types.TYPE_A); err == nil {
+		t.Error("record missing from the zone file was kept")
+	}
+}
+
+func TestServer_HandleTTLPolicy(t *testing.T) {
+	s := newUDPTestServer(t, nil)
+	s.config.Zones = config.ZonesConfig{
+		DefaultTTL: 10 * time.Minute,
+		TypeTTLs:   map[string]time.Duration{"soa": time.Hour, "A": 5 * time.Minute},
+	}
+
+	recorder := httptest.NewRecorder()
+	s.handleTTLPolicy(recorder, httptest.NewRequest(http.MethodGet, "/config/ttl", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, expected %d", recorder.Code, http.StatusOK)
+	}
+
+	var report ttlPolicyReport
+	if err := json.NewDecoder(recorder.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	if report.DefaultTTL != 600 || len(report.TypeTTLs) != 2 || report.TypeTTLs["SOA"] != 3600 || report.TypeTTLs["A"] != 300 {
+		t.Errorf("report = %+v, expected default 600, SOA 3600 and A 300", report)
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "example.org.zone")
+	if err := os.WriteFile(good, []byte("@ IN SOA ns1 hostmaster 1 3600 600 604800 300\n  IN NS ns1\nns1 IN A 192.0.2.53\n"), 0o644); err != nil {
+		t.Fatalf("failed to write zone file: %v", err)
+	}
+	bad := filepath.Join(dir, "example.net.zone")
+	if err := os.WriteFile(bad, []byte("www IN A not-an-address\n"), 0o644); err != nil {
+		t.Fatalf("failed to write zone file: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Zones.Files = []config.ZoneFileConfig{{Zone: "example.org", File: good}}
+	if problems := CheckConfig(cfg); len(problems) != 0 {
+		t.Fatalf("CheckConfig() = %v, expected no problems", problems)
+	}
+
+	// A zone file that does not parse and one that is missing are both reported
+	cfg.Zones.Files = append(cfg.Zones.Files,
+		config.ZoneFileConfig{Zone: "example.net", File: bad},
+		config.ZoneFileConfig{Zone: "example.com", File: filepath.Join(dir, "missing.zone")},
+	)
+	problems := CheckConfig(cfg)
+	if len(problems) != 2 {
+		t.Fatalf("CheckConfig() = %v, expected 2 problems", problems)
+	}
+	if !strings.Contains(problems[0].Error(), "missing.zone") || !strings.Contains(problems[1].Error(), bad) {
+		t.Errorf("CheckConfig() = %v, expected the missing and the invalid zone file", problems)
+	}
+}
+
+func TestCheckConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dnska.yaml")
+	content := "server:\n  adress: \"127.0.0.1:5353\"\n  allow_query: [\"10.0.0.0/33\"]\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	// The unknown key does not keep the other keys from being checked
+	err := CheckConfigFile(path)
+	if err == nil {
+		t.Fatal("CheckConfigFile() = nil, expected the unknown key and the invalid network")
+	}
+	problems := err.(interface{ Unwrap() []error }).Unwrap()
+	if len(problems) != 2 {
+		t.Fatalf("CheckConfigFile() = %v, expected 2 problems", problems)
+	}
+	if !strings.Contains(problems[0].Error(), "server.adress") || !strings.Contains(problems[1].Error(), "10.0.0.0/33") {
+		t.Errorf("CheckConfigFile() = %v, expected the unknown key and the invalid network", problems)
+	}
+}
@@ -0,0 +1,315 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// AutoPTRStorage wraps a Storage backend and keeps reverse PTR records in sync
+// with the A and AAAA records written through it. Inserting an address record
+// creates or refreshes the PTR for its IP when the storage holds the reverse
+// zone (in-addr.arpa or ip6.arpa) of the IP, removing it deletes only the PTR
+// that points back at the removed owner, so several names sharing one IP each
+// keep their own PTR record.
+//
+// The forward write and the PTR update are separate operations; if the PTR
+// update fails the forward change is kept and the error is returned.
+type AutoPTRStorage struct {
+	Storage
+}
+
+// NewAutoPTRStorage wraps the given storage with automatic PTR maintenance
+func NewAutoPTRStorage(inner Storage) *AutoPTRStorage {
+	return &AutoPTRStorage{Storage: inner}
+}
+
+// PutRecord stores a record and creates the matching PTR for address records
+func (s *AutoPTRStorage) PutRecord(ctx context.Context, record records.DNSRecord) error {
+	if err := s.Storage.PutRecord(ctx, record); err != nil {
+		return err
+	}
+	return s.AutoPTR(ctx, record)
+}
+
+// BatchPutRecords stores records and creates the matching PTRs for address records
+func (s *AutoPTRStorage) BatchPutRecords(ctx context.Context, recordList []records.DNSRecord) error {
+	if err := s.Storage.BatchPutRecords(ctx, recordList); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, record := range recordList {
+		if err := s.AutoPTR(ctx, record); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DeleteRecord removes records and the PTRs that pointed back at them
+func (s *AutoPTRStorage) DeleteRecord(ctx context.Context, name string, recordType types.DNSType) error {
+	removed, err := s.addressRecords(ctx, name, recordType)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Storage.DeleteRecord(ctx, name, recordType); err != nil {
+		return err
+	}
+
+	return s.removePTRs(ctx, removed, nil)
+}
+
+// BatchDeleteRecords removes records for several names and their PTRs
+func (s *AutoPTRStorage) BatchDeleteRecords(ctx context.Context, names []string, recordType types.DNSType) error {
+	var removed []records.DNSRecord
+	for _, name := range names {
+		nameRecords, err := s.addressRecords(ctx, name, recordType)
+		if err != nil {
+			return err
+		}
+		removed = append(removed, nameRecords...)
+	}
+
+	if err := s.Storage.BatchDeleteRecords(ctx, names, recordType); err != nil {
+		return err
+	}
+
+	return s.removePTRs(ctx, removed, nil)
+}
+
+// ReplaceRRSet replaces an RRset and reconciles the PTRs of the old and new sets
+func (s *AutoPTRStorage) ReplaceRRSet(ctx context.Context, name string, recordType types.DNSType, recordList []records.DNSRecord) error {
+	previous, err := s.addressRecords(ctx, name, recordType)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Storage.ReplaceRRSet(ctx, name, recordType, recordList); err != nil {
+		return err
+	}
+
+	errs := []error{s.removePTRs(ctx, previous, recordList)}
+	for _, record := range recordList {
+		errs = append(errs, s.AutoPTR(ctx, record))
+	}
+	return errors.Join(errs...)
+}
+
+// Begin starts a transaction on the wrapped storage whose commit also
+// reconciles the PTRs of the address records it changed
+func (s *AutoPTRStorage) Begin(ctx context.Context) (Transaction, error) {
+	tx, err := s.Storage.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &autoPTRTransaction{Transaction: tx, storage: s}, nil
+}
+
+// GetStats returns statistics of the wrapped storage when it supports them
+func (s *AutoPTRStorage) GetStats(ctx context.Context) (*StorageStats, error) {
+	statsStorage, ok := s.Storage.(StorageWithStats)
+	if !ok {
+		return nil, fmt.Errorf("storage does not provide statistics")
+	}
+	return statsStorage.GetStats(ctx)
+}
+
+// addressRecords returns the A and AAAA records affected by an operation on
+// name and recordType
+func (s *AutoPTRStorage) addressRecords(ctx context.Context, name string, recordType types.DNSType) ([]records.DNSRecord, error) {
+	if recordType != 0 && recordType != types.TYPE_A && recordType != types.TYPE_AAAA {
+		return nil, nil
+	}
+
+	existing, err := s.Storage.GetRecords(ctx, name, recordType)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]records.DNSRecord, 0, len(existing))
+	for _, record := range existing {
+		if recordIP(record) != nil {
+			result = append(result, record)
+		}
+	}
+	return result, nil
+}
+
+// AutoPTR creates or refreshes the PTR record for an A or AAAA record, such
+// as 1.0.0.10.in-addr.arpa. for 10.0.0.1, when the storage holds the reverse
+// zone of its address. Other records and addresses outside the stored
+// reverse zones are left alone
+func (s *AutoPTRStorage) AutoPTR(ctx context.Context, record records.DNSRecord) error {
+	ip := recordIP(record)
+	if ip == nil {
+		return nil
+	}
+
+	ptr, err := records.NewPTRRecordForIP(ip, record.Name(), record.TTL())
+	if err != nil {
+		return err
+	}
+
+	hasZone, err := s.hasReverseZone(ctx, ptr.Name())
+	if err != nil {
+		return fmt.Errorf("failed to look up the reverse zone of %s: %w", ptr.Name(), err)
+	}
+	if !hasZone {
+		return nil
+	}
+
+	// The PTR is served in the same view as the address record
+	if err := s.Storage.PutRecord(ctx, NewViewRecord(ptr, RecordView(record))); err != nil {
+		return fmt.Errorf("failed to store PTR record %s: %w", ptr.Name(), err)
+	}
+	return nil
+}
+
+// hasReverseZone reports whether the storage holds a zone enclosing the
+// reverse name, that is an SOA record at the name or one of its ancestors
+func (s *AutoPTRStorage) hasReverseZone(ctx context.Context, reverseName string) (bool, error) {
+	labels := strings.Split(strings.TrimSuffix(reverseName, "."), ".")
+	for i := range labels {
+		soa, err := s.Storage.GetRecords(ctx, strings.Join(labels[i:], ".")+".", types.TYPE_SOA)
+		if err != nil && !errors.Is(err, ErrRecordNotFound) {
+			return false, err
+		}
+		if len(soa) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// removePTRs deletes the PTR records pointing back at the removed address
+// records, except for addresses that are still present in kept
+func (s *AutoPTRStorage) removePTRs(ctx context.Context, removed, kept []records.DNSRecord) error {
+	var errs []error
+
+	for _, record := range removed {
+		ip := recordIP(record)
+		if ip == nil || containsAddress(kept, record.Name(), ip) {
+			continue
+		}
+
+		reverseName := records.ReverseName(ip)
+		existing, err := s.Storage.GetRecords(ctx, reverseName, types.TYPE_PTR)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		remaining := make([]records.DNSRecord, 0, len(existing))
+		for _, ptr := range existing {
+			ptrRecord, ok := unwrapRecord(ptr).(*records.PTRRecord)
+			if ok && RecordView(ptr) == RecordView(record) && strings.EqualFold(normalizeDomainName(ptrRecord.Target()), normalizeDomainName(record.Name())) {
+				continue
+			}
+			remaining = append(remaining, ptr)
+		}
+
+		if len(remaining) == len(existing) {
+			continue
+		}
+
+		if err := s.Storage.ReplaceRRSet(ctx, reverseName, types.TYPE_PTR, remaining); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove PTR record %s: %w", reverseName, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// recordIP returns the address carried by an A or AAAA record
+func recordIP(record records.DNSRecord) net.IP {
+	switch r := unwrapRecord(record).(type) {
+	case *records.ARecord:
+		return r.IP()
+	case *records.AAAARecord:
+		return r.IP()
+	default:
+		return nil
+	}
+}
+
+// containsAddress reports whether recordList has an address record for name and ip
+func containsAddress(recordList []records.DNSRecord, name string, ip net.IP) bool {
+	for _, record := range recordList {
+		if strings.EqualFold(normalizeDomainName(record.Name()), normalizeDomainName(name)) && ip.Equal(recordIP(record)) {
+			return true
+		}
+	}
+	return false
+}
+
+// autoPTRTransaction tracks the address records staged in a transaction so
+// their PTRs can be updated once it commits. Like the non-transactional
+// operations, the PTR updates happen after the forward change
+type autoPTRTransaction struct {
+	Transaction
+	storage *AutoPTRStorage
+	put     []records.DNSRecord // Address records stored by the transaction
+	removed []records.DNSRecord // Address records present before their deletion was staged
+}
+
+// PutRecord stages a record and remembers it for PTR creation
+func (tx *autoPTRTransaction) PutRecord(ctx context.Context, record records.DNSRecord) error {
+	if err := tx.Transaction.PutRecord(ctx, record); err != nil {
+		return err
+	}
+
+	if recordIP(record) != nil {
+		tx.put = append(tx.put, record)
+	}
+	return nil
+}
+
+// DeleteRecord stages a deletion and remembers the address records it removes
+func (tx *autoPTRTransaction) DeleteRecord(ctx context.Context, name string, recordType types.DNSType) error {
+	removed, err := tx.storage.addressRecords(ctx, name, recordType)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Transaction.DeleteRecord(ctx, name, recordType); err != nil {
+		return err
+	}
+
+	tx.removed = append(tx.removed, removed...)
+
+	// Records stored earlier in this transaction are deleted again
+	kept := tx.put[:0]
+	for _, record := range tx.put {
+		sameName := strings.EqualFold(normalizeDomainName(record.Name()), normalizeDomainName(name))
+		if !sameName || recordType != 0 && record.Type() != recordType {
+			kept = append(kept, record)
+		}
+	}
+	tx.put = kept
+
+	return nil
+}
+
+// Commit applies the transaction and then reconciles the affected PTRs
+func (tx *autoPTRTransaction) Commit(ctx context.Context) error {
+	if err := tx.Transaction.Commit(ctx); err != nil {
+		return err
+	}
+
+	errs := []error{tx.storage.removePTRs(ctx, tx.removed, tx.put)}
+	for _, record := range tx.put {
+		errs = append(errs, tx.storage.AutoPTR(ctx, record))
+	}
+	return errors.Join(errs...)
+}
+
+// Ensure AutoPTRStorage implements Storage interface
+var _ Storage = (*AutoPTRStorage)(nil)
+var _ StorageWithStats = (*AutoPTRStorage)(nil)
@@ -0,0 +1,175 @@
+package storage_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// newAutoPTRStorage returns a storage maintaining PTR records, holding the
+// reverse zones of 192.0.2.0/24 and 2001:db8::/32
+func newAutoPTRStorage(t *testing.T) storage.Storage {
+	t.Helper()
+
+	s, err := storage.NewStorage(context.Background(), &storage.StorageConfig{
+		Type:             storage.StorageTypeMemory,
+		AutoPTR:          true,
+		ValidationConfig: &storage.ValidationConfig{Enabled: true},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	for _, zone := range []string{"2.0.192.in-addr.arpa.", "8.b.d.0.1.0.0.2.ip6.arpa."} {
+		soa := records.NewSOARecord(zone, "ns1.example.com.", "hostmaster.example.com.",
+			1, time.Hour, 10*time.Minute, 7*24*time.Hour, 5*time.Minute, 3600)
+		require.NoError(t, s.PutRecord(context.Background(), soa))
+	}
+	return s
+}
+
+func ptrTargets(t *testing.T, s storage.Storage, ip string) []string {
+	t.Helper()
+
+	recs, err := s.GetRecords(context.Background(), records.ReverseName(net.ParseIP(ip)), types.TYPE_PTR)
+	require.NoError(t, err)
+
+	targets := make([]string, 0, len(recs))
+	for _, rec := range recs {
+		targets = append(targets, rec.(*records.PTRRecord).Target())
+	}
+	return targets
+}
+
+func TestAutoPTRStorage_Suite(t *testing.T) {
+	suite := NewStorageTestSuite(t, newAutoPTRStorage(t))
+	suite.RunAll()
+}
+
+func TestAutoPTRStorage_PutAndDelete(t *testing.T) {
+	ctx := context.Background()
+	s := newAutoPTRStorage(t)
+
+	a, err := records.NewARecordFromString("host.example.com.", "192.0.2.10", 300)
+	require.NoError(t, err)
+	require.NoError(t, s.PutRecord(ctx, a))
+
+	assert.Equal(t, []string{"host.example.com."}, ptrTargets(t, s, "192.0.2.10"))
+
+	require.NoError(t, s.DeleteRecord(ctx, "host.example.com.", types.TYPE_A))
+	assert.Empty(t, ptrTargets(t, s, "192.0.2.10"))
+}
+
+func TestAutoPTRStorage_ReverseZone(t *testing.T) {
+	ctx := context.Background()
+	s := newAutoPTRStorage(t)
+
+	// 198.51.100.0/24 has no reverse zone in storage
+	outside, err := records.NewARecordFromString("outside.example.com.", "198.51.100.10", 300)
+	require.NoError(t, err)
+	require.NoError(t, s.PutRecord(ctx, outside))
+	assert.Empty(t, ptrTargets(t, s, "198.51.100.10"))
+
+	// Records stored after the reverse zone get their PTR
+	soa := records.NewSOARecord("100.51.198.in-addr.arpa.", "ns1.example.com.", "hostmaster.example.com.",
+		1, time.Hour, 10*time.Minute, 7*24*time.Hour, 5*time.Minute, 3600)
+	require.NoError(t, s.PutRecord(ctx, soa))
+	inside, err := records.NewARecordFromString("inside.example.com.", "198.51.100.11", 300)
+	require.NoError(t, err)
+	require.NoError(t, s.PutRecord(ctx, inside))
+	assert.Equal(t, []string{"inside.example.com."}, ptrTargets(t, s, "198.51.100.11"))
+
+	// AAAA records outside 2001:db8::/32 get none either
+	aaaa, err := records.NewAAAARecordFromString("v6.example.com.", "2001:db9::1", 300)
+	require.NoError(t, err)
+	require.NoError(t, s.PutRecord(ctx, aaaa))
+	assert.Empty(t, ptrTargets(t, s, "2001:db9::1"))
+}
+
+func TestAutoPTRStorage_SharedAddress(t *testing.T) {
+	ctx := context.Background()
+	s := newAutoPTRStorage(t)
+
+	first, err := records.NewARecordFromString("www.example.com.", "192.0.2.20", 300)
+	require.NoError(t, err)
+	second, err := records.NewARecordFromString("api.example.com.", "192.0.2.20", 300)
+	require.NoError(t, err)
+	require.NoError(t, s.BatchPutRecords(ctx, []records.DNSRecord{first, second}))
+
+	assert.ElementsMatch(t, []string{"www.example.com.", "api.example.com."}, ptrTargets(t, s, "192.0.2.20"))
+
+	// Removing one owner keeps the PTR of the other
+	require.NoError(t, s.DeleteRecord(ctx, "www.example.com.", types.TYPE_A))
+	assert.Equal(t, []string{"api.example.com."}, ptrTargets(t, s, "192.0.2.20"))
+}
+
+func TestAutoPTRStorage_IPv6(t *testing.T) {
+	ctx := context.Background()
+	s := newAutoPTRStorage(t)
+
+	aaaa, err := records.NewAAAARecordFromString("v6.example.com.", "2001:db8::1", 300)
+	require.NoError(t, err)
+	require.NoError(t, s.PutRecord(ctx, aaaa))
+
+	recs, err := s.GetRecords(ctx,
+		"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.", types.TYPE_PTR)
+	require.NoError(t, err)
+	require.Len(t, recs, 1)
+	assert.Equal(t, "v6.example.com.", recs[0].(*records.PTRRecord).Target())
+}
+
+func TestAutoPTRStorage_ReplaceRRSet(t *testing.T) {
+	ctx := context.Background()
+	s := newAutoPTRStorage(t)
+
+	oldA, err := records.NewARecordFromString("host.example.com.", "192.0.2.30", 300)
+	require.NoError(t, err)
+	keptA, err := records.NewARecordFromString("host.example.com.", "192.0.2.31", 300)
+	require.NoError(t, err)
+	require.NoError(t, s.BatchPutRecords(ctx, []records.DNSRecord{oldA, keptA}))
+
+	newA, err := records.NewARecordFromString("host.example.com.", "192.0.2.32", 300)
+	require.NoError(t, err)
+	require.NoError(t, s.ReplaceRRSet(ctx, "host.example.com.", types.TYPE_A, []records.DNSRecord{keptA, newA}))
+
+	assert.Empty(t, ptrTargets(t, s, "192.0.2.30"))
+	assert.Equal(t, []string{"host.example.com."}, ptrTargets(t, s, "192.0.2.31"))
+	assert.Equal(t, []string{"host.example.com."}, ptrTargets(t, s, "192.0.2.32"))
+}
+
+func TestAutoPTRStorage_Transaction(t *testing.T) {
+	ctx := context.Background()
+	s := newAutoPTRStorage(t)
+
+	oldA, err := records.NewARecordFromString("tx.example.com.", "192.0.2.40", 300)
+	require.NoError(t, err)
+	require.NoError(t, s.PutRecord(ctx, oldA))
+
+	newA, err := records.NewARecordFromString("tx.example.com.", "192.0.2.41", 300)
+	require.NoError(t, err)
+	droppedA, err := records.NewARecordFromString("dropped.example.com.", "192.0.2.42", 300)
+	require.NoError(t, err)
+
+	tx, err := s.Begin(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.DeleteRecord(ctx, "tx.example.com.", types.TYPE_A))
+	require.NoError(t, tx.PutRecord(ctx, newA))
+	require.NoError(t, tx.PutRecord(ctx, droppedA))
+	require.NoError(t, tx.DeleteRecord(ctx, "dropped.example.com.", 0))
+
+	// Nothing changes before commit
+	assert.Equal(t, []string{"tx.example.com."}, ptrTargets(t, s, "192.0.2.40"))
+	assert.Empty(t, ptrTargets(t, s, "192.0.2.41"))
+
+	require.NoError(t, tx.Commit(ctx))
+
+	assert.Empty(t, ptrTargets(t, s, "192.0.2.40"))
+	assert.Equal(t, []string{"tx.example.com."}, ptrTargets(t, s, "192.0.2.41"))
+	assert.Empty(t, ptrTargets(t, s, "192.0.2.42"), "Record deleted in the same transaction gets no PTR")
+}
@@ -0,0 +1,982 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"go.etcd.io/bbolt"
+	bolterrors "go.etcd.io/bbolt/errors"
+)
+
+// BoltDBStorage implements the Storage interface on an embedded bbolt
+// database, so records persist in a single file without an external server.
+// bbolt locks the file while it is open: only one process can use a
+// database at a time, others fail to open it with ErrStorageLocked
+type BoltDBStorage struct {
+	db          *bbolt.DB
+	validator   *Validator
+	converter   *RecordConverter
+	notifier    *changeNotifier
+	closed      atomic.Bool
+	lastUpdated atomic.Int64 // Unix timestamp of the last change made through this storage
+}
+
+// Buckets of a bbolt database. dns_records holds an RRset per key, keyed by
+// boltKey so that the RRsets of a name are adjacent and ordered by type.
+// dns_zones holds the number of records of each zone, dns_zone_snapshots
+// the saved zone snapshots by ID
+var (
+	boltRecordsBucket   = []byte("dns_records")
+	boltZonesBucket     = []byte("dns_zones")
+	boltSnapshotsBucket = []byte("dns_zone_snapshots")
+)
+
+// boltLockTimeout is how long opening a database waits for another process
+// to release its lock
+const boltLockTimeout = time.Second
+
+// boltSnapshot is a zone snapshot as stored in the dns_zone_snapshots bucket
+type boltSnapshot struct {
+	Zone      string          `json:"zone"`
+	Records   int             `json:"records"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"` // Records encoded by encodeSnapshot
+}
+
+// NewBoltDBStorage creates a bbolt storage using the connection string as
+// the database file name, e.g. "dnska.bolt". The file and its buckets are
+// created when missing
+func NewBoltDBStorage(config *StorageConfig) (*BoltDBStorage, error) {
+	if config == nil {
+		return nil, fmt.Errorf("storage config is required")
+	}
+	if config.ConnectionString == "" {
+		return nil, fmt.Errorf("bbolt database path is required")
+	}
+
+	db, err := bbolt.Open(config.ConnectionString, 0o600, &bbolt.Options{Timeout: boltLockTimeout})
+	if errors.Is(err, bolterrors.ErrTimeout) {
+		return nil, fmt.Errorf("failed to open bbolt database %s: %w", config.ConnectionString, ErrStorageLocked)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bbolt database: %w", err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, bucket := range [][]byte{boltRecordsBucket, boltZonesBucket, boltSnapshotsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize buckets: %w", err)
+	}
+
+	return &BoltDBStorage{
+		db:        db,
+		validator: NewValidator(config.ValidationConfig),
+		converter: NewRecordConverter(),
+		notifier:  newChangeNotifier(),
+	}, nil
+}
+
+// GetRecords returns all records for a given domain name and record type
+func (s *BoltDBStorage) GetRecords(ctx context.Context, name string, recordType types.DNSType) ([]records.DNSRecord, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	if err := s.validator.ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	name = normalizeDomainName(name)
+
+	result := []records.DNSRecord{}
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(boltRecordsBucket)
+		if recordType != 0 {
+			result = s.decodeRRSet(name, bucket.Get(boltKey(name, recordType)))
+			return nil
+		}
+
+		return boltScan(ctx, bucket, boltNamePrefix(name), func(_ string, _ types.DNSType, value []byte) error {
+			result = append(result, s.decodeRRSet(name, value)...)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	return result, nil
+}
+
+// GetRecord returns a single record for a given domain name and record type
+func (s *BoltDBStorage) GetRecord(ctx context.Context, name string, recordType types.DNSType) (records.DNSRecord, error) {
+	recordList, err := s.GetRecords(ctx, name, recordType)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(recordList) == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	return recordList[0], nil
+}
+
+// PutRecord stores or updates a DNS record with validation
+func (s *BoltDBStorage) PutRecord(ctx context.Context, record records.DNSRecord) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+
+	// Validate record
+	if err := s.validator.ValidateRecord(record); err != nil {
+		return err
+	}
+
+	if err := s.db.Update(func(tx *bbolt.Tx) error { return s.upsert(tx, record) }); err != nil {
+		return err
+	}
+
+	s.changed(s.changeEvent(ChangeOperationPut, record.Name(), record.Type()))
+	return nil
+}
+
+// DeleteRecord removes a DNS record
+// If recordType is 0, deletes all records for the name
+func (s *BoltDBStorage) DeleteRecord(ctx context.Context, name string, recordType types.DNSType) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+
+	// Validate input
+	if err := s.validator.ValidateName(name); err != nil {
+		return err
+	}
+
+	var deleted bool
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		deleted, err = s.delete(tx, normalizeDomainName(name), recordType)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrRecordNotFound
+	}
+
+	s.changed(s.changeEvent(ChangeOperationDelete, name, recordType))
+	return nil
+}
+
+// ListRecords returns all records in the storage, ordered by name and type
+func (s *BoltDBStorage) ListRecords(ctx context.Context) ([]records.DNSRecord, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.scanRecords(ctx, nil, func(string) bool { return true })
+}
+
+// ListRecordsByZone returns all records for a specific zone. Keys are
+// ordered from the first label of a name, so the names of a zone are not
+// adjacent and every key is visited
+func (s *BoltDBStorage) ListRecordsByZone(ctx context.Context, zone string) ([]records.DNSRecord, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Validate zone
+	if err := s.validator.ValidateZone(zone); err != nil {
+		return nil, err
+	}
+
+	zone = normalizeDomainName(zone)
+	return s.scanRecords(ctx, nil, func(name string) bool { return boltInZone(name, zone) })
+}
+
+// GetZones returns all available zones from the dns_zones bucket
+func (s *BoltDBStorage) GetZones(ctx context.Context) ([]string, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+
+	zones := []string{}
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltZonesBucket).ForEach(func(zone, _ []byte) error {
+			zones = append(zones, string(zone))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	return zones, nil
+}
+
+// QueryRecords performs a filtered query with optional pagination. A name or
+// name prefix bounds the scan to the keys starting with it
+func (s *BoltDBStorage) QueryRecords(ctx context.Context, options QueryOptions) ([]records.DNSRecord, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Normalize query options
+	var prefix []byte
+	queryPrefix := options.NamePrefix
+	if queryPrefix != "" {
+		queryPrefix = normalizeDomainName(queryPrefix)
+		prefix = []byte(queryPrefix)
+	}
+	queryName := options.Name
+	if queryName != "" {
+		queryName = normalizeDomainName(queryName)
+		prefix = boltNamePrefix(queryName)
+	}
+	queryZone := options.Zone
+	if queryZone != "" {
+		queryZone = normalizeDomainName(queryZone)
+	}
+
+	var results []records.DNSRecord
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return boltScan(ctx, tx.Bucket(boltRecordsBucket), prefix, func(name string, recordType types.DNSType, value []byte) error {
+			// Apply name and type filters
+			if queryName != "" && !strings.HasPrefix(name, queryPrefix) {
+				return nil
+			}
+			if queryZone != "" && !boltInZone(name, queryZone) {
+				return nil
+			}
+			if options.RecordType != 0 && recordType != options.RecordType {
+				return nil
+			}
+
+			for _, record := range s.decodeRRSet(name, value) {
+				if inView(record, options.View) {
+					results = append(results, record)
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	// Sort results; the scan already ordered them by name
+	sortRecords(results, options.SortBy, options.SortOrder)
+
+	// Apply pagination
+	if options.Offset > 0 {
+		if options.Offset >= len(results) {
+			return []records.DNSRecord{}, nil
+		}
+		results = results[options.Offset:]
+	}
+
+	if options.Limit > 0 && options.Limit < len(results) {
+		results = results[:options.Limit]
+	}
+
+	if results == nil {
+		return []records.DNSRecord{}, nil
+	}
+	return results, nil
+}
+
+// BatchPutRecords stores multiple records in a single read-write
+// transaction. A record failing validation rejects the whole batch
+func (s *BoltDBStorage) BatchPutRecords(ctx context.Context, recordList []records.DNSRecord) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+
+	if len(recordList) == 0 {
+		return nil
+	}
+
+	for _, record := range recordList {
+		if err := s.validator.ValidateRecord(record); err != nil {
+			return fmt.Errorf("validation failed: %w", err)
+		}
+	}
+
+	events := make([]ChangeEvent, 0, len(recordList))
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		for _, record := range recordList {
+			if err := s.upsert(tx, record); err != nil {
+				return err
+			}
+			events = append(events, s.changeEvent(ChangeOperationPut, record.Name(), record.Type()))
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("batch insert failed: %w", err)
+	}
+
+	s.changed(events...)
+	return nil
+}
+
+// BatchDeleteRecords deletes multiple records in a single transaction
+func (s *BoltDBStorage) BatchDeleteRecords(ctx context.Context, names []string, recordType types.DNSType) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	var events []ChangeEvent
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range names {
+			deleted, err := s.delete(tx, normalizeDomainName(name), recordType)
+			if err != nil {
+				return err
+			}
+			if deleted {
+				events = append(events, s.changeEvent(ChangeOperationDelete, name, recordType))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("batch delete failed: %w", err)
+	}
+
+	s.changed(events...)
+	return nil
+}
+
+// ReplaceRRSet atomically replaces all records of the given name and type
+// inside a single bbolt transaction
+func (s *BoltDBStorage) ReplaceRRSet(ctx context.Context, name string, recordType types.DNSType, recordList []records.DNSRecord) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+
+	// Validate the whole set before touching any state
+	if err := s.validator.ValidateRRSet(name, recordType, recordList); err != nil {
+		return err
+	}
+
+	name = normalizeDomainName(name)
+
+	var existed bool
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		if existed, err = s.delete(tx, name, recordType); err != nil {
+			return err
+		}
+		for _, record := range recordList {
+			if err := s.upsert(tx, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to replace RRset: %w", err)
+	}
+
+	if len(recordList) > 0 {
+		s.changed(s.changeEvent(ChangeOperationPut, name, recordType))
+	} else if existed {
+		s.changed(s.changeEvent(ChangeOperationDelete, name, recordType))
+	}
+
+	return nil
+}
+
+// SnapshotZone saves the records of zone in the dns_zone_snapshots bucket
+func (s *BoltDBStorage) SnapshotZone(ctx context.Context, zone string) (string, error) {
+	recordList, err := s.ListRecordsByZone(ctx, zone)
+	if err != nil {
+		return "", err
+	}
+	if len(recordList) == 0 {
+		return "", ErrRecordNotFound
+	}
+
+	blob, err := encodeSnapshot(s.converter, recordList)
+	if err != nil {
+		return "", err
+	}
+	id, err := newSnapshotID()
+	if err != nil {
+		return "", err
+	}
+
+	value, err := json.Marshal(boltSnapshot{
+		Zone:      normalizeDomainName(zone),
+		Records:   len(recordList),
+		CreatedAt: time.Now().UTC(),
+		Data:      blob,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltSnapshotsBucket).Put([]byte(id), value)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store snapshot: %w", err)
+	}
+	return id, nil
+}
+
+// RestoreSnapshot replaces the records of the zone of a snapshot with those
+// saved in it, in one database transaction
+func (s *BoltDBStorage) RestoreSnapshot(ctx context.Context, snapshotID string) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+
+	var snapshot boltSnapshot
+	var found bool
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		value := tx.Bucket(boltSnapshotsBucket).Get([]byte(snapshotID))
+		if value == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(value, &snapshot)
+	})
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+	if !found {
+		return ErrSnapshotNotFound
+	}
+
+	recordList, err := decodeSnapshot(s.converter, snapshot.Data)
+	if err != nil {
+		return err
+	}
+	return restoreZone(ctx, s, snapshot.Zone, recordList)
+}
+
+// ListSnapshots returns the snapshots of zone, oldest first
+func (s *BoltDBStorage) ListSnapshots(ctx context.Context, zone string) ([]SnapshotInfo, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+	if err := s.validator.ValidateZone(zone); err != nil {
+		return nil, err
+	}
+
+	zone = normalizeDomainName(zone)
+
+	snapshots := []SnapshotInfo{}
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltSnapshotsBucket).ForEach(func(id, value []byte) error {
+			var snapshot boltSnapshot
+			if err := json.Unmarshal(value, &snapshot); err != nil {
+				return fmt.Errorf("failed to read snapshot: %w", err)
+			}
+			if snapshot.Zone == zone {
+				snapshots = append(snapshots, SnapshotInfo{
+					ID:        string(id),
+					Zone:      snapshot.Zone,
+					Records:   snapshot.Records,
+					CreatedAt: snapshot.CreatedAt,
+				})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// DeleteSnapshot removes a snapshot from the dns_zone_snapshots bucket
+func (s *BoltDBStorage) DeleteSnapshot(ctx context.Context, snapshotID string) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+
+	var found bool
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(boltSnapshotsBucket)
+		if bucket.Get([]byte(snapshotID)) == nil {
+			return nil
+		}
+		found = true
+		return bucket.Delete([]byte(snapshotID))
+	})
+	if err != nil {
+		return fmt.Errorf("delete failed: %w", err)
+	}
+	if !found {
+		return ErrSnapshotNotFound
+	}
+	return nil
+}
+
+// Begin starts a transaction that stages changes until Commit applies them
+// in a single bbolt transaction
+func (s *BoltDBStorage) Begin(ctx context.Context) (Transaction, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+
+	return &boltTransaction{storage: s}, nil
+}
+
+// Subscribe returns a channel receiving an event after every change made
+// through this storage
+func (s *BoltDBStorage) Subscribe(ctx context.Context) (<-chan ChangeEvent, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+
+	return s.notifier.subscribe(ctx)
+}
+
+// Ping checks that the database can be read
+func (s *BoltDBStorage) Ping(ctx context.Context) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+
+	if err := s.db.View(func(*bbolt.Tx) error { return nil }); err != nil {
+		return fmt.Errorf("ping failed: %w", err)
+	}
+	return nil
+}
+
+// Close closes the database, releasing its file lock, and cleans up resources
+func (s *BoltDBStorage) Close() error {
+	if !s.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	s.notifier.close()
+	return s.db.Close()
+}
+
+// GetStats returns storage statistics
+func (s *BoltDBStorage) GetStats(ctx context.Context) (*StorageStats, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+
+	stats := &StorageStats{
+		RecordTypes:   make(map[string]int),
+		LastUpdated:   s.lastUpdated.Load(),
+		DroppedEvents: s.notifier.droppedEvents(),
+	}
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		stats.TotalZones = tx.Bucket(boltZonesBucket).Stats().KeyN
+
+		return boltScan(ctx, tx.Bucket(boltRecordsBucket), nil, func(name string, _ types.DNSType, value []byte) error {
+			var entries []fileRecord
+			if err := json.Unmarshal(value, &entries); err != nil {
+				return fmt.Errorf("failed to read RRset of %s: %w", name, err)
+			}
+			for _, entry := range entries {
+				stats.RecordTypes[types.DNSType(entry.RecordType).String()]++
+			}
+			stats.TotalRecords += len(entries)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// Helper methods
+
+// upsert adds a validated record to its RRset, replacing a record with the
+// same data in the same view
+func (s *BoltDBStorage) upsert(tx *bbolt.Tx, record records.DNSRecord) error {
+	recordData, err := s.converter.ToStorageFormat(record)
+	if err != nil {
+		return err
+	}
+
+	name := normalizeDomainName(recordData.Name)
+	recordType := types.DNSType(recordData.RecordType)
+	entry := fileRecord{
+		Name:       name,
+		RecordType: recordData.RecordType,
+		Class:      recordData.Class,
+		TTL:        recordData.TTL,
+		Data:       recordData.Data,
+		View:       recordData.View,
+	}
+
+	entries, err := boltRRSet(tx, name, recordType)
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i := range entries {
+		if entries[i].Data == entry.Data && entries[i].View == entry.View {
+			entries[i] = entry
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		entries = append(entries, entry)
+	}
+
+	if err := s.putRRSet(tx, name, recordType, entries); err != nil {
+		return fmt.Errorf("failed to store record: %w", err)
+	}
+	return nil
+}
+
+// delete removes the records of a normalized name with the given type, or
+// all of them when recordType is 0. It reports whether anything was removed
+func (s *BoltDBStorage) delete(tx *bbolt.Tx, name string, recordType types.DNSType) (bool, error) {
+	recordTypes := []types.DNSType{recordType}
+	if recordType == 0 {
+		// The types are collected first, as a bucket must not change while
+		// a cursor walks it
+		recordTypes = nil
+		err := boltScan(context.Background(), tx.Bucket(boltRecordsBucket), boltNamePrefix(name), func(_ string, keyType types.DNSType, _ []byte) error {
+			recordTypes = append(recordTypes, keyType)
+			return nil
+		})
+		if err != nil {
+			return false, fmt.Errorf("delete failed: %w", err)
+		}
+	}
+
+	deleted := false
+	for _, keyType := range recordTypes {
+		entries, err := boltRRSet(tx, name, keyType)
+		if err != nil {
+			return false, err
+		}
+		if len(entries) == 0 {
+			continue
+		}
+		if err := s.putRRSet(tx, name, keyType, nil); err != nil {
+			return false, fmt.Errorf("delete failed: %w", err)
+		}
+		deleted = true
+	}
+
+	return deleted, nil
+}
+
+// putRRSet stores entries as the RRset of name and type, removing the key
+// when there are none, and updates the record count of the zone of name
+func (s *BoltDBStorage) putRRSet(tx *bbolt.Tx, name string, recordType types.DNSType, entries []fileRecord) error {
+	previous, err := boltRRSet(tx, name, recordType)
+	if err != nil {
+		return err
+	}
+
+	bucket := tx.Bucket(boltRecordsBucket)
+	key := boltKey(name, recordType)
+	if len(entries) == 0 {
+		err = bucket.Delete(key)
+	} else {
+		var value []byte
+		if value, err = json.Marshal(entries); err == nil {
+			err = bucket.Put(key, value)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.countZoneRecords(tx, s.converter.extractZone(name), len(entries)-len(previous))
+}
+
+// countZoneRecords adds delta to the record count of zone in the dns_zones
+// bucket, dropping zones left without records
+func (s *BoltDBStorage) countZoneRecords(tx *bbolt.Tx, zone string, delta int) error {
+	if zone == "" || delta == 0 {
+		return nil
+	}
+
+	bucket := tx.Bucket(boltZonesBucket)
+	var count int64
+	if value := bucket.Get([]byte(zone)); len(value) == 8 {
+		count = int64(binary.BigEndian.Uint64(value))
+	}
+
+	count += int64(delta)
+	if count <= 0 {
+		return bucket.Delete([]byte(zone))
+	}
+	return bucket.Put([]byte(zone), binary.BigEndian.AppendUint64(nil, uint64(count)))
+}
+
+// scanRecords returns the records of the RRsets whose keys start with
+// prefix and whose names match
+func (s *BoltDBStorage) scanRecords(ctx context.Context, prefix []byte, match func(name string) bool) ([]records.DNSRecord, error) {
+	result := []records.DNSRecord{}
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return boltScan(ctx, tx.Bucket(boltRecordsBucket), prefix, func(name string, _ types.DNSType, value []byte) error {
+			if match(name) {
+				result = append(result, s.decodeRRSet(name, value)...)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	return result, nil
+}
+
+// decodeRRSet converts a stored RRset of name to records. Records that no
+// longer convert are skipped
+func (s *BoltDBStorage) decodeRRSet(name string, value []byte) []records.DNSRecord {
+	if value == nil {
+		return nil
+	}
+
+	var entries []fileRecord
+	if err := json.Unmarshal(value, &entries); err != nil {
+		logger.Warn("Skipping unreadable RRset", "name", name, "error", err)
+		return nil
+	}
+
+	result := make([]records.DNSRecord, 0, len(entries))
+	for _, entry := range entries {
+		record, err := s.converter.FromStorageFormat(&RecordData{
+			Name:       entry.Name,
+			RecordType: entry.RecordType,
+			Class:      entry.Class,
+			TTL:        entry.TTL,
+			Data:       entry.Data,
+			View:       entry.View,
+		})
+		if err != nil {
+			// Skip invalid records rather than failing entirely
+			continue
+		}
+		result = append(result, record)
+	}
+	return result
+}
+
+// changed records the time of a change and publishes its events
+func (s *BoltDBStorage) changed(events ...ChangeEvent) {
+	s.lastUpdated.Store(time.Now().Unix())
+	s.notifier.publish(events...)
+}
+
+// changeEvent creates an event for a change to the records of name
+func (s *BoltDBStorage) changeEvent(operation ChangeOperation, name string, recordType types.DNSType) ChangeEvent {
+	name = normalizeDomainName(name)
+	return newChangeEvent(operation, name, recordType, s.converter.extractZone(name))
+}
+
+// boltKey returns the key of the RRset of a normalized name and type: the
+// name, a zero byte and the type as a big-endian 16-bit number. The zero
+// byte sorts the RRsets of a name before those of longer names it prefixes
+func boltKey(name string, recordType types.DNSType) []byte {
+	key := make([]byte, 0, len(name)+3)
+	key = append(key, name...)
+	key = append(key, 0)
+	return binary.BigEndian.AppendUint16(key, uint16(recordType))
+}
+
+// boltNamePrefix returns the prefix shared by the keys of the RRsets of a
+// normalized name
+func boltNamePrefix(name string) []byte {
+	return append([]byte(name), 0)
+}
+
+// splitBoltKey returns the name and type a key made by boltKey holds
+func splitBoltKey(key []byte) (string, types.DNSType) {
+	if len(key) < 3 {
+		return string(key), 0
+	}
+	return string(key[:len(key)-3]), types.DNSType(binary.BigEndian.Uint16(key[len(key)-2:]))
+}
+
+// boltRRSet returns the stored RRset of a normalized name and type
+func boltRRSet(tx *bbolt.Tx, name string, recordType types.DNSType) ([]fileRecord, error) {
+	value := tx.Bucket(boltRecordsBucket).Get(boltKey(name, recordType))
+	if value == nil {
+		return nil, nil
+	}
+
+	var entries []fileRecord
+	if err := json.Unmarshal(value, &entries); err != nil {
+		return nil, fmt.Errorf("failed to read RRset of %s: %w", name, err)
+	}
+	return entries, nil
+}
+
+// boltScan calls fn with the name, type and value of every key of bucket starting
+// with prefix, in key order. It seeks to the prefix and stops at the first
+// key past it, so only the matching range is read
+func boltScan(ctx context.Context, bucket *bbolt.Bucket, prefix []byte, fn func(name string, recordType types.DNSType, value []byte) error) error {
+	cursor := bucket.Cursor()
+	scanned := 0
+	for key, value := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, value = cursor.Next() {
+		if err := scanCanceled(ctx, &scanned); err != nil {
+			return err
+		}
+
+		name, recordType := splitBoltKey(key)
+		if err := fn(name, recordType, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// boltInZone reports whether a normalized name is zone or below it
+func boltInZone(name, zone string) bool {
+	return name == zone || strings.HasSuffix(name, "."+zone)
+}
+
+// boltTransaction stages changes for a BoltDBStorage and applies them in a
+// single bbolt transaction on Commit. It is not safe for concurrent use
+type boltTransaction struct {
+	storage    *BoltDBStorage
+	operations []memoryOperation
+	done       bool
+}
+
+// PutRecord validates and stages a record
+func (tx *boltTransaction) PutRecord(ctx context.Context, record records.DNSRecord) error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+
+	if err := tx.storage.validator.ValidateRecord(record); err != nil {
+		return err
+	}
+
+	tx.operations = append(tx.operations, memoryOperation{record: record})
+	return nil
+}
+
+// DeleteRecord stages removing records. Deleting records that do not exist
+// at commit time is not an error
+func (tx *boltTransaction) DeleteRecord(ctx context.Context, name string, recordType types.DNSType) error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+
+	if err := tx.storage.validator.ValidateName(name); err != nil {
+		return err
+	}
+
+	tx.operations = append(tx.operations, memoryOperation{
+		name:       normalizeDomainName(name),
+		recordType: recordType,
+	})
+	return nil
+}
+
+// Commit applies all staged changes in one bbolt transaction
+func (tx *boltTransaction) Commit(ctx context.Context) error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+	tx.done = true
+
+	s := tx.storage
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+
+	if len(tx.operations) == 0 {
+		return nil
+	}
+
+	events := make([]ChangeEvent, 0, len(tx.operations))
+	err := s.db.Update(func(boltTx *bbolt.Tx) error {
+		for _, operation := range tx.operations {
+			if operation.record != nil {
+				if err := s.upsert(boltTx, operation.record); err != nil {
+					return err
+				}
+				events = append(events, s.changeEvent(ChangeOperationPut, operation.record.Name(), operation.record.Type()))
+				continue
+			}
+
+			deleted, err := s.delete(boltTx, operation.name, operation.recordType)
+			if err != nil {
+				return err
+			}
+			if deleted {
+				events = append(events, s.changeEvent(ChangeOperationDelete, operation.name, operation.recordType))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("transaction failed: %w", err)
+	}
+
+	tx.operations = nil
+	s.changed(events...)
+	return nil
+}
+
+// Rollback discards all staged changes; nothing has been written yet
+func (tx *boltTransaction) Rollback() error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+	tx.done = true
+	tx.operations = nil
+
+	return nil
+}
+
+// Ensure BoltDBStorage implements Storage interface
+var _ Storage = (*BoltDBStorage)(nil)
+var _ StorageWithStats = (*BoltDBStorage)(nil)
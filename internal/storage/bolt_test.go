@@ -0,0 +1,131 @@
+package storage_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+func newTestBoltDBStorage(t *testing.T, path string) *storage.BoltDBStorage {
+	t.Helper()
+
+	s, err := storage.NewBoltDBStorage(&storage.StorageConfig{
+		Type:             storage.StorageTypeBolt,
+		ConnectionString: path,
+		ValidationConfig: &storage.ValidationConfig{Enabled: true, AllowUnderscore: true},
+	})
+	require.NoError(t, err)
+	return s
+}
+
+func TestBoltDBStorage_Suite(t *testing.T) {
+	boltStorage := newTestBoltDBStorage(t, filepath.Join(t.TempDir(), "records.bolt"))
+	defer boltStorage.Close()
+
+	suite := NewStorageTestSuite(t, boltStorage)
+	suite.RunAll()
+}
+
+func TestBoltDBStorage_ReloadAfterClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.bolt")
+	ctx := context.Background()
+
+	s := newTestBoltDBStorage(t, path)
+	testRecords := storage.CreateTestRecords(t)
+	require.NoError(t, s.BatchPutRecords(ctx, testRecords))
+	require.NoError(t, s.DeleteRecord(ctx, "alias.example.com", types.TYPE_CNAME))
+	require.NoError(t, s.Close())
+
+	reloaded := newTestBoltDBStorage(t, path)
+	defer reloaded.Close()
+
+	all, err := reloaded.ListRecords(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, len(testRecords)-1)
+
+	_, err = reloaded.GetRecord(ctx, "alias.example.com", types.TYPE_CNAME)
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+}
+
+func TestBoltDBStorage_Locked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.bolt")
+
+	s := newTestBoltDBStorage(t, path)
+
+	_, err := storage.NewStorage(context.Background(), &storage.StorageConfig{
+		Type:             storage.StorageTypeBolt,
+		ConnectionString: path,
+	})
+	require.ErrorIs(t, err, storage.ErrStorageLocked)
+
+	// Closing the first storage releases the lock
+	require.NoError(t, s.Close())
+	reopened := newTestBoltDBStorage(t, path)
+	assert.NoError(t, reopened.Close())
+}
+
+func TestBoltDBStorage_NamePrefixScan(t *testing.T) {
+	s := newTestBoltDBStorage(t, filepath.Join(t.TempDir(), "records.bolt"))
+	defer s.Close()
+	ctx := context.Background()
+
+	for _, name := range []string{"www.example.com", "www.example.com.au", "a.www.example.com"} {
+		record, _ := records.NewARecordFromString(name, "192.0.2.1", 300)
+		require.NoError(t, s.PutRecord(ctx, record))
+	}
+	mx := records.NewMXRecord("www.example.com", "mail.example.com", 10, 300)
+	require.NoError(t, s.PutRecord(ctx, mx))
+
+	// The records of a name do not include those of names it prefixes
+	recs, err := s.GetRecords(ctx, "www.example.com", 0)
+	require.NoError(t, err)
+	require.Len(t, recs, 2)
+	assert.Equal(t, types.TYPE_A, recs[0].Type())
+	assert.Equal(t, types.TYPE_MX, recs[1].Type())
+
+	recs, err = s.QueryRecords(ctx, storage.QueryOptions{NamePrefix: "a.www"})
+	require.NoError(t, err)
+	require.Len(t, recs, 1)
+	assert.Equal(t, "a.www.example.com.", recs[0].Name())
+
+	recs, err = s.QueryRecords(ctx, storage.QueryOptions{Name: "www.example.com.au", RecordType: types.TYPE_A})
+	require.NoError(t, err)
+	assert.Len(t, recs, 1)
+}
+
+func TestBoltDBStorage_ZoneCounts(t *testing.T) {
+	s := newTestBoltDBStorage(t, filepath.Join(t.TempDir(), "records.bolt"))
+	defer s.Close()
+	ctx := context.Background()
+
+	first, _ := records.NewARecordFromString("www.example.com", "192.0.2.1", 300)
+	second, _ := records.NewARecordFromString("www.example.com", "192.0.2.2", 300)
+	other, _ := records.NewARecordFromString("www.example.org", "192.0.2.3", 300)
+	updated, _ := records.NewARecordFromString("WWW.example.com", "192.0.2.1", 600)
+	require.NoError(t, s.BatchPutRecords(ctx, []records.DNSRecord{first, second, other, updated}))
+
+	zones, err := s.GetZones(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"example.com", "example.org"}, zones)
+
+	stats, err := s.GetStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.TotalRecords, "records with the same data must be updated, not duplicated")
+
+	// A zone disappears with its last record
+	require.NoError(t, s.DeleteRecord(ctx, "www.example.org", types.TYPE_A))
+	zones, err = s.GetZones(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"example.com"}, zones)
+
+	require.NoError(t, s.ReplaceRRSet(ctx, "www.example.com", types.TYPE_A, nil))
+	zones, err = s.GetZones(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, zones)
+}
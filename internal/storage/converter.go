@@ -1,7 +1,10 @@
 package storage
 
 import (
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -26,6 +29,7 @@ type RecordData struct {
 	TTL        uint32    `json:"ttl"`
 	Data       string    `json:"data"`
 	Zone       string    `json:"zone"`
+	View       string    `json:"view,omitempty"` // Empty for the default view
 	CreatedAt  time.Time `json:"created_at,omitempty"`
 	UpdatedAt  time.Time `json:"updated_at,omitempty"`
 }
@@ -37,23 +41,38 @@ func (c *RecordConverter) ToStorageFormat(record records.DNSRecord) (*RecordData
 	}
 
 	data := &RecordData{
-		Name:       strings.ToLower(record.Name()),
+		Name:       normalizeDomainName(record.Name()),
 		RecordType: int(record.Type()),
 		Class:      int(record.Class()),
 		TTL:        record.TTL(),
 		Zone:       c.extractZone(record.Name()),
-		Data:       c.formatRecordData(record),
+		Data:       c.formatRecordData(unwrapRecord(record)),
+	}
+
+	if r, ok := record.(*ViewRecord); ok {
+		data.View = r.view
 	}
 
 	return data, nil
 }
 
-// FromStorageFormat converts storage format to a DNS record
+// FromStorageFormat converts storage format to a DNS record, tagged with its
+// view unless it belongs to the default view
 func (c *RecordConverter) FromStorageFormat(data *RecordData) (records.DNSRecord, error) {
 	if data == nil {
 		return nil, ErrInvalidRecord
 	}
 
+	record, err := c.parseRecord(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewViewRecord(record, data.View), nil
+}
+
+// parseRecord builds the record of the type data describes
+func (c *RecordConverter) parseRecord(data *RecordData) (records.DNSRecord, error) {
 	recordType := types.DNSType(data.RecordType)
 
 	switch recordType {
@@ -63,12 +82,21 @@ func (c *RecordConverter) FromStorageFormat(data *RecordData) (records.DNSRecord
 	case types.TYPE_AAAA:
 		return records.NewAAAARecordFromString(data.Name, data.Data, data.TTL)
 
+	case types.TYPE_CAA:
+		return c.parseCAARecord(data.Name, data.Data, data.TTL)
+
 	case types.TYPE_CNAME:
 		return records.NewCNAMERecord(data.Name, data.Data, data.TTL), nil
 
+	case types.TYPE_DNAME:
+		return records.NewDNAMERecord(data.Name, data.Data, data.TTL), nil
+
 	case types.TYPE_MX:
 		return c.parseMXRecord(data.Name, data.Data, data.TTL)
 
+	case types.TYPE_NAPTR:
+		return c.parseNAPTRRecord(data.Name, data.Data, data.TTL)
+
 	case types.TYPE_NS:
 		return records.NewNSRecord(data.Name, data.Data, data.TTL), nil
 
@@ -78,11 +106,41 @@ func (c *RecordConverter) FromStorageFormat(data *RecordData) (records.DNSRecord
 	case types.TYPE_SOA:
 		return c.parseSOARecord(data.Name, data.Data, data.TTL)
 
+	case types.TYPE_SRV:
+		return c.parseSRVRecord(data.Name, data.Data, data.TTL)
+
 	case types.TYPE_TXT:
-		return records.NewTXTRecordFromString(data.Name, data.Data, data.TTL), nil
+		return c.parseTXTRecord(data.Name, data.Data, data.TTL)
+
+	case types.TYPE_TLSA:
+		return c.parseTLSARecord(data.Name, data.Data, data.TTL)
+
+	case types.TYPE_SVCB, types.TYPE_HTTPS:
+		return c.parseSVCBRecord(recordType, data.Name, data.Data, data.TTL)
+
+	case types.TYPE_DNSKEY:
+		return c.parseDNSKEYRecord(data.Name, data.Data, data.TTL)
+
+	case types.TYPE_DS:
+		return c.parseDSRecord(data.Name, data.Data, data.TTL)
+
+	case types.TYPE_RRSIG:
+		return c.parseRRSIGRecord(data.Name, data.Data, data.TTL)
+
+	case types.TYPE_NSEC:
+		return c.parseNSECRecord(data.Name, data.Data, data.TTL)
 
 	default:
-		return nil, fmt.Errorf("%w: unsupported record type %s", ErrInvalidRecord, recordType)
+		// Types without a typed record are stored in the generic form of
+		// RFC 3597
+		if !records.IsGenericRData(data.Data) {
+			return nil, fmt.Errorf("%w: unsupported record type %s", ErrInvalidRecord, recordType.Mnemonic())
+		}
+		rdata, err := records.ParseGenericRData(data.Data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid %s data: %v", ErrInvalidRecord, recordType.Mnemonic(), err)
+		}
+		return records.NewGenericRecord(data.Name, recordType, rdata, data.TTL), nil
 	}
 }
 
@@ -121,12 +179,23 @@ func (c *RecordConverter) formatRecordData(record records.DNSRecord) string {
 	case *records.AAAARecord:
 		return r.IP().String()
 
+	case *records.CAARecord:
+		return fmt.Sprintf("%d %s %s", r.Flags(), r.Tag(), r.Value())
+
 	case *records.CNAMERecord:
 		return r.Target()
 
+	case *records.DNAMERecord:
+		return r.Target()
+
 	case *records.MXRecord:
 		return fmt.Sprintf("%d %s", r.Preference(), r.MailServer())
 
+	case *records.NAPTRRecord:
+		// Character strings are quoted as they may contain spaces and any byte
+		return fmt.Sprintf("%d %d %s %s %s %s", r.Order(), r.Preference(),
+			strconv.Quote(r.Flags()), strconv.Quote(r.Services()), strconv.Quote(r.Regexp()), r.Replacement())
+
 	case *records.NSRecord:
 		return r.NameServer()
 
@@ -139,13 +208,47 @@ func (c *RecordConverter) formatRecordData(record records.DNSRecord) string {
 			int(r.Refresh().Seconds()), int(r.Retry().Seconds()),
 			int(r.Expire().Seconds()), int(r.Minimum().Seconds()))
 
+	case *records.SRVRecord:
+		return fmt.Sprintf("%d %d %d %s", r.Priority(), r.Weight(), r.Port(), r.Target())
+
 	case *records.TXTRecord:
-		texts := r.Texts()
-		if len(texts) > 0 {
-			// Join multiple text strings with a separator
-			return strings.Join(texts, "\x00")
+		// Quoted character strings as in a zone file
+		strs := r.Strings()
+		quoted := make([]string, len(strs))
+		for i, str := range strs {
+			quoted[i] = records.QuoteCharacterString(str)
 		}
-		return ""
+		return strings.Join(quoted, " ")
+
+	case *records.TLSARecord:
+		return fmt.Sprintf("%d %d %d %X", r.CertUsage(), r.Selector(), r.MatchingType(), r.CertAssocData())
+
+	case *records.HTTPSRecord:
+		return formatSVCBData(&r.SVCBRecord)
+
+	case *records.SVCBRecord:
+		return formatSVCBData(r)
+
+	case *records.DNSKEYRecord:
+		return fmt.Sprintf("%d %d %d %s", r.Flags(), r.Protocol(), r.Algorithm(), base64.StdEncoding.EncodeToString(r.PublicKey()))
+
+	case *records.DSRecord:
+		return fmt.Sprintf("%d %d %d %X", r.KeyTag(), r.Algorithm(), r.DigestType(), r.Digest())
+
+	case *records.RRSIGRecord:
+		return fmt.Sprintf("%s %d %d %d %s %s %d %s %s", r.TypeCovered().Mnemonic(), r.Algorithm(), r.Labels(),
+			r.OriginalTTL(), r.Expiration().Format(records.RRSIGTimeLayout), r.Inception().Format(records.RRSIGTimeLayout),
+			r.KeyTag(), r.SignerName(), base64.StdEncoding.EncodeToString(r.Signature()))
+
+	case *records.NSECRecord:
+		fields := []string{r.NextName()}
+		for _, recordType := range r.Types() {
+			fields = append(fields, recordType.Mnemonic())
+		}
+		return strings.Join(fields, " ")
+
+	case *records.GenericRecord:
+		return records.FormatGenericRData(r.Data())
 
 	default:
 		// Fallback to raw data conversion
@@ -153,6 +256,38 @@ func (c *RecordConverter) formatRecordData(record records.DNSRecord) string {
 	}
 }
 
+// formatSVCBData formats SVCB and HTTPS data as "priority target params",
+// the parameters hex encoded in their wire format and left out when there
+// are none
+func formatSVCBData(r *records.SVCBRecord) string {
+	var params []byte
+	for _, param := range r.Params() {
+		params = append(params, byte(param.Key>>8), byte(param.Key&0xFF))
+		params = append(params, byte(len(param.Value)>>8), byte(len(param.Value)&0xFF))
+		params = append(params, param.Value...)
+	}
+
+	if len(params) == 0 {
+		return fmt.Sprintf("%d %s", r.Priority(), r.TargetName())
+	}
+	return fmt.Sprintf("%d %s %X", r.Priority(), r.TargetName(), params)
+}
+
+// parseTXTRecord parses TXT record data as quoted character strings. Data
+// stored before TXT records were quoted holds the texts as they are, joined
+// by NUL bytes
+func (c *RecordConverter) parseTXTRecord(name, data string, ttl uint32) (records.DNSRecord, error) {
+	if !strings.HasPrefix(data, `"`) {
+		return records.NewTXTRecord(name, strings.Split(data, "\x00"), ttl), nil
+	}
+
+	strs, err := records.ParseCharacterStrings(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid TXT record data: %v", ErrInvalidRecord, err)
+	}
+	return records.NewTXTRecordFromStrings(name, strs, ttl), nil
+}
+
 // parseMXRecord parses MX record data in format "priority mailserver"
 func (c *RecordConverter) parseMXRecord(name, data string, ttl uint32) (records.DNSRecord, error) {
 	parts := strings.Fields(data)
@@ -168,6 +303,280 @@ func (c *RecordConverter) parseMXRecord(name, data string, ttl uint32) (records.
 	return records.NewMXRecord(name, parts[1], priority, ttl), nil
 }
 
+// parseCAARecord parses CAA record data in format "flags tag value". The
+// value is the remainder of the string and may contain spaces
+func (c *RecordConverter) parseCAARecord(name, data string, ttl uint32) (records.DNSRecord, error) {
+	parts := strings.SplitN(data, " ", 3)
+	if len(parts) != 3 || parts[1] == "" {
+		return nil, fmt.Errorf("%w: invalid CAA record format", ErrInvalidRecord)
+	}
+
+	var flags uint8
+	if _, err := fmt.Sscanf(parts[0], "%d", &flags); err != nil {
+		return nil, fmt.Errorf("%w: invalid CAA flags: %v", ErrInvalidRecord, err)
+	}
+
+	return records.NewCAARecord(name, parts[1], parts[2], flags, ttl), nil
+}
+
+// parseSRVRecord parses SRV record data in format "priority weight port target"
+func (c *RecordConverter) parseSRVRecord(name, data string, ttl uint32) (records.DNSRecord, error) {
+	parts := strings.Fields(data)
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("%w: invalid SRV record format", ErrInvalidRecord)
+	}
+
+	var priority, weight, port uint16
+
+	if _, err := fmt.Sscanf(parts[0], "%d", &priority); err != nil {
+		return nil, fmt.Errorf("%w: invalid SRV priority: %v", ErrInvalidRecord, err)
+	}
+	if _, err := fmt.Sscanf(parts[1], "%d", &weight); err != nil {
+		return nil, fmt.Errorf("%w: invalid SRV weight: %v", ErrInvalidRecord, err)
+	}
+	if _, err := fmt.Sscanf(parts[2], "%d", &port); err != nil {
+		return nil, fmt.Errorf("%w: invalid SRV port: %v", ErrInvalidRecord, err)
+	}
+
+	return records.NewSRVRecord(name, parts[3], priority, weight, port, ttl), nil
+}
+
+// parseNAPTRRecord parses NAPTR record data in format
+// `order preference "flags" "services" "regexp" replacement`
+func (c *RecordConverter) parseNAPTRRecord(name, data string, ttl uint32) (records.DNSRecord, error) {
+	fields := strings.SplitN(data, " ", 3)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("%w: invalid NAPTR record format", ErrInvalidRecord)
+	}
+
+	var order, preference uint16
+	if _, err := fmt.Sscanf(fields[0], "%d", &order); err != nil {
+		return nil, fmt.Errorf("%w: invalid NAPTR order: %v", ErrInvalidRecord, err)
+	}
+	if _, err := fmt.Sscanf(fields[1], "%d", &preference); err != nil {
+		return nil, fmt.Errorf("%w: invalid NAPTR preference: %v", ErrInvalidRecord, err)
+	}
+
+	rest := fields[2]
+	var texts [3]string
+	for i := range texts {
+		quoted, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid NAPTR character string: %v", ErrInvalidRecord, err)
+		}
+		texts[i], _ = strconv.Unquote(quoted)
+		rest = strings.TrimPrefix(rest[len(quoted):], " ")
+	}
+
+	if rest == "" || strings.Contains(rest, " ") {
+		return nil, fmt.Errorf("%w: invalid NAPTR replacement %q", ErrInvalidRecord, rest)
+	}
+
+	return records.NewNAPTRRecord(name, order, preference, texts[0], texts[1], texts[2], rest, ttl), nil
+}
+
+// parseTLSARecord parses TLSA record data in format
+// "usage selector matching-type hex-data"
+func (c *RecordConverter) parseTLSARecord(name, data string, ttl uint32) (records.DNSRecord, error) {
+	parts := strings.Fields(data)
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("%w: invalid TLSA record format", ErrInvalidRecord)
+	}
+
+	var fields [3]uint8
+	for i := range fields {
+		if _, err := fmt.Sscanf(parts[i], "%d", &fields[i]); err != nil {
+			return nil, fmt.Errorf("%w: invalid TLSA field %q: %v", ErrInvalidRecord, parts[i], err)
+		}
+	}
+	associationData, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid TLSA data: %v", ErrInvalidRecord, err)
+	}
+
+	record, err := records.NewTLSARecord(name, fields[0], fields[1], fields[2], associationData, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRecord, err)
+	}
+	return record, nil
+}
+
+// parseSVCBRecord parses SVCB and HTTPS record data in format
+// "priority target [hex-params]"
+func (c *RecordConverter) parseSVCBRecord(recordType types.DNSType, name, data string, ttl uint32) (records.DNSRecord, error) {
+	parts := strings.Fields(data)
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, fmt.Errorf("%w: invalid %s record format", ErrInvalidRecord, recordType)
+	}
+
+	var priority uint16
+	if _, err := fmt.Sscanf(parts[0], "%d", &priority); err != nil {
+		return nil, fmt.Errorf("%w: invalid %s priority: %v", ErrInvalidRecord, recordType, err)
+	}
+
+	var params []records.SvcParam
+	if len(parts) == 3 {
+		wire, err := hex.DecodeString(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid %s parameters: %v", ErrInvalidRecord, recordType, err)
+		}
+		if params, err = records.DecodeSvcParams(wire); err != nil {
+			return nil, fmt.Errorf("%w: invalid %s parameters: %v", ErrInvalidRecord, recordType, err)
+		}
+	}
+
+	if recordType == types.TYPE_HTTPS {
+		return records.NewHTTPSRecord(name, parts[1], priority, params, ttl), nil
+	}
+	return records.NewSVCBRecord(name, parts[1], priority, params, ttl), nil
+}
+
+// parseDNSKEYRecord parses DNSKEY record data in format
+// "flags protocol algorithm base64-key". The key may be split by spaces
+func (c *RecordConverter) parseDNSKEYRecord(name, data string, ttl uint32) (records.DNSRecord, error) {
+	parts := strings.Fields(data)
+	if len(parts) < 4 {
+		return nil, fmt.Errorf("%w: invalid DNSKEY record format", ErrInvalidRecord)
+	}
+
+	var flags uint16
+	var protocol, algorithm uint8
+	if _, err := fmt.Sscanf(parts[0], "%d", &flags); err != nil {
+		return nil, fmt.Errorf("%w: invalid DNSKEY flags: %v", ErrInvalidRecord, err)
+	}
+	if _, err := fmt.Sscanf(parts[1], "%d", &protocol); err != nil {
+		return nil, fmt.Errorf("%w: invalid DNSKEY protocol: %v", ErrInvalidRecord, err)
+	}
+	if _, err := fmt.Sscanf(parts[2], "%d", &algorithm); err != nil {
+		return nil, fmt.Errorf("%w: invalid DNSKEY algorithm: %v", ErrInvalidRecord, err)
+	}
+	publicKey, err := base64.StdEncoding.DecodeString(strings.Join(parts[3:], ""))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid DNSKEY public key: %v", ErrInvalidRecord, err)
+	}
+
+	record, err := records.NewDNSKEYRecord(name, flags, protocol, algorithm, publicKey, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRecord, err)
+	}
+	return record, nil
+}
+
+// parseDSRecord parses DS record data in format
+// "key-tag algorithm digest-type hex-digest". The digest may be split by spaces
+func (c *RecordConverter) parseDSRecord(name, data string, ttl uint32) (records.DNSRecord, error) {
+	parts := strings.Fields(data)
+	if len(parts) < 4 {
+		return nil, fmt.Errorf("%w: invalid DS record format", ErrInvalidRecord)
+	}
+
+	var keyTag uint16
+	var algorithm, digestType uint8
+	if _, err := fmt.Sscanf(parts[0], "%d", &keyTag); err != nil {
+		return nil, fmt.Errorf("%w: invalid DS key tag: %v", ErrInvalidRecord, err)
+	}
+	if _, err := fmt.Sscanf(parts[1], "%d", &algorithm); err != nil {
+		return nil, fmt.Errorf("%w: invalid DS algorithm: %v", ErrInvalidRecord, err)
+	}
+	if _, err := fmt.Sscanf(parts[2], "%d", &digestType); err != nil {
+		return nil, fmt.Errorf("%w: invalid DS digest type: %v", ErrInvalidRecord, err)
+	}
+	digest, err := hex.DecodeString(strings.Join(parts[3:], ""))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid DS digest: %v", ErrInvalidRecord, err)
+	}
+
+	record, err := records.NewDSRecord(name, keyTag, algorithm, digestType, digest, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRecord, err)
+	}
+	return record, nil
+}
+
+// parseRRSIGRecord parses RRSIG record data in format "type-covered
+// algorithm labels original-ttl expiration inception key-tag signer
+// base64-signature". The signature may be split by spaces
+func (c *RecordConverter) parseRRSIGRecord(name, data string, ttl uint32) (records.DNSRecord, error) {
+	parts := strings.Fields(data)
+	if len(parts) < 9 {
+		return nil, fmt.Errorf("%w: invalid RRSIG record format", ErrInvalidRecord)
+	}
+
+	typeCovered, ok := types.ParseDNSType(parts[0])
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid RRSIG type covered %q", ErrInvalidRecord, parts[0])
+	}
+
+	var algorithm, labels uint8
+	var originalTTL uint32
+	var keyTag uint16
+	if _, err := fmt.Sscanf(parts[1], "%d", &algorithm); err != nil {
+		return nil, fmt.Errorf("%w: invalid RRSIG algorithm: %v", ErrInvalidRecord, err)
+	}
+	if _, err := fmt.Sscanf(parts[2], "%d", &labels); err != nil {
+		return nil, fmt.Errorf("%w: invalid RRSIG labels: %v", ErrInvalidRecord, err)
+	}
+	if _, err := fmt.Sscanf(parts[3], "%d", &originalTTL); err != nil {
+		return nil, fmt.Errorf("%w: invalid RRSIG original TTL: %v", ErrInvalidRecord, err)
+	}
+	expiration, err := parseRRSIGTime(parts[4])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid RRSIG expiration: %v", ErrInvalidRecord, err)
+	}
+	inception, err := parseRRSIGTime(parts[5])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid RRSIG inception: %v", ErrInvalidRecord, err)
+	}
+	if _, err := fmt.Sscanf(parts[6], "%d", &keyTag); err != nil {
+		return nil, fmt.Errorf("%w: invalid RRSIG key tag: %v", ErrInvalidRecord, err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.Join(parts[8:], ""))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid RRSIG signature: %v", ErrInvalidRecord, err)
+	}
+
+	record, err := records.NewRRSIGRecord(name, typeCovered, algorithm, labels, originalTTL,
+		expiration, inception, keyTag, parts[7], signature, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRecord, err)
+	}
+	return record, nil
+}
+
+// parseRRSIGTime parses an RRSIG timestamp, either YYYYMMDDHHmmSS in UTC or
+// the seconds since the epoch as a decimal number (RFC 4034 §3.2)
+func parseRRSIGTime(value string) (time.Time, error) {
+	if len(value) == len(records.RRSIGTimeLayout) {
+		return time.Parse(records.RRSIGTimeLayout, value)
+	}
+
+	seconds, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(seconds), 0), nil
+}
+
+// parseNSECRecord parses NSEC record data in format "next-name [type...]",
+// the types given by mnemonic or in the TYPEnnn form
+func (c *RecordConverter) parseNSECRecord(name, data string, ttl uint32) (records.DNSRecord, error) {
+	parts := strings.Fields(data)
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("%w: invalid NSEC record format", ErrInvalidRecord)
+	}
+
+	recordTypes := make([]types.DNSType, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		recordType, ok := types.ParseDNSType(part)
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid NSEC type %q", ErrInvalidRecord, part)
+		}
+		recordTypes = append(recordTypes, recordType)
+	}
+
+	return records.NewNSECRecord(name, parts[0], recordTypes, ttl), nil
+}
+
 // parseSOARecord parses SOA record data
 func (c *RecordConverter) parseSOARecord(name, data string, ttl uint32) (records.DNSRecord, error) {
 	// SOA format: "primaryNS responsible serial refresh retry expire minimum"
@@ -0,0 +1,316 @@
+package storage_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+func TestRecordConverter_SRVRoundTrip(t *testing.T) {
+	converter := storage.NewRecordConverter()
+	original := records.NewSRVRecord("_sip._udp.example.com", "sip.example.com.", 10, 60, 5060, 300)
+
+	data, err := converter.ToStorageFormat(original)
+	require.NoError(t, err)
+	assert.Equal(t, int(types.TYPE_SRV), data.RecordType)
+	assert.Equal(t, "10 60 5060 sip.example.com.", data.Data)
+
+	restored, err := converter.FromStorageFormat(data)
+	require.NoError(t, err)
+
+	srv, ok := restored.(*records.SRVRecord)
+	require.True(t, ok, "expected *records.SRVRecord, got %T", restored)
+	assert.Equal(t, original.Priority(), srv.Priority())
+	assert.Equal(t, original.Weight(), srv.Weight())
+	assert.Equal(t, original.Port(), srv.Port())
+	assert.Equal(t, original.Target(), srv.Target())
+	assert.Equal(t, original.Data(), srv.Data())
+}
+
+func TestRecordConverter_RejectsMalformedSRV(t *testing.T) {
+	converter := storage.NewRecordConverter()
+
+	for _, data := range []string{"10 60 sip.example.com.", "10 60 port sip.example.com.", "10 60 70000 sip.example.com."} {
+		_, err := converter.FromStorageFormat(&storage.RecordData{
+			Name:       "_sip._udp.example.com.",
+			RecordType: int(types.TYPE_SRV),
+			Class:      int(types.CLASS_IN),
+			TTL:        300,
+			Data:       data,
+		})
+		assert.ErrorIs(t, err, storage.ErrInvalidRecord, "data %q", data)
+	}
+}
+
+func TestRecordConverter_CAARoundTrip(t *testing.T) {
+	converter := storage.NewRecordConverter()
+	original := records.NewCAARecord("example.com", "issue", "ca.example.net; account=230123", records.CAAFlagCritical, 3600)
+
+	data, err := converter.ToStorageFormat(original)
+	require.NoError(t, err)
+	assert.Equal(t, int(types.TYPE_CAA), data.RecordType)
+
+	restored, err := converter.FromStorageFormat(data)
+	require.NoError(t, err)
+
+	caa, ok := restored.(*records.CAARecord)
+	require.True(t, ok, "expected *records.CAARecord, got %T", restored)
+	assert.Equal(t, original.Flags(), caa.Flags())
+	assert.Equal(t, original.Tag(), caa.Tag())
+	assert.Equal(t, original.Value(), caa.Value(), "value with spaces must survive the round trip")
+	assert.True(t, caa.Critical())
+}
+
+func TestRecordConverter_NAPTRRoundTrip(t *testing.T) {
+	converter := storage.NewRecordConverter()
+
+	for _, regexp := range []string{
+		`!^.*$!sip:info@example.com!`,
+		`!^\+?(\d+)$!tel:+\1!`,
+		"spaces \"quotes\" \\ and \t control \x00 bytes",
+		"",
+	} {
+		original := records.NewNAPTRRecord("4.3.2.1.5.5.5.0.0.8.1.e164.arpa", 100, 10, "u", "E2U+sip", regexp, ".", 300)
+
+		data, err := converter.ToStorageFormat(original)
+		require.NoError(t, err)
+		assert.Equal(t, int(types.TYPE_NAPTR), data.RecordType)
+
+		restored, err := converter.FromStorageFormat(data)
+		require.NoError(t, err, "data %q", data.Data)
+
+		naptr, ok := restored.(*records.NAPTRRecord)
+		require.True(t, ok, "expected *records.NAPTRRecord, got %T", restored)
+		assert.Equal(t, original.Regexp(), naptr.Regexp())
+		assert.Equal(t, original.Data(), naptr.Data())
+	}
+}
+
+func TestRecordConverter_RejectsMalformedNAPTR(t *testing.T) {
+	converter := storage.NewRecordConverter()
+
+	for _, data := range []string{
+		`100 10 "u" "E2U+sip" "!^.*$!sip:info@example.com!"`,
+		`100 10 u E2U+sip "" .`,
+		`100 "u" "E2U+sip" "" .`,
+	} {
+		_, err := converter.FromStorageFormat(&storage.RecordData{
+			Name:       "4.3.2.1.5.5.5.0.0.8.1.e164.arpa.",
+			RecordType: int(types.TYPE_NAPTR),
+			Class:      int(types.CLASS_IN),
+			TTL:        300,
+			Data:       data,
+		})
+		assert.ErrorIs(t, err, storage.ErrInvalidRecord, "data %q", data)
+	}
+}
+
+func TestRecordConverter_TLSARoundTrip(t *testing.T) {
+	converter := storage.NewRecordConverter()
+	original, err := records.NewTLSARecord("_443._tcp.example.com.", 3, 1, 1, make([]byte, 32), 3600)
+	require.NoError(t, err)
+
+	data, err := converter.ToStorageFormat(original)
+	require.NoError(t, err)
+	assert.Equal(t, "3 1 1 0000000000000000000000000000000000000000000000000000000000000000", data.Data)
+
+	restored, err := converter.FromStorageFormat(data)
+	require.NoError(t, err)
+
+	tlsa, ok := restored.(*records.TLSARecord)
+	require.True(t, ok, "expected *records.TLSARecord, got %T", restored)
+	assert.Equal(t, original.Data(), tlsa.Data())
+}
+
+func TestRecordConverter_SVCBRoundTrip(t *testing.T) {
+	converter := storage.NewRecordConverter()
+	params := []records.SvcParam{
+		records.NewALPNParam("h2", "h3"),
+		records.NewPortParam(8443),
+		records.NewIPv4HintParam(net.ParseIP("192.0.2.1")),
+	}
+
+	for _, original := range []records.DNSRecord{
+		records.NewSVCBRecord("_dns.example.com.", "dns.example.com.", 1, params, 300),
+		records.NewHTTPSRecord("example.com.", "svc.example.net.", 1, params, 300),
+		records.NewHTTPSRecord("www.example.com.", "example.com.", 0, nil, 300),
+	} {
+		data, err := converter.ToStorageFormat(original)
+		require.NoError(t, err)
+
+		restored, err := converter.FromStorageFormat(data)
+		require.NoError(t, err, "data %q", data.Data)
+		assert.Equal(t, original.Type(), restored.Type())
+		assert.Equal(t, original.Data(), restored.Data())
+	}
+}
+
+func TestRecordConverter_DNSSECRoundTrip(t *testing.T) {
+	converter := storage.NewRecordConverter()
+	dnskey, err := records.NewDNSKEYRecord("example.com.", records.DNSKEYFlagZone|records.DNSKEYFlagSEP, 3, 13, []byte{0x01, 0x02, 0x03, 0x04}, 3600)
+	require.NoError(t, err)
+	ds, err := records.NewDSRecord("example.com.", 2371, 13, records.DSDigestSHA256, make([]byte, 32), 3600)
+	require.NoError(t, err)
+	rrsig, err := records.NewRRSIGRecord("www.example.com.", types.TYPE_A, 13, 3, 300,
+		time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		2371, "example.com.", []byte{0xDE, 0xAD, 0xBE, 0xEF}, 300)
+	require.NoError(t, err)
+
+	tests := []struct {
+		record records.DNSRecord
+		data   string
+	}{
+		{record: dnskey, data: "257 3 13 AQIDBA=="},
+		{record: ds, data: "2371 13 2 0000000000000000000000000000000000000000000000000000000000000000"},
+		{record: rrsig, data: "A 13 3 300 20240201000000 20240101000000 2371 example.com. 3q2+7w=="},
+		{
+			record: records.NewNSECRecord("www.example.com.", "example.com.", []types.DNSType{types.TYPE_RRSIG, types.TYPE_A, types.TYPE_NSEC, 1234}, 300),
+			data:   "example.com. A RRSIG NSEC TYPE1234",
+		},
+	}
+
+	for _, tt := range tests {
+		data, err := converter.ToStorageFormat(tt.record)
+		require.NoError(t, err)
+		assert.Equal(t, tt.data, data.Data)
+
+		restored, err := converter.FromStorageFormat(data)
+		require.NoError(t, err, "data %q", data.Data)
+		assert.Equal(t, tt.record.Type(), restored.Type())
+		assert.Equal(t, tt.record.Data(), restored.Data())
+	}
+}
+
+func TestRecordConverter_ParsesDNSSECPresentation(t *testing.T) {
+	converter := storage.NewRecordConverter()
+
+	// Keys and signatures split into several fields, and timestamps in
+	// seconds, as zone files often have them
+	restored, err := converter.FromStorageFormat(&storage.RecordData{
+		Name:       "www.example.com.",
+		RecordType: int(types.TYPE_RRSIG),
+		Class:      int(types.CLASS_IN),
+		TTL:        300,
+		Data:       "aaaa 13 3 300 1706745600 20240101000000 2371 example.com. 3q2+ 7w==",
+	})
+	require.NoError(t, err)
+	rrsig, ok := restored.(*records.RRSIGRecord)
+	require.True(t, ok, "expected *records.RRSIGRecord, got %T", restored)
+	assert.Equal(t, types.TYPE_AAAA, rrsig.TypeCovered())
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), rrsig.Expiration())
+	assert.Equal(t, []byte{0xDE, 0xAD, 0xBE, 0xEF}, rrsig.Signature())
+
+	for _, tt := range []struct {
+		recordType types.DNSType
+		data       string
+	}{
+		{types.TYPE_DNSKEY, "257 2 13 AQIDBA=="},
+		{types.TYPE_DNSKEY, "257 3 13 not-base64"},
+		{types.TYPE_DS, "2371 13 2 00"},
+		{types.TYPE_DS, "2371 13 2"},
+		{types.TYPE_RRSIG, "BOGUS 13 3 300 20240201000000 20240101000000 2371 example.com. 3q2+7w=="},
+		{types.TYPE_RRSIG, "A 13 3 300 2024-02-01 20240101000000 2371 example.com. 3q2+7w=="},
+		{types.TYPE_NSEC, "example.com. A BOGUS"},
+		{types.TYPE_NSEC, ""},
+	} {
+		_, err := converter.FromStorageFormat(&storage.RecordData{
+			Name:       "example.com.",
+			RecordType: int(tt.recordType),
+			Class:      int(types.CLASS_IN),
+			TTL:        300,
+			Data:       tt.data,
+		})
+		assert.ErrorIs(t, err, storage.ErrInvalidRecord, "%s data %q", tt.recordType, tt.data)
+	}
+}
+
+func TestRecordConverter_TXTRoundTrip(t *testing.T) {
+	converter := storage.NewRecordConverter()
+	long := strings.Repeat("v=spf1 include:_spf.example.net ", 22)[:700]
+
+	for _, original := range []*records.TXTRecord{
+		records.NewTXTRecordFromString("example.com.", "v=spf1 mx -all", 300),
+		records.NewTXTRecordFromString("example.com.", long, 300),
+		records.NewTXTRecordFromString("example.com.", `note "quoted" and back\slash`, 300),
+		records.NewTXTRecordFromStrings("example.com.", []string{"first", "", "third part"}, 300),
+	} {
+		data, err := converter.ToStorageFormat(original)
+		require.NoError(t, err)
+
+		restored, err := converter.FromStorageFormat(data)
+		require.NoError(t, err, "data %q", data.Data)
+		txt, ok := restored.(*records.TXTRecord)
+		require.True(t, ok, "expected *records.TXTRecord, got %T", restored)
+		assert.Equal(t, original.Data(), txt.Data())
+		assert.Equal(t, original.Text(), txt.Text())
+	}
+
+	data, err := converter.ToStorageFormat(records.NewTXTRecordFromString("example.com.", `say "hi"`, 300))
+	require.NoError(t, err)
+	assert.Equal(t, `"say \"hi\""`, data.Data)
+}
+
+func TestRecordConverter_ParsesTXT(t *testing.T) {
+	converter := storage.NewRecordConverter()
+
+	for _, tt := range []struct {
+		data     string
+		expected []string
+	}{
+		// Stored before TXT data was quoted
+		{data: "v=spf1 +all", expected: []string{"v=spf1 +all"}},
+		{data: "first\x00second", expected: []string{"first", "second"}},
+		{data: `"a b" "c"`, expected: []string{"a b", "c"}},
+	} {
+		restored, err := converter.FromStorageFormat(&storage.RecordData{
+			Name:       "example.com.",
+			RecordType: int(types.TYPE_TXT),
+			Class:      int(types.CLASS_IN),
+			TTL:        300,
+			Data:       tt.data,
+		})
+		require.NoError(t, err, "data %q", tt.data)
+		assert.Equal(t, tt.expected, restored.(*records.TXTRecord).Texts(), "data %q", tt.data)
+	}
+
+	_, err := converter.FromStorageFormat(&storage.RecordData{
+		Name:       "example.com.",
+		RecordType: int(types.TYPE_TXT),
+		Class:      int(types.CLASS_IN),
+		TTL:        300,
+		Data:       `"unterminated`,
+	})
+	assert.ErrorIs(t, err, storage.ErrInvalidRecord)
+}
+
+func TestRecordConverter_GenericRoundTrip(t *testing.T) {
+	converter := storage.NewRecordConverter()
+	original := records.NewGenericRecord("example.com.", types.DNSType(65280), []byte{0x0a, 0x00, 0x00, 0x01}, 300)
+
+	data, err := converter.ToStorageFormat(original)
+	require.NoError(t, err)
+	assert.Equal(t, `\# 4 0A000001`, data.Data)
+
+	restored, err := converter.FromStorageFormat(data)
+	require.NoError(t, err)
+	assert.Equal(t, types.DNSType(65280), restored.Type())
+	assert.Equal(t, original.Data(), restored.Data())
+
+	for _, data := range []string{"0A000001", `\# 5 0A000001`, `\# 4 0A0000ZZ`} {
+		_, err := converter.FromStorageFormat(&storage.RecordData{
+			Name:       "example.com.",
+			RecordType: 65280,
+			Class:      int(types.CLASS_IN),
+			TTL:        300,
+			Data:       data,
+		})
+		assert.ErrorIs(t, err, storage.ErrInvalidRecord, "data %q", data)
+	}
+}
@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// RecordDiff is the difference between two sets of records. Records are
+// compared individually, so one address added to a round-robin A set is one
+// added record
+type RecordDiff struct {
+	Added   []records.DNSRecord
+	Removed []records.DNSRecord
+	Changed []RecordChange // Same name, type and RDATA with another TTL
+
+	unchanged []records.DNSRecord
+}
+
+// RecordChange is a record whose TTL changed
+type RecordChange struct {
+	Old records.DNSRecord
+	New records.DNSRecord
+}
+
+// recordKey identifies a record by its lowercased name, type, view and
+// canonical RDATA
+type recordKey struct {
+	name       string
+	recordType types.DNSType
+	view       string
+	rdata      string
+}
+
+// rrsetKey identifies the records of a name and type
+type rrsetKey struct {
+	name       string
+	recordType types.DNSType
+}
+
+// DiffRecordSets returns the records to add, remove and update to turn the
+// old set into the new one. Records with the same name, type and RDATA but a
+// different TTL count as changed rather than removed and added. Duplicates
+// within a set count once
+func DiffRecordSets(oldSet, newSet []records.DNSRecord) RecordDiff {
+	oldByKey := make(map[recordKey]records.DNSRecord, len(oldSet))
+	for _, record := range oldSet {
+		oldByKey[diffKey(record)] = record
+	}
+
+	var diff RecordDiff
+	seen := make(map[recordKey]bool, len(newSet))
+	for _, record := range newSet {
+		key := diffKey(record)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		previous, ok := oldByKey[key]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, record)
+		case previous.TTL() != record.TTL():
+			diff.Changed = append(diff.Changed, RecordChange{Old: previous, New: record})
+		default:
+			diff.unchanged = append(diff.unchanged, record)
+		}
+	}
+
+	for _, record := range oldSet {
+		key := diffKey(record)
+		if !seen[key] {
+			seen[key] = true
+			diff.Removed = append(diff.Removed, record)
+		}
+	}
+
+	return diff
+}
+
+// Empty reports whether the sets were equal
+func (d RecordDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Summary counts the added, removed and changed records
+func (d RecordDiff) Summary() string {
+	return fmt.Sprintf("%d added, %d removed, %d changed", len(d.Added), len(d.Removed), len(d.Changed))
+}
+
+// String lists the differences one record per line: "+" for added, "-" for
+// removed and "~" for changed records
+func (d RecordDiff) String() string {
+	var b strings.Builder
+	for _, record := range d.Added {
+		fmt.Fprintf(&b, "+ %s\n", record)
+	}
+	for _, record := range d.Removed {
+		fmt.Fprintf(&b, "- %s\n", record)
+	}
+	for _, change := range d.Changed {
+		fmt.Fprintf(&b, "~ %s (TTL %d -> %d)\n", change.New, change.Old.TTL(), change.New.TTL())
+	}
+	return b.String()
+}
+
+// ApplyDiff applies diff to store in a single transaction. Storages delete
+// whole RRsets, so an RRset losing records is deleted and the records it
+// keeps are stored again; RRsets that only gain or change records are left
+// in place. The old set diff was computed from must hold every stored record
+// of its names
+func ApplyDiff(ctx context.Context, store Storage, diff RecordDiff) error {
+	if diff.Empty() {
+		return nil
+	}
+
+	shrinking := make(map[rrsetKey]bool)
+	for _, record := range diff.Removed {
+		shrinking[diffRRSetKey(record)] = true
+	}
+
+	puts := make([]records.DNSRecord, 0, len(diff.Added)+len(diff.Changed))
+	puts = append(puts, diff.Added...)
+	for _, change := range diff.Changed {
+		puts = append(puts, change.New)
+	}
+	for _, record := range diff.unchanged {
+		if shrinking[diffRRSetKey(record)] {
+			puts = append(puts, record)
+		}
+	}
+
+	tx, err := store.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	deleted := make(map[rrsetKey]bool, len(shrinking))
+	for _, record := range diff.Removed {
+		key := diffRRSetKey(record)
+		if deleted[key] {
+			continue
+		}
+		deleted[key] = true
+
+		if err := tx.DeleteRecord(ctx, record.Name(), record.Type()); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to stage deleting %s %s: %w", record.Name(), record.Type(), err)
+		}
+	}
+
+	for _, record := range puts {
+		if err := tx.PutRecord(ctx, record); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to stage storing %s %s: %w", record.Name(), record.Type(), err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// SameRecord reports whether a and b have the same name, type, view and
+// RDATA, compared the way DiffRecordSets does. TTLs are not compared
+func SameRecord(a, b records.DNSRecord) bool {
+	return diffKey(a) == diffKey(b)
+}
+
+// diffKey returns the key a record is compared by
+func diffKey(record records.DNSRecord) recordKey {
+	return recordKey{
+		name:       normalizeDomainName(record.Name()),
+		recordType: record.Type(),
+		view:       RecordView(record),
+		rdata:      string(canonicalRData(record)),
+	}
+}
+
+// diffRRSetKey returns the key of the RRset a record belongs to
+func diffRRSetKey(record records.DNSRecord) rrsetKey {
+	return rrsetKey{name: normalizeDomainName(record.Name()), recordType: record.Type()}
+}
+
+// canonicalRData returns the RDATA of record with the domain names of the
+// types listed in RFC 4034 §6.2 lowercased, so names differing in case only
+// compare equal
+func canonicalRData(record records.DNSRecord) []byte {
+	data := record.Data()
+
+	// Offset of the domain name within the RDATA
+	var offset int
+	switch record.Type() {
+	case types.TYPE_CNAME, types.TYPE_DNAME, types.TYPE_NS, types.TYPE_PTR:
+		offset = 0
+	case types.TYPE_MX:
+		offset = 2
+	case types.TYPE_SRV:
+		offset = 6
+	default:
+		return data
+	}
+	if len(data) <= offset {
+		return data
+	}
+
+	canonical := make([]byte, len(data))
+	copy(canonical, data)
+	for i := offset; i < len(canonical) && canonical[i] != 0; {
+		length := int(canonical[i])
+		for j := i + 1; j <= i+length && j < len(canonical); j++ {
+			if c := canonical[j]; c >= 'A' && c <= 'Z' {
+				canonical[j] = c + ('a' - 'A')
+			}
+		}
+		i += length + 1
+	}
+	return canonical
+}
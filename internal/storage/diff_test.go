@@ -0,0 +1,145 @@
+package storage_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+func aRecord(name, ip string, ttl uint32) records.DNSRecord {
+	return records.NewARecord(name, net.ParseIP(ip), ttl)
+}
+
+func recordStrings(recordList []records.DNSRecord) []string {
+	out := make([]string, 0, len(recordList))
+	for _, record := range recordList {
+		out = append(out, record.String())
+	}
+	return out
+}
+
+func TestDiffRecordSets(t *testing.T) {
+	oldSet := []records.DNSRecord{
+		aRecord("www.example.com.", "192.0.2.1", 300),
+		aRecord("www.example.com.", "192.0.2.2", 300),
+		aRecord("mail.example.com.", "192.0.2.25", 300),
+		records.NewCNAMERecord("alias.example.com.", "WWW.example.com.", 300),
+		records.NewTXTRecord("example.com.", []string{"v=spf1 -all"}, 300),
+	}
+	newSet := []records.DNSRecord{
+		// Round-robin set losing one address and gaining another
+		aRecord("WWW.example.com", "192.0.2.2", 300),
+		aRecord("www.example.com.", "192.0.2.3", 300),
+		// TTL-only change
+		aRecord("mail.example.com.", "192.0.2.25", 60),
+		// Target differing in case only
+		records.NewCNAMERecord("alias.example.com.", "www.example.com.", 300),
+		records.NewTXTRecord("example.com.", []string{"v=spf1 mx -all"}, 300),
+	}
+
+	diff := storage.DiffRecordSets(oldSet, newSet)
+
+	assert.Equal(t, recordStrings([]records.DNSRecord{
+		aRecord("www.example.com.", "192.0.2.3", 300),
+		records.NewTXTRecord("example.com.", []string{"v=spf1 mx -all"}, 300),
+	}), recordStrings(diff.Added))
+	assert.Equal(t, recordStrings([]records.DNSRecord{
+		aRecord("www.example.com.", "192.0.2.1", 300),
+		records.NewTXTRecord("example.com.", []string{"v=spf1 -all"}, 300),
+	}), recordStrings(diff.Removed))
+
+	require.Len(t, diff.Changed, 1)
+	assert.Equal(t, uint32(300), diff.Changed[0].Old.TTL())
+	assert.Equal(t, uint32(60), diff.Changed[0].New.TTL())
+
+	assert.False(t, diff.Empty())
+	assert.Equal(t, "2 added, 2 removed, 1 changed", diff.Summary())
+	assert.Contains(t, diff.String(), "~ mail.example.com. 60 IN A 192.0.2.25 (TTL 300 -> 60)\n")
+	assert.Contains(t, diff.String(), "- www.example.com. 300 IN A 192.0.2.1\n")
+}
+
+func TestDiffRecordSets_Equal(t *testing.T) {
+	set := []records.DNSRecord{
+		aRecord("www.example.com.", "192.0.2.1", 300),
+		aRecord("www.example.com.", "192.0.2.1", 300),
+	}
+
+	diff := storage.DiffRecordSets(set, set[:1])
+	assert.True(t, diff.Empty())
+	assert.Empty(t, diff.String())
+}
+
+func TestSameRecord(t *testing.T) {
+	record := records.NewCNAMERecord("www.example.com.", "web.example.com.", 300)
+
+	assert.True(t, storage.SameRecord(record, records.NewCNAMERecord("WWW.example.com", "Web.Example.com.", 60)))
+	assert.False(t, storage.SameRecord(record, records.NewCNAMERecord("www.example.com.", "web.example.net.", 300)))
+	assert.False(t, storage.SameRecord(aRecord("www.example.com.", "192.0.2.1", 300), aRecord("www.example.com.", "192.0.2.2", 300)))
+}
+
+func TestApplyDiff(t *testing.T) {
+	ctx := context.Background()
+	s, err := storage.NewMemoryStorage(nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	oldSet := []records.DNSRecord{
+		aRecord("www.example.com.", "192.0.2.1", 300),
+		aRecord("www.example.com.", "192.0.2.2", 300),
+		aRecord("mail.example.com.", "192.0.2.25", 300),
+		aRecord("old.example.com.", "192.0.2.99", 300),
+	}
+	require.NoError(t, s.BatchPutRecords(ctx, oldSet))
+
+	newSet := []records.DNSRecord{
+		aRecord("www.example.com.", "192.0.2.2", 300),
+		aRecord("www.example.com.", "192.0.2.3", 300),
+		aRecord("mail.example.com.", "192.0.2.25", 60),
+		aRecord("new.example.com.", "192.0.2.100", 300),
+	}
+
+	events, err := s.Subscribe(ctx)
+	require.NoError(t, err)
+
+	diff := storage.DiffRecordSets(oldSet, newSet)
+	require.NoError(t, storage.ApplyDiff(ctx, s, diff))
+
+	for _, name := range []string{"www.example.com.", "mail.example.com.", "new.example.com.", "old.example.com."} {
+		var expected []string
+		for _, record := range newSet {
+			if record.Name() == name {
+				expected = append(expected, record.String())
+			}
+		}
+
+		stored, err := s.GetRecords(ctx, name, types.TYPE_A)
+		if expected == nil {
+			assert.Empty(t, stored, name)
+			continue
+		}
+		require.NoError(t, err)
+		assert.ElementsMatch(t, expected, recordStrings(stored), name)
+	}
+
+	// The unchanged mail.example.com. set is updated in place, not deleted
+	for range 6 {
+		event := <-events
+		if event.Operation == storage.ChangeOperationDelete {
+			assert.NotEqual(t, "mail.example.com.", event.Name)
+		}
+	}
+
+	// Applying an empty diff changes nothing
+	require.NoError(t, storage.ApplyDiff(ctx, s, storage.DiffRecordSets(newSet, newSet)))
+	select {
+	case event := <-events:
+		t.Errorf("unexpected change event for an empty diff: %+v", event)
+	default:
+	}
+}
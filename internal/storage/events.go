@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// defaultSubscriberBuffer is the number of events buffered per subscriber
+// before further events for it are dropped
+const defaultSubscriberBuffer = 256
+
+// changeNotifier fans change events out to subscribers. Publishing never
+// blocks: an event that does not fit in a subscriber's buffer is dropped for
+// that subscriber and counted
+type changeNotifier struct {
+	mu          sync.RWMutex
+	subscribers map[chan ChangeEvent]struct{}
+	bufferSize  int
+	closed      bool
+	done        chan struct{} // Closed by close to release context watchers
+	dropped     atomic.Uint64
+}
+
+// newChangeNotifier creates a notifier with the default per-subscriber buffer
+func newChangeNotifier() *changeNotifier {
+	return &changeNotifier{
+		subscribers: make(map[chan ChangeEvent]struct{}),
+		bufferSize:  defaultSubscriberBuffer,
+		done:        make(chan struct{}),
+	}
+}
+
+// subscribe registers a subscriber whose channel is closed when ctx is
+// cancelled or the notifier is closed
+func (n *changeNotifier) subscribe(ctx context.Context) (<-chan ChangeEvent, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return nil, ErrStorageClosed
+	}
+
+	events := make(chan ChangeEvent, n.bufferSize)
+	n.subscribers[events] = struct{}{}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			n.unsubscribe(events)
+		case <-n.done:
+		}
+	}()
+
+	return events, nil
+}
+
+// unsubscribe removes a subscriber and closes its channel
+func (n *changeNotifier) unsubscribe(events chan ChangeEvent) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, ok := n.subscribers[events]; ok {
+		delete(n.subscribers, events)
+		close(events)
+	}
+}
+
+// publish delivers events to every subscriber without blocking
+func (n *changeNotifier) publish(events ...ChangeEvent) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	for subscriber := range n.subscribers {
+		for _, event := range events {
+			select {
+			case subscriber <- event:
+			default:
+				n.dropped.Add(1)
+			}
+		}
+	}
+}
+
+// droppedEvents returns the number of events dropped for slow subscribers
+func (n *changeNotifier) droppedEvents() uint64 {
+	return n.dropped.Load()
+}
+
+// close closes every subscriber channel and rejects new subscriptions
+func (n *changeNotifier) close() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return
+	}
+	n.closed = true
+	close(n.done)
+
+	for events := range n.subscribers {
+		delete(n.subscribers, events)
+		close(events)
+	}
+}
+
+// newChangeEvent creates an event for name stamped with the current time
+func newChangeEvent(operation ChangeOperation, name string, recordType types.DNSType, zone string) ChangeEvent {
+	return ChangeEvent{
+		Operation:  operation,
+		Name:       name,
+		RecordType: recordType,
+		Zone:       zone,
+		Timestamp:  time.Now(),
+	}
+}
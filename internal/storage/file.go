@@ -0,0 +1,337 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+const (
+	// DefaultFlushInterval is how long FileStorage collects changes before
+	// writing them, so a burst of changes results in a single write
+	DefaultFlushInterval = 100 * time.Millisecond
+
+	// maxFileLineSize bounds a single record line when loading the file
+	maxFileLineSize = 1 << 20
+)
+
+// FileStorage keeps records in memory and persists them to a JSON Lines file
+// holding one record per line. The file is replaced atomically through a
+// temporary file and a rename, so a crash leaves either the previous or the
+// new version on disk.
+//
+// Changes are written once the flush interval has passed since the first
+// unwritten change; Flush and Close write pending changes immediately. With a
+// zero interval every change is written before the call making it returns.
+type FileStorage struct {
+	*MemoryStorage
+	path          string
+	flushInterval time.Duration
+
+	writeMu sync.Mutex // Serializes writes of the file
+	timerMu sync.Mutex // Guards timer
+	timer   *time.Timer
+}
+
+// fileRecord is the form of a record on a line of the storage file
+type fileRecord struct {
+	Name       string `json:"name"`
+	RecordType int    `json:"record_type"`
+	Class      int    `json:"class"`
+	TTL        uint32 `json:"ttl"`
+	Data       string `json:"data"`
+	View       string `json:"view,omitempty"`
+}
+
+// NewFileStorage creates a file-backed storage, loading the records stored
+// at path. A missing file is created on the first change. Entries that cannot
+// be parsed or fail validation are skipped and logged
+func NewFileStorage(path string, flushInterval time.Duration, validationConfig *ValidationConfig) (*FileStorage, error) {
+	if path == "" {
+		return nil, errors.New("file storage path is required")
+	}
+
+	memory, err := NewMemoryStorage(validationConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &FileStorage{
+		MemoryStorage: memory,
+		path:          path,
+		flushInterval: max(flushInterval, 0),
+	}
+
+	if err := s.load(); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+// Path returns the path of the storage file
+func (s *FileStorage) Path() string {
+	return s.path
+}
+
+// PutRecord stores or updates a record and schedules a write of the file
+func (s *FileStorage) PutRecord(ctx context.Context, record records.DNSRecord) error {
+	if err := s.MemoryStorage.PutRecord(ctx, record); err != nil {
+		return err
+	}
+	return s.changed()
+}
+
+// DeleteRecord removes records and schedules a write of the file
+func (s *FileStorage) DeleteRecord(ctx context.Context, name string, recordType types.DNSType) error {
+	if err := s.MemoryStorage.DeleteRecord(ctx, name, recordType); err != nil {
+		return err
+	}
+	return s.changed()
+}
+
+// BatchPutRecords stores records atomically and schedules a write of the file
+func (s *FileStorage) BatchPutRecords(ctx context.Context, recordList []records.DNSRecord) error {
+	if err := s.MemoryStorage.BatchPutRecords(ctx, recordList); err != nil {
+		return err
+	}
+	return s.changed()
+}
+
+// BatchDeleteRecords deletes records and schedules a write of the file
+func (s *FileStorage) BatchDeleteRecords(ctx context.Context, names []string, recordType types.DNSType) error {
+	if err := s.MemoryStorage.BatchDeleteRecords(ctx, names, recordType); err != nil {
+		return err
+	}
+	return s.changed()
+}
+
+// ReplaceRRSet replaces an RRset and schedules a write of the file
+func (s *FileStorage) ReplaceRRSet(ctx context.Context, name string, recordType types.DNSType, recordList []records.DNSRecord) error {
+	if err := s.MemoryStorage.ReplaceRRSet(ctx, name, recordType, recordList); err != nil {
+		return err
+	}
+	return s.changed()
+}
+
+// Begin starts a transaction whose commit schedules a write of the file
+func (s *FileStorage) Begin(ctx context.Context) (Transaction, error) {
+	tx, err := s.MemoryStorage.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &fileTransaction{Transaction: tx, storage: s}, nil
+}
+
+// RestoreSnapshot replaces the records of the zone of a snapshot with those
+// saved in it and writes the file. Snapshots are kept in memory only, so
+// they do not outlive the process
+func (s *FileStorage) RestoreSnapshot(ctx context.Context, snapshotID string) error {
+	return s.restoreSnapshot(ctx, s, snapshotID)
+}
+
+// Flush writes pending changes to the file immediately
+func (s *FileStorage) Flush() error {
+	s.timerMu.Lock()
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.timerMu.Unlock()
+
+	return s.write()
+}
+
+// Close writes pending changes and closes the storage
+func (s *FileStorage) Close() error {
+	err := s.Flush()
+	if errors.Is(err, ErrStorageClosed) {
+		err = nil
+	}
+
+	if closeErr := s.MemoryStorage.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// changed writes the file now or schedules a write, depending on the flush
+// interval
+func (s *FileStorage) changed() error {
+	if s.flushInterval == 0 {
+		return s.write()
+	}
+
+	s.timerMu.Lock()
+	defer s.timerMu.Unlock()
+
+	// Later changes join the pending write instead of postponing it, so a
+	// steady stream of changes cannot delay persistence indefinitely
+	if s.timer == nil {
+		s.timer = time.AfterFunc(s.flushInterval, s.flushScheduled)
+	}
+	return nil
+}
+
+// flushScheduled runs a scheduled write
+func (s *FileStorage) flushScheduled() {
+	s.timerMu.Lock()
+	s.timer = nil
+	s.timerMu.Unlock()
+
+	if err := s.write(); err != nil && !errors.Is(err, ErrStorageClosed) {
+		logger.Error("Failed to write records", "file", s.path, "error", err)
+	}
+}
+
+// write replaces the file with the current records
+func (s *FileStorage) write() error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	recordList, err := s.MemoryStorage.ListRecords(context.Background())
+	if err != nil {
+		return err
+	}
+
+	// A stable order keeps the file diffable between writes
+	sort.Slice(recordList, func(i, j int) bool {
+		if recordList[i].Name() != recordList[j].Name() {
+			return recordList[i].Name() < recordList[j].Name()
+		}
+		return recordList[i].Type() < recordList[j].Type()
+	})
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range recordList {
+		data, err := s.converter.ToStorageFormat(record)
+		if err != nil {
+			return err
+		}
+
+		if err := encoder.Encode(fileRecord{
+			Name:       data.Name,
+			RecordType: data.RecordType,
+			Class:      data.Class,
+			TTL:        data.TTL,
+			Data:       data.Data,
+			View:       data.View,
+		}); err != nil {
+			return fmt.Errorf("failed to encode %s: %w", record.Name(), err)
+		}
+	}
+
+	return writeFileAtomic(s.path, buf.Bytes())
+}
+
+// load reads the records stored in the file
+func (s *FileStorage) load() error {
+	file, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	ctx := context.Background()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxFileLineSize)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var entry fileRecord
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			logger.Warn("Skipping corrupt entry", "file", s.path, "line", line, "error", err)
+			continue
+		}
+
+		record, err := s.converter.FromStorageFormat(&RecordData{
+			Name:       entry.Name,
+			RecordType: entry.RecordType,
+			Class:      entry.Class,
+			TTL:        entry.TTL,
+			Data:       entry.Data,
+			View:       entry.View,
+		})
+		if err == nil {
+			err = s.MemoryStorage.PutRecord(ctx, record)
+		}
+		if err != nil {
+			logger.Warn("Skipping invalid entry", "file", s.path, "line", line, "error", err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// writeFileAtomic replaces path with data by writing a temporary file in the
+// same directory and renaming it over path
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	// Removing fails harmlessly once the file has been renamed
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+
+	// Persist the rename itself; not every platform supports syncing directories
+	if dirFile, err := os.Open(dir); err == nil {
+		dirFile.Sync()
+		dirFile.Close()
+	}
+
+	return nil
+}
+
+// fileTransaction schedules a write of the file after a successful commit
+type fileTransaction struct {
+	Transaction
+	storage *FileStorage
+}
+
+// Commit applies the staged changes and schedules a write of the file
+func (tx *fileTransaction) Commit(ctx context.Context) error {
+	if err := tx.Transaction.Commit(ctx); err != nil {
+		return err
+	}
+	return tx.storage.changed()
+}
+
+// Ensure FileStorage implements Storage interface
+var _ Storage = (*FileStorage)(nil)
+var _ StorageWithStats = (*FileStorage)(nil)
@@ -0,0 +1,214 @@
+package storage_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// fileStorageChildEnv makes the test binary act as a writer that is killed
+// by TestFileStorage_SurvivesKill
+const fileStorageChildEnv = "DNSKA_FILE_STORAGE_CHILD_PATH"
+
+func TestFileStorage_Suite(t *testing.T) {
+	fileStorage, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "records.jsonl"), 0, &storage.ValidationConfig{
+		Enabled: true,
+	})
+	require.NoError(t, err)
+	defer fileStorage.Close()
+
+	suite := NewStorageTestSuite(t, fileStorage)
+	suite.RunAll()
+}
+
+func TestFileStorage_ReloadAfterClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.jsonl")
+	ctx := context.Background()
+
+	s, err := storage.NewFileStorage(path, time.Hour, nil)
+	require.NoError(t, err)
+
+	testRecords := storage.CreateTestRecords(t)
+	require.NoError(t, s.BatchPutRecords(ctx, testRecords))
+	require.NoError(t, s.PutRecord(ctx, records.NewNAPTRRecord("4.3.2.1.5.5.5.0.0.8.1.e164.arpa", 100, 10, "u", "E2U+sip",
+		`!^.*$!sip:"quoted" info@example.com!`, ".", 300)))
+	internal, _ := records.NewARecordFromString("www.example.com", "192.168.1.10", 300)
+	require.NoError(t, s.PutRecord(ctx, storage.NewViewRecord(internal, "lan")))
+	require.NoError(t, s.DeleteRecord(ctx, "alias.example.com", types.TYPE_CNAME))
+	require.NoError(t, s.Close())
+
+	reloaded, err := storage.NewFileStorage(path, time.Hour, nil)
+	require.NoError(t, err)
+	defer reloaded.Close()
+
+	all, err := reloaded.ListRecords(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, len(testRecords)+1)
+
+	lan, err := reloaded.QueryRecords(ctx, storage.QueryOptions{Name: "www.example.com", View: "lan"})
+	require.NoError(t, err)
+	require.Len(t, lan, 1)
+	assert.Equal(t, []byte{192, 168, 1, 10}, lan[0].Data())
+
+	naptr, err := reloaded.GetRecord(ctx, "4.3.2.1.5.5.5.0.0.8.1.e164.arpa", types.TYPE_NAPTR)
+	require.NoError(t, err)
+	assert.Equal(t, `!^.*$!sip:"quoted" info@example.com!`, naptr.(*records.NAPTRRecord).Regexp())
+
+	_, err = reloaded.GetRecord(ctx, "alias.example.com", types.TYPE_CNAME)
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+}
+
+func TestFileStorage_DebouncesWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.jsonl")
+	ctx := context.Background()
+
+	s, err := storage.NewFileStorage(path, time.Hour, nil)
+	require.NoError(t, err)
+	defer s.Close()
+
+	for i := 0; i < 20; i++ {
+		record, _ := records.NewARecordFromString(fmt.Sprintf("host%d.example.com", i), "192.0.2.1", 300)
+		require.NoError(t, s.BatchPutRecords(ctx, []records.DNSRecord{record}))
+	}
+
+	_, err = os.Stat(path)
+	assert.ErrorIs(t, err, os.ErrNotExist, "changes must wait for the flush interval")
+
+	require.NoError(t, s.Flush())
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 20, strings.Count(string(content), "\n"))
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files must not be left behind")
+}
+
+func TestFileStorage_FlushesAfterInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.jsonl")
+
+	s, err := storage.NewFileStorage(path, 10*time.Millisecond, nil)
+	require.NoError(t, err)
+	defer s.Close()
+
+	record, _ := records.NewARecordFromString("www.example.com", "192.0.2.1", 300)
+	require.NoError(t, s.PutRecord(context.Background(), record))
+
+	assert.Eventually(t, func() bool {
+		content, err := os.ReadFile(path)
+		return err == nil && strings.Contains(string(content), "www.example.com.")
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestFileStorage_SkipsCorruptEntries(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "records.jsonl")
+
+	content := strings.Join([]string{
+		`{"name":"www.example.com.","record_type":1,"class":1,"ttl":300,"data":"192.0.2.1"}`,
+		`{"name":"broken.example.com.","record_type":1,`,
+		`{"name":"bad-ip.example.com.","record_type":1,"class":1,"ttl":300,"data":"999.0.2.1"}`,
+		`{"name":"invalid..example.com.","record_type":1,"class":1,"ttl":300,"data":"192.0.2.2"}`,
+		``,
+		`{"name":"mail.example.com.","record_type":15,"class":1,"ttl":300,"data":"10 mx.example.com."}`,
+	}, "\n")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	// A temporary file left by an interrupted write is ignored
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "records.jsonl.123.tmp"), []byte("garbage"), 0o600))
+
+	s, err := storage.NewFileStorage(path, 0, &storage.ValidationConfig{Enabled: true})
+	require.NoError(t, err)
+	defer s.Close()
+
+	all, err := s.ListRecords(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	_, err = s.GetRecord(context.Background(), "mail.example.com", types.TYPE_MX)
+	assert.NoError(t, err)
+}
+
+func TestFileStorage_SurvivesKill(t *testing.T) {
+	if path := os.Getenv(fileStorageChildEnv); path != "" {
+		runFileStorageWriter(path)
+		return
+	}
+
+	path := filepath.Join(t.TempDir(), "records.jsonl")
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFileStorage_SurvivesKill$")
+	cmd.Env = append(os.Environ(), fileStorageChildEnv+"="+path)
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+
+	// The writer reports every batch once Flush has returned
+	committed := 0
+	scanner := bufio.NewScanner(stdout)
+	for committed < 30 && scanner.Scan() {
+		batch, err := strconv.Atoi(scanner.Text())
+		if err != nil {
+			continue
+		}
+		committed = batch + 1
+	}
+
+	require.NoError(t, cmd.Process.Kill())
+	cmd.Wait()
+	require.Equal(t, 30, committed, "writer stopped early")
+
+	s, err := storage.NewFileStorage(path, 0, nil)
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx := context.Background()
+	for batch := 0; batch < committed; batch++ {
+		for i := 0; i < 3; i++ {
+			_, err := s.GetRecord(ctx, fmt.Sprintf("host%d-%d.example.com", batch, i), types.TYPE_A)
+			assert.NoError(t, err, "committed record of batch %d lost", batch)
+		}
+	}
+}
+
+// runFileStorageWriter writes batches of records until the process is killed,
+// printing the number of each batch after it has been flushed
+func runFileStorageWriter(path string) {
+	s, err := storage.NewFileStorage(path, storage.DefaultFlushInterval, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	for batch := 0; ; batch++ {
+		var batchRecords []records.DNSRecord
+		for i := 0; i < 3; i++ {
+			record, _ := records.NewARecordFromString(fmt.Sprintf("host%d-%d.example.com", batch, i), "192.0.2.1", 300)
+			batchRecords = append(batchRecords, record)
+		}
+
+		if err := s.BatchPutRecords(ctx, batchRecords); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := s.Flush(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(batch)
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/vadim-su/dnska/pkg/dns/records"
 	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
 )
 
 // MemoryStorage implements the Storage interface using in-memory storage
@@ -19,8 +20,18 @@ type MemoryStorage struct {
 	zones     map[string]bool                                  // set of zones
 	validator *Validator
 	converter *RecordConverter
+	notifier  *changeNotifier
 	closed    bool
 	stats     StorageStats
+
+	snapshotMu sync.Mutex // Guards snapshots
+	snapshots  map[string]memorySnapshot
+}
+
+// memorySnapshot is a zone snapshot kept by MemoryStorage
+type memorySnapshot struct {
+	info SnapshotInfo
+	blob []byte
 }
 
 // NewMemoryStorage creates a new in-memory storage instance with validation
@@ -30,19 +41,27 @@ func NewMemoryStorage(validationConfig *ValidationConfig) (*MemoryStorage, error
 		zones:     make(map[string]bool),
 		validator: NewValidator(validationConfig),
 		converter: NewRecordConverter(),
+		notifier:  newChangeNotifier(),
+		snapshots: make(map[string]memorySnapshot),
 	}, nil
 }
 
-// GetRecords returns all records for a given domain name and record type
-// normalizeDomainName ensures the domain name has a trailing dot
+// normalizeDomainName returns the canonical form of a domain name records
+// are keyed by: lowercased with a trailing dot. Names that do not parse are
+// lowercased as they are, validation rejects them before they are stored
 func normalizeDomainName(name string) string {
-	name = strings.ToLower(name)
-	if name != "" && name[len(name)-1] != '.' {
-		name = name + "."
+	domainName, err := utils.ParseDomainName(name)
+	if err != nil {
+		name = strings.ToLower(name)
+		if name != "" && name[len(name)-1] != '.' {
+			name = name + "."
+		}
+		return name
 	}
-	return name
+	return domainName.CanonicalString()
 }
 
+// GetRecords returns all records for a given domain name and record type
 func (s *MemoryStorage) GetRecords(ctx context.Context, name string, recordType types.DNSType) ([]records.DNSRecord, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -50,6 +69,9 @@ func (s *MemoryStorage) GetRecords(ctx context.Context, name string, recordType
 	if s.closed {
 		return nil, ErrStorageClosed
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	// Validate input
 	if err := s.validator.ValidateName(name); err != nil {
@@ -83,18 +105,58 @@ func (s *MemoryStorage) GetRecords(ctx context.Context, name string, recordType
 	return result, nil
 }
 
-// GetRecord returns a single record for a given domain name and record type
+// scanCheckInterval is the number of names a scan visits between checks
+// for cancellation
+const scanCheckInterval = 1024
+
+// scanCanceled counts a name visited by a scan and, once every
+// scanCheckInterval names, returns the error of ctx if it is done
+func scanCanceled(ctx context.Context, scanned *int) error {
+	*scanned++
+	if *scanned%scanCheckInterval != 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return nil
+	}
+}
+
+// GetRecord returns a single record for a given domain name and record type,
+// looking it up by name and then by type without copying the RRset
 func (s *MemoryStorage) GetRecord(ctx context.Context, name string, recordType types.DNSType) (records.DNSRecord, error) {
-	records, err := s.GetRecords(ctx, name, recordType)
-	if err != nil {
+	if recordType == 0 {
+		recordList, err := s.GetRecords(ctx, name, recordType)
+		if err != nil {
+			return nil, err
+		}
+		if len(recordList) == 0 {
+			return nil, ErrRecordNotFound
+		}
+		return recordList[0], nil
+	}
+
+	if err := s.validator.ValidateName(name); err != nil {
 		return nil, err
 	}
 
-	if len(records) == 0 {
-		return nil, ErrRecordNotFound
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, ErrStorageClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	return records[0], nil
+	typeRecords := s.records[normalizeDomainName(name)][recordType]
+	if len(typeRecords) == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return typeRecords[0], nil
 }
 
 // PutRecord stores or updates a DNS record with validation
@@ -111,36 +173,9 @@ func (s *MemoryStorage) PutRecord(ctx context.Context, record records.DNSRecord)
 		return ErrStorageClosed
 	}
 
-	name := strings.ToLower(record.Name())
-
-	// Initialize maps if they don't exist
-	if s.records[name] == nil {
-		s.records[name] = make(map[types.DNSType][]records.DNSRecord)
-	}
-
-	recordType := record.Type()
-	typeRecords := s.records[name][recordType]
-
-	// Check if record already exists (update vs insert)
-	updated := false
-	for i, existingRecord := range typeRecords {
-		if s.recordsMatch(existingRecord, record) {
-			// Update existing record
-			typeRecords[i] = record
-			updated = true
-			break
-		}
-	}
-
-	if !updated {
-		// Add new record
-		s.records[name][recordType] = append(typeRecords, record)
-		s.stats.TotalRecords++
-	}
-
-	// Update zones
-	s.updateZones(name)
+	s.putRecordLocked(record)
 	s.stats.LastUpdated = time.Now().Unix()
+	s.notifier.publish(s.changeEvent(ChangeOperationPut, record.Name(), record.Type()))
 
 	return nil
 }
@@ -159,40 +194,12 @@ func (s *MemoryStorage) DeleteRecord(ctx context.Context, name string, recordTyp
 		return ErrStorageClosed
 	}
 
-	name = normalizeDomainName(name)
-
-	nameRecords, exists := s.records[name]
-	if !exists {
-		return ErrRecordNotFound
-	}
-
-	if recordType == 0 {
-		// Delete all records for this name
-		for _, typeRecords := range nameRecords {
-			s.stats.TotalRecords -= len(typeRecords)
-		}
-		delete(s.records, name)
-		s.updateZonesOnDelete(name)
-		s.stats.LastUpdated = time.Now().Unix()
-		return nil
-	}
-
-	typeRecords, exists := nameRecords[recordType]
-	if !exists || len(typeRecords) == 0 {
+	if !s.deleteRecordLocked(normalizeDomainName(name), recordType) {
 		return ErrRecordNotFound
 	}
 
-	// Remove all records of this type
-	s.stats.TotalRecords -= len(typeRecords)
-	delete(nameRecords, recordType)
-
-	// If no records left for this name, remove the name entry
-	if len(nameRecords) == 0 {
-		delete(s.records, name)
-		s.updateZonesOnDelete(name)
-	}
-
 	s.stats.LastUpdated = time.Now().Unix()
+	s.notifier.publish(s.changeEvent(ChangeOperationDelete, name, recordType))
 	return nil
 }
 
@@ -204,9 +211,16 @@ func (s *MemoryStorage) ListRecords(ctx context.Context) ([]records.DNSRecord, e
 	if s.closed {
 		return nil, ErrStorageClosed
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	allRecords := make([]records.DNSRecord, 0)
+	scanned := 0
 	for _, nameRecords := range s.records {
+		if err := scanCanceled(ctx, &scanned); err != nil {
+			return nil, err
+		}
 		for _, typeRecords := range nameRecords {
 			allRecords = append(allRecords, typeRecords...)
 		}
@@ -229,10 +243,18 @@ func (s *MemoryStorage) ListRecordsByZone(ctx context.Context, zone string) ([]r
 		return nil, ErrStorageClosed
 	}
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	zone = normalizeDomainName(zone)
 	var zoneRecords []records.DNSRecord
 
+	scanned := 0
 	for name, nameRecords := range s.records {
+		if err := scanCanceled(ctx, &scanned); err != nil {
+			return nil, err
+		}
 		if s.isInZone(name, zone) {
 			for _, typeRecords := range nameRecords {
 				zoneRecords = append(zoneRecords, typeRecords...)
@@ -269,6 +291,9 @@ func (s *MemoryStorage) QueryRecords(ctx context.Context, options QueryOptions)
 	if s.closed {
 		return nil, ErrStorageClosed
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	// Collect matching records
 	var results []records.DNSRecord
@@ -287,11 +312,19 @@ func (s *MemoryStorage) QueryRecords(ctx context.Context, options QueryOptions)
 		queryZone = normalizeDomainName(queryZone)
 	}
 
-	for name, nameRecords := range s.records {
-		// Apply name filters
-		if queryName != "" && !strings.EqualFold(name, queryName) {
-			continue
+	// An exact name needs a single lookup instead of a scan
+	candidates := s.records
+	if queryName != "" {
+		candidates = map[string]map[types.DNSType][]records.DNSRecord{queryName: s.records[queryName]}
+	}
+
+	scanned := 0
+	for name, nameRecords := range candidates {
+		if err := scanCanceled(ctx, &scanned); err != nil {
+			return nil, err
 		}
+
+		// Apply name filters
 		if queryPrefix != "" && !strings.HasPrefix(strings.ToLower(name), strings.ToLower(queryPrefix)) {
 			continue
 		}
@@ -304,12 +337,16 @@ func (s *MemoryStorage) QueryRecords(ctx context.Context, options QueryOptions)
 			if options.RecordType != 0 && recordType != options.RecordType {
 				continue
 			}
-			results = append(results, typeRecords...)
+			for _, record := range typeRecords {
+				if inView(record, options.View) {
+					results = append(results, record)
+				}
+			}
 		}
 	}
 
 	// Sort results
-	s.sortRecords(results, options.SortBy, options.SortOrder)
+	sortRecords(results, options.SortBy, options.SortOrder)
 
 	// Apply pagination
 	if options.Offset > 0 {
@@ -326,40 +363,26 @@ func (s *MemoryStorage) QueryRecords(ctx context.Context, options QueryOptions)
 	return results, nil
 }
 
-// BatchPutRecords stores multiple records in a single operation
+// BatchPutRecords stores multiple records in a single transaction. A record
+// failing validation rolls back the whole batch
 func (s *MemoryStorage) BatchPutRecords(ctx context.Context, recordList []records.DNSRecord) error {
 	if len(recordList) == 0 {
 		return nil
 	}
 
-	// Validate all records first
-	if errs := s.validator.ValidateBatch(recordList); len(errs) > 0 {
-		return fmt.Errorf("validation failed: %v", errs[0])
-	}
-
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if s.closed {
-		return ErrStorageClosed
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		return err
 	}
 
-	// Store all records
 	for _, record := range recordList {
-		name := strings.ToLower(record.Name())
-
-		if s.records[name] == nil {
-			s.records[name] = make(map[types.DNSType][]records.DNSRecord)
+		if err := tx.PutRecord(ctx, record); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("validation failed: %w", err)
 		}
-
-		recordType := record.Type()
-		s.records[name][recordType] = append(s.records[name][recordType], record)
-		s.updateZones(name)
-		s.stats.TotalRecords++
 	}
 
-	s.stats.LastUpdated = time.Now().Unix()
-	return nil
+	return tx.Commit(ctx)
 }
 
 // BatchDeleteRecords deletes multiple records in a single operation
@@ -376,6 +399,7 @@ func (s *MemoryStorage) BatchDeleteRecords(ctx context.Context, names []string,
 	}
 
 	deletedCount := 0
+	var events []ChangeEvent
 	for _, name := range names {
 		name = normalizeDomainName(name)
 
@@ -387,9 +411,11 @@ func (s *MemoryStorage) BatchDeleteRecords(ctx context.Context, names []string,
 				}
 				delete(s.records, name)
 				s.updateZonesOnDelete(name)
+				events = append(events, s.changeEvent(ChangeOperationDelete, name, recordType))
 			} else if typeRecords, exists := nameRecords[recordType]; exists {
 				deletedCount += len(typeRecords)
 				delete(nameRecords, recordType)
+				events = append(events, s.changeEvent(ChangeOperationDelete, name, recordType))
 
 				if len(nameRecords) == 0 {
 					delete(s.records, name)
@@ -403,10 +429,77 @@ func (s *MemoryStorage) BatchDeleteRecords(ctx context.Context, names []string,
 		s.stats.TotalRecords -= deletedCount
 		s.stats.LastUpdated = time.Now().Unix()
 	}
+	s.notifier.publish(events...)
+
+	return nil
+}
+
+// ReplaceRRSet atomically replaces all records of the given name and type
+func (s *MemoryStorage) ReplaceRRSet(ctx context.Context, name string, recordType types.DNSType, recordList []records.DNSRecord) error {
+	// Validate the whole set before touching any state
+	if err := s.validator.ValidateRRSet(name, recordType, recordList); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrStorageClosed
+	}
+
+	name = normalizeDomainName(name)
+	existed := len(s.records[name][recordType]) > 0
+
+	s.replaceRRSetLocked(name, recordType, recordList)
+	s.stats.LastUpdated = time.Now().Unix()
+
+	if len(recordList) > 0 {
+		s.notifier.publish(s.changeEvent(ChangeOperationPut, name, recordType))
+	} else if existed {
+		s.notifier.publish(s.changeEvent(ChangeOperationDelete, name, recordType))
+	}
 
 	return nil
 }
 
+// Begin starts a transaction that stages changes until Commit
+func (s *MemoryStorage) Begin(ctx context.Context) (Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, ErrStorageClosed
+	}
+
+	return &memoryTransaction{storage: s}, nil
+}
+
+// Subscribe returns a channel receiving an event after every successful
+// change. Events are published while the change still holds the write lock,
+// so they arrive in the order the changes were applied
+func (s *MemoryStorage) Subscribe(ctx context.Context) (<-chan ChangeEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, ErrStorageClosed
+	}
+
+	return s.notifier.subscribe(ctx)
+}
+
+// Ping reports ErrStorageClosed once the storage has been closed
+func (s *MemoryStorage) Ping(ctx context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return ErrStorageClosed
+	}
+	return nil
+}
+
 // Close closes the storage connection and cleans up resources
 func (s *MemoryStorage) Close() error {
 	s.mu.Lock()
@@ -419,6 +512,7 @@ func (s *MemoryStorage) Close() error {
 	s.records = make(map[string]map[types.DNSType][]records.DNSRecord)
 	s.zones = make(map[string]bool)
 	s.closed = true
+	s.notifier.close()
 
 	return nil
 }
@@ -434,6 +528,7 @@ func (s *MemoryStorage) GetStats(ctx context.Context) (*StorageStats, error) {
 
 	stats := s.stats
 	stats.TotalZones = len(s.zones)
+	stats.DroppedEvents = s.notifier.droppedEvents()
 
 	// Count records by type
 	stats.RecordTypes = make(map[string]int)
@@ -446,13 +541,105 @@ func (s *MemoryStorage) GetStats(ctx context.Context) (*StorageStats, error) {
 	return &stats, nil
 }
 
+// SnapshotZone saves the records of zone in memory
+func (s *MemoryStorage) SnapshotZone(ctx context.Context, zone string) (string, error) {
+	recordList, err := s.ListRecordsByZone(ctx, zone)
+	if err != nil {
+		return "", err
+	}
+	if len(recordList) == 0 {
+		return "", ErrRecordNotFound
+	}
+
+	blob, err := encodeSnapshot(s.converter, recordList)
+	if err != nil {
+		return "", err
+	}
+	id, err := newSnapshotID()
+	if err != nil {
+		return "", err
+	}
+
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+	s.snapshots[id] = memorySnapshot{
+		info: SnapshotInfo{ID: id, Zone: normalizeDomainName(zone), Records: len(recordList), CreatedAt: time.Now()},
+		blob: blob,
+	}
+	return id, nil
+}
+
+// RestoreSnapshot replaces the records of the zone of a snapshot with those
+// saved in it, in one transaction
+func (s *MemoryStorage) RestoreSnapshot(ctx context.Context, snapshotID string) error {
+	return s.restoreSnapshot(ctx, s, snapshotID)
+}
+
+// restoreSnapshot restores a snapshot through store, the storage embedding
+// s or s itself, so its transaction is the one used
+func (s *MemoryStorage) restoreSnapshot(ctx context.Context, store Storage, snapshotID string) error {
+	s.snapshotMu.Lock()
+	snapshot, ok := s.snapshots[snapshotID]
+	s.snapshotMu.Unlock()
+	if !ok {
+		return ErrSnapshotNotFound
+	}
+
+	recordList, err := decodeSnapshot(s.converter, snapshot.blob)
+	if err != nil {
+		return err
+	}
+	return restoreZone(ctx, store, snapshot.info.Zone, recordList)
+}
+
+// ListSnapshots returns the snapshots of zone, oldest first
+func (s *MemoryStorage) ListSnapshots(ctx context.Context, zone string) ([]SnapshotInfo, error) {
+	if err := s.validator.ValidateZone(zone); err != nil {
+		return nil, err
+	}
+	if err := s.Ping(ctx); err != nil {
+		return nil, err
+	}
+
+	zone = normalizeDomainName(zone)
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+
+	snapshots := []SnapshotInfo{}
+	for _, snapshot := range s.snapshots {
+		if snapshot.info.Zone == zone {
+			snapshots = append(snapshots, snapshot.info)
+		}
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// DeleteSnapshot removes a snapshot
+func (s *MemoryStorage) DeleteSnapshot(ctx context.Context, snapshotID string) error {
+	if err := s.Ping(ctx); err != nil {
+		return err
+	}
+
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+
+	if _, ok := s.snapshots[snapshotID]; !ok {
+		return ErrSnapshotNotFound
+	}
+	delete(s.snapshots, snapshotID)
+	return nil
+}
+
 // Helper methods
 
 // recordsMatch checks if two records match for update purposes
 func (s *MemoryStorage) recordsMatch(r1, r2 records.DNSRecord) bool {
-	// Match by name, type, and data content
+	// Match by name, type, view and data content
 	// This allows multiple records of the same type with different data
-	if !strings.EqualFold(r1.Name(), r2.Name()) || r1.Type() != r2.Type() {
+	if normalizeDomainName(r1.Name()) != normalizeDomainName(r2.Name()) || r1.Type() != r2.Type() || RecordView(r1) != RecordView(r2) {
 		return false
 	}
 
@@ -473,6 +660,117 @@ func (s *MemoryStorage) recordsMatch(r1, r2 records.DNSRecord) bool {
 	return true
 }
 
+// putRecordLocked stores a record, replacing an existing record with the same
+// data. The caller must hold the write lock
+func (s *MemoryStorage) putRecordLocked(record records.DNSRecord) {
+	name := normalizeDomainName(record.Name())
+
+	// Initialize maps if they don't exist
+	if s.records[name] == nil {
+		s.records[name] = make(map[types.DNSType][]records.DNSRecord)
+	}
+
+	recordType := record.Type()
+	typeRecords := s.records[name][recordType]
+
+	// Check if record already exists (update vs insert)
+	for i, existingRecord := range typeRecords {
+		if s.recordsMatch(existingRecord, record) {
+			typeRecords[i] = record
+			return
+		}
+	}
+
+	// Add new record
+	s.records[name][recordType] = append(typeRecords, record)
+	s.stats.TotalRecords++
+	s.updateZones(name)
+}
+
+// deleteRecordLocked removes the records of name with the given type, or all
+// of them when recordType is 0. It reports whether anything was removed.
+// The caller must hold the write lock and pass a normalized name
+func (s *MemoryStorage) deleteRecordLocked(name string, recordType types.DNSType) bool {
+	nameRecords, exists := s.records[name]
+	if !exists {
+		return false
+	}
+
+	if recordType == 0 {
+		// Delete all records for this name
+		for _, typeRecords := range nameRecords {
+			s.stats.TotalRecords -= len(typeRecords)
+		}
+		delete(s.records, name)
+		s.updateZonesOnDelete(name)
+		return true
+	}
+
+	typeRecords, exists := nameRecords[recordType]
+	if !exists || len(typeRecords) == 0 {
+		return false
+	}
+
+	// Remove all records of this type
+	s.stats.TotalRecords -= len(typeRecords)
+	delete(nameRecords, recordType)
+
+	// If no records left for this name, remove the name entry
+	if len(nameRecords) == 0 {
+		delete(s.records, name)
+		s.updateZonesOnDelete(name)
+	}
+
+	return true
+}
+
+// replaceRRSetLocked swaps the RRset for name and type with the given records.
+// The caller must hold the write lock and pass a normalized name
+func (s *MemoryStorage) replaceRRSetLocked(name string, recordType types.DNSType, recordList []records.DNSRecord) {
+	nameRecords := s.records[name]
+	if nameRecords != nil {
+		s.stats.TotalRecords -= len(nameRecords[recordType])
+		delete(nameRecords, recordType)
+	}
+
+	newSet := make([]records.DNSRecord, 0, len(recordList))
+	for _, record := range recordList {
+		duplicate := false
+		for _, existing := range newSet {
+			if s.recordsMatch(existing, record) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			newSet = append(newSet, record)
+		}
+	}
+
+	if len(newSet) > 0 {
+		if nameRecords == nil {
+			nameRecords = make(map[types.DNSType][]records.DNSRecord)
+			s.records[name] = nameRecords
+		}
+		nameRecords[recordType] = newSet
+		s.stats.TotalRecords += len(newSet)
+		s.updateZones(name)
+		return
+	}
+
+	// Empty replacement set: drop the name entirely if nothing else is left
+	if nameRecords != nil && len(nameRecords) == 0 {
+		delete(s.records, name)
+		s.updateZonesOnDelete(name)
+	}
+}
+
+// changeEvent creates an event for a change to the records of name
+func (s *MemoryStorage) changeEvent(operation ChangeOperation, name string, recordType types.DNSType) ChangeEvent {
+	name = normalizeDomainName(name)
+	return newChangeEvent(operation, name, recordType, s.converter.extractZone(name))
+}
+
 // isInZone checks if a name belongs to a zone
 func (s *MemoryStorage) isInZone(name, zone string) bool {
 	name = strings.ToLower(strings.TrimSuffix(name, "."))
@@ -501,8 +799,8 @@ func (s *MemoryStorage) updateZonesOnDelete(deletedName string) {
 }
 
 // sortRecords sorts records based on the specified field and order
-func (s *MemoryStorage) sortRecords(records []records.DNSRecord, sortBy, sortOrder string) {
-	sort.Slice(records, func(i, j int) bool {
+func sortRecords(records []records.DNSRecord, sortBy, sortOrder string) {
+	sort.SliceStable(records, func(i, j int) bool {
 		var less bool
 
 		switch sortBy {
@@ -540,6 +838,99 @@ func (s *MemoryStorage) String() string {
 	return sb.String()
 }
 
+// memoryTransaction stages changes for a MemoryStorage and applies them under
+// a single write lock on Commit. It is not safe for concurrent use
+type memoryTransaction struct {
+	storage    *MemoryStorage
+	operations []memoryOperation
+	done       bool
+}
+
+// memoryOperation is a staged put (record set) or delete (record nil)
+type memoryOperation struct {
+	record     records.DNSRecord
+	name       string
+	recordType types.DNSType
+}
+
+// PutRecord validates and stages a record
+func (tx *memoryTransaction) PutRecord(ctx context.Context, record records.DNSRecord) error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+
+	if err := tx.storage.validator.ValidateRecord(record); err != nil {
+		return err
+	}
+
+	tx.operations = append(tx.operations, memoryOperation{record: record})
+	return nil
+}
+
+// DeleteRecord stages removing records. Unlike MemoryStorage.DeleteRecord,
+// deleting records that do not exist at commit time is not an error, so a
+// commit never stops halfway
+func (tx *memoryTransaction) DeleteRecord(ctx context.Context, name string, recordType types.DNSType) error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+
+	if err := tx.storage.validator.ValidateName(name); err != nil {
+		return err
+	}
+
+	tx.operations = append(tx.operations, memoryOperation{
+		name:       normalizeDomainName(name),
+		recordType: recordType,
+	})
+	return nil
+}
+
+// Commit applies all staged changes while holding the write lock
+func (tx *memoryTransaction) Commit(ctx context.Context) error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+	tx.done = true
+
+	s := tx.storage
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrStorageClosed
+	}
+
+	events := make([]ChangeEvent, 0, len(tx.operations))
+	for _, operation := range tx.operations {
+		if operation.record != nil {
+			s.putRecordLocked(operation.record)
+			events = append(events, s.changeEvent(ChangeOperationPut, operation.record.Name(), operation.record.Type()))
+		} else if s.deleteRecordLocked(operation.name, operation.recordType) {
+			events = append(events, s.changeEvent(ChangeOperationDelete, operation.name, operation.recordType))
+		}
+	}
+
+	if len(tx.operations) > 0 {
+		s.stats.LastUpdated = time.Now().Unix()
+	}
+	tx.operations = nil
+	s.notifier.publish(events...)
+
+	return nil
+}
+
+// Rollback discards all staged changes
+func (tx *memoryTransaction) Rollback() error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+	tx.done = true
+	tx.operations = nil
+
+	return nil
+}
+
 // Ensure MemoryStorage implements Storage interface
 var _ Storage = (*MemoryStorage)(nil)
 var _ StorageWithStats = (*MemoryStorage)(nil)
@@ -2,8 +2,12 @@ package storage_test
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -73,6 +77,103 @@ func TestMemoryStorage_Initialization(t *testing.T) {
 	})
 }
 
+func TestMemoryStorage_RejectsMalformedAddresses(t *testing.T) {
+	s, err := storage.NewMemoryStorage(&storage.ValidationConfig{Enabled: true})
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx := context.Background()
+
+	// IPv6 address in an A record leaves no IPv4 RDATA
+	err = s.PutRecord(ctx, records.NewARecord("v4.example.com", net.ParseIP("2001:db8::1"), 300))
+	assert.Error(t, err, "Should reject A record without an IPv4 address")
+
+	err = s.PutRecord(ctx, records.NewAAAARecord("v6.example.com", net.IP{10, 0, 0}, 300))
+	assert.Error(t, err, "Should reject AAAA record with a truncated address")
+
+	err = s.PutRecord(ctx, &testRecord{
+		name:       "raw.example.com",
+		recordType: types.TYPE_A,
+		ttl:        300,
+		data:       []byte{192, 168, 1},
+	})
+	assert.Error(t, err, "Should reject A RDATA that is not 4 bytes")
+
+	err = s.PutRecord(ctx, records.NewARecord("v4.example.com", net.ParseIP("192.0.2.1"), 300))
+	assert.NoError(t, err)
+
+	err = s.PutRecord(ctx, records.NewAAAARecord("v6.example.com", net.ParseIP("2001:db8::1"), 300))
+	assert.NoError(t, err)
+}
+
+func TestMemoryStorage_ValidatesCAATags(t *testing.T) {
+	s, err := storage.NewMemoryStorage(&storage.ValidationConfig{Enabled: true})
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx := context.Background()
+
+	for _, tag := range []string{"issue", "issuewild", "iodef", "ISSUE", "contactemail"} {
+		err = s.PutRecord(ctx, records.NewCAARecord("example.com", tag, "ca.example.net", 0, 300))
+		assert.NoError(t, err, "Should accept registered tag %q", tag)
+	}
+
+	for _, tag := range []string{"policy", "unknown", ""} {
+		err = s.PutRecord(ctx, records.NewCAARecord("example.com", tag, "ca.example.net", 0, 300))
+		assert.Error(t, err, "Should reject tag %q", tag)
+	}
+}
+
+func TestMemoryStorage_UnknownTypes(t *testing.T) {
+	ctx := context.Background()
+	record := records.NewGenericRecord("private.example.com", types.DNSType(65280), []byte{0x0a, 0x00, 0x00, 0x01}, 300)
+
+	denying, err := storage.NewMemoryStorage(&storage.ValidationConfig{Enabled: true})
+	require.NoError(t, err)
+	defer denying.Close()
+	assert.ErrorIs(t, denying.PutRecord(ctx, record), storage.ErrInvalidRecord, "Should reject unknown types by default")
+
+	s, err := storage.NewMemoryStorage(&storage.ValidationConfig{Enabled: true, AllowUnknownTypes: true})
+	require.NoError(t, err)
+	defer s.Close()
+	require.NoError(t, s.PutRecord(ctx, record))
+
+	found, err := s.GetRecords(ctx, "private.example.com.", types.DNSType(65280))
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, record.Data(), found[0].Data())
+
+	for _, recordType := range []types.DNSType{0, types.TYPE_OPT, types.TYPE_AXFR, types.TYPE_ANY} {
+		err = s.PutRecord(ctx, records.NewGenericRecord("meta.example.com", recordType, nil, 300))
+		assert.ErrorIs(t, err, storage.ErrInvalidRecord, "Should reject meta type %s", recordType.Mnemonic())
+	}
+}
+
+func TestMemoryStorage_InternationalizedNames(t *testing.T) {
+	s, err := storage.NewMemoryStorage(&storage.ValidationConfig{Enabled: true})
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx := context.Background()
+
+	err = s.PutRecord(ctx, records.NewARecord("MÜNCHEN.example.com", net.ParseIP("192.0.2.1"), 300))
+	require.NoError(t, err)
+
+	// Lookups from the wire use A-labels, the admin side may use either form
+	for _, name := range []string{"xn--mnchen-3ya.example.com.", "münchen.example.com", "München.Example.com."} {
+		found, err := s.GetRecords(ctx, name, types.TYPE_A)
+		require.NoError(t, err)
+		assert.Len(t, found, 1, "Should find the record under %s", name)
+	}
+
+	// The A-label of 57 Unicode characters is 64 bytes long
+	err = s.PutRecord(ctx, records.NewARecord(strings.Repeat("a", 56)+"ü.example.com", net.ParseIP("192.0.2.2"), 300))
+	assert.ErrorIs(t, err, storage.ErrInvalidRecord, "Should reject a label whose A-label is too long")
+
+	err = s.PutRecord(ctx, records.NewARecord("עברית-abc.example.com", net.ParseIP("192.0.2.3"), 300))
+	assert.ErrorIs(t, err, storage.ErrInvalidRecord, "Should reject a label without an A-label")
+}
+
 func TestMemoryStorage_Concurrency(t *testing.T) {
 	s, err := storage.NewMemoryStorage(&storage.ValidationConfig{Enabled: true})
 	require.NoError(t, err)
@@ -142,6 +243,174 @@ func TestMemoryStorage_Concurrency(t *testing.T) {
 	assert.Len(t, allRecords, goroutines*recordsPerGoroutine/2)
 }
 
+func TestMemoryStorage_TransactionIsolation(t *testing.T) {
+	s, err := storage.NewMemoryStorage(nil)
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx := context.Background()
+	generations := [][]string{
+		{"192.0.2.1", "192.0.2.2", "192.0.2.3"},
+		{"198.51.100.1", "198.51.100.2", "198.51.100.3"},
+	}
+
+	// generationOf returns the index of the generation ip belongs to
+	generationOf := func(ip string) int {
+		for i, generation := range generations {
+			for _, candidate := range generation {
+				if candidate == ip {
+					return i
+				}
+			}
+		}
+		return -1
+	}
+
+	for _, ip := range generations[0] {
+		require.NoError(t, s.PutRecord(ctx, mustBuild(t, records.NewBuilder().Name("swap.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP(ip)))))
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	mixed := make(chan string, 1)
+
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				recs, err := s.GetRecords(ctx, "swap.example.com", types.TYPE_A)
+				if err != nil {
+					continue
+				}
+
+				seen := map[int]int{}
+				for _, rec := range recs {
+					seen[generationOf(rec.(*records.ARecord).IP().String())]++
+				}
+				if len(recs) != 3 || len(seen) != 1 {
+					select {
+					case mixed <- fmt.Sprintf("observed %d records from generations %v", len(recs), seen):
+					default:
+					}
+				}
+			}
+		}()
+	}
+
+	for i := range 200 {
+		tx, err := s.Begin(ctx)
+		require.NoError(t, err)
+		require.NoError(t, tx.DeleteRecord(ctx, "swap.example.com", types.TYPE_A))
+		for _, ip := range generations[(i+1)%2] {
+			require.NoError(t, tx.PutRecord(ctx, mustBuild(t, records.NewBuilder().Name("swap.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP(ip)))))
+		}
+		require.NoError(t, tx.Commit(ctx))
+	}
+
+	close(stop)
+	wg.Wait()
+
+	select {
+	case state := <-mixed:
+		t.Fatalf("reader saw a partially applied transaction: %s", state)
+	default:
+	}
+}
+
+func TestMemoryStorage_Subscribe(t *testing.T) {
+	s, err := storage.NewMemoryStorage(&storage.ValidationConfig{Enabled: true})
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx := context.Background()
+	first, err := s.Subscribe(ctx)
+	require.NoError(t, err)
+	second, err := s.Subscribe(ctx)
+	require.NoError(t, err)
+
+	const writers = 50
+	var wg sync.WaitGroup
+	wg.Add(writers)
+	for i := 0; i < writers; i++ {
+		go func(id int) {
+			defer wg.Done()
+			record, _ := records.NewARecordFromString(fmt.Sprintf("host%d.example.com", id), "192.0.2.1", 300)
+			assert.NoError(t, s.PutRecord(ctx, record))
+		}(i)
+	}
+	wg.Wait()
+
+	for _, events := range []<-chan storage.ChangeEvent{first, second} {
+		seen := make(map[string]bool)
+		for len(seen) < writers {
+			select {
+			case event := <-events:
+				assert.Equal(t, storage.ChangeOperationPut, event.Operation)
+				assert.Equal(t, types.TYPE_A, event.RecordType)
+				assert.Equal(t, "example.com", event.Zone)
+				assert.False(t, event.Timestamp.IsZero())
+				seen[event.Name] = true
+			case <-time.After(time.Second):
+				t.Fatalf("received %d of %d events", len(seen), writers)
+			}
+		}
+		assert.True(t, seen["host0.example.com."])
+	}
+
+	// A delete that removes nothing is not reported
+	require.NoError(t, s.DeleteRecord(ctx, "host0.example.com", types.TYPE_A))
+	assert.ErrorIs(t, s.DeleteRecord(ctx, "host0.example.com", types.TYPE_A), storage.ErrRecordNotFound)
+
+	event := <-first
+	assert.Equal(t, storage.ChangeOperationDelete, event.Operation)
+	assert.Equal(t, "host0.example.com.", event.Name)
+	assert.Empty(t, first)
+
+	stats, err := s.GetStats(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, stats.DroppedEvents)
+}
+
+func TestMemoryStorage_SubscribeCancel(t *testing.T) {
+	s, err := storage.NewMemoryStorage(nil)
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := s.Subscribe(ctx)
+	require.NoError(t, err)
+	kept, err := s.Subscribe(context.Background())
+	require.NoError(t, err)
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok, "expected the channel to be closed")
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed after cancelling the context")
+	}
+
+	// Other subscribers keep receiving events until the storage is closed
+	record, _ := records.NewARecordFromString("www.example.com", "192.0.2.1", 300)
+	require.NoError(t, s.PutRecord(context.Background(), record))
+	event := <-kept
+	assert.Equal(t, "www.example.com.", event.Name)
+
+	require.NoError(t, s.Close())
+	_, ok := <-kept
+	assert.False(t, ok, "expected Close to close the channel")
+
+	_, err = s.Subscribe(context.Background())
+	assert.ErrorIs(t, err, storage.ErrStorageClosed)
+}
+
 func TestMemoryStorage_Stats(t *testing.T) {
 	s, err := storage.NewMemoryStorage(&storage.ValidationConfig{Enabled: true})
 	require.NoError(t, err)
@@ -164,10 +433,10 @@ func TestMemoryStorage_Stats(t *testing.T) {
 
 	// Add some records
 	records := []records.DNSRecord{
-		mustCreateARecord("host1.zone1.com", "192.168.1.1", 300),
-		mustCreateARecord("host2.zone1.com", "192.168.1.2", 300),
-		mustCreateAAAARecord("host1.zone1.com", "2001:db8::1", 300),
-		mustCreateARecord("host1.zone2.com", "192.168.2.1", 300),
+		mustBuild(t, records.NewBuilder().Name("host1.zone1.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1"))),
+		mustBuild(t, records.NewBuilder().Name("host2.zone1.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.2"))),
+		mustBuild(t, records.NewBuilder().Name("host1.zone1.com").Type(types.TYPE_AAAA).TTL(300).IPv6(net.ParseIP("2001:db8::1"))),
+		mustBuild(t, records.NewBuilder().Name("host1.zone2.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.2.1"))),
 		records.NewCNAMERecord("www.zone1.com", "zone1.com", 300),
 	}
 
@@ -232,11 +501,11 @@ func TestMemoryStorage_ZoneManagement(t *testing.T) {
 
 	// Add records to build zone hierarchy
 	testRecords := []records.DNSRecord{
-		mustCreateARecord("example.com", "192.168.1.1", 300),
-		mustCreateARecord("www.example.com", "192.168.1.2", 300),
-		mustCreateARecord("mail.example.com", "192.168.1.3", 300),
-		mustCreateARecord("subdomain.mail.example.com", "192.168.1.4", 300),
-		mustCreateARecord("test.org", "192.168.2.1", 300),
+		mustBuild(t, records.NewBuilder().Name("example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1"))),
+		mustBuild(t, records.NewBuilder().Name("www.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.2"))),
+		mustBuild(t, records.NewBuilder().Name("mail.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.3"))),
+		mustBuild(t, records.NewBuilder().Name("subdomain.mail.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.4"))),
+		mustBuild(t, records.NewBuilder().Name("test.org").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.2.1"))),
 	}
 
 	for _, r := range testRecords {
@@ -274,11 +543,11 @@ func TestMemoryStorage_QueryOptions(t *testing.T) {
 
 	// Setup test data with varied TTLs
 	testRecords := []records.DNSRecord{
-		mustCreateARecord("alpha.example.com", "192.168.1.1", 100),
-		mustCreateARecord("beta.example.com", "192.168.1.2", 200),
-		mustCreateARecord("gamma.example.com", "192.168.1.3", 300),
-		mustCreateAAAARecord("alpha.example.com", "2001:db8::1", 400),
-		mustCreateAAAARecord("beta.example.com", "2001:db8::2", 500),
+		mustBuild(t, records.NewBuilder().Name("alpha.example.com").Type(types.TYPE_A).TTL(100).IPv4(net.ParseIP("192.168.1.1"))),
+		mustBuild(t, records.NewBuilder().Name("beta.example.com").Type(types.TYPE_A).TTL(200).IPv4(net.ParseIP("192.168.1.2"))),
+		mustBuild(t, records.NewBuilder().Name("gamma.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.3"))),
+		mustBuild(t, records.NewBuilder().Name("alpha.example.com").Type(types.TYPE_AAAA).TTL(400).IPv6(net.ParseIP("2001:db8::1"))),
+		mustBuild(t, records.NewBuilder().Name("beta.example.com").Type(types.TYPE_AAAA).TTL(500).IPv6(net.ParseIP("2001:db8::2"))),
 	}
 
 	for _, r := range testRecords {
@@ -379,8 +648,8 @@ func TestMemoryStorage_String(t *testing.T) {
 
 	// Add some records
 	records := []records.DNSRecord{
-		mustCreateARecord("test.example.com", "192.168.1.1", 300),
-		mustCreateAAAARecord("test.example.com", "2001:db8::1", 300),
+		mustBuild(t, records.NewBuilder().Name("test.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1"))),
+		mustBuild(t, records.NewBuilder().Name("test.example.com").Type(types.TYPE_AAAA).TTL(300).IPv6(net.ParseIP("2001:db8::1"))),
 		records.NewCNAMERecord("www.example.com", "example.com", 300),
 	}
 
@@ -397,6 +666,115 @@ func TestMemoryStorage_String(t *testing.T) {
 	assert.Contains(t, str, "www.example.com")
 }
 
+// typedRecords returns one record of every type the storage holds besides
+// CNAME, all owned by name
+func typedRecords(tb testing.TB, name string) []records.DNSRecord {
+	tb.Helper()
+
+	ds, err := records.NewDSRecord(name, 1, 8, 2, make([]byte, 32), 300)
+	require.NoError(tb, err)
+	key, err := records.NewDNSKEYRecord(name, 257, 3, 8, []byte{1, 2, 3, 4}, 300)
+	require.NoError(tb, err)
+	tlsa, err := records.NewTLSARecord(name, 3, 1, 1, make([]byte, 32), 300)
+	require.NoError(tb, err)
+	now := time.Unix(1700000000, 0)
+	rrsig, err := records.NewRRSIGRecord(name, types.TYPE_A, 8, 3, 300, now.Add(time.Hour), now, 1, "example.com.", []byte{1, 2}, 300)
+	require.NoError(tb, err)
+
+	return []records.DNSRecord{
+		records.NewARecord(name, net.ParseIP("192.0.2.1"), 300),
+		records.NewAAAARecord(name, net.ParseIP("2001:db8::1"), 300),
+		records.NewMXRecord(name, "mail.example.com.", 10, 300),
+		records.NewTXTRecordFromString(name, "text", 300),
+		records.NewNSRecord(name, "ns1.example.com.", 300),
+		records.NewPTRRecord(name, "ptr.example.com.", 300),
+		records.NewSRVRecord(name, "srv.example.com.", 1, 1, 80, 300),
+		records.NewCAARecord(name, "issue", "ca.example.net", 0, 300),
+		records.NewNAPTRRecord(name, 1, 1, "U", "E2U+sip", "", "sip.example.com.", 300),
+		records.NewSVCBRecord(name, "svc.example.com.", 1, nil, 300),
+		records.NewHTTPSRecord(name, "web.example.com.", 1, nil, 300),
+		records.NewNSECRecord(name, "next.example.com.", []types.DNSType{types.TYPE_A}, 300),
+		records.NewSOARecord(name, "ns1.example.com.", "hostmaster.example.com.", 1, time.Hour, time.Hour, time.Hour, time.Hour, 300),
+		records.NewDNAMERecord(name, "other.example.net.", 300),
+		ds, key, tlsa, rrsig,
+	}
+}
+
+func TestMemoryStorage_TypeIndex(t *testing.T) {
+	s, err := storage.NewMemoryStorage(nil)
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx := context.Background()
+	name := "host.example.com."
+	typed := typedRecords(t, name)
+	require.NoError(t, s.BatchPutRecords(ctx, typed))
+
+	for _, record := range typed {
+		found, err := s.GetRecord(ctx, "HOST.example.com", record.Type())
+		require.NoError(t, err, record.Type().String())
+		assert.Equal(t, record.Data(), found.Data(), record.Type().String())
+	}
+	_, err = s.GetRecord(ctx, name, types.TYPE_CNAME)
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+
+	all, err := s.GetRecords(ctx, name, 0)
+	require.NoError(t, err)
+	assert.Len(t, all, len(typed))
+
+	// Deleting one type keeps the others, deleting type 0 removes the name
+	require.NoError(t, s.DeleteRecord(ctx, name, types.TYPE_TXT))
+	all, err = s.GetRecords(ctx, name, 0)
+	require.NoError(t, err)
+	assert.Len(t, all, len(typed)-1)
+
+	require.NoError(t, s.DeleteRecord(ctx, name, 0))
+	all, err = s.GetRecords(ctx, name, 0)
+	require.NoError(t, err)
+	assert.Empty(t, all)
+	assert.ErrorIs(t, s.DeleteRecord(ctx, name, 0), storage.ErrRecordNotFound)
+}
+
+// BenchmarkTypeIndex compares finding one type among
+// many at a name in the records indexed by name and type, as the memory
+// storage keeps them, with filtering a flat list of the records of the name
+func BenchmarkTypeIndex(b *testing.B) {
+	name := "host.example.com."
+	typed := typedRecords(b, name)
+
+	b.Run("indexed", func(b *testing.B) {
+		indexed := map[string]map[types.DNSType][]records.DNSRecord{name: {}}
+		for _, record := range typed {
+			indexed[name][record.Type()] = append(indexed[name][record.Type()], record)
+		}
+
+		for b.Loop() {
+			typeRecords := indexed[name][types.TYPE_TLSA]
+			found := make([]records.DNSRecord, len(typeRecords))
+			copy(found, typeRecords)
+			if len(found) != 1 {
+				b.Fatalf("found %d records", len(found))
+			}
+		}
+	})
+
+	b.Run("flat", func(b *testing.B) {
+		flat := map[string][]records.DNSRecord{name: typed}
+
+		for b.Loop() {
+			var found []records.DNSRecord
+			for _, record := range flat[name] {
+				if record.Type() == types.TYPE_TLSA {
+					found = append(found, record)
+				}
+			}
+			if len(found) != 1 {
+				b.Fatalf("found %d records", len(found))
+			}
+		}
+	})
+}
+
 // Helper function to generate unique domain names for concurrent tests
 func generateDomainName(goroutineID, recordID int) string {
 	return string(rune('a'+goroutineID)) + "-" +
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// ErrInvalidQuery is returned when query options are combined incorrectly
+var ErrInvalidQuery = errors.New("invalid query")
+
+// QueryBuilder builds QueryOptions, checking that the options set make
+// sense together
+type QueryBuilder struct {
+	options QueryOptions
+}
+
+// NewQuery returns a builder for a query matching every record
+func NewQuery() *QueryBuilder {
+	return &QueryBuilder{}
+}
+
+// ForName matches records with exactly this name
+func (b *QueryBuilder) ForName(name string) *QueryBuilder {
+	b.options.Name = name
+	return b
+}
+
+// WithType matches records of this type
+func (b *QueryBuilder) WithType(recordType types.DNSType) *QueryBuilder {
+	b.options.RecordType = recordType
+	return b
+}
+
+// InZone matches records at or below zone
+func (b *QueryBuilder) InZone(zone string) *QueryBuilder {
+	b.options.Zone = zone
+	return b
+}
+
+// InView matches records of view, DefaultView for untagged records
+func (b *QueryBuilder) InView(view string) *QueryBuilder {
+	b.options.View = view
+	return b
+}
+
+// WithPrefix matches records whose name starts with prefix
+func (b *QueryBuilder) WithPrefix(prefix string) *QueryBuilder {
+	b.options.NamePrefix = prefix
+	return b
+}
+
+// SortBy sorts the results by field ("name", "type" or "ttl") in order
+// ("asc" or "desc")
+func (b *QueryBuilder) SortBy(field, order string) *QueryBuilder {
+	b.options.SortBy = field
+	b.options.SortOrder = order
+	return b
+}
+
+// Limit returns at most n records
+func (b *QueryBuilder) Limit(n int) *QueryBuilder {
+	b.options.Limit = n
+	return b
+}
+
+// Offset skips the first n records
+func (b *QueryBuilder) Offset(n int) *QueryBuilder {
+	b.options.Offset = n
+	return b
+}
+
+// Build returns the query options, or ErrInvalidQuery when options that
+// cannot be combined were set
+func (b *QueryBuilder) Build() (QueryOptions, error) {
+	options := b.options
+
+	if options.Name != "" && options.NamePrefix != "" {
+		return QueryOptions{}, fmt.Errorf("%w: name and name prefix are mutually exclusive", ErrInvalidQuery)
+	}
+	if options.Name != "" && options.Zone != "" && !nameInZone(options.Name, options.Zone) {
+		return QueryOptions{}, fmt.Errorf("%w: name %s is outside zone %s", ErrInvalidQuery, options.Name, options.Zone)
+	}
+
+	switch options.SortBy {
+	case "", "name", "type", "ttl":
+	default:
+		return QueryOptions{}, fmt.Errorf("%w: unknown sort field %q", ErrInvalidQuery, options.SortBy)
+	}
+	switch options.SortOrder {
+	case "", "asc", "desc":
+	default:
+		return QueryOptions{}, fmt.Errorf("%w: unknown sort order %q", ErrInvalidQuery, options.SortOrder)
+	}
+	if options.SortOrder != "" && options.SortBy == "" {
+		return QueryOptions{}, fmt.Errorf("%w: sort order without a sort field", ErrInvalidQuery)
+	}
+
+	if options.Limit < 0 {
+		return QueryOptions{}, fmt.Errorf("%w: negative limit %d", ErrInvalidQuery, options.Limit)
+	}
+	if options.Offset < 0 {
+		return QueryOptions{}, fmt.Errorf("%w: negative offset %d", ErrInvalidQuery, options.Offset)
+	}
+
+	return options, nil
+}
+
+// nameInZone reports whether name is zone or a name below it
+func nameInZone(name, zone string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	zone = strings.ToLower(strings.TrimSuffix(zone, "."))
+	return name == zone || strings.HasSuffix(name, "."+zone)
+}
@@ -0,0 +1,61 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+func TestQueryBuilder(t *testing.T) {
+	options, err := storage.NewQuery().
+		ForName("www.example.com").
+		WithType(types.TYPE_A).
+		InZone("example.com").
+		InView("lan").
+		SortBy("ttl", "desc").
+		Limit(10).
+		Offset(5).
+		Build()
+	require.NoError(t, err)
+	assert.Equal(t, storage.QueryOptions{
+		Name:       "www.example.com",
+		RecordType: types.TYPE_A,
+		Zone:       "example.com",
+		View:       "lan",
+		SortBy:     "ttl",
+		SortOrder:  "desc",
+		Limit:      10,
+		Offset:     5,
+	}, options)
+
+	options, err = storage.NewQuery().Build()
+	require.NoError(t, err)
+	assert.Equal(t, storage.QueryOptions{}, options)
+}
+
+func TestQueryBuilderInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		query *storage.QueryBuilder
+	}{
+		{name: "name and prefix", query: storage.NewQuery().ForName("www.example.com").WithPrefix("www")},
+		{name: "name outside zone", query: storage.NewQuery().ForName("www.example.org").InZone("example.com")},
+		{name: "name in lookalike zone", query: storage.NewQuery().ForName("www.badexample.com").InZone("example.com")},
+		{name: "unknown sort field", query: storage.NewQuery().SortBy("data", "asc")},
+		{name: "unknown sort order", query: storage.NewQuery().SortBy("name", "up")},
+		{name: "sort order without field", query: storage.NewQuery().SortBy("", "desc")},
+		{name: "negative limit", query: storage.NewQuery().Limit(-1)},
+		{name: "negative offset", query: storage.NewQuery().Offset(-1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, err := tt.query.Build()
+			assert.ErrorIs(t, err, storage.ErrInvalidQuery)
+			assert.Equal(t, storage.QueryOptions{}, options)
+		})
+	}
+}
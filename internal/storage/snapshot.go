@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/records"
+)
+
+// SnapshotInfo describes a snapshot of the records of a zone
+type SnapshotInfo struct {
+	ID        string
+	Zone      string // Zone whose records were saved
+	Records   int    // Number of records saved
+	CreatedAt time.Time
+}
+
+// newSnapshotID returns a random snapshot ID
+func newSnapshotID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate snapshot ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// encodeSnapshot serializes records to the JSON blob a snapshot is saved
+// as, an array of records in the form of the lines of the storage file
+func encodeSnapshot(converter *RecordConverter, recordList []records.DNSRecord) ([]byte, error) {
+	entries := make([]fileRecord, 0, len(recordList))
+	for _, record := range recordList {
+		data, err := converter.ToStorageFormat(record)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fileRecord{
+			Name:       data.Name,
+			RecordType: data.RecordType,
+			Class:      data.Class,
+			TTL:        data.TTL,
+			Data:       data.Data,
+			View:       data.View,
+		})
+	}
+
+	blob, err := json.Marshal(entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	return blob, nil
+}
+
+// decodeSnapshot returns the records of a blob encodeSnapshot made
+func decodeSnapshot(converter *RecordConverter, blob []byte) ([]records.DNSRecord, error) {
+	var entries []fileRecord
+	if err := json.Unmarshal(blob, &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	recordList := make([]records.DNSRecord, 0, len(entries))
+	for _, entry := range entries {
+		record, err := converter.FromStorageFormat(&RecordData{
+			Name:       entry.Name,
+			RecordType: entry.RecordType,
+			Class:      entry.Class,
+			TTL:        entry.TTL,
+			Data:       entry.Data,
+			View:       entry.View,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode snapshot record %s: %w", entry.Name, err)
+		}
+		recordList = append(recordList, record)
+	}
+	return recordList, nil
+}
+
+// restoreZone replaces the records of zone in store with recordList in a
+// single transaction, changing only the records that differ
+func restoreZone(ctx context.Context, store Storage, zone string, recordList []records.DNSRecord) error {
+	current, err := store.ListRecordsByZone(ctx, zone)
+	if err != nil && !errors.Is(err, ErrRecordNotFound) {
+		return fmt.Errorf("failed to list records of zone %s: %w", zone, err)
+	}
+
+	if err := ApplyDiff(ctx, store, DiffRecordSets(current, recordList)); err != nil {
+		return fmt.Errorf("failed to restore zone %s: %w", zone, err)
+	}
+	return nil
+}
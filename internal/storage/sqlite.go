@@ -0,0 +1,834 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	_ "modernc.org/sqlite" // Registers the pure-Go "sqlite" database/sql driver
+)
+
+// SQLiteStorage implements the Storage interface on an embedded SQLite
+// database, so records persist in a single file without an external server
+type SQLiteStorage struct {
+	db          *sql.DB
+	validator   *Validator
+	converter   *RecordConverter
+	notifier    *changeNotifier
+	closed      atomic.Bool
+	lastUpdated atomic.Int64 // Unix timestamp of the last change made through this storage
+}
+
+// sqliteSchema creates the records and zone snapshots tables. A record is identified by its name,
+// type, data and view, so an RRset holds any number of distinct records.
+// The view is empty for records of the default view
+var sqliteSchema = []string{
+	`CREATE TABLE IF NOT EXISTS dns_records (
+		id          INTEGER PRIMARY KEY,
+		name        TEXT    NOT NULL,
+		record_type INTEGER NOT NULL,
+		class       INTEGER NOT NULL,
+		ttl         INTEGER NOT NULL,
+		data        TEXT    NOT NULL,
+		zone        TEXT    NOT NULL,
+		view        TEXT    NOT NULL DEFAULT '',
+		created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS name_type_data_view_idx ON dns_records (name, record_type, data, view)`,
+	`CREATE INDEX IF NOT EXISTS zone_idx ON dns_records (zone)`,
+	`CREATE INDEX IF NOT EXISTS type_idx ON dns_records (record_type)`,
+	`CREATE TABLE IF NOT EXISTS dns_zone_snapshots (
+		id         TEXT     PRIMARY KEY,
+		zone       TEXT     NOT NULL,
+		records    INTEGER  NOT NULL,
+		data       TEXT     NOT NULL,
+		created_at DATETIME NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS snapshot_zone_idx ON dns_zone_snapshots (zone)`,
+}
+
+// sqliteUpsert stores a record or refreshes the record with the same data
+const sqliteUpsert = `
+	INSERT INTO dns_records (name, record_type, class, ttl, data, zone, view)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (name, record_type, data, view) DO UPDATE
+	SET class = excluded.class,
+	    ttl = excluded.ttl,
+	    zone = excluded.zone,
+	    updated_at = CURRENT_TIMESTAMP`
+
+// sqliteColumns are the columns read back into records
+const sqliteColumns = "name, record_type, class, ttl, data, zone, view"
+
+// sqlExecer is implemented by both *sql.DB and *sql.Tx
+type sqlExecer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// NewSQLiteStorage creates a SQLite storage using the connection string as
+// the database file name or URI, e.g. "dnska.db" or
+// "file:dnska.db?_journal_mode=WAL". The schema is created when missing
+func NewSQLiteStorage(ctx context.Context, config *StorageConfig) (*SQLiteStorage, error) {
+	if config == nil {
+		return nil, fmt.Errorf("storage config is required")
+	}
+	if config.ConnectionString == "" {
+		return nil, fmt.Errorf("SQLite connection string is required")
+	}
+
+	db, err := sql.Open("sqlite", config.ConnectionString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+
+	// SQLite allows a single writer; one connection also keeps an in-memory
+	// database from being split across connections
+	db.SetMaxOpenConns(1)
+
+	storage := &SQLiteStorage{
+		db:        db,
+		validator: NewValidator(config.ValidationConfig),
+		converter: NewRecordConverter(),
+		notifier:  newChangeNotifier(),
+	}
+
+	if err := migrateSQLiteViews(ctx, db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
+
+	for _, statement := range sqliteSchema {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to initialize schema: %w", err)
+		}
+	}
+
+	return storage, nil
+}
+
+// GetRecords returns all records for a given domain name and record type
+func (s *SQLiteStorage) GetRecords(ctx context.Context, name string, recordType types.DNSType) ([]records.DNSRecord, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+
+	// Validate input
+	if err := s.validator.ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	query := "SELECT " + sqliteColumns + " FROM dns_records WHERE name = ?"
+	args := []any{normalizeDomainName(name)}
+
+	if recordType != 0 {
+		query += " AND record_type = ?"
+		args = append(args, int(recordType))
+	}
+
+	return s.queryRecords(ctx, query+" ORDER BY id", args...)
+}
+
+// GetRecord returns a single record for a given domain name and record type
+func (s *SQLiteStorage) GetRecord(ctx context.Context, name string, recordType types.DNSType) (records.DNSRecord, error) {
+	recordList, err := s.GetRecords(ctx, name, recordType)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(recordList) == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	return recordList[0], nil
+}
+
+// PutRecord stores or updates a DNS record with validation
+func (s *SQLiteStorage) PutRecord(ctx context.Context, record records.DNSRecord) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+
+	// Validate record
+	if err := s.validator.ValidateRecord(record); err != nil {
+		return err
+	}
+
+	if err := s.upsert(ctx, s.db, record); err != nil {
+		return err
+	}
+
+	s.changed(s.changeEvent(ChangeOperationPut, record.Name(), record.Type()))
+	return nil
+}
+
+// DeleteRecord removes a DNS record
+// If recordType is 0, deletes all records for the name
+func (s *SQLiteStorage) DeleteRecord(ctx context.Context, name string, recordType types.DNSType) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+
+	// Validate input
+	if err := s.validator.ValidateName(name); err != nil {
+		return err
+	}
+
+	deleted, err := s.delete(ctx, s.db, normalizeDomainName(name), recordType)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrRecordNotFound
+	}
+
+	s.changed(s.changeEvent(ChangeOperationDelete, name, recordType))
+	return nil
+}
+
+// ListRecords returns all records in the storage
+func (s *SQLiteStorage) ListRecords(ctx context.Context) ([]records.DNSRecord, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+
+	return s.queryRecords(ctx, "SELECT "+sqliteColumns+" FROM dns_records ORDER BY name, record_type, id")
+}
+
+// ListRecordsByZone returns all records for a specific zone
+func (s *SQLiteStorage) ListRecordsByZone(ctx context.Context, zone string) ([]records.DNSRecord, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+
+	// Validate zone
+	if err := s.validator.ValidateZone(zone); err != nil {
+		return nil, err
+	}
+
+	condition, args := sqliteZoneCondition(zone)
+	return s.queryRecords(ctx, "SELECT "+sqliteColumns+" FROM dns_records WHERE "+condition+" ORDER BY name, record_type, id", args...)
+}
+
+// GetZones returns all available zones
+func (s *SQLiteStorage) GetZones(ctx context.Context) ([]string, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT DISTINCT zone FROM dns_records WHERE zone != '' ORDER BY zone")
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	zones := []string{}
+	for rows.Next() {
+		var zone string
+		if err := rows.Scan(&zone); err != nil {
+			return nil, fmt.Errorf("failed to read zone: %w", err)
+		}
+		zones = append(zones, zone)
+	}
+
+	return zones, rows.Err()
+}
+
+// QueryRecords performs a filtered query with optional pagination
+func (s *SQLiteStorage) QueryRecords(ctx context.Context, options QueryOptions) ([]records.DNSRecord, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+
+	query := "SELECT " + sqliteColumns + " FROM dns_records"
+	var conditions []string
+	var args []any
+
+	// Build filter conditions
+	if options.Name != "" {
+		conditions = append(conditions, "name = ?")
+		args = append(args, normalizeDomainName(options.Name))
+	}
+
+	if options.NamePrefix != "" {
+		// substr avoids escaping the LIKE wildcards '_' and '%' in names
+		prefix := normalizeDomainName(options.NamePrefix)
+		conditions = append(conditions, "substr(name, 1, ?) = ?")
+		args = append(args, len(prefix), prefix)
+	}
+
+	if options.RecordType != 0 {
+		conditions = append(conditions, "record_type = ?")
+		args = append(args, int(options.RecordType))
+	}
+
+	if options.Zone != "" {
+		condition, zoneArgs := sqliteZoneCondition(options.Zone)
+		conditions = append(conditions, condition)
+		args = append(args, zoneArgs...)
+	}
+
+	if options.View != "" {
+		conditions = append(conditions, "view = ?")
+		args = append(args, storedView(options.View))
+	}
+
+	// Add WHERE clause if conditions exist
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// Add sorting; id keeps the order stable between pages
+	sortField := "name"
+	switch options.SortBy {
+	case "type":
+		sortField = "record_type"
+	case "ttl":
+		sortField = "ttl"
+	}
+
+	if options.SortOrder == "desc" {
+		query += fmt.Sprintf(" ORDER BY %s DESC, id", sortField)
+	} else {
+		query += fmt.Sprintf(" ORDER BY %s ASC, id", sortField)
+	}
+
+	// Add pagination; SQLite needs a LIMIT for an OFFSET, -1 means no limit
+	if options.Limit > 0 || options.Offset > 0 {
+		limit := -1
+		if options.Limit > 0 {
+			limit = options.Limit
+		}
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, limit, max(options.Offset, 0))
+	}
+
+	return s.queryRecords(ctx, query, args...)
+}
+
+// BatchPutRecords stores multiple records in a single transaction. A record
+// failing validation rolls back the whole batch
+func (s *SQLiteStorage) BatchPutRecords(ctx context.Context, recordList []records.DNSRecord) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+
+	if len(recordList) == 0 {
+		return nil
+	}
+
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range recordList {
+		if err := tx.PutRecord(ctx, record); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("validation failed: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("batch insert failed: %w", err)
+	}
+
+	return nil
+}
+
+// BatchDeleteRecords deletes multiple records in a single transaction
+func (s *SQLiteStorage) BatchDeleteRecords(ctx context.Context, names []string, recordType types.DNSType) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	var events []ChangeEvent
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, name := range names {
+			deleted, err := s.delete(ctx, tx, normalizeDomainName(name), recordType)
+			if err != nil {
+				return err
+			}
+			if deleted {
+				events = append(events, s.changeEvent(ChangeOperationDelete, name, recordType))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("batch delete failed: %w", err)
+	}
+
+	s.changed(events...)
+	return nil
+}
+
+// ReplaceRRSet atomically replaces all records of the given name and type
+// inside a single SQLite transaction
+func (s *SQLiteStorage) ReplaceRRSet(ctx context.Context, name string, recordType types.DNSType, recordList []records.DNSRecord) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+
+	// Validate the whole set before touching any state
+	if err := s.validator.ValidateRRSet(name, recordType, recordList); err != nil {
+		return err
+	}
+
+	name = normalizeDomainName(name)
+
+	var existed bool
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		if existed, err = s.delete(ctx, tx, name, recordType); err != nil {
+			return err
+		}
+		for _, record := range recordList {
+			if err := s.upsert(ctx, tx, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to replace RRset: %w", err)
+	}
+
+	if len(recordList) > 0 {
+		s.changed(s.changeEvent(ChangeOperationPut, name, recordType))
+	} else if existed {
+		s.changed(s.changeEvent(ChangeOperationDelete, name, recordType))
+	}
+
+	return nil
+}
+
+// SnapshotZone saves the records of zone as a JSON blob in the
+// dns_zone_snapshots table
+func (s *SQLiteStorage) SnapshotZone(ctx context.Context, zone string) (string, error) {
+	recordList, err := s.ListRecordsByZone(ctx, zone)
+	if err != nil {
+		return "", err
+	}
+	if len(recordList) == 0 {
+		return "", ErrRecordNotFound
+	}
+
+	blob, err := encodeSnapshot(s.converter, recordList)
+	if err != nil {
+		return "", err
+	}
+	id, err := newSnapshotID()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		"INSERT INTO dns_zone_snapshots (id, zone, records, data, created_at) VALUES (?, ?, ?, ?, ?)",
+		id, normalizeDomainName(zone), len(recordList), string(blob), time.Now().UTC(),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to store snapshot: %w", err)
+	}
+	return id, nil
+}
+
+// RestoreSnapshot replaces the records of the zone of a snapshot with those
+// saved in it, in one database transaction
+func (s *SQLiteStorage) RestoreSnapshot(ctx context.Context, snapshotID string) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+
+	var zone, blob string
+	err := s.db.QueryRowContext(ctx, "SELECT zone, data FROM dns_zone_snapshots WHERE id = ?", snapshotID).Scan(&zone, &blob)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrSnapshotNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+
+	recordList, err := decodeSnapshot(s.converter, []byte(blob))
+	if err != nil {
+		return err
+	}
+	return restoreZone(ctx, s, zone, recordList)
+}
+
+// ListSnapshots returns the snapshots of zone, oldest first
+func (s *SQLiteStorage) ListSnapshots(ctx context.Context, zone string) ([]SnapshotInfo, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+	if err := s.validator.ValidateZone(zone); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, zone, records, created_at FROM dns_zone_snapshots WHERE zone = ? ORDER BY created_at, rowid",
+		normalizeDomainName(zone),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	snapshots := []SnapshotInfo{}
+	for rows.Next() {
+		var snapshot SnapshotInfo
+		if err := rows.Scan(&snapshot.ID, &snapshot.Zone, &snapshot.Records, &snapshot.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, rows.Err()
+}
+
+// DeleteSnapshot removes a snapshot from the dns_zone_snapshots table
+func (s *SQLiteStorage) DeleteSnapshot(ctx context.Context, snapshotID string) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+
+	result, err := s.db.ExecContext(ctx, "DELETE FROM dns_zone_snapshots WHERE id = ?", snapshotID)
+	if err != nil {
+		return fmt.Errorf("delete failed: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrSnapshotNotFound
+	}
+	return nil
+}
+
+// Begin starts a transaction that stages changes until Commit applies them
+// in a single SQLite transaction
+func (s *SQLiteStorage) Begin(ctx context.Context) (Transaction, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+
+	return &sqliteTransaction{storage: s}, nil
+}
+
+// Subscribe returns a channel receiving an event after every change made
+// through this storage. Changes written to the database by other processes
+// are not reported
+func (s *SQLiteStorage) Subscribe(ctx context.Context) (<-chan ChangeEvent, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+
+	return s.notifier.subscribe(ctx)
+}
+
+// Ping checks that the database can be reached
+func (s *SQLiteStorage) Ping(ctx context.Context) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("ping failed: %w", err)
+	}
+	return nil
+}
+
+// Close closes the database and cleans up resources
+func (s *SQLiteStorage) Close() error {
+	if !s.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	s.notifier.close()
+	return s.db.Close()
+}
+
+// GetStats returns storage statistics
+func (s *SQLiteStorage) GetStats(ctx context.Context) (*StorageStats, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+
+	stats := &StorageStats{
+		RecordTypes:   make(map[string]int),
+		LastUpdated:   s.lastUpdated.Load(),
+		DroppedEvents: s.notifier.droppedEvents(),
+	}
+
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*), COUNT(DISTINCT NULLIF(zone, '')) FROM dns_records",
+	).Scan(&stats.TotalRecords, &stats.TotalZones)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get record count: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT record_type, COUNT(*) FROM dns_records GROUP BY record_type")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get type counts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var recordType, count int
+		if err := rows.Scan(&recordType, &count); err != nil {
+			return nil, fmt.Errorf("failed to read type count: %w", err)
+		}
+		stats.RecordTypes[types.DNSType(recordType).String()] = count
+	}
+
+	return stats, rows.Err()
+}
+
+// Helper methods
+
+// upsert writes a validated record with exec
+func (s *SQLiteStorage) upsert(ctx context.Context, exec sqlExecer, record records.DNSRecord) error {
+	recordData, err := s.converter.ToStorageFormat(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec.ExecContext(ctx, sqliteUpsert,
+		normalizeDomainName(recordData.Name),
+		recordData.RecordType,
+		recordData.Class,
+		recordData.TTL,
+		recordData.Data,
+		recordData.Zone,
+		recordData.View,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store record: %w", err)
+	}
+
+	return nil
+}
+
+// delete removes the records of a normalized name with the given type, or
+// all of them when recordType is 0. It reports whether anything was removed
+func (s *SQLiteStorage) delete(ctx context.Context, exec sqlExecer, name string, recordType types.DNSType) (bool, error) {
+	query := "DELETE FROM dns_records WHERE name = ?"
+	args := []any{name}
+
+	if recordType != 0 {
+		query += " AND record_type = ?"
+		args = append(args, int(recordType))
+	}
+
+	result, err := exec.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, fmt.Errorf("delete failed: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("delete failed: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// inTx runs fn in a database transaction, committing when it succeeds
+func (s *SQLiteStorage) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// queryRecords runs a query selecting sqliteColumns and converts the rows.
+// Rows that no longer convert to a record are skipped
+func (s *SQLiteStorage) queryRecords(ctx context.Context, query string, args ...any) ([]records.DNSRecord, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	result := []records.DNSRecord{}
+	for rows.Next() {
+		var data RecordData
+		if err := rows.Scan(&data.Name, &data.RecordType, &data.Class, &data.TTL, &data.Data, &data.Zone, &data.View); err != nil {
+			return nil, fmt.Errorf("failed to read record: %w", err)
+		}
+
+		record, err := s.converter.FromStorageFormat(&data)
+		if err != nil {
+			// Skip invalid records rather than failing entirely
+			continue
+		}
+		result = append(result, record)
+	}
+
+	return result, rows.Err()
+}
+
+// changed records the time of a change and publishes its events
+func (s *SQLiteStorage) changed(events ...ChangeEvent) {
+	s.lastUpdated.Store(time.Now().Unix())
+	s.notifier.publish(events...)
+}
+
+// changeEvent creates an event for a change to the records of name
+func (s *SQLiteStorage) changeEvent(operation ChangeOperation, name string, recordType types.DNSType) ChangeEvent {
+	name = normalizeDomainName(name)
+	return newChangeEvent(operation, name, recordType, s.converter.extractZone(name))
+}
+
+// migrateSQLiteViews adds the view column to a records table created before
+// views existed, whose records all belong to the default view. A missing
+// table is left to the schema
+func migrateSQLiteViews(ctx context.Context, db *sql.DB) error {
+	var columns, viewColumns int
+	err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*), COALESCE(SUM(name = 'view'), 0) FROM pragma_table_info('dns_records')",
+	).Scan(&columns, &viewColumns)
+	if err != nil || columns == 0 || viewColumns > 0 {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range []string{
+		`ALTER TABLE dns_records ADD COLUMN view TEXT NOT NULL DEFAULT ''`,
+		`DROP INDEX IF EXISTS name_type_data_idx`,
+	} {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// sqliteZoneCondition matches names equal to zone or below it, like
+// MemoryStorage.isInZone
+func sqliteZoneCondition(zone string) (string, []any) {
+	zone = normalizeDomainName(zone)
+	return "(name = ? OR substr(name, -?) = ?)", []any{zone, len(zone) + 1, "." + zone}
+}
+
+// sqliteTransaction stages changes for a SQLiteStorage and applies them in a
+// single database transaction on Commit. It is not safe for concurrent use
+type sqliteTransaction struct {
+	storage    *SQLiteStorage
+	operations []memoryOperation
+	done       bool
+}
+
+// PutRecord validates and stages a record
+func (tx *sqliteTransaction) PutRecord(ctx context.Context, record records.DNSRecord) error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+
+	if err := tx.storage.validator.ValidateRecord(record); err != nil {
+		return err
+	}
+
+	tx.operations = append(tx.operations, memoryOperation{record: record})
+	return nil
+}
+
+// DeleteRecord stages removing records. Deleting records that do not exist
+// at commit time is not an error
+func (tx *sqliteTransaction) DeleteRecord(ctx context.Context, name string, recordType types.DNSType) error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+
+	if err := tx.storage.validator.ValidateName(name); err != nil {
+		return err
+	}
+
+	tx.operations = append(tx.operations, memoryOperation{
+		name:       normalizeDomainName(name),
+		recordType: recordType,
+	})
+	return nil
+}
+
+// Commit applies all staged changes in one database transaction
+func (tx *sqliteTransaction) Commit(ctx context.Context) error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+	tx.done = true
+
+	s := tx.storage
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+
+	if len(tx.operations) == 0 {
+		return nil
+	}
+
+	events := make([]ChangeEvent, 0, len(tx.operations))
+	err := s.inTx(ctx, func(sqlTx *sql.Tx) error {
+		for _, operation := range tx.operations {
+			if operation.record != nil {
+				if err := s.upsert(ctx, sqlTx, operation.record); err != nil {
+					return err
+				}
+				events = append(events, s.changeEvent(ChangeOperationPut, operation.record.Name(), operation.record.Type()))
+				continue
+			}
+
+			deleted, err := s.delete(ctx, sqlTx, operation.name, operation.recordType)
+			if err != nil {
+				return err
+			}
+			if deleted {
+				events = append(events, s.changeEvent(ChangeOperationDelete, operation.name, operation.recordType))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("transaction failed: %w", err)
+	}
+
+	tx.operations = nil
+	s.changed(events...)
+	return nil
+}
+
+// Rollback discards all staged changes; nothing has been written yet
+func (tx *sqliteTransaction) Rollback() error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+	tx.done = true
+	tx.operations = nil
+
+	return nil
+}
+
+// Ensure SQLiteStorage implements Storage interface
+var _ Storage = (*SQLiteStorage)(nil)
+var _ StorageWithStats = (*SQLiteStorage)(nil)
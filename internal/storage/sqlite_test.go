@@ -0,0 +1,241 @@
+package storage_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+func newTestSQLiteStorage(t *testing.T, path string) *storage.SQLiteStorage {
+	t.Helper()
+
+	s, err := storage.NewSQLiteStorage(context.Background(), &storage.StorageConfig{
+		Type:             storage.StorageTypeSQLite,
+		ConnectionString: path,
+		ValidationConfig: &storage.ValidationConfig{Enabled: true, AllowUnderscore: true},
+	})
+	require.NoError(t, err)
+	return s
+}
+
+func TestSQLiteStorage_Suite(t *testing.T) {
+	sqliteStorage := newTestSQLiteStorage(t, filepath.Join(t.TempDir(), "records.db"))
+	defer sqliteStorage.Close()
+
+	suite := NewStorageTestSuite(t, sqliteStorage)
+	suite.RunAll()
+}
+
+func TestSQLiteStorage_InMemory(t *testing.T) {
+	s, err := storage.NewStorage(context.Background(), &storage.StorageConfig{
+		Type:             storage.StorageTypeSQLite,
+		ConnectionString: ":memory:",
+	})
+	require.NoError(t, err)
+	defer s.Close()
+
+	record, _ := records.NewARecordFromString("www.example.com", "192.0.2.1", 300)
+	require.NoError(t, s.PutRecord(context.Background(), record))
+
+	_, err = s.GetRecord(context.Background(), "www.example.com", types.TYPE_A)
+	assert.NoError(t, err)
+}
+
+func TestSQLiteStorage_ReloadAfterClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.db")
+	ctx := context.Background()
+
+	s := newTestSQLiteStorage(t, path)
+	testRecords := storage.CreateTestRecords(t)
+	require.NoError(t, s.BatchPutRecords(ctx, testRecords))
+	require.NoError(t, s.DeleteRecord(ctx, "alias.example.com", types.TYPE_CNAME))
+	require.NoError(t, s.Close())
+
+	reloaded := newTestSQLiteStorage(t, path)
+	defer reloaded.Close()
+
+	all, err := reloaded.ListRecords(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, len(testRecords)-1)
+
+	_, err = reloaded.GetRecord(ctx, "alias.example.com", types.TYPE_CNAME)
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+}
+
+func TestSQLiteStorage_MigratesViews(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.db")
+	ctx := context.Background()
+
+	// A database written before records had views
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	for _, statement := range []string{
+		`CREATE TABLE dns_records (
+			id          INTEGER PRIMARY KEY,
+			name        TEXT    NOT NULL,
+			record_type INTEGER NOT NULL,
+			class       INTEGER NOT NULL,
+			ttl         INTEGER NOT NULL,
+			data        TEXT    NOT NULL,
+			zone        TEXT    NOT NULL,
+			created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE UNIQUE INDEX name_type_data_idx ON dns_records (name, record_type, data)`,
+		`INSERT INTO dns_records (name, record_type, class, ttl, data, zone) VALUES ('www.example.com.', 1, 1, 300, '192.0.2.1', 'example.com')`,
+	} {
+		_, err := db.Exec(statement)
+		require.NoError(t, err)
+	}
+	require.NoError(t, db.Close())
+
+	s := newTestSQLiteStorage(t, path)
+	defer s.Close()
+
+	// The same data may now be stored once per view
+	lan, _ := records.NewARecordFromString("www.example.com", "192.0.2.1", 60)
+	require.NoError(t, s.PutRecord(ctx, storage.NewViewRecord(lan, "lan")))
+
+	results, err := s.QueryRecords(ctx, storage.QueryOptions{Name: "www.example.com", View: storage.DefaultView})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, uint32(300), results[0].TTL())
+
+	results, err = s.QueryRecords(ctx, storage.QueryOptions{Name: "www.example.com", View: "lan"})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, uint32(60), results[0].TTL())
+}
+
+func TestSQLiteStorage_UniqueRecordData(t *testing.T) {
+	s := newTestSQLiteStorage(t, filepath.Join(t.TempDir(), "records.db"))
+	defer s.Close()
+	ctx := context.Background()
+
+	first, _ := records.NewARecordFromString("www.example.com", "192.0.2.1", 300)
+	second, _ := records.NewARecordFromString("www.example.com", "192.0.2.2", 300)
+	updated, _ := records.NewARecordFromString("WWW.example.com", "192.0.2.1", 600)
+
+	require.NoError(t, s.PutRecord(ctx, first))
+	require.NoError(t, s.PutRecord(ctx, second))
+	require.NoError(t, s.PutRecord(ctx, updated))
+
+	recs, err := s.GetRecords(ctx, "www.example.com", types.TYPE_A)
+	require.NoError(t, err)
+	require.Len(t, recs, 2, "records with the same data must be updated, not duplicated")
+	assert.Equal(t, uint32(600), recs[0].TTL())
+	assert.Equal(t, uint32(300), recs[1].TTL())
+}
+
+func TestSQLiteStorage_QueryOptions(t *testing.T) {
+	s := newTestSQLiteStorage(t, filepath.Join(t.TempDir(), "records.db"))
+	defer s.Close()
+	ctx := context.Background()
+
+	for i, name := range []string{"a.example.com", "b.example.com", "c.example.com", "d.example.org", "my_host.example.com"} {
+		record, _ := records.NewARecordFromString(name, "192.0.2.1", uint32(100*(i+1)))
+		require.NoError(t, s.PutRecord(ctx, record))
+	}
+
+	names := func(recs []records.DNSRecord) []string {
+		result := make([]string, len(recs))
+		for i, record := range recs {
+			result[i] = record.Name()
+		}
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		options  storage.QueryOptions
+		expected []string
+	}{
+		{
+			name:     "zone",
+			options:  storage.QueryOptions{Zone: "example.org"},
+			expected: []string{"d.example.org."},
+		},
+		{
+			name:     "sort by ttl descending",
+			options:  storage.QueryOptions{Zone: "example.com", SortBy: "ttl", SortOrder: "desc"},
+			expected: []string{"my_host.example.com.", "c.example.com.", "b.example.com.", "a.example.com."},
+		},
+		{
+			name:     "limit and offset",
+			options:  storage.QueryOptions{Limit: 2, Offset: 1},
+			expected: []string{"b.example.com.", "c.example.com."},
+		},
+		{
+			name:     "offset without limit",
+			options:  storage.QueryOptions{Offset: 3},
+			expected: []string{"d.example.org.", "my_host.example.com."},
+		},
+		{
+			name:     "prefix with underscore is not a wildcard",
+			options:  storage.QueryOptions{NamePrefix: "my_host"},
+			expected: []string{"my_host.example.com."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := s.QueryRecords(ctx, tt.options)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, names(results))
+		})
+	}
+
+	zones, err := s.GetZones(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"example.com", "example.org"}, zones)
+}
+
+func TestSQLiteStorage_BatchRollback(t *testing.T) {
+	s := newTestSQLiteStorage(t, filepath.Join(t.TempDir(), "records.db"))
+	defer s.Close()
+	ctx := context.Background()
+
+	valid, _ := records.NewARecordFromString("valid.example.com", "192.0.2.1", 300)
+	err := s.BatchPutRecords(ctx, []records.DNSRecord{
+		valid,
+		&testRecord{name: "invalid..example.com", recordType: types.TYPE_A, ttl: 300, data: []byte{192, 0, 2, 2}},
+	})
+	require.Error(t, err)
+
+	all, err := s.ListRecords(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, all, "a failed batch must not store any record")
+}
+
+func TestSQLiteStorage_Stats(t *testing.T) {
+	s := newTestSQLiteStorage(t, filepath.Join(t.TempDir(), "records.db"))
+	defer s.Close()
+	ctx := context.Background()
+
+	events, err := s.Subscribe(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, s.BatchPutRecords(ctx, storage.CreateTestRecords(t)))
+
+	stats, err := s.GetStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 9, stats.TotalRecords)
+	assert.Equal(t, 2, stats.TotalZones)
+	assert.Equal(t, 2, stats.RecordTypes["A"])
+	assert.NotZero(t, stats.LastUpdated)
+
+	event := <-events
+	assert.Equal(t, storage.ChangeOperationPut, event.Operation)
+	assert.Equal(t, "example.com", event.Zone)
+
+	require.NoError(t, s.Close())
+	_, err = s.GetStats(ctx)
+	assert.ErrorIs(t, err, storage.ErrStorageClosed)
+}
@@ -3,11 +3,17 @@ package storage
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
+	"github.com/vadim-su/dnska/internal/logging"
 	"github.com/vadim-su/dnska/pkg/dns/records"
 	"github.com/vadim-su/dnska/pkg/dns/types"
 )
 
+// logger is the logger of the storage component
+var logger = logging.For("storage")
+
 var (
 	// ErrRecordNotFound is returned when a record is not found
 	ErrRecordNotFound = errors.New("record not found")
@@ -23,6 +29,14 @@ var (
 	ErrInvalidTTL = errors.New("invalid TTL value")
 	// ErrStorageClosed is returned when operations are attempted on closed storage
 	ErrStorageClosed = errors.New("storage is closed")
+	// ErrStorageUnavailable is returned while the storage reconnects to its backend
+	ErrStorageUnavailable = errors.New("storage is unavailable")
+	// ErrStorageLocked is returned when another process holds the database of an embedded storage
+	ErrStorageLocked = errors.New("storage is locked by another process")
+	// ErrTransactionDone is returned when a committed or rolled back transaction is used
+	ErrTransactionDone = errors.New("transaction already committed or rolled back")
+	// ErrSnapshotNotFound is returned when a zone snapshot is not found
+	ErrSnapshotNotFound = errors.New("snapshot not found")
 )
 
 // Storage defines the unified interface for DNS record storage
@@ -68,12 +82,90 @@ type Storage interface {
 	// BatchDeleteRecords deletes multiple records in a single operation
 	BatchDeleteRecords(ctx context.Context, names []string, recordType types.DNSType) error
 
+	// ReplaceRRSet atomically replaces all records of the given name and type
+	// with the provided set. Readers observe either the old or the new set,
+	// never a mix. An empty set behaves like a bulk delete of the RRset
+	ReplaceRRSet(ctx context.Context, name string, recordType types.DNSType, records []records.DNSRecord) error
+
+	// Transactions
+
+	// Begin starts a transaction. Changes made through it are invisible to
+	// readers until Commit applies all of them at once
+	Begin(ctx context.Context) (Transaction, error)
+
+	// Zone snapshots
+
+	// SnapshotZone saves the records of a zone, those of its name and the
+	// names below it, and returns the ID of the snapshot. A zone without
+	// records returns ErrRecordNotFound
+	SnapshotZone(ctx context.Context, zone string) (string, error)
+
+	// RestoreSnapshot atomically replaces the records of the zone of a
+	// snapshot with those saved in it
+	RestoreSnapshot(ctx context.Context, snapshotID string) error
+
+	// ListSnapshots returns the snapshots of a zone, oldest first
+	ListSnapshots(ctx context.Context, zone string) ([]SnapshotInfo, error)
+
+	// DeleteSnapshot removes a snapshot
+	DeleteSnapshot(ctx context.Context, snapshotID string) error
+
+	// Change notifications
+
+	// Subscribe returns a channel receiving an event for every committed
+	// change. The channel is closed when ctx is cancelled or the storage is
+	// closed. Events that do not fit in a slow subscriber's buffer are
+	// dropped rather than blocking writers
+	Subscribe(ctx context.Context) (<-chan ChangeEvent, error)
+
 	// Lifecycle
 
+	// Ping checks that the storage can serve requests, e.g. that the
+	// database connection is alive
+	Ping(ctx context.Context) error
+
 	// Close closes the storage connection and cleans up resources
 	Close() error
 }
 
+// Transaction stages record changes that are applied atomically on Commit.
+// Readers observe either none or all of the staged changes. A transaction
+// must end with Commit or Rollback and cannot be used afterwards
+type Transaction interface {
+	// PutRecord stages storing or updating a record
+	// Implementation MUST validate the record before staging it
+	PutRecord(ctx context.Context, record records.DNSRecord) error
+
+	// DeleteRecord stages removing records of a name
+	// If recordType is 0, deletes all records for the name
+	DeleteRecord(ctx context.Context, name string, recordType types.DNSType) error
+
+	// Commit applies all staged changes in order
+	Commit(ctx context.Context) error
+
+	// Rollback discards all staged changes
+	Rollback() error
+}
+
+// ChangeOperation identifies the kind of change reported by a ChangeEvent
+type ChangeOperation string
+
+const (
+	// ChangeOperationPut reports records stored or updated
+	ChangeOperationPut ChangeOperation = "put"
+	// ChangeOperationDelete reports records removed
+	ChangeOperationDelete ChangeOperation = "delete"
+)
+
+// ChangeEvent describes a committed change to the records of a name
+type ChangeEvent struct {
+	Operation  ChangeOperation
+	Name       string        // Normalized owner name with a trailing dot
+	RecordType types.DNSType // 0 when all types of the name were deleted
+	Zone       string        // Zone of the name, as stored with the record
+	Timestamp  time.Time
+}
+
 // QueryOptions defines options for record queries
 type QueryOptions struct {
 	// Filter criteria
@@ -81,6 +173,7 @@ type QueryOptions struct {
 	NamePrefix string        // Domain name prefix filter
 	RecordType types.DNSType // Record type filter (0 = all types)
 	Zone       string        // Zone filter
+	View       string        // View filter, DefaultView for untagged records ("" = all views)
 
 	// Pagination
 	Limit  int // Maximum number of records to return (0 = no limit)
@@ -99,6 +192,12 @@ const (
 	StorageTypeMemory StorageType = "memory"
 	// StorageTypeSurrealDB represents SurrealDB storage
 	StorageTypeSurrealDB StorageType = "surrealdb"
+	// StorageTypeFile represents in-memory storage persisted to a JSON Lines file
+	StorageTypeFile StorageType = "file"
+	// StorageTypeSQLite represents storage in an embedded SQLite database
+	StorageTypeSQLite StorageType = "sqlite"
+	// StorageTypeBolt represents storage in an embedded bbolt database
+	StorageTypeBolt StorageType = "bolt"
 )
 
 // StorageConfig holds configuration for storage backends
@@ -107,6 +206,10 @@ type StorageConfig struct {
 	ConnectionString string         `yaml:"connection_string,omitempty" json:"connection_string,omitempty"`
 	Options          map[string]any `yaml:"options,omitempty" json:"options,omitempty"`
 
+	// AutoPTR keeps reverse PTR records in sync with stored A/AAAA records
+	// whose reverse zones are stored
+	AutoPTR bool `yaml:"auto_ptr,omitempty" json:"auto_ptr,omitempty"`
+
 	// Validation configuration
 	ValidationConfig *ValidationConfig `yaml:"validation,omitempty" json:"validation,omitempty"`
 }
@@ -125,6 +228,10 @@ type ValidationConfig struct {
 
 	// Allowed record types (empty means all types allowed)
 	AllowedTypes []string `yaml:"allowed_types,omitempty" json:"allowed_types,omitempty"`
+
+	// Allow records of types without a typed representation, stored with
+	// their RDATA in the generic form of RFC 3597
+	AllowUnknownTypes bool `yaml:"allow_unknown_types" json:"allow_unknown_types"`
 }
 
 // NewStorage creates a new storage instance based on the provided configuration
@@ -140,14 +247,33 @@ func NewStorage(ctx context.Context, config *StorageConfig) (Storage, error) {
 		}
 	}
 
+	var storage Storage
+	var err error
+
 	switch config.Type {
 	case StorageTypeMemory:
-		return NewMemoryStorage(config.ValidationConfig)
+		storage, err = NewMemoryStorage(config.ValidationConfig)
 	case StorageTypeSurrealDB:
-		return NewSurrealDBStorage(ctx, config)
+		storage, err = NewSurrealDBStorage(ctx, config)
+	case StorageTypeFile:
+		storage, err = newFileStorageFromConfig(config)
+	case StorageTypeSQLite:
+		storage, err = NewSQLiteStorage(ctx, config)
+	case StorageTypeBolt:
+		storage, err = NewBoltDBStorage(config)
 	default:
 		return nil, errors.New("unsupported storage type: " + string(config.Type))
 	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if config.AutoPTR {
+		storage = NewAutoPTRStorage(storage)
+	}
+
+	return storage, nil
 }
 
 // StorageStats represents storage statistics
@@ -156,6 +282,8 @@ type StorageStats struct {
 	TotalZones   int            // Total number of zones
 	RecordTypes  map[string]int // Count by record type
 	LastUpdated  int64          // Unix timestamp of last update
+
+	DroppedEvents uint64 // Change events dropped for slow subscribers
 }
 
 // StorageWithStats extends Storage with statistics capabilities
@@ -165,3 +293,25 @@ type StorageWithStats interface {
 	// GetStats returns storage statistics
 	GetStats(ctx context.Context) (*StorageStats, error)
 }
+
+// newFileStorageFromConfig creates a file storage from the "path" and
+// "flush_interval" options. The connection string is used when no path is set
+func newFileStorageFromConfig(config *StorageConfig) (*FileStorage, error) {
+	path := config.ConnectionString
+	flushInterval := DefaultFlushInterval
+
+	if config.Options != nil {
+		if optionPath, ok := config.Options["path"].(string); ok {
+			path = optionPath
+		}
+		if interval, ok := config.Options["flush_interval"].(string); ok {
+			parsed, err := time.ParseDuration(interval)
+			if err != nil {
+				return nil, fmt.Errorf("invalid flush_interval: %w", err)
+			}
+			flushInterval = parsed
+		}
+	}
+
+	return NewFileStorage(path, flushInterval, config.ValidationConfig)
+}
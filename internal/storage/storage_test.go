@@ -2,6 +2,7 @@ package storage_test
 
 import (
 	"context"
+	"net"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -33,9 +34,14 @@ func (s *StorageTestSuite) RunAll() {
 	s.TestGetRecords()
 	s.TestQueryRecords()
 	s.TestBatchOperations()
+	s.TestReplaceRRSet()
+	s.TestTransactions()
 	s.TestZoneOperations()
 	s.TestValidation()
 	s.TestEdgeCases()
+	s.TestViews()
+	s.TestCancellation()
+	s.TestSnapshots()
 }
 
 // TestBasicCRUD tests basic create, read, update, delete operations
@@ -123,10 +129,10 @@ func (s *StorageTestSuite) TestQueryRecords() {
 
 	// Setup test data
 	testRecords := []records.DNSRecord{
-		mustCreateARecord("alpha.example.com", "192.168.1.1", 300),
-		mustCreateARecord("beta.example.com", "192.168.1.2", 600),
-		mustCreateARecord("gamma.example.com", "192.168.1.3", 900),
-		mustCreateAAAARecord("alpha.example.com", "2001:db8::1", 300),
+		mustBuild(t, records.NewBuilder().Name("alpha.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1"))),
+		mustBuild(t, records.NewBuilder().Name("beta.example.com").Type(types.TYPE_A).TTL(600).IPv4(net.ParseIP("192.168.1.2"))),
+		mustBuild(t, records.NewBuilder().Name("gamma.example.com").Type(types.TYPE_A).TTL(900).IPv4(net.ParseIP("192.168.1.3"))),
+		mustBuild(t, records.NewBuilder().Name("alpha.example.com").Type(types.TYPE_AAAA).TTL(300).IPv6(net.ParseIP("2001:db8::1"))),
 		records.NewCNAMERecord("www.example.com", "example.com", 300),
 		records.NewMXRecord("example.com", "mail.example.com", 10, 300),
 	}
@@ -188,10 +194,10 @@ func (s *StorageTestSuite) TestBatchOperations() {
 
 	// Prepare batch records
 	batchRecords := []records.DNSRecord{
-		mustCreateARecord("batch1.example.com", "192.168.1.1", 300),
-		mustCreateARecord("batch2.example.com", "192.168.1.2", 300),
-		mustCreateARecord("batch3.example.com", "192.168.1.3", 300),
-		mustCreateAAAARecord("batch1.example.com", "2001:db8::1", 300),
+		mustBuild(t, records.NewBuilder().Name("batch1.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1"))),
+		mustBuild(t, records.NewBuilder().Name("batch2.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.2"))),
+		mustBuild(t, records.NewBuilder().Name("batch3.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.3"))),
+		mustBuild(t, records.NewBuilder().Name("batch1.example.com").Type(types.TYPE_AAAA).TTL(300).IPv6(net.ParseIP("2001:db8::1"))),
 		records.NewCNAMERecord("batch-cname.example.com", "example.com", 300),
 	}
 
@@ -228,6 +234,125 @@ func (s *StorageTestSuite) TestBatchOperations() {
 	s.storage.DeleteRecord(ctx, "batch-cname.example.com", types.TYPE_CNAME)
 }
 
+// TestReplaceRRSet tests atomic RRset replacement
+func (s *StorageTestSuite) TestReplaceRRSet() {
+	t := s.t
+	ctx := s.ctx
+
+	require.NoError(t, s.storage.BatchPutRecords(ctx, []records.DNSRecord{
+		mustBuild(t, records.NewBuilder().Name("rrset.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1"))),
+		mustBuild(t, records.NewBuilder().Name("rrset.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.2"))),
+		mustBuild(t, records.NewBuilder().Name("rrset.example.com").Type(types.TYPE_AAAA).TTL(300).IPv6(net.ParseIP("2001:db8::1"))),
+	}))
+
+	// Replace the A RRset with a different set
+	newSet := []records.DNSRecord{
+		mustBuild(t, records.NewBuilder().Name("rrset.example.com").Type(types.TYPE_A).TTL(600).IPv4(net.ParseIP("10.0.0.1"))),
+		mustBuild(t, records.NewBuilder().Name("rrset.example.com").Type(types.TYPE_A).TTL(600).IPv4(net.ParseIP("10.0.0.2"))),
+		mustBuild(t, records.NewBuilder().Name("rrset.example.com").Type(types.TYPE_A).TTL(600).IPv4(net.ParseIP("10.0.0.3"))),
+	}
+	err := s.storage.ReplaceRRSet(ctx, "rrset.example.com", types.TYPE_A, newSet)
+	assert.NoError(t, err, "Should replace RRset without error")
+
+	aRecords, err := s.storage.GetRecords(ctx, "rrset.example.com", types.TYPE_A)
+	assert.NoError(t, err)
+	assert.Len(t, aRecords, 3, "Should contain exactly the new set")
+	for _, record := range aRecords {
+		assert.Equal(t, uint32(600), record.TTL())
+	}
+
+	// Other types at the same name must be untouched
+	aaaaRecords, err := s.storage.GetRecords(ctx, "rrset.example.com", types.TYPE_AAAA)
+	assert.NoError(t, err)
+	assert.Len(t, aaaaRecords, 1)
+
+	// Records for another name or type must be rejected
+	err = s.storage.ReplaceRRSet(ctx, "rrset.example.com", types.TYPE_A, []records.DNSRecord{
+		mustBuild(t, records.NewBuilder().Name("other.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("10.0.0.1"))),
+	})
+	assert.ErrorIs(t, err, storage.ErrInvalidRecord)
+
+	err = s.storage.ReplaceRRSet(ctx, "rrset.example.com", types.TYPE_A, []records.DNSRecord{
+		mustBuild(t, records.NewBuilder().Name("rrset.example.com").Type(types.TYPE_AAAA).TTL(300).IPv6(net.ParseIP("2001:db8::2"))),
+	})
+	assert.ErrorIs(t, err, storage.ErrInvalidRecord)
+
+	// A failed replacement must not change the stored set
+	aRecords, err = s.storage.GetRecords(ctx, "rrset.example.com", types.TYPE_A)
+	assert.NoError(t, err)
+	assert.Len(t, aRecords, 3)
+
+	// An empty set removes the RRset
+	err = s.storage.ReplaceRRSet(ctx, "rrset.example.com", types.TYPE_A, nil)
+	assert.NoError(t, err)
+
+	_, err = s.storage.GetRecord(ctx, "rrset.example.com", types.TYPE_A)
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound)
+
+	// Cleanup
+	s.storage.DeleteRecord(ctx, "rrset.example.com", 0)
+}
+
+// TestTransactions tests staged changes, commit and rollback
+func (s *StorageTestSuite) TestTransactions() {
+	t := s.t
+	ctx := s.ctx
+
+	require.NoError(t, s.storage.PutRecord(ctx, mustBuild(t, records.NewBuilder().Name("tx.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1")))))
+
+	// Staged changes stay invisible until commit
+	tx, err := s.storage.Begin(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.DeleteRecord(ctx, "tx.example.com", types.TYPE_A))
+	require.NoError(t, tx.PutRecord(ctx, mustBuild(t, records.NewBuilder().Name("tx.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("10.0.0.1")))))
+	require.NoError(t, tx.PutRecord(ctx, mustBuild(t, records.NewBuilder().Name("tx.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("10.0.0.2")))))
+
+	aRecords, err := s.storage.GetRecords(ctx, "tx.example.com", types.TYPE_A)
+	assert.NoError(t, err)
+	assert.Len(t, aRecords, 1, "Staged changes must not be visible before commit")
+
+	require.NoError(t, tx.Commit(ctx))
+
+	aRecords, err = s.storage.GetRecords(ctx, "tx.example.com", types.TYPE_A)
+	assert.NoError(t, err)
+	assert.Len(t, aRecords, 2, "Commit should apply the delete and both inserts")
+
+	// A finished transaction cannot be reused
+	assert.ErrorIs(t, tx.Commit(ctx), storage.ErrTransactionDone)
+	assert.ErrorIs(t, tx.PutRecord(ctx, mustBuild(t, records.NewBuilder().Name("tx.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("10.0.0.3")))), storage.ErrTransactionDone)
+
+	// Rollback discards staged changes
+	tx, err = s.storage.Begin(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.DeleteRecord(ctx, "tx.example.com", 0))
+	require.NoError(t, tx.Rollback())
+	assert.ErrorIs(t, tx.Rollback(), storage.ErrTransactionDone)
+
+	aRecords, err = s.storage.GetRecords(ctx, "tx.example.com", types.TYPE_A)
+	assert.NoError(t, err)
+	assert.Len(t, aRecords, 2, "Rolled back delete must not be applied")
+
+	// Invalid records are rejected when staged
+	tx, err = s.storage.Begin(ctx)
+	require.NoError(t, err)
+	err = tx.PutRecord(ctx, mustBuild(t, records.NewBuilder().Name("invalid..example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("10.0.0.1"))))
+	assert.ErrorIs(t, err, storage.ErrInvalidRecord)
+	require.NoError(t, tx.Rollback())
+
+	// A batch with an invalid record stores nothing
+	err = s.storage.BatchPutRecords(ctx, []records.DNSRecord{
+		mustBuild(t, records.NewBuilder().Name("tx-batch.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("10.0.0.1"))),
+		mustBuild(t, records.NewBuilder().Name("invalid..example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("10.0.0.2"))),
+	})
+	assert.Error(t, err)
+
+	_, err = s.storage.GetRecord(ctx, "tx-batch.example.com", types.TYPE_A)
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound, "Failed batch must not leave partial records")
+
+	// Cleanup
+	s.storage.DeleteRecord(ctx, "tx.example.com", 0)
+}
+
 // TestZoneOperations tests zone-related functionality
 func (s *StorageTestSuite) TestZoneOperations() {
 	t := s.t
@@ -235,10 +360,10 @@ func (s *StorageTestSuite) TestZoneOperations() {
 
 	// Setup records in different zones
 	zoneRecords := []records.DNSRecord{
-		mustCreateARecord("host1.zone1.com", "192.168.1.1", 300),
-		mustCreateARecord("host2.zone1.com", "192.168.1.2", 300),
-		mustCreateARecord("host1.zone2.com", "192.168.2.1", 300),
-		mustCreateARecord("subdomain.host1.zone1.com", "192.168.1.11", 300),
+		mustBuild(t, records.NewBuilder().Name("host1.zone1.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1"))),
+		mustBuild(t, records.NewBuilder().Name("host2.zone1.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.2"))),
+		mustBuild(t, records.NewBuilder().Name("host1.zone2.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.2.1"))),
+		mustBuild(t, records.NewBuilder().Name("subdomain.host1.zone1.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.11"))),
 		records.NewCNAMERecord("www.zone1.com", "zone1.com", 300),
 	}
 
@@ -347,22 +472,126 @@ func (s *StorageTestSuite) TestEdgeCases() {
 	}
 }
 
+// TestViews tests that records of different views are kept apart
+func (s *StorageTestSuite) TestViews() {
+	t := s.t
+	ctx := s.ctx
+
+	public := mustBuild(t, records.NewBuilder().Name("split.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("203.0.113.10")))
+	internal := storage.NewViewRecord(mustBuild(t, records.NewBuilder().Name("split.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.10"))), "lan")
+
+	require.NoError(t, s.storage.PutRecord(ctx, public))
+	require.NoError(t, s.storage.PutRecord(ctx, internal))
+
+	all, err := s.storage.GetRecords(ctx, "split.example.com", types.TYPE_A)
+	require.NoError(t, err)
+	assert.Len(t, all, 2, "GetRecords should return the records of every view")
+
+	results, err := s.storage.QueryRecords(ctx, storage.QueryOptions{Name: "split.example.com", View: "lan"})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "lan", storage.RecordView(results[0]))
+	assert.Equal(t, []byte{192, 168, 1, 10}, results[0].Data())
+
+	results, err = s.storage.QueryRecords(ctx, storage.QueryOptions{Name: "split.example.com", View: storage.DefaultView})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, storage.DefaultView, storage.RecordView(results[0]))
+	assert.Equal(t, []byte{203, 0, 113, 10}, results[0].Data())
+
+	results, err = s.storage.QueryRecords(ctx, storage.QueryOptions{Name: "split.example.com", View: "guest"})
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	// Cleanup
+	require.NoError(t, s.storage.DeleteRecord(ctx, "split.example.com", types.TYPE_A))
+}
+
 // Helper functions
 
-func mustCreateARecord(name, ip string, ttl uint32) records.DNSRecord {
-	r, err := records.NewARecordFromString(name, ip, ttl)
-	if err != nil {
-		panic(err)
-	}
-	return r
+// TestCancellation tests that lookups fail with the error of a canceled context
+func (s *StorageTestSuite) TestCancellation() {
+	t := s.t
+
+	record, err := records.NewARecordFromString("cancel.example.com", "192.168.1.1", 300)
+	require.NoError(t, err)
+	require.NoError(t, s.storage.PutRecord(s.ctx, record))
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	cancel()
+
+	_, err = s.storage.GetRecords(ctx, "cancel.example.com", types.TYPE_A)
+	assert.ErrorIs(t, err, context.Canceled, "GetRecords should fail with a canceled context")
+	_, err = s.storage.QueryRecords(ctx, storage.QueryOptions{Zone: "example.com"})
+	assert.ErrorIs(t, err, context.Canceled, "QueryRecords should fail with a canceled context")
+	_, err = s.storage.ListRecords(ctx)
+	assert.ErrorIs(t, err, context.Canceled, "ListRecords should fail with a canceled context")
 }
 
-func mustCreateAAAARecord(name, ip string, ttl uint32) records.DNSRecord {
-	r, err := records.NewAAAARecordFromString(name, ip, ttl)
-	if err != nil {
-		panic(err)
+// TestSnapshots tests that restoring a zone snapshot undoes later changes
+// to the zone only
+func (s *StorageTestSuite) TestSnapshots() {
+	t := s.t
+	ctx := s.ctx
+
+	original := []records.DNSRecord{
+		mustBuild(t, records.NewBuilder().Name("www.snap.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.0.2.1"))),
+		mustBuild(t, records.NewBuilder().Name("www.snap.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.0.2.2"))),
+		records.NewMXRecord("snap.com", "mail.snap.com", 10, 300),
+		storage.NewViewRecord(mustBuild(t, records.NewBuilder().Name("lan.snap.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("10.0.0.1"))), "lan"),
+	}
+	other := records.NewMXRecord("other.com", "mail.other.com", 10, 300)
+	require.NoError(t, s.storage.BatchPutRecords(ctx, append([]records.DNSRecord{other}, original...)))
+
+	_, err := s.storage.SnapshotZone(ctx, "missing.com")
+	assert.ErrorIs(t, err, storage.ErrRecordNotFound, "a zone without records cannot be snapshotted")
+
+	id, err := s.storage.SnapshotZone(ctx, "snap.com")
+	require.NoError(t, err)
+	snapshots, err := s.storage.ListSnapshots(ctx, "snap.com")
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	assert.Equal(t, id, snapshots[0].ID)
+	assert.Equal(t, 4, snapshots[0].Records)
+	assert.False(t, snapshots[0].CreatedAt.IsZero())
+
+	// A bad bulk update: one address and the MX removed, a record added, a
+	// TTL changed, and a record of another zone changed
+	require.NoError(t, s.storage.ReplaceRRSet(ctx, "www.snap.com", types.TYPE_A, original[:1]))
+	require.NoError(t, s.storage.DeleteRecord(ctx, "snap.com", types.TYPE_MX))
+	require.NoError(t, s.storage.PutRecord(ctx, records.NewCNAMERecord("bad.snap.com", "www.snap.com", 300)))
+	require.NoError(t, s.storage.PutRecord(ctx, mustBuild(t, records.NewBuilder().Name("lan.snap.com").Type(types.TYPE_A).TTL(60).IPv4(net.ParseIP("10.0.0.1")))))
+	require.NoError(t, s.storage.ReplaceRRSet(ctx, "other.com", types.TYPE_MX, []records.DNSRecord{records.NewMXRecord("other.com", "mx.other.com", 20, 300)}))
+
+	require.NoError(t, s.storage.RestoreSnapshot(ctx, id))
+
+	restored, err := s.storage.ListRecordsByZone(ctx, "snap.com")
+	require.NoError(t, err)
+	assert.True(t, storage.DiffRecordSets(original, restored).Empty(), "zone should hold the snapshot records, got %v", restored)
+	otherRecords, err := s.storage.GetRecords(ctx, "other.com", types.TYPE_MX)
+	require.NoError(t, err)
+	require.Len(t, otherRecords, 1)
+	assert.Equal(t, "mx.other.com", otherRecords[0].(*records.MXRecord).MailServer(), "other zones should be left alone")
+
+	assert.ErrorIs(t, s.storage.RestoreSnapshot(ctx, "missing"), storage.ErrSnapshotNotFound)
+	require.NoError(t, s.storage.DeleteSnapshot(ctx, id))
+	assert.ErrorIs(t, s.storage.DeleteSnapshot(ctx, id), storage.ErrSnapshotNotFound)
+	snapshots, err = s.storage.ListSnapshots(ctx, "snap.com")
+	require.NoError(t, err)
+	assert.Empty(t, snapshots)
+
+	// Cleanup
+	for _, name := range []string{"www.snap.com", "snap.com", "lan.snap.com", "other.com"} {
+		s.storage.DeleteRecord(ctx, name, 0)
 	}
-	return r
+}
+
+func mustBuild(t *testing.T, builder *records.Builder) records.DNSRecord {
+	t.Helper()
+
+	record, err := builder.Build()
+	require.NoError(t, err)
+	return record
 }
 
 // testRecord is a minimal DNSRecord implementation for testing
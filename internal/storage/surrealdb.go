@@ -1,23 +1,55 @@
 package storage
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	surrealdb "github.com/surrealdb/surrealdb.go"
+	"github.com/surrealdb/surrealdb.go/pkg/connection"
 	"github.com/vadim-su/dnska/pkg/dns/records"
 	"github.com/vadim-su/dnska/pkg/dns/types"
 )
 
-// SurrealDBStorage implements the Storage interface using SurrealDB as the backend
+// Connection states of SurrealDBStorage
+const (
+	surrealConnected int32 = iota
+	surrealReconnecting
+	surrealClosed
+)
+
+// Default delays between reconnection attempts
+const (
+	defaultReconnectDelay    = time.Second
+	defaultMaxReconnectDelay = 60 * time.Second
+)
+
+// SurrealDBStorage implements the Storage interface using SurrealDB as the
+// backend. When the connection is lost it reconnects in the background,
+// answering GetRecords from the last known records meanwhile
 type SurrealDBStorage struct {
-	db        *surrealdb.DB
 	validator *Validator
 	converter *RecordConverter
 	config    *SurrealDBConfig
-	closed    bool
+	notifier  *changeNotifier
+	state     atomic.Int32 // One of the surreal* connection states
+
+	dbMu sync.RWMutex
+	db   *surrealdb.DB // Replaced when reconnecting
+
+	ctx    context.Context // Canceled by Close to stop reconnecting
+	cancel context.CancelFunc
+
+	lastKnownMu sync.RWMutex
+	lastKnown   map[string][]records.DNSRecord // GetRecords results by name and type
+
+	liveMu sync.Mutex
+	liveID string // Live query feeding the notifier, empty until the first Subscribe
 }
 
 // SurrealDBConfig holds configuration for SurrealDB connection
@@ -33,6 +65,12 @@ type SurrealDBConfig struct {
 	Password string
 	// Optional: Access method for record-based authentication
 	Access string
+	// Optional: opens the connection in place of EndpointURL, e.g. to wrap it
+	Dial func(ctx context.Context) (connection.Connection, error)
+	// Delay before the first reconnection attempt after the connection is
+	// lost, doubling up to MaxReconnectDelay. Zero uses 1s and 60s
+	ReconnectDelay    time.Duration
+	MaxReconnectDelay time.Duration
 	// Validation configuration
 	ValidationConfig *ValidationConfig
 }
@@ -85,7 +123,44 @@ func NewSurrealDBStorageWithConfig(ctx context.Context, config *SurrealDBConfig)
 		return nil, fmt.Errorf("config is required")
 	}
 
-	db, err := surrealdb.FromEndpointURLString(ctx, config.EndpointURL)
+	db, err := connectSurrealDB(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	storage := &SurrealDBStorage{
+		db:        db,
+		validator: NewValidator(config.ValidationConfig),
+		converter: NewRecordConverter(),
+		config:    config,
+		notifier:  newChangeNotifier(),
+		lastKnown: make(map[string][]records.DNSRecord),
+	}
+	storage.ctx, storage.cancel = context.WithCancel(context.Background())
+
+	// Initialize the schema
+	if err := storage.initSchema(ctx); err != nil {
+		storage.cancel()
+		db.Close(ctx)
+		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+	}
+
+	return storage, nil
+}
+
+// connectSurrealDB opens a connection, selects the namespace and database
+// and signs in when credentials are configured
+func connectSurrealDB(ctx context.Context, config *SurrealDBConfig) (*surrealdb.DB, error) {
+	var db *surrealdb.DB
+	var err error
+	if config.Dial != nil {
+		var conn connection.Connection
+		if conn, err = config.Dial(ctx); err == nil {
+			db, err = surrealdb.FromConnection(ctx, conn)
+		}
+	} else {
+		db, err = surrealdb.FromEndpointURLString(ctx, config.EndpointURL)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to SurrealDB: %w", err)
 	}
@@ -115,23 +190,151 @@ func NewSurrealDBStorageWithConfig(ctx context.Context, config *SurrealDBConfig)
 		}
 	}
 
-	storage := &SurrealDBStorage{
-		db:        db,
-		validator: NewValidator(config.ValidationConfig),
-		converter: NewRecordConverter(),
-		config:    config,
+	return db, nil
+}
+
+// surrealQuery runs sql on the current connection. A lost connection starts
+// reconnecting and is reported as ErrStorageUnavailable. The result of a
+// query that finished after ctx was done is dropped
+func surrealQuery[TResult any](ctx context.Context, s *SurrealDBStorage, sql string, vars map[string]any) (*[]surrealdb.QueryResult[TResult], error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	db, err := s.conn()
+	if err != nil {
+		return nil, err
 	}
 
-	// Initialize the schema
-	if err := storage.initSchema(ctx); err != nil {
-		db.Close(ctx)
-		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+	result, err := surrealdb.Query[TResult](ctx, db, sql, vars)
+	if err != nil && isConnectionError(err) {
+		s.startReconnect()
+		return nil, fmt.Errorf("%w: %v", ErrStorageUnavailable, err)
 	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return result, err
+}
 
-	return storage, nil
+// conn returns the current connection unless the storage is closed or
+// reconnecting
+func (s *SurrealDBStorage) conn() (*surrealdb.DB, error) {
+	switch s.state.Load() {
+	case surrealClosed:
+		return nil, ErrStorageClosed
+	case surrealReconnecting:
+		return nil, ErrStorageUnavailable
+	}
+
+	s.dbMu.RLock()
+	defer s.dbMu.RUnlock()
+	return s.db, nil
+}
+
+// isConnectionError reports whether err means the connection was lost
+// rather than SurrealDB rejecting the request or the caller giving up
+func isConnectionError(err error) bool {
+	var rpcErr *surrealdb.RPCError
+	var queryErr *surrealdb.QueryError
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.As(err, &rpcErr), errors.As(err, &queryErr):
+		return false
+	}
+	return true
+}
+
+// startReconnect starts the reconnect loop unless it is already running
+func (s *SurrealDBStorage) startReconnect() {
+	if !s.state.CompareAndSwap(surrealConnected, surrealReconnecting) {
+		return
+	}
+	logger.Warn("Lost the connection to SurrealDB, reconnecting")
+	go s.reconnect()
+}
+
+// reconnect replaces the lost connection, waiting between the attempts
+// twice as long as before up to the maximum delay, until it succeeds or
+// the storage is closed
+func (s *SurrealDBStorage) reconnect() {
+	s.dbMu.Lock()
+	lost := s.db
+	s.dbMu.Unlock()
+	lost.Close(context.Background())
+
+	delay := cmp.Or(s.config.ReconnectDelay, defaultReconnectDelay)
+	maxDelay := cmp.Or(s.config.MaxReconnectDelay, defaultMaxReconnectDelay)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		db, err := connectSurrealDB(s.ctx, s.config)
+		if err != nil {
+			delay = min(2*delay, maxDelay)
+			timer.Reset(delay)
+			continue
+		}
+
+		s.dbMu.Lock()
+		s.db = db
+		s.dbMu.Unlock()
+		if !s.state.CompareAndSwap(surrealReconnecting, surrealConnected) {
+			// Closed while connecting
+			db.Close(context.Background())
+			return
+		}
+		logger.Info("Reconnected to SurrealDB")
+
+		// The live query ended with the lost connection
+		s.liveMu.Lock()
+		subscribed := s.liveID != ""
+		s.liveID = ""
+		s.liveMu.Unlock()
+		if subscribed {
+			if err := s.startLiveQuery(s.ctx); err != nil {
+				logger.Error("Failed to restart the SurrealDB live query", "error", err)
+			}
+		}
+		return
+	}
+}
+
+// rememberRecords keeps the records GetRecords found for name and type
+// to answer with while reconnecting
+func (s *SurrealDBStorage) rememberRecords(name string, recordType types.DNSType, recordsList []records.DNSRecord) {
+	if len(recordsList) == 0 {
+		return
+	}
+	s.lastKnownMu.Lock()
+	s.lastKnown[fmt.Sprintf("%s/%d", name, recordType)] = recordsList
+	s.lastKnownMu.Unlock()
+}
+
+// lastKnownRecords returns the records GetRecords last found for name and
+// type, if any
+func (s *SurrealDBStorage) lastKnownRecords(name string, recordType types.DNSType) ([]records.DNSRecord, bool) {
+	s.lastKnownMu.RLock()
+	defer s.lastKnownMu.RUnlock()
+	recordsList, ok := s.lastKnown[fmt.Sprintf("%s/%d", name, recordType)]
+	return recordsList, ok
+}
+
+// forgetRecords drops the last known records after a change
+func (s *SurrealDBStorage) forgetRecords() {
+	s.lastKnownMu.Lock()
+	clear(s.lastKnown)
+	s.lastKnownMu.Unlock()
 }
 
-// initSchema creates the necessary tables and indexes for DNS records
+// initSchema creates the necessary tables and indexes for DNS records and
+// zone snapshots
 func (s *SurrealDBStorage) initSchema(ctx context.Context) error {
 	schemaQueries := []string{
 		// Define the dns_records table
@@ -144,18 +347,31 @@ func (s *SurrealDBStorage) initSchema(ctx context.Context) error {
 		`DEFINE FIELD IF NOT EXISTS ttl ON dns_records TYPE int;`,
 		`DEFINE FIELD IF NOT EXISTS data ON dns_records TYPE string;`,
 		`DEFINE FIELD IF NOT EXISTS zone ON dns_records TYPE string;`,
+		`DEFINE FIELD IF NOT EXISTS view ON dns_records TYPE string DEFAULT "";`,
 		`DEFINE FIELD IF NOT EXISTS created_at ON dns_records TYPE datetime DEFAULT time::now();`,
 		`DEFINE FIELD IF NOT EXISTS updated_at ON dns_records TYPE datetime DEFAULT time::now();`,
 
 		// Define indexes for efficient querying
-		`DEFINE INDEX IF NOT EXISTS name_type_idx ON dns_records FIELDS name, record_type UNIQUE;`,
+		// Each view holds its own version of a record
+		`REMOVE INDEX IF EXISTS name_type_idx ON dns_records;`,
+		`DEFINE INDEX IF NOT EXISTS name_type_view_idx ON dns_records FIELDS name, record_type, view UNIQUE;`,
 		`DEFINE INDEX IF NOT EXISTS zone_idx ON dns_records FIELDS zone;`,
 		`DEFINE INDEX IF NOT EXISTS name_idx ON dns_records FIELDS name;`,
 		`DEFINE INDEX IF NOT EXISTS type_idx ON dns_records FIELDS record_type;`,
+
+		// Define the dns_zone_snapshots table holding zone snapshots
+		`DEFINE TABLE IF NOT EXISTS dns_zone_snapshots SCHEMAFULL;`,
+		`DEFINE FIELD IF NOT EXISTS snapshot_id ON dns_zone_snapshots TYPE string;`,
+		`DEFINE FIELD IF NOT EXISTS zone ON dns_zone_snapshots TYPE string;`,
+		`DEFINE FIELD IF NOT EXISTS records ON dns_zone_snapshots TYPE int;`,
+		`DEFINE FIELD IF NOT EXISTS data ON dns_zone_snapshots TYPE string;`,
+		`DEFINE FIELD IF NOT EXISTS created_at ON dns_zone_snapshots TYPE datetime DEFAULT time::now();`,
+		`DEFINE INDEX IF NOT EXISTS snapshot_id_idx ON dns_zone_snapshots FIELDS snapshot_id UNIQUE;`,
+		`DEFINE INDEX IF NOT EXISTS snapshot_zone_idx ON dns_zone_snapshots FIELDS zone;`,
 	}
 
 	for _, query := range schemaQueries {
-		if _, err := surrealdb.Query[any](ctx, s.db, query, nil); err != nil {
+		if _, err := surrealQuery[any](ctx, s, query, nil); err != nil {
 			return err
 		}
 	}
@@ -165,7 +381,7 @@ func (s *SurrealDBStorage) initSchema(ctx context.Context) error {
 
 // GetRecords returns all records for a given domain name and record type
 func (s *SurrealDBStorage) GetRecords(ctx context.Context, name string, recordType types.DNSType) ([]records.DNSRecord, error) {
-	if s.closed {
+	if s.state.Load() == surrealClosed {
 		return nil, ErrStorageClosed
 	}
 
@@ -188,8 +404,13 @@ func (s *SurrealDBStorage) GetRecords(ctx context.Context, name string, recordTy
 		vars["record_type"] = int(recordType)
 	}
 
-	result, err := surrealdb.Query[[]SurrealDBRecord](ctx, s.db, query, vars)
+	result, err := surrealQuery[[]SurrealDBRecord](ctx, s, query, vars)
 	if err != nil {
+		if errors.Is(err, ErrStorageUnavailable) {
+			if recordsList, ok := s.lastKnownRecords(name, recordType); ok {
+				return recordsList, nil
+			}
+		}
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
 
@@ -197,12 +418,17 @@ func (s *SurrealDBStorage) GetRecords(ctx context.Context, name string, recordTy
 		return []records.DNSRecord{}, nil
 	}
 
-	return s.convertToRecords((*result)[0].Result)
+	recordsList, err := s.convertToRecords((*result)[0].Result)
+	if err != nil {
+		return nil, err
+	}
+	s.rememberRecords(name, recordType, recordsList)
+	return recordsList, nil
 }
 
 // GetRecord returns a single record for a given domain name and record type
 func (s *SurrealDBStorage) GetRecord(ctx context.Context, name string, recordType types.DNSType) (records.DNSRecord, error) {
-	if s.closed {
+	if s.state.Load() == surrealClosed {
 		return nil, ErrStorageClosed
 	}
 
@@ -219,7 +445,7 @@ func (s *SurrealDBStorage) GetRecord(ctx context.Context, name string, recordTyp
 		"record_type": int(recordType),
 	}
 
-	result, err := surrealdb.Query[[]SurrealDBRecord](ctx, s.db, query, vars)
+	result, err := surrealQuery[[]SurrealDBRecord](ctx, s, query, vars)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -238,7 +464,7 @@ func (s *SurrealDBStorage) GetRecord(ctx context.Context, name string, recordTyp
 
 // PutRecord stores or updates a DNS record with validation
 func (s *SurrealDBStorage) PutRecord(ctx context.Context, record records.DNSRecord) error {
-	if s.closed {
+	if s.state.Load() == surrealClosed {
 		return ErrStorageClosed
 	}
 
@@ -260,29 +486,32 @@ func (s *SurrealDBStorage) PutRecord(ctx context.Context, record records.DNSReco
 		    ttl = $ttl,
 		    data = $data,
 		    zone = $zone,
+		    view = $view,
 		    updated_at = time::now()
-		WHERE name = $name AND record_type = $record_type
+		WHERE name = $name AND record_type = $record_type AND view = $view
 	`
 
-	_, err = surrealdb.Query[any](ctx, s.db, query, map[string]any{
+	_, err = surrealQuery[any](ctx, s, query, map[string]any{
 		"name":        recordData.Name,
 		"record_type": recordData.RecordType,
 		"class":       recordData.Class,
 		"ttl":         recordData.TTL,
 		"data":        recordData.Data,
 		"zone":        recordData.Zone,
+		"view":        recordData.View,
 	})
 
 	if err != nil {
 		return fmt.Errorf("failed to store record: %w", err)
 	}
+	s.forgetRecords()
 
 	return nil
 }
 
 // DeleteRecord removes a DNS record
 func (s *SurrealDBStorage) DeleteRecord(ctx context.Context, name string, recordType types.DNSType) error {
-	if s.closed {
+	if s.state.Load() == surrealClosed {
 		return ErrStorageClosed
 	}
 
@@ -305,23 +534,24 @@ func (s *SurrealDBStorage) DeleteRecord(ctx context.Context, name string, record
 		vars["record_type"] = int(recordType)
 	}
 
-	_, err := surrealdb.Query[any](ctx, s.db, query, vars)
+	_, err := surrealQuery[any](ctx, s, query, vars)
 	if err != nil {
 		return fmt.Errorf("delete failed: %w", err)
 	}
+	s.forgetRecords()
 
 	return nil
 }
 
 // ListRecords returns all records in the storage
 func (s *SurrealDBStorage) ListRecords(ctx context.Context) ([]records.DNSRecord, error) {
-	if s.closed {
+	if s.state.Load() == surrealClosed {
 		return nil, ErrStorageClosed
 	}
 
 	query := "SELECT * FROM dns_records ORDER BY name, record_type"
 
-	result, err := surrealdb.Query[[]SurrealDBRecord](ctx, s.db, query, nil)
+	result, err := surrealQuery[[]SurrealDBRecord](ctx, s, query, nil)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -335,7 +565,7 @@ func (s *SurrealDBStorage) ListRecords(ctx context.Context) ([]records.DNSRecord
 
 // ListRecordsByZone returns all records for a specific zone
 func (s *SurrealDBStorage) ListRecordsByZone(ctx context.Context, zone string) ([]records.DNSRecord, error) {
-	if s.closed {
+	if s.state.Load() == surrealClosed {
 		return nil, ErrStorageClosed
 	}
 
@@ -351,7 +581,7 @@ func (s *SurrealDBStorage) ListRecordsByZone(ctx context.Context, zone string) (
 		"zone": zone,
 	}
 
-	result, err := surrealdb.Query[[]SurrealDBRecord](ctx, s.db, query, vars)
+	result, err := surrealQuery[[]SurrealDBRecord](ctx, s, query, vars)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -365,7 +595,7 @@ func (s *SurrealDBStorage) ListRecordsByZone(ctx context.Context, zone string) (
 
 // GetZones returns all available zones
 func (s *SurrealDBStorage) GetZones(ctx context.Context) ([]string, error) {
-	if s.closed {
+	if s.state.Load() == surrealClosed {
 		return nil, ErrStorageClosed
 	}
 
@@ -375,7 +605,7 @@ func (s *SurrealDBStorage) GetZones(ctx context.Context) ([]string, error) {
 		Zone string `json:"zone"`
 	}
 
-	result, err := surrealdb.Query[[]ZoneResult](ctx, s.db, query, nil)
+	result, err := surrealQuery[[]ZoneResult](ctx, s, query, nil)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -394,7 +624,7 @@ func (s *SurrealDBStorage) GetZones(ctx context.Context) ([]string, error) {
 
 // QueryRecords performs a filtered query with optional pagination
 func (s *SurrealDBStorage) QueryRecords(ctx context.Context, options QueryOptions) ([]records.DNSRecord, error) {
-	if s.closed {
+	if s.state.Load() == surrealClosed {
 		return nil, ErrStorageClosed
 	}
 
@@ -423,6 +653,11 @@ func (s *SurrealDBStorage) QueryRecords(ctx context.Context, options QueryOption
 		vars["zone"] = strings.ToLower(options.Zone)
 	}
 
+	if options.View != "" {
+		conditions = append(conditions, "view = $view")
+		vars["view"] = storedView(options.View)
+	}
+
 	// Add WHERE clause if conditions exist
 	if len(conditions) > 0 {
 		query += " WHERE " + strings.Join(conditions, " AND ")
@@ -451,7 +686,7 @@ func (s *SurrealDBStorage) QueryRecords(ctx context.Context, options QueryOption
 		}
 	}
 
-	result, err := surrealdb.Query[[]SurrealDBRecord](ctx, s.db, query, vars)
+	result, err := surrealQuery[[]SurrealDBRecord](ctx, s, query, vars)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -463,9 +698,10 @@ func (s *SurrealDBStorage) QueryRecords(ctx context.Context, options QueryOption
 	return s.convertToRecords((*result)[0].Result)
 }
 
-// BatchPutRecords stores multiple records in a single operation
+// BatchPutRecords stores multiple records in a single transaction. A record
+// failing validation rolls back the whole batch
 func (s *SurrealDBStorage) BatchPutRecords(ctx context.Context, recordsList []records.DNSRecord) error {
-	if s.closed {
+	if s.state.Load() == surrealClosed {
 		return ErrStorageClosed
 	}
 
@@ -473,39 +709,19 @@ func (s *SurrealDBStorage) BatchPutRecords(ctx context.Context, recordsList []re
 		return nil
 	}
 
-	// Validate all records first
-	if errs := s.validator.ValidateBatch(recordsList); len(errs) > 0 {
-		return fmt.Errorf("validation failed: %v", errs[0])
-	}
-
-	// Convert records to storage format
-	recordsData, err := s.converter.BatchToStorageFormat(recordsList)
+	tx, err := s.Begin(ctx)
 	if err != nil {
 		return err
 	}
 
-	// Build batch insert data
-	insertData := make([]map[string]any, len(recordsData))
-	for i, data := range recordsData {
-		insertData[i] = map[string]any{
-			"name":        data.Name,
-			"record_type": data.RecordType,
-			"class":       data.Class,
-			"ttl":         data.TTL,
-			"data":        data.Data,
-			"zone":        data.Zone,
-			"created_at":  "time::now()",
-			"updated_at":  "time::now()",
+	for _, record := range recordsList {
+		if err := tx.PutRecord(ctx, record); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("validation failed: %w", err)
 		}
 	}
 
-	query := "INSERT INTO dns_records $records ON DUPLICATE KEY UPDATE data = $input.data, ttl = $input.ttl, updated_at = time::now()"
-	vars := map[string]any{
-		"records": insertData,
-	}
-
-	_, err = surrealdb.Query[any](ctx, s.db, query, vars)
-	if err != nil {
+	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("batch insert failed: %w", err)
 	}
 
@@ -514,7 +730,7 @@ func (s *SurrealDBStorage) BatchPutRecords(ctx context.Context, recordsList []re
 
 // BatchDeleteRecords deletes multiple records in a single operation
 func (s *SurrealDBStorage) BatchDeleteRecords(ctx context.Context, names []string, recordType types.DNSType) error {
-	if s.closed {
+	if s.state.Load() == surrealClosed {
 		return ErrStorageClosed
 	}
 
@@ -538,26 +754,319 @@ func (s *SurrealDBStorage) BatchDeleteRecords(ctx context.Context, names []strin
 		vars["record_type"] = int(recordType)
 	}
 
-	_, err := surrealdb.Query[any](ctx, s.db, query, vars)
+	_, err := surrealQuery[any](ctx, s, query, vars)
 	if err != nil {
 		return fmt.Errorf("batch delete failed: %w", err)
 	}
+	s.forgetRecords()
 
 	return nil
 }
 
+// ReplaceRRSet atomically replaces all records of the given name and type
+// inside a single SurrealDB transaction
+func (s *SurrealDBStorage) ReplaceRRSet(ctx context.Context, name string, recordType types.DNSType, recordsList []records.DNSRecord) error {
+	if s.state.Load() == surrealClosed {
+		return ErrStorageClosed
+	}
+
+	// Validate the whole set before touching any state
+	if err := s.validator.ValidateRRSet(name, recordType, recordsList); err != nil {
+		return err
+	}
+
+	recordsData, err := s.converter.BatchToStorageFormat(recordsList)
+	if err != nil {
+		return err
+	}
+
+	insertData := make([]map[string]any, len(recordsData))
+	for i, data := range recordsData {
+		insertData[i] = map[string]any{
+			"name":        data.Name,
+			"record_type": data.RecordType,
+			"class":       data.Class,
+			"ttl":         data.TTL,
+			"data":        data.Data,
+			"zone":        data.Zone,
+			"view":        data.View,
+		}
+	}
+
+	query := `
+		BEGIN TRANSACTION;
+		DELETE FROM dns_records WHERE name = $name AND record_type = $record_type;
+		IF array::len($records) > 0 {
+			INSERT INTO dns_records $records;
+		};
+		COMMIT TRANSACTION;
+	`
+
+	_, err = surrealQuery[any](ctx, s, query, map[string]any{
+		"name":        normalizeDomainName(name),
+		"record_type": int(recordType),
+		"records":     insertData,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to replace RRset: %w", err)
+	}
+	s.forgetRecords()
+
+	return nil
+}
+
+// SurrealDBSnapshot represents a zone snapshot in SurrealDB format
+type SurrealDBSnapshot struct {
+	SnapshotID string    `json:"snapshot_id"`
+	Zone       string    `json:"zone"`
+	Records    int       `json:"records"`
+	Data       string    `json:"data,omitempty"`
+	CreatedAt  time.Time `json:"created_at,omitempty"`
+}
+
+// SnapshotZone saves the records of zone as a JSON blob in the
+// dns_zone_snapshots table
+func (s *SurrealDBStorage) SnapshotZone(ctx context.Context, zone string) (string, error) {
+	recordList, err := s.ListRecordsByZone(ctx, zone)
+	if err != nil {
+		return "", err
+	}
+	if len(recordList) == 0 {
+		return "", ErrRecordNotFound
+	}
+
+	blob, err := encodeSnapshot(s.converter, recordList)
+	if err != nil {
+		return "", err
+	}
+	id, err := newSnapshotID()
+	if err != nil {
+		return "", err
+	}
+
+	query := "CREATE dns_zone_snapshots SET snapshot_id = $snapshot_id, zone = $zone, records = $records, data = $data"
+	vars := map[string]any{
+		"snapshot_id": id,
+		"zone":        strings.ToLower(zone),
+		"records":     len(recordList),
+		"data":        string(blob),
+	}
+	if _, err := surrealQuery[any](ctx, s, query, vars); err != nil {
+		return "", fmt.Errorf("failed to store snapshot: %w", err)
+	}
+	return id, nil
+}
+
+// RestoreSnapshot replaces the records of the zone of a snapshot with those
+// saved in it, in one transaction
+func (s *SurrealDBStorage) RestoreSnapshot(ctx context.Context, snapshotID string) error {
+	if s.state.Load() == surrealClosed {
+		return ErrStorageClosed
+	}
+
+	query := "SELECT * FROM dns_zone_snapshots WHERE snapshot_id = $snapshot_id"
+	result, err := surrealQuery[[]SurrealDBSnapshot](ctx, s, query, map[string]any{"snapshot_id": snapshotID})
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+	if len(*result) == 0 || len((*result)[0].Result) == 0 {
+		return ErrSnapshotNotFound
+	}
+	snapshot := (*result)[0].Result[0]
+
+	recordList, err := decodeSnapshot(s.converter, []byte(snapshot.Data))
+	if err != nil {
+		return err
+	}
+	return restoreZone(ctx, s, snapshot.Zone, recordList)
+}
+
+// ListSnapshots returns the snapshots of zone, oldest first
+func (s *SurrealDBStorage) ListSnapshots(ctx context.Context, zone string) ([]SnapshotInfo, error) {
+	if s.state.Load() == surrealClosed {
+		return nil, ErrStorageClosed
+	}
+	if err := s.validator.ValidateZone(zone); err != nil {
+		return nil, err
+	}
+
+	query := "SELECT snapshot_id, zone, records, created_at FROM dns_zone_snapshots WHERE zone = $zone ORDER BY created_at"
+	result, err := surrealQuery[[]SurrealDBSnapshot](ctx, s, query, map[string]any{"zone": strings.ToLower(zone)})
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	snapshots := []SnapshotInfo{}
+	if len(*result) == 0 {
+		return snapshots, nil
+	}
+	for _, snapshot := range (*result)[0].Result {
+		snapshots = append(snapshots, SnapshotInfo{
+			ID:        snapshot.SnapshotID,
+			Zone:      snapshot.Zone,
+			Records:   snapshot.Records,
+			CreatedAt: snapshot.CreatedAt,
+		})
+	}
+	return snapshots, nil
+}
+
+// DeleteSnapshot removes a snapshot from the dns_zone_snapshots table
+func (s *SurrealDBStorage) DeleteSnapshot(ctx context.Context, snapshotID string) error {
+	if s.state.Load() == surrealClosed {
+		return ErrStorageClosed
+	}
+
+	query := "DELETE FROM dns_zone_snapshots WHERE snapshot_id = $snapshot_id RETURN BEFORE"
+	result, err := surrealQuery[[]SurrealDBSnapshot](ctx, s, query, map[string]any{"snapshot_id": snapshotID})
+	if err != nil {
+		return fmt.Errorf("delete failed: %w", err)
+	}
+	if len(*result) == 0 || len((*result)[0].Result) == 0 {
+		return ErrSnapshotNotFound
+	}
+	return nil
+}
+
+// Begin starts a transaction whose statements are sent to SurrealDB wrapped
+// in BEGIN/COMMIT TRANSACTION when it is committed
+func (s *SurrealDBStorage) Begin(ctx context.Context) (Transaction, error) {
+	if s.state.Load() == surrealClosed {
+		return nil, ErrStorageClosed
+	}
+
+	return &surrealTransaction{storage: s, vars: map[string]any{}}, nil
+}
+
+// Ping checks that SurrealDB answers requests
+func (s *SurrealDBStorage) Ping(ctx context.Context) error {
+	if s.state.Load() == surrealClosed {
+		return ErrStorageClosed
+	}
+
+	db, err := s.conn()
+	if err != nil {
+		return err
+	}
+	if _, err := db.Version(ctx); err != nil {
+		if isConnectionError(err) {
+			s.startReconnect()
+		}
+		return fmt.Errorf("ping failed: %w", err)
+	}
+	return nil
+}
+
 // Close closes the storage connection and cleans up resources
 func (s *SurrealDBStorage) Close() error {
-	if s.closed {
+	previous := s.state.Swap(surrealClosed)
+	if previous == surrealClosed {
 		return nil
 	}
+	s.cancel()
+	s.notifier.close()
+
+	// The reconnect loop closes the lost connection and any new one
+	if previous == surrealReconnecting {
+		return nil
+	}
+
+	s.dbMu.RLock()
+	db := s.db
+	s.dbMu.RUnlock()
+
+	s.liveMu.Lock()
+	if s.liveID != "" {
+		surrealdb.Kill(context.Background(), db, s.liveID)
+	}
+	s.liveMu.Unlock()
+
+	return db.Close(context.Background())
+}
+
+// Subscribe returns a channel receiving an event for every change to the
+// dns_records table, including changes made by other instances. A single
+// live query is started on first use and shared by all subscribers
+func (s *SurrealDBStorage) Subscribe(ctx context.Context) (<-chan ChangeEvent, error) {
+	if s.state.Load() == surrealClosed {
+		return nil, ErrStorageClosed
+	}
 
-	s.closed = true
-	return s.db.Close(context.Background())
+	if err := s.startLiveQuery(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.notifier.subscribe(ctx)
+}
+
+// startLiveQuery starts the live query on dns_records unless it is running
+func (s *SurrealDBStorage) startLiveQuery(ctx context.Context) error {
+	s.liveMu.Lock()
+	defer s.liveMu.Unlock()
+
+	if s.liveID != "" {
+		return nil
+	}
+
+	db, err := s.conn()
+	if err != nil {
+		return err
+	}
+
+	live, err := surrealdb.Live(ctx, db, "dns_records", false)
+	if err != nil {
+		return fmt.Errorf("failed to start live query: %w", err)
+	}
+
+	notifications, err := db.LiveNotifications(live.String())
+	if err != nil {
+		surrealdb.Kill(ctx, db, live.String())
+		return fmt.Errorf("failed to receive live query notifications: %w", err)
+	}
+
+	s.liveID = live.String()
+	go s.publishNotifications(notifications)
+
+	return nil
+}
+
+// publishNotifications turns live query notifications into change events
+// until the live query is killed
+func (s *SurrealDBStorage) publishNotifications(notifications chan connection.Notification) {
+	for notification := range notifications {
+		record, ok := notification.Result.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		operation := ChangeOperationPut
+		if notification.Action == connection.DeleteAction {
+			operation = ChangeOperationDelete
+		}
+
+		name, _ := record["name"].(string)
+		zone, _ := record["zone"].(string)
+		s.notifier.publish(newChangeEvent(operation, name, liveRecordType(record["record_type"]), zone))
+	}
 }
 
 // Helper types and methods
 
+// liveRecordType reads the record type of a live query result, which may be
+// decoded as either a signed or an unsigned integer
+func liveRecordType(value any) types.DNSType {
+	switch v := value.(type) {
+	case uint64:
+		return types.DNSType(v)
+	case int64:
+		return types.DNSType(v)
+	case float64:
+		return types.DNSType(v)
+	default:
+		return 0
+	}
+}
+
 // SurrealDBRecord represents a DNS record in SurrealDB format
 type SurrealDBRecord struct {
 	ID         any       `json:"id,omitempty"`
@@ -567,6 +1076,7 @@ type SurrealDBRecord struct {
 	TTL        uint32    `json:"ttl"`
 	Data       string    `json:"data"`
 	Zone       string    `json:"zone"`
+	View       string    `json:"view,omitempty"`
 	CreatedAt  time.Time `json:"created_at,omitempty"`
 	UpdatedAt  time.Time `json:"updated_at,omitempty"`
 }
@@ -585,6 +1095,7 @@ func (s *SurrealDBStorage) convertToRecords(data []SurrealDBRecord) ([]records.D
 			TTL:        surrealRecord.TTL,
 			Data:       surrealRecord.Data,
 			Zone:       surrealRecord.Zone,
+			View:       surrealRecord.View,
 			CreatedAt:  surrealRecord.CreatedAt,
 			UpdatedAt:  surrealRecord.UpdatedAt,
 		}
@@ -602,7 +1113,7 @@ func (s *SurrealDBStorage) convertToRecords(data []SurrealDBRecord) ([]records.D
 
 // GetStats returns storage statistics (if implemented)
 func (s *SurrealDBStorage) GetStats(ctx context.Context) (*StorageStats, error) {
-	if s.closed {
+	if s.state.Load() == surrealClosed {
 		return nil, ErrStorageClosed
 	}
 
@@ -617,7 +1128,7 @@ func (s *SurrealDBStorage) GetStats(ctx context.Context) (*StorageStats, error)
 		Count int `json:"count"`
 	}
 
-	countRes, err := surrealdb.Query[[]CountResult](ctx, s.db, countQuery, nil)
+	countRes, err := surrealQuery[[]CountResult](ctx, s, countQuery, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get record count: %w", err)
 	}
@@ -628,7 +1139,7 @@ func (s *SurrealDBStorage) GetStats(ctx context.Context) (*StorageStats, error)
 
 	// Count zones
 	zoneCountQuery := "SELECT COUNT(DISTINCT zone) as count FROM dns_records WHERE zone IS NOT NULL"
-	zoneRes, err := surrealdb.Query[[]CountResult](ctx, s.db, zoneCountQuery, nil)
+	zoneRes, err := surrealQuery[[]CountResult](ctx, s, zoneCountQuery, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get zone count: %w", err)
 	}
@@ -644,7 +1155,7 @@ func (s *SurrealDBStorage) GetStats(ctx context.Context) (*StorageStats, error)
 		Count      int `json:"count"`
 	}
 
-	typeRes, err := surrealdb.Query[[]TypeCount](ctx, s.db, typeQuery, nil)
+	typeRes, err := surrealQuery[[]TypeCount](ctx, s, typeQuery, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get type counts: %w", err)
 	}
@@ -659,6 +1170,114 @@ func (s *SurrealDBStorage) GetStats(ctx context.Context) (*StorageStats, error)
 	return stats, nil
 }
 
+// surrealTransaction collects statements for a single SurrealDB transaction.
+// Every statement gets its own set of variables, suffixed with its index.
+// It is not safe for concurrent use
+type surrealTransaction struct {
+	storage    *SurrealDBStorage
+	statements []string
+	vars       map[string]any
+	done       bool
+}
+
+// PutRecord validates a record and stages its upsert
+func (tx *surrealTransaction) PutRecord(ctx context.Context, record records.DNSRecord) error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+
+	if err := tx.storage.validator.ValidateRecord(record); err != nil {
+		return err
+	}
+
+	recordData, err := tx.storage.converter.ToStorageFormat(record)
+	if err != nil {
+		return err
+	}
+
+	i := len(tx.statements)
+	tx.statements = append(tx.statements, fmt.Sprintf(`
+		UPSERT dns_records
+		SET name = $name_%[1]d,
+		    record_type = $record_type_%[1]d,
+		    class = $class_%[1]d,
+		    ttl = $ttl_%[1]d,
+		    data = $data_%[1]d,
+		    zone = $zone_%[1]d,
+		    view = $view_%[1]d,
+		    updated_at = time::now()
+		WHERE name = $name_%[1]d AND record_type = $record_type_%[1]d AND view = $view_%[1]d;`, i))
+
+	tx.vars[fmt.Sprintf("name_%d", i)] = recordData.Name
+	tx.vars[fmt.Sprintf("record_type_%d", i)] = recordData.RecordType
+	tx.vars[fmt.Sprintf("class_%d", i)] = recordData.Class
+	tx.vars[fmt.Sprintf("ttl_%d", i)] = recordData.TTL
+	tx.vars[fmt.Sprintf("data_%d", i)] = recordData.Data
+	tx.vars[fmt.Sprintf("zone_%d", i)] = recordData.Zone
+	tx.vars[fmt.Sprintf("view_%d", i)] = recordData.View
+
+	return nil
+}
+
+// DeleteRecord stages removing records of a name
+func (tx *surrealTransaction) DeleteRecord(ctx context.Context, name string, recordType types.DNSType) error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+
+	if err := tx.storage.validator.ValidateName(name); err != nil {
+		return err
+	}
+
+	i := len(tx.statements)
+	statement := fmt.Sprintf("DELETE FROM dns_records WHERE name = $name_%d", i)
+	tx.vars[fmt.Sprintf("name_%d", i)] = strings.ToLower(name)
+
+	if recordType != 0 {
+		statement += fmt.Sprintf(" AND record_type = $record_type_%d", i)
+		tx.vars[fmt.Sprintf("record_type_%d", i)] = int(recordType)
+	}
+
+	tx.statements = append(tx.statements, statement+";")
+	return nil
+}
+
+// Commit sends all staged statements as one transaction
+func (tx *surrealTransaction) Commit(ctx context.Context) error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+	tx.done = true
+
+	if tx.storage.state.Load() == surrealClosed {
+		return ErrStorageClosed
+	}
+
+	if len(tx.statements) == 0 {
+		return nil
+	}
+
+	query := "BEGIN TRANSACTION;\n" + strings.Join(tx.statements, "\n") + "\nCOMMIT TRANSACTION;"
+	if _, err := surrealQuery[any](ctx, tx.storage, query, tx.vars); err != nil {
+		return fmt.Errorf("transaction failed: %w", err)
+	}
+	tx.storage.forgetRecords()
+
+	return nil
+}
+
+// Rollback discards all staged statements; nothing has been sent yet
+func (tx *surrealTransaction) Rollback() error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+	tx.done = true
+	tx.statements = nil
+	tx.vars = nil
+
+	return nil
+}
+
 // Ensure SurrealDBStorage implements Storage interface
 var _ Storage = (*SurrealDBStorage)(nil)
 var _ StorageWithStats = (*SurrealDBStorage)(nil)
@@ -0,0 +1,153 @@
+package storage_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surrealdb/surrealdb.go/pkg/connection"
+	surrealhttp "github.com/surrealdb/surrealdb.go/pkg/connection/http"
+	"github.com/surrealdb/surrealdb.go/pkg/models"
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// fakeSurrealDB answers the SurrealDB HTTP RPC with the records of a
+// fixed set of names, and drops every connection while it is down
+type fakeSurrealDB struct {
+	server   *httptest.Server
+	records  map[string][]map[string]any
+	down     atomic.Bool
+	connects atomic.Int32
+}
+
+func newFakeSurrealDB(t *testing.T) *fakeSurrealDB {
+	fake := &fakeSurrealDB{records: map[string][]map[string]any{
+		"www.example.com.":  {{"name": "www.example.com.", "record_type": 1, "class": 1, "ttl": 300, "data": "192.0.2.1", "zone": "example.com."}},
+		"mail.example.com.": {{"name": "mail.example.com.", "record_type": 1, "class": 1, "ttl": 300, "data": "192.0.2.25", "zone": "example.com."}},
+	}}
+	fake.server = httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(fake.server.Close)
+	return fake
+}
+
+func (f *fakeSurrealDB) serve(w http.ResponseWriter, r *http.Request) {
+	if f.down.Load() {
+		panic(http.ErrAbortHandler)
+	}
+	if r.URL.Path == "/health" {
+		f.connects.Add(1)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	var request connection.RPCRequest
+	if err := (&models.CborUnmarshaler{}).Unmarshal(body, &request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var result any = "surrealdb-2.0.0"
+	if request.Method == "query" {
+		rows := []map[string]any{}
+		if vars, ok := request.Params[1].(map[string]any); ok {
+			if name, ok := vars["name"].(string); ok {
+				rows = append(rows, f.records[name]...)
+			}
+		}
+		result = []map[string]any{{"status": "OK", "time": "1ms", "result": rows}}
+	}
+
+	response, _ := (&models.CborMarshaler{}).Marshal(connection.RPCResponse[any]{ID: request.ID, Result: &result})
+	w.Header().Set("Content-Type", "application/cbor")
+	w.Write(response)
+}
+
+func newFakeSurrealDBStorage(t *testing.T, fake *fakeSurrealDB) *storage.SurrealDBStorage {
+	s, err := storage.NewSurrealDBStorageWithConfig(context.Background(), &storage.SurrealDBConfig{
+		Namespace: "dns",
+		Database:  "records",
+		Dial: func(ctx context.Context) (connection.Connection, error) {
+			u, err := url.Parse(fake.server.URL)
+			if err != nil {
+				return nil, err
+			}
+			return surrealhttp.New(connection.NewConfig(u)), nil
+		},
+		ReconnectDelay:    10 * time.Millisecond,
+		MaxReconnectDelay: 40 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSurrealDBStorage_Reconnect(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeSurrealDB(t)
+	s := newFakeSurrealDBStorage(t, fake)
+
+	found, err := s.GetRecords(ctx, "www.example.com.", types.TYPE_A)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+
+	fake.down.Store(true)
+
+	// The lost connection is noticed by the next query
+	_, err = s.GetRecords(ctx, "mail.example.com.", types.TYPE_A)
+	assert.ErrorIs(t, err, storage.ErrStorageUnavailable)
+	assert.ErrorIs(t, s.Ping(ctx), storage.ErrStorageUnavailable)
+
+	// Records found before are still answered while reconnecting
+	found, err = s.GetRecords(ctx, "www.example.com.", types.TYPE_A)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, "www.example.com.", found[0].Name())
+
+	fake.down.Store(false)
+	assert.Eventually(t, func() bool {
+		return s.Ping(ctx) == nil
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(2), fake.connects.Load())
+
+	found, err = s.GetRecords(ctx, "mail.example.com.", types.TYPE_A)
+	require.NoError(t, err)
+	assert.Len(t, found, 1)
+}
+
+func TestSurrealDBStorage_CloseStopsReconnecting(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeSurrealDB(t)
+	s := newFakeSurrealDBStorage(t, fake)
+
+	fake.down.Store(true)
+	_, err := s.GetRecords(ctx, "www.example.com.", types.TYPE_A)
+	assert.ErrorIs(t, err, storage.ErrStorageUnavailable)
+
+	require.NoError(t, s.Close())
+	fake.down.Store(false)
+	time.Sleep(100 * time.Millisecond)
+
+	assert.Equal(t, int32(1), fake.connects.Load(), "reconnected after Close")
+	_, err = s.GetRecords(ctx, "www.example.com.", types.TYPE_A)
+	assert.ErrorIs(t, err, storage.ErrStorageClosed)
+}
+
+func TestSurrealDBStorage_Canceled(t *testing.T) {
+	s := newFakeSurrealDBStorage(t, newFakeSurrealDB(t))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := s.GetRecords(ctx, "www.example.com.", types.TYPE_A)
+	assert.ErrorIs(t, err, context.Canceled)
+	// A canceled query is no reason to reconnect
+	assert.NoError(t, s.Ping(context.Background()))
+}
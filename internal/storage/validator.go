@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/vadim-su/dnska/pkg/dns/message"
 	"github.com/vadim-su/dnska/pkg/dns/records"
 	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
 )
 
 const (
@@ -24,6 +27,7 @@ const (
 type Validator struct {
 	enabled         bool
 	allowUnderscore bool
+	allowUnknown    bool
 	minTTL          uint32
 	maxTTL          uint32
 	allowedTypes    map[types.DNSType]bool
@@ -39,6 +43,7 @@ func NewValidator(config *ValidationConfig) *Validator {
 	v := &Validator{
 		enabled:         config.Enabled,
 		allowUnderscore: config.AllowUnderscore,
+		allowUnknown:    config.AllowUnknownTypes,
 		minTTL:          config.MinTTL,
 		maxTTL:          config.MaxTTL,
 	}
@@ -60,16 +65,36 @@ func NewValidator(config *ValidationConfig) *Validator {
 				dnsType = types.TYPE_A
 			case "AAAA":
 				dnsType = types.TYPE_AAAA
+			case "CAA":
+				dnsType = types.TYPE_CAA
 			case "CNAME":
 				dnsType = types.TYPE_CNAME
+			case "DNAME":
+				dnsType = types.TYPE_DNAME
+			case "DNSKEY":
+				dnsType = types.TYPE_DNSKEY
+			case "DS":
+				dnsType = types.TYPE_DS
+			case "HTTPS":
+				dnsType = types.TYPE_HTTPS
 			case "MX":
 				dnsType = types.TYPE_MX
+			case "NAPTR":
+				dnsType = types.TYPE_NAPTR
 			case "NS":
 				dnsType = types.TYPE_NS
+			case "NSEC":
+				dnsType = types.TYPE_NSEC
 			case "PTR":
 				dnsType = types.TYPE_PTR
+			case "RRSIG":
+				dnsType = types.TYPE_RRSIG
 			case "SOA":
 				dnsType = types.TYPE_SOA
+			case "SRV":
+				dnsType = types.TYPE_SRV
+			case "SVCB":
+				dnsType = types.TYPE_SVCB
 			case "TXT":
 				dnsType = types.TYPE_TXT
 			}
@@ -128,6 +153,16 @@ func (v *Validator) ValidateName(name string) error {
 		return fmt.Errorf("%w: empty name", ErrInvalidName)
 	}
 
+	// Unicode names are stored and served as A-labels, so limits apply to
+	// their ACE form
+	if !isASCII(name) {
+		ace, err := utils.ToACE(name)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidName, err)
+		}
+		name = ace
+	}
+
 	// Remove trailing dot if present
 	name = strings.TrimSuffix(name, ".")
 
@@ -152,6 +187,16 @@ func (v *Validator) ValidateName(name string) error {
 	return nil
 }
 
+// isASCII reports whether name holds ASCII characters only
+func isASCII(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if name[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
 // ValidateZone validates a zone name
 func (v *Validator) ValidateZone(zone string) error {
 	if !v.enabled {
@@ -215,16 +260,27 @@ func (v *Validator) validateLabel(label string) error {
 
 // validateRecordData validates record-specific data
 func (v *Validator) validateRecordData(record records.DNSRecord) error {
-	switch r := record.(type) {
+	switch r := unwrapRecord(record).(type) {
 	case *records.CNAMERecord:
 		return v.ValidateName(r.Target())
 
+	case *records.DNAMERecord:
+		return v.ValidateName(r.Target())
+
 	case *records.MXRecord:
 		if r.Preference() > 65535 {
 			return fmt.Errorf("MX preference must be 0-65535")
 		}
 		return v.ValidateName(r.MailServer())
 
+	case *records.NAPTRRecord:
+		for _, text := range []string{r.Flags(), r.Services(), r.Regexp()} {
+			if len(text) > 255 {
+				return fmt.Errorf("NAPTR character string exceeds 255 characters")
+			}
+		}
+		return v.ValidateName(r.Replacement())
+
 	case *records.NSRecord:
 		return v.ValidateName(r.NameServer())
 
@@ -245,25 +301,67 @@ func (v *Validator) validateRecordData(record records.DNSRecord) error {
 		}
 		return nil
 
-	case *records.TXTRecord:
-		// TXT records can contain any data, but check string lengths
-		for i, text := range r.Texts() {
-			if len(text) > 255 {
-				return fmt.Errorf("TXT string %d exceeds 255 characters", i+1)
-			}
+	case *records.CAARecord:
+		if !records.IsRegisteredCAATag(r.Tag()) {
+			return fmt.Errorf("CAA tag %q is not registered with IANA", r.Tag())
+		}
+		return nil
+
+	case *records.RRSIGRecord:
+		return v.ValidateName(r.SignerName())
+
+	case *records.NSECRecord:
+		// The next name may be a wildcard, so it is only checked to encode
+		if r.Data() == nil {
+			return fmt.Errorf("invalid NSEC next name %q", r.NextName())
 		}
 		return nil
 
-	case *records.ARecord, *records.AAAARecord:
-		// IP address validation is done by the record constructors
+	case *records.GenericRecord:
+		if !v.allowUnknown {
+			return fmt.Errorf("%w: record type %s is unknown", ErrInvalidRecord, r.Type().Mnemonic())
+		}
+		if records.IsMetaType(r.Type()) {
+			return fmt.Errorf("%w: %s is not a type records can have", ErrInvalidRecord, r.Type().Mnemonic())
+		}
+		if size := len(r.Data()); size > 65535 {
+			return fmt.Errorf("%s data of %d bytes exceeds 65535", r.Type().Mnemonic(), size)
+		}
+		return nil
+
+	case *records.TXTRecord:
+		// TXT records can contain any data; long texts are split into
+		// character strings, but the RDATA length must fit in 16 bits
+		if size := len(r.Data()); size > 65535 {
+			return fmt.Errorf("TXT data of %d bytes exceeds 65535", size)
+		}
 		return nil
 
 	default:
+		if record.Type() == types.TYPE_A || record.Type() == types.TYPE_AAAA {
+			return validateAddressData(record)
+		}
 		// Unknown record type - no specific validation
 		return nil
 	}
 }
 
+// validateAddressData runs the RDATA of an A or AAAA record through the
+// wire-format parsers so addresses of the wrong size are rejected on write
+func validateAddressData(record records.DNSRecord) error {
+	answer, err := message.NewDNSAnswer([]byte{0x00}, record.Class(), record.Type(), record.TTL(), record.Data())
+	if err != nil {
+		return err
+	}
+
+	if record.Type() == types.TYPE_A {
+		_, err = answer.ParseARecord()
+	} else {
+		_, err = answer.ParseAAAARecord()
+	}
+	return err
+}
+
 // ValidateBatch validates multiple records and returns all errors
 func (v *Validator) ValidateBatch(records []records.DNSRecord) []error {
 	if !v.enabled || len(records) == 0 {
@@ -278,3 +376,34 @@ func (v *Validator) ValidateBatch(records []records.DNSRecord) []error {
 	}
 	return errors
 }
+
+// ValidateRRSet validates a set of records that is meant to replace the
+// RRset identified by name and recordType. Every record must belong to that
+// RRset, regardless of whether validation is enabled
+func (v *Validator) ValidateRRSet(name string, recordType types.DNSType, rrset []records.DNSRecord) error {
+	if recordType == 0 {
+		return fmt.Errorf("%w: RRset type must be specified", ErrInvalidRecord)
+	}
+
+	if err := v.ValidateName(name); err != nil {
+		return err
+	}
+
+	for i, record := range rrset {
+		if record == nil {
+			return fmt.Errorf("%w: record %d is nil", ErrInvalidRecord, i)
+		}
+		if !strings.EqualFold(normalizeDomainName(record.Name()), normalizeDomainName(name)) {
+			return fmt.Errorf("%w: record %d has name %s, expected %s", ErrInvalidRecord, i, record.Name(), name)
+		}
+		if record.Type() != recordType {
+			return fmt.Errorf("%w: record %d has type %s, expected %s", ErrInvalidRecord, i, record.Type(), recordType)
+		}
+	}
+
+	if errs := v.ValidateBatch(rrset); len(errs) > 0 {
+		return fmt.Errorf("validation failed: %w", errs[0])
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"github.com/vadim-su/dnska/pkg/dns/records"
+)
+
+// DefaultView names the view of records stored without a view tag. Clients
+// that belong to no configured view are answered from it, and so are clients
+// of a view lacking the queried records
+const DefaultView = "default"
+
+// ViewRecord is a record that is only served to clients of a view
+// (split-horizon DNS)
+type ViewRecord struct {
+	records.DNSRecord
+	view string
+}
+
+// NewViewRecord tags record with view. The default view needs no tag, so for
+// it record is returned untagged
+func NewViewRecord(record records.DNSRecord, view string) records.DNSRecord {
+	record = unwrapRecord(record)
+	if view == "" || view == DefaultView {
+		return record
+	}
+	return &ViewRecord{DNSRecord: record, view: view}
+}
+
+// View returns the view the record is served in
+func (r *ViewRecord) View() string {
+	return r.view
+}
+
+// Unwrap returns the untagged record
+func (r *ViewRecord) Unwrap() records.DNSRecord {
+	return r.DNSRecord
+}
+
+// RecordView returns the view record is served in
+func RecordView(record records.DNSRecord) string {
+	if r, ok := record.(*ViewRecord); ok {
+		return r.view
+	}
+	return DefaultView
+}
+
+// unwrapRecord returns the record a view tag wraps, so its concrete type can
+// be inspected
+func unwrapRecord(record records.DNSRecord) records.DNSRecord {
+	if r, ok := record.(*ViewRecord); ok {
+		return r.DNSRecord
+	}
+	return record
+}
+
+// inView reports whether record is served in view; an empty view matches
+// every record
+func inView(record records.DNSRecord, view string) bool {
+	return view == "" || RecordView(record) == view
+}
+
+// storedView returns the view as stored with a record, empty for the
+// default view
+func storedView(view string) string {
+	if view == DefaultView {
+		return ""
+	}
+	return view
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// Zone validity violations reported by ValidateZone (RFC 1035 §5.2)
+var (
+	ErrZoneNoSOA       = errors.New("zone has no SOA record")
+	ErrZoneMultipleSOA = errors.New("zone has more than one SOA record")
+	ErrZoneNoNS        = errors.New("zone has no NS records at its apex")
+	ErrZoneMissingGlue = errors.New("in-zone name server has no A or AAAA records")
+	ErrZoneMNAMENotNS  = errors.New("SOA primary name server is not an apex NS target")
+)
+
+// ZoneError is a violation of a zone validity rule found by ValidateZone
+type ZoneError struct {
+	Zone string // Zone checked
+	Name string // Name the violation concerns, e.g. a name server
+	Err  error  // One of the ErrZone violations
+}
+
+// Error returns the violation with the zone and name it concerns
+func (e *ZoneError) Error() string {
+	return fmt.Sprintf("zone %s: %s: %v", e.Zone, e.Name, e.Err)
+}
+
+// Unwrap returns the violation
+func (e *ZoneError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateZone checks that the zone named zoneName in store can be served:
+// it has exactly one SOA record and at least one NS record at its apex, each
+// name server inside the zone has address records, and the primary name
+// server of the SOA is one of the apex name servers. Violations are returned
+// as *ZoneError; a failed lookup ends the checks and is returned as it is
+func ValidateZone(ctx context.Context, store Storage, zoneName string) []error {
+	zone := normalizeDomainName(zoneName)
+	violation := func(name string, err error) error {
+		return &ZoneError{Zone: zone, Name: name, Err: err}
+	}
+
+	var errs []error
+	soas, err := store.GetRecords(ctx, zone, types.TYPE_SOA)
+	if err != nil {
+		return []error{fmt.Errorf("failed to look up the SOA of %s: %w", zone, err)}
+	}
+	switch {
+	case len(soas) == 0:
+		errs = append(errs, violation(zone, ErrZoneNoSOA))
+	case len(soas) > 1:
+		errs = append(errs, violation(zone, ErrZoneMultipleSOA))
+	}
+
+	nameServers, err := store.GetRecords(ctx, zone, types.TYPE_NS)
+	if err != nil {
+		return append(errs, fmt.Errorf("failed to look up the NS records of %s: %w", zone, err))
+	}
+	if len(nameServers) == 0 {
+		errs = append(errs, violation(zone, ErrZoneNoNS))
+	}
+
+	targets := make(map[string]bool, len(nameServers))
+	for _, record := range nameServers {
+		ns, ok := unwrapRecord(record).(*records.NSRecord)
+		if !ok {
+			continue
+		}
+		target := normalizeDomainName(ns.NameServer())
+		if targets[target] {
+			continue
+		}
+		targets[target] = true
+
+		// Out-of-zone name servers are resolved through their own zones
+		if !inZone(target, zone) {
+			continue
+		}
+		glued, err := hasAddress(ctx, store, target)
+		if err != nil {
+			return append(errs, fmt.Errorf("failed to look up the addresses of %s: %w", target, err))
+		}
+		if !glued {
+			errs = append(errs, violation(target, ErrZoneMissingGlue))
+		}
+	}
+
+	for _, record := range soas {
+		soa, ok := unwrapRecord(record).(*records.SOARecord)
+		if !ok || len(targets) == 0 {
+			continue
+		}
+		if mname := normalizeDomainName(soa.PrimaryNS()); !targets[mname] {
+			errs = append(errs, violation(mname, ErrZoneMNAMENotNS))
+		}
+	}
+
+	return errs
+}
+
+// hasAddress reports whether name has A or AAAA records in store
+func hasAddress(ctx context.Context, store Storage, name string) (bool, error) {
+	for _, recordType := range []types.DNSType{types.TYPE_A, types.TYPE_AAAA} {
+		addresses, err := store.GetRecords(ctx, name, recordType)
+		if err != nil {
+			return false, err
+		}
+		if len(addresses) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// inZone reports whether the normalized name is zone or a name below it
+func inZone(name, zone string) bool {
+	return zone == "." || name == zone || strings.HasSuffix(name, "."+zone)
+}
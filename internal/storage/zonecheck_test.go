@@ -0,0 +1,113 @@
+package storage_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+)
+
+func zoneSOA(primaryNS string) records.DNSRecord {
+	return records.NewSOARecord("example.com.", primaryNS, "hostmaster.example.com.", 1,
+		time.Hour, 10*time.Minute, 24*time.Hour, 5*time.Minute, 3600)
+}
+
+func TestValidateZone(t *testing.T) {
+	nsGlue := records.NewARecord("ns1.example.com.", net.ParseIP("192.0.2.53"), 3600)
+
+	tests := []struct {
+		name     string
+		records  []records.DNSRecord
+		expected []error // Violations in the order they are reported
+		names    []string
+	}{
+		{
+			name: "valid",
+			records: []records.DNSRecord{
+				zoneSOA("ns1.example.com."),
+				records.NewNSRecord("example.com.", "ns1.example.com.", 3600),
+				records.NewNSRecord("example.com.", "ns.example.net.", 3600),
+				nsGlue,
+			},
+		},
+		{
+			name: "IPv6 glue",
+			records: []records.DNSRecord{
+				zoneSOA("NS1.Example.COM"),
+				records.NewNSRecord("example.com.", "ns1.example.com.", 3600),
+				records.NewAAAARecord("ns1.example.com.", net.ParseIP("2001:db8::53"), 3600),
+			},
+		},
+		{
+			name:     "empty zone",
+			expected: []error{storage.ErrZoneNoSOA, storage.ErrZoneNoNS},
+			names:    []string{"example.com.", "example.com."},
+		},
+		{
+			name: "no name servers",
+			records: []records.DNSRecord{
+				zoneSOA("ns1.example.com."),
+				nsGlue,
+			},
+			expected: []error{storage.ErrZoneNoNS},
+			names:    []string{"example.com."},
+		},
+		{
+			name: "missing glue",
+			records: []records.DNSRecord{
+				zoneSOA("ns.example.net."),
+				records.NewNSRecord("example.com.", "ns.example.net.", 3600),
+				records.NewNSRecord("example.com.", "ns2.example.com.", 3600),
+			},
+			expected: []error{storage.ErrZoneMissingGlue},
+			names:    []string{"ns2.example.com."},
+		},
+		{
+			name: "primary name server not in NS",
+			records: []records.DNSRecord{
+				zoneSOA("hidden.example.com."),
+				records.NewNSRecord("example.com.", "ns1.example.com.", 3600),
+				nsGlue,
+			},
+			expected: []error{storage.ErrZoneMNAMENotNS},
+			names:    []string{"hidden.example.com."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store, err := storage.NewMemoryStorage(nil)
+			require.NoError(t, err)
+			defer store.Close()
+			if len(tt.records) > 0 {
+				require.NoError(t, store.BatchPutRecords(ctx, tt.records))
+			}
+
+			errs := storage.ValidateZone(ctx, store, "Example.com")
+			require.Len(t, errs, len(tt.expected), "violations: %v", errs)
+			for i, err := range errs {
+				assert.ErrorIs(t, err, tt.expected[i])
+				var zoneErr *storage.ZoneError
+				require.ErrorAs(t, err, &zoneErr)
+				assert.Equal(t, "example.com.", zoneErr.Zone)
+				assert.Equal(t, tt.names[i], zoneErr.Name)
+			}
+		})
+	}
+}
+
+func TestValidateZone_StorageError(t *testing.T) {
+	store, err := storage.NewMemoryStorage(nil)
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	errs := storage.ValidateZone(context.Background(), store, "example.com.")
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], storage.ErrStorageClosed)
+}
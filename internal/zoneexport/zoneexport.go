@@ -0,0 +1,236 @@
+// Package zoneexport writes stored records as master files in the format
+// read by BIND (RFC 1035 §5)
+package zoneexport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// WriteZone writes the records of zone to w as a master file. The file
+// starts with $ORIGIN and a $TTL set to the most common record TTL, followed
+// by the SOA and NS records of the apex and then every other record in
+// canonical order (RFC 4034 §6.1). Owners inside the zone are written
+// relative to $ORIGIN and TTLs equal to $TTL are omitted. Names in RDATA are
+// always written fully qualified
+func WriteZone(w io.Writer, zone string, recordList []records.DNSRecord) error {
+	origin := fqdn(strings.ToLower(zone))
+	defaultTTL := mostCommonTTL(recordList)
+
+	sorted := make([]records.DNSRecord, 0, len(recordList))
+	for _, record := range recordList {
+		sorted = append(sorted, unwrap(record))
+	}
+	slices.SortStableFunc(sorted, func(a, b records.DNSRecord) int {
+		if rankA, rankB := apexRank(a, origin), apexRank(b, origin); rankA != rankB {
+			return rankA - rankB
+		}
+		return compareCanonical(a, b)
+	})
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "$ORIGIN %s\n", origin)
+	fmt.Fprintf(out, "$TTL %d\n", defaultTTL)
+
+	for _, record := range sorted {
+		rdata, err := presentRData(record)
+		if err != nil {
+			return fmt.Errorf("failed to export record %s: %w", record.Name(), err)
+		}
+
+		fields := []string{relativeName(record.Name(), origin)}
+		if record.TTL() != defaultTTL {
+			fields = append(fields, strconv.FormatUint(uint64(record.TTL()), 10))
+		}
+		fields = append(fields, className(record.Class()), typeName(record.Type()), rdata)
+		fmt.Fprintln(out, strings.Join(fields, "\t"))
+	}
+
+	return out.Flush()
+}
+
+// unwrap returns the record a wrapper such as a view tag holds, so its
+// concrete type can be inspected
+func unwrap(record records.DNSRecord) records.DNSRecord {
+	if wrapper, ok := record.(interface{ Unwrap() records.DNSRecord }); ok {
+		return wrapper.Unwrap()
+	}
+	return record
+}
+
+// mostCommonTTL returns the TTL most records share, the lowest one on ties
+func mostCommonTTL(recordList []records.DNSRecord) uint32 {
+	counts := make(map[uint32]int)
+	var best uint32
+	for _, record := range recordList {
+		ttl := record.TTL()
+		counts[ttl]++
+		if counts[ttl] > counts[best] || (counts[ttl] == counts[best] && ttl < best) {
+			best = ttl
+		}
+	}
+	return best
+}
+
+// apexRank orders the SOA of the apex first and its NS records second
+func apexRank(record records.DNSRecord, origin string) int {
+	if !strings.EqualFold(fqdn(record.Name()), origin) {
+		return 2
+	}
+	switch record.Type() {
+	case types.TYPE_SOA:
+		return 0
+	case types.TYPE_NS:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// compareCanonical orders records by owner name in canonical order, then by
+// type and RDATA
+func compareCanonical(a, b records.DNSRecord) int {
+	if c := compareNames(a.Name(), b.Name()); c != 0 {
+		return c
+	}
+	if a.Type() != b.Type() {
+		return int(a.Type()) - int(b.Type())
+	}
+	return bytes.Compare(a.Data(), b.Data())
+}
+
+// compareNames compares names label by label from the rightmost label,
+// ignoring case, so a name sorts right after its parent
+func compareNames(a, b string) int {
+	labelsA := labels(a)
+	labelsB := labels(b)
+	for i := 1; i <= len(labelsA) && i <= len(labelsB); i++ {
+		if c := strings.Compare(labelsA[len(labelsA)-i], labelsB[len(labelsB)-i]); c != 0 {
+			return c
+		}
+	}
+	return len(labelsA) - len(labelsB)
+}
+
+// labels returns the lowercase labels of name, leftmost first
+func labels(name string) []string {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	if name == "" {
+		return nil
+	}
+	return strings.Split(name, ".")
+}
+
+// relativeName returns name relative to origin, "@" for origin itself, or
+// the fully qualified name when it is outside origin
+func relativeName(name, origin string) string {
+	name = fqdn(name)
+	if strings.EqualFold(name, origin) {
+		return "@"
+	}
+	if origin != "." && len(name) > len(origin) && strings.EqualFold(name[len(name)-len(origin)-1:], "."+origin) {
+		return name[:len(name)-len(origin)-1]
+	}
+	return name
+}
+
+// fqdn returns name with a trailing dot
+func fqdn(name string) string {
+	if !strings.HasSuffix(name, ".") {
+		return name + "."
+	}
+	return name
+}
+
+// typeName returns the mnemonic of recordType, or the generic TYPEnnn form
+// of RFC 3597 for types without one
+func typeName(recordType types.DNSType) string {
+	if name := recordType.String(); name != "UNKNOWN" {
+		return name
+	}
+	return fmt.Sprintf("TYPE%d", uint16(recordType))
+}
+
+// className returns the mnemonic of class, or the generic CLASSnnn form
+func className(class types.DNSClass) string {
+	if name := class.String(); name != "UNKNOWN" {
+		return name
+	}
+	return fmt.Sprintf("CLASS%d", uint16(class))
+}
+
+// presentRData returns the RDATA of record in presentation format. Types
+// without a typed representation use the generic \# form of RFC 3597
+func presentRData(record records.DNSRecord) (string, error) {
+	switch r := record.(type) {
+	case *records.ARecord:
+		return r.IP().String(), nil
+	case *records.AAAARecord:
+		return r.IP().String(), nil
+	case *records.CNAMERecord:
+		return fqdn(r.Target()), nil
+	case *records.DNAMERecord:
+		return fqdn(r.Target()), nil
+	case *records.NSRecord:
+		return fqdn(r.NameServer()), nil
+	case *records.PTRRecord:
+		return fqdn(r.Target()), nil
+	case *records.MXRecord:
+		return fmt.Sprintf("%d %s", r.Preference(), fqdn(r.MailServer())), nil
+	case *records.SRVRecord:
+		return fmt.Sprintf("%d %d %d %s", r.Priority(), r.Weight(), r.Port(), fqdn(r.Target())), nil
+	case *records.SOARecord:
+		return fmt.Sprintf("%s %s %d %d %d %d %d",
+			fqdn(r.PrimaryNS()), fqdn(r.Responsible()), r.Serial(),
+			int(r.Refresh().Seconds()), int(r.Retry().Seconds()),
+			int(r.Expire().Seconds()), int(r.Minimum().Seconds())), nil
+	case *records.TXTRecord:
+		strs := r.Strings()
+		quoted := make([]string, len(strs))
+		for i, str := range strs {
+			quoted[i] = quote(str)
+		}
+		return strings.Join(quoted, " "), nil
+	case *records.CAARecord:
+		return fmt.Sprintf("%d %s %s", r.Flags(), r.Tag(), quote(r.Value())), nil
+	case *records.NAPTRRecord:
+		return fmt.Sprintf("%d %d %s %s %s %s", r.Order(), r.Preference(),
+			quote(r.Flags()), quote(r.Services()), quote(r.Regexp()), fqdn(r.Replacement())), nil
+	case *records.TLSARecord:
+		return fmt.Sprintf("%d %d %d %X", r.CertUsage(), r.Selector(), r.MatchingType(), r.CertAssocData()), nil
+	case *records.DNSKEYRecord:
+		return fmt.Sprintf("%d %d %d %s", r.Flags(), r.Protocol(), r.Algorithm(), base64.StdEncoding.EncodeToString(r.PublicKey())), nil
+	case *records.DSRecord:
+		return fmt.Sprintf("%d %d %d %X", r.KeyTag(), r.Algorithm(), r.DigestType(), r.Digest()), nil
+	case *records.RRSIGRecord:
+		return fmt.Sprintf("%s %d %d %d %s %s %d %s %s", typeName(r.TypeCovered()), r.Algorithm(), r.Labels(),
+			r.OriginalTTL(), r.Expiration().Format(records.RRSIGTimeLayout), r.Inception().Format(records.RRSIGTimeLayout),
+			r.KeyTag(), fqdn(r.SignerName()), base64.StdEncoding.EncodeToString(r.Signature())), nil
+	case *records.NSECRecord:
+		fields := []string{fqdn(r.NextName())}
+		for _, recordType := range r.Types() {
+			fields = append(fields, typeName(recordType))
+		}
+		return strings.Join(fields, " "), nil
+	}
+
+	data := record.Data()
+	if data == nil {
+		return "", fmt.Errorf("no RDATA for %s record", typeName(record.Type()))
+	}
+	return records.FormatGenericRData(data), nil
+}
+
+// quote returns s as a quoted character string
+func quote(s string) string {
+	return records.QuoteCharacterString(s)
+}
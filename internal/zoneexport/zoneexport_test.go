@@ -0,0 +1,101 @@
+package zoneexport
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+func TestWriteZone(t *testing.T) {
+	zone := []records.DNSRecord{
+		records.NewTXTRecord("www.example.com", []string{`say "hi"`, "caf\xc3\xa9"}, 300),
+		records.NewARecord("www.example.com", net.ParseIP("192.0.2.80"), 300),
+		records.NewMXRecord("example.com", "mail.example.com", 10, 3600),
+		records.NewNSRecord("example.com", "ns2.example.com", 3600),
+		records.NewARecord("mail.example.com", net.ParseIP("192.0.2.25"), 3600),
+		records.NewCNAMERecord("alias.example.com", "www.example.com", 3600),
+		records.NewNSRecord("example.com", "ns1.example.com", 3600),
+		records.NewARecord("*.example.com", net.ParseIP("192.0.2.1"), 3600),
+		records.NewSOARecord("example.com", "ns1.example.com", "hostmaster.example.com",
+			2024010101, time.Hour, 10*time.Minute, 7*24*time.Hour, 5*time.Minute, 3600),
+		records.NewARecord("ns1.example.org", net.ParseIP("198.51.100.53"), 3600),
+		records.NewAAAARecord("Sub.Example.com", net.ParseIP("2001:db8::1"), 3600),
+	}
+
+	var out strings.Builder
+	if err := WriteZone(&out, "example.com", zone); err != nil {
+		t.Fatalf("WriteZone() returned error: %v", err)
+	}
+
+	expected := strings.Join([]string{
+		"$ORIGIN example.com.",
+		"$TTL 3600",
+		"@\tIN\tSOA\tns1.example.com. hostmaster.example.com. 2024010101 3600 600 604800 300",
+		"@\tIN\tNS\tns1.example.com.",
+		"@\tIN\tNS\tns2.example.com.",
+		"@\tIN\tMX\t10 mail.example.com.",
+		"*\tIN\tA\t192.0.2.1",
+		"alias\tIN\tCNAME\twww.example.com.",
+		"mail\tIN\tA\t192.0.2.25",
+		"Sub\tIN\tAAAA\t2001:db8::1",
+		"www\t300\tIN\tA\t192.0.2.80",
+		"www\t300\tIN\tTXT\t\"say \\\"hi\\\"\" \"caf\\195\\169\"",
+		"ns1.example.org.\tIN\tA\t198.51.100.53",
+		"",
+	}, "\n")
+
+	if out.String() != expected {
+		t.Errorf("WriteZone() output:\n%s\nexpected:\n%s", out.String(), expected)
+	}
+}
+
+func TestPresentRData(t *testing.T) {
+	tests := []struct {
+		name     string
+		record   records.DNSRecord
+		expected string
+	}{
+		{name: "SRV", record: records.NewSRVRecord("_sip._tcp.example.com", "sip.example.com", 10, 20, 5060, 300), expected: "10 20 5060 sip.example.com."},
+		{name: "CAA", record: records.NewCAARecord("example.com", "issue", "ca.example.net", 0, 300), expected: `0 issue "ca.example.net"`},
+		{name: "NAPTR", record: records.NewNAPTRRecord("example.com", 100, 10, "S", "SIP+D2U", "", "_sip._udp.example.com", 300), expected: `100 10 "S" "SIP+D2U" "" _sip._udp.example.com.`},
+		{
+			name:     "TXT over 255 bytes",
+			record:   records.NewTXTRecordFromString("example.com", strings.Repeat("a", 255)+"v=spf1 -all", 300),
+			expected: `"` + strings.Repeat("a", 255) + `" "v=spf1 -all"`,
+		},
+		{name: "TXT strings", record: records.NewTXTRecordFromStrings("example.com", []string{"first part", "", "x"}, 300), expected: `"first part" "" "x"`},
+		{name: "DNAME", record: records.NewDNAMERecord("old.example.com", "new.example.com", 300), expected: "new.example.com."},
+		{name: "PTR", record: records.NewPTRRecord("80.2.0.192.in-addr.arpa", "www.example.com", 300), expected: "www.example.com."},
+		{
+			name:     "NSEC",
+			record:   records.NewNSECRecord("example.com", "www.example.com", []types.DNSType{types.TYPE_SOA, types.TYPE_NS, types.TYPE_RRSIG, types.TYPE_NSEC, types.TYPE_DNSKEY}, 300),
+			expected: "www.example.com. NS SOA RRSIG NSEC DNSKEY",
+		},
+		{name: "generic", record: records.NewHTTPSRecord("example.com", ".", 1, nil, 300), expected: `\# 3 000100`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdata, err := presentRData(tt.record)
+			if err != nil {
+				t.Fatalf("presentRData() returned error: %v", err)
+			}
+			if rdata != tt.expected {
+				t.Errorf("presentRData() = %q, want %q", rdata, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTypeName(t *testing.T) {
+	if name := typeName(types.TYPE_MX); name != "MX" {
+		t.Errorf("typeName(MX) = %q, want MX", name)
+	}
+	if name := typeName(types.DNSType(65280)); name != "TYPE65280" {
+		t.Errorf("typeName(65280) = %q, want TYPE65280", name)
+	}
+}
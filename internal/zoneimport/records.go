@@ -0,0 +1,201 @@
+package zoneimport
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// csvColumns are the columns of CSV imports, in order
+var csvColumns = []string{"name", "type", "class", "ttl", "data"}
+
+// RowError reports an invalid record of an import
+type RowError struct {
+	Row  int    // Position of the record in a JSON array, or its line in a CSV file
+	Name string // Owner name as given
+	Err  error
+}
+
+// Error returns the error with the row and name it concerns
+func (e *RowError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+	}
+	return fmt.Sprintf("row %d (%s): %v", e.Row, e.Name, e.Err)
+}
+
+// Unwrap returns the error of the row
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// RowErrors lists the invalid records of an import
+type RowErrors []*RowError
+
+// Error returns the errors of every row
+func (e RowErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// jsonRecord is a record of a JSON import
+type jsonRecord struct {
+	Name  string  `json:"name"`
+	Type  string  `json:"type"`
+	Class string  `json:"class,omitempty"`
+	TTL   *uint32 `json:"ttl,omitempty"`
+	Data  string  `json:"data"`
+}
+
+// ImportJSON reads records from a JSON array such as
+// [{"name":"www.example.com","type":"A","ttl":300,"data":"192.0.2.1"}],
+// one element at a time. The data is in the format records are stored in,
+// e.g. "10 mail.example.com" for MX records. Records without a TTL take the
+// one policy gives their type.
+//
+// The records of the valid elements are returned along with RowErrors
+// listing the invalid ones. Input that is not a JSON array of objects
+// returns no records
+func ImportJSON(r io.Reader, policy TTLPolicy) ([]records.DNSRecord, error) {
+	decoder := json.NewDecoder(r)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return nil, fmt.Errorf("records must be a JSON array")
+	}
+
+	var recordList []records.DNSRecord
+	var rowErrors RowErrors
+	for row := 1; decoder.More(); row++ {
+		var element jsonRecord
+		if err := decoder.Decode(&element); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if !errors.As(err, &typeErr) {
+				return nil, fmt.Errorf("failed to decode record %d: %w", row, err)
+			}
+			rowErrors = append(rowErrors, &RowError{Row: row, Err: err})
+			continue
+		}
+
+		ttl, hasTTL := uint32(0), element.TTL != nil
+		if hasTTL {
+			ttl = *element.TTL
+		}
+		record, err := newRecord(element.Name, element.Type, element.Class, ttl, hasTTL, element.Data, policy)
+		if err != nil {
+			rowErrors = append(rowErrors, &RowError{Row: row, Name: element.Name, Err: err})
+			continue
+		}
+		recordList = append(recordList, record)
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return nil, fmt.Errorf("failed to decode records: %w", err)
+	}
+	if len(rowErrors) > 0 {
+		return recordList, rowErrors
+	}
+	return recordList, nil
+}
+
+// ImportCSV reads records from CSV with the columns name, type, class, ttl
+// and data, one line at a time. The first line is skipped when hasHeader is
+// set. An empty class is IN and an empty TTL the one policy gives the type;
+// the data is in the format records are stored in, as for ImportJSON.
+//
+// The records of the valid lines are returned along with RowErrors listing
+// the invalid ones. Input that is not CSV returns no records
+func ImportCSV(r io.Reader, hasHeader bool, policy TTLPolicy) ([]records.DNSRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(csvColumns)
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	var recordList []records.DNSRecord
+	var rowErrors RowErrors
+	for first := true; ; first = false {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) || !errors.Is(parseErr.Err, csv.ErrFieldCount) {
+				return nil, fmt.Errorf("failed to read records: %w", err)
+			}
+			columnsErr := fmt.Errorf("expected the columns %s", strings.Join(csvColumns, ","))
+			rowErrors = append(rowErrors, &RowError{Row: parseErr.Line, Err: columnsErr})
+			continue
+		}
+		if first && hasHeader {
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		name, typeName, class, ttlField, data := fields[0], fields[1], fields[2], fields[3], fields[4]
+		var ttl uint32
+		if ttlField != "" {
+			value, err := strconv.ParseUint(ttlField, 10, 32)
+			if err != nil {
+				rowErrors = append(rowErrors, &RowError{Row: line, Name: name, Err: fmt.Errorf("invalid TTL %q", ttlField)})
+				continue
+			}
+			ttl = uint32(value)
+		}
+
+		record, err := newRecord(name, typeName, class, ttl, ttlField != "", data, policy)
+		if err != nil {
+			rowErrors = append(rowErrors, &RowError{Row: line, Name: name, Err: err})
+			continue
+		}
+		recordList = append(recordList, record)
+	}
+
+	if len(rowErrors) > 0 {
+		return recordList, rowErrors
+	}
+	return recordList, nil
+}
+
+// newRecord builds the record a JSON element or CSV line describes
+func newRecord(name, typeName, class string, ttl uint32, hasTTL bool, data string, policy TTLPolicy) (records.DNSRecord, error) {
+	owner, err := absoluteName(name, ".")
+	if err != nil {
+		return nil, fmt.Errorf("invalid name: %w", err)
+	}
+
+	recordType, ok := types.ParseDNSType(typeName)
+	if !ok {
+		return nil, fmt.Errorf("unknown record type %q", typeName)
+	}
+
+	if class != "" {
+		if parsed, ok := parseClass(class); !ok || parsed != types.CLASS_IN {
+			return nil, fmt.Errorf("unsupported class %q", class)
+		}
+	}
+
+	if !hasTTL {
+		ttl = policy.TTL(recordType)
+	}
+	if ttl > maxTTL {
+		return nil, fmt.Errorf("TTL %d exceeds %d", ttl, maxTTL)
+	}
+
+	return storage.NewRecordConverter().FromStorageFormat(&storage.RecordData{
+		Name:       owner,
+		RecordType: int(recordType),
+		Class:      int(types.CLASS_IN),
+		TTL:        ttl,
+		Data:       data,
+	})
+}
@@ -0,0 +1,103 @@
+package zoneimport
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+func TestImportJSON(t *testing.T) {
+	input := `[
+		{"name": "www.example.com", "type": "A", "ttl": 60, "data": "192.0.2.1"},
+		{"name": "example.com.", "type": "MX", "data": "10 mail.example.com."},
+		{"name": "bad.example.com", "type": "A", "data": "not-an-address"},
+		{"name": "txt.example.com", "type": "BOGUS", "data": "x"},
+		{"name": "ttl.example.com", "type": "A", "ttl": "60", "data": "192.0.2.2"}
+	]`
+
+	recordList, err := ImportJSON(strings.NewReader(input), testPolicy)
+	var rowErrors RowErrors
+	if !errors.As(err, &rowErrors) {
+		t.Fatalf("ImportJSON() error = %v, expected RowErrors", err)
+	}
+
+	if len(recordList) != 2 {
+		t.Fatalf("ImportJSON() returned %d records, expected 2", len(recordList))
+	}
+	if a := recordList[0]; a.Name() != "www.example.com." || a.Type() != types.TYPE_A || a.TTL() != 60 {
+		t.Errorf("first record = %v, expected www.example.com. A with TTL 60", a)
+	}
+	if mx, ok := recordList[1].(*records.MXRecord); !ok || mx.TTL() != 3600 || mx.MailServer() != "mail.example.com." {
+		t.Errorf("second record = %v, expected MX with the policy TTL 3600", recordList[1])
+	}
+
+	rows := make([]int, len(rowErrors))
+	for i, rowErr := range rowErrors {
+		rows[i] = rowErr.Row
+	}
+	if len(rows) != 3 || rows[0] != 3 || rows[1] != 4 || rows[2] != 5 {
+		t.Errorf("invalid rows = %v, expected [3 4 5]", rows)
+	}
+	if rowErrors[0].Name != "bad.example.com" {
+		t.Errorf("row 3 name = %q, expected bad.example.com", rowErrors[0].Name)
+	}
+}
+
+func TestImportJSONMalformed(t *testing.T) {
+	for _, input := range []string{`{"name": "www.example.com"}`, `[{"name": `, `not json`} {
+		recordList, err := ImportJSON(strings.NewReader(input), testPolicy)
+		var rowErrors RowErrors
+		if err == nil || errors.As(err, &rowErrors) || recordList != nil {
+			t.Errorf("ImportJSON(%q) = %v, %v, expected a decoding error", input, recordList, err)
+		}
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	input := strings.Join([]string{
+		"name,type,class,ttl,data",
+		"www.example.com,A,IN,60,192.0.2.1",
+		`example.com.,TXT,,,"""v=spf1 -all"""`,
+		"short.example.com,A,IN,60",
+		"ttl.example.com,A,IN,soon,192.0.2.2",
+		"chaos.example.com,A,CH,60,192.0.2.3",
+	}, "\n")
+
+	recordList, err := ImportCSV(strings.NewReader(input), true, testPolicy)
+	var rowErrors RowErrors
+	if !errors.As(err, &rowErrors) {
+		t.Fatalf("ImportCSV() error = %v, expected RowErrors", err)
+	}
+
+	if len(recordList) != 2 {
+		t.Fatalf("ImportCSV() returned %d records, expected 2", len(recordList))
+	}
+	if a := recordList[0]; a.Name() != "www.example.com." || a.TTL() != 60 {
+		t.Errorf("first record = %v, expected www.example.com. with TTL 60", a)
+	}
+	txt, ok := recordList[1].(*records.TXTRecord)
+	if !ok || txt.TTL() != 300 || len(txt.Strings()) != 1 || txt.Strings()[0] != "v=spf1 -all" {
+		t.Errorf("second record = %v, expected TXT v=spf1 -all with the policy TTL 300", recordList[1])
+	}
+
+	rows := make([]int, len(rowErrors))
+	for i, rowErr := range rowErrors {
+		rows[i] = rowErr.Row
+	}
+	if len(rows) != 3 || rows[0] != 4 || rows[1] != 5 || rows[2] != 6 {
+		t.Errorf("invalid lines = %v, expected [4 5 6]", rows)
+	}
+}
+
+func TestImportCSVWithoutHeader(t *testing.T) {
+	recordList, err := ImportCSV(strings.NewReader("www.example.com,AAAA,IN,,2001:db8::1\n"), false, testPolicy)
+	if err != nil {
+		t.Fatalf("ImportCSV() returned error: %v", err)
+	}
+	if len(recordList) != 1 || recordList[0].Type() != types.TYPE_AAAA || recordList[0].TTL() != 300 {
+		t.Errorf("ImportCSV() = %v, expected one AAAA record with TTL 300", recordList)
+	}
+}
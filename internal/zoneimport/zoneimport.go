@@ -0,0 +1,430 @@
+// Package zoneimport reads records from master files in the format written
+// by BIND and zoneexport (RFC 1035 §5), and from JSON and CSV lists
+package zoneimport
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/storage"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// maxTTL is the largest TTL a record can have (RFC 2181 §8)
+const maxTTL = 1<<31 - 1
+
+// TTLPolicy gives the TTL of records that have none and are not preceded
+// by a $TTL directive
+type TTLPolicy struct {
+	Default uint32                   // TTL of types without their own
+	Types   map[types.DNSType]uint32 // TTLs of single record types
+}
+
+// TTL returns the TTL of a recordType record written without one
+func (p TTLPolicy) TTL(recordType types.DNSType) uint32 {
+	if ttl, ok := p.Types[recordType]; ok {
+		return ttl
+	}
+	return p.Default
+}
+
+// nameFields lists the RDATA fields holding domain names, which are made
+// absolute against the origin when written relative to it
+var nameFields = map[types.DNSType][]int{
+	types.TYPE_CNAME: {0},
+	types.TYPE_DNAME: {0},
+	types.TYPE_NS:    {0},
+	types.TYPE_PTR:   {0},
+	types.TYPE_MX:    {1},
+	types.TYPE_SRV:   {3},
+	types.TYPE_SOA:   {0, 1},
+	types.TYPE_NAPTR: {5},
+	types.TYPE_SVCB:  {1},
+	types.TYPE_HTTPS: {1},
+	types.TYPE_NSEC:  {0},
+	types.TYPE_RRSIG: {7},
+}
+
+// token is a field of a master file entry. Quoted tokens keep their quotes
+type token struct {
+	text   string
+	quoted bool
+}
+
+// entry is a master file entry, its parentheses joining lines
+type entry struct {
+	line       int
+	tokens     []token
+	blankOwner bool // The entry starts with a blank, reusing the previous owner
+}
+
+// ReadZone reads the master file in r, whose relative names are relative to
+// origin until a $ORIGIN directive changes it. A record without a TTL takes
+// the one of the last $TTL directive or, before any, the one policy gives
+// its type. Only the IN class is accepted and $INCLUDE is not supported
+func ReadZone(r io.Reader, origin string, policy TTLPolicy) ([]records.DNSRecord, error) {
+	originName, err := absoluteName(origin, ".")
+	if err != nil {
+		return nil, fmt.Errorf("invalid origin %q: %w", origin, err)
+	}
+	origin = originName
+
+	entries, err := readEntries(r)
+	if err != nil {
+		return nil, err
+	}
+
+	converter := storage.NewRecordConverter()
+	var recordList []records.DNSRecord
+	var owner string
+	var defaultTTL uint32
+	hasDefaultTTL := false
+
+	for _, e := range entries {
+		fields := e.tokens
+		switch directive := strings.ToUpper(fields[0].text); {
+		case fields[0].quoted || e.blankOwner || !strings.HasPrefix(directive, "$"):
+		case directive == "$ORIGIN":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: $ORIGIN takes one name", e.line)
+			}
+			if origin, err = absoluteName(fields[1].text, origin); err != nil {
+				return nil, fmt.Errorf("line %d: invalid $ORIGIN: %w", e.line, err)
+			}
+			continue
+		case directive == "$TTL":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: $TTL takes one TTL", e.line)
+			}
+			if defaultTTL, err = parseTTL(fields[1].text); err != nil {
+				return nil, fmt.Errorf("line %d: invalid $TTL: %w", e.line, err)
+			}
+			hasDefaultTTL = true
+			continue
+		default:
+			return nil, fmt.Errorf("line %d: unsupported directive %s", e.line, fields[0].text)
+		}
+
+		if !e.blankOwner {
+			if owner, err = absoluteName(fields[0].text, origin); err != nil {
+				return nil, fmt.Errorf("line %d: invalid owner: %w", e.line, err)
+			}
+			fields = fields[1:]
+		} else if owner == "" {
+			return nil, fmt.Errorf("line %d: record without an owner", e.line)
+		}
+
+		data, err := parseRecord(fields, origin)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", e.line, err)
+		}
+		data.Name = owner
+		if !data.hasTTL {
+			data.TTL = policy.TTL(types.DNSType(data.RecordType))
+			if hasDefaultTTL {
+				data.TTL = defaultTTL
+			}
+		}
+
+		record, err := converter.FromStorageFormat(&data.RecordData)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", e.line, err)
+		}
+		recordList = append(recordList, record)
+	}
+
+	return recordList, nil
+}
+
+// recordData is a parsed record, noting whether its TTL was given
+type recordData struct {
+	storage.RecordData
+	hasTTL bool
+}
+
+// parseRecord parses the fields of a record after its owner: an optional
+// TTL and class in either order, the type and the RDATA
+func parseRecord(fields []token, origin string) (recordData, error) {
+	data := recordData{RecordData: storage.RecordData{Class: int(types.CLASS_IN)}}
+	for len(fields) > 0 && !fields[0].quoted {
+		if class, ok := parseClass(fields[0].text); ok {
+			if class != types.CLASS_IN {
+				return data, fmt.Errorf("unsupported class %s", fields[0].text)
+			}
+		} else if isDigit(fields[0].text[0]) && !data.hasTTL {
+			ttl, err := parseTTL(fields[0].text)
+			if err != nil {
+				return data, err
+			}
+			data.TTL, data.hasTTL = ttl, true
+		} else {
+			break
+		}
+		fields = fields[1:]
+	}
+
+	if len(fields) == 0 || fields[0].quoted {
+		return data, fmt.Errorf("record without a type")
+	}
+	recordType, ok := types.ParseDNSType(fields[0].text)
+	if !ok {
+		return data, fmt.Errorf("unknown record type %s", fields[0].text)
+	}
+	data.RecordType = int(recordType)
+
+	rdata, err := parseRData(recordType, fields[1:], origin)
+	if err != nil {
+		return data, fmt.Errorf("invalid %s record: %w", recordType.Mnemonic(), err)
+	}
+	data.Data = rdata
+	return data, nil
+}
+
+// parseRData returns the RDATA fields in the format the storage converter
+// reads, with names made absolute. RDATA in the generic form of RFC 3597,
+// such as `\# 4 0A000001`, is kept as it is
+func parseRData(recordType types.DNSType, fields []token, origin string) (string, error) {
+	if len(fields) == 0 {
+		return "", fmt.Errorf("missing RDATA")
+	}
+
+	rdata := make([]string, len(fields))
+	for i, field := range fields {
+		rdata[i] = field.text
+	}
+
+	// RDATA in the generic form of RFC 3597 is stored as it is
+	if !fields[0].quoted && fields[0].text == `\#` {
+		return strings.Join(rdata, " "), nil
+	}
+
+	for _, i := range nameFields[recordType] {
+		if i >= len(fields) || fields[i].quoted {
+			continue
+		}
+		name, err := absoluteName(fields[i].text, origin)
+		if err != nil {
+			return "", err
+		}
+		rdata[i] = name
+	}
+
+	switch recordType {
+	case types.TYPE_SOA:
+		// Timers may be written with units, as TTLs are
+		for i := 3; i < len(fields) && i < 7; i++ {
+			seconds, err := parseTTL(fields[i].text)
+			if err != nil {
+				return "", err
+			}
+			rdata[i] = strconv.FormatUint(uint64(seconds), 10)
+		}
+	case types.TYPE_TXT:
+		// Unquoted words are character strings of their own
+		for i, field := range fields {
+			if !field.quoted {
+				rdata[i] = `"` + field.text + `"`
+			}
+		}
+	case types.TYPE_CAA:
+		// Stored CAA values are not quoted
+		if len(fields) == 3 && fields[2].quoted {
+			strs, err := records.ParseCharacterStrings(fields[2].text)
+			if err != nil {
+				return "", err
+			}
+			rdata[2] = strings.Join(strs, "")
+		}
+	}
+
+	return strings.Join(rdata, " "), nil
+}
+
+// readEntries splits a master file into entries. Comments are dropped and
+// the lines between parentheses are joined
+func readEntries(r io.Reader) ([]entry, error) {
+	var entries []entry
+	var current entry
+	depth := 0
+	lineNumber := 0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNumber++
+		if depth == 0 {
+			current = entry{line: lineNumber, blankOwner: line != "" && (line[0] == ' ' || line[0] == '\t')}
+		}
+
+		for i := 0; i < len(line); {
+			switch char := line[i]; {
+			case char == ' ' || char == '\t' || char == '\r':
+				i++
+			case char == ';':
+				i = len(line)
+			case char == '(':
+				depth++
+				i++
+			case char == ')':
+				if depth == 0 {
+					return nil, fmt.Errorf("line %d: unbalanced parentheses", lineNumber)
+				}
+				depth--
+				i++
+			case char == '"':
+				end := closingQuote(line, i)
+				if end < 0 {
+					return nil, fmt.Errorf("line %d: unterminated quoted string", lineNumber)
+				}
+				current.tokens = append(current.tokens, token{text: line[i : end+1], quoted: true})
+				i = end + 1
+			default:
+				end := i
+				for end < len(line) && !strings.ContainsRune(" \t\r;()\"", rune(line[end])) {
+					if line[end] == '\\' && end+1 < len(line) {
+						end++
+					}
+					end++
+				}
+				current.tokens = append(current.tokens, token{text: line[i:end]})
+				i = end
+			}
+		}
+
+		if depth == 0 && len(current.tokens) > 0 {
+			entries = append(entries, current)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read zone: %w", err)
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("line %d: unbalanced parentheses", current.line)
+	}
+
+	return entries, nil
+}
+
+// closingQuote returns the index of the quote ending the quoted string
+// starting at start, or -1 when the line ends first
+func closingQuote(line string, start int) int {
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// absoluteName returns name made absolute against origin. "@" stands for
+// the origin and names ending in an unescaped dot are already absolute
+func absoluteName(name, origin string) (string, error) {
+	switch {
+	case name == "@":
+		name = origin
+	case isAbsolute(name):
+	case origin == ".":
+		name += "."
+	default:
+		name += "." + origin
+	}
+
+	parsed, err := utils.ParseDomainName(name)
+	if err != nil {
+		return "", err
+	}
+	return parsed.String(), nil
+}
+
+// isAbsolute reports whether name ends in a dot that is not escaped
+func isAbsolute(name string) bool {
+	if !strings.HasSuffix(name, ".") {
+		return false
+	}
+	escapes := 0
+	for i := len(name) - 2; i >= 0 && name[i] == '\\'; i-- {
+		escapes++
+	}
+	return escapes%2 == 0
+}
+
+// parseClass returns the class named by a mnemonic such as "IN" or by the
+// generic CLASSnnn form
+func parseClass(name string) (types.DNSClass, bool) {
+	name = strings.ToUpper(name)
+	if number, ok := strings.CutPrefix(name, "CLASS"); ok {
+		value, err := strconv.ParseUint(number, 10, 16)
+		return types.DNSClass(value), err == nil
+	}
+	switch name {
+	case "IN":
+		return types.CLASS_IN, true
+	case "CS":
+		return types.CLASS_CS, true
+	case "CH":
+		return types.CLASS_CH, true
+	case "HS":
+		return types.CLASS_HS, true
+	}
+	return 0, false
+}
+
+// ttlUnits are the units of TTLs written as BIND does, such as 1h30m
+var ttlUnits = map[byte]time.Duration{
+	's': time.Second,
+	'm': time.Minute,
+	'h': time.Hour,
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+}
+
+// parseTTL parses a TTL given in seconds or with units, such as 1h30m
+func parseTTL(value string) (uint32, error) {
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		if seconds > maxTTL {
+			return 0, fmt.Errorf("TTL %s exceeds %d", value, maxTTL)
+		}
+		return uint32(seconds), nil
+	}
+
+	var total time.Duration
+	rest := strings.ToLower(value)
+	for rest != "" {
+		digits := 0
+		for digits < len(rest) && isDigit(rest[digits]) {
+			digits++
+		}
+		if digits == 0 || digits == len(rest) {
+			return 0, fmt.Errorf("invalid TTL %q", value)
+		}
+		unit, ok := ttlUnits[rest[digits]]
+		if !ok {
+			return 0, fmt.Errorf("invalid TTL unit in %q", value)
+		}
+		count, err := strconv.ParseUint(rest[:digits], 10, 32)
+		if err != nil || count > maxTTL {
+			return 0, fmt.Errorf("TTL %s exceeds %d", value, maxTTL)
+		}
+		total += time.Duration(count) * unit
+		if total > maxTTL*time.Second {
+			return 0, fmt.Errorf("TTL %s exceeds %d", value, maxTTL)
+		}
+		rest = rest[digits+1:]
+	}
+	return uint32(total / time.Second), nil
+}
+
+// isDigit reports whether char is an ASCII digit
+func isDigit(char byte) bool {
+	return char >= '0' && char <= '9'
+}
@@ -0,0 +1,231 @@
+package zoneimport
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/zoneexport"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// testPolicy gives SOA and MX records an hour and other types five minutes
+var testPolicy = TTLPolicy{
+	Default: 300,
+	Types: map[types.DNSType]uint32{
+		types.TYPE_SOA: 3600,
+		types.TYPE_MX:  3600,
+	},
+}
+
+func TestReadZoneTypeTTLs(t *testing.T) {
+	zone := strings.Join([]string{
+		"@ IN SOA ns1 hostmaster 2024010101 3600 600 604800 300",
+		"  IN NS ns1",
+		"  IN MX 10 mail",
+		"ns1 IN A 192.0.2.53",
+		"mail IN A 192.0.2.25",
+	}, "\n")
+
+	recordList, err := ReadZone(strings.NewReader(zone), "example.com", testPolicy)
+	if err != nil {
+		t.Fatalf("ReadZone() returned error: %v", err)
+	}
+
+	expected := []struct {
+		name       string
+		recordType types.DNSType
+		ttl        uint32
+	}{
+		{"example.com.", types.TYPE_SOA, 3600},
+		{"example.com.", types.TYPE_NS, 300},
+		{"example.com.", types.TYPE_MX, 3600},
+		{"ns1.example.com.", types.TYPE_A, 300},
+		{"mail.example.com.", types.TYPE_A, 300},
+	}
+	if len(recordList) != len(expected) {
+		t.Fatalf("ReadZone() returned %d records, expected %d", len(recordList), len(expected))
+	}
+	for i, want := range expected {
+		record := recordList[i]
+		if record.Name() != want.name || record.Type() != want.recordType || record.TTL() != want.ttl {
+			t.Errorf("record %d is %s %s TTL %d, expected %s %s TTL %d", i,
+				record.Name(), record.Type(), record.TTL(), want.name, want.recordType, want.ttl)
+		}
+	}
+
+	mx, ok := recordList[2].(*records.MXRecord)
+	if !ok || mx.MailServer() != "mail.example.com." || mx.Preference() != 10 {
+		t.Errorf("MX record = %v, expected 10 mail.example.com.", recordList[2])
+	}
+}
+
+func TestReadZoneTTLInheritance(t *testing.T) {
+	zone := strings.Join([]string{
+		"www IN A 192.0.2.1      ; before $TTL, from the policy",
+		"$TTL 1h30m",
+		"www IN MX 10 mail       ; $TTL over the policy of MX",
+		"www 60 IN A 192.0.2.2   ; explicit TTL",
+		"    IN 2d AAAA 2001:db8::1",
+		"$TTL 120",
+		"www IN TXT hello world",
+	}, "\n")
+
+	recordList, err := ReadZone(strings.NewReader(zone), "example.com.", testPolicy)
+	if err != nil {
+		t.Fatalf("ReadZone() returned error: %v", err)
+	}
+
+	expected := []uint32{300, 5400, 60, 172800, 120}
+	if len(recordList) != len(expected) {
+		t.Fatalf("ReadZone() returned %d records, expected %d", len(recordList), len(expected))
+	}
+	for i, ttl := range expected {
+		if recordList[i].TTL() != ttl {
+			t.Errorf("record %d (%s) TTL = %d, expected %d", i, recordList[i].Type(), recordList[i].TTL(), ttl)
+		}
+	}
+
+	txt, ok := recordList[4].(*records.TXTRecord)
+	if !ok || len(txt.Strings()) != 2 || txt.Strings()[0] != "hello" || txt.Strings()[1] != "world" {
+		t.Errorf("TXT record = %v, expected the strings hello and world", recordList[4])
+	}
+}
+
+func TestReadZoneSyntax(t *testing.T) {
+	zone := strings.Join([]string{
+		"$ORIGIN example.com.",
+		"@ IN SOA ns1.example.com. hostmaster (",
+		"        2024010101 ; serial",
+		"        1h 10m 1w 5m )",
+		"$ORIGIN sub.example.com.",
+		"alias IN CNAME @",
+		`txt IN TXT "a; not a comment" "say \"hi\""`,
+		"_sip._tcp IN SRV 10 20 5060 sip.example.net.",
+		`@ IN CAA 0 issue "ca.example.net"`,
+	}, "\n")
+
+	recordList, err := ReadZone(strings.NewReader(zone), "ignored.example", testPolicy)
+	if err != nil {
+		t.Fatalf("ReadZone() returned error: %v", err)
+	}
+	if len(recordList) != 5 {
+		t.Fatalf("ReadZone() returned %d records, expected 5", len(recordList))
+	}
+
+	soa, ok := recordList[0].(*records.SOARecord)
+	if !ok {
+		t.Fatalf("first record is %T, expected *records.SOARecord", recordList[0])
+	}
+	if soa.Responsible() != "hostmaster.example.com." || soa.Serial() != 2024010101 ||
+		soa.Refresh() != time.Hour || soa.Expire() != 7*24*time.Hour || soa.Minimum() != 5*time.Minute {
+		t.Errorf("SOA record = %v, expected hostmaster.example.com. 2024010101 1h 10m 1w 5m", soa)
+	}
+
+	if cname, ok := recordList[1].(*records.CNAMERecord); !ok || cname.Name() != "alias.sub.example.com." || cname.Target() != "sub.example.com." {
+		t.Errorf("CNAME record = %v, expected alias.sub.example.com. to sub.example.com.", recordList[1])
+	}
+
+	txt, ok := recordList[2].(*records.TXTRecord)
+	if !ok || len(txt.Strings()) != 2 || txt.Strings()[0] != "a; not a comment" || txt.Strings()[1] != `say "hi"` {
+		t.Errorf("TXT record = %v, expected the quoted strings", recordList[2])
+	}
+
+	if srv, ok := recordList[3].(*records.SRVRecord); !ok || srv.Name() != "_sip._tcp.sub.example.com." || srv.Port() != 5060 {
+		t.Errorf("SRV record = %v, expected _sip._tcp.sub.example.com. port 5060", recordList[3])
+	}
+
+	if caa, ok := recordList[4].(*records.CAARecord); !ok || caa.Tag() != "issue" || caa.Value() != "ca.example.net" {
+		t.Errorf("CAA record = %v, expected issue ca.example.net", recordList[4])
+	}
+}
+
+func TestReadZoneErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		zone string
+	}{
+		{name: "no owner", zone: "  IN A 192.0.2.1"},
+		{name: "unknown type", zone: "www IN BOGUS 192.0.2.1"},
+		{name: "other class", zone: "www CH A 192.0.2.1"},
+		{name: "include", zone: "$INCLUDE other.zone"},
+		{name: "TTL too large", zone: "$TTL 4294967295"},
+		{name: "bad TTL unit", zone: "$TTL 1y"},
+		{name: "unbalanced parentheses", zone: "@ IN SOA ns1 hostmaster ( 1 2 3 4 5"},
+		{name: "unterminated string", zone: `www IN TXT "open`},
+		{name: "bad RDATA", zone: "www IN A not-an-address"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadZone(strings.NewReader(tt.zone), "example.com", testPolicy); err == nil {
+				t.Errorf("ReadZone(%q) succeeded, expected an error", tt.zone)
+			}
+		})
+	}
+}
+
+func TestReadZoneExported(t *testing.T) {
+	zone := []records.DNSRecord{
+		records.NewSOARecord("example.com.", "ns1.example.com.", "hostmaster.example.com.",
+			2024010101, time.Hour, 10*time.Minute, 7*24*time.Hour, 5*time.Minute, 3600),
+		records.NewNSRecord("example.com.", "ns1.example.com.", 3600),
+		records.NewARecord("www.example.com.", net.ParseIP("192.0.2.80"), 300),
+		records.NewTXTRecordFromStrings("www.example.com.", []string{`say "hi"`, "caf\xc3\xa9"}, 300),
+		records.NewARecord("ns1.example.org.", net.ParseIP("198.51.100.53"), 3600),
+		records.NewGenericRecord("private.example.com.", types.DNSType(65280), []byte{0x0a, 0x00, 0x00, 0x01}, 300),
+	}
+
+	var out strings.Builder
+	if err := zoneexport.WriteZone(&out, "example.com", zone); err != nil {
+		t.Fatalf("WriteZone() returned error: %v", err)
+	}
+
+	recordList, err := ReadZone(strings.NewReader(out.String()), "example.com", TTLPolicy{})
+	if err != nil {
+		t.Fatalf("ReadZone() returned error: %v\n%s", err, out.String())
+	}
+	if len(recordList) != len(zone) {
+		t.Fatalf("ReadZone() returned %d records, expected %d", len(recordList), len(zone))
+	}
+
+	for _, want := range zone {
+		found := false
+		for _, record := range recordList {
+			if record.Name() == want.Name() && record.Type() == want.Type() &&
+				record.TTL() == want.TTL() && string(record.Data()) == string(want.Data()) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("record %s %s was not read back from:\n%s", want.Name(), want.Type(), out.String())
+		}
+	}
+}
+
+func TestParseTTL(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected uint32
+	}{
+		{"0", 0},
+		{"3600", 3600},
+		{"1h", 3600},
+		{"1H30M", 5400},
+		{"1w2d", 777600},
+		{"2147483647", 2147483647},
+	}
+
+	for _, tt := range tests {
+		ttl, err := parseTTL(tt.input)
+		if err != nil {
+			t.Errorf("parseTTL(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if ttl != tt.expected {
+			t.Errorf("parseTTL(%q) = %d, expected %d", tt.input, ttl, tt.expected)
+		}
+	}
+}
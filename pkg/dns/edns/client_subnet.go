@@ -0,0 +1,119 @@
+package edns
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// Address families of the EDNS Client Subnet option (IANA address family
+// numbers)
+const (
+	FAMILY_IPV4 uint16 = 1
+	FAMILY_IPV6 uint16 = 2
+)
+
+// ClientSubnet is the EDNS Client Subnet option (RFC 7871 §6): the network a
+// query originates from, which resolvers pass on so that authoritative
+// servers can tailor their answers to it. Responses set ScopePrefixLength to
+// the length of the network the answer is valid for
+type ClientSubnet struct {
+	Family             uint16
+	SourcePrefixLength uint8
+	ScopePrefixLength  uint8
+	Address            net.IP // 4 bytes for IPv4, 16 for IPv6, zero past the source prefix
+}
+
+// NewClientSubnet returns the option for the network of ip with the given
+// prefix length, capped to the address length
+func NewClientSubnet(ip net.IP, sourcePrefixLength uint8) *ClientSubnet {
+	family, address := FAMILY_IPV6, ip.To16()
+	if ip4 := ip.To4(); ip4 != nil {
+		family, address = FAMILY_IPV4, ip4
+	}
+	sourcePrefixLength = min(sourcePrefixLength, uint8(len(address)*8))
+
+	return &ClientSubnet{
+		Family:             family,
+		SourcePrefixLength: sourcePrefixLength,
+		Address:            address.Mask(net.CIDRMask(int(sourcePrefixLength), len(address)*8)),
+	}
+}
+
+// ParseClientSubnet decodes the data of an EDNS Client Subnet option. As
+// RFC 7871 §6 requires, the address must be sent in the fewest bytes holding
+// the source prefix, with the bits past it zero
+func ParseClientSubnet(data []byte) (*ClientSubnet, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("invalid client subnet option: %d bytes, need at least 4", len(data))
+	}
+
+	subnet := &ClientSubnet{
+		Family:             binary.BigEndian.Uint16(data[0:2]),
+		SourcePrefixLength: data[2],
+		ScopePrefixLength:  data[3],
+	}
+
+	var size int
+	switch subnet.Family {
+	case FAMILY_IPV4:
+		size = net.IPv4len
+	case FAMILY_IPV6:
+		size = net.IPv6len
+	default:
+		return nil, fmt.Errorf("invalid client subnet option: unknown family %d", subnet.Family)
+	}
+	if int(subnet.SourcePrefixLength) > size*8 || int(subnet.ScopePrefixLength) > size*8 {
+		return nil, fmt.Errorf("invalid client subnet option: prefix lengths %d and %d exceed %d bits",
+			subnet.SourcePrefixLength, subnet.ScopePrefixLength, size*8)
+	}
+
+	address := data[4:]
+	if len(address) != prefixBytes(subnet.SourcePrefixLength) {
+		return nil, fmt.Errorf("invalid client subnet option: %d address bytes for a /%d prefix", len(address), subnet.SourcePrefixLength)
+	}
+
+	subnet.Address = make(net.IP, size)
+	copy(subnet.Address, address)
+	if !subnet.Address.Mask(net.CIDRMask(int(subnet.SourcePrefixLength), size*8)).Equal(subnet.Address) {
+		return nil, fmt.Errorf("invalid client subnet option: address %s has bits set past /%d", subnet.Address, subnet.SourcePrefixLength)
+	}
+
+	return subnet, nil
+}
+
+// Bytes returns the option data, with the address truncated to the source
+// prefix
+func (c *ClientSubnet) Bytes() []byte {
+	data := binary.BigEndian.AppendUint16(nil, c.Family)
+	data = append(data, c.SourcePrefixLength, c.ScopePrefixLength)
+
+	address := make(net.IP, net.IPv6len)
+	copy(address, c.Address.To16())
+	if c.Family == FAMILY_IPV4 {
+		address = address[net.IPv6len-net.IPv4len:]
+	}
+	return append(data, address[:min(prefixBytes(c.SourcePrefixLength), len(address))]...)
+}
+
+// Option returns the subnet as an EDNS option
+func (c *ClientSubnet) Option() Option {
+	return Option{Code: OPTION_CLIENT_SUBNET, Data: c.Bytes()}
+}
+
+// IP returns the address of the subnet, which is used in place of the
+// address of the client the query came from
+func (c *ClientSubnet) IP() net.IP {
+	return c.Address
+}
+
+// String returns the subnet in CIDR notation followed by the scope, as in
+// "192.0.2.0/24/0"
+func (c *ClientSubnet) String() string {
+	return fmt.Sprintf("%s/%d/%d", c.Address, c.SourcePrefixLength, c.ScopePrefixLength)
+}
+
+// prefixBytes returns the number of bytes holding a prefix of length bits
+func prefixBytes(length uint8) int {
+	return (int(length) + 7) / 8
+}
@@ -0,0 +1,113 @@
+package edns
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestClientSubnet(t *testing.T) {
+	tests := []struct {
+		name    string
+		subnet  *ClientSubnet
+		wire    []byte
+		address string
+	}{
+		{
+			name:    "IPv4 /24",
+			subnet:  NewClientSubnet(net.ParseIP("192.0.2.77"), 24),
+			wire:    []byte{0x00, 0x01, 24, 0, 192, 0, 2},
+			address: "192.0.2.0",
+		},
+		{
+			name:    "IPv4 /20",
+			subnet:  NewClientSubnet(net.ParseIP("198.51.100.1"), 20),
+			wire:    []byte{0x00, 0x01, 20, 0, 198, 51, 96},
+			address: "198.51.96.0",
+		},
+		{
+			name:    "IPv4 /0",
+			subnet:  NewClientSubnet(net.ParseIP("192.0.2.1"), 0),
+			wire:    []byte{0x00, 0x01, 0, 0},
+			address: "0.0.0.0",
+		},
+		{
+			name:    "IPv6 /56",
+			subnet:  NewClientSubnet(net.ParseIP("2001:db8:1:2ff::1"), 56),
+			wire:    []byte{0x00, 0x02, 56, 0, 0x20, 0x01, 0x0d, 0xb8, 0x00, 0x01, 0x02},
+			address: "2001:db8:1:200::",
+		},
+		{
+			name:    "IPv6 prefix capped",
+			subnet:  NewClientSubnet(net.ParseIP("2001:db8::1"), 200),
+			wire:    append([]byte{0x00, 0x02, 128, 0}, net.ParseIP("2001:db8::1")...),
+			address: "2001:db8::1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.subnet.Bytes(); !bytes.Equal(got, tt.wire) {
+				t.Errorf("Bytes() = %v, want %v", got, tt.wire)
+			}
+
+			parsed, err := ParseClientSubnet(tt.wire)
+			if err != nil {
+				t.Fatalf("ParseClientSubnet() returned error: %v", err)
+			}
+			if parsed.Family != tt.subnet.Family || parsed.SourcePrefixLength != tt.subnet.SourcePrefixLength ||
+				parsed.ScopePrefixLength != 0 || parsed.IP().String() != tt.address {
+				t.Errorf("ParseClientSubnet() = %s, want %s", parsed, tt.subnet)
+			}
+		})
+	}
+}
+
+func TestParseClientSubnet_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "short", data: []byte{0x00, 0x01, 24}},
+		{name: "unknown family", data: []byte{0x00, 0x03, 8, 0, 10}},
+		{name: "IPv4 prefix too long", data: []byte{0x00, 0x01, 33, 0, 192, 0, 2, 1, 0}},
+		{name: "scope too long", data: []byte{0x00, 0x01, 24, 33, 192, 0, 2}},
+		{name: "address too long", data: []byte{0x00, 0x01, 24, 0, 192, 0, 2, 0}},
+		{name: "address too short", data: []byte{0x00, 0x01, 24, 0, 192, 0}},
+		{name: "bits past the prefix", data: []byte{0x00, 0x01, 20, 0, 198, 51, 100}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if subnet, err := ParseClientSubnet(tt.data); err == nil {
+				t.Errorf("ParseClientSubnet() = %s, expected an error", subnet)
+			}
+		})
+	}
+}
+
+func TestOptions(t *testing.T) {
+	options := []Option{
+		{Code: 10, Data: []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+		NewClientSubnet(net.ParseIP("192.0.2.1"), 24).Option(),
+		{Code: 12},
+	}
+	rdata := PackOptions(options)
+
+	parsed, err := ParseOptions(rdata)
+	if err != nil {
+		t.Fatalf("ParseOptions() returned error: %v", err)
+	}
+	if len(parsed) != len(options) {
+		t.Fatalf("ParseOptions() returned %d options, want %d", len(parsed), len(options))
+	}
+	for i, option := range parsed {
+		if option.Code != options[i].Code || !bytes.Equal(option.Data, options[i].Data) {
+			t.Errorf("option %d = %+v, want %+v", i, option, options[i])
+		}
+	}
+
+	if _, err := ParseOptions(rdata[:len(rdata)-9]); err == nil {
+		t.Error("ParseOptions() accepted a truncated option")
+	}
+}
@@ -0,0 +1,63 @@
+package edns
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// Sizes of the cookies of a COOKIE option (RFC 7873 §4)
+const (
+	ClientCookieSize    = 8
+	MinServerCookieSize = 8
+	MaxServerCookieSize = 32
+)
+
+// Cookie is the DNS Cookie option (RFC 7873 §4): a client cookie, with the
+// server cookie the server last returned to the client, if any. Servers
+// return the client cookie with a server cookie computed from it and the
+// client address, proving to the client that the response is not forged
+// and to the server, once the client sends it back, that the client
+// address is not spoofed
+type Cookie struct {
+	Client []byte // ClientCookieSize bytes
+	Server []byte // Empty or MinServerCookieSize to MaxServerCookieSize bytes
+}
+
+// ParseCookie decodes the data of a COOKIE option
+func ParseCookie(data []byte) (*Cookie, error) {
+	if len(data) < ClientCookieSize {
+		return nil, fmt.Errorf("invalid cookie option: %d bytes, need at least %d", len(data), ClientCookieSize)
+	}
+
+	server := data[ClientCookieSize:]
+	if len(server) > 0 && (len(server) < MinServerCookieSize || len(server) > MaxServerCookieSize) {
+		return nil, fmt.Errorf("invalid cookie option: server cookie of %d bytes, need %d to %d",
+			len(server), MinServerCookieSize, MaxServerCookieSize)
+	}
+
+	cookie := &Cookie{Client: append([]byte(nil), data[:ClientCookieSize]...)}
+	if len(server) > 0 {
+		cookie.Server = append([]byte(nil), server...)
+	}
+	return cookie, nil
+}
+
+// Bytes returns the option data
+func (c *Cookie) Bytes() []byte {
+	data := make([]byte, 0, len(c.Client)+len(c.Server))
+	return append(append(data, c.Client...), c.Server...)
+}
+
+// Option returns the cookie as an EDNS option
+func (c *Cookie) Option() Option {
+	return Option{Code: OPTION_COOKIE, Data: c.Bytes()}
+}
+
+// String returns the cookies in hexadecimal, separated by a slash when
+// there is a server cookie
+func (c *Cookie) String() string {
+	if len(c.Server) == 0 {
+		return hex.EncodeToString(c.Client)
+	}
+	return hex.EncodeToString(c.Client) + "/" + hex.EncodeToString(c.Server)
+}
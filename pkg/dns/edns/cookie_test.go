@@ -0,0 +1,44 @@
+package edns
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCookie(t *testing.T) {
+	client := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		invalid bool
+	}{
+		{name: "client cookie", data: client},
+		{name: "minimal server cookie", data: append(append([]byte{}, client...), bytes.Repeat([]byte{9}, MinServerCookieSize)...)},
+		{name: "maximal server cookie", data: append(append([]byte{}, client...), bytes.Repeat([]byte{9}, MaxServerCookieSize)...)},
+		{name: "short client cookie", data: client[:7], invalid: true},
+		{name: "short server cookie", data: append(append([]byte{}, client...), 9), invalid: true},
+		{name: "long server cookie", data: append(append([]byte{}, client...), bytes.Repeat([]byte{9}, MaxServerCookieSize+1)...), invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cookie, err := ParseCookie(tt.data)
+			if tt.invalid {
+				if err == nil {
+					t.Fatalf("ParseCookie() accepted %d bytes", len(tt.data))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCookie() returned error: %v", err)
+			}
+			if !bytes.Equal(cookie.Client, client) {
+				t.Errorf("Client = %x, want %x", cookie.Client, client)
+			}
+			if option := cookie.Option(); option.Code != OPTION_COOKIE || !bytes.Equal(option.Data, tt.data) {
+				t.Errorf("Option() = %d %x, want %d %x", option.Code, option.Data, OPTION_COOKIE, tt.data)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+// Package edns implements the options carried in the RDATA of the EDNS(0)
+// OPT pseudo-record (RFC 6891 §6.1.2), such as the EDNS Client Subnet option
+// (RFC 7871) and DNS Cookies (RFC 7873)
+package edns
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Option codes
+const (
+	OPTION_CLIENT_SUBNET uint16 = 8  // EDNS Client Subnet (RFC 7871)
+	OPTION_COOKIE        uint16 = 10 // DNS Cookie (RFC 7873)
+)
+
+// Option is a single EDNS option
+type Option struct {
+	Code uint16
+	Data []byte
+}
+
+// ParseOptions splits the RDATA of an OPT record into its options
+func ParseOptions(rdata []byte) ([]Option, error) {
+	var options []Option
+	for len(rdata) > 0 {
+		if len(rdata) < 4 {
+			return nil, fmt.Errorf("invalid EDNS option: %d bytes left, need 4 for the header", len(rdata))
+		}
+		code := binary.BigEndian.Uint16(rdata[0:2])
+		length := int(binary.BigEndian.Uint16(rdata[2:4]))
+		if len(rdata) < 4+length {
+			return nil, fmt.Errorf("invalid EDNS option %d: length %d exceeds the %d bytes left", code, length, len(rdata)-4)
+		}
+
+		options = append(options, Option{Code: code, Data: rdata[4 : 4+length]})
+		rdata = rdata[4+length:]
+	}
+	return options, nil
+}
+
+// PackOptions returns the RDATA of an OPT record carrying options
+func PackOptions(options []Option) []byte {
+	var rdata []byte
+	for _, option := range options {
+		rdata = binary.BigEndian.AppendUint16(rdata, option.Code)
+		rdata = binary.BigEndian.AppendUint16(rdata, uint16(len(option.Data)))
+		rdata = append(rdata, option.Data...)
+	}
+	return rdata
+}
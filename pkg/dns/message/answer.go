@@ -2,7 +2,9 @@ package message
 
 import (
 	"fmt"
+	"net"
 
+	"github.com/vadim-su/dnska/pkg/dns/records"
 	"github.com/vadim-su/dnska/pkg/dns/types"
 	"github.com/vadim-su/dnska/pkg/dns/utils"
 )
@@ -32,6 +34,121 @@ func NewDNSAnswer(name []byte, class types.DNSClass, type_ types.DNSType, ttl ui
 	}, nil
 }
 
+// NewDNSAnswerFromParts creates a DNS answer from already parsed fields
+func NewDNSAnswerFromParts(name utils.DomainName, t types.DNSType, c types.DNSClass, ttl uint32, data []byte) *DNSAnswer {
+	answer := &DNSAnswer{
+		name:  name,
+		class: types.DnsTypeClassToBytes(c),
+		type_: types.DnsTypeClassToBytes(t),
+		data:  data,
+	}
+	answer.SetTTL(ttl)
+	return answer
+}
+
+// NewAAnswer creates an address record for ip. IPv4 addresses, including
+// IPv4-mapped IPv6 ones, produce a TYPE_A record with 4 bytes of RDATA; other
+// 16-byte addresses produce a TYPE_AAAA record
+func NewAAnswer(name utils.DomainName, ip net.IP, ttl uint32, class [2]byte) (*DNSAnswer, error) {
+	if len(ip) != net.IPv4len && len(ip) != net.IPv6len {
+		return nil, fmt.Errorf("can't create address answer: invalid IP length %d", len(ip))
+	}
+
+	type_ := types.TYPE_AAAA
+	data := ip.To16()
+	if ip4 := ip.To4(); ip4 != nil {
+		type_ = types.TYPE_A
+		data = ip4
+	}
+
+	return &DNSAnswer{
+		name:  name,
+		class: class,
+		type_: types.DnsTypeClassToBytes(type_),
+		ttl:   [4]byte{byte(ttl >> 24), byte(ttl >> 16), byte(ttl >> 8), byte(ttl)},
+		data:  append([]byte(nil), data...),
+	}, nil
+}
+
+// NewMXAnswer creates an MX record whose RDATA holds the preference followed
+// by the uncompressed exchange name
+func NewMXAnswer(name utils.DomainName, preference uint16, exchange utils.DomainName, ttl uint32, class [2]byte) *DNSAnswer {
+	data := []byte{byte(preference >> 8), byte(preference)}
+	data = append(data, exchange.ToBytes()...)
+
+	answer := &DNSAnswer{
+		name:  name,
+		class: class,
+		type_: types.DnsTypeClassToBytes(types.TYPE_MX),
+		data:  data,
+	}
+	answer.SetTTL(ttl)
+	return answer
+}
+
+// SOARData holds the fields of SOA RDATA (RFC 1035 §3.3.13)
+type SOARData struct {
+	MNAME   utils.DomainName // Primary name server
+	RNAME   utils.DomainName // Responsible mailbox
+	Serial  uint32
+	Refresh uint32
+	Retry   uint32
+	Expire  uint32
+	Minimum uint32
+}
+
+// soaTimersLength is the size of the five 32-bit SOA fields following the names
+const soaTimersLength = 20
+
+// NewSOAAnswer creates an IN SOA record with uncompressed names in its RDATA
+func NewSOAAnswer(name utils.DomainName, soa SOARData, ttl uint32) *DNSAnswer {
+	data := soa.MNAME.ToBytes()
+	data = append(data, soa.RNAME.ToBytes()...)
+	for _, field := range []uint32{soa.Serial, soa.Refresh, soa.Retry, soa.Expire, soa.Minimum} {
+		data = append(data, byte(field>>24), byte(field>>16), byte(field>>8), byte(field))
+	}
+
+	return NewDNSAnswerFromParts(name, types.TYPE_SOA, types.CLASS_IN, ttl, data)
+}
+
+// SRVRData holds the fields of SRV RDATA (RFC 2782)
+type SRVRData struct {
+	Priority uint16
+	Weight   uint16
+	Port     uint16
+	Target   utils.DomainName
+}
+
+// srvFixedLength is the size of the priority, weight and port preceding the target
+const srvFixedLength = 6
+
+// CAARData holds the fields of CAA RDATA (RFC 8659 §4.1)
+type CAARData struct {
+	Flags uint8
+	Tag   string
+	Value string
+}
+
+// maxCAATagLength is the longest property tag allowed by RFC 8659 §4.1
+const maxCAATagLength = 15
+
+// NAPTRRData holds the fields of NAPTR RDATA (RFC 2915 §2)
+type NAPTRRData struct {
+	Order       uint16
+	Preference  uint16
+	Flags       string
+	Services    string
+	Regexp      string
+	Replacement utils.DomainName
+}
+
+// SVCBRData holds the fields of SVCB and HTTPS RDATA (RFC 9460 §2.2)
+type SVCBRData struct {
+	Priority   uint16
+	TargetName utils.DomainName
+	Params     []records.SvcParam
+}
+
 // Create multiple DNS answers from raw data
 func NewDNSAnswers(data []byte, count uint16, originalMessage []byte) ([]DNSAnswer, uint16, error) {
 	resultAnswers := make([]DNSAnswer, 0)
@@ -50,8 +167,8 @@ func NewDNSAnswers(data []byte, count uint16, originalMessage []byte) ([]DNSAnsw
 			return nil, 0, fmt.Errorf("insufficient data for DNS answer fields: need 10 bytes, have %d", len(data))
 		}
 
-		class := [2]byte{data[0], data[1]}
-		type_ := [2]byte{data[2], data[3]}
+		type_ := [2]byte{data[0], data[1]}
+		class := [2]byte{data[2], data[3]}
 		ttl := [4]byte{data[4], data[5], data[6], data[7]}
 
 		dataLength := uint16(data[8])<<8 | uint16(data[9])
@@ -79,13 +196,303 @@ func NewDNSAnswers(data []byte, count uint16, originalMessage []byte) ([]DNSAnsw
 	return resultAnswers, answersDataSize, nil
 }
 
+// Name returns the owner name of the record
+func (d *DNSAnswer) Name() utils.DomainName {
+	return d.name
+}
+
+// WithName returns a copy of the record owned by name, such as the question
+// name in the case a client asked for it. The copy shares the RDATA
+func (d *DNSAnswer) WithName(name utils.DomainName) DNSAnswer {
+	answer := *d
+	answer.name = name
+	return answer
+}
+
+// Type returns the TYPE field of the record
+func (d *DNSAnswer) Type() types.DNSType {
+	return types.DNSType(uint16(d.type_[0])<<8 | uint16(d.type_[1]))
+}
+
+// Class returns the CLASS field of the record
+func (d *DNSAnswer) Class() types.DNSClass {
+	return types.DNSClass(uint16(d.class[0])<<8 | uint16(d.class[1]))
+}
+
+// TTL returns the time to live of the record in seconds
+func (d *DNSAnswer) TTL() uint32 {
+	return uint32(d.ttl[0])<<24 | uint32(d.ttl[1])<<16 | uint32(d.ttl[2])<<8 | uint32(d.ttl[3])
+}
+
+// SetTTL replaces the time to live of the record
+func (d *DNSAnswer) SetTTL(ttl uint32) {
+	d.ttl = [4]byte{byte(ttl >> 24), byte(ttl >> 16), byte(ttl >> 8), byte(ttl)}
+}
+
+// Data returns the RDATA of the record
+func (d *DNSAnswer) Data() []byte {
+	return d.data
+}
+
+// ParseARecord returns the IPv4 address held in the RDATA of an A record
+func (d *DNSAnswer) ParseARecord() (net.IP, error) {
+	if d.Type() != types.TYPE_A {
+		return nil, fmt.Errorf("not an A record: type %d", d.Type())
+	}
+
+	if len(d.data) != net.IPv4len {
+		return nil, fmt.Errorf("invalid A record data: need %d bytes, have %d", net.IPv4len, len(d.data))
+	}
+
+	return append(net.IP(nil), d.data...), nil
+}
+
+// ParseAAAARecord returns the IPv6 address held in the RDATA of an AAAA record
+func (d *DNSAnswer) ParseAAAARecord() (net.IP, error) {
+	if d.Type() != types.TYPE_AAAA {
+		return nil, fmt.Errorf("not an AAAA record: type %d", d.Type())
+	}
+
+	if len(d.data) != net.IPv6len {
+		return nil, fmt.Errorf("invalid AAAA record data: need %d bytes, have %d", net.IPv6len, len(d.data))
+	}
+
+	return append(net.IP(nil), d.data...), nil
+}
+
+// ParseMXRecord returns the preference and exchange held in the RDATA of an
+// MX record. originalMsg is the message the answer was read from and is used
+// to follow compression pointers in the exchange name
+func (d *DNSAnswer) ParseMXRecord(originalMsg []byte) (uint16, utils.DomainName, error) {
+	if d.Type() != types.TYPE_MX {
+		return 0, utils.DomainName{}, fmt.Errorf("not an MX record: type %d", d.Type())
+	}
+
+	if len(d.data) < 3 {
+		return 0, utils.DomainName{}, fmt.Errorf("invalid MX record data: need at least 3 bytes, have %d", len(d.data))
+	}
+
+	preference := uint16(d.data[0])<<8 | uint16(d.data[1])
+
+	exchange, size, err := utils.NewDomainNameWithDecompression(d.data[2:], originalMsg)
+	if err != nil {
+		return 0, utils.DomainName{}, fmt.Errorf("invalid MX exchange: %w", err)
+	}
+
+	if int(size) != len(d.data)-2 {
+		return 0, utils.DomainName{}, fmt.Errorf("invalid MX record data: %d trailing bytes", len(d.data)-2-int(size))
+	}
+
+	return preference, *exchange, nil
+}
+
+// ParseSRVRecord returns the fields held in the RDATA of an SRV record.
+// originalMsg is the message the answer was read from; RFC 2782 forbids
+// compressing the target, but pointers sent by lenient servers are followed
+func (d *DNSAnswer) ParseSRVRecord(originalMsg []byte) (SRVRData, error) {
+	if d.Type() != types.TYPE_SRV {
+		return SRVRData{}, fmt.Errorf("not an SRV record: type %d", d.Type())
+	}
+
+	if len(d.data) <= srvFixedLength {
+		return SRVRData{}, fmt.Errorf("invalid SRV record data: need at least %d bytes, have %d", srvFixedLength+1, len(d.data))
+	}
+
+	target, size, err := utils.NewDomainNameWithDecompression(d.data[srvFixedLength:], originalMsg)
+	if err != nil {
+		return SRVRData{}, fmt.Errorf("invalid SRV target: %w", err)
+	}
+
+	if int(size) != len(d.data)-srvFixedLength {
+		return SRVRData{}, fmt.Errorf("invalid SRV record data: %d trailing bytes", len(d.data)-srvFixedLength-int(size))
+	}
+
+	return SRVRData{
+		Priority: uint16(d.data[0])<<8 | uint16(d.data[1]),
+		Weight:   uint16(d.data[2])<<8 | uint16(d.data[3]),
+		Port:     uint16(d.data[4])<<8 | uint16(d.data[5]),
+		Target:   *target,
+	}, nil
+}
+
+// ParseTXTRecord returns the character strings held in the RDATA of a TXT
+// record. Texts longer than 255 bytes arrive split over consecutive strings
+func (d *DNSAnswer) ParseTXTRecord() ([]string, error) {
+	if d.Type() != types.TYPE_TXT {
+		return nil, fmt.Errorf("not a TXT record: type %d", d.Type())
+	}
+	return decodeCharacterStrings(d.data)
+}
+
+// ParseCAARecord returns the flags, property tag and value held in the RDATA
+// of a CAA record. The tag must be 1 to 15 ASCII letters and digits
+func (d *DNSAnswer) ParseCAARecord() (CAARData, error) {
+	if d.Type() != types.TYPE_CAA {
+		return CAARData{}, fmt.Errorf("not a CAA record: type %d", d.Type())
+	}
+
+	if len(d.data) < 2 {
+		return CAARData{}, fmt.Errorf("invalid CAA record data: need at least 2 bytes, have %d", len(d.data))
+	}
+
+	tagLength := int(d.data[1])
+	if tagLength == 0 || tagLength > maxCAATagLength {
+		return CAARData{}, fmt.Errorf("invalid CAA tag length %d", tagLength)
+	}
+
+	if len(d.data)-2 < tagLength {
+		return CAARData{}, fmt.Errorf("invalid CAA record data: tag needs %d bytes, have %d", tagLength, len(d.data)-2)
+	}
+
+	tag := d.data[2 : 2+tagLength]
+	for _, char := range tag {
+		isAlphanumeric := 'a' <= char && char <= 'z' || 'A' <= char && char <= 'Z' || '0' <= char && char <= '9'
+		if !isAlphanumeric {
+			return CAARData{}, fmt.Errorf("invalid CAA tag %q: contains character 0x%02x", tag, char)
+		}
+	}
+
+	return CAARData{
+		Flags: d.data[0],
+		Tag:   string(tag),
+		Value: string(d.data[2+tagLength:]),
+	}, nil
+}
+
+// ParseNAPTRRecord returns the fields held in the RDATA of a NAPTR record.
+// originalMsg is the message the answer was read from; the replacement must
+// not be compressed, but pointers sent by lenient servers are followed
+func (d *DNSAnswer) ParseNAPTRRecord(originalMsg []byte) (NAPTRRData, error) {
+	if d.Type() != types.TYPE_NAPTR {
+		return NAPTRRData{}, fmt.Errorf("not a NAPTR record: type %d", d.Type())
+	}
+
+	if len(d.data) < 4 {
+		return NAPTRRData{}, fmt.Errorf("invalid NAPTR record data: need at least 4 bytes, have %d", len(d.data))
+	}
+
+	naptr := NAPTRRData{
+		Order:      uint16(d.data[0])<<8 | uint16(d.data[1]),
+		Preference: uint16(d.data[2])<<8 | uint16(d.data[3]),
+	}
+
+	data := d.data[4:]
+	for _, field := range []struct {
+		name  string
+		value *string
+	}{
+		{"flags", &naptr.Flags},
+		{"services", &naptr.Services},
+		{"regexp", &naptr.Regexp},
+	} {
+		if len(data) == 0 || len(data[1:]) < int(data[0]) {
+			return NAPTRRData{}, fmt.Errorf("invalid NAPTR record data: truncated %s", field.name)
+		}
+		length := int(data[0])
+		*field.value = string(data[1 : 1+length])
+		data = data[1+length:]
+	}
+
+	if len(data) == 0 {
+		return NAPTRRData{}, fmt.Errorf("invalid NAPTR record data: missing replacement")
+	}
+
+	replacement, size, err := utils.NewDomainNameWithDecompression(data, originalMsg)
+	if err != nil {
+		return NAPTRRData{}, fmt.Errorf("invalid NAPTR replacement: %w", err)
+	}
+
+	if int(size) != len(data) {
+		return NAPTRRData{}, fmt.Errorf("invalid NAPTR record data: %d trailing bytes", len(data)-int(size))
+	}
+
+	naptr.Replacement = *replacement
+	return naptr, nil
+}
+
+// ParseSVCBRecord returns the fields held in the RDATA of an SVCB or HTTPS
+// record. The target name is never compressed (RFC 9460 §2.2)
+func (d *DNSAnswer) ParseSVCBRecord() (SVCBRData, error) {
+	if d.Type() != types.TYPE_SVCB && d.Type() != types.TYPE_HTTPS {
+		return SVCBRData{}, fmt.Errorf("not an SVCB or HTTPS record: type %d", d.Type())
+	}
+
+	if len(d.data) < 3 {
+		return SVCBRData{}, fmt.Errorf("invalid SVCB record data: need at least 3 bytes, have %d", len(d.data))
+	}
+
+	targetName, size, err := utils.NewDomainName(d.data[2:])
+	if err != nil {
+		return SVCBRData{}, fmt.Errorf("invalid SVCB target name: %w", err)
+	}
+
+	params, err := records.DecodeSvcParams(d.data[2+size:])
+	if err != nil {
+		return SVCBRData{}, fmt.Errorf("invalid SVCB parameters: %w", err)
+	}
+
+	return SVCBRData{
+		Priority:   uint16(d.data[0])<<8 | uint16(d.data[1]),
+		TargetName: *targetName,
+		Params:     params,
+	}, nil
+}
+
+// ParseSOARecord returns the fields held in the RDATA of an SOA record.
+// originalMsg is the message the answer was read from and is used to follow
+// compression pointers in MNAME and RNAME
+func (d *DNSAnswer) ParseSOARecord(originalMsg []byte) (SOARData, error) {
+	if d.Type() != types.TYPE_SOA {
+		return SOARData{}, fmt.Errorf("not an SOA record: type %d", d.Type())
+	}
+
+	data := d.data
+	if len(data) == 0 {
+		return SOARData{}, fmt.Errorf("invalid SOA record data: empty")
+	}
+
+	mname, size, err := utils.NewDomainNameWithDecompression(data, originalMsg)
+	if err != nil {
+		return SOARData{}, fmt.Errorf("invalid SOA MNAME: %w", err)
+	}
+	data = data[size:]
+
+	if len(data) == 0 {
+		return SOARData{}, fmt.Errorf("invalid SOA record data: missing RNAME")
+	}
+
+	rname, size, err := utils.NewDomainNameWithDecompression(data, originalMsg)
+	if err != nil {
+		return SOARData{}, fmt.Errorf("invalid SOA RNAME: %w", err)
+	}
+	data = data[size:]
+
+	if len(data) != soaTimersLength {
+		return SOARData{}, fmt.Errorf("invalid SOA record data: need %d bytes of timers, have %d", soaTimersLength, len(data))
+	}
+
+	field := func(index int) uint32 {
+		return uint32(data[index])<<24 | uint32(data[index+1])<<16 | uint32(data[index+2])<<8 | uint32(data[index+3])
+	}
+
+	return SOARData{
+		MNAME:   *mname,
+		RNAME:   *rname,
+		Serial:  field(0),
+		Refresh: field(4),
+		Retry:   field(8),
+		Expire:  field(12),
+		Minimum: field(16),
+	}, nil
+}
+
 // Convert DNS answer to bytes
 func (d *DNSAnswer) ToBytes() []byte {
 	question := d.name.ToBytes()
 	data_length := []byte{byte(len(d.data) >> 8), byte(len(d.data) & 0xFF)}
 
-	question = append(question, d.class[:]...)
 	question = append(question, d.type_[:]...)
+	question = append(question, d.class[:]...)
 	question = append(question, d.ttl[:]...)
 	question = append(question, data_length...)
 	question = append(question, d.data...)
@@ -98,14 +505,7 @@ func (d *DNSAnswer) ToBytesWithCompression(
 	compressionMap *utils.CompressionMap,
 	currentOffset uint16,
 ) []byte {
-	nameBytes := d.name.ToBytesWithCompression(compressionMap, currentOffset)
-	data_length := []byte{byte(len(d.data) >> 8), byte(len(d.data) & 0xFF)}
-
-	result := append(nameBytes, d.class[:]...)
-	result = append(result, d.type_[:]...)
-	result = append(result, d.ttl[:]...)
-	result = append(result, data_length...)
-	result = append(result, d.data...)
-
-	return result
+	writer := newMessageWriterAt(compressionMap, currentOffset)
+	writer.WriteRecord(*d)
+	return writer.Bytes()
 }
@@ -2,10 +2,13 @@ package message
 
 import (
 	"bytes"
+	"net"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
 
+	"github.com/vadim-su/dnska/pkg/dns/records"
 	"github.com/vadim-su/dnska/pkg/dns/types"
 	"github.com/vadim-su/dnska/pkg/dns/utils"
 )
@@ -17,11 +20,7 @@ func containsString(text, substr string) bool {
 
 // Helper function for comparing domain names
 func compareDomainNames(a, b utils.DomainName) bool {
-	// Handle case where one is nil and other is empty slice
-	if len(a.Labels) == 0 && len(b.Labels) == 0 {
-		return true
-	}
-	return reflect.DeepEqual(a.Labels, b.Labels)
+	return a.Equal(b)
 }
 
 func TestNewDNSAnswer(t *testing.T) {
@@ -358,8 +357,8 @@ func TestDNSAnswerToBytes(t *testing.T) {
 				0x07, 'e', 'x', 'a', 'm', 'p', 'l', 'e',
 				0x03, 'c', 'o', 'm',
 				0x00,       // End of domain name
-				0x00, 0x01, // Class IN
 				0x00, 0x01, // Type A
+				0x00, 0x01, // Class IN
 				0x00, 0x00, 0x01, 0x2C, // TTL (300 seconds)
 				0x00, 0x04, // Data length (4 bytes)
 				192, 0, 2, 1, // IP address data
@@ -383,8 +382,8 @@ func TestDNSAnswerToBytes(t *testing.T) {
 				// Domain name
 				0x04, 't', 'e', 's', 't',
 				0x00,       // End of domain name
-				0x00, 0x01, // Class IN
 				0x00, 0x1C, // Type AAAA
+				0x00, 0x01, // Class IN
 				0x00, 0x00, 0x0E, 0x10, // TTL (3600 seconds)
 				0x00, 0x10, // Data length (16 bytes)
 				// IPv6 address data
@@ -407,8 +406,8 @@ func TestDNSAnswerToBytes(t *testing.T) {
 			expectedResult: []byte{
 				// Root domain
 				0x00,       // End of domain name
-				0x00, 0x01, // Class IN
 				0x00, 0x02, // Type NS
+				0x00, 0x01, // Class IN
 				0x00, 0x02, 0xA3, 0x00, // TTL (172800 seconds)
 				0x00, 0x14, // Data length (20 bytes)
 				// NS data: a.root-servers.net
@@ -437,8 +436,8 @@ func TestDNSAnswerToBytes(t *testing.T) {
 				0x07, 'e', 'x', 'a', 'm', 'p', 'l', 'e',
 				0x03, 'o', 'r', 'g',
 				0x00,       // End of domain name
-				0x00, 0x01, // Class IN
 				0x00, 0x10, // Type TXT
+				0x00, 0x01, // Class IN
 				0x00, 0x00, 0x00, 0x3C, // TTL (60 seconds)
 				0x00, 0x0C, // Data length (12 bytes)
 				// TXT data: "hello world"
@@ -463,8 +462,8 @@ func TestDNSAnswerToBytes(t *testing.T) {
 				// Domain name
 				0x05, 'c', 'a', 'c', 'h', 'e',
 				0x00,       // End of domain name
-				0x00, 0x01, // Class IN
 				0x00, 0x01, // Type A
+				0x00, 0x01, // Class IN
 				0x00, 0x00, 0x00, 0x00, // TTL (0 seconds)
 				0x00, 0x04, // Data length (4 bytes)
 				127, 0, 0, 1, // IP address data
@@ -488,8 +487,8 @@ func TestDNSAnswerToBytes(t *testing.T) {
 				// Domain name
 				0x03, 'm', 'a', 'x',
 				0x00,       // End of domain name
-				0x00, 0x01, // Class IN
 				0x00, 0x01, // Type A
+				0x00, 0x01, // Class IN
 				0xFF, 0xFF, 0xFF, 0xFF, // TTL (maximum)
 				0x00, 0x04, // Data length (4 bytes)
 				192, 168, 1, 1, // IP address data
@@ -513,8 +512,8 @@ func TestDNSAnswerToBytes(t *testing.T) {
 				// Domain name
 				0x05, 'e', 'm', 'p', 't', 'y',
 				0x00,       // End of domain name
-				0x00, 0x01, // Class IN
 				0x00, 0x01, // Type A
+				0x00, 0x01, // Class IN
 				0x00, 0x00, 0x01, 0x2C, // TTL (300 seconds)
 				0x00, 0x00, // Data length (0 bytes)
 				// No data
@@ -539,8 +538,8 @@ func TestDNSAnswerToBytes(t *testing.T) {
 					// Domain name
 					0x04, 't', 'e', 's', 't',
 					0x00,       // End of domain name
-					0x00, 0x01, // Class IN
 					0x00, 0x10, // Type TXT
+					0x00, 0x01, // Class IN
 					0x00, 0x00, 0x01, 0x2C, // TTL (300 seconds)
 					0x02, 0x00, // Data length (512 bytes)
 				}
@@ -568,8 +567,8 @@ func TestDNSAnswerToBytes(t *testing.T) {
 				0x07, 'v', 'e', 'r', 's', 'i', 'o', 'n',
 				0x04, 'b', 'i', 'n', 'd',
 				0x00,       // End of domain name
-				0x00, 0x03, // Class CH
 				0x00, 0x10, // Type TXT
+				0x00, 0x03, // Class CH
 				0x00, 0x00, 0x00, 0x00, // TTL (0 seconds)
 				0x00, 0x0E, // Data length (14 bytes)
 				// TXT data: "9.16.1-Ubuntu"
@@ -777,3 +776,774 @@ func TestDNSAnswerEdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestNewAAnswer(t *testing.T) {
+	name, _, _ := utils.NewDomainName([]byte{0x04, 'h', 'o', 's', 't', 0x00})
+	classIN := types.DnsTypeClassToBytes(types.CLASS_IN)
+
+	tests := []struct {
+		name         string
+		ip           net.IP
+		expectedType types.DNSType
+		expectedData []byte
+		expectedErr  bool
+	}{
+		{
+			name:         "4-byte IPv4",
+			ip:           net.IP{192, 0, 2, 1},
+			expectedType: types.TYPE_A,
+			expectedData: []byte{192, 0, 2, 1},
+		},
+		{
+			name:         "IPv4 in 16-byte form",
+			ip:           net.ParseIP("192.0.2.1"),
+			expectedType: types.TYPE_A,
+			expectedData: []byte{192, 0, 2, 1},
+		},
+		{
+			name:         "IPv6",
+			ip:           net.ParseIP("2001:db8::1"),
+			expectedType: types.TYPE_AAAA,
+			expectedData: []byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+		},
+		{
+			name:        "nil IP",
+			ip:          nil,
+			expectedErr: true,
+		},
+		{
+			name:        "truncated IP",
+			ip:          net.IP{10, 0, 0},
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer, err := NewAAnswer(*name, tt.ip, 300, classIN)
+			if tt.expectedErr {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if answer.Type() != tt.expectedType {
+				t.Errorf("got type %d, want %d", answer.Type(), tt.expectedType)
+			}
+			if !bytes.Equal(answer.data, tt.expectedData) {
+				t.Errorf("got data %v, want %v", answer.data, tt.expectedData)
+			}
+			if answer.class != classIN {
+				t.Errorf("got class %v, want %v", answer.class, classIN)
+			}
+		})
+	}
+}
+
+func TestDNSAnswerParseAddressRecords(t *testing.T) {
+	name := []byte{0x04, 'h', 'o', 's', 't', 0x00}
+
+	tests := []struct {
+		name        string
+		type_       types.DNSType
+		data        []byte
+		parse       func(*DNSAnswer) (net.IP, error)
+		expectedIP  net.IP
+		expectedErr string
+	}{
+		{
+			name:       "valid A",
+			type_:      types.TYPE_A,
+			data:       []byte{10, 0, 0, 1},
+			parse:      (*DNSAnswer).ParseARecord,
+			expectedIP: net.IP{10, 0, 0, 1},
+		},
+		{
+			name:        "short A",
+			type_:       types.TYPE_A,
+			data:        []byte{10, 0, 0},
+			parse:       (*DNSAnswer).ParseARecord,
+			expectedErr: "need 4 bytes, have 3",
+		},
+		{
+			name:        "A parser on AAAA",
+			type_:       types.TYPE_AAAA,
+			data:        net.ParseIP("2001:db8::1"),
+			parse:       (*DNSAnswer).ParseARecord,
+			expectedErr: "not an A record",
+		},
+		{
+			name:       "valid AAAA",
+			type_:      types.TYPE_AAAA,
+			data:       net.ParseIP("2001:db8::1"),
+			parse:      (*DNSAnswer).ParseAAAARecord,
+			expectedIP: net.ParseIP("2001:db8::1"),
+		},
+		{
+			name:        "long AAAA",
+			type_:       types.TYPE_AAAA,
+			data:        make([]byte, 17),
+			parse:       (*DNSAnswer).ParseAAAARecord,
+			expectedErr: "need 16 bytes, have 17",
+		},
+		{
+			name:        "AAAA parser on A",
+			type_:       types.TYPE_A,
+			data:        []byte{10, 0, 0, 1},
+			parse:       (*DNSAnswer).ParseAAAARecord,
+			expectedErr: "not an AAAA record",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer, err := NewDNSAnswer(name, types.CLASS_IN, tt.type_, 300, tt.data)
+			if err != nil {
+				t.Fatalf("failed to create answer: %v", err)
+			}
+
+			ip, err := tt.parse(answer)
+			if tt.expectedErr != "" {
+				if err == nil || !containsString(err.Error(), tt.expectedErr) {
+					t.Errorf("got error %v, want one containing %q", err, tt.expectedErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !ip.Equal(tt.expectedIP) {
+				t.Errorf("got IP %v, want %v", ip, tt.expectedIP)
+			}
+		})
+	}
+}
+
+func TestDNSAnswerAccessors(t *testing.T) {
+	wire := []byte{
+		0x04, 'h', 'o', 's', 't', 0x00,
+		0x00, 0x1C, // Type AAAA
+		0x00, 0x01, // Class IN
+		0x00, 0x00, 0x0E, 0x10, // TTL 3600
+		0x00, 0x10, // RDLENGTH
+		0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1,
+	}
+
+	answers, _, err := NewDNSAnswers(wire, 1, wire)
+	if err != nil {
+		t.Fatalf("failed to parse answer: %v", err)
+	}
+	answer := answers[0]
+
+	if name := answer.Name(); name.String() != "host." {
+		t.Errorf("Name() = %q, want %q", name.String(), "host.")
+	}
+	if answer.Type() != types.TYPE_AAAA {
+		t.Errorf("Type() = %d, want %d", answer.Type(), types.TYPE_AAAA)
+	}
+	if answer.Class() != types.CLASS_IN {
+		t.Errorf("Class() = %d, want %d", answer.Class(), types.CLASS_IN)
+	}
+	if answer.TTL() != 3600 {
+		t.Errorf("TTL() = %d, want 3600", answer.TTL())
+	}
+	if !bytes.Equal(answer.Data(), wire[len(wire)-16:]) {
+		t.Errorf("Data() = %v, want %v", answer.Data(), wire[len(wire)-16:])
+	}
+
+	answer.SetTTL(0x01020304)
+	if answer.TTL() != 0x01020304 {
+		t.Errorf("TTL() after SetTTL = %#x, want %#x", answer.TTL(), 0x01020304)
+	}
+
+	upper, err := utils.NewDomainNameFromString("HOST.")
+	if err != nil {
+		t.Fatalf("failed to parse name: %v", err)
+	}
+	renamed := answer.WithName(*upper)
+	if name := renamed.Name(); name.String() != "HOST." {
+		t.Errorf("WithName() name = %q, want %q", name.String(), "HOST.")
+	}
+	if name := answer.Name(); name.String() != "host." {
+		t.Errorf("WithName() changed the original name to %q", name.String())
+	}
+	if renamed.Type() != answer.Type() || renamed.TTL() != answer.TTL() || !bytes.Equal(renamed.Data(), answer.Data()) {
+		t.Errorf("WithName() changed fields other than the name")
+	}
+
+	rebuilt := NewDNSAnswerFromParts(answer.Name(), answer.Type(), answer.Class(), 3600, answer.Data())
+	if !bytes.Equal(rebuilt.ToBytes(), wire) {
+		t.Errorf("NewDNSAnswerFromParts round trip:\ngot:  %v\nwant: %v", rebuilt.ToBytes(), wire)
+	}
+}
+
+func TestMXAnswerRoundTrip(t *testing.T) {
+	classIN := types.DnsTypeClassToBytes(types.CLASS_IN)
+
+	tests := []struct {
+		name       string
+		owner      string
+		preference uint16
+		exchange   string
+	}{
+		{name: "exchange under owner", owner: "example.com", preference: 10, exchange: "mail.example.com"},
+		{name: "unrelated exchange", owner: "example.com", preference: 20, exchange: "mx.provider.net"},
+		{name: "exchange equals owner", owner: "example.org", preference: 0, exchange: "example.org"},
+		{name: "maximum preference", owner: "example.com", preference: 65535, exchange: "backup.example.com"},
+		{name: "null MX", owner: "example.com", preference: 0, exchange: "."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner, err := utils.NewDomainNameFromString(tt.owner)
+			if err != nil {
+				t.Fatalf("invalid owner: %v", err)
+			}
+			exchange, err := utils.NewDomainNameFromString(tt.exchange)
+			if err != nil {
+				t.Fatalf("invalid exchange: %v", err)
+			}
+
+			answer := NewMXAnswer(*owner, tt.preference, *exchange, 3600, classIN)
+			if answer.Type() != types.TYPE_MX || answer.TTL() != 3600 {
+				t.Fatalf("unexpected type %d or TTL %d", answer.Type(), answer.TTL())
+			}
+
+			// Uncompressed: RDATA parses without the surrounding message
+			preference, parsed, err := answer.ParseMXRecord(nil)
+			if err != nil {
+				t.Fatalf("ParseMXRecord failed: %v", err)
+			}
+			if preference != tt.preference || !parsed.Equal(*exchange) {
+				t.Errorf("got %d %s, want %d %s", preference, parsed.String(), tt.preference, exchange.String())
+			}
+
+			// Compressed: the exchange may point back into the message
+			wire := NewResponse(0x1234).AddAnswer(*answer).Build().ToBytesWithCompression()
+			response, err := NewDNSResponse(wire)
+			if err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+
+			preference, parsed, err = response.Answers[0].ParseMXRecord(wire)
+			if err != nil {
+				t.Fatalf("ParseMXRecord on compressed message failed: %v", err)
+			}
+			if preference != tt.preference || !parsed.Equal(*exchange) {
+				t.Errorf("compressed: got %d %s, want %d %s", preference, parsed.String(), tt.preference, exchange.String())
+			}
+		})
+	}
+}
+
+func TestParseMXRecordErrors(t *testing.T) {
+	name := []byte{0x04, 'h', 'o', 's', 't', 0x00}
+
+	tests := []struct {
+		name        string
+		type_       types.DNSType
+		data        []byte
+		expectedErr string
+	}{
+		{
+			name:        "wrong type",
+			type_:       types.TYPE_A,
+			data:        []byte{0x00, 0x0A, 0x00},
+			expectedErr: "not an MX record",
+		},
+		{
+			name:        "missing exchange",
+			type_:       types.TYPE_MX,
+			data:        []byte{0x00, 0x0A},
+			expectedErr: "need at least 3 bytes",
+		},
+		{
+			name:        "truncated exchange",
+			type_:       types.TYPE_MX,
+			data:        []byte{0x00, 0x0A, 0x04, 'm', 'a'},
+			expectedErr: "invalid MX exchange",
+		},
+		{
+			name:        "trailing bytes",
+			type_:       types.TYPE_MX,
+			data:        []byte{0x00, 0x0A, 0x00, 0xFF},
+			expectedErr: "1 trailing bytes",
+		},
+		{
+			name:        "pointer without message",
+			type_:       types.TYPE_MX,
+			data:        []byte{0x00, 0x0A, 0xC0, 0x0C},
+			expectedErr: "invalid MX exchange",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer, err := NewDNSAnswer(name, types.CLASS_IN, tt.type_, 300, tt.data)
+			if err != nil {
+				t.Fatalf("failed to create answer: %v", err)
+			}
+
+			_, _, err = answer.ParseMXRecord(nil)
+			if err == nil || !containsString(err.Error(), tt.expectedErr) {
+				t.Errorf("got error %v, want one containing %q", err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestSOAAnswerRoundTrip(t *testing.T) {
+	mustName := func(name string) utils.DomainName {
+		domainName, err := utils.NewDomainNameFromString(name)
+		if err != nil {
+			t.Fatalf("invalid name %q: %v", name, err)
+		}
+		return *domainName
+	}
+
+	tests := []struct {
+		name  string
+		owner string
+		soa   SOARData
+	}{
+		{
+			name:  "typical zone",
+			owner: "example.com",
+			soa: SOARData{
+				MNAME:   mustName("ns1.example.com"),
+				RNAME:   mustName("hostmaster.example.com"),
+				Serial:  2024031501,
+				Refresh: 7200,
+				Retry:   3600,
+				Expire:  1209600,
+				Minimum: 3600,
+			},
+		},
+		{
+			name:  "names outside the zone",
+			owner: "example.org",
+			soa: SOARData{
+				MNAME:   mustName("a.iana-servers.net"),
+				RNAME:   mustName("noc.dns.icann.org"),
+				Serial:  2023112810,
+				Refresh: 7200,
+				Retry:   3600,
+				Expire:  1209600,
+				Minimum: 3600,
+			},
+		},
+		{
+			name:  "root zone with maximum serial",
+			owner: ".",
+			soa: SOARData{
+				MNAME:   mustName("a.root-servers.net"),
+				RNAME:   mustName("nstld.verisign-grs.com"),
+				Serial:  0xFFFFFFFF,
+				Refresh: 1800,
+				Retry:   900,
+				Expire:  604800,
+				Minimum: 86400,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer := NewSOAAnswer(mustName(tt.owner), tt.soa, 86400)
+			if answer.Type() != types.TYPE_SOA || answer.Class() != types.CLASS_IN || answer.TTL() != 86400 {
+				t.Fatalf("unexpected type %d, class %d or TTL %d", answer.Type(), answer.Class(), answer.TTL())
+			}
+
+			checkSOA := func(label string, parsed SOARData) {
+				if !parsed.MNAME.Equal(tt.soa.MNAME) || !parsed.RNAME.Equal(tt.soa.RNAME) {
+					t.Errorf("%s: got names %s %s, want %s %s", label,
+						parsed.MNAME.String(), parsed.RNAME.String(), tt.soa.MNAME.String(), tt.soa.RNAME.String())
+				}
+				parsed.MNAME, parsed.RNAME = tt.soa.MNAME, tt.soa.RNAME
+				if !reflect.DeepEqual(parsed, tt.soa) {
+					t.Errorf("%s: got %+v, want %+v", label, parsed, tt.soa)
+				}
+			}
+
+			parsed, err := answer.ParseSOARecord(nil)
+			if err != nil {
+				t.Fatalf("ParseSOARecord failed: %v", err)
+			}
+			checkSOA("uncompressed", parsed)
+
+			wire := NewResponse(0x1234).AddAnswer(*answer).Build().ToBytesWithCompression()
+			response, err := NewDNSResponse(wire)
+			if err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+
+			parsed, err = response.Answers[0].ParseSOARecord(wire)
+			if err != nil {
+				t.Fatalf("ParseSOARecord on compressed message failed: %v", err)
+			}
+			checkSOA("compressed", parsed)
+		})
+	}
+}
+
+func TestParseSRVRecord(t *testing.T) {
+	// Owner _sip._udp.example.com starts right after the 12-byte header, so
+	// example.com sits at offset 22
+	owner := []byte{
+		0x04, '_', 's', 'i', 'p', 0x04, '_', 'u', 'd', 'p',
+		0x07, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0x03, 'c', 'o', 'm', 0x00,
+	}
+	fixed := []byte{0x00, 0x0A, 0x00, 0x3C, 0x13, 0xC4} // priority 10, weight 60, port 5060
+
+	tests := []struct {
+		name     string
+		target   []byte
+		expected string
+	}{
+		{name: "uncompressed target", target: []byte{0x03, 's', 'i', 'p', 0x03, 'n', 'e', 't', 0x00}, expected: "sip.net"},
+		{name: "target is a pointer", target: []byte{0xC0, 0x16}, expected: "example.com"},
+		{name: "label followed by a pointer", target: []byte{0x03, 's', 'i', 'p', 0xC0, 0x16}, expected: "sip.example.com"},
+		{name: "root target", target: []byte{0x00}, expected: "."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdata := append(append([]byte{}, fixed...), tt.target...)
+
+			wire := []byte{0x12, 0x34, 0x81, 0x80, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}
+			wire = append(wire, owner...)
+			wire = append(wire, 0x00, 0x21, 0x00, 0x01, 0x00, 0x00, 0x0E, 0x10, 0x00, byte(len(rdata)))
+			wire = append(wire, rdata...)
+
+			response, err := NewDNSResponse(wire)
+			if err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+
+			srv, err := response.Answers[0].ParseSRVRecord(wire)
+			if err != nil {
+				t.Fatalf("ParseSRVRecord failed: %v", err)
+			}
+
+			if srv.Priority != 10 || srv.Weight != 60 || srv.Port != 5060 {
+				t.Errorf("got priority %d, weight %d, port %d, want 10, 60, 5060", srv.Priority, srv.Weight, srv.Port)
+			}
+
+			expected, err := utils.NewDomainNameFromString(tt.expected)
+			if err != nil {
+				t.Fatalf("invalid expected target: %v", err)
+			}
+			if !srv.Target.Equal(*expected) {
+				t.Errorf("got target %s, want %s", srv.Target.String(), expected.String())
+			}
+		})
+	}
+}
+
+func TestParseSRVRecordErrors(t *testing.T) {
+	name := []byte{0x04, 'h', 'o', 's', 't', 0x00}
+
+	tests := []struct {
+		name        string
+		type_       types.DNSType
+		data        []byte
+		expectedErr string
+	}{
+		{
+			name:        "wrong type",
+			type_:       types.TYPE_MX,
+			data:        []byte{0x00, 0x0A, 0x00, 0x3C, 0x13, 0xC4, 0x00},
+			expectedErr: "not an SRV record",
+		},
+		{
+			name:        "missing target",
+			type_:       types.TYPE_SRV,
+			data:        []byte{0x00, 0x0A, 0x00, 0x3C, 0x13, 0xC4},
+			expectedErr: "need at least 7 bytes",
+		},
+		{
+			name:        "truncated target",
+			type_:       types.TYPE_SRV,
+			data:        []byte{0x00, 0x0A, 0x00, 0x3C, 0x13, 0xC4, 0x03, 's'},
+			expectedErr: "invalid SRV target",
+		},
+		{
+			name:        "trailing bytes",
+			type_:       types.TYPE_SRV,
+			data:        []byte{0x00, 0x0A, 0x00, 0x3C, 0x13, 0xC4, 0x00, 0xFF, 0xFF},
+			expectedErr: "2 trailing bytes",
+		},
+		{
+			name:        "pointer without message",
+			type_:       types.TYPE_SRV,
+			data:        []byte{0x00, 0x0A, 0x00, 0x3C, 0x13, 0xC4, 0xC0, 0x0C},
+			expectedErr: "invalid SRV target",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer, err := NewDNSAnswer(name, types.CLASS_IN, tt.type_, 300, tt.data)
+			if err != nil {
+				t.Fatalf("failed to create answer: %v", err)
+			}
+
+			_, err = answer.ParseSRVRecord(nil)
+			if err == nil || !containsString(err.Error(), tt.expectedErr) {
+				t.Errorf("got error %v, want one containing %q", err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestParseTXTRecord(t *testing.T) {
+	long := strings.Repeat("a", 255)
+	tests := []struct {
+		name     string
+		data     []byte
+		expected []string
+	}{
+		{name: "single string", data: append([]byte{0x05}, "hello"...), expected: []string{"hello"}},
+		{name: "multiple strings", data: append(append([]byte{0x05}, "hello"...), append([]byte{0x05}, "world"...)...), expected: []string{"hello", "world"}},
+		{name: "empty string", data: []byte{0x00}, expected: []string{""}},
+		{name: "chunked text", data: append(append([]byte{0xFF}, long...), append([]byte{0x03}, "end"...)...), expected: []string{long, "end"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer, err := NewDNSAnswer([]byte{0x00}, types.CLASS_IN, types.TYPE_TXT, 300, tt.data)
+			if err != nil {
+				t.Fatalf("failed to create answer: %v", err)
+			}
+
+			texts, err := answer.ParseTXTRecord()
+			if err != nil {
+				t.Fatalf("ParseTXTRecord failed: %v", err)
+			}
+			if !slices.Equal(texts, tt.expected) {
+				t.Errorf("got %q, want %q", texts, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseTXTRecordErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		type_       types.DNSType
+		data        []byte
+		expectedErr string
+	}{
+		{name: "wrong type", type_: types.TYPE_CAA, data: []byte{0x01, 'a'}, expectedErr: "not a TXT record"},
+		{name: "no strings", type_: types.TYPE_TXT, data: nil, expectedErr: "no character strings"},
+		{name: "truncated string", type_: types.TYPE_TXT, data: []byte{0x05, 'a', 'b'}, expectedErr: "string needs 5 bytes, have 2"},
+		{name: "truncated second string", type_: types.TYPE_TXT, data: []byte{0x01, 'a', 0x03, 'b'}, expectedErr: "string needs 3 bytes, have 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer, err := NewDNSAnswer([]byte{0x00}, types.CLASS_IN, tt.type_, 300, tt.data)
+			if err != nil {
+				t.Fatalf("failed to create answer: %v", err)
+			}
+
+			_, err = answer.ParseTXTRecord()
+			if err == nil || !containsString(err.Error(), tt.expectedErr) {
+				t.Errorf("got error %v, want one containing %q", err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestParseCAARecord(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected CAARData
+	}{
+		{
+			name:     "issue",
+			data:     append([]byte{0x00, 0x05}, "issueletsencrypt.org"...),
+			expected: CAARData{Flags: 0, Tag: "issue", Value: "letsencrypt.org"},
+		},
+		{
+			name:     "critical iodef",
+			data:     append([]byte{0x80, 0x05}, "iodefmailto:security@example.com"...),
+			expected: CAARData{Flags: 0x80, Tag: "iodef", Value: "mailto:security@example.com"},
+		},
+		{
+			name:     "empty value forbids issuance",
+			data:     append([]byte{0x00, 0x09}, "issuewild"...),
+			expected: CAARData{Flags: 0, Tag: "issuewild", Value: ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer, err := NewDNSAnswer([]byte{0x00}, types.CLASS_IN, types.TYPE_CAA, 300, tt.data)
+			if err != nil {
+				t.Fatalf("failed to create answer: %v", err)
+			}
+
+			caa, err := answer.ParseCAARecord()
+			if err != nil {
+				t.Fatalf("ParseCAARecord failed: %v", err)
+			}
+			if caa != tt.expected {
+				t.Errorf("got %+v, want %+v", caa, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseCAARecordErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		type_       types.DNSType
+		data        []byte
+		expectedErr string
+	}{
+		{name: "wrong type", type_: types.TYPE_TXT, data: []byte{0x00, 0x01, 'a'}, expectedErr: "not a CAA record"},
+		{name: "missing tag length", type_: types.TYPE_CAA, data: []byte{0x00}, expectedErr: "need at least 2 bytes"},
+		{name: "empty tag", type_: types.TYPE_CAA, data: []byte{0x00, 0x00}, expectedErr: "invalid CAA tag length 0"},
+		{name: "tag too long", type_: types.TYPE_CAA, data: append([]byte{0x00, 0x10}, "abcdefghijklmnop"...), expectedErr: "invalid CAA tag length 16"},
+		{name: "truncated tag", type_: types.TYPE_CAA, data: []byte{0x00, 0x05, 'i', 's'}, expectedErr: "tag needs 5 bytes, have 2"},
+		{name: "non-alphanumeric tag", type_: types.TYPE_CAA, data: []byte{0x00, 0x03, 'a', '-', 'b'}, expectedErr: "contains character 0x2d"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer, err := NewDNSAnswer([]byte{0x00}, types.CLASS_IN, tt.type_, 300, tt.data)
+			if err != nil {
+				t.Fatalf("failed to create answer: %v", err)
+			}
+
+			_, err = answer.ParseCAARecord()
+			if err == nil || !containsString(err.Error(), tt.expectedErr) {
+				t.Errorf("got error %v, want one containing %q", err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestParseNAPTRRecord(t *testing.T) {
+	// Owner sip.example.com starts right after the 12-byte header, so
+	// example.com sits at offset 16
+	owner := []byte{0x03, 's', 'i', 'p', 0x07, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0x03, 'c', 'o', 'm', 0x00}
+	fixed := []byte{0x00, 0x64, 0x00, 0x0A, 0x01, 's', 0x07, 'S', 'I', 'P', '+', 'D', '2', 'U', 0x00}
+
+	tests := []struct {
+		name        string
+		replacement []byte
+		expected    string
+	}{
+		{name: "uncompressed replacement", replacement: []byte{0x04, '_', 's', 'i', 'p', 0x04, '_', 'u', 'd', 'p', 0x00}, expected: "_sip._udp"},
+		{name: "label followed by a pointer", replacement: []byte{0x04, '_', 's', 'i', 'p', 0xC0, 0x10}, expected: "_sip.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdata := append(append([]byte{}, fixed...), tt.replacement...)
+
+			wire := []byte{0x12, 0x34, 0x81, 0x80, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}
+			wire = append(wire, owner...)
+			wire = append(wire, 0x00, 0x23, 0x00, 0x01, 0x00, 0x00, 0x0E, 0x10, 0x00, byte(len(rdata)))
+			wire = append(wire, rdata...)
+
+			response, err := NewDNSResponse(wire)
+			if err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+
+			naptr, err := response.Answers[0].ParseNAPTRRecord(wire)
+			if err != nil {
+				t.Fatalf("ParseNAPTRRecord failed: %v", err)
+			}
+
+			if naptr.Order != 100 || naptr.Preference != 10 || naptr.Flags != "s" || naptr.Services != "SIP+D2U" || naptr.Regexp != "" {
+				t.Errorf("got %+v", naptr)
+			}
+
+			expected, err := utils.NewDomainNameFromString(tt.expected)
+			if err != nil {
+				t.Fatalf("invalid expected replacement: %v", err)
+			}
+			if !naptr.Replacement.Equal(*expected) {
+				t.Errorf("got replacement %s, want %s", naptr.Replacement.String(), expected.String())
+			}
+		})
+	}
+}
+
+func TestParseNAPTRRecordErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		type_       types.DNSType
+		data        []byte
+		expectedErr string
+	}{
+		{name: "wrong type", type_: types.TYPE_SRV, data: []byte{0, 1, 0, 1, 0, 0, 0, 0}, expectedErr: "not a NAPTR record"},
+		{name: "missing preference", type_: types.TYPE_NAPTR, data: []byte{0, 1}, expectedErr: "need at least 4 bytes"},
+		{name: "missing flags", type_: types.TYPE_NAPTR, data: []byte{0, 1, 0, 1}, expectedErr: "truncated flags"},
+		{name: "truncated services", type_: types.TYPE_NAPTR, data: []byte{0, 1, 0, 1, 0, 5, 'E'}, expectedErr: "truncated services"},
+		{name: "truncated regexp", type_: types.TYPE_NAPTR, data: []byte{0, 1, 0, 1, 0, 0}, expectedErr: "truncated regexp"},
+		{name: "missing replacement", type_: types.TYPE_NAPTR, data: []byte{0, 1, 0, 1, 0, 0, 0}, expectedErr: "missing replacement"},
+		{name: "trailing bytes", type_: types.TYPE_NAPTR, data: []byte{0, 1, 0, 1, 0, 0, 0, 0, 0xFF}, expectedErr: "1 trailing bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer, err := NewDNSAnswer([]byte{0x00}, types.CLASS_IN, tt.type_, 300, tt.data)
+			if err != nil {
+				t.Fatalf("failed to create answer: %v", err)
+			}
+
+			_, err = answer.ParseNAPTRRecord(nil)
+			if err == nil || !containsString(err.Error(), tt.expectedErr) {
+				t.Errorf("got error %v, want one containing %q", err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func FuzzNAPTRRegexpRoundTrip(f *testing.F) {
+	f.Add([]byte(`!^.*$!sip:info@example.com!`))
+	f.Add([]byte(`!^\+?(\d+)$!tel:+\1!i`))
+	f.Add([]byte("with spaces \"quotes\" and \\ backslashes"))
+	f.Add([]byte{0x00, 0x01, 0x7F, '\t', '\n'})
+	f.Add([]byte{})
+	f.Add([]byte(strings.Repeat("x", 255)))
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		// Any 7-bit ASCII string that fits a character string
+		regexp := make([]byte, 0, min(len(input), 255))
+		for _, char := range input[:min(len(input), 255)] {
+			regexp = append(regexp, char&0x7F)
+		}
+
+		record := records.NewNAPTRRecord("4.3.2.1.5.5.5.0.0.8.1.e164.arpa", 100, 10, "u", "E2U+sip", string(regexp), ".", 300)
+		wire := NewResponse(0x1234).AddAnswer(answerFromRecord(t, record)).Build().ToBytesWithCompression()
+
+		response, err := NewDNSResponse(wire)
+		if err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+
+		naptr, err := response.Answers[0].ParseNAPTRRecord(wire)
+		if err != nil {
+			t.Fatalf("ParseNAPTRRecord failed: %v", err)
+		}
+
+		if naptr.Regexp != string(regexp) {
+			t.Errorf("regexp corrupted: got %q, want %q", naptr.Regexp, regexp)
+		}
+		if naptr.Flags != "u" || naptr.Services != "E2U+sip" || len(naptr.Replacement.Labels) != 0 {
+			t.Errorf("other fields corrupted: %+v", naptr)
+		}
+	})
+}
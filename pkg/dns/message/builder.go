@@ -0,0 +1,99 @@
+package message
+
+import (
+	"slices"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// ResponseBuilder assembles a DNSResponse section by section.
+// Header counts are derived from the sections when Build is called, so they
+// can never disagree with the records actually present in the message.
+//
+//	response := NewResponseFor(request).
+//		AddAnswer(answers...).
+//		SetRcode(types.RCODE_NO_ERROR).
+//		Build()
+type ResponseBuilder struct {
+	id                uint16
+	flags             types.DNSFlag
+	questions         []DNSQuestion
+	answers           []DNSAnswer
+	authorityRecords  []DNSAnswer
+	additionalRecords []DNSAnswer
+}
+
+// NewResponse starts building a response with the given message ID
+func NewResponse(id uint16) *ResponseBuilder {
+	return &ResponseBuilder{
+		id:    id,
+		flags: types.FLAG_QR_RESPONSE,
+	}
+}
+
+// NewResponseFor starts building the response to request: its ID, flags
+// prepared from the request's and its questions. Responses to requests
+// should be built with it, so they cannot carry another ID than the request
+func NewResponseFor(request *DNSRequest) *ResponseBuilder {
+	return NewResponse(request.Header.ID).
+		WithFlags(PrepareResponseFlags(request.Header.Flags)).
+		AddQuestion(request.Questions...)
+}
+
+// WithFlags replaces the header flags. The QR bit is always set by Build
+func (b *ResponseBuilder) WithFlags(flags types.DNSFlag) *ResponseBuilder {
+	b.flags = flags
+	return b
+}
+
+// SetRcode replaces the response code in the header flags
+func (b *ResponseBuilder) SetRcode(rcode types.DNSRCode) *ResponseBuilder {
+	b.flags = b.flags.SetRcode(rcode)
+	return b
+}
+
+// AddQuestion appends questions to the question section
+func (b *ResponseBuilder) AddQuestion(questions ...DNSQuestion) *ResponseBuilder {
+	b.questions = append(b.questions, questions...)
+	return b
+}
+
+// AddAnswer appends records to the answer section
+func (b *ResponseBuilder) AddAnswer(answers ...DNSAnswer) *ResponseBuilder {
+	b.answers = append(b.answers, answers...)
+	return b
+}
+
+// AddAuthority appends records to the authority section
+func (b *ResponseBuilder) AddAuthority(records ...DNSAnswer) *ResponseBuilder {
+	b.authorityRecords = append(b.authorityRecords, records...)
+	return b
+}
+
+// AddAdditional appends records to the additional section
+func (b *ResponseBuilder) AddAdditional(records ...DNSAnswer) *ResponseBuilder {
+	b.additionalRecords = append(b.additionalRecords, records...)
+	return b
+}
+
+// Build produces the response. The builder can keep being used afterwards;
+// later additions do not affect responses that were already built
+func (b *ResponseBuilder) Build() *DNSResponse {
+	response := &DNSResponse{
+		Questions:         slices.Clone(b.questions),
+		Answers:           slices.Clone(b.answers),
+		AuthorityRecords:  slices.Clone(b.authorityRecords),
+		AdditionalRecords: slices.Clone(b.additionalRecords),
+	}
+
+	response.Header = DNSHeader{
+		ID:                    b.id,
+		Flags:                 b.flags | types.FLAG_QR_RESPONSE,
+		QuestionCount:         uint16(len(response.Questions)),
+		AnswerRecordCount:     uint16(len(response.Answers)),
+		AuthorityRecordCount:  uint16(len(response.AuthorityRecords)),
+		AdditionalRecordCount: uint16(len(response.AdditionalRecords)),
+	}
+
+	return response
+}
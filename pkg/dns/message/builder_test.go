@@ -0,0 +1,172 @@
+package message
+
+import (
+	"net"
+	"testing"
+
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// assertCountsMatchSections checks that the header counts agree with the sections
+func assertCountsMatchSections(t *testing.T, response *DNSResponse) {
+	t.Helper()
+
+	if int(response.Header.QuestionCount) != len(response.Questions) {
+		t.Errorf("QuestionCount = %d, want %d", response.Header.QuestionCount, len(response.Questions))
+	}
+	if int(response.Header.AnswerRecordCount) != len(response.Answers) {
+		t.Errorf("AnswerRecordCount = %d, want %d", response.Header.AnswerRecordCount, len(response.Answers))
+	}
+	if int(response.Header.AuthorityRecordCount) != len(response.AuthorityRecords) {
+		t.Errorf("AuthorityRecordCount = %d, want %d", response.Header.AuthorityRecordCount, len(response.AuthorityRecords))
+	}
+	if int(response.Header.AdditionalRecordCount) != len(response.AdditionalRecords) {
+		t.Errorf("AdditionalRecordCount = %d, want %d", response.Header.AdditionalRecordCount, len(response.AdditionalRecords))
+	}
+}
+
+func TestResponseBuilderCounts(t *testing.T) {
+	question := createTestDNSQuestion("example.com.", TYPE_A, CLASS_IN)
+	answer := mustBuildAnswer(t, records.NewBuilder().Name("example.com.").Type(TYPE_A).TTL(300).IPv4(net.IPv4(192, 0, 2, 1)))
+	ns := mustBuildAnswer(t, records.NewBuilder().Name("example.com.").Type(types.TYPE_NS).TTL(3600).Target("ns1.example.com."))
+	glue := mustBuildAnswer(t, records.NewBuilder().Name("ns1.example.com.").Type(TYPE_A).TTL(300).IPv4(net.IPv4(192, 0, 2, 53)))
+
+	tests := []struct {
+		name    string
+		builder *ResponseBuilder
+		counts  [4]int
+	}{
+		{
+			name:    "empty",
+			builder: NewResponse(1),
+		},
+		{
+			name:    "question and answers",
+			builder: NewResponse(2).AddQuestion(question).AddAnswer(answer, answer),
+			counts:  [4]int{1, 2, 0, 0},
+		},
+		{
+			name:    "all sections",
+			builder: NewResponse(3).AddQuestion(question).AddAnswer(answer).AddAuthority(ns).AddAdditional(glue, glue),
+			counts:  [4]int{1, 1, 1, 2},
+		},
+		{
+			name:    "authority only",
+			builder: NewResponse(4).AddQuestion(question).AddAuthority(ns),
+			counts:  [4]int{1, 0, 1, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := tt.builder.Build()
+			assertCountsMatchSections(t, response)
+
+			got := [4]int{
+				len(response.Questions),
+				len(response.Answers),
+				len(response.AuthorityRecords),
+				len(response.AdditionalRecords),
+			}
+			if got != tt.counts {
+				t.Errorf("section lengths = %v, want %v", got, tt.counts)
+			}
+
+			// Serialized header counts must match as well
+			parsed, err := NewDNSResponse(response.ToBytes())
+			if err != nil {
+				t.Fatalf("failed to parse built response: %v", err)
+			}
+			assertCountsMatchSections(t, parsed)
+			if parsed.Header != response.Header {
+				t.Errorf("parsed header = %+v, want %+v", parsed.Header, response.Header)
+			}
+		})
+	}
+}
+
+func TestResponseBuilderDeferredBuild(t *testing.T) {
+	question := createTestDNSQuestion("example.com.", TYPE_A, CLASS_IN)
+	answer := mustBuildAnswer(t, records.NewBuilder().Name("example.com.").Type(TYPE_A).TTL(300).IPv4(net.IPv4(192, 0, 2, 1)))
+
+	builder := NewResponse(0x1234).AddQuestion(question)
+	first := builder.Build()
+
+	// Sections added after a Build show up in the next Build only
+	builder.AddAnswer(answer).AddAuthority(answer).AddAdditional(answer)
+	second := builder.Build()
+
+	assertCountsMatchSections(t, first)
+	assertCountsMatchSections(t, second)
+
+	if len(first.Answers) != 0 || len(first.AuthorityRecords) != 0 || len(first.AdditionalRecords) != 0 {
+		t.Errorf("earlier response was modified by later additions: %+v", first.Header)
+	}
+	if second.Header.AnswerRecordCount != 1 || second.Header.AuthorityRecordCount != 1 || second.Header.AdditionalRecordCount != 1 {
+		t.Errorf("later response missing records: %+v", second.Header)
+	}
+}
+
+func TestResponseBuilderFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		builder  *ResponseBuilder
+		expected DNSFlag
+	}{
+		{
+			name:     "default flags",
+			builder:  NewResponse(1),
+			expected: FLAG_QR_RESPONSE,
+		},
+		{
+			name:     "QR always set",
+			builder:  NewResponse(1).WithFlags(FLAG_RD_RECURSION_DESIRED),
+			expected: FLAG_QR_RESPONSE | FLAG_RD_RECURSION_DESIRED,
+		},
+		{
+			name:     "rcode set",
+			builder:  NewResponse(1).WithFlags(FLAG_RD_RECURSION_DESIRED).SetRcode(types.RCODE_NAME_ERROR),
+			expected: FLAG_QR_RESPONSE | FLAG_RD_RECURSION_DESIRED | types.FLAG_RCODE_NAME_ERROR,
+		},
+		{
+			name:     "rcode replaced",
+			builder:  NewResponse(1).WithFlags(FLAG_RCODE_NOT_IMPLEMENTED).SetRcode(types.RCODE_SERVER_FAILURE),
+			expected: FLAG_QR_RESPONSE | types.FLAG_RCODE_SERVER_FAILURE,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := tt.builder.Build()
+			if response.Header.Flags != tt.expected {
+				t.Errorf("flags = 0x%04x, want 0x%04x", response.Header.Flags, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewResponseFor(t *testing.T) {
+	query := GenerateDNSQuery(0xbeef, []DNSQuestion{createTestDNSQuestion("example.com.", TYPE_A, CLASS_IN)})
+	request, err := NewDNSRequest(query.ToBytes())
+	if err != nil {
+		t.Fatalf("failed to parse request: %v", err)
+	}
+
+	answer := mustBuildAnswer(t, records.NewBuilder().Name("example.com.").Type(TYPE_A).TTL(300).IPv4(net.IPv4(192, 0, 2, 1)))
+	response := GenerateDNSResponseFor(request, []DNSAnswer{answer})
+
+	if response.Header.ID != request.Header.ID {
+		t.Errorf("ID = 0x%04x, want the request ID 0x%04x", response.Header.ID, request.Header.ID)
+	}
+	if response.Header.Flags != PrepareResponseFlags(request.Header.Flags) {
+		t.Errorf("flags = 0x%04x, want 0x%04x", response.Header.Flags, PrepareResponseFlags(request.Header.Flags))
+	}
+	if len(response.Questions) != 1 || response.Questions[0].Name.String() != "example.com." {
+		t.Errorf("questions = %v, want the request question", response.Questions)
+	}
+	if len(response.Answers) != 1 {
+		t.Errorf("answers = %d, want 1", len(response.Answers))
+	}
+	assertCountsMatchSections(t, response)
+}
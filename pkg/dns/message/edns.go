@@ -0,0 +1,181 @@
+package message
+
+import (
+	"fmt"
+
+	"github.com/vadim-su/dnska/pkg/dns/edns"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+const (
+	// DefaultUDPPayloadSize is the UDP message size limit without EDNS(0) (RFC 1035 §4.2.1)
+	DefaultUDPPayloadSize = 512
+	// MaxUDPPayloadSize caps the payload size a client may advertise
+	MaxUDPPayloadSize = 4096
+	// EDNSFlagDO is the DNSSEC OK bit of the extended flags an OPT record
+	// carries in its TTL field (RFC 3225 §3)
+	EDNSFlagDO = 0x8000
+)
+
+// UDPPayloadSize returns the largest UDP response the client accepts.
+// It is taken from the CLASS field of an EDNS(0) OPT record in the additional
+// section (RFC 6891 §6.2.3), bounded to [DefaultUDPPayloadSize, MaxUDPPayloadSize]
+func (request *DNSRequest) UDPPayloadSize() int {
+	for _, record := range request.AdditionalRecords {
+		if record.Type() != types.TYPE_OPT {
+			continue
+		}
+
+		size := int(record.Class())
+		return min(max(size, DefaultUDPPayloadSize), MaxUDPPayloadSize)
+	}
+
+	return DefaultUDPPayloadSize
+}
+
+// DNSSECOK reports whether the client asked for DNSSEC records by setting
+// the DO bit in its EDNS(0) OPT record
+func (request *DNSRequest) DNSSECOK() bool {
+	for _, record := range request.AdditionalRecords {
+		if record.Type() == types.TYPE_OPT {
+			return record.TTL()&EDNSFlagDO != 0
+		}
+	}
+	return false
+}
+
+// NewOPTAnswer creates an EDNS(0) OPT pseudo-record advertising payloadSize
+// and carrying options (RFC 6891 §6.1.2)
+func NewOPTAnswer(payloadSize uint16, options ...edns.Option) *DNSAnswer {
+	return NewDNSAnswerFromParts(utils.DomainName{}, types.TYPE_OPT, types.DNSClass(payloadSize), 0, edns.PackOptions(options))
+}
+
+// ClientSubnet returns the EDNS Client Subnet option of the message, or nil
+// when it has none or it is malformed
+func (d *DNSResponse) ClientSubnet() *edns.ClientSubnet {
+	subnet, _ := clientSubnetOf(d.AdditionalRecords)
+	return subnet
+}
+
+// AddClientSubnet adds subnet as an EDNS Client Subnet option, to the OPT
+// record of the message or to a new one. It replaces any such option present
+func (d *DNSResponse) AddClientSubnet(subnet *edns.ClientSubnet) {
+	d.setOption(subnet.Option())
+}
+
+// Cookie returns the COOKIE option of the message, or nil when it has none
+// or it is malformed
+func (d *DNSResponse) Cookie() *edns.Cookie {
+	cookie, _ := cookieOf(d.AdditionalRecords)
+	return cookie
+}
+
+// AddCookie adds cookie as a COOKIE option, to the OPT record of the message
+// or to a new one. It replaces any such option present
+func (d *DNSResponse) AddCookie(cookie *edns.Cookie) {
+	d.setOption(cookie.Option())
+}
+
+// Rcode returns the response code of the message, including the upper 8
+// bits of an extended one carried in the OPT record (RFC 6891 §6.1.3)
+func (d *DNSResponse) Rcode() types.DNSRCode {
+	rcode := d.Header.Flags.Rcode()
+	for _, record := range d.AdditionalRecords {
+		if record.Type() == types.TYPE_OPT {
+			rcode |= types.DNSRCode(record.TTL()>>24) << 4
+			break
+		}
+	}
+	return rcode
+}
+
+// SetRcode replaces the response code of the message. The upper 8 bits of
+// an extended one go to the OPT record, which is added when missing
+func (d *DNSResponse) SetRcode(rcode types.DNSRCode) {
+	d.Header.Flags = d.Header.Flags.SetRcode(rcode)
+	d.wire = nil
+
+	extended := uint32(rcode>>4) & 0xFF
+	for i, record := range d.AdditionalRecords {
+		if record.Type() != types.TYPE_OPT {
+			continue
+		}
+		ttl := record.TTL()&0x00FFFFFF | extended<<24
+		d.AdditionalRecords[i] = *NewDNSAnswerFromParts(record.Name(), types.TYPE_OPT, record.Class(), ttl, record.Data())
+		return
+	}
+
+	if extended != 0 {
+		opt := NewDNSAnswerFromParts(utils.DomainName{}, types.TYPE_OPT, MaxUDPPayloadSize, extended<<24, nil)
+		d.AdditionalRecords = append(d.AdditionalRecords, *opt)
+		d.Header.AdditionalRecordCount++
+	}
+}
+
+// setOption adds option to the OPT record of the message or to a new one,
+// replacing any option with the same code
+func (d *DNSResponse) setOption(option edns.Option) {
+	d.wire = nil
+	for i, record := range d.AdditionalRecords {
+		if record.Type() != types.TYPE_OPT {
+			continue
+		}
+
+		options, _ := edns.ParseOptions(record.Data())
+		kept := []edns.Option{option}
+		for _, existing := range options {
+			if existing.Code != option.Code {
+				kept = append(kept, existing)
+			}
+		}
+		d.AdditionalRecords[i] = *NewDNSAnswerFromParts(record.Name(), types.TYPE_OPT, record.Class(), record.TTL(), edns.PackOptions(kept))
+		return
+	}
+
+	d.AdditionalRecords = append(d.AdditionalRecords, *NewOPTAnswer(MaxUDPPayloadSize, option))
+	d.Header.AdditionalRecordCount++
+}
+
+// clientSubnetOf decodes the EDNS Client Subnet option of the OPT record
+// among additional, returning nil when there is none
+func clientSubnetOf(additional []DNSAnswer) (*edns.ClientSubnet, error) {
+	data, err := optionOf(additional, edns.OPTION_CLIENT_SUBNET)
+	if data == nil || err != nil {
+		return nil, err
+	}
+	return edns.ParseClientSubnet(data)
+}
+
+// cookieOf decodes the COOKIE option of the OPT record among additional,
+// returning nil when there is none
+func cookieOf(additional []DNSAnswer) (*edns.Cookie, error) {
+	data, err := optionOf(additional, edns.OPTION_COOKIE)
+	if data == nil || err != nil {
+		return nil, err
+	}
+	return edns.ParseCookie(data)
+}
+
+// optionOf returns the data of the option with code in the OPT record among
+// additional, nil when there is none
+func optionOf(additional []DNSAnswer, code uint16) ([]byte, error) {
+	for _, record := range additional {
+		if record.Type() != types.TYPE_OPT {
+			continue
+		}
+
+		options, err := edns.ParseOptions(record.Data())
+		if err != nil {
+			return nil, fmt.Errorf("invalid OPT record: %w", err)
+		}
+		for _, option := range options {
+			if option.Code == code {
+				// The data of an empty option is empty but not nil
+				return option.Data, nil
+			}
+		}
+		return nil, nil
+	}
+	return nil, nil
+}
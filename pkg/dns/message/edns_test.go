@@ -0,0 +1,196 @@
+package message
+
+import (
+	"net"
+	"testing"
+
+	"github.com/vadim-su/dnska/pkg/dns/edns"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+func TestClientSubnet(t *testing.T) {
+	name, err := utils.ParseDomainName("www.example.com.")
+	if err != nil {
+		t.Fatalf("failed to parse name: %v", err)
+	}
+	questions := []DNSQuestion{NewDNSQuestion(name, types.TYPE_A, types.CLASS_IN)}
+
+	tests := []struct {
+		name    string
+		subnet  *edns.ClientSubnet
+		options []edns.Option // Of an OPT record added before the subnet
+		want    string
+	}{
+		{name: "without EDNS"},
+		{name: "without the option", options: []edns.Option{{Code: 10, Data: []byte{1, 2, 3, 4, 5, 6, 7, 8}}}},
+		{name: "IPv4", subnet: edns.NewClientSubnet(net.ParseIP("192.0.2.1"), 24), want: "192.0.2.0/24/0"},
+		{
+			name:    "IPv6 next to other options",
+			subnet:  edns.NewClientSubnet(net.ParseIP("2001:db8::1"), 48),
+			options: []edns.Option{{Code: 10, Data: []byte{1, 2, 3, 4, 5, 6, 7, 8}}},
+			want:    "2001:db8::/48/0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := GenerateDNSQuery(0x1234, questions)
+			if tt.options != nil {
+				query.AdditionalRecords = append(query.AdditionalRecords, *NewOPTAnswer(1232, tt.options...))
+				query.Header.AdditionalRecordCount++
+			}
+			if tt.subnet != nil {
+				query.AddClientSubnet(tt.subnet)
+			}
+
+			request, err := NewDNSRequest(query.ToBytesWithCompression())
+			if err != nil {
+				t.Fatalf("NewDNSRequest() returned error: %v", err)
+			}
+			if len(request.AdditionalRecords) > 1 {
+				t.Errorf("expected a single OPT record, got %d records", len(request.AdditionalRecords))
+			}
+			if tt.options != nil && request.UDPPayloadSize() != 1232 {
+				t.Errorf("UDPPayloadSize() = %d, the OPT record was replaced", request.UDPPayloadSize())
+			}
+
+			if tt.want == "" {
+				if request.ClientSubnet != nil {
+					t.Errorf("ClientSubnet = %s, expected none", request.ClientSubnet)
+				}
+				return
+			}
+			if request.ClientSubnet == nil || request.ClientSubnet.String() != tt.want {
+				t.Errorf("ClientSubnet = %v, want %s", request.ClientSubnet, tt.want)
+			}
+			if subnet := query.ClientSubnet(); subnet == nil || subnet.String() != tt.want {
+				t.Errorf("DNSResponse.ClientSubnet() = %v, want %s", subnet, tt.want)
+			}
+		})
+	}
+
+	t.Run("malformed", func(t *testing.T) {
+		query := GenerateDNSQuery(0x1234, questions)
+		query.AdditionalRecords = []DNSAnswer{*NewOPTAnswer(1232, edns.Option{Code: edns.OPTION_CLIENT_SUBNET, Data: []byte{0, 1, 24}})}
+		query.Header.AdditionalRecordCount = 1
+		if request, err := NewDNSRequest(query.ToBytes()); err == nil {
+			t.Errorf("NewDNSRequest() = %+v, expected an error", request)
+		}
+	})
+}
+
+func TestDNSSECOK(t *testing.T) {
+	name, err := utils.ParseDomainName("www.example.com.")
+	if err != nil {
+		t.Fatalf("failed to parse name: %v", err)
+	}
+	questions := []DNSQuestion{NewDNSQuestion(name, types.TYPE_A, types.CLASS_IN)}
+
+	tests := []struct {
+		name  string
+		opt   *DNSAnswer
+		doSet bool
+	}{
+		{name: "without EDNS"},
+		{name: "DO clear", opt: NewOPTAnswer(1232)},
+		{name: "DO set", opt: NewDNSAnswerFromParts(utils.DomainName{}, types.TYPE_OPT, 1232, EDNSFlagDO, nil), doSet: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := GenerateDNSQuery(0x1234, questions)
+			if tt.opt != nil {
+				query.AdditionalRecords = append(query.AdditionalRecords, *tt.opt)
+				query.Header.AdditionalRecordCount++
+			}
+
+			request, err := NewDNSRequest(query.ToBytesWithCompression())
+			if err != nil {
+				t.Fatalf("NewDNSRequest() returned error: %v", err)
+			}
+			if got := request.DNSSECOK(); got != tt.doSet {
+				t.Errorf("DNSSECOK() = %v, expected %v", got, tt.doSet)
+			}
+		})
+	}
+}
+
+func TestCookie(t *testing.T) {
+	name, err := utils.ParseDomainName("www.example.com.")
+	if err != nil {
+		t.Fatalf("failed to parse name: %v", err)
+	}
+	questions := []DNSQuestion{NewDNSQuestion(name, types.TYPE_A, types.CLASS_IN)}
+	client := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	server := []byte{9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		invalid bool
+	}{
+		{name: "client cookie", data: client, want: "0102030405060708"},
+		{name: "server cookie", data: append(append([]byte{}, client...), server...), want: "0102030405060708/090a0b0c0d0e0f101112131415161718"},
+		{name: "empty", data: []byte{}, invalid: true},
+		{name: "short client cookie", data: client[:5], invalid: true},
+		{name: "short server cookie", data: append(append([]byte{}, client...), 1, 2, 3), invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := GenerateDNSQuery(0x1234, questions)
+			query.AdditionalRecords = append(query.AdditionalRecords, *NewOPTAnswer(1232, edns.Option{Code: edns.OPTION_COOKIE, Data: tt.data}))
+			query.Header.AdditionalRecordCount++
+
+			request, err := NewDNSRequest(query.ToBytesWithCompression())
+			if tt.invalid {
+				if err == nil {
+					t.Fatal("NewDNSRequest() accepted a malformed cookie")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewDNSRequest() returned error: %v", err)
+			}
+			if request.Cookie == nil || request.Cookie.String() != tt.want {
+				t.Errorf("Cookie = %v, want %s", request.Cookie, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseExtendedRcode(t *testing.T) {
+	response := NewResponse(0x1234).Build()
+	response.AddCookie(&edns.Cookie{Client: []byte{1, 2, 3, 4, 5, 6, 7, 8}})
+	response.SetRcode(types.RCODE_BADCOOKIE)
+
+	parsed, err := NewDNSResponse(response.ToBytesWithCompression())
+	if err != nil {
+		t.Fatalf("NewDNSResponse() returned error: %v", err)
+	}
+	if parsed.Rcode() != types.RCODE_BADCOOKIE {
+		t.Errorf("Rcode() = %s, want BADCOOKIE", parsed.Rcode())
+	}
+	if parsed.Header.Flags.Rcode() != types.RCODE_BADCOOKIE&0xF {
+		t.Errorf("header RCODE = %d, want the lower 4 bits of BADCOOKIE", parsed.Header.Flags.Rcode())
+	}
+	if cookie := parsed.Cookie(); cookie == nil || cookie.String() != "0102030405060708" {
+		t.Errorf("Cookie() = %v, the cookie was lost", cookie)
+	}
+
+	// Setting a plain RCODE clears the upper bits
+	response.SetRcode(types.RCODE_REFUSED)
+	if response.Rcode() != types.RCODE_REFUSED {
+		t.Errorf("Rcode() = %s, want REFUSED", response.Rcode())
+	}
+
+	// An extended RCODE needs an OPT record, which is added
+	response = NewResponse(0x1234).Build()
+	response.SetRcode(types.RCODE_BADCOOKIE)
+	if response.Rcode() != types.RCODE_BADCOOKIE || response.Header.AdditionalRecordCount != 1 {
+		t.Errorf("Rcode() = %s with %d additional records, want BADCOOKIE and an OPT record",
+			response.Rcode(), response.Header.AdditionalRecordCount)
+	}
+}
@@ -35,19 +35,17 @@ func NewDNSHeader(
 
 // Convert DNSHeader to byte array
 func (h *DNSHeader) ToBytes() []byte {
-	var header = make([]byte, 12)
-	header[0] = byte(h.ID >> 8)
-	header[1] = byte(h.ID & 0xFF)
-	header[2] = byte(h.Flags >> 8)
-	header[3] = byte(h.Flags & 0xFF)
-	header[4] = byte(h.QuestionCount >> 8)
-	header[5] = byte(h.QuestionCount & 0xFF)
-	header[6] = byte(h.AnswerRecordCount >> 8)
-	header[7] = byte(h.AnswerRecordCount & 0xFF)
-	header[8] = byte(h.AuthorityRecordCount >> 8)
-	header[9] = byte(h.AuthorityRecordCount & 0xFF)
-	header[10] = byte(h.AdditionalRecordCount >> 8)
-	header[11] = byte(h.AdditionalRecordCount & 0xFF)
+	return h.AppendBytes(make([]byte, 0, 12))
+}
 
-	return header
+// AppendBytes appends the 12-byte header to dst
+func (h *DNSHeader) AppendBytes(dst []byte) []byte {
+	return append(dst,
+		byte(h.ID>>8), byte(h.ID&0xFF),
+		byte(h.Flags>>8), byte(h.Flags&0xFF),
+		byte(h.QuestionCount>>8), byte(h.QuestionCount&0xFF),
+		byte(h.AnswerRecordCount>>8), byte(h.AnswerRecordCount&0xFF),
+		byte(h.AuthorityRecordCount>>8), byte(h.AuthorityRecordCount&0xFF),
+		byte(h.AdditionalRecordCount>>8), byte(h.AdditionalRecordCount&0xFF),
+	)
 }
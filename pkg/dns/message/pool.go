@@ -0,0 +1,80 @@
+package message
+
+import "sync"
+
+// BufferPool recycles the buffers messages are read into. A request parsed
+// from a pooled buffer refers to it for its names and RDATA, so the buffer
+// is only returned to the pool once the request is released
+type BufferPool struct {
+	size int
+	pool sync.Pool
+}
+
+// NewBufferPool creates a pool of buffers of size bytes
+func NewBufferPool(size int) *BufferPool {
+	p := &BufferPool{size: size}
+	p.pool.New = func() any {
+		buffer := make([]byte, size)
+		return &buffer
+	}
+	return p
+}
+
+// Get returns a buffer of length bytes. Buffers longer than the pool's size
+// are allocated rather than taken from the pool
+func (p *BufferPool) Get(length int) *[]byte {
+	if length > p.size {
+		buffer := make([]byte, length)
+		return &buffer
+	}
+	buffer := p.pool.Get().(*[]byte)
+	*buffer = (*buffer)[:length]
+	return buffer
+}
+
+// Put returns a buffer to the pool. Buffers of other sizes than the pool's
+// are left to the garbage collector, as are all buffers given to a nil pool
+func (p *BufferPool) Put(buffer *[]byte) {
+	if p == nil || cap(*buffer) != p.size {
+		return
+	}
+	p.pool.Put(buffer)
+}
+
+// NewPooledDNSRequest parses the request in buffer, a buffer of pool, like
+// NewDNSRequestWithOptions. The request takes buffer over and returns it to
+// pool on Release; on error buffer stays with the caller. With a nil pool
+// the request is not pooled and Release does nothing.
+//
+// Args:
+//
+//	pool: Pool buffer was taken from.
+//	buffer: Raw DNS packet bytes to parse.
+//	options: Limits and strictness checks to apply.
+//
+// Returns:
+//
+//	Parsed DNSRequest struct and any parsing error.
+func NewPooledDNSRequest(pool *BufferPool, buffer *[]byte, options ParseOptions) (*DNSRequest, error) {
+	request, err := NewDNSRequestWithOptions(*buffer, options)
+	if err != nil {
+		return nil, err
+	}
+	request.pool = pool
+	request.buffer = buffer
+	return request, nil
+}
+
+// Release returns the buffer of a request parsed by NewPooledDNSRequest to
+// its pool. The names and RDATA of the request refer to the buffer, so the
+// request must not be used afterwards. Release does nothing for other
+// requests
+func (request *DNSRequest) Release() {
+	if request.pool == nil {
+		return
+	}
+	request.pool.Put(request.buffer)
+	request.pool = nil
+	request.buffer = nil
+	request.wire = nil
+}
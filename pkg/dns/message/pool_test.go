@@ -0,0 +1,125 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// pooledTestQuery returns a query for www.example.com with an OPT record,
+// as resolvers send them
+func pooledTestQuery(t testing.TB) []byte {
+	t.Helper()
+
+	name, err := utils.ParseDomainName("www.example.com.")
+	if err != nil {
+		t.Fatalf("failed to parse name: %v", err)
+	}
+	query := GenerateDNSQuery(0x1234, []DNSQuestion{NewDNSQuestion(name, types.TYPE_A, types.CLASS_IN)})
+	query.AdditionalRecords = append(query.AdditionalRecords, *NewOPTAnswer(1232))
+	query.Header.AdditionalRecordCount = 1
+	return query.ToBytesWithCompression()
+}
+
+func TestBufferPool(t *testing.T) {
+	pool := NewBufferPool(512)
+
+	buffer := pool.Get(100)
+	if len(*buffer) != 100 || cap(*buffer) != 512 {
+		t.Errorf("Get(100) returned len %d cap %d, want len 100 cap 512", len(*buffer), cap(*buffer))
+	}
+	pool.Put(buffer)
+
+	large := pool.Get(1000)
+	if len(*large) != 1000 {
+		t.Errorf("Get(1000) returned len %d, want 1000", len(*large))
+	}
+	pool.Put(large) // Not kept, as it is larger than the pool's buffers
+
+	var none *BufferPool
+	none.Put(buffer)
+}
+
+func TestNewPooledDNSRequest(t *testing.T) {
+	pool := NewBufferPool(512)
+	query := pooledTestQuery(t)
+
+	buffer := pool.Get(len(query))
+	copy(*buffer, query)
+
+	request, err := NewPooledDNSRequest(pool, buffer, DefaultParseOptions())
+	if err != nil {
+		t.Fatalf("NewPooledDNSRequest() returned error: %v", err)
+	}
+	if got := request.Questions[0].Name.String(); got != "www.example.com." {
+		t.Errorf("question name = %q, want www.example.com.", got)
+	}
+	if got := request.UDPPayloadSize(); got != 1232 {
+		t.Errorf("UDPPayloadSize() = %d, want 1232", got)
+	}
+
+	// The name refers to the pooled buffer rather than a copy of it
+	if &request.Questions[0].Name.Labels[0].Content[0] != &(*buffer)[13] {
+		t.Error("question name does not refer to the pooled buffer")
+	}
+
+	request.Release()
+	if request.buffer != nil || request.wire != nil {
+		t.Error("Release() kept the buffer")
+	}
+	request.Release() // Releasing twice does nothing
+
+	// A request that fails to parse leaves the buffer with the caller
+	buffer = pool.Get(5)
+	if _, err := NewPooledDNSRequest(pool, buffer, DefaultParseOptions()); err == nil {
+		t.Fatal("NewPooledDNSRequest() accepted a truncated message")
+	}
+}
+
+func TestDNSRequestReleaseWithoutPool(t *testing.T) {
+	request, err := NewDNSRequest(pooledTestQuery(t))
+	if err != nil {
+		t.Fatalf("NewDNSRequest() returned error: %v", err)
+	}
+	request.Release()
+	if request.wire == nil {
+		t.Error("Release() dropped the message of a request without a pool")
+	}
+}
+
+// BenchmarkParseRequest compares parsing queries from a buffer allocated
+// for each of them with parsing them from pooled buffers
+func BenchmarkParseRequest(b *testing.B) {
+	query := pooledTestQuery(b)
+	options := DefaultParseOptions()
+
+	b.Run("new buffer", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				data := make([]byte, len(query))
+				copy(data, query)
+				if _, err := NewDNSRequestWithOptions(data, options); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+
+	b.Run("pooled buffer", func(b *testing.B) {
+		pool := NewBufferPool(512)
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				buffer := pool.Get(len(query))
+				copy(*buffer, query)
+				request, err := NewPooledDNSRequest(pool, buffer, options)
+				if err != nil {
+					b.Fatal(err)
+				}
+				request.Release()
+			}
+		})
+	})
+}
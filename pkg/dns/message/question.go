@@ -3,16 +3,37 @@ package message
 import (
 	"fmt"
 
+	"github.com/vadim-su/dnska/pkg/dns/types"
 	"github.com/vadim-su/dnska/pkg/dns/utils"
 )
 
-// DNSQuestion represents a DNS question record.
+// DNSQuestion represents a DNS question record. Class and Type hold the wire
+// format; Qclass and Qtype return them as typed values
 type DNSQuestion struct {
 	Name  utils.DomainName
 	Class [2]byte
 	Type  [2]byte
 }
 
+// NewDNSQuestion creates a question for records of type t and class c
+func NewDNSQuestion(name utils.DomainName, t types.DNSType, c types.DNSClass) DNSQuestion {
+	return DNSQuestion{
+		Name:  name,
+		Class: types.DnsTypeClassToBytes(c),
+		Type:  types.DnsTypeClassToBytes(t),
+	}
+}
+
+// Qtype returns the record type asked for
+func (d *DNSQuestion) Qtype() types.DNSType {
+	return types.DnsTypeClassFromBytes[types.DNSType](d.Type)
+}
+
+// Qclass returns the class asked for
+func (d *DNSQuestion) Qclass() types.DNSClass {
+	return types.DnsTypeClassFromBytes[types.DNSClass](d.Class)
+}
+
 // Create a new DNS question
 func NewDNSQuestions(data []byte, count uint16, originalMessage []byte) ([]DNSQuestion, uint16, error) {
 	resultQuestions := make([]DNSQuestion, 0)
@@ -26,14 +47,14 @@ func NewDNSQuestions(data []byte, count uint16, originalMessage []byte) ([]DNSQu
 		questionsDataSize += domainDataSize
 
 		if len(data[domainDataSize:]) < 4 {
-			return nil, 0, fmt.Errorf("not enough bytes for class and type")
+			return nil, 0, fmt.Errorf("not enough bytes for type and class")
 		}
 
 		data = data[domainDataSize:] // Remove domain name data
 
-		class := [2]byte{data[0], data[1]}
-		type_ := [2]byte{data[2], data[3]}
-		questionsDataSize += 4 // Add 4 bytes for class and type
+		type_ := [2]byte{data[0], data[1]}
+		class := [2]byte{data[2], data[3]}
+		questionsDataSize += 4 // Add 4 bytes for type and class
 
 		resultQuestions = append(resultQuestions, DNSQuestion{
 			*dnsName,
@@ -41,7 +62,7 @@ func NewDNSQuestions(data []byte, count uint16, originalMessage []byte) ([]DNSQu
 			type_,
 		})
 
-		data = data[4:] // Move past class and type bytes
+		data = data[4:] // Move past type and class bytes
 	}
 
 	return resultQuestions, questionsDataSize, nil
@@ -51,8 +72,8 @@ func NewDNSQuestions(data []byte, count uint16, originalMessage []byte) ([]DNSQu
 func (d *DNSQuestion) ToBytes() []byte {
 	question := d.Name.ToBytes()
 
-	question = append(question, d.Class[:]...)
 	question = append(question, d.Type[:]...)
+	question = append(question, d.Class[:]...)
 
 	return question
 }
@@ -63,7 +84,7 @@ func (d *DNSQuestion) ToBytesWithCompression(
 	currentOffset uint16,
 ) []byte {
 	nameBytes := d.Name.ToBytesWithCompression(compressionMap, currentOffset)
-	result := append(nameBytes, d.Class[:]...)
-	result = append(result, d.Type[:]...)
+	result := append(nameBytes, d.Type[:]...)
+	result = append(result, d.Class[:]...)
 	return result
 }
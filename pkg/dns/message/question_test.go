@@ -6,6 +6,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/vadim-su/dnska/pkg/dns/types"
 	"github.com/vadim-su/dnska/pkg/dns/utils"
 )
 
@@ -37,8 +38,8 @@ func TestDNSQuestionToBytesWithCompression(t *testing.T) {
 				0x07, 'e', 'x', 'a', 'm', 'p', 'l', 'e',
 				0x03, 'c', 'o', 'm',
 				0x00,       // End of domain name
-				0x00, 0x01, // Class IN
 				0x00, 0x01, // Type A
+				0x00, 0x01, // Class IN
 			},
 			expectedMapEntries: 2,
 			description:        "Should encode question without compression and add domain to map",
@@ -63,8 +64,8 @@ func TestDNSQuestionToBytesWithCompression(t *testing.T) {
 			currentOffset: 50,
 			expectedResult: []byte{
 				0xC0, 0x14, // Compression pointer to offset 20
-				0x00, 0x01, // Class IN
 				0x00, 0x1C, // Type AAAA
+				0x00, 0x01, // Class IN
 			},
 			expectedMapEntries: 0,
 			description:        "Should use compression pointer when domain exists in map",
@@ -91,8 +92,8 @@ func TestDNSQuestionToBytesWithCompression(t *testing.T) {
 			expectedResult: []byte{
 				0x03, 'w', 'w', 'w',
 				0xC0, 0x19, // Compression pointer to offset 25
-				0x00, 0x01, // Class IN
 				0x00, 0x0F, // Type MX
+				0x00, 0x01, // Class IN
 			},
 			expectedMapEntries: 0,
 			description:        "Should use suffix compression when available",
@@ -110,8 +111,8 @@ func TestDNSQuestionToBytesWithCompression(t *testing.T) {
 			currentOffset:  100,
 			expectedResult: []byte{
 				0x00,       // Root domain
-				0x00, 0x01, // Class IN
 				0x00, 0x02, // Type NS
+				0x00, 0x01, // Class IN
 			},
 			expectedMapEntries: 1,
 			description:        "Should handle root domain correctly",
@@ -134,8 +135,8 @@ func TestDNSQuestionToBytesWithCompression(t *testing.T) {
 				0x04, 't', 'e', 's', 't',
 				0x03, 'o', 'r', 'g',
 				0x00,       // End of domain name
-				0x00, 0x03, // Class CH
 				0x00, 0x10, // Type TXT
+				0x00, 0x03, // Class CH
 			},
 			expectedMapEntries: 2,
 			description:        "Should handle zero offset correctly",
@@ -156,10 +157,10 @@ func TestDNSQuestionToBytesWithCompression(t *testing.T) {
 			expectedResult: []byte{
 				0x01, 'a',
 				0x00,       // End of domain name
-				0xFF, 0xFF, // Class (max value)
 				0xFF, 0xFF, // Type (max value)
+				0xFF, 0xFF, // Class (max value)
 			},
-			expectedMapEntries: 1,
+			expectedMapEntries: 0, // offset is out of compression pointer range
 			description:        "Should handle maximum values correctly",
 		},
 	}
@@ -277,10 +278,10 @@ func TestDNSQuestionToBytesWithCompressionEdgeCases(t *testing.T) {
 					len(result), testCase.expectedLength, testCase.description)
 			}
 
-			// Verify structure: should end with class and type (4 bytes)
+			// Verify structure: should end with type and class (4 bytes)
 			if len(result) >= 4 {
-				class := [2]byte{result[len(result)-4], result[len(result)-3]}
-				resultType := [2]byte{result[len(result)-2], result[len(result)-1]}
+				resultType := [2]byte{result[len(result)-4], result[len(result)-3]}
+				class := [2]byte{result[len(result)-2], result[len(result)-1]}
 
 				if !bytes.Equal(class[:], testCase.question.Class[:]) {
 					t.Errorf("Class mismatch: got %v, want %v",
@@ -411,8 +412,8 @@ func TestDNSQuestionToBytes(t *testing.T) {
 				0x07, 'e', 'x', 'a', 'm', 'p', 'l', 'e',
 				0x03, 'c', 'o', 'm',
 				0x00,       // End of domain name
-				0x00, 0x01, // Class IN
 				0x00, 0x01, // Type A
+				0x00, 0x01, // Class IN
 			},
 			description: "Should encode simple A record question correctly",
 		},
@@ -427,8 +428,8 @@ func TestDNSQuestionToBytes(t *testing.T) {
 			},
 			expectedResult: []byte{
 				0x00,       // Root domain
-				0x00, 0x01, // Class IN
 				0x00, 0x02, // Type NS
+				0x00, 0x01, // Class IN
 			},
 			description: "Should encode root domain NS question correctly",
 		},
@@ -450,8 +451,8 @@ func TestDNSQuestionToBytes(t *testing.T) {
 				0x07, 'e', 'x', 'a', 'm', 'p', 'l', 'e',
 				0x03, 'n', 'e', 't',
 				0x00,       // End of domain name
-				0x00, 0x01, // Class IN
 				0x00, 0x0F, // Type MX
+				0x00, 0x01, // Class IN
 			},
 			description: "Should encode MX record question correctly",
 		},
@@ -490,9 +491,9 @@ func TestDNSQuestionToBytesRoundTrip(t *testing.T) {
 		t.Fatalf("Question bytes too short: %d", len(questionBytes))
 	}
 
-	// Extract class and type from the end
-	extractedClass := [2]byte{questionBytes[len(questionBytes)-4], questionBytes[len(questionBytes)-3]}
-	extractedType := [2]byte{questionBytes[len(questionBytes)-2], questionBytes[len(questionBytes)-1]}
+	// Extract type and class from the end
+	extractedType := [2]byte{questionBytes[len(questionBytes)-4], questionBytes[len(questionBytes)-3]}
+	extractedClass := [2]byte{questionBytes[len(questionBytes)-2], questionBytes[len(questionBytes)-1]}
 
 	if !bytes.Equal(extractedClass[:], originalQuestion.Class[:]) {
 		t.Errorf("Class round-trip failed: got %v, want %v",
@@ -520,13 +521,7 @@ func createTestQuestion(domain string, class, recordType uint16) DNSQuestion {
 		}
 	}
 
-	return DNSQuestion{
-		Name: utils.DomainName{
-			Labels: labels,
-		},
-		Class: [2]byte{byte(class >> 8), byte(class & 0xFF)},
-		Type:  [2]byte{byte(recordType >> 8), byte(recordType & 0xFF)},
-	}
+	return NewDNSQuestion(utils.DomainName{Labels: labels}, types.DNSType(recordType), types.DNSClass(class))
 }
 
 func TestCreateTestQuestion(t *testing.T) {
@@ -554,3 +549,49 @@ func TestCreateTestQuestion(t *testing.T) {
 		t.Errorf("Expected type %v, got %v", expectedType, question.Type)
 	}
 }
+
+func TestNewDNSQuestion(t *testing.T) {
+	name, err := utils.NewDomainNameFromString("example.com")
+	if err != nil {
+		t.Fatalf("Failed to create domain name: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		recordType types.DNSType
+		class      types.DNSClass
+		wire       []byte
+	}{
+		{name: "A IN", recordType: types.TYPE_A, class: types.CLASS_IN, wire: []byte{0x00, 0x01, 0x00, 0x01}},
+		{name: "AAAA IN", recordType: types.TYPE_AAAA, class: types.CLASS_IN, wire: []byte{0x00, 0x1C, 0x00, 0x01}},
+		{name: "TXT CH", recordType: types.TYPE_TXT, class: types.CLASS_CH, wire: []byte{0x00, 0x10, 0x00, 0x03}},
+		{name: "maximum values", recordType: 0xFFFF, class: 0xFFFF, wire: []byte{0xFF, 0xFF, 0xFF, 0xFF}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			question := NewDNSQuestion(*name, tt.recordType, tt.class)
+
+			if question.Qtype() != tt.recordType {
+				t.Errorf("Qtype() = %d, expected %d", question.Qtype(), tt.recordType)
+			}
+			if question.Qclass() != tt.class {
+				t.Errorf("Qclass() = %d, expected %d", question.Qclass(), tt.class)
+			}
+
+			// The type and class follow the name on the wire
+			data := question.ToBytes()
+			if wire := data[len(data)-4:]; !bytes.Equal(wire, tt.wire) {
+				t.Errorf("wire format = %v, expected %v", wire, tt.wire)
+			}
+
+			parsed, _, err := NewDNSQuestions(data, 1, data)
+			if err != nil {
+				t.Fatalf("Failed to parse question: %v", err)
+			}
+			if parsed[0].Qtype() != tt.recordType || parsed[0].Qclass() != tt.class {
+				t.Errorf("parsed %s %s, expected %s %s", parsed[0].Qtype(), parsed[0].Qclass(), tt.recordType, tt.class)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package message
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// ErrUnsupportedRData is returned by DecodeRData for record types it has no
+// typed representation for
+var ErrUnsupportedRData = errors.New("unsupported RDATA type")
+
+// DecodeRData builds the typed record held by answer. Names embedded in the
+// RDATA are decompressed against originalMessage, the message the answer was
+// parsed from, so the result no longer depends on that message.
+//
+// Args:
+//
+//	answer: Parsed answer whose RDATA should be decoded.
+//	originalMessage: Raw message the answer was read from.
+//
+// Returns:
+//
+//	Typed record, or an error wrapping ErrUnsupportedRData for unknown types.
+func DecodeRData(answer DNSAnswer, originalMessage []byte) (records.DNSRecord, error) {
+	name := answer.Name()
+	owner := name.String()
+	ttl := answer.TTL()
+	data := answer.Data()
+
+	switch answer.Type() {
+	case types.TYPE_A:
+		ip, err := answer.ParseARecord()
+		if err != nil {
+			return nil, err
+		}
+		return records.NewARecord(owner, ip, ttl), nil
+
+	case types.TYPE_AAAA:
+		ip, err := answer.ParseAAAARecord()
+		if err != nil {
+			return nil, err
+		}
+		return records.NewAAAARecord(owner, ip, ttl), nil
+
+	case types.TYPE_CAA:
+		caa, err := answer.ParseCAARecord()
+		if err != nil {
+			return nil, err
+		}
+		return records.NewCAARecord(owner, caa.Tag, caa.Value, caa.Flags, ttl), nil
+
+	case types.TYPE_CNAME:
+		target, err := decodeRDataName(data, originalMessage, "CNAME")
+		if err != nil {
+			return nil, err
+		}
+		return records.NewCNAMERecord(owner, target, ttl), nil
+
+	case types.TYPE_DNAME:
+		target, err := decodeRDataName(data, originalMessage, "DNAME")
+		if err != nil {
+			return nil, err
+		}
+		return records.NewDNAMERecord(owner, target, ttl), nil
+
+	case types.TYPE_NAPTR:
+		naptr, err := answer.ParseNAPTRRecord(originalMessage)
+		if err != nil {
+			return nil, err
+		}
+		return records.NewNAPTRRecord(owner, naptr.Order, naptr.Preference,
+			naptr.Flags, naptr.Services, naptr.Regexp, naptr.Replacement.String(), ttl), nil
+
+	case types.TYPE_NS:
+		nameServer, err := decodeRDataName(data, originalMessage, "NS")
+		if err != nil {
+			return nil, err
+		}
+		return records.NewNSRecord(owner, nameServer, ttl), nil
+
+	case types.TYPE_PTR:
+		target, err := decodeRDataName(data, originalMessage, "PTR")
+		if err != nil {
+			return nil, err
+		}
+		return records.NewPTRRecord(owner, target, ttl), nil
+
+	case types.TYPE_MX:
+		preference, exchange, err := answer.ParseMXRecord(originalMessage)
+		if err != nil {
+			return nil, err
+		}
+		return records.NewMXRecord(owner, exchange.String(), preference, ttl), nil
+
+	case types.TYPE_SRV:
+		srv, err := answer.ParseSRVRecord(originalMessage)
+		if err != nil {
+			return nil, err
+		}
+		return records.NewSRVRecord(owner, srv.Target.String(), srv.Priority, srv.Weight, srv.Port, ttl), nil
+
+	case types.TYPE_SVCB, types.TYPE_HTTPS:
+		svcb, err := answer.ParseSVCBRecord()
+		if err != nil {
+			return nil, err
+		}
+		if answer.Type() == types.TYPE_HTTPS {
+			return records.NewHTTPSRecord(owner, svcb.TargetName.String(), svcb.Priority, svcb.Params, ttl), nil
+		}
+		return records.NewSVCBRecord(owner, svcb.TargetName.String(), svcb.Priority, svcb.Params, ttl), nil
+
+	case types.TYPE_TXT:
+		texts, err := decodeCharacterStrings(data)
+		if err != nil {
+			return nil, err
+		}
+		return records.NewTXTRecord(owner, texts, ttl), nil
+
+	case types.TYPE_SOA:
+		return decodeSOA(answer, owner, originalMessage)
+
+	case types.TYPE_DNSKEY:
+		if len(data) < 4 {
+			return nil, fmt.Errorf("invalid DNSKEY record data: %d bytes", len(data))
+		}
+		record, err := records.NewDNSKEYRecord(owner, binary.BigEndian.Uint16(data), data[2], data[3], data[4:], ttl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DNSKEY record data: %w", err)
+		}
+		return record, nil
+
+	case types.TYPE_DS:
+		if len(data) < 4 {
+			return nil, fmt.Errorf("invalid DS record data: %d bytes", len(data))
+		}
+		record, err := records.NewDSRecord(owner, binary.BigEndian.Uint16(data), data[2], data[3], data[4:], ttl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DS record data: %w", err)
+		}
+		return record, nil
+
+	case types.TYPE_RRSIG:
+		return decodeRRSIG(owner, ttl, data, originalMessage)
+
+	case types.TYPE_NSEC:
+		nextName, size, err := utils.NewDomainNameWithDecompression(data, originalMessage)
+		if err != nil {
+			return nil, fmt.Errorf("invalid NSEC record data: %w", err)
+		}
+		recordTypes, err := records.DecodeTypeBitmap(data[size:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid NSEC record data: %w", err)
+		}
+		return records.NewNSECRecord(owner, nextName.String(), recordTypes, ttl), nil
+
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedRData, answer.Type())
+	}
+}
+
+// decodeRDataName decodes RDATA consisting of exactly one domain name
+func decodeRDataName(data, originalMessage []byte, recordType string) (string, error) {
+	name, size, err := utils.NewDomainNameWithDecompression(data, originalMessage)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s record data: %w", recordType, err)
+	}
+
+	if int(size) != len(data) {
+		return "", fmt.Errorf("invalid %s record data: %d trailing bytes", recordType, len(data)-int(size))
+	}
+
+	return name.String(), nil
+}
+
+// decodeCharacterStrings splits TXT RDATA into its length-prefixed strings
+func decodeCharacterStrings(data []byte) ([]string, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("invalid TXT record data: no character strings")
+	}
+
+	var texts []string
+	for len(data) > 0 {
+		length := int(data[0])
+		if len(data[1:]) < length {
+			return nil, fmt.Errorf("invalid TXT record data: string needs %d bytes, have %d", length, len(data[1:]))
+		}
+
+		texts = append(texts, string(data[1:1+length]))
+		data = data[1+length:]
+	}
+
+	return texts, nil
+}
+
+// decodeRRSIG builds an RRSIG record from its fixed fields, the signer name
+// and the signature filling the rest of the RDATA
+func decodeRRSIG(owner string, ttl uint32, data, originalMessage []byte) (records.DNSRecord, error) {
+	// Type covered, algorithm, labels, original TTL, expiration, inception and key tag
+	const fixedSize = 18
+	if len(data) < fixedSize {
+		return nil, fmt.Errorf("invalid RRSIG record data: %d bytes", len(data))
+	}
+
+	signerName, size, err := utils.NewDomainNameWithDecompression(data[fixedSize:], originalMessage)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RRSIG record data: %w", err)
+	}
+
+	seconds := func(value uint32) time.Time {
+		return time.Unix(int64(value), 0)
+	}
+
+	record, err := records.NewRRSIGRecord(owner,
+		types.DNSType(binary.BigEndian.Uint16(data)),
+		data[2],
+		data[3],
+		binary.BigEndian.Uint32(data[4:]),
+		seconds(binary.BigEndian.Uint32(data[8:])),
+		seconds(binary.BigEndian.Uint32(data[12:])),
+		binary.BigEndian.Uint16(data[16:]),
+		signerName.String(),
+		data[fixedSize+int(size):],
+		ttl,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RRSIG record data: %w", err)
+	}
+	return record, nil
+}
+
+// decodeSOA builds an SOA record from the parsed RDATA fields
+func decodeSOA(answer DNSAnswer, owner string, originalMessage []byte) (records.DNSRecord, error) {
+	soa, err := answer.ParseSOARecord(originalMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	seconds := func(value uint32) time.Duration {
+		return time.Duration(value) * time.Second
+	}
+
+	return records.NewSOARecord(
+		owner,
+		soa.MNAME.String(),
+		soa.RNAME.String(),
+		soa.Serial,
+		seconds(soa.Refresh),
+		seconds(soa.Retry),
+		seconds(soa.Expire),
+		seconds(soa.Minimum),
+		answer.TTL(),
+	), nil
+}
@@ -0,0 +1,179 @@
+package message
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+func TestDecodeRData(t *testing.T) {
+	dnskey, err := records.NewDNSKEYRecord("example.com", records.DNSKEYFlagZone, 3, 13, []byte{0x01, 0x02, 0x03, 0x04}, 3600)
+	if err != nil {
+		t.Fatalf("failed to create DNSKEY: %v", err)
+	}
+	ds, err := records.NewDSRecordFromDNSKEY(dnskey, records.DSDigestSHA256, 3600)
+	if err != nil {
+		t.Fatalf("failed to create DS: %v", err)
+	}
+	rrsig, err := records.NewRRSIGRecord("www.example.com", types.TYPE_A, 13, 3, 300,
+		time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		dnskey.KeyTag(), "example.com", []byte{0xDE, 0xAD, 0xBE, 0xEF}, 300)
+	if err != nil {
+		t.Fatalf("failed to create RRSIG: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		record records.DNSRecord
+	}{
+		{name: "A", record: records.NewARecord("www.example.com", net.ParseIP("192.0.2.1"), 300)},
+		{name: "AAAA", record: records.NewAAAARecord("www.example.com", net.ParseIP("2001:db8::1"), 300)},
+		{name: "CNAME", record: records.NewCNAMERecord("www.example.com", "web.example.com", 300)},
+		{
+			name: "NAPTR",
+			record: records.NewNAPTRRecord("4.3.2.1.5.5.5.0.0.8.1.e164.arpa", 100, 10, "u", "E2U+sip",
+				`!^.*$!sip:info@example.com!`, ".", 300),
+		},
+		{name: "NS", record: records.NewNSRecord("example.com", "ns1.example.com", 3600)},
+		{name: "PTR", record: records.NewPTRRecord("1.2.0.192.in-addr.arpa", "www.example.com", 300)},
+		{name: "MX", record: records.NewMXRecord("example.com", "mail.example.com", 10, 300)},
+		{name: "CAA", record: records.NewCAARecord("example.com", "issue", "ca.example.net; account=230123", 0, 300)},
+		{
+			name: "HTTPS",
+			record: records.NewHTTPSRecord("example.com", ".", 1,
+				[]records.SvcParam{records.NewALPNParam("h2", "h3"), records.NewPortParam(443)}, 300),
+		},
+		{name: "SVCB alias", record: records.NewSVCBRecord("_dns.example.com", "dns.example.net", 0, nil, 300)},
+		{name: "DNAME", record: records.NewDNAMERecord("old.example.com", "new.example.com", 300)},
+		{name: "SRV", record: records.NewSRVRecord("_sip._udp.example.com", "sip.example.com", 10, 60, 5060, 300)},
+		{name: "TXT", record: records.NewTXTRecord("example.com", []string{"v=spf1 -all", "", "second"}, 300)},
+		{name: "TXT over 255 bytes", record: records.NewTXTRecordFromString("example.com", strings.Repeat("include:_spf.example.net ", 28), 300)},
+		{name: "TXT with quotes", record: records.NewTXTRecordFromString("example.com", `say "hi" \ bye`, 300)},
+		{name: "DNSKEY", record: dnskey},
+		{name: "DS", record: ds},
+		{name: "RRSIG", record: rrsig},
+		{
+			name:   "NSEC",
+			record: records.NewNSECRecord("example.com", "www.example.com", []types.DNSType{types.TYPE_NS, types.TYPE_SOA, types.TYPE_RRSIG, types.TYPE_NSEC, types.TYPE_DNSKEY, types.TYPE_CAA}, 300),
+		},
+		{
+			name: "SOA",
+			record: records.NewSOARecord("example.com", "ns1.example.com", "hostmaster.example.com",
+				2024010101, time.Hour, 15*time.Minute, 7*24*time.Hour, 5*time.Minute, 3600),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Write the record into a compressed message and parse it back
+			wire := NewResponse(0x1234).AddAnswer(answerFromRecord(t, tt.record)).Build().ToBytesWithCompression()
+			response, err := NewDNSResponse(wire)
+			if err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+
+			decoded, err := DecodeRData(response.Answers[0], wire)
+			if err != nil {
+				t.Fatalf("DecodeRData failed: %v", err)
+			}
+
+			if decoded.Type() != tt.record.Type() {
+				t.Errorf("got type %d, want %d", decoded.Type(), tt.record.Type())
+			}
+			if decoded.Name() != tt.record.Name() {
+				t.Errorf("got name %q, want %q", decoded.Name(), tt.record.Name())
+			}
+			if decoded.TTL() != tt.record.TTL() {
+				t.Errorf("got TTL %d, want %d", decoded.TTL(), tt.record.TTL())
+			}
+			if !bytes.Equal(decoded.Data(), tt.record.Data()) {
+				t.Errorf("got RDATA %v, want %v", decoded.Data(), tt.record.Data())
+			}
+		})
+	}
+}
+
+func TestDecodeRDataErrors(t *testing.T) {
+	name := []byte{0x04, 'h', 'o', 's', 't', 0x00}
+
+	tests := []struct {
+		name        string
+		type_       types.DNSType
+		data        []byte
+		unsupported bool
+	}{
+		{name: "short A", type_: types.TYPE_A, data: []byte{1, 2, 3}},
+		{name: "CNAME with trailing bytes", type_: types.TYPE_CNAME, data: []byte{0x00, 0x01}},
+		{name: "NS pointer outside message", type_: types.TYPE_NS, data: []byte{0xC0, 0x40}},
+		{name: "empty TXT", type_: types.TYPE_TXT, data: []byte{}},
+		{name: "truncated TXT string", type_: types.TYPE_TXT, data: []byte{0x05, 'a', 'b'}},
+		{name: "SOA missing timers", type_: types.TYPE_SOA, data: []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}},
+		{name: "SOA missing responsible", type_: types.TYPE_SOA, data: []byte{0x00}},
+		{name: "SRV without target", type_: types.TYPE_SRV, data: []byte{0x00, 0x0A, 0x00, 0x3C, 0x13, 0xC4}},
+		{name: "CAA with empty tag", type_: types.TYPE_CAA, data: []byte{0x00, 0x00, 'x'}},
+		{name: "HTTPS with unordered params", type_: types.TYPE_HTTPS, data: []byte{0x00, 0x01, 0x00, 0x00, 0x03, 0x00, 0x02, 0x01, 0xBB, 0x00, 0x01, 0x00, 0x03, 0x02, 'h', '2'}},
+		{name: "DNSKEY of another protocol", type_: types.TYPE_DNSKEY, data: []byte{0x01, 0x00, 0x02, 0x0D, 0x01}},
+		{name: "DS with short SHA-256 digest", type_: types.TYPE_DS, data: []byte{0x30, 0x39, 0x0D, 0x02, 0x01}},
+		{name: "short RRSIG", type_: types.TYPE_RRSIG, data: []byte{0x00, 0x01, 0x0D, 0x02}},
+		{name: "NSEC with empty bitmap window", type_: types.TYPE_NSEC, data: append(append([]byte(nil), name...), 0x00, 0x00)},
+		{name: "unknown type", type_: types.DNSType(65280), data: []byte{0xAB}, unsupported: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer, err := NewDNSAnswer(name, types.CLASS_IN, tt.type_, 300, tt.data)
+			if err != nil {
+				t.Fatalf("failed to create answer: %v", err)
+			}
+
+			_, err = DecodeRData(*answer, nil)
+			if err == nil {
+				t.Fatal("expected error but got none")
+			}
+			if errors.Is(err, ErrUnsupportedRData) != tt.unsupported {
+				t.Errorf("errors.Is(err, ErrUnsupportedRData) = %v, want %v (err: %v)",
+					!tt.unsupported, tt.unsupported, err)
+			}
+		})
+	}
+}
+
+func TestDecodeRData_ChunkedTXT(t *testing.T) {
+	text := strings.Repeat("v=spf1 ip4:192.0.2.0/24 ", 30)[:700]
+	record := records.NewTXTRecordFromString("example.com", text, 300)
+
+	wire := NewResponse(0x1234).AddAnswer(answerFromRecord(t, record)).Build().ToBytesWithCompression()
+	response, err := NewDNSResponse(wire)
+	if err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	texts, err := response.Answers[0].ParseTXTRecord()
+	if err != nil {
+		t.Fatalf("ParseTXTRecord failed: %v", err)
+	}
+	if strings.Join(texts, "") != text || len(texts) != 3 {
+		t.Errorf("ParseTXTRecord() = %d strings, expected the text in 3", len(texts))
+	}
+
+	decoded, err := DecodeRData(response.Answers[0], wire)
+	if err != nil {
+		t.Fatalf("DecodeRData failed: %v", err)
+	}
+
+	txt, ok := decoded.(*records.TXTRecord)
+	if !ok {
+		t.Fatalf("expected *records.TXTRecord, got %T", decoded)
+	}
+	if len(txt.Texts()) != 3 {
+		t.Errorf("decoded %d character strings, expected 3", len(txt.Texts()))
+	}
+	if txt.Text() != text {
+		t.Errorf("Text() = %q, expected %q", txt.Text(), text)
+	}
+}
@@ -3,6 +3,7 @@ package message
 import (
 	"fmt"
 
+	"github.com/vadim-su/dnska/pkg/dns/edns"
 	"github.com/vadim-su/dnska/pkg/dns/types"
 	"github.com/vadim-su/dnska/pkg/dns/utils"
 )
@@ -14,6 +15,48 @@ type DNSRequest struct {
 	Answers           []DNSAnswer
 	AuthorityRecords  []DNSAnswer
 	AdditionalRecords []DNSAnswer
+
+	// ClientSubnet is the EDNS Client Subnet option (RFC 7871) of the OPT
+	// record, if any
+	ClientSubnet *edns.ClientSubnet
+
+	// Cookie is the COOKIE option (RFC 7873) of the OPT record, if any
+	Cookie *edns.Cookie
+
+	wire []byte // Message the request was parsed from, if any
+
+	pool   *BufferPool // Pool buffer goes back to on Release, if any
+	buffer *[]byte     // Pooled buffer holding wire
+}
+
+// Default limits applied by NewDNSRequest.
+const (
+	DefaultMaxQuestions    = 256
+	DefaultMaxTotalRecords = 10000
+	DefaultMaxMessageSize  = 65535
+)
+
+// ParseOptions holds the limits enforced while parsing a DNS request.
+// A zero limit disables the corresponding check.
+type ParseOptions struct {
+	MaxQuestions    int // Maximum QDCOUNT
+	MaxTotalRecords int // Maximum sum of the four section counts
+	MaxMessageSize  int // Maximum message length in bytes
+
+	// Strict rejects trailing bytes after the last record and enables the
+	// checks below.
+	Strict         bool
+	SingleQuestion bool // Reject QDCOUNT greater than one
+	HostnameLabels bool // Allow only letters, digits, hyphens and underscores in question names
+}
+
+// DefaultParseOptions returns the options used by NewDNSRequest.
+func DefaultParseOptions() ParseOptions {
+	return ParseOptions{
+		MaxQuestions:    DefaultMaxQuestions,
+		MaxTotalRecords: DefaultMaxTotalRecords,
+		MaxMessageSize:  DefaultMaxMessageSize,
+	}
 }
 
 // NewDNSRequest creates a new DNS request from raw byte data with comprehensive
@@ -27,6 +70,21 @@ type DNSRequest struct {
 //
 //	Parsed DNSRequest struct and any parsing error.
 func NewDNSRequest(data []byte) (*DNSRequest, error) {
+	return NewDNSRequestWithOptions(data, DefaultParseOptions())
+}
+
+// NewDNSRequestWithOptions creates a new DNS request from raw byte data,
+// enforcing the limits in options.
+//
+// Args:
+//
+//	data: Raw DNS packet bytes to parse.
+//	options: Limits and strictness checks to apply.
+//
+// Returns:
+//
+//	Parsed DNSRequest struct and any parsing error.
+func NewDNSRequestWithOptions(data []byte, options ParseOptions) (*DNSRequest, error) {
 	// Validate minimum required length for DNS header (12 bytes)
 	if len(data) == 0 {
 		return nil, fmt.Errorf("invalid DNS request: empty data provided")
@@ -39,23 +97,45 @@ func NewDNSRequest(data []byte) (*DNSRequest, error) {
 		)
 	}
 
+	if options.MaxMessageSize > 0 && len(data) > options.MaxMessageSize {
+		return nil, fmt.Errorf(
+			"invalid DNS request: message too large (%d bytes), maximum allowed: %d",
+			len(data),
+			options.MaxMessageSize,
+		)
+	}
+
 	// Parse DNS header
 	header, headerParseError := parseHeaderFromBytes(data[:12])
 	if headerParseError != nil {
 		return nil, fmt.Errorf("failed to parse DNS header: %w", headerParseError)
 	}
 
+	if options.MaxQuestions > 0 && int(header.QuestionCount) > options.MaxQuestions {
+		return nil, fmt.Errorf(
+			"invalid DNS request: too many questions (%d), maximum allowed: %d",
+			header.QuestionCount,
+			options.MaxQuestions,
+		)
+	}
+
+	if options.Strict && options.SingleQuestion && header.QuestionCount > 1 {
+		return nil, fmt.Errorf(
+			"invalid DNS request: multiple questions (%d) not allowed in strict mode",
+			header.QuestionCount,
+		)
+	}
+
 	// Calculate total expected records for validation
-	totalExpectedRecords := header.QuestionCount + header.AnswerRecordCount +
-		header.AuthorityRecordCount + header.AdditionalRecordCount
+	totalExpectedRecords := int(header.QuestionCount) + int(header.AnswerRecordCount) +
+		int(header.AuthorityRecordCount) + int(header.AdditionalRecordCount)
 
 	// Validate reasonable limits to prevent memory exhaustion
-	const maxReasonableRecords = 10000
-	if totalExpectedRecords > maxReasonableRecords {
+	if options.MaxTotalRecords > 0 && totalExpectedRecords > options.MaxTotalRecords {
 		return nil, fmt.Errorf(
 			"invalid DNS request: unreasonable number of total records (%d), maximum allowed: %d",
 			totalExpectedRecords,
-			maxReasonableRecords,
+			options.MaxTotalRecords,
 		)
 	}
 
@@ -103,7 +183,7 @@ func NewDNSRequest(data []byte) (*DNSRequest, error) {
 	currentOffset += authoritySize
 
 	// Parse additional records section
-	additionalRecords, _, additionalError := parseAnswersSection(
+	additionalRecords, additionalSize, additionalError := parseAnswersSection(
 		remainingData,
 		header.AdditionalRecordCount,
 		originalMessage,
@@ -112,15 +192,74 @@ func NewDNSRequest(data []byte) (*DNSRequest, error) {
 		return nil, fmt.Errorf("failed to parse additional records section: %w", additionalError)
 	}
 
+	currentOffset += additionalSize
+
+	clientSubnet, err := clientSubnetOf(additionalRecords)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DNS request: %w", err)
+	}
+	cookie, err := cookieOf(additionalRecords)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DNS request: %w", err)
+	}
+
+	if options.Strict {
+		if trailing := len(data) - int(currentOffset); trailing > 0 {
+			return nil, fmt.Errorf(
+				"invalid DNS request: %d bytes of trailing data after the last record",
+				trailing,
+			)
+		}
+
+		if options.HostnameLabels {
+			for _, question := range questions {
+				if err := validateHostnameLabels(question.Name); err != nil {
+					return nil, fmt.Errorf("invalid DNS request: %w", err)
+				}
+			}
+		}
+	}
+
 	return &DNSRequest{
 		Header:            header,
 		Questions:         questions,
 		Answers:           answers,
 		AuthorityRecords:  authorityRecords,
 		AdditionalRecords: additionalRecords,
+		ClientSubnet:      clientSubnet,
+		Cookie:            cookie,
+		wire:              data,
 	}, nil
 }
 
+// ParseHeader parses the header leading the message in data, leaving the
+// rest of the message unparsed.
+func ParseHeader(data []byte) (DNSHeader, error) {
+	if len(data) < 12 {
+		return DNSHeader{}, fmt.Errorf("message too short for a header: %d bytes", len(data))
+	}
+	return parseHeaderFromBytes(data[:12])
+}
+
+// NewDNSRequestPartial parses as much of a request as is needed to answer
+// it with an error when NewDNSRequest rejects it: the header. It succeeds
+// whenever data holds at least the 12 bytes of a header.
+//
+// Args:
+//
+//	data: Raw DNS packet bytes to parse.
+//
+// Returns:
+//
+//	Parsed header of the request and any parsing error.
+func NewDNSRequestPartial(data []byte) (*DNSHeader, error) {
+	header, err := ParseHeader(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DNS request: %w", err)
+	}
+	return &header, nil
+}
+
 // parseHeaderFromBytes parses DNS header from exactly 12 bytes of data.
 func parseHeaderFromBytes(headerData []byte) (DNSHeader, error) {
 	if len(headerData) != 12 {
@@ -227,6 +366,22 @@ func parseAnswersSection(
 	return answers, size, nil
 }
 
+// validateHostnameLabels reports the first label byte outside the hostname
+// character set: letters, digits, hyphens and underscores.
+func validateHostnameLabels(name utils.DomainName) error {
+	for _, label := range name.Labels {
+		for _, char := range label.Content {
+			switch {
+			case char >= 'a' && char <= 'z', char >= 'A' && char <= 'Z':
+			case char >= '0' && char <= '9', char == '-', char == '_':
+			default:
+				return fmt.Errorf("label %q contains disallowed character 0x%02x", label.Content, char)
+			}
+		}
+	}
+	return nil
+}
+
 // advanceDataPointer safely advances the data slice by the specified number of bytes.
 func advanceDataPointer(data []byte, bytesToAdvance uint16) []byte {
 	if uint16(len(data)) < bytesToAdvance {
@@ -260,35 +415,16 @@ func (request *DNSRequest) ToBytes() []byte {
 
 // ToBytesWithCompression converts the DNSRequest to bytes using DNS name compression.
 func (request *DNSRequest) ToBytesWithCompression() []byte {
-	compressionMap := utils.NewCompressionMap()
-	result := request.Header.ToBytes()
-	currentOffset := uint16(12) // Header is always 12 bytes
-
-	for _, question := range request.Questions {
-		questionBytes := question.ToBytesWithCompression(compressionMap, currentOffset)
-		result = append(result, questionBytes...)
-		currentOffset += uint16(len(questionBytes))
-	}
-
-	for _, answer := range request.Answers {
-		answerBytes := answer.ToBytesWithCompression(compressionMap, currentOffset)
-		result = append(result, answerBytes...)
-		currentOffset += uint16(len(answerBytes))
-	}
-
-	for _, authorityRecord := range request.AuthorityRecords {
-		authorityBytes := authorityRecord.ToBytesWithCompression(compressionMap, currentOffset)
-		result = append(result, authorityBytes...)
-		currentOffset += uint16(len(authorityBytes))
-	}
-
-	for _, additionalRecord := range request.AdditionalRecords {
-		additionalBytes := additionalRecord.ToBytesWithCompression(compressionMap, currentOffset)
-		result = append(result, additionalBytes...)
-		currentOffset += uint16(len(additionalBytes))
-	}
-
-	return result
+	writer := NewMessageWriter()
+	defer writer.Release()
+	writer.WriteMessage(
+		request.Header,
+		request.Questions,
+		request.Answers,
+		request.AuthorityRecords,
+		request.AdditionalRecords,
+	)
+	return writer.Bytes()
 }
 
 // String returns a human-readable representation of the DNS request.
@@ -0,0 +1,179 @@
+package message
+
+import (
+	"strings"
+	"testing"
+)
+
+// buildRequest encodes a query header with the given counts followed by the
+// questions and any extra bytes.
+func buildRequest(counts [4]uint16, questions [][]byte, extra []byte) []byte {
+	data := []byte{0x12, 0x34, 0x01, 0x00}
+	for _, count := range counts {
+		data = append(data, byte(count>>8), byte(count))
+	}
+
+	for _, question := range questions {
+		data = append(data, question...)
+		data = append(data, 0x00, 0x01, 0x00, 0x01)
+	}
+
+	return append(data, extra...)
+}
+
+func encodeQuestionName(labels ...string) []byte {
+	var name []byte
+	for _, label := range labels {
+		name = append(name, byte(len(label)))
+		name = append(name, label...)
+	}
+	return append(name, 0x00)
+}
+
+func TestNewDNSRequestWithOptionsLimits(t *testing.T) {
+	exampleCom := encodeQuestionName("example", "com")
+	underscored := encodeQuestionName("_sip", "_udp", "example", "com")
+
+	tests := []struct {
+		name        string
+		data        []byte
+		options     ParseOptions
+		expectedErr string
+	}{
+		{
+			name:    "defaults accept a single question",
+			data:    buildRequest([4]uint16{1, 0, 0, 0}, [][]byte{exampleCom}, nil),
+			options: DefaultParseOptions(),
+		},
+		{
+			name:        "max questions",
+			data:        buildRequest([4]uint16{3, 0, 0, 0}, [][]byte{exampleCom, exampleCom, exampleCom}, nil),
+			options:     ParseOptions{MaxQuestions: 2},
+			expectedErr: "too many questions (3), maximum allowed: 2",
+		},
+		{
+			name:        "max total records",
+			data:        buildRequest([4]uint16{1, 2, 2, 2}, [][]byte{exampleCom}, nil),
+			options:     ParseOptions{MaxTotalRecords: 5},
+			expectedErr: "unreasonable number of total records (7), maximum allowed: 5",
+		},
+		{
+			name:        "max message size",
+			data:        buildRequest([4]uint16{1, 0, 0, 0}, [][]byte{exampleCom}, nil),
+			options:     ParseOptions{MaxMessageSize: 20},
+			expectedErr: "message too large (29 bytes), maximum allowed: 20",
+		},
+		{
+			name:    "trailing data accepted without strict mode",
+			data:    buildRequest([4]uint16{1, 0, 0, 0}, [][]byte{exampleCom}, []byte{0xDE, 0xAD}),
+			options: ParseOptions{},
+		},
+		{
+			name:        "trailing data rejected in strict mode",
+			data:        buildRequest([4]uint16{1, 0, 0, 0}, [][]byte{exampleCom}, []byte{0xDE, 0xAD}),
+			options:     ParseOptions{Strict: true},
+			expectedErr: "2 bytes of trailing data after the last record",
+		},
+		{
+			name:    "multiple questions accepted without single question",
+			data:    buildRequest([4]uint16{2, 0, 0, 0}, [][]byte{exampleCom, exampleCom}, nil),
+			options: ParseOptions{Strict: true},
+		},
+		{
+			name:        "multiple questions rejected with single question",
+			data:        buildRequest([4]uint16{2, 0, 0, 0}, [][]byte{exampleCom, exampleCom}, nil),
+			options:     ParseOptions{Strict: true, SingleQuestion: true},
+			expectedErr: "multiple questions (2) not allowed",
+		},
+		{
+			name:    "single question ignored outside strict mode",
+			data:    buildRequest([4]uint16{2, 0, 0, 0}, [][]byte{exampleCom, exampleCom}, nil),
+			options: ParseOptions{SingleQuestion: true},
+		},
+		{
+			name:    "hostname labels allow underscores",
+			data:    buildRequest([4]uint16{1, 0, 0, 0}, [][]byte{underscored}, nil),
+			options: ParseOptions{Strict: true, HostnameLabels: true},
+		},
+		{
+			name:        "hostname labels reject other bytes",
+			data:        buildRequest([4]uint16{1, 0, 0, 0}, [][]byte{encodeQuestionName("ex ample", "com")}, nil),
+			options:     ParseOptions{Strict: true, HostnameLabels: true},
+			expectedErr: `label "ex ample" contains disallowed character 0x20`,
+		},
+		{
+			name:    "hostname labels ignored without flag",
+			data:    buildRequest([4]uint16{1, 0, 0, 0}, [][]byte{encodeQuestionName("ex ample", "com")}, nil),
+			options: ParseOptions{Strict: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := NewDNSRequestWithOptions(tt.data, tt.options)
+
+			if tt.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if int(request.Header.QuestionCount) != len(request.Questions) {
+					t.Errorf("parsed %d questions, header says %d", len(request.Questions), request.Header.QuestionCount)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("expected error containing %q, got none", tt.expectedErr)
+			}
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("error %q does not mention %q", err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestNewDNSRequestUsesDefaultLimits(t *testing.T) {
+	data := buildRequest([4]uint16{DefaultMaxQuestions + 1, 0, 0, 0}, nil, nil)
+
+	_, err := NewDNSRequest(data)
+	if err == nil || !strings.Contains(err.Error(), "too many questions") {
+		t.Errorf("expected default question limit error, got %v", err)
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	data := buildRequest([4]uint16{1, 0, 0, 1}, [][]byte{encodeQuestionName("example", "com")}, nil)
+
+	header, err := ParseHeader(data)
+	if err != nil {
+		t.Fatalf("ParseHeader() returned error: %v", err)
+	}
+	if header.ID != 0x1234 || header.QuestionCount != 1 || header.AdditionalRecordCount != 1 || !header.Flags.IsRecursionDesired() {
+		t.Errorf("ParseHeader() = %+v", header)
+	}
+
+	if _, err := ParseHeader(data[:11]); err == nil {
+		t.Error("expected error for a truncated header")
+	}
+}
+
+func TestNewDNSRequestPartial(t *testing.T) {
+	data := buildRequest([4]uint16{1, 0, 0, 1}, [][]byte{encodeQuestionName("example", "com")}, nil)
+
+	// The question is cut short, so only the header can be parsed
+	truncated := data[:15]
+	if _, err := NewDNSRequest(truncated); err == nil {
+		t.Fatal("expected NewDNSRequest to reject a truncated question")
+	}
+	header, err := NewDNSRequestPartial(truncated)
+	if err != nil {
+		t.Fatalf("NewDNSRequestPartial() returned error: %v", err)
+	}
+	if header.ID != 0x1234 || header.QuestionCount != 1 {
+		t.Errorf("NewDNSRequestPartial() = %+v", header)
+	}
+
+	if _, err := NewDNSRequestPartial(data[:11]); err == nil {
+		t.Error("expected error for a truncated header")
+	}
+}
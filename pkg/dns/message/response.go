@@ -9,9 +9,13 @@ import (
 
 // DNSResponse represents a full DNS response message.
 type DNSResponse struct {
-	Header    DNSHeader
-	Questions []DNSQuestion
-	Answers   []DNSAnswer
+	Header            DNSHeader
+	Questions         []DNSQuestion
+	Answers           []DNSAnswer
+	AuthorityRecords  []DNSAnswer
+	AdditionalRecords []DNSAnswer
+
+	wire []byte // Message the response was parsed from, if any
 }
 
 // Create a new DNS response from raw byte data
@@ -48,64 +52,104 @@ func NewDNSResponse(data []byte) (*DNSResponse, error) {
 
 	data = data[questionsDataSize:] // Remove questions data
 
-	answers, _, err := NewDNSAnswers(data, header.AnswerRecordCount, originalMessage)
+	answers, answersDataSize, err := NewDNSAnswers(data, header.AnswerRecordCount, originalMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	data = data[answersDataSize:] // Remove answers data
+
+	authorityRecords, authorityDataSize, err := NewDNSAnswers(data, header.AuthorityRecordCount, originalMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	data = data[authorityDataSize:] // Remove authority data
+
+	additionalRecords, _, err := NewDNSAnswers(data, header.AdditionalRecordCount, originalMessage)
 	if err != nil {
 		return nil, err
 	}
 
 	return &DNSResponse{
-		*header,
-		questions,
-		answers,
+		Header:            *header,
+		Questions:         questions,
+		Answers:           answers,
+		AuthorityRecords:  authorityRecords,
+		AdditionalRecords: additionalRecords,
+		wire:              originalMessage,
 	}, nil
 }
 
-// Generate a DNS response based on the request flags and provided questions and answers
+// Generate a DNS response based on the request flags and provided questions and answers.
+// It is a thin wrapper around ResponseBuilder kept for compatibility.
+//
+// Deprecated: use GenerateDNSResponseFor, which takes the ID from the request
 func GenerateDNSResponse(id uint16, reqFlags types.DNSFlag, questions []DNSQuestion, answers []DNSAnswer) *DNSResponse {
-	flags := PrepareResponseFlags(reqFlags)
-	return &DNSResponse{
-		DNSHeader{
-			id,
-			flags,
-			uint16(len(questions)),
-			uint16(len(answers)),
-			0,
-			0,
-		},
-		questions,
-		answers,
-	}
+	return NewResponse(id).
+		WithFlags(PrepareResponseFlags(reqFlags)).
+		AddQuestion(questions...).
+		AddAnswer(answers...).
+		Build()
+}
+
+// GenerateDNSResponseFor generates the response to request with the given
+// answers, carrying the ID, flags and questions of the request
+func GenerateDNSResponseFor(request *DNSRequest, answers []DNSAnswer) *DNSResponse {
+	return NewResponseFor(request).
+		AddAnswer(answers...).
+		Build()
 }
 
 // Generate a DNS query with the given ID and questions
 func GenerateDNSQuery(id uint16, questions []DNSQuestion) *DNSResponse {
 	// Create proper query flags: standard query with recursion desired
-	flags := types.FLAG_QR_QUERY | types.FLAG_OPCODE_STANDARD | types.FLAG_RD_RECURSION_DESIRED
+	flags := types.NewFlagBuilder(0).
+		SetQR(false).
+		SetOpcode(uint8(types.OPCODE_QUERY)).
+		SetRD(true).
+		Build()
 	return &DNSResponse{
-		DNSHeader{
-			id,
-			flags,
-			uint16(len(questions)),
-			0, // No answers in a query
-			0,
-			0,
+		Header: DNSHeader{
+			ID:            id,
+			Flags:         flags,
+			QuestionCount: uint16(len(questions)),
 		},
-		questions,
-		nil,
+		Questions: questions,
+	}
+}
+
+// GenerateNotify builds a NOTIFY message (RFC 1996) with the given ID telling
+// a secondary that zone changed. The message asks for the SOA of zone and
+// carries the new SOA records, if any, in its answer section
+func GenerateNotify(id uint16, zone utils.DomainName, soa []DNSAnswer) *DNSResponse {
+	flags := types.NewFlagBuilder(0).
+		SetQR(false).
+		SetOpcode(uint8(types.OPCODE_NOTIFY)).
+		SetAA(true).
+		Build()
+	return &DNSResponse{
+		Header: DNSHeader{
+			ID:                id,
+			Flags:             flags,
+			QuestionCount:     1,
+			AnswerRecordCount: uint16(len(soa)),
+		},
+		Questions: []DNSQuestion{NewDNSQuestion(zone, types.TYPE_SOA, types.CLASS_IN)},
+		Answers:   soa,
 	}
 }
 
 // PrepareResponseFlags prepares the response flags based on the request flags
 func PrepareResponseFlags(reqFlags types.DNSFlag) types.DNSFlag {
-	respFlags := reqFlags | types.FLAG_QR_RESPONSE
+	builder := types.NewFlagBuilder(reqFlags).SetQR(true).SetRcode(uint8(types.RCODE_NO_ERROR))
 
-	if ((reqFlags >> types.BIT_OPCODE_START) & 0xF) == 0 {
-		respFlags = respFlags | types.FLAG_RCODE_NO_ERROR
-	} else {
-		respFlags = respFlags | types.FLAG_RCODE_NOT_IMPLEMENTED
+	// Only standard queries are implemented
+	if reqFlags.Opcode() != types.OPCODE_QUERY {
+		builder.SetRcode(uint8(types.RCODE_NOT_IMPLEMENTED))
 	}
 
-	return respFlags
+	return builder.Build()
 }
 
 // Convert DNSResponse to byte array
@@ -120,31 +164,82 @@ func (d *DNSResponse) ToBytes() []byte {
 		resp = append(resp, answer.ToBytes()...)
 	}
 
+	for _, authorityRecord := range d.AuthorityRecords {
+		resp = append(resp, authorityRecord.ToBytes()...)
+	}
+
+	for _, additionalRecord := range d.AdditionalRecords {
+		resp = append(resp, additionalRecord.ToBytes()...)
+	}
+
 	return resp
 }
 
 // Convert DNSResponse to byte array with name compression
 func (d *DNSResponse) ToBytesWithCompression() []byte {
-	compressionMap := utils.NewCompressionMap()
-	result := make([]byte, 0, 512)
+	return d.AppendBytesWithCompression(make([]byte, 0, d.estimatedSize()))
+}
 
-	headerBytes := d.Header.ToBytes()
-	result = append(result, headerBytes...)
-	currentOffset := uint16(12)
+// AppendBytesWithCompression appends the response with name compression to
+// buffer, e.g. one reused between messages
+func (d *DNSResponse) AppendBytesWithCompression(buffer []byte) []byte {
+	writer := NewMessageWriterTo(buffer)
+	defer writer.Release()
+	writer.WriteMessage(d.Header, d.Questions, d.Answers, d.AuthorityRecords, d.AdditionalRecords)
+	return writer.Bytes()
+}
 
-	// Add questions with compression
+// estimatedSize returns the size of the response without name compression,
+// which bounds the compressed size
+func (d *DNSResponse) estimatedSize() int {
+	size := 12
 	for _, question := range d.Questions {
-		questionBytes := question.ToBytesWithCompression(compressionMap, currentOffset)
-		result = append(result, questionBytes...)
-		currentOffset += uint16(len(questionBytes))
+		size += question.Name.EncodedLength() + 4
+	}
+	for _, section := range [][]DNSAnswer{d.Answers, d.AuthorityRecords, d.AdditionalRecords} {
+		for _, record := range section {
+			size += record.name.EncodedLength() + 10 + len(record.data)
+		}
 	}
+	return size
+}
 
-	// Add answers with compression
-	for _, answer := range d.Answers {
-		answerBytes := answer.ToBytesWithCompression(compressionMap, currentOffset)
-		result = append(result, answerBytes...)
-		currentOffset += uint16(len(answerBytes))
+// TruncateTo returns a response whose serialized, compressed form fits in
+// maxBytes. Records are removed from the tail of the message (additional,
+// then authority, then answer section) until it fits and the TC bit is set
+// so the client can retry over TCP. A response that already fits is returned
+// unchanged
+func (d *DNSResponse) TruncateTo(maxBytes int) *DNSResponse {
+	if len(d.ToBytesWithCompression()) <= maxBytes {
+		return d
 	}
 
-	return result
+	truncated := &DNSResponse{
+		Header:            d.Header,
+		Questions:         d.Questions,
+		Answers:           d.Answers,
+		AuthorityRecords:  d.AuthorityRecords,
+		AdditionalRecords: d.AdditionalRecords,
+	}
+	truncated.Header.Flags = types.NewFlagBuilder(truncated.Header.Flags).SetTC(true).Build()
+
+	for {
+		switch {
+		case len(truncated.AdditionalRecords) > 0:
+			truncated.AdditionalRecords = truncated.AdditionalRecords[:len(truncated.AdditionalRecords)-1]
+		case len(truncated.AuthorityRecords) > 0:
+			truncated.AuthorityRecords = truncated.AuthorityRecords[:len(truncated.AuthorityRecords)-1]
+		case len(truncated.Answers) > 0:
+			truncated.Answers = truncated.Answers[:len(truncated.Answers)-1]
+		}
+
+		truncated.Header.AnswerRecordCount = uint16(len(truncated.Answers))
+		truncated.Header.AuthorityRecordCount = uint16(len(truncated.AuthorityRecords))
+		truncated.Header.AdditionalRecordCount = uint16(len(truncated.AdditionalRecords))
+
+		recordsLeft := len(truncated.Answers) + len(truncated.AuthorityRecords) + len(truncated.AdditionalRecords)
+		if recordsLeft == 0 || len(truncated.ToBytesWithCompression()) <= maxBytes {
+			return truncated
+		}
+	}
 }
@@ -2,9 +2,13 @@ package message
 
 import (
 	"bytes"
+	"fmt"
+	"net"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/vadim-su/dnska/pkg/dns/records"
 	"github.com/vadim-su/dnska/pkg/dns/types"
 	"github.com/vadim-su/dnska/pkg/dns/utils"
 )
@@ -443,7 +447,7 @@ func TestGenerateDNSResponse(t *testing.T) {
 				createTestDNSQuestion("example.com.", TYPE_A, CLASS_IN),
 			},
 			answers: []DNSAnswer{
-				createTestDNSAnswer("example.com.", TYPE_A, CLASS_IN, 300, []byte{192, 0, 2, 1}),
+				mustBuildAnswer(t, records.NewBuilder().Name("example.com.").Type(TYPE_A).TTL(300).IPv4(net.IPv4(192, 0, 2, 1))),
 			},
 			expected: &DNSResponse{
 				Header: DNSHeader{
@@ -458,7 +462,7 @@ func TestGenerateDNSResponse(t *testing.T) {
 					createTestDNSQuestion("example.com.", TYPE_A, CLASS_IN),
 				},
 				Answers: []DNSAnswer{
-					createTestDNSAnswer("example.com.", TYPE_A, CLASS_IN, 300, []byte{192, 0, 2, 1}),
+					mustBuildAnswer(t, records.NewBuilder().Name("example.com.").Type(TYPE_A).TTL(300).IPv4(net.IPv4(192, 0, 2, 1))),
 				},
 			},
 			description: "Should generate valid DNS response",
@@ -492,8 +496,8 @@ func TestGenerateDNSResponse(t *testing.T) {
 				createTestDNSQuestion("test2.com.", TYPE_A, CLASS_IN),
 			},
 			answers: []DNSAnswer{
-				createTestDNSAnswer("test1.com.", TYPE_A, CLASS_IN, 300, []byte{10, 0, 0, 1}),
-				createTestDNSAnswer("test2.com.", TYPE_A, CLASS_IN, 600, []byte{10, 0, 0, 2}),
+				mustBuildAnswer(t, records.NewBuilder().Name("test1.com.").Type(TYPE_A).TTL(300).IPv4(net.IPv4(10, 0, 0, 1))),
+				mustBuildAnswer(t, records.NewBuilder().Name("test2.com.").Type(TYPE_A).TTL(600).IPv4(net.IPv4(10, 0, 0, 2))),
 			},
 			expected: &DNSResponse{
 				Header: DNSHeader{
@@ -509,8 +513,8 @@ func TestGenerateDNSResponse(t *testing.T) {
 					createTestDNSQuestion("test2.com.", TYPE_A, CLASS_IN),
 				},
 				Answers: []DNSAnswer{
-					createTestDNSAnswer("test1.com.", TYPE_A, CLASS_IN, 300, []byte{10, 0, 0, 1}),
-					createTestDNSAnswer("test2.com.", TYPE_A, CLASS_IN, 600, []byte{10, 0, 0, 2}),
+					mustBuildAnswer(t, records.NewBuilder().Name("test1.com.").Type(TYPE_A).TTL(300).IPv4(net.IPv4(10, 0, 0, 1))),
+					mustBuildAnswer(t, records.NewBuilder().Name("test2.com.").Type(TYPE_A).TTL(600).IPv4(net.IPv4(10, 0, 0, 2))),
 				},
 			},
 			description: "Should generate response with multiple questions and answers",
@@ -667,6 +671,58 @@ func TestGenerateDNSQuery(t *testing.T) {
 	}
 }
 
+func TestGenerateNotify(t *testing.T) {
+	mustName := func(name string) utils.DomainName {
+		domainName, err := utils.NewDomainNameFromString(name)
+		if err != nil {
+			t.Fatalf("invalid name %q: %v", name, err)
+		}
+		return *domainName
+	}
+
+	soa := NewSOAAnswer(mustName("example.com"), SOARData{
+		MNAME:  mustName("ns1.example.com"),
+		RNAME:  mustName("hostmaster.example.com"),
+		Serial: 2024010102,
+	}, 3600)
+
+	notify := GenerateNotify(0x4242, mustName("example.com"), []DNSAnswer{*soa})
+
+	// The message survives the wire unchanged
+	parsed, err := NewDNSResponse(notify.ToBytes())
+	if err != nil {
+		t.Fatalf("failed to parse NOTIFY: %v", err)
+	}
+
+	if parsed.Header.ID != 0x4242 {
+		t.Errorf("ID = %#x, want 0x4242", parsed.Header.ID)
+	}
+	if opcode := parsed.Header.Flags.Opcode(); opcode != types.OPCODE_NOTIFY {
+		t.Errorf("opcode = %s, want NOTIFY", opcode)
+	}
+	if parsed.Header.Flags.IsResponse() || !parsed.Header.Flags.IsAuthoritative() {
+		t.Errorf("flags = %#x, want a query with AA set", uint16(parsed.Header.Flags))
+	}
+	if parsed.Header.Flags.IsRecursionDesired() {
+		t.Error("NOTIFY asks for recursion")
+	}
+
+	if len(parsed.Questions) != 1 {
+		t.Fatalf("got %d questions, want 1", len(parsed.Questions))
+	}
+	question := parsed.Questions[0]
+	if question.Name.String() != "example.com." || question.Qtype() != types.TYPE_SOA || question.Qclass() != types.CLASS_IN {
+		t.Errorf("question = %s %s %s, want example.com. SOA IN", question.Name.String(), question.Qtype(), question.Qclass())
+	}
+
+	if len(parsed.Answers) != 1 || parsed.Answers[0].Type() != types.TYPE_SOA {
+		t.Fatalf("answers = %v, want the SOA", parsed.Answers)
+	}
+	if !bytes.Equal(parsed.Answers[0].Data(), soa.Data()) {
+		t.Error("SOA RDATA changed on the wire")
+	}
+}
+
 func TestPrepareResponseFlags(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -722,20 +778,18 @@ func TestPrepareResponseFlags(t *testing.T) {
 			}
 
 			// Verify OPCODE is preserved
-			reqOpcode := (test.reqFlags >> BIT_OPCODE_START) & 0xF
-			respOpcode := (result >> BIT_OPCODE_START) & 0xF
-			if reqOpcode != respOpcode {
-				t.Errorf("OPCODE not preserved: expected %d, got %d", reqOpcode, respOpcode)
+			reqOpcode := test.reqFlags.Opcode()
+			if respOpcode := result.Opcode(); reqOpcode != respOpcode {
+				t.Errorf("OPCODE not preserved: expected %s, got %s", reqOpcode, respOpcode)
 			}
 
 			// Verify RCODE is set correctly
-			expectedRcode := uint16(0) // NO_ERROR
-			if reqOpcode != 0 {
-				expectedRcode = 4 // NOT_IMPLEMENTED
+			expectedRcode := types.RCODE_NO_ERROR
+			if reqOpcode != types.OPCODE_QUERY {
+				expectedRcode = types.RCODE_NOT_IMPLEMENTED
 			}
-			actualRcode := uint16(result & 0xF)
-			if actualRcode != expectedRcode {
-				t.Errorf("RCODE mismatch: expected %d, got %d", expectedRcode, actualRcode)
+			if actualRcode := result.Rcode(); actualRcode != expectedRcode {
+				t.Errorf("RCODE mismatch: expected %s, got %s", expectedRcode, actualRcode)
 			}
 		})
 	}
@@ -763,7 +817,7 @@ func TestDNSResponseToBytes(t *testing.T) {
 					createTestDNSQuestion("test.com.", TYPE_A, CLASS_IN),
 				},
 				Answers: []DNSAnswer{
-					createTestDNSAnswer("test.com.", TYPE_A, CLASS_IN, 300, []byte{192, 0, 2, 1}),
+					mustBuildAnswer(t, records.NewBuilder().Name("test.com.").Type(TYPE_A).TTL(300).IPv4(net.IPv4(192, 0, 2, 1))),
 				},
 			},
 			expected: []byte{
@@ -853,7 +907,7 @@ func TestDNSResponseToBytesWithCompression(t *testing.T) {
 					createTestDNSQuestion("example.com.", TYPE_A, CLASS_IN),
 				},
 				Answers: []DNSAnswer{
-					createTestDNSAnswer("example.com.", TYPE_A, CLASS_IN, 300, []byte{192, 0, 2, 1}),
+					mustBuildAnswer(t, records.NewBuilder().Name("example.com.").Type(TYPE_A).TTL(300).IPv4(net.IPv4(192, 0, 2, 1))),
 				},
 			},
 			description: "Should use compression for repeated domain names",
@@ -874,8 +928,8 @@ func TestDNSResponseToBytesWithCompression(t *testing.T) {
 					createTestDNSQuestion("www.example.com.", TYPE_A, CLASS_IN),
 				},
 				Answers: []DNSAnswer{
-					createTestDNSAnswer("test.example.com.", TYPE_A, CLASS_IN, 300, []byte{10, 0, 0, 1}),
-					createTestDNSAnswer("www.example.com.", TYPE_A, CLASS_IN, 600, []byte{10, 0, 0, 2}),
+					mustBuildAnswer(t, records.NewBuilder().Name("test.example.com.").Type(TYPE_A).TTL(300).IPv4(net.IPv4(10, 0, 0, 1))),
+					mustBuildAnswer(t, records.NewBuilder().Name("www.example.com.").Type(TYPE_A).TTL(600).IPv4(net.IPv4(10, 0, 0, 2))),
 				},
 			},
 			description: "Should handle compression with multiple names sharing suffixes",
@@ -953,7 +1007,7 @@ func TestDNSResponseRoundTrip(t *testing.T) {
 					createTestDNSQuestion("example.com.", TYPE_A, CLASS_IN),
 				},
 				Answers: []DNSAnswer{
-					createTestDNSAnswer("example.com.", TYPE_A, CLASS_IN, 300, []byte{192, 0, 2, 1}),
+					mustBuildAnswer(t, records.NewBuilder().Name("example.com.").Type(TYPE_A).TTL(300).IPv4(net.IPv4(192, 0, 2, 1))),
 				},
 			},
 			description: "Should survive round trip serialization and parsing",
@@ -1045,79 +1099,32 @@ func createTestDNSQuestion(domain string, recordType types.DNSType, class types.
 		}
 	}
 
-	return DNSQuestion{
-		Name: utils.DomainName{
-			Labels: labels,
-		},
-		Type:  types.DnsTypeClassToBytes(recordType),
-		Class: types.DnsTypeClassToBytes(class),
-	}
+	return NewDNSQuestion(utils.DomainName{Labels: labels}, recordType, class)
 }
 
-func createTestDNSAnswer(domain string, recordType types.DNSType, class types.DNSClass, ttl uint32, data []byte) DNSAnswer {
-	var labels []utils.Label
-	if domain != "." {
-		// Remove trailing dot if present
-		domain = domain[:len(domain)-1]
-		parts := []string{}
-		if domain != "" {
-			parts = []string{domain}
-			// Split by dots but handle edge cases
-			if len(domain) > 0 {
-				parts = []string{}
-				current := ""
-				for _, char := range domain {
-					if char == '.' {
-						if current != "" {
-							parts = append(parts, current)
-							current = ""
-						}
-					} else {
-						current += string(char)
-					}
-				}
-				if current != "" {
-					parts = append(parts, current)
-				}
-			}
-		}
-
-		for _, part := range parts {
-			if len(part) > 0 {
-				labels = append(labels, utils.Label{
-					Length:  uint8(len(part)),
-					Content: []byte(part),
-				})
-			}
-		}
-	}
+// mustBuildAnswer builds a record and converts it to an answer
+func mustBuildAnswer(t *testing.T, builder *records.Builder) DNSAnswer {
+	t.Helper()
 
-	ttlBytes := [4]byte{
-		byte(ttl >> 24),
-		byte(ttl >> 16),
-		byte(ttl >> 8),
-		byte(ttl),
+	record, err := builder.Build()
+	if err != nil {
+		t.Fatalf("failed to build record: %v", err)
 	}
-
-	return DNSAnswer{
-		name: utils.DomainName{
-			Labels: labels,
-		},
-		type_: types.DnsTypeClassToBytes(recordType),
-		class: types.DnsTypeClassToBytes(class),
-		ttl:   ttlBytes,
-		data:  data,
+	name, err := utils.NewDomainNameFromString(record.Name())
+	if err != nil {
+		t.Fatalf("invalid record name %q: %v", record.Name(), err)
 	}
+	return *NewDNSAnswerFromParts(*name, record.Type(), record.Class(), record.TTL(), record.Data())
 }
 
 func compareDNSQuestions(a, b DNSQuestion) bool {
-	return reflect.DeepEqual(a.Name.Labels, b.Name.Labels) &&
+	return a.Name.Equal(b.Name) &&
 		bytes.Equal(a.Type[:], b.Type[:]) &&
 		bytes.Equal(a.Class[:], b.Class[:])
 }
 
 func compareDNSAnswers(a, b DNSAnswer) bool {
-	return reflect.DeepEqual(a.name.Labels, b.name.Labels) &&
+	return a.name.Equal(b.name) &&
 		bytes.Equal(a.type_[:], b.type_[:]) &&
 		bytes.Equal(a.class[:], b.class[:]) &&
 		bytes.Equal(a.ttl[:], b.ttl[:]) &&
@@ -1177,7 +1184,7 @@ func TestDNSResponseEdgeCases(t *testing.T) {
 					createTestDNSQuestion("test.com.", TYPE_A, CLASS_IN),
 				},
 				Answers: []DNSAnswer{
-					createTestDNSAnswer("test.com.", TYPE_A, CLASS_IN, 0xFFFFFFFF, []byte{192, 0, 2, 1}),
+					mustBuildAnswer(t, records.NewBuilder().Name("test.com.").Type(TYPE_A).TTL(0xFFFFFFFF).IPv4(net.IPv4(192, 0, 2, 1))),
 				},
 			},
 			description: "Should handle maximum TTL values",
@@ -1297,7 +1304,7 @@ func TestDNSResponseComplexScenarios(t *testing.T) {
 					createTestDNSQuestion("example.com.", TYPE_A, CLASS_IN),
 				}
 				answers := []DNSAnswer{
-					createTestDNSAnswer("example.com.", TYPE_A, CLASS_IN, 300, []byte{192, 0, 2, 1}),
+					mustBuildAnswer(t, records.NewBuilder().Name("example.com.").Type(TYPE_A).TTL(300).IPv4(net.IPv4(192, 0, 2, 1))),
 				}
 				return GenerateDNSResponse(0x1234, FLAG_QR_QUERY|FLAG_OPCODE_STANDARD, questions, answers)
 			},
@@ -1310,7 +1317,7 @@ func TestDNSResponseComplexScenarios(t *testing.T) {
 					createTestDNSQuestion("example.com.", TYPE_A, CLASS_IN),
 				}
 				answers := []DNSAnswer{
-					createTestDNSAnswer("example.com.", TYPE_A, CLASS_IN, 300, []byte{192, 0, 2, 1}),
+					mustBuildAnswer(t, records.NewBuilder().Name("example.com.").Type(TYPE_A).TTL(300).IPv4(net.IPv4(192, 0, 2, 1))),
 				}
 				return GenerateDNSResponse(0x1234, FLAG_QR_QUERY|FLAG_OPCODE_STANDARD, questions, answers)
 			},
@@ -1323,7 +1330,7 @@ func TestDNSResponseComplexScenarios(t *testing.T) {
 					createTestDNSQuestion("example.com.", TYPE_A, CLASS_IN),
 				}
 				answers := []DNSAnswer{
-					createTestDNSAnswer("example.com.", TYPE_A, CLASS_IN, 86400, []byte{192, 0, 2, 1}), // One day TTL
+					mustBuildAnswer(t, records.NewBuilder().Name("example.com.").Type(TYPE_A).TTL(86400).IPv4(net.IPv4(192, 0, 2, 1))), // One day TTL
 				}
 				return GenerateDNSResponse(0x1234, FLAG_QR_QUERY|FLAG_OPCODE_STANDARD, questions, answers)
 			},
@@ -1378,6 +1385,24 @@ func FuzzNewDNSResponse(f *testing.F) {
 		{0x12, 0x34}, // Two bytes
 	}
 
+	// Over-length label and name in the question section
+	header := []byte{0x12, 0x34, 0x81, 0x80, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	longLabel := append(append([]byte{}, header...), 0x40)
+	longLabel = append(longLabel, bytes.Repeat([]byte{'a'}, 64)...)
+	longLabel = append(longLabel, 0x00, 0x00, 0x01, 0x00, 0x01)
+	longName := append([]byte{}, header...)
+	for range 5 {
+		longName = append(longName, 0x3F)
+		longName = append(longName, bytes.Repeat([]byte{'a'}, 63)...)
+	}
+	longName = append(longName, 0x00, 0x00, 0x01, 0x00, 0x01)
+	seedData = append(seedData, longLabel, longName)
+
+	// Question names made of compression pointers that loop
+	selfPointer := append(append([]byte{}, header...), 0xC0, 0x0C, 0x00, 0x01, 0x00, 0x01)
+	forwardPointer := append(append([]byte{}, header...), 0xC0, 0x12, 0x00, 0x01, 0x00, 0x01, 0xC0, 0x0C)
+	seedData = append(seedData, selfPointer, forwardPointer)
+
 	for _, seed := range seedData {
 		f.Add(seed)
 	}
@@ -1422,10 +1447,9 @@ func TestPrepareResponseFlagsEdgeCases(t *testing.T) {
 				if (result & FLAG_QR_RESPONSE) == 0 {
 					t.Error("QR bit should be set to response")
 				}
-				// When all bits are set, RCODE will be 15 (all 1s) OR'd with 4 = 15
-				expectedRcode := uint16(15) // Original RCODE bits (all 1s) OR'd with NOT_IMPLEMENTED
-				if (result & 0xF) != DNSFlag(expectedRcode) {
-					t.Errorf("Expected RCODE %d, got %d", expectedRcode, result&0xF)
+				// The request RCODE bits are cleared before NOT_IMPLEMENTED is set
+				if rcode := result.Rcode(); rcode != types.RCODE_NOT_IMPLEMENTED {
+					t.Errorf("Expected RCODE NOTIMP, got %s", rcode)
 				}
 			},
 		},
@@ -1433,12 +1457,11 @@ func TestPrepareResponseFlagsEdgeCases(t *testing.T) {
 			name:     "only opcode bits set",
 			reqFlags: FLAG_OPCODE_INVERSE,
 			checkFn: func(t *testing.T, result DNSFlag) {
-				opcode := (result >> BIT_OPCODE_START) & 0xF
-				if opcode != 1 {
-					t.Errorf("Expected opcode 1 (INVERSE), got %d", opcode)
+				if opcode := result.Opcode(); opcode != types.OPCODE_IQUERY {
+					t.Errorf("Expected opcode IQUERY, got %s", opcode)
 				}
-				if (result & 0xF) != 4 {
-					t.Errorf("Expected RCODE 4 (NOT_IMPLEMENTED), got %d", result&0xF)
+				if rcode := result.Rcode(); rcode != types.RCODE_NOT_IMPLEMENTED {
+					t.Errorf("Expected RCODE NOTIMP, got %s", rcode)
 				}
 			},
 		},
@@ -1446,12 +1469,11 @@ func TestPrepareResponseFlagsEdgeCases(t *testing.T) {
 			name:     "boundary opcode value",
 			reqFlags: DNSFlag(15 << BIT_OPCODE_START), // Maximum 4-bit opcode value
 			checkFn: func(t *testing.T, result DNSFlag) {
-				opcode := (result >> BIT_OPCODE_START) & 0xF
-				if opcode != 15 {
+				if opcode := result.Opcode(); opcode != 15 {
 					t.Errorf("Expected opcode 15, got %d", opcode)
 				}
-				if (result & 0xF) != 4 {
-					t.Errorf("Expected RCODE 4 (NOT_IMPLEMENTED), got %d", result&0xF)
+				if rcode := result.Rcode(); rcode != types.RCODE_NOT_IMPLEMENTED {
+					t.Errorf("Expected RCODE NOTIMP, got %s", rcode)
 				}
 			},
 		},
@@ -1464,3 +1486,234 @@ func TestPrepareResponseFlagsEdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestNewDNSResponseRejectsOverlongNames(t *testing.T) {
+	header := []byte{0x12, 0x34, 0x81, 0x80, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+	tests := []struct {
+		name  string
+		qname []byte
+	}{
+		{
+			name:  "label longer than 63 bytes",
+			qname: append(append([]byte{0x40}, bytes.Repeat([]byte{'a'}, 64)...), 0x00),
+		},
+		{
+			name: "name longer than 255 bytes",
+			qname: func() []byte {
+				var qname []byte
+				for range 5 {
+					qname = append(qname, 0x3F)
+					qname = append(qname, bytes.Repeat([]byte{'a'}, 63)...)
+				}
+				return append(qname, 0x00)
+			}(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := append(append([]byte{}, header...), tt.qname...)
+			data = append(data, 0x00, 0x01, 0x00, 0x01)
+
+			if _, err := NewDNSResponse(data); err == nil {
+				t.Error("expected error for over-length name, got none")
+			}
+		})
+	}
+}
+
+func TestDNSResponseTruncateTo(t *testing.T) {
+	question := createTestDNSQuestion("example.com.", TYPE_A, CLASS_IN)
+	answer := mustBuildAnswer(t, records.NewBuilder().Name("example.com.").Type(TYPE_A).TTL(300).IPv4(net.IPv4(192, 0, 2, 1)))
+	glue := mustBuildAnswer(t, records.NewBuilder().Name("ns.example.com.").Type(TYPE_A).TTL(300).IPv4(net.IPv4(192, 0, 2, 53)))
+
+	manyAnswers := NewResponse(1).AddQuestion(question)
+	for range 60 {
+		manyAnswers.AddAnswer(answer)
+	}
+
+	withAdditional := NewResponse(2).AddQuestion(question).AddAnswer(answer)
+	for range 60 {
+		withAdditional.AddAdditional(glue)
+	}
+
+	tests := []struct {
+		name          string
+		response      *DNSResponse
+		maxBytes      int
+		truncated     bool
+		keepAllAnswer bool
+	}{
+		{
+			name:          "fits without truncation",
+			response:      NewResponse(3).AddQuestion(question).AddAnswer(answer).Build(),
+			maxBytes:      DefaultUDPPayloadSize,
+			keepAllAnswer: true,
+		},
+		{
+			name:      "answers truncated to 512 bytes",
+			response:  manyAnswers.Build(),
+			maxBytes:  DefaultUDPPayloadSize,
+			truncated: true,
+		},
+		{
+			name:      "answers truncated to EDNS size",
+			response:  manyAnswers.Build(),
+			maxBytes:  700,
+			truncated: true,
+		},
+		{
+			name:          "additional records dropped first",
+			response:      withAdditional.Build(),
+			maxBytes:      DefaultUDPPayloadSize,
+			truncated:     true,
+			keepAllAnswer: true,
+		},
+		{
+			name:      "limit below header and question",
+			response:  manyAnswers.Build(),
+			maxBytes:  10,
+			truncated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := len(tt.response.Answers)
+			result := tt.response.TruncateTo(tt.maxBytes)
+			serialized := result.ToBytesWithCompression()
+
+			if result.Header.Flags.IsTruncated() != tt.truncated {
+				t.Errorf("TC bit = %v, want %v", result.Header.Flags.IsTruncated(), tt.truncated)
+			}
+
+			recordsLeft := len(result.Answers) + len(result.AuthorityRecords) + len(result.AdditionalRecords)
+			if len(serialized) > tt.maxBytes && recordsLeft > 0 {
+				t.Errorf("serialized size %d exceeds limit %d", len(serialized), tt.maxBytes)
+			}
+
+			if tt.keepAllAnswer && len(result.Answers) != original {
+				t.Errorf("answers = %d, want all %d kept", len(result.Answers), original)
+			}
+
+			if int(result.Header.AnswerRecordCount) != len(result.Answers) ||
+				int(result.Header.AdditionalRecordCount) != len(result.AdditionalRecords) {
+				t.Errorf("header counts %+v do not match sections", result.Header)
+			}
+
+			if len(tt.response.Answers) != original {
+				t.Error("TruncateTo modified the original response")
+			}
+
+			// The truncated message must still parse
+			if _, err := NewDNSResponse(serialized); err != nil {
+				t.Errorf("truncated response does not parse: %v", err)
+			}
+		})
+	}
+}
+
+func TestDNSResponseTruncateToKeepsWholeAnswers(t *testing.T) {
+	question := createTestDNSQuestion("example.com.", TYPE_A, CLASS_IN)
+
+	// Ten A records with distinct 50 byte labels take 67 bytes each once
+	// their example.com suffix is compressed; 29 bytes of header and question
+	// leave room for seven of them in 512 bytes
+	builder := NewResponse(1).AddQuestion(question)
+	for i := range 10 {
+		name := fmt.Sprintf("%s%02d.example.com.", strings.Repeat("a", 48), i)
+		builder.AddAnswer(mustBuildAnswer(t, records.NewBuilder().Name(name).Type(TYPE_A).TTL(300).IPv4(net.IPv4(192, 0, 2, byte(i)))))
+	}
+	response := builder.Build()
+
+	result := response.TruncateTo(DefaultUDPPayloadSize)
+
+	if !result.Header.Flags.IsTruncated() {
+		t.Error("TC bit not set")
+	}
+	if len(result.Answers) != 7 || result.Header.AnswerRecordCount != 7 {
+		t.Errorf("kept %d answers (count %d), want 7", len(result.Answers), result.Header.AnswerRecordCount)
+	}
+	if size := len(result.ToBytesWithCompression()); size > DefaultUDPPayloadSize {
+		t.Errorf("serialized size %d exceeds %d", size, DefaultUDPPayloadSize)
+	}
+	if len(result.Questions) != 1 || !result.Questions[0].Name.Equal(question.Name) {
+		t.Errorf("questions = %v, want the original question", result.Questions)
+	}
+	for i, answer := range result.Answers {
+		if !answer.Name().Equal(response.Answers[i].Name()) {
+			t.Errorf("answer %d was not among the leading answers", i)
+		}
+	}
+}
+
+func TestDNSRequestUDPPayloadSize(t *testing.T) {
+	opt := func(size uint16) DNSAnswer {
+		return DNSAnswer{
+			class: [2]byte{byte(size >> 8), byte(size)},
+			type_: types.DnsTypeClassToBytes(types.TYPE_OPT),
+		}
+	}
+
+	tests := []struct {
+		name       string
+		additional []DNSAnswer
+		expected   int
+	}{
+		{"no EDNS", nil, DefaultUDPPayloadSize},
+		{"EDNS 1232", []DNSAnswer{opt(1232)}, 1232},
+		{"EDNS below minimum", []DNSAnswer{opt(100)}, DefaultUDPPayloadSize},
+		{"EDNS above maximum", []DNSAnswer{opt(65535)}, MaxUDPPayloadSize},
+		{
+			"OPT after other records",
+			[]DNSAnswer{mustBuildAnswer(t, records.NewBuilder().Name("ns.example.com.").Type(TYPE_A).TTL(300).IPv4(net.IPv4(192, 0, 2, 53))), opt(2048)},
+			2048,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &DNSRequest{AdditionalRecords: tt.additional}
+			if got := request.UDPPayloadSize(); got != tt.expected {
+				t.Errorf("UDPPayloadSize() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewDNSMessageRejectsCompressionLoops(t *testing.T) {
+	header := []byte{0x12, 0x34, 0x81, 0x80, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+	tests := []struct {
+		name string
+		body []byte
+	}{
+		{
+			name: "question name pointing at itself",
+			body: []byte{0xC0, 0x0C, 0x00, 0x01, 0x00, 0x01},
+		},
+		{
+			name: "question name pointing forward into a loop",
+			body: []byte{0xC0, 0x12, 0x00, 0x01, 0x00, 0x01, 0xC0, 0x0C},
+		},
+		{
+			name: "label followed by a pointer back to itself",
+			body: []byte{0x01, 'x', 0xC0, 0x0C, 0x00, 0x01, 0x00, 0x01},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := append(append([]byte{}, header...), tt.body...)
+
+			if _, err := NewDNSResponse(data); err == nil {
+				t.Error("NewDNSResponse: expected error for looping name, got none")
+			}
+
+			if _, err := NewDNSRequest(data); err == nil {
+				t.Error("NewDNSRequest: expected error for looping name, got none")
+			}
+		})
+	}
+}
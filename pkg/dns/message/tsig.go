@@ -0,0 +1,130 @@
+package message
+
+import (
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/tsig"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// Sign signs the request with key, named keyName, using HMAC-SHA256 and
+// appends the TSIG record to its additional section. The request must not
+// change once signed
+func (request *DNSRequest) Sign(keyName string, key []byte) error {
+	record, err := tsig.NewTSIGRecord(keyName, tsig.HMACSHA256, key, uint64(time.Now().Unix()), tsig.DefaultFudge, nil, request.Header.ID)
+	if err != nil {
+		return err
+	}
+
+	answer, err := signedTSIGAnswer(record, key, nil, request.ToBytesWithCompression())
+	if err != nil {
+		return err
+	}
+	request.AdditionalRecords = append(request.AdditionalRecords, *answer)
+	request.Header.AdditionalRecordCount++
+	request.wire = nil
+	return nil
+}
+
+// ValidateTSIG checks the TSIG record ending the request with the key of the
+// same name in keyring. It returns tsig.ErrUnsigned for requests without a
+// TSIG record and tsig.ErrBadKey, tsig.ErrBadSig or tsig.ErrBadTime for
+// records that fail verification
+func (request *DNSRequest) ValidateTSIG(keyring map[string][]byte) error {
+	wire := request.wire
+	if wire == nil {
+		wire = request.ToBytesWithCompression()
+	}
+	_, err := tsig.Verify(wire, keyring, nil, time.Now())
+	return err
+}
+
+// TSIG returns the TSIG record ending the request, or nil when the request
+// is not signed
+func (request *DNSRequest) TSIG() *tsig.TSIGRecord {
+	return lastTSIG(request.AdditionalRecords)
+}
+
+// Sign signs the message with key, named keyName, using HMAC-SHA256 and
+// appends the TSIG record to its additional section. The message must not
+// change once signed
+func (d *DNSResponse) Sign(keyName string, key []byte) error {
+	record, err := tsig.NewTSIGRecord(keyName, tsig.HMACSHA256, key, uint64(time.Now().Unix()), tsig.DefaultFudge, nil, d.Header.ID)
+	if err != nil {
+		return err
+	}
+	return d.SignTSIG(record, key, nil)
+}
+
+// SignTSIG computes the MAC of record over the message and appends record to
+// its additional section. requestMAC is the MAC of the signed request the
+// message answers, nil when the message is a request
+func (d *DNSResponse) SignTSIG(record *tsig.TSIGRecord, key, requestMAC []byte) error {
+	answer, err := signedTSIGAnswer(record, key, requestMAC, d.ToBytesWithCompression())
+	if err != nil {
+		return err
+	}
+	d.AdditionalRecords = append(d.AdditionalRecords, *answer)
+	d.Header.AdditionalRecordCount++
+	d.wire = nil
+	return nil
+}
+
+// AddTSIG appends record to the additional section as it is, such as the
+// unsigned TSIG record of an error response to a request whose key is unknown
+func (d *DNSResponse) AddTSIG(record *tsig.TSIGRecord) {
+	d.AdditionalRecords = append(d.AdditionalRecords, *tsigAnswer(record))
+	d.Header.AdditionalRecordCount++
+	d.wire = nil
+}
+
+// ValidateTSIG checks the TSIG record ending the message with the key of the
+// same name in keyring, as for a request. Multi-message responses to signed
+// requests are checked with a tsig.Stream instead
+func (d *DNSResponse) ValidateTSIG(keyring map[string][]byte) error {
+	wire := d.wire
+	if wire == nil {
+		wire = d.ToBytesWithCompression()
+	}
+	_, err := tsig.Verify(wire, keyring, nil, time.Now())
+	return err
+}
+
+// TSIG returns the TSIG record ending the message, or nil when the message
+// is not signed
+func (d *DNSResponse) TSIG() *tsig.TSIGRecord {
+	return lastTSIG(d.AdditionalRecords)
+}
+
+// signedTSIGAnswer sets the MAC of record over unsigned, the message to sign,
+// and returns the record as an answer
+func signedTSIGAnswer(record *tsig.TSIGRecord, key, requestMAC, unsigned []byte) (*DNSAnswer, error) {
+	mac, err := record.ComputeMAC(key, requestMAC, unsigned, false)
+	if err != nil {
+		return nil, err
+	}
+	record.MAC = mac
+	return tsigAnswer(record), nil
+}
+
+// tsigAnswer returns record as an answer owned by its key name
+func tsigAnswer(record *tsig.TSIGRecord) *DNSAnswer {
+	return NewDNSAnswerFromParts(record.KeyName, types.TYPE_TSIG, types.CLASS_ANY, 0, record.RData())
+}
+
+// lastTSIG decodes the TSIG record ending the additional records, if any
+func lastTSIG(additional []DNSAnswer) *tsig.TSIGRecord {
+	if len(additional) == 0 {
+		return nil
+	}
+
+	last := additional[len(additional)-1]
+	if last.Type() != types.TYPE_TSIG {
+		return nil
+	}
+	record, err := tsig.ParseTSIGRecord(last.Name(), last.Data())
+	if err != nil {
+		return nil
+	}
+	return record
+}
@@ -0,0 +1,55 @@
+package message
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vadim-su/dnska/pkg/dns/tsig"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+func TestSignAndValidateTSIG(t *testing.T) {
+	key := []byte("secret-key-for-testing-12345678")
+	name, err := utils.ParseDomainName("example.org.")
+	if err != nil {
+		t.Fatalf("failed to parse name: %v", err)
+	}
+	query := GenerateDNSQuery(0x1234, []DNSQuestion{NewDNSQuestion(name, types.TYPE_AXFR, types.CLASS_IN)})
+	if err := query.Sign("transfer-key", key); err != nil {
+		t.Fatalf("Sign() returned error: %v", err)
+	}
+	wire := query.ToBytesWithCompression()
+
+	tests := []struct {
+		name    string
+		wire    []byte
+		keyring map[string][]byte
+		wantErr error
+	}{
+		{name: "signed", wire: wire, keyring: map[string][]byte{"transfer-key.": key}},
+		{name: "unknown key", wire: wire, keyring: map[string][]byte{"other-key.": key}, wantErr: tsig.ErrBadKey},
+		{name: "wrong key", wire: wire, keyring: map[string][]byte{"transfer-key.": []byte("other")}, wantErr: tsig.ErrBadSig},
+		{name: "unsigned", wire: GenerateDNSQuery(0x1234, query.Questions).ToBytes(), keyring: map[string][]byte{"transfer-key.": key}, wantErr: tsig.ErrUnsigned},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := NewDNSRequest(tt.wire)
+			if err != nil {
+				t.Fatalf("NewDNSRequest() returned error: %v", err)
+			}
+			if err := request.ValidateTSIG(tt.keyring); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateTSIG() error = %v, expected %v", err, tt.wantErr)
+			}
+
+			record := request.TSIG()
+			if (record != nil) != (tt.wantErr != tsig.ErrUnsigned) {
+				t.Fatalf("TSIG() = %+v", record)
+			}
+			if record != nil && (record.Algorithm != tsig.HMACSHA256 || record.OriginalID != 0x1234) {
+				t.Errorf("unexpected TSIG record %+v", record)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package message
+
+import (
+	"fmt"
+
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// DNSUpdateMessage is a dynamic update (RFC 2136 §2). It shares the wire
+// format of queries, with the sections renamed: the question section names
+// the zone, the answer section holds the prerequisites and the authority
+// section the updates
+type DNSUpdateMessage struct {
+	Header        DNSHeader
+	Zone          DNSQuestion // Zone name and class, with type SOA
+	Prerequisites []DNSAnswer
+	Updates       []DNSAnswer
+	Additional    []DNSAnswer
+
+	wire []byte // Message the update was parsed from
+}
+
+// ParseUpdateMessage decodes an UPDATE message
+func ParseUpdateMessage(data []byte) (*DNSUpdateMessage, error) {
+	request, err := NewDNSRequest(data)
+	if err != nil {
+		return nil, err
+	}
+	return NewUpdateMessage(request)
+}
+
+// NewUpdateMessage returns the update carried by request, which must have
+// the UPDATE opcode and a zone section of exactly one SOA entry
+func NewUpdateMessage(request *DNSRequest) (*DNSUpdateMessage, error) {
+	if opcode := request.Header.Flags.Opcode(); opcode != types.OPCODE_UPDATE {
+		return nil, fmt.Errorf("invalid DNS update: opcode %s", opcode)
+	}
+	if len(request.Questions) != 1 {
+		return nil, fmt.Errorf("invalid DNS update: %d zone entries, expected 1", len(request.Questions))
+	}
+	zone := request.Questions[0]
+	if zoneType := zone.Qtype(); zoneType != types.TYPE_SOA {
+		return nil, fmt.Errorf("invalid DNS update: zone type %s, expected SOA", zoneType)
+	}
+
+	return &DNSUpdateMessage{
+		Header:        request.Header,
+		Zone:          zone,
+		Prerequisites: request.Answers,
+		Updates:       request.AuthorityRecords,
+		Additional:    request.AdditionalRecords,
+		wire:          request.wire,
+	}, nil
+}
+
+// DecodeRecord returns the typed record of a prerequisite or update record.
+// It returns an error wrapping ErrUnsupportedRData for unknown types
+func (u *DNSUpdateMessage) DecodeRecord(record DNSAnswer) (records.DNSRecord, error) {
+	return DecodeRData(record, u.wire)
+}
+
+// GenerateUpdate builds an UPDATE message (RFC 2136) with the given ID for
+// zone, carrying prerequisites and updates. The message can be signed before
+// it is sent
+func GenerateUpdate(id uint16, zone utils.DomainName, prerequisites, updates []DNSAnswer) *DNSResponse {
+	flags := types.NewFlagBuilder(0).
+		SetQR(false).
+		SetOpcode(uint8(types.OPCODE_UPDATE)).
+		Build()
+	return &DNSResponse{
+		Header: DNSHeader{
+			ID:                   id,
+			Flags:                flags,
+			QuestionCount:        1,
+			AnswerRecordCount:    uint16(len(prerequisites)),
+			AuthorityRecordCount: uint16(len(updates)),
+		},
+		Questions:        []DNSQuestion{NewDNSQuestion(zone, types.TYPE_SOA, types.CLASS_IN)},
+		Answers:          prerequisites,
+		AuthorityRecords: updates,
+	}
+}
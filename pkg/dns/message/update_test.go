@@ -0,0 +1,82 @@
+package message
+
+import (
+	"net"
+	"testing"
+
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+func TestParseUpdateMessage(t *testing.T) {
+	zone, err := utils.ParseDomainName("example.org.")
+	if err != nil {
+		t.Fatalf("failed to parse name: %v", err)
+	}
+	host, err := utils.ParseDomainName("www.example.org.")
+	if err != nil {
+		t.Fatalf("failed to parse name: %v", err)
+	}
+
+	prerequisites := []DNSAnswer{*NewDNSAnswerFromParts(host, types.TYPE_ANY, types.CLASS_NONE, 0, nil)}
+	updates := []DNSAnswer{
+		*NewDNSAnswerFromParts(host, types.TYPE_A, types.CLASS_IN, 300, net.ParseIP("192.0.2.1").To4()),
+		*NewDNSAnswerFromParts(host, types.TYPE_AAAA, types.CLASS_ANY, 0, nil),
+	}
+	update := GenerateUpdate(0x1234, zone, prerequisites, updates)
+
+	query := GenerateDNSQuery(0x1234, update.Questions)
+	notify := GenerateNotify(0x1234, zone, nil)
+	noZone := GenerateUpdate(0x1234, zone, nil, nil)
+	noZone.Questions, noZone.Header.QuestionCount = nil, 0
+	aZone := GenerateUpdate(0x1234, zone, nil, nil)
+	aZone.Questions[0].Type = types.DnsTypeClassToBytes(types.TYPE_A)
+
+	tests := []struct {
+		name    string
+		wire    []byte
+		wantErr bool
+	}{
+		{name: "update", wire: update.ToBytesWithCompression()},
+		{name: "query", wire: query.ToBytes(), wantErr: true},
+		{name: "notify", wire: notify.ToBytes(), wantErr: true},
+		{name: "no zone", wire: noZone.ToBytes(), wantErr: true},
+		{name: "zone type A", wire: aZone.ToBytes(), wantErr: true},
+		{name: "truncated", wire: update.ToBytes()[:20], wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParseUpdateMessage(tt.wire)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseUpdateMessage() = %+v, expected an error", parsed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseUpdateMessage() returned error: %v", err)
+			}
+
+			if parsed.Header.ID != 0x1234 || parsed.Zone.Name.String() != "example.org." {
+				t.Errorf("unexpected header %+v or zone %s", parsed.Header, parsed.Zone.Name.String())
+			}
+			if len(parsed.Prerequisites) != 1 || len(parsed.Updates) != 2 || len(parsed.Additional) != 0 {
+				t.Fatalf("got %d prerequisites, %d updates and %d additional records",
+					len(parsed.Prerequisites), len(parsed.Updates), len(parsed.Additional))
+			}
+			if prerequisite := parsed.Prerequisites[0]; prerequisite.Type() != types.TYPE_ANY || prerequisite.Class() != types.CLASS_NONE {
+				t.Errorf("unexpected prerequisite %s %s", prerequisite.Class(), prerequisite.Type())
+			}
+
+			record, err := parsed.DecodeRecord(parsed.Updates[0])
+			if err != nil {
+				t.Fatalf("DecodeRecord() returned error: %v", err)
+			}
+			if a, ok := record.(*records.ARecord); !ok || !a.IP().Equal(net.ParseIP("192.0.2.1")) || a.TTL() != 300 {
+				t.Errorf("DecodeRecord() = %+v", record)
+			}
+		})
+	}
+}
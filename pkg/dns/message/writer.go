@@ -0,0 +1,182 @@
+package message
+
+import (
+	"sync"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// MessageWriter serializes DNS messages with name compression.
+// It tracks the offset of everything written so far, so owner names and the
+// domain names embedded in RDATA can be compressed against earlier names
+type MessageWriter struct {
+	compressionMap *utils.CompressionMap
+	pooled         bool   // The compression map goes back to compressionMaps on Release
+	baseOffset     uint16 // Message offset of the first byte in buffer
+	buffer         []byte
+}
+
+// compressionMaps recycles the compression maps of written messages
+var compressionMaps = sync.Pool{
+	New: func() any { return utils.NewCompressionMap() },
+}
+
+// NewMessageWriter creates a writer for a new message
+func NewMessageWriter() *MessageWriter {
+	return NewMessageWriterTo(make([]byte, 0, 512))
+}
+
+// NewMessageWriterTo creates a writer appending a new message to buffer.
+// Bytes already in buffer, such as the length prefix of a TCP message, are
+// not part of the message and do not count in its offsets
+func NewMessageWriterTo(buffer []byte) *MessageWriter {
+	return &MessageWriter{
+		compressionMap: compressionMaps.Get().(*utils.CompressionMap),
+		pooled:         true,
+		// Offsets wrap around to 0 at the end of the bytes already in buffer
+		baseOffset: -uint16(len(buffer)),
+		buffer:     buffer,
+	}
+}
+
+// Release recycles the compression map of a writer created by
+// NewMessageWriter or NewMessageWriterTo. The written bytes stay valid, but
+// nothing can be written after it
+func (w *MessageWriter) Release() {
+	if !w.pooled {
+		return
+	}
+	w.compressionMap.Reset()
+	compressionMaps.Put(w.compressionMap)
+	w.compressionMap = nil
+	w.pooled = false
+}
+
+// newMessageWriterAt creates a writer continuing a message at the given offset
+// with an existing compression map
+func newMessageWriterAt(compressionMap *utils.CompressionMap, offset uint16) *MessageWriter {
+	return &MessageWriter{
+		compressionMap: compressionMap,
+		baseOffset:     offset,
+	}
+}
+
+// Bytes returns the bytes written so far
+func (w *MessageWriter) Bytes() []byte {
+	return w.buffer
+}
+
+// Offset returns the message offset of the next byte to be written
+func (w *MessageWriter) Offset() uint16 {
+	return w.baseOffset + uint16(len(w.buffer))
+}
+
+// WriteHeader writes the 12-byte message header
+func (w *MessageWriter) WriteHeader(header DNSHeader) {
+	w.buffer = header.AppendBytes(w.buffer)
+}
+
+// WriteName writes a domain name, compressing it against earlier names
+func (w *MessageWriter) WriteName(name utils.DomainName) {
+	w.buffer = name.AppendBytesWithCompression(w.buffer, w.compressionMap, w.Offset())
+}
+
+// WriteQuestion writes a question entry
+func (w *MessageWriter) WriteQuestion(question DNSQuestion) {
+	w.WriteName(question.Name)
+	w.buffer = append(w.buffer, question.Type[:]...)
+	w.buffer = append(w.buffer, question.Class[:]...)
+}
+
+// WriteRecord writes a resource record. Domain names inside the RDATA of the
+// RFC 1035 types that allow it are compressed as well, and RDLENGTH is
+// computed from the compressed RDATA
+func (w *MessageWriter) WriteRecord(record DNSAnswer) {
+	w.WriteName(record.name)
+	w.buffer = append(w.buffer, record.type_[:]...)
+	w.buffer = append(w.buffer, record.class[:]...)
+	w.buffer = append(w.buffer, record.ttl[:]...)
+
+	// Reserve RDLENGTH and fill it in once the RDATA is written
+	lengthPosition := len(w.buffer)
+	w.buffer = append(w.buffer, 0x00, 0x00)
+
+	if !w.writeCompressedRData(record) {
+		w.buffer = append(w.buffer[:lengthPosition+2], record.data...)
+	}
+
+	dataLength := len(w.buffer) - lengthPosition - 2
+	w.buffer[lengthPosition] = byte(dataLength >> 8)
+	w.buffer[lengthPosition+1] = byte(dataLength)
+}
+
+// WriteMessage writes a complete message: header and all four sections
+func (w *MessageWriter) WriteMessage(
+	header DNSHeader,
+	questions []DNSQuestion,
+	answers, authorityRecords, additionalRecords []DNSAnswer,
+) {
+	w.WriteHeader(header)
+
+	for _, question := range questions {
+		w.WriteQuestion(question)
+	}
+
+	for _, section := range [][]DNSAnswer{answers, authorityRecords, additionalRecords} {
+		for _, record := range section {
+			w.WriteRecord(record)
+		}
+	}
+}
+
+// writeCompressedRData writes RDATA with its embedded names compressed.
+// It returns false without a usable result when the type carries no
+// compressible names or the RDATA does not parse, in which case the caller
+// writes the RDATA verbatim
+func (w *MessageWriter) writeCompressedRData(record DNSAnswer) bool {
+	recordType := record.Type()
+	data := record.data
+
+	var namesBefore, fixedAfter int
+	switch recordType {
+	case types.TYPE_NS, types.TYPE_CNAME, types.TYPE_PTR,
+		types.TYPE_MD, types.TYPE_MF, types.TYPE_MB, types.TYPE_MG, types.TYPE_MR:
+		namesBefore = 1
+	case types.TYPE_MINFO:
+		namesBefore = 2
+	case types.TYPE_SOA:
+		namesBefore, fixedAfter = 2, 20
+	case types.TYPE_MX:
+		// 16-bit preference followed by the exchange name
+		if len(data) < 2 {
+			return false
+		}
+		w.buffer = append(w.buffer, data[:2]...)
+		data = data[2:]
+		namesBefore = 1
+	default:
+		return false
+	}
+
+	names := make([]utils.DomainName, 0, namesBefore)
+	for range namesBefore {
+		name, size, err := utils.NewDomainName(data)
+		if err != nil {
+			return false
+		}
+		names = append(names, *name)
+		data = data[size:]
+	}
+
+	if len(data) != fixedAfter {
+		return false
+	}
+
+	for _, name := range names {
+		w.WriteName(name)
+	}
+	w.buffer = append(w.buffer, data...)
+
+	return true
+}
@@ -0,0 +1,276 @@
+package message
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// answerFromRecord converts a record into a DNSAnswer owned by its own name
+func answerFromRecord(t testing.TB, record records.DNSRecord) DNSAnswer {
+	t.Helper()
+
+	name, err := utils.NewDomainNameFromString(record.Name())
+	if err != nil {
+		t.Fatalf("invalid record name %q: %v", record.Name(), err)
+	}
+
+	answer, err := NewDNSAnswer(name.ToBytes(), record.Class(), record.Type(), record.TTL(), record.Data())
+	if err != nil {
+		t.Fatalf("failed to create answer: %v", err)
+	}
+	return *answer
+}
+
+// expandRData returns the RDATA of a parsed answer with compressed names
+// expanded against the whole message
+func expandRData(t *testing.T, answer DNSAnswer, message []byte) []byte {
+	t.Helper()
+
+	recordType := answer.Type()
+	data := answer.data
+
+	var prefix, names int
+	switch recordType {
+	case types.TYPE_NS, types.TYPE_CNAME, types.TYPE_PTR:
+		names = 1
+	case types.TYPE_MX:
+		prefix, names = 2, 1
+	case types.TYPE_SOA:
+		names = 2
+	default:
+		return data
+	}
+
+	result := append([]byte{}, data[:prefix]...)
+	data = data[prefix:]
+	for range names {
+		name, size, err := utils.NewDomainNameWithDecompression(data, message)
+		if err != nil {
+			t.Fatalf("failed to expand RDATA name: %v", err)
+		}
+		result = append(result, name.ToBytes()...)
+		data = data[size:]
+	}
+	return append(result, data...)
+}
+
+// assertSameRecords checks that parsed records match the originals
+func assertSameRecords(t *testing.T, section string, got, want []DNSAnswer, message []byte) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d records, want %d", section, len(got), len(want))
+	}
+
+	for idx := range want {
+		if !got[idx].name.Equal(want[idx].name) {
+			t.Errorf("%s[%d]: name %s, want %s", section, idx, got[idx].name.String(), want[idx].name.String())
+		}
+		if got[idx].type_ != want[idx].type_ || got[idx].class != want[idx].class || got[idx].ttl != want[idx].ttl {
+			t.Errorf("%s[%d]: fixed fields differ", section, idx)
+		}
+		if rdata := expandRData(t, got[idx], message); !bytes.Equal(rdata, want[idx].data) {
+			t.Errorf("%s[%d]: RDATA %x, want %x", section, idx, rdata, want[idx].data)
+		}
+	}
+}
+
+func buildZoneResponse(t testing.TB, hosts int) *DNSResponse {
+	t.Helper()
+
+	question := createTestDNSQuestion("example.com.", types.TYPE_MX, types.CLASS_IN)
+	builder := NewResponse(0x4242).AddQuestion(question)
+
+	for idx := range hosts {
+		builder.AddAnswer(answerFromRecord(t, records.NewMXRecord(
+			"example.com.", fmt.Sprintf("mail%d.example.com.", idx), uint16(10*idx), 300)))
+		builder.AddAnswer(answerFromRecord(t, records.NewCNAMERecord(
+			fmt.Sprintf("alias%d.example.com.", idx), fmt.Sprintf("mail%d.example.com.", idx), 300)))
+	}
+
+	builder.AddAuthority(
+		answerFromRecord(t, records.NewNSRecord("example.com.", "ns1.example.com.", 3600)),
+		answerFromRecord(t, records.NewNSRecord("example.com.", "ns2.example.com.", 3600)),
+		answerFromRecord(t, records.NewSOARecord("example.com.", "ns1.example.com.", "hostmaster.example.com.",
+			2024010101, time.Hour, 15*time.Minute, 7*24*time.Hour, 5*time.Minute, 3600)),
+	)
+
+	for idx := range hosts {
+		a, err := records.NewARecordFromString(fmt.Sprintf("mail%d.example.com.", idx), fmt.Sprintf("192.0.2.%d", idx%250+1), 300)
+		if err != nil {
+			t.Fatalf("failed to create A record: %v", err)
+		}
+		builder.AddAdditional(answerFromRecord(t, a))
+	}
+
+	return builder.Build()
+}
+
+func TestMessageWriterRoundTrip(t *testing.T) {
+	for _, hosts := range []int{1, 10, 100} {
+		t.Run(fmt.Sprintf("%d hosts", hosts), func(t *testing.T) {
+			response := buildZoneResponse(t, hosts)
+
+			compressed := response.ToBytesWithCompression()
+			uncompressed := response.ToBytes()
+
+			if len(compressed) >= len(uncompressed) {
+				t.Errorf("compressed size %d not smaller than uncompressed %d", len(compressed), len(uncompressed))
+			}
+
+			parsed, err := NewDNSResponse(compressed)
+			if err != nil {
+				t.Fatalf("failed to parse compressed response: %v", err)
+			}
+
+			if parsed.Header != response.Header {
+				t.Errorf("header = %+v, want %+v", parsed.Header, response.Header)
+			}
+			assertSameRecords(t, "answer", parsed.Answers, response.Answers, compressed)
+			assertSameRecords(t, "authority", parsed.AuthorityRecords, response.AuthorityRecords, compressed)
+			assertSameRecords(t, "additional", parsed.AdditionalRecords, response.AdditionalRecords, compressed)
+		})
+	}
+}
+
+func TestMessageWriterTo(t *testing.T) {
+	response := buildZoneResponse(t, 10)
+	expected := response.ToBytesWithCompression()
+
+	// Offsets count from the end of a TCP length prefix
+	framed := response.AppendBytesWithCompression([]byte{0x00, 0x00})
+	if !bytes.Equal(framed[2:], expected) {
+		t.Error("message after a prefix differs from the message on its own")
+	}
+
+	// A reused buffer and compression map produce the same message
+	buffer := make([]byte, 0, len(expected))
+	for range 3 {
+		buffer = response.AppendBytesWithCompression(buffer[:0])
+		if !bytes.Equal(buffer, expected) {
+			t.Fatal("message written to a reused buffer differs")
+		}
+	}
+	if cap(buffer) != len(expected) {
+		t.Errorf("buffer grew to %d bytes, expected it to be reused", cap(buffer))
+	}
+}
+
+func TestMessageWriterDeterministic(t *testing.T) {
+	response := buildZoneResponse(t, 20)
+
+	first := response.ToBytesWithCompression()
+	for range 10 {
+		if !bytes.Equal(response.ToBytesWithCompression(), first) {
+			t.Fatal("compressed output differs between runs")
+		}
+	}
+}
+
+func TestMessageWriterRDataCompression(t *testing.T) {
+	question := createTestDNSQuestion("www.example.com.", types.TYPE_CNAME, types.CLASS_IN)
+	cname := answerFromRecord(t, records.NewCNAMERecord("www.example.com.", "web.example.com.", 300))
+
+	response := NewResponse(1).AddQuestion(question).AddAnswer(cname).Build()
+	message := response.ToBytesWithCompression()
+
+	// header(12) + question(17 + 4) + owner pointer(2) + type/class/ttl(8)
+	rdLengthOffset := 12 + 21 + 2 + 8
+	rdLength := int(message[rdLengthOffset])<<8 | int(message[rdLengthOffset+1])
+	rdata := message[rdLengthOffset+2:]
+
+	// "web" label followed by a pointer to "example.com." in the question
+	expected := []byte{0x03, 'w', 'e', 'b', 0xC0, 16}
+	if rdLength != len(expected) || !bytes.Equal(rdata, expected) {
+		t.Errorf("RDATA = %x (RDLENGTH %d), want %x", rdata, rdLength, expected)
+	}
+}
+
+func TestMessageWriterPointerOffsetLimit(t *testing.T) {
+	// Fill the message past the 14-bit pointer range with TXT records
+	question := createTestDNSQuestion("example.com.", types.TYPE_TXT, types.CLASS_IN)
+	builder := NewResponse(1).AddQuestion(question)
+
+	text := strings.Repeat("x", 250)
+	for range 80 {
+		builder.AddAnswer(answerFromRecord(t, records.NewTXTRecordFromString("example.com.", text, 300)))
+	}
+
+	// Names first written beyond 0x3FFF must not become pointer targets
+	for idx := range 5 {
+		name := fmt.Sprintf("late%d.example.org.", idx)
+		builder.AddAdditional(answerFromRecord(t, records.NewCNAMERecord(name, "target.example.org.", 300)))
+	}
+
+	response := builder.Build()
+	message := response.ToBytesWithCompression()
+
+	if len(message) <= utils.MAX_COMPRESSION_OFFSET {
+		t.Fatalf("message of %d bytes does not exceed the pointer range", len(message))
+	}
+
+	parsed, err := NewDNSResponse(message)
+	if err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+
+	assertSameRecords(t, "answer", parsed.Answers, response.Answers, message)
+	assertSameRecords(t, "additional", parsed.AdditionalRecords, response.AdditionalRecords, message)
+}
+
+func BenchmarkSerializeResponse(b *testing.B) {
+	response := buildZoneResponse(b, 10)
+
+	b.Run("new buffer", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			response.ToBytesWithCompression()
+		}
+	})
+
+	b.Run("reused buffer", func(b *testing.B) {
+		buffer := make([]byte, 0, 512)
+		b.ReportAllocs()
+		for b.Loop() {
+			buffer = response.AppendBytesWithCompression(buffer[:0])
+		}
+	})
+}
+
+// BenchmarkCompressionMapReuse compares allocating a compression map for
+// every response with taking it from the pool, building responses from
+// concurrent goroutines as a server under load does
+func BenchmarkCompressionMapReuse(b *testing.B) {
+	response := buildZoneResponse(b, 10)
+
+	b.Run("new map", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			buffer := make([]byte, 0, 512)
+			for pb.Next() {
+				w := newMessageWriterAt(utils.NewCompressionMap(), 0)
+				w.buffer = buffer[:0]
+				w.WriteMessage(response.Header, response.Questions, response.Answers,
+					response.AuthorityRecords, response.AdditionalRecords)
+				buffer = w.Bytes()
+			}
+		})
+	})
+
+	b.Run("pooled map", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			buffer := make([]byte, 0, 512)
+			for pb.Next() {
+				buffer = response.AppendBytesWithCompression(buffer[:0])
+			}
+		})
+	})
+}
@@ -48,7 +48,7 @@ func (r *ARecord) Data() []byte {
 	return r.ip.To4()
 }
 
-// String returns a string representation of the A record
+// String returns the record in presentation format
 func (r *ARecord) String() string {
-	return fmt.Sprintf("%s %d IN A %s", r.name, r.ttl, r.ip.String())
+	return r.Presentation()
 }
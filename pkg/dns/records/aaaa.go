@@ -48,7 +48,7 @@ func (r *AAAARecord) Data() []byte {
 	return r.ip.To16()
 }
 
-// String returns a string representation of the AAAA record
+// String returns the record in presentation format
 func (r *AAAARecord) String() string {
-	return fmt.Sprintf("%s %d IN AAAA %s", r.name, r.ttl, r.ip.String())
+	return r.Presentation()
 }
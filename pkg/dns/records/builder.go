@@ -0,0 +1,166 @@
+package records
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// Builder constructs records field by field. It supports the A, AAAA,
+// CNAME, NS, PTR, MX and TXT types
+type Builder struct {
+	name        string
+	recordType  types.DNSType
+	class       types.DNSClass
+	ttl         uint32
+	ipv4        net.IP
+	ipv6        net.IP
+	priority    uint16
+	hasPriority bool
+	target      string
+	texts       []string
+}
+
+// NewBuilder returns a builder for an IN class record with a zero TTL
+func NewBuilder() *Builder {
+	return &Builder{class: types.CLASS_IN}
+}
+
+// Name sets the owner name of the record
+func (b *Builder) Name(name string) *Builder {
+	b.name = name
+	return b
+}
+
+// Type sets the record type
+func (b *Builder) Type(recordType types.DNSType) *Builder {
+	b.recordType = recordType
+	return b
+}
+
+// Class sets the record class
+func (b *Builder) Class(class types.DNSClass) *Builder {
+	b.class = class
+	return b
+}
+
+// TTL sets the time-to-live of the record
+func (b *Builder) TTL(ttl uint32) *Builder {
+	b.ttl = ttl
+	return b
+}
+
+// IPv4 sets the address of an A record
+func (b *Builder) IPv4(ip net.IP) *Builder {
+	b.ipv4 = ip
+	return b
+}
+
+// IPv6 sets the address of an AAAA record
+func (b *Builder) IPv6(ip net.IP) *Builder {
+	b.ipv6 = ip
+	return b
+}
+
+// Priority sets the preference of an MX record
+func (b *Builder) Priority(priority uint16) *Builder {
+	b.priority = priority
+	b.hasPriority = true
+	return b
+}
+
+// Target sets the domain name a CNAME, NS, PTR or MX record points to
+func (b *Builder) Target(target string) *Builder {
+	b.target = target
+	return b
+}
+
+// Text appends a string to a TXT record
+func (b *Builder) Text(text string) *Builder {
+	b.texts = append(b.texts, text)
+	return b
+}
+
+// Build returns the record, or an error naming the first field the record
+// type needs that is missing, or a field set that the type does not use
+func (b *Builder) Build() (DNSRecord, error) {
+	if b.name == "" {
+		return nil, errors.New("record: missing name")
+	}
+
+	base := NewBaseRecord(b.name, b.class, b.ttl)
+	switch b.recordType {
+	case types.TYPE_A:
+		if err := b.check("IPv4 address"); err != nil {
+			return nil, err
+		}
+		if b.ipv4.To4() == nil {
+			return nil, fmt.Errorf("A record %s: not an IPv4 address: %s", b.name, b.ipv4)
+		}
+		return &ARecord{BaseRecord: base, ip: b.ipv4.To4()}, nil
+	case types.TYPE_AAAA:
+		if err := b.check("IPv6 address"); err != nil {
+			return nil, err
+		}
+		if b.ipv6.To16() == nil || b.ipv6.To4() != nil {
+			return nil, fmt.Errorf("AAAA record %s: not an IPv6 address: %s", b.name, b.ipv6)
+		}
+		return &AAAARecord{BaseRecord: base, ip: b.ipv6.To16()}, nil
+	case types.TYPE_CNAME:
+		if err := b.check("target"); err != nil {
+			return nil, err
+		}
+		return &CNAMERecord{BaseRecord: base, target: b.target}, nil
+	case types.TYPE_NS:
+		if err := b.check("target"); err != nil {
+			return nil, err
+		}
+		return &NSRecord{BaseRecord: base, nameServer: b.target}, nil
+	case types.TYPE_PTR:
+		if err := b.check("target"); err != nil {
+			return nil, err
+		}
+		return &PTRRecord{BaseRecord: base, target: b.target}, nil
+	case types.TYPE_MX:
+		if err := b.check("target", "priority"); err != nil {
+			return nil, err
+		}
+		return &MXRecord{BaseRecord: base, preference: b.priority, mailServer: b.target}, nil
+	case types.TYPE_TXT:
+		if err := b.check("text"); err != nil {
+			return nil, err
+		}
+		return &TXTRecord{BaseRecord: base, texts: b.texts}, nil
+	case 0:
+		return nil, fmt.Errorf("record %s: missing type", b.name)
+	default:
+		return nil, fmt.Errorf("record %s: type %s is not supported by the builder", b.name, b.recordType)
+	}
+}
+
+// check returns an error naming the first of the required fields that was
+// not set, or the first set field that is not required
+func (b *Builder) check(required ...string) error {
+	set := map[string]bool{
+		"IPv4 address": b.ipv4 != nil,
+		"IPv6 address": b.ipv6 != nil,
+		"priority":     b.hasPriority,
+		"target":       b.target != "",
+		"text":         len(b.texts) > 0,
+	}
+
+	for _, field := range required {
+		if !set[field] {
+			return fmt.Errorf("%s record %s: missing %s", b.recordType, b.name, field)
+		}
+		delete(set, field)
+	}
+	for _, field := range []string{"IPv4 address", "IPv6 address", "priority", "target", "text"} {
+		if set[field] {
+			return fmt.Errorf("%s record %s: unexpected %s", b.recordType, b.name, field)
+		}
+	}
+	return nil
+}
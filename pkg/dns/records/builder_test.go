@@ -0,0 +1,105 @@
+package records
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+func TestBuilder(t *testing.T) {
+	tests := []struct {
+		name     string
+		builder  *Builder
+		expected string
+	}{
+		{
+			name:     "A",
+			builder:  NewBuilder().Name("www.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.0.2.1")),
+			expected: "www.example.com. 300 IN A 192.0.2.1",
+		},
+		{
+			name:     "AAAA",
+			builder:  NewBuilder().Name("www.example.com").Type(types.TYPE_AAAA).TTL(300).IPv6(net.ParseIP("2001:db8::1")),
+			expected: "www.example.com. 300 IN AAAA 2001:db8::1",
+		},
+		{
+			name:     "CNAME",
+			builder:  NewBuilder().Name("alias.example.com").Type(types.TYPE_CNAME).TTL(60).Target("www.example.com."),
+			expected: NewCNAMERecord("alias.example.com", "www.example.com.", 60).String(),
+		},
+		{
+			name:     "NS",
+			builder:  NewBuilder().Name("example.com").Type(types.TYPE_NS).TTL(3600).Target("ns1.example.com."),
+			expected: NewNSRecord("example.com", "ns1.example.com.", 3600).String(),
+		},
+		{
+			name:     "PTR",
+			builder:  NewBuilder().Name("1.2.0.192.in-addr.arpa").Type(types.TYPE_PTR).TTL(300).Target("www.example.com."),
+			expected: NewPTRRecord("1.2.0.192.in-addr.arpa", "www.example.com.", 300).String(),
+		},
+		{
+			name:     "MX with zero priority",
+			builder:  NewBuilder().Name("example.com").Type(types.TYPE_MX).TTL(300).Priority(0).Target("mail.example.com."),
+			expected: NewMXRecord("example.com", "mail.example.com.", 0, 300).String(),
+		},
+		{
+			name:     "TXT",
+			builder:  NewBuilder().Name("example.com").Type(types.TYPE_TXT).TTL(300).Text("v=spf1 -all").Text("second"),
+			expected: NewTXTRecord("example.com", []string{"v=spf1 -all", "second"}, 300).String(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := tt.builder.Build()
+			if err != nil {
+				t.Fatalf("Build() returned error: %v", err)
+			}
+			if got := record.String(); got != tt.expected {
+				t.Errorf("String() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBuilderClass(t *testing.T) {
+	record, err := NewBuilder().Name("version.bind").Type(types.TYPE_TXT).Class(types.CLASS_CH).Text("dnska").Build()
+	if err != nil {
+		t.Fatalf("Build() returned error: %v", err)
+	}
+	if record.Class() != types.CLASS_CH {
+		t.Errorf("Class() = %v, want CH", record.Class())
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		builder  *Builder
+		expected string
+	}{
+		{name: "no name", builder: NewBuilder().Type(types.TYPE_A).IPv4(net.ParseIP("192.0.2.1")), expected: "missing name"},
+		{name: "no type", builder: NewBuilder().Name("example.com"), expected: "missing type"},
+		{name: "unsupported type", builder: NewBuilder().Name("example.com").Type(types.TYPE_SOA), expected: "not supported"},
+		{name: "A without address", builder: NewBuilder().Name("example.com").Type(types.TYPE_A), expected: "missing IPv4 address"},
+		{name: "A with IPv6 address", builder: NewBuilder().Name("example.com").Type(types.TYPE_A).IPv4(net.ParseIP("2001:db8::1")), expected: "not an IPv4 address"},
+		{name: "AAAA with IPv4 address", builder: NewBuilder().Name("example.com").Type(types.TYPE_AAAA).IPv6(net.ParseIP("192.0.2.1")), expected: "not an IPv6 address"},
+		{name: "MX without priority", builder: NewBuilder().Name("example.com").Type(types.TYPE_MX).Target("mail.example.com."), expected: "missing priority"},
+		{name: "CNAME with text", builder: NewBuilder().Name("example.com").Type(types.TYPE_CNAME).Target("www.example.com.").Text("x"), expected: "unexpected text"},
+		{name: "TXT without text", builder: NewBuilder().Name("example.com").Type(types.TYPE_TXT), expected: "missing text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.builder.Build()
+			if err == nil {
+				t.Fatal("Build() returned no error")
+			}
+			if !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Build() error = %q, want it to contain %q", err, tt.expected)
+			}
+		})
+	}
+}
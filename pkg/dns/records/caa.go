@@ -0,0 +1,93 @@
+package records
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// CAA property tags registered with IANA (RFC 8659 §7.2, RFC 9495)
+const (
+	CAATagIssue        = "issue"        // CA authorized to issue certificates for the name
+	CAATagIssueWild    = "issuewild"    // CA authorized to issue wildcard certificates
+	CAATagIODEF        = "iodef"        // Where CAs report invalid certificate requests
+	CAATagContactEmail = "contactemail" // E-mail address of the domain contact
+	CAATagContactPhone = "contactphone" // Phone number of the domain contact
+	CAATagIssueVMC     = "issuevmc"     // CA authorized to issue verified mark certificates
+	CAATagIssueMail    = "issuemail"    // CA authorized to issue S/MIME certificates
+)
+
+// CAAFlagCritical is the issuer critical flag: a CA that does not understand
+// the tag must refuse to issue
+const CAAFlagCritical uint8 = 0x80
+
+// CAARecord represents a CAA record (certification authority authorization)
+type CAARecord struct {
+	BaseRecord
+	flags uint8  // Flags, only the issuer critical bit is defined
+	tag   string // Property tag, e.g. "issue"
+	value string // Property value
+}
+
+// NewCAARecord creates a new CAA record
+func NewCAARecord(name, tag, value string, flags uint8, ttl uint32) *CAARecord {
+	return &CAARecord{
+		BaseRecord: NewBaseRecord(name, types.CLASS_IN, ttl),
+		flags:      flags,
+		tag:        tag,
+		value:      value,
+	}
+}
+
+// IsRegisteredCAATag reports whether tag is a property tag registered with
+// IANA. Tags are matched case-insensitively
+func IsRegisteredCAATag(tag string) bool {
+	switch strings.ToLower(tag) {
+	case CAATagIssue, CAATagIssueWild, CAATagIODEF,
+		CAATagContactEmail, CAATagContactPhone, CAATagIssueVMC, CAATagIssueMail:
+		return true
+	default:
+		return false
+	}
+}
+
+// Type returns the DNS record type
+func (r *CAARecord) Type() types.DNSType {
+	return types.TYPE_CAA
+}
+
+// Flags returns the record flags
+func (r *CAARecord) Flags() uint8 {
+	return r.flags
+}
+
+// Critical reports whether the issuer critical flag is set
+func (r *CAARecord) Critical() bool {
+	return r.flags&CAAFlagCritical != 0
+}
+
+// Tag returns the property tag
+func (r *CAARecord) Tag() string {
+	return r.tag
+}
+
+// Value returns the property value
+func (r *CAARecord) Value() string {
+	return r.value
+}
+
+// Data returns the flags, tag and value as bytes
+func (r *CAARecord) Data() []byte {
+	// Format: 1 byte flags + 1 byte tag length + tag + value (rest of RDATA)
+	data := make([]byte, 0, 2+len(r.tag)+len(r.value))
+	data = append(data, r.flags, byte(len(r.tag)))
+	data = append(data, r.tag...)
+	data = append(data, r.value...)
+	return data
+}
+
+// String returns a string representation of the CAA record
+func (r *CAARecord) String() string {
+	return fmt.Sprintf("%s %d IN CAA %d %s %q", r.name, r.ttl, r.flags, r.tag, r.value)
+}
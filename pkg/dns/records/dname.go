@@ -0,0 +1,43 @@
+package records
+
+import (
+	"fmt"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// DNAMERecord represents a DNAME record, which redirects the names below its
+// owner to the same names below the target (RFC 6672)
+type DNAMERecord struct {
+	BaseRecord
+	target string // The domain name substituted for the owner
+}
+
+// NewDNAMERecord creates a new DNAME record
+func NewDNAMERecord(name, target string, ttl uint32) *DNAMERecord {
+	return &DNAMERecord{
+		BaseRecord: NewBaseRecord(name, types.CLASS_IN, ttl),
+		target:     target,
+	}
+}
+
+// Type returns the DNS record type
+func (r *DNAMERecord) Type() types.DNSType {
+	return types.TYPE_DNAME
+}
+
+// Target returns the domain name substituted for the owner
+func (r *DNAMERecord) Target() string {
+	return r.target
+}
+
+// Data returns the target domain name in wire format. It is never
+// compressed (RFC 6672 §2.5)
+func (r *DNAMERecord) Data() []byte {
+	return encodeName(r.target)
+}
+
+// String returns a string representation of the DNAME record
+func (r *DNAMERecord) String() string {
+	return fmt.Sprintf("%s %d IN DNAME %s", r.name, r.ttl, r.target)
+}
@@ -0,0 +1,107 @@
+package records
+
+import (
+	"encoding/base64"
+	"fmt"
+	"slices"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// DNSKEY flags (RFC 4034 §2.1.1)
+const (
+	DNSKEYFlagZone uint16 = 0x0100 // The key signs zone data
+	DNSKEYFlagSEP  uint16 = 0x0001 // Secure entry point, usually a key-signing key
+)
+
+// DNSKEYProtocol is the only valid value of the protocol field (RFC 4034 §2.1.2)
+const DNSKEYProtocol uint8 = 3
+
+// DNSKEYRecord represents a DNSKEY record, a public key of a signed zone
+type DNSKEYRecord struct {
+	BaseRecord
+	flags     uint16 // Zone and SEP flags
+	protocol  uint8  // Always 3
+	algorithm uint8  // DNSSEC algorithm number of the key
+	publicKey []byte // Key material in the format of the algorithm
+}
+
+// NewDNSKEYRecord creates a new DNSKEY record. The protocol must be 3 and
+// the key must not be empty
+func NewDNSKEYRecord(name string, flags uint16, protocol, algorithm uint8, publicKey []byte, ttl uint32) (*DNSKEYRecord, error) {
+	switch {
+	case protocol != DNSKEYProtocol:
+		return nil, fmt.Errorf("DNSKEY protocol must be %d, got %d", DNSKEYProtocol, protocol)
+	case len(publicKey) == 0:
+		return nil, fmt.Errorf("empty DNSKEY public key")
+	}
+
+	return &DNSKEYRecord{
+		BaseRecord: NewBaseRecord(name, types.CLASS_IN, ttl),
+		flags:      flags,
+		protocol:   protocol,
+		algorithm:  algorithm,
+		publicKey:  slices.Clone(publicKey),
+	}, nil
+}
+
+// Type returns the DNS record type
+func (r *DNSKEYRecord) Type() types.DNSType {
+	return types.TYPE_DNSKEY
+}
+
+// Flags returns the key flags
+func (r *DNSKEYRecord) Flags() uint16 {
+	return r.flags
+}
+
+// Protocol returns the protocol field
+func (r *DNSKEYRecord) Protocol() uint8 {
+	return r.protocol
+}
+
+// Algorithm returns the DNSSEC algorithm number
+func (r *DNSKEYRecord) Algorithm() uint8 {
+	return r.algorithm
+}
+
+// PublicKey returns the key material
+func (r *DNSKEYRecord) PublicKey() []byte {
+	return r.publicKey
+}
+
+// KeyTag returns the tag identifying the key in DS and RRSIG records,
+// computed over the RDATA as described in RFC 4034 Appendix B
+func (r *DNSKEYRecord) KeyTag() uint16 {
+	if r.algorithm == 1 && len(r.publicKey) >= 3 {
+		// RSA/MD5 keys are tagged by bits of their modulus instead (Appendix B.1)
+		return uint16(r.publicKey[len(r.publicKey)-3])<<8 | uint16(r.publicKey[len(r.publicKey)-2])
+	}
+
+	var sum uint32
+	for i, b := range r.Data() {
+		if i%2 == 0 {
+			sum += uint32(b) << 8
+		} else {
+			sum += uint32(b)
+		}
+	}
+	sum += (sum >> 16) & 0xFFFF
+	return uint16(sum)
+}
+
+// Data returns the flags, protocol, algorithm and public key as bytes
+func (r *DNSKEYRecord) Data() []byte {
+	// Format: 2 bytes flags + 1 byte protocol + 1 byte algorithm + key
+	data := make([]byte, 0, 4+len(r.publicKey))
+	data = append(data, byte(r.flags>>8), byte(r.flags))
+	data = append(data, r.protocol, r.algorithm)
+	data = append(data, r.publicKey...)
+	return data
+}
+
+// String returns a string representation of the DNSKEY record
+func (r *DNSKEYRecord) String() string {
+	return fmt.Sprintf("%s %d IN DNSKEY %d %d %d %s",
+		r.name, r.ttl, r.flags, r.protocol, r.algorithm, base64.StdEncoding.EncodeToString(r.publicKey))
+}
@@ -0,0 +1,120 @@
+package records
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// DS digest types (RFC 4034 §5.1.3, RFC 4509, RFC 6605)
+const (
+	DSDigestSHA1   uint8 = 1 // SHA-1
+	DSDigestSHA256 uint8 = 2 // SHA-256
+	DSDigestSHA384 uint8 = 4 // SHA-384
+)
+
+// DSRecord represents a DS record, the delegation signer pointing a parent
+// zone at a DNSKEY of its child
+type DSRecord struct {
+	BaseRecord
+	keyTag     uint16 // Tag of the DNSKEY the digest covers
+	algorithm  uint8  // DNSSEC algorithm number of that key
+	digestType uint8  // Hash function of the digest
+	digest     []byte // Digest of the owner name and DNSKEY RDATA
+}
+
+// NewDSRecord creates a new DS record. Digests of known types must have the
+// length of their hash function
+func NewDSRecord(name string, keyTag uint16, algorithm, digestType uint8, digest []byte, ttl uint32) (*DSRecord, error) {
+	switch {
+	case len(digest) == 0:
+		return nil, fmt.Errorf("empty DS digest")
+	case digestType == DSDigestSHA1 && len(digest) != sha1.Size:
+		return nil, fmt.Errorf("SHA-1 DS digest must be %d bytes, got %d", sha1.Size, len(digest))
+	case digestType == DSDigestSHA256 && len(digest) != sha256.Size:
+		return nil, fmt.Errorf("SHA-256 DS digest must be %d bytes, got %d", sha256.Size, len(digest))
+	case digestType == DSDigestSHA384 && len(digest) != sha512.Size384:
+		return nil, fmt.Errorf("SHA-384 DS digest must be %d bytes, got %d", sha512.Size384, len(digest))
+	}
+
+	return &DSRecord{
+		BaseRecord: NewBaseRecord(name, types.CLASS_IN, ttl),
+		keyTag:     keyTag,
+		algorithm:  algorithm,
+		digestType: digestType,
+		digest:     slices.Clone(digest),
+	}, nil
+}
+
+// NewDSRecordFromDNSKEY creates the DS record delegating to key, hashing its
+// owner name and RDATA with the given digest type (RFC 4034 §5.1.4)
+func NewDSRecordFromDNSKEY(key *DNSKEYRecord, digestType uint8, ttl uint32) (*DSRecord, error) {
+	owner := encodeName(strings.ToLower(key.Name()))
+	if owner == nil {
+		return nil, fmt.Errorf("invalid DNSKEY owner name %q", key.Name())
+	}
+	signed := append(owner, key.Data()...)
+
+	var digest []byte
+	switch digestType {
+	case DSDigestSHA1:
+		sum := sha1.Sum(signed)
+		digest = sum[:]
+	case DSDigestSHA256:
+		sum := sha256.Sum256(signed)
+		digest = sum[:]
+	case DSDigestSHA384:
+		sum := sha512.Sum384(signed)
+		digest = sum[:]
+	default:
+		return nil, fmt.Errorf("unknown DS digest type %d", digestType)
+	}
+
+	return NewDSRecord(key.Name(), key.KeyTag(), key.Algorithm(), digestType, digest, ttl)
+}
+
+// Type returns the DNS record type
+func (r *DSRecord) Type() types.DNSType {
+	return types.TYPE_DS
+}
+
+// KeyTag returns the tag of the referenced DNSKEY
+func (r *DSRecord) KeyTag() uint16 {
+	return r.keyTag
+}
+
+// Algorithm returns the DNSSEC algorithm number of the referenced DNSKEY
+func (r *DSRecord) Algorithm() uint8 {
+	return r.algorithm
+}
+
+// DigestType returns the digest type
+func (r *DSRecord) DigestType() uint8 {
+	return r.digestType
+}
+
+// Digest returns the digest
+func (r *DSRecord) Digest() []byte {
+	return r.digest
+}
+
+// Data returns the key tag, algorithm, digest type and digest as bytes
+func (r *DSRecord) Data() []byte {
+	// Format: 2 bytes key tag + 1 byte algorithm + 1 byte digest type + digest
+	data := make([]byte, 0, 4+len(r.digest))
+	data = append(data, byte(r.keyTag>>8), byte(r.keyTag))
+	data = append(data, r.algorithm, r.digestType)
+	data = append(data, r.digest...)
+	return data
+}
+
+// String returns a string representation of the DS record
+func (r *DSRecord) String() string {
+	return fmt.Sprintf("%s %d IN DS %d %d %d %X",
+		r.name, r.ttl, r.keyTag, r.algorithm, r.digestType, r.digest)
+}
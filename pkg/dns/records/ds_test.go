@@ -0,0 +1,128 @@
+package records
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// dskeyExample returns the DNSKEY of the example in RFC 4034 §5.4
+func dskeyExample(t *testing.T) *DNSKEYRecord {
+	t.Helper()
+	publicKey, err := base64.StdEncoding.DecodeString("AQOeiiR0GOMYkDshWoSKz9XzfwJr1AYtsmx3TGkJaNXVbfi/" +
+		"2pHm822aJ5iI9BMzNXxeYCmZDRD99WYwYqUSdjMmmAphXdvxegXd/M5+X7OrzKBaMbCVdFLU" +
+		"Uh6DhweJBjEVv5f2wwjM9XzcnOf+EPbtG9DMBmADjFDc2w/rljwvFw==")
+	if err != nil {
+		t.Fatalf("failed to decode key: %v", err)
+	}
+	key, err := NewDNSKEYRecord("dskey.example.com.", DNSKEYFlagZone, DNSKEYProtocol, 5, publicKey, 86400)
+	if err != nil {
+		t.Fatalf("NewDNSKEYRecord() returned error: %v", err)
+	}
+	return key
+}
+
+func TestNewDNSKEYRecord(t *testing.T) {
+	tests := []struct {
+		name        string
+		protocol    uint8
+		key         []byte
+		expectedErr string
+	}{
+		{name: "valid", protocol: DNSKEYProtocol, key: []byte{0x03, 0x01, 0x00, 0x01}},
+		{name: "other protocol", protocol: 1, key: []byte{0x03}, expectedErr: "DNSKEY protocol must be 3"},
+		{name: "empty key", protocol: DNSKEYProtocol, expectedErr: "empty DNSKEY public key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewDNSKEYRecord("example.com.", DNSKEYFlagZone|DNSKEYFlagSEP, tt.protocol, 13, tt.key, 3600)
+			if tt.expectedErr == "" {
+				if err != nil {
+					t.Errorf("NewDNSKEYRecord() returned error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("NewDNSKEYRecord() error = %v, expected %q", err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestDNSKEYRecord(t *testing.T) {
+	key := dskeyExample(t)
+
+	if tag := key.KeyTag(); tag != 60485 {
+		t.Errorf("KeyTag() = %d, expected 60485", tag)
+	}
+	if data := key.Data(); !bytes.Equal(data[:4], []byte{0x01, 0x00, 0x03, 0x05}) || !bytes.Equal(data[4:], key.PublicKey()) {
+		t.Errorf("Data() = %X, expected flags, protocol and algorithm before the key", data)
+	}
+	expected := "dskey.example.com. 86400 IN DNSKEY 256 3 5 AQOeiiR0GOMYkDshWoSKz9Xz"
+	if got := key.String(); !strings.HasPrefix(got, expected) {
+		t.Errorf("String() = %q, expected it to start with %q", got, expected)
+	}
+}
+
+func TestNewDSRecord(t *testing.T) {
+	tests := []struct {
+		name        string
+		digestType  uint8
+		digest      []byte
+		expectedErr string
+	}{
+		{name: "SHA-256", digestType: DSDigestSHA256, digest: make([]byte, sha256.Size)},
+		{name: "SHA-384", digestType: DSDigestSHA384, digest: make([]byte, 48)},
+		{name: "unknown digest type", digestType: 200, digest: []byte{0x01}},
+		{name: "empty digest", digestType: DSDigestSHA256, expectedErr: "empty DS digest"},
+		{name: "short SHA-1 digest", digestType: DSDigestSHA1, digest: make([]byte, 19), expectedErr: "SHA-1 DS digest must be 20 bytes"},
+		{name: "long SHA-256 digest", digestType: DSDigestSHA256, digest: make([]byte, 33), expectedErr: "SHA-256 DS digest must be 32 bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewDSRecord("example.com.", 12345, 13, tt.digestType, tt.digest, 3600)
+			if tt.expectedErr == "" {
+				if err != nil {
+					t.Errorf("NewDSRecord() returned error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("NewDSRecord() error = %v, expected %q", err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestNewDSRecordFromDNSKEY(t *testing.T) {
+	ds, err := NewDSRecordFromDNSKEY(dskeyExample(t), DSDigestSHA1, 86400)
+	if err != nil {
+		t.Fatalf("NewDSRecordFromDNSKEY() returned error: %v", err)
+	}
+
+	expected := "dskey.example.com. 86400 IN DS 60485 5 1 2BB183AF5F22588179A53B0A98631FAD1A292118"
+	if got := ds.String(); got != expected {
+		t.Errorf("String() = %q, expected %q", got, expected)
+	}
+	if data := ds.Data(); hex.EncodeToString(data[:4]) != "ec450501" || !bytes.Equal(data[4:], ds.Digest()) {
+		t.Errorf("Data() = %X, expected key tag, algorithm and digest type before the digest", data)
+	}
+
+	// The owner name is hashed in its canonical lowercase form
+	upper, err := NewDNSKEYRecord("DSKEY.Example.COM.", DNSKEYFlagZone, DNSKEYProtocol, 5, dskeyExample(t).PublicKey(), 86400)
+	if err != nil {
+		t.Fatalf("NewDNSKEYRecord() returned error: %v", err)
+	}
+	if ds, _ := NewDSRecordFromDNSKEY(upper, DSDigestSHA1, 86400); !strings.HasSuffix(ds.String(), "2BB183AF5F22588179A53B0A98631FAD1A292118") {
+		t.Errorf("digest of a mixed case owner = %s", ds)
+	}
+
+	if _, err := NewDSRecordFromDNSKEY(dskeyExample(t), 3, 86400); err == nil {
+		t.Error("NewDSRecordFromDNSKEY() accepted an unknown digest type")
+	}
+}
@@ -0,0 +1,92 @@
+package records
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// GenericRecord represents a record of a type without a typed
+// representation, carrying its RDATA as opaque bytes (RFC 3597)
+type GenericRecord struct {
+	BaseRecord
+	recordType types.DNSType
+	rdata      []byte
+}
+
+// NewGenericRecord creates a new record of recordType with the RDATA rdata
+func NewGenericRecord(name string, recordType types.DNSType, rdata []byte, ttl uint32) *GenericRecord {
+	return &GenericRecord{
+		BaseRecord: NewBaseRecord(name, types.CLASS_IN, ttl),
+		recordType: recordType,
+		rdata:      append([]byte{}, rdata...),
+	}
+}
+
+// Type returns the DNS record type
+func (r *GenericRecord) Type() types.DNSType {
+	return r.recordType
+}
+
+// Data returns the RDATA as it was given
+func (r *GenericRecord) Data() []byte {
+	return r.rdata
+}
+
+// String returns the record in presentation format
+func (r *GenericRecord) String() string {
+	return r.Presentation()
+}
+
+// Presentation returns the record in presentation format, with the RDATA in
+// the generic form of RFC 3597
+func (r *GenericRecord) Presentation() string {
+	return r.presentation(r.recordType, FormatGenericRData(r.rdata))
+}
+
+// FormatGenericRData returns rdata in the generic form of RFC 3597: "\#",
+// its length and its bytes in hex, such as `\# 4 0A000001`
+func FormatGenericRData(rdata []byte) string {
+	if len(rdata) == 0 {
+		return `\# 0`
+	}
+	return fmt.Sprintf(`\# %d %X`, len(rdata), rdata)
+}
+
+// IsGenericRData reports whether rdata is written in the generic form of
+// RFC 3597
+func IsGenericRData(rdata string) bool {
+	fields := strings.Fields(rdata)
+	return len(fields) > 0 && fields[0] == `\#`
+}
+
+// ParseGenericRData parses RDATA in the generic form of RFC 3597. The hex
+// may be split by whitespace and must hold exactly the length given
+func ParseGenericRData(rdata string) ([]byte, error) {
+	fields := strings.Fields(rdata)
+	if len(fields) < 2 || fields[0] != `\#` {
+		return nil, fmt.Errorf(`expected \# and the RDATA length`)
+	}
+
+	length, err := strconv.ParseUint(fields[1], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RDATA length %q", fields[1])
+	}
+	data, err := hex.DecodeString(strings.Join(fields[2:], ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RDATA hex: %w", err)
+	}
+	if len(data) != int(length) {
+		return nil, fmt.Errorf("RDATA length is %d, but %d bytes are given", length, len(data))
+	}
+	return data, nil
+}
+
+// IsMetaType reports whether recordType is a QTYPE or meta type, such as
+// OPT, AXFR or ANY, that records stored in a zone cannot have (RFC 6895 §3.1)
+func IsMetaType(recordType types.DNSType) bool {
+	return recordType == 0 || recordType == types.TYPE_OPT || (recordType >= 128 && recordType <= 255)
+}
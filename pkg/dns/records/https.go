@@ -0,0 +1,28 @@
+package records
+
+import (
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// HTTPSRecord represents an HTTPS record (RFC 9460 §9). It shares the SVCB
+// RDATA format and differs only in its type
+type HTTPSRecord struct {
+	SVCBRecord
+}
+
+// NewHTTPSRecord creates a new HTTPS record. Parameters are stored ordered by key
+func NewHTTPSRecord(name, targetName string, priority uint16, params []SvcParam, ttl uint32) *HTTPSRecord {
+	return &HTTPSRecord{
+		SVCBRecord: *NewSVCBRecord(name, targetName, priority, params, ttl),
+	}
+}
+
+// Type returns the DNS record type
+func (r *HTTPSRecord) Type() types.DNSType {
+	return types.TYPE_HTTPS
+}
+
+// String returns a string representation of the HTTPS record
+func (r *HTTPSRecord) String() string {
+	return r.format("HTTPS")
+}
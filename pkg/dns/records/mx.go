@@ -1,8 +1,6 @@
 package records
 
 import (
-	"fmt"
-
 	"github.com/vadim-su/dnska/pkg/dns/types"
 )
 
@@ -44,13 +42,11 @@ func (r *MXRecord) Data() []byte {
 	data[0] = byte(r.preference >> 8)
 	data[1] = byte(r.preference & 0xFF)
 
-	// This would need proper DNS name encoding for the mail server
-	// For now, append the string as bytes
-	data = append(data, []byte(r.mailServer)...)
+	data = append(data, encodeName(r.mailServer)...)
 	return data
 }
 
-// String returns a string representation of the MX record
+// String returns the record in presentation format
 func (r *MXRecord) String() string {
-	return fmt.Sprintf("%s %d IN MX %d %s", r.name, r.ttl, r.preference, r.mailServer)
+	return r.Presentation()
 }
@@ -0,0 +1,90 @@
+package records
+
+import (
+	"fmt"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// NAPTRRecord represents a NAPTR record (naming authority pointer, RFC 2915)
+type NAPTRRecord struct {
+	BaseRecord
+	order       uint16 // Processing order (lower first)
+	preference  uint16 // Preference among records of equal order
+	flags       string // Rewrite flags, e.g. "U" or "S"
+	services    string // Service parameters, e.g. "E2U+sip"
+	regexp      string // Substitution expression applied to the client string
+	replacement string // Next name to look up when regexp is empty
+}
+
+// NewNAPTRRecord creates a new NAPTR record
+func NewNAPTRRecord(name string, order, preference uint16, flags, services, regexp, replacement string, ttl uint32) *NAPTRRecord {
+	return &NAPTRRecord{
+		BaseRecord:  NewBaseRecord(name, types.CLASS_IN, ttl),
+		order:       order,
+		preference:  preference,
+		flags:       flags,
+		services:    services,
+		regexp:      regexp,
+		replacement: replacement,
+	}
+}
+
+// Type returns the DNS record type
+func (r *NAPTRRecord) Type() types.DNSType {
+	return types.TYPE_NAPTR
+}
+
+// Order returns the processing order
+func (r *NAPTRRecord) Order() uint16 {
+	return r.order
+}
+
+// Preference returns the preference among records of equal order
+func (r *NAPTRRecord) Preference() uint16 {
+	return r.preference
+}
+
+// Flags returns the rewrite flags
+func (r *NAPTRRecord) Flags() string {
+	return r.flags
+}
+
+// Services returns the service parameters
+func (r *NAPTRRecord) Services() string {
+	return r.services
+}
+
+// Regexp returns the substitution expression
+func (r *NAPTRRecord) Regexp() string {
+	return r.regexp
+}
+
+// Replacement returns the replacement domain name
+func (r *NAPTRRecord) Replacement() string {
+	return r.replacement
+}
+
+// Data returns the NAPTR data as bytes
+func (r *NAPTRRecord) Data() []byte {
+	// Format: 2 bytes order + 2 bytes preference + flags, services and regexp
+	// as character strings + uncompressed replacement name
+	data := []byte{
+		byte(r.order >> 8), byte(r.order & 0xFF),
+		byte(r.preference >> 8), byte(r.preference & 0xFF),
+	}
+
+	for _, text := range []string{r.flags, r.services, r.regexp} {
+		data = append(data, byte(len(text)))
+		data = append(data, text...)
+	}
+
+	data = append(data, encodeName(r.replacement)...)
+	return data
+}
+
+// String returns a string representation of the NAPTR record
+func (r *NAPTRRecord) String() string {
+	return fmt.Sprintf("%s %d IN NAPTR %d %d %q %q %q %s",
+		r.name, r.ttl, r.order, r.preference, r.flags, r.services, r.regexp, r.replacement)
+}
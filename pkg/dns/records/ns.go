@@ -1,8 +1,6 @@
 package records
 
 import (
-	"fmt"
-
 	"github.com/vadim-su/dnska/pkg/dns/types"
 )
 
@@ -30,14 +28,12 @@ func (r *NSRecord) NameServer() string {
 	return r.nameServer
 }
 
-// Data returns the name server domain name as bytes
+// Data returns the name server domain name in wire format
 func (r *NSRecord) Data() []byte {
-	// This would need proper DNS name encoding
-	// For now, return the string as bytes
-	return []byte(r.nameServer)
+	return encodeName(r.nameServer)
 }
 
-// String returns a string representation of the NS record
+// String returns the record in presentation format
 func (r *NSRecord) String() string {
-	return fmt.Sprintf("%s %d IN NS %s", r.name, r.ttl, r.nameServer)
+	return r.Presentation()
 }
@@ -0,0 +1,125 @@
+package records
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// NSECRecord represents an NSEC record, linking an owner name to the next
+// name of the zone and listing the types present at the owner
+type NSECRecord struct {
+	BaseRecord
+	nextName string          // Next owner name in canonical order
+	types    []types.DNSType // Types at the owner, ascending and unique
+}
+
+// NewNSECRecord creates a new NSEC record. The type list is sorted and
+// duplicates are dropped
+func NewNSECRecord(name, nextName string, recordTypes []types.DNSType, ttl uint32) *NSECRecord {
+	if nextName != "" && nextName[len(nextName)-1] != '.' {
+		nextName += "."
+	}
+
+	sorted := slices.Clone(recordTypes)
+	slices.Sort(sorted)
+
+	return &NSECRecord{
+		BaseRecord: NewBaseRecord(name, types.CLASS_IN, ttl),
+		nextName:   nextName,
+		types:      slices.Compact(sorted),
+	}
+}
+
+// Type returns the DNS record type
+func (r *NSECRecord) Type() types.DNSType {
+	return types.TYPE_NSEC
+}
+
+// NextName returns the next owner name of the zone
+func (r *NSECRecord) NextName() string {
+	return r.nextName
+}
+
+// Types returns the types present at the owner name
+func (r *NSECRecord) Types() []types.DNSType {
+	return r.types
+}
+
+// Data returns the next name, uncompressed, followed by the type bitmap
+func (r *NSECRecord) Data() []byte {
+	nextName := encodeName(r.nextName)
+	if nextName == nil {
+		return nil
+	}
+	return append(nextName, EncodeTypeBitmap(r.types)...)
+}
+
+// String returns a string representation of the NSEC record
+func (r *NSECRecord) String() string {
+	mnemonics := make([]string, len(r.types))
+	for i, recordType := range r.types {
+		mnemonics[i] = recordType.Mnemonic()
+	}
+	return fmt.Sprintf("%s %d IN NSEC %s %s", r.name, r.ttl, r.nextName, strings.Join(mnemonics, " "))
+}
+
+// EncodeTypeBitmap encodes recordTypes as the type bitmap of NSEC records
+// (RFC 4034 §4.1.2). The 65536 types are split into 256 windows by their
+// high byte; each window present is written as its number, the length of
+// its bitmap and the bitmap, in which type low byte N is bit 0x80>>(N%8) of
+// byte N/8. Trailing zero bytes of a bitmap are left out
+func EncodeTypeBitmap(recordTypes []types.DNSType) []byte {
+	sorted := slices.Clone(recordTypes)
+	slices.Sort(sorted)
+
+	var data []byte
+	for i := 0; i < len(sorted); {
+		window := byte(sorted[i] >> 8)
+		var bitmap [32]byte
+		length := 0
+		for ; i < len(sorted) && byte(sorted[i]>>8) == window; i++ {
+			low := byte(sorted[i])
+			bitmap[low/8] |= 0x80 >> (low % 8)
+			length = int(low/8) + 1
+		}
+		data = append(data, window, byte(length))
+		data = append(data, bitmap[:length]...)
+	}
+	return data
+}
+
+// DecodeTypeBitmap decodes an NSEC type bitmap into the types it lists in
+// ascending order. Windows out of order, bitmaps of a length outside 1-32
+// and truncated data are rejected
+func DecodeTypeBitmap(data []byte) ([]types.DNSType, error) {
+	var recordTypes []types.DNSType
+	previous := -1
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return nil, fmt.Errorf("truncated type bitmap window header")
+		}
+		window, length := int(data[0]), int(data[1])
+		switch {
+		case window <= previous:
+			return nil, fmt.Errorf("type bitmap window %d follows window %d", window, previous)
+		case length < 1 || length > 32:
+			return nil, fmt.Errorf("invalid type bitmap length %d in window %d", length, window)
+		case len(data) < 2+length:
+			return nil, fmt.Errorf("truncated type bitmap in window %d", window)
+		}
+
+		for i, b := range data[2 : 2+length] {
+			for bit := range 8 {
+				if b&(0x80>>bit) != 0 {
+					recordTypes = append(recordTypes, types.DNSType(window<<8|i*8+bit))
+				}
+			}
+		}
+		previous = window
+		data = data[2+length:]
+	}
+	return recordTypes, nil
+}
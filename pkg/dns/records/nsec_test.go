@@ -0,0 +1,99 @@
+package records
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+func TestEncodeTypeBitmap(t *testing.T) {
+	tests := []struct {
+		name     string
+		types    []types.DNSType
+		expected []byte
+	}{
+		{name: "no types"},
+		{
+			// The example of RFC 4034 §4.3
+			name:  "two windows",
+			types: []types.DNSType{types.TYPE_A, types.TYPE_MX, types.TYPE_RRSIG, types.TYPE_NSEC, 1234},
+			expected: append(
+				[]byte{0x00, 0x06, 0x40, 0x01, 0x00, 0x00, 0x00, 0x03, 0x04, 0x1b},
+				append(make([]byte, 26), 0x20)...),
+		},
+		{
+			name:     "unsorted with duplicates",
+			types:    []types.DNSType{types.TYPE_AAAA, types.TYPE_A, types.TYPE_AAAA},
+			expected: []byte{0x00, 0x04, 0x40, 0x00, 0x00, 0x08},
+		},
+		{name: "type 0", types: []types.DNSType{0}, expected: []byte{0x00, 0x01, 0x80}},
+		{name: "last bit of a window", types: []types.DNSType{255}, expected: append([]byte{0x00, 0x20}, append(make([]byte, 31), 0x01)...)},
+		{name: "CAA", types: []types.DNSType{types.TYPE_CAA}, expected: []byte{0x01, 0x01, 0x40}},
+		{name: "last type", types: []types.DNSType{65535}, expected: append([]byte{0xFF, 0x20}, append(make([]byte, 31), 0x01)...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := EncodeTypeBitmap(tt.types)
+			if !bytes.Equal(data, tt.expected) {
+				t.Fatalf("EncodeTypeBitmap() = %X, expected %X", data, tt.expected)
+			}
+
+			decoded, err := DecodeTypeBitmap(data)
+			if err != nil {
+				t.Fatalf("DecodeTypeBitmap() returned error: %v", err)
+			}
+			expected := slices.Compact(slices.Sorted(slices.Values(tt.types)))
+			if !slices.Equal(decoded, expected) {
+				t.Errorf("DecodeTypeBitmap() = %v, expected %v", decoded, expected)
+			}
+		})
+	}
+}
+
+func TestDecodeTypeBitmap_Invalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		expectedErr string
+	}{
+		{name: "truncated header", data: []byte{0x00}, expectedErr: "truncated type bitmap window header"},
+		{name: "empty bitmap", data: []byte{0x00, 0x00}, expectedErr: "invalid type bitmap length 0"},
+		{name: "bitmap too long", data: append([]byte{0x00, 0x21}, make([]byte, 33)...), expectedErr: "invalid type bitmap length 33"},
+		{name: "truncated bitmap", data: []byte{0x00, 0x02, 0x40}, expectedErr: "truncated type bitmap"},
+		{name: "windows out of order", data: []byte{0x01, 0x01, 0x40, 0x00, 0x01, 0x40}, expectedErr: "window 0 follows window 1"},
+		{name: "repeated window", data: []byte{0x00, 0x01, 0x40, 0x00, 0x01, 0x20}, expectedErr: "window 0 follows window 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeTypeBitmap(tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("DecodeTypeBitmap() error = %v, expected %q", err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestNSECRecord(t *testing.T) {
+	record := NewNSECRecord("alfa.example.com", "host.example.com",
+		[]types.DNSType{types.TYPE_NSEC, types.TYPE_RRSIG, types.TYPE_MX, types.TYPE_A, 1234}, 86400)
+
+	expected := "alfa.example.com. 86400 IN NSEC host.example.com. A MX RRSIG NSEC TYPE1234"
+	if got := record.String(); got != expected {
+		t.Errorf("String() = %q, expected %q", got, expected)
+	}
+
+	// The next name is never compressed and the bitmap follows it
+	nextName := []byte("\x04host\x07example\x03com\x00")
+	data := record.Data()
+	if !bytes.HasPrefix(data, nextName) {
+		t.Fatalf("Data() = %X, expected the next name %X first", data, nextName)
+	}
+	if bitmap := data[len(nextName):]; !bytes.Equal(bitmap, EncodeTypeBitmap(record.Types())) {
+		t.Errorf("Data() bitmap = %X, expected %X", bitmap, EncodeTypeBitmap(record.Types()))
+	}
+}
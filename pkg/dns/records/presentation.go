@@ -0,0 +1,303 @@
+package records
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// maxPresentationTTL is the largest TTL a record may carry (RFC 2181 §8)
+const maxPresentationTTL = 1<<31 - 1
+
+// presentation returns the record as one line of presentation format: the
+// owner, TTL, class and type followed by rdata
+func (r BaseRecord) presentation(recordType types.DNSType, rdata string) string {
+	return fmt.Sprintf("%s %d %s %s %s", absolute(r.name), r.ttl, presentClass(r.class), recordType.Mnemonic(), rdata)
+}
+
+// Presentation returns the A record in presentation format
+func (r *ARecord) Presentation() string {
+	return r.presentation(types.TYPE_A, r.ip.String())
+}
+
+// Presentation returns the AAAA record in presentation format
+func (r *AAAARecord) Presentation() string {
+	return r.presentation(types.TYPE_AAAA, r.ip.String())
+}
+
+// Presentation returns the CNAME record in presentation format
+func (r *CNAMERecord) Presentation() string {
+	return r.presentation(types.TYPE_CNAME, absolute(r.target))
+}
+
+// Presentation returns the NS record in presentation format
+func (r *NSRecord) Presentation() string {
+	return r.presentation(types.TYPE_NS, absolute(r.nameServer))
+}
+
+// Presentation returns the PTR record in presentation format
+func (r *PTRRecord) Presentation() string {
+	return r.presentation(types.TYPE_PTR, absolute(r.target))
+}
+
+// Presentation returns the MX record in presentation format
+func (r *MXRecord) Presentation() string {
+	return r.presentation(types.TYPE_MX, fmt.Sprintf("%d %s", r.preference, absolute(r.mailServer)))
+}
+
+// Presentation returns the SRV record in presentation format
+func (r *SRVRecord) Presentation() string {
+	return r.presentation(types.TYPE_SRV, fmt.Sprintf("%d %d %d %s", r.priority, r.weight, r.port, absolute(r.target)))
+}
+
+// Presentation returns the TXT record in presentation format, each
+// character string quoted and escaped
+func (r *TXTRecord) Presentation() string {
+	strs := r.Strings()
+	quoted := make([]string, len(strs))
+	for i, str := range strs {
+		quoted[i] = QuoteCharacterString(str)
+	}
+	return r.presentation(types.TYPE_TXT, strings.Join(quoted, " "))
+}
+
+// Presentation returns the SOA record in presentation format, on one line
+// with its timers in seconds
+func (r *SOARecord) Presentation() string {
+	return r.presentation(types.TYPE_SOA, fmt.Sprintf("%s %s %d %d %d %d %d",
+		absolute(r.primaryNS), absolute(r.responsible), r.serial,
+		int(r.refresh.Seconds()), int(r.retry.Seconds()),
+		int(r.expire.Seconds()), int(r.minimum.Seconds())))
+}
+
+// ParsePresentation parses one line of presentation format as Presentation
+// returns it, such as "example.com. 300 IN MX 10 mail.example.com.". The
+// owner, TTL, class and type are all required and names must be absolute,
+// as there is no origin to complete them. Other types than those with a
+// Presentation method take their RDATA in the generic form of RFC 3597,
+// such as "example.com. 300 IN TYPE65280 \# 4 0A000001"
+func ParsePresentation(line string) (DNSRecord, error) {
+	fields, rdata := cutFields(line, 4)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("expected owner, TTL, class, type and RDATA in %q", line)
+	}
+
+	owner := fields[0]
+	if !isAbsolute(owner) {
+		return nil, fmt.Errorf("owner %q is not an absolute name", owner)
+	}
+	ttl, err := strconv.ParseUint(fields[1], 10, 32)
+	if err != nil || ttl > maxPresentationTTL {
+		return nil, fmt.Errorf("invalid TTL %q", fields[1])
+	}
+	class, ok := parsePresentationClass(fields[2])
+	if !ok {
+		return nil, fmt.Errorf("invalid class %q", fields[2])
+	}
+	recordType, ok := types.ParseDNSType(fields[3])
+	if !ok {
+		return nil, fmt.Errorf("unknown type %q", fields[3])
+	}
+
+	record, err := parseRData(NewBaseRecord(owner, class, uint32(ttl)), recordType, rdata)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s record: %w", owner, recordType.Mnemonic(), err)
+	}
+	return record, nil
+}
+
+// parseRData builds the record of recordType with base from rdata in
+// presentation format
+func parseRData(base BaseRecord, recordType types.DNSType, rdata string) (DNSRecord, error) {
+	if recordType == types.TYPE_TXT {
+		strs, err := ParseCharacterStrings(rdata)
+		if err != nil {
+			return nil, err
+		}
+		return &TXTRecord{BaseRecord: base, texts: strs}, nil
+	}
+
+	fields := strings.Fields(rdata)
+	switch recordType {
+	case types.TYPE_A:
+		ip := net.ParseIP(rdata)
+		if len(fields) != 1 || ip.To4() == nil || strings.Contains(rdata, ":") {
+			return nil, fmt.Errorf("invalid IPv4 address %q", rdata)
+		}
+		return &ARecord{BaseRecord: base, ip: ip.To4()}, nil
+	case types.TYPE_AAAA:
+		ip := net.ParseIP(rdata)
+		if len(fields) != 1 || ip == nil || !strings.Contains(rdata, ":") {
+			return nil, fmt.Errorf("invalid IPv6 address %q", rdata)
+		}
+		return &AAAARecord{BaseRecord: base, ip: ip.To16()}, nil
+	case types.TYPE_CNAME:
+		if err := checkRData(fields, "target"); err != nil {
+			return nil, err
+		}
+		if err := checkNames(fields[0]); err != nil {
+			return nil, err
+		}
+		return &CNAMERecord{BaseRecord: base, target: fields[0]}, nil
+	case types.TYPE_NS:
+		if err := checkRData(fields, "name server"); err != nil {
+			return nil, err
+		}
+		if err := checkNames(fields[0]); err != nil {
+			return nil, err
+		}
+		return &NSRecord{BaseRecord: base, nameServer: fields[0]}, nil
+	case types.TYPE_PTR:
+		if err := checkRData(fields, "target"); err != nil {
+			return nil, err
+		}
+		if err := checkNames(fields[0]); err != nil {
+			return nil, err
+		}
+		return &PTRRecord{BaseRecord: base, target: fields[0]}, nil
+	case types.TYPE_MX:
+		if err := checkRData(fields, "preference", "mail server"); err != nil {
+			return nil, err
+		}
+		preference, err := strconv.ParseUint(fields[0], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid preference %q", fields[0])
+		}
+		if err := checkNames(fields[1]); err != nil {
+			return nil, err
+		}
+		return &MXRecord{BaseRecord: base, preference: uint16(preference), mailServer: fields[1]}, nil
+	case types.TYPE_SRV:
+		if err := checkRData(fields, "priority", "weight", "port", "target"); err != nil {
+			return nil, err
+		}
+		var values [3]uint16
+		for i, name := range []string{"priority", "weight", "port"} {
+			value, err := strconv.ParseUint(fields[i], 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q", name, fields[i])
+			}
+			values[i] = uint16(value)
+		}
+		record := &SRVRecord{BaseRecord: base, priority: values[0], weight: values[1], port: values[2], target: fields[3]}
+		if err := checkNames(fields[3]); err != nil {
+			return nil, err
+		}
+		return record, nil
+	case types.TYPE_SOA:
+		if err := checkRData(fields, "primary name server", "responsible mailbox", "serial", "refresh", "retry", "expire", "minimum"); err != nil {
+			return nil, err
+		}
+		var values [5]uint32
+		for i, name := range []string{"serial", "refresh", "retry", "expire", "minimum"} {
+			value, err := strconv.ParseUint(fields[2+i], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q", name, fields[2+i])
+			}
+			values[i] = uint32(value)
+		}
+		record := &SOARecord{
+			BaseRecord:  base,
+			primaryNS:   fields[0],
+			responsible: fields[1],
+			serial:      values[0],
+			refresh:     time.Duration(values[1]) * time.Second,
+			retry:       time.Duration(values[2]) * time.Second,
+			expire:      time.Duration(values[3]) * time.Second,
+			minimum:     time.Duration(values[4]) * time.Second,
+		}
+		if err := checkNames(fields[0], fields[1]); err != nil {
+			return nil, err
+		}
+		return record, nil
+	default:
+		if !IsGenericRData(rdata) {
+			return nil, fmt.Errorf(`type is only supported with RDATA in the \# form`)
+		}
+		data, err := ParseGenericRData(rdata)
+		if err != nil {
+			return nil, err
+		}
+		return &GenericRecord{BaseRecord: base, recordType: recordType, rdata: data}, nil
+	}
+}
+
+// checkRData returns an error unless there is one field per name
+func checkRData(fields []string, names ...string) error {
+	if len(fields) != len(names) {
+		return fmt.Errorf("expected %s as RDATA", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// checkNames returns an error for the first name that is not absolute
+func checkNames(names ...string) error {
+	for _, name := range names {
+		if !isAbsolute(name) {
+			return fmt.Errorf("%q is not an absolute name", name)
+		}
+	}
+	return nil
+}
+
+// cutFields splits the first n whitespace separated fields off line,
+// returning them and the rest of the line
+func cutFields(line string, n int) ([]string, string) {
+	fields := make([]string, 0, n)
+	rest := strings.TrimSpace(line)
+	for len(fields) < n && rest != "" {
+		end := strings.IndexAny(rest, " \t")
+		if end < 0 {
+			end = len(rest)
+		}
+		fields = append(fields, rest[:end])
+		rest = strings.TrimLeft(rest[end:], " \t")
+	}
+	return fields, rest
+}
+
+// isAbsolute reports whether name ends with an unescaped dot
+func isAbsolute(name string) bool {
+	if !strings.HasSuffix(name, ".") {
+		return false
+	}
+	escapes := len(name) - 1 - len(strings.TrimRight(name[:len(name)-1], "\\"))
+	return escapes%2 == 0
+}
+
+// absolute returns name with the trailing dot of an absolute name
+func absolute(name string) string {
+	if isAbsolute(name) {
+		return name
+	}
+	return name + "."
+}
+
+// presentClass returns the mnemonic of class, or the generic CLASSnnn form
+// of RFC 3597 for classes without one
+func presentClass(class types.DNSClass) string {
+	if name := class.String(); name != "UNKNOWN" {
+		return name
+	}
+	return "CLASS" + strconv.Itoa(int(class))
+}
+
+// parsePresentationClass returns the class named by a mnemonic such as
+// "IN", or by the generic CLASSnnn form, ignoring case
+func parsePresentationClass(name string) (types.DNSClass, bool) {
+	name = strings.ToUpper(name)
+	if number, ok := strings.CutPrefix(name, "CLASS"); ok {
+		value, err := strconv.ParseUint(number, 10, 16)
+		return types.DNSClass(value), err == nil
+	}
+	for _, class := range []types.DNSClass{types.CLASS_IN, types.CLASS_CS, types.CLASS_CH, types.CLASS_HS} {
+		if class.String() == name {
+			return class, true
+		}
+	}
+	return 0, false
+}
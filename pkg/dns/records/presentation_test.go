@@ -0,0 +1,127 @@
+package records
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+func TestPresentation(t *testing.T) {
+	tests := []struct {
+		name     string
+		record   DNSRecord
+		expected string
+	}{
+		{"A", NewARecord("www.example.com", net.ParseIP("192.0.2.1"), 300),
+			"www.example.com. 300 IN A 192.0.2.1"},
+		{"AAAA", NewAAAARecord("www.example.com.", net.ParseIP("2001:db8::1"), 300),
+			"www.example.com. 300 IN AAAA 2001:db8::1"},
+		{"CNAME", NewCNAMERecord("alias.example.com.", "www.example.com", 60),
+			"alias.example.com. 60 IN CNAME www.example.com."},
+		{"NS", NewNSRecord("example.com.", "ns1.example.com.", 86400),
+			"example.com. 86400 IN NS ns1.example.com."},
+		{"PTR", NewPTRRecord("1.2.0.192.in-addr.arpa.", "www.example.com.", 3600),
+			"1.2.0.192.in-addr.arpa. 3600 IN PTR www.example.com."},
+		{"MX", NewMXRecord("example.com.", "mail.example.com.", 10, 300),
+			"example.com. 300 IN MX 10 mail.example.com."},
+		{"SRV", NewSRVRecord("_sip._tcp.example.com.", "sip.example.com.", 10, 20, 5060, 300),
+			"_sip._tcp.example.com. 300 IN SRV 10 20 5060 sip.example.com."},
+		{"SOA", NewSOARecord("example.com.", "ns1.example.com.", "hostmaster.example.com.",
+			2024010101, time.Hour, 10*time.Minute, 7*24*time.Hour, 5*time.Minute, 3600),
+			"example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 2024010101 3600 600 604800 300"},
+		{"TXT", NewTXTRecordFromStrings("example.com.", []string{"v=spf1 -all", "second"}, 300),
+			`example.com. 300 IN TXT "v=spf1 -all" "second"`},
+		{"TXT quotes and backslashes", NewTXTRecordFromString("example.com.", `say "hi" \ bye`, 300),
+			`example.com. 300 IN TXT "say \"hi\" \\ bye"`},
+		{"TXT semicolon", NewTXTRecordFromString("example.com.", "a; b", 300),
+			`example.com. 300 IN TXT "a; b"`},
+		{"TXT non-ASCII and control bytes", NewTXTRecordFromString("example.com.", "caf\xc3\xa9\ttab", 300),
+			`example.com. 300 IN TXT "caf\195\169\009tab"`},
+		{"TXT empty string", NewTXTRecordFromString("example.com.", "", 300),
+			`example.com. 300 IN TXT ""`},
+		{"TXT over 255 bytes", NewTXTRecordFromString("example.com.", strings.Repeat("a", 300), 300),
+			`example.com. 300 IN TXT "` + strings.Repeat("a", 255) + `" "` + strings.Repeat("a", 45) + `"`},
+		{"generic", NewGenericRecord("example.com.", types.DNSType(65280), []byte{0x0a, 0x00, 0x00, 0x01}, 300),
+			`example.com. 300 IN TYPE65280 \# 4 0A000001`},
+		{"generic empty", NewGenericRecord("example.com.", types.DNSType(65280), nil, 300),
+			`example.com. 300 IN TYPE65280 \# 0`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			presenter, ok := tt.record.(interface{ Presentation() string })
+			if !ok {
+				t.Fatalf("%T has no Presentation method", tt.record)
+			}
+			if got := presenter.Presentation(); got != tt.expected {
+				t.Errorf("Presentation() = %s\nexpected       %s", got, tt.expected)
+			}
+			if got := tt.record.String(); got != tt.expected {
+				t.Errorf("String() = %s, expected the presentation format", got)
+			}
+
+			parsed, err := ParsePresentation(tt.expected)
+			if err != nil {
+				t.Fatalf("ParsePresentation() returned error: %v", err)
+			}
+			if parsed.Type() != tt.record.Type() || string(parsed.Data()) != string(tt.record.Data()) {
+				t.Errorf("ParsePresentation() = %v, expected the record back", parsed)
+			}
+			if got := parsed.String(); got != tt.expected {
+				t.Errorf("ParsePresentation() presented as %s, expected %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParsePresentation(t *testing.T) {
+	record, err := ParsePresentation("  WWW.Example.com.\t60 ch txt unquoted words  ")
+	if err != nil {
+		t.Fatalf("ParsePresentation() returned error: %v", err)
+	}
+	txt, ok := record.(*TXTRecord)
+	if !ok || txt.Class() != types.CLASS_CH || txt.TTL() != 60 || txt.Name() != "WWW.Example.com." {
+		t.Fatalf("ParsePresentation() = %v, expected a CH TXT record of WWW.Example.com. with TTL 60", record)
+	}
+	if strs := txt.Strings(); len(strs) != 2 || strs[0] != "unquoted" || strs[1] != "words" {
+		t.Errorf("TXT strings = %q, expected unquoted and words", strs)
+	}
+
+	record, err = ParsePresentation(`example.com. 300 IN TYPE65280 \# 4 0A00 0001`)
+	if err != nil {
+		t.Fatalf("ParsePresentation() returned error: %v", err)
+	}
+	if generic, ok := record.(*GenericRecord); !ok || generic.Type() != 65280 || string(generic.Data()) != "\x0a\x00\x00\x01" {
+		t.Errorf("ParsePresentation() = %v, expected TYPE65280 RDATA split across words", record)
+	}
+
+	errors := []string{
+		"",
+		"example.com. 300 IN A",
+		"example.com 300 IN A 192.0.2.1",
+		"example.com. 2147483648 IN A 192.0.2.1",
+		"example.com. 1h IN A 192.0.2.1",
+		"example.com. 300 XX A 192.0.2.1",
+		"example.com. 300 IN BOGUS 192.0.2.1",
+		"example.com. 300 IN A 2001:db8::1",
+		"example.com. 300 IN AAAA 192.0.2.1",
+		"example.com. 300 IN CNAME www",
+		"example.com. 300 IN MX mail.example.com.",
+		"example.com. 300 IN MX 65536 mail.example.com.",
+		"example.com. 300 IN SRV 10 20 port sip.example.com.",
+		"example.com. 300 IN SOA ns1.example.com. hostmaster.example.com. 1 2 3 4",
+		`example.com. 300 IN TXT "unterminated`,
+		"example.com. 300 IN CAA 0 issue ca.example.net",
+		"example.com. 300 IN TYPE65280 0A000001",
+		`example.com. 300 IN TYPE65280 \# 5 0A000001`,
+		`example.com. 300 IN TYPE65280 \# 2 0G00`,
+	}
+	for _, line := range errors {
+		if record, err := ParsePresentation(line); err == nil {
+			t.Errorf("ParsePresentation(%q) = %v, expected an error", line, record)
+		}
+	}
+}
@@ -1,8 +1,6 @@
 package records
 
 import (
-	"fmt"
-
 	"github.com/vadim-su/dnska/pkg/dns/types"
 )
 
@@ -30,14 +28,12 @@ func (r *PTRRecord) Target() string {
 	return r.target
 }
 
-// Data returns the target domain name as bytes
+// Data returns the target domain name in wire format
 func (r *PTRRecord) Data() []byte {
-	// This would need proper DNS name encoding
-	// For now, return the string as bytes
-	return []byte(r.target)
+	return encodeName(r.target)
 }
 
-// String returns a string representation of the PTR record
+// String returns the record in presentation format
 func (r *PTRRecord) String() string {
-	return fmt.Sprintf("%s %d IN PTR %s", r.name, r.ttl, r.target)
+	return r.Presentation()
 }
@@ -2,6 +2,7 @@ package records
 
 import (
 	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
 )
 
 // DNSRecord represents a generic DNS resource record
@@ -59,3 +60,14 @@ func (r BaseRecord) TTL() uint32 {
 func (r *BaseRecord) SetTTL(ttl uint32) {
 	r.ttl = ttl
 }
+
+// encodeName returns the uncompressed wire format of a domain name as used in
+// RDATA. Names that cannot be encoded (e.g. over-long labels) yield nil;
+// storage validation rejects such records before they are served
+func encodeName(name string) []byte {
+	domainName, err := utils.NewDomainNameFromString(name)
+	if err != nil {
+		return nil
+	}
+	return domainName.ToBytes()
+}
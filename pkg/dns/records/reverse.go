@@ -0,0 +1,50 @@
+package records
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	// ReverseZoneIPv4 is the reverse mapping zone for IPv4 addresses
+	ReverseZoneIPv4 = "in-addr.arpa."
+	// ReverseZoneIPv6 is the reverse mapping zone for IPv6 addresses
+	ReverseZoneIPv6 = "ip6.arpa."
+)
+
+// ReverseName returns the reverse lookup owner name for an IP address.
+// IPv4 addresses map into in-addr.arpa and IPv6 addresses are expanded to
+// all 32 nibbles under ip6.arpa. An empty string is returned for invalid IPs
+func ReverseName(ip net.IP) string {
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.%s", ipv4[3], ipv4[2], ipv4[1], ipv4[0], ReverseZoneIPv4)
+	}
+
+	ipv6 := ip.To16()
+	if ipv6 == nil {
+		return ""
+	}
+
+	const hexDigits = "0123456789abcdef"
+	var sb strings.Builder
+	sb.Grow(64 + len(ReverseZoneIPv6))
+	for i := len(ipv6) - 1; i >= 0; i-- {
+		sb.WriteByte(hexDigits[ipv6[i]&0x0F])
+		sb.WriteByte('.')
+		sb.WriteByte(hexDigits[ipv6[i]>>4])
+		sb.WriteByte('.')
+	}
+	sb.WriteString(ReverseZoneIPv6)
+
+	return sb.String()
+}
+
+// NewPTRRecordForIP creates a PTR record mapping an IP address back to target
+func NewPTRRecordForIP(ip net.IP, target string, ttl uint32) (*PTRRecord, error) {
+	name := ReverseName(ip)
+	if name == "" {
+		return nil, fmt.Errorf("invalid IP address: %v", ip)
+	}
+	return NewPTRRecord(name, target, ttl), nil
+}
@@ -0,0 +1,75 @@
+package records
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestReverseName(t *testing.T) {
+	tests := []struct {
+		name     string
+		ip       string
+		expected string
+	}{
+		{
+			name:     "ipv4 address",
+			ip:       "192.168.1.1",
+			expected: "1.1.168.192.in-addr.arpa.",
+		},
+		{
+			name:     "ipv4 with zero octets",
+			ip:       "10.0.0.1",
+			expected: "1.0.0.10.in-addr.arpa.",
+		},
+		{
+			name:     "ipv4-mapped ipv6 address",
+			ip:       "::ffff:10.1.2.3",
+			expected: "3.2.1.10.in-addr.arpa.",
+		},
+		{
+			name:     "ipv6 with zero compression",
+			ip:       "2001:db8::1",
+			expected: "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.",
+		},
+		{
+			name:     "ipv6 loopback",
+			ip:       "::1",
+			expected: "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.ip6.arpa.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ReverseName(net.ParseIP(tt.ip))
+			if got != tt.expected {
+				t.Errorf("ReverseName(%s) = %s, want %s", tt.ip, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReverseNameIPv6NibbleCount(t *testing.T) {
+	name := ReverseName(net.ParseIP("fe80::"))
+	labels := strings.Split(strings.TrimSuffix(name, ".ip6.arpa."), ".")
+	if len(labels) != 32 {
+		t.Fatalf("expected 32 nibble labels, got %d in %s", len(labels), name)
+	}
+}
+
+func TestNewPTRRecordForIP(t *testing.T) {
+	record, err := NewPTRRecordForIP(net.ParseIP("192.0.2.5"), "host.example.com.", 300)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if record.Name() != "5.2.0.192.in-addr.arpa." {
+		t.Errorf("unexpected name %s", record.Name())
+	}
+	if record.Target() != "host.example.com." {
+		t.Errorf("unexpected target %s", record.Target())
+	}
+
+	if _, err := NewPTRRecordForIP(nil, "host.example.com.", 300); err == nil {
+		t.Error("expected error for invalid IP")
+	}
+}
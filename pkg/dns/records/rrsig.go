@@ -0,0 +1,139 @@
+package records
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// RRSIGTimeLayout is the presentation format of RRSIG signature expiration
+// and inception times, YYYYMMDDHHmmSS in UTC (RFC 4034 §3.2)
+const RRSIGTimeLayout = "20060102150405"
+
+// RRSIGRecord represents an RRSIG record, the signature over one RRset
+type RRSIGRecord struct {
+	BaseRecord
+	typeCovered types.DNSType // Type of the signed RRset
+	algorithm   uint8         // DNSSEC algorithm number of the signing key
+	labels      uint8         // Labels of the original owner name, without a wildcard
+	originalTTL uint32        // TTL of the RRset as in the zone
+	expiration  uint32        // End of the validity period, in seconds since the epoch
+	inception   uint32        // Start of the validity period, in seconds since the epoch
+	keyTag      uint16        // Tag of the signing DNSKEY
+	signerName  string        // Owner of the signing DNSKEY
+	signature   []byte        // Signature in the format of the algorithm
+}
+
+// NewRRSIGRecord creates a new RRSIG record. Times are kept as the 32-bit
+// seconds of the wire format. The signer name and signature must not be empty
+func NewRRSIGRecord(name string, typeCovered types.DNSType, algorithm, labels uint8, originalTTL uint32,
+	expiration, inception time.Time, keyTag uint16, signerName string, signature []byte, ttl uint32) (*RRSIGRecord, error) {
+	switch {
+	case signerName == "":
+		return nil, fmt.Errorf("empty RRSIG signer name")
+	case len(signature) == 0:
+		return nil, fmt.Errorf("empty RRSIG signature")
+	}
+
+	if signerName[len(signerName)-1] != '.' {
+		signerName += "."
+	}
+
+	return &RRSIGRecord{
+		BaseRecord:  NewBaseRecord(name, types.CLASS_IN, ttl),
+		typeCovered: typeCovered,
+		algorithm:   algorithm,
+		labels:      labels,
+		originalTTL: originalTTL,
+		expiration:  uint32(expiration.Unix()),
+		inception:   uint32(inception.Unix()),
+		keyTag:      keyTag,
+		signerName:  signerName,
+		signature:   slices.Clone(signature),
+	}, nil
+}
+
+// Type returns the DNS record type
+func (r *RRSIGRecord) Type() types.DNSType {
+	return types.TYPE_RRSIG
+}
+
+// TypeCovered returns the type of the signed RRset
+func (r *RRSIGRecord) TypeCovered() types.DNSType {
+	return r.typeCovered
+}
+
+// Algorithm returns the DNSSEC algorithm number
+func (r *RRSIGRecord) Algorithm() uint8 {
+	return r.algorithm
+}
+
+// Labels returns the label count of the original owner name
+func (r *RRSIGRecord) Labels() uint8 {
+	return r.labels
+}
+
+// OriginalTTL returns the TTL of the RRset as in the zone
+func (r *RRSIGRecord) OriginalTTL() uint32 {
+	return r.originalTTL
+}
+
+// Expiration returns the end of the validity period
+func (r *RRSIGRecord) Expiration() time.Time {
+	return time.Unix(int64(r.expiration), 0).UTC()
+}
+
+// Inception returns the start of the validity period
+func (r *RRSIGRecord) Inception() time.Time {
+	return time.Unix(int64(r.inception), 0).UTC()
+}
+
+// KeyTag returns the tag of the signing DNSKEY
+func (r *RRSIGRecord) KeyTag() uint16 {
+	return r.keyTag
+}
+
+// SignerName returns the owner of the signing DNSKEY
+func (r *RRSIGRecord) SignerName() string {
+	return r.signerName
+}
+
+// Signature returns the signature
+func (r *RRSIGRecord) Signature() []byte {
+	return r.signature
+}
+
+// Data returns the RRSIG fields as bytes, the signer name uncompressed as
+// RFC 4034 §3.1.7 requires
+func (r *RRSIGRecord) Data() []byte {
+	signerName := encodeName(r.signerName)
+	if signerName == nil {
+		return nil
+	}
+
+	// Format: 2 bytes type covered + 1 byte algorithm + 1 byte labels +
+	// 4 bytes original TTL + 4 bytes expiration + 4 bytes inception +
+	// 2 bytes key tag + signer name + signature
+	data := make([]byte, 0, 18+len(signerName)+len(r.signature))
+	data = append(data, byte(r.typeCovered>>8), byte(r.typeCovered))
+	data = append(data, r.algorithm, r.labels)
+	data = binary.BigEndian.AppendUint32(data, r.originalTTL)
+	data = binary.BigEndian.AppendUint32(data, r.expiration)
+	data = binary.BigEndian.AppendUint32(data, r.inception)
+	data = append(data, byte(r.keyTag>>8), byte(r.keyTag))
+	data = append(data, signerName...)
+	data = append(data, r.signature...)
+	return data
+}
+
+// String returns a string representation of the RRSIG record
+func (r *RRSIGRecord) String() string {
+	return fmt.Sprintf("%s %d IN RRSIG %s %d %d %d %s %s %d %s %s",
+		r.name, r.ttl, r.typeCovered.Mnemonic(), r.algorithm, r.labels, r.originalTTL,
+		r.Expiration().Format(RRSIGTimeLayout), r.Inception().Format(RRSIGTimeLayout),
+		r.keyTag, r.signerName, base64.StdEncoding.EncodeToString(r.signature))
+}
@@ -0,0 +1,59 @@
+package records
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+func TestRRSIGRecord(t *testing.T) {
+	// The example of RFC 4034 §3.3
+	signature, err := base64.StdEncoding.DecodeString("oJB1W6WNGv+ldvQ3WDG0MQkg5IEhjRip8WTrPYGv07h108dUKGMeDPKijVCHX3DDKdfb+v6o" +
+		"B9wfuh3DTJXUAfI/M0zmO/zz8bW0Rznl8O3tGNazPwQKkRN20XPXV6nwwfoXmJQbsLNrLfkGJ5D6fwFm8nN+6pBzeDQfsS3Ap3o=")
+	if err != nil {
+		t.Fatalf("failed to decode signature: %v", err)
+	}
+	expiration := time.Date(2003, 3, 22, 17, 31, 3, 0, time.UTC)
+	inception := time.Date(2003, 2, 20, 17, 31, 3, 0, time.UTC)
+
+	record, err := NewRRSIGRecord("host.example.com.", types.TYPE_A, 5, 3, 86400, expiration, inception, 2642, "example.com", signature, 86400)
+	if err != nil {
+		t.Fatalf("NewRRSIGRecord() returned error: %v", err)
+	}
+
+	expected := "host.example.com. 86400 IN RRSIG A 5 3 86400 20030322173103 20030220173103 2642 example.com. " +
+		base64.StdEncoding.EncodeToString(signature)
+	if got := record.String(); got != expected {
+		t.Errorf("String() = %q, expected %q", got, expected)
+	}
+	if !record.Expiration().Equal(expiration) || !record.Inception().Equal(inception) {
+		t.Errorf("validity = %s - %s, expected %s - %s", record.Inception(), record.Expiration(), inception, expiration)
+	}
+
+	header := []byte{
+		0x00, 0x01, // type covered
+		0x05, 0x03, // algorithm, labels
+		0x00, 0x01, 0x51, 0x80, // original TTL
+		0x3E, 0x7C, 0x9D, 0xD7, // expiration
+		0x3E, 0x55, 0x10, 0xD7, // inception
+		0x0A, 0x52, // key tag
+	}
+	signer := []byte("\x07example\x03com\x00")
+	expectedData := append(append(header, signer...), signature...)
+	if data := record.Data(); !bytes.Equal(data, expectedData) {
+		t.Errorf("Data() = %X, expected %X", data, expectedData)
+	}
+}
+
+func TestNewRRSIGRecord_Invalid(t *testing.T) {
+	now := time.Now()
+	if _, err := NewRRSIGRecord("example.com.", types.TYPE_A, 13, 2, 300, now, now, 1, "", []byte{0x01}, 300); err == nil {
+		t.Error("NewRRSIGRecord() accepted an empty signer name")
+	}
+	if _, err := NewRRSIGRecord("example.com.", types.TYPE_A, 13, 2, 300, now, now, 1, "example.com.", nil, 300); err == nil {
+		t.Error("NewRRSIGRecord() accepted an empty signature")
+	}
+}
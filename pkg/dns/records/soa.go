@@ -1,7 +1,7 @@
 package records
 
 import (
-	"fmt"
+	"strings"
 	"time"
 
 	"github.com/vadim-su/dnska/pkg/dns/types"
@@ -53,11 +53,34 @@ func (r *SOARecord) Responsible() string {
 	return r.responsible
 }
 
+// AdminEmail returns the responsible mailbox as an e-mail address: the first
+// label of the responsible name is the local part (RFC 1035 §8)
+func (r *SOARecord) AdminEmail() string {
+	mailbox := strings.TrimSuffix(r.responsible, ".")
+
+	for i := 0; i < len(mailbox); i++ {
+		switch mailbox[i] {
+		case '\\':
+			i++ // Escaped character, e.g. first\.last
+		case '.':
+			local := strings.ReplaceAll(mailbox[:i], "\\.", ".")
+			return local + "@" + mailbox[i+1:]
+		}
+	}
+
+	return mailbox
+}
+
 // Serial returns the zone serial number
 func (r *SOARecord) Serial() uint32 {
 	return r.serial
 }
 
+// SetSerial sets the zone serial number
+func (r *SOARecord) SetSerial(serial uint32) {
+	r.serial = serial
+}
+
 // Refresh returns the refresh interval
 func (r *SOARecord) Refresh() time.Duration {
 	return r.refresh
@@ -83,10 +106,8 @@ func (r *SOARecord) Data() []byte {
 	// Format: primaryNS + responsible + 5 uint32 values
 	data := []byte{}
 
-	// This would need proper DNS name encoding for primaryNS and responsible
-	// For now, append strings as bytes
-	data = append(data, []byte(r.primaryNS)...)
-	data = append(data, []byte(r.responsible)...)
+	data = append(data, encodeName(r.primaryNS)...)
+	data = append(data, encodeName(r.responsible)...)
 
 	// Serial number
 	serialBytes := make([]byte, 4)
@@ -135,10 +156,7 @@ func (r *SOARecord) Data() []byte {
 	return data
 }
 
-// String returns a string representation of the SOA record
+// String returns the record in presentation format
 func (r *SOARecord) String() string {
-	return fmt.Sprintf("%s %d IN SOA %s %s %d %d %d %d %d",
-		r.name, r.ttl, r.primaryNS, r.responsible, r.serial,
-		int(r.refresh.Seconds()), int(r.retry.Seconds()),
-		int(r.expire.Seconds()), int(r.minimum.Seconds()))
+	return r.Presentation()
 }
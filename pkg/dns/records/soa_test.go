@@ -0,0 +1,43 @@
+package records
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSOARecordAdminEmail(t *testing.T) {
+	tests := []struct {
+		responsible string
+		expected    string
+	}{
+		{responsible: "hostmaster.example.com.", expected: "hostmaster@example.com"},
+		{responsible: "hostmaster.example.com", expected: "hostmaster@example.com"},
+		{responsible: `first\.last.example.com.`, expected: "first.last@example.com"},
+		{responsible: "root.", expected: "root"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.responsible, func(t *testing.T) {
+			soa := NewSOARecord("example.com", "ns1.example.com.", tt.responsible,
+				1, time.Hour, time.Minute, time.Hour, time.Minute, 3600)
+
+			if got := soa.AdminEmail(); got != tt.expected {
+				t.Errorf("AdminEmail() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSOARecordSetSerial(t *testing.T) {
+	soa := NewSOARecord("example.com", "ns1.example.com.", "hostmaster.example.com.",
+		2024010101, time.Hour, time.Minute, time.Hour, time.Minute, 3600)
+
+	soa.SetSerial(2024010102)
+
+	if soa.Serial() != 2024010102 {
+		t.Errorf("Serial() = %d, want 2024010102", soa.Serial())
+	}
+	if data := soa.Data(); len(data) < 20 || data[len(data)-20] != byte(2024010102>>24) || data[len(data)-17] != byte(2024010102&0xFF) {
+		t.Errorf("Data() does not carry the new serial: %v", data)
+	}
+}
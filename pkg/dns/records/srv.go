@@ -0,0 +1,69 @@
+package records
+
+import (
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// SRVRecord represents an SRV record (service location, RFC 2782)
+type SRVRecord struct {
+	BaseRecord
+	priority uint16 // Target priority (lower is tried first)
+	weight   uint16 // Relative weight among targets of equal priority
+	port     uint16 // Port the service listens on
+	target   string // Host providing the service
+}
+
+// NewSRVRecord creates a new SRV record
+func NewSRVRecord(name, target string, priority, weight, port uint16, ttl uint32) *SRVRecord {
+	return &SRVRecord{
+		BaseRecord: NewBaseRecord(name, types.CLASS_IN, ttl),
+		priority:   priority,
+		weight:     weight,
+		port:       port,
+		target:     target,
+	}
+}
+
+// Type returns the DNS record type
+func (r *SRVRecord) Type() types.DNSType {
+	return types.TYPE_SRV
+}
+
+// Priority returns the target priority
+func (r *SRVRecord) Priority() uint16 {
+	return r.priority
+}
+
+// Weight returns the target weight
+func (r *SRVRecord) Weight() uint16 {
+	return r.weight
+}
+
+// Port returns the service port
+func (r *SRVRecord) Port() uint16 {
+	return r.port
+}
+
+// Target returns the host providing the service
+func (r *SRVRecord) Target() string {
+	return r.target
+}
+
+// Data returns the priority, weight, port and target as bytes
+func (r *SRVRecord) Data() []byte {
+	// Format: 2 bytes priority + 2 bytes weight + 2 bytes port + domain name bytes.
+	// The target is never compressed (RFC 2782)
+	data := []byte{
+		byte(r.priority >> 8), byte(r.priority & 0xFF),
+		byte(r.weight >> 8), byte(r.weight & 0xFF),
+		byte(r.port >> 8), byte(r.port & 0xFF),
+	}
+
+	data = append(data, encodeName(r.target)...)
+	return data
+}
+
+// String returns the record in presentation format
+func (r *SRVRecord) String() string {
+	return r.Presentation()
+}
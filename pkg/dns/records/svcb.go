@@ -0,0 +1,348 @@
+package records
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// SvcParamKey identifies a service parameter of SVCB and HTTPS records
+type SvcParamKey uint16
+
+// Service parameter keys (RFC 9460 §14.3.2)
+const (
+	SvcParamMandatory     SvcParamKey = 0 // keys a client must understand
+	SvcParamALPN          SvcParamKey = 1 // supported application protocols
+	SvcParamNoDefaultALPN SvcParamKey = 2 // the default protocol is not supported
+	SvcParamPort          SvcParamKey = 3 // alternative port
+	SvcParamIPv4Hint      SvcParamKey = 4 // IPv4 addresses of the target
+	SvcParamECH           SvcParamKey = 5 // encrypted ClientHello configuration
+	SvcParamIPv6Hint      SvcParamKey = 6 // IPv6 addresses of the target
+)
+
+// String returns the presentation name of the key
+func (k SvcParamKey) String() string {
+	switch k {
+	case SvcParamMandatory:
+		return "mandatory"
+	case SvcParamALPN:
+		return "alpn"
+	case SvcParamNoDefaultALPN:
+		return "no-default-alpn"
+	case SvcParamPort:
+		return "port"
+	case SvcParamIPv4Hint:
+		return "ipv4hint"
+	case SvcParamECH:
+		return "ech"
+	case SvcParamIPv6Hint:
+		return "ipv6hint"
+	default:
+		return fmt.Sprintf("key%d", uint16(k))
+	}
+}
+
+// SvcParam is a single service parameter holding its value in wire format
+type SvcParam struct {
+	Key   SvcParamKey
+	Value []byte
+}
+
+// NewMandatoryParam creates a mandatory parameter listing keys
+func NewMandatoryParam(keys ...SvcParamKey) SvcParam {
+	sorted := slices.Clone(keys)
+	slices.Sort(sorted)
+
+	value := make([]byte, 0, 2*len(sorted))
+	for _, key := range sorted {
+		value = append(value, byte(key>>8), byte(key))
+	}
+	return SvcParam{Key: SvcParamMandatory, Value: value}
+}
+
+// NewALPNParam creates an alpn parameter listing protocol IDs, e.g. "h2"
+func NewALPNParam(protocols ...string) SvcParam {
+	var value []byte
+	for _, protocol := range protocols {
+		value = append(value, byte(len(protocol)))
+		value = append(value, protocol...)
+	}
+	return SvcParam{Key: SvcParamALPN, Value: value}
+}
+
+// NewNoDefaultALPNParam creates a no-default-alpn parameter
+func NewNoDefaultALPNParam() SvcParam {
+	return SvcParam{Key: SvcParamNoDefaultALPN, Value: []byte{}}
+}
+
+// NewPortParam creates a port parameter
+func NewPortParam(port uint16) SvcParam {
+	return SvcParam{Key: SvcParamPort, Value: []byte{byte(port >> 8), byte(port)}}
+}
+
+// NewIPv4HintParam creates an ipv4hint parameter. Addresses without an IPv4
+// form are skipped
+func NewIPv4HintParam(ips ...net.IP) SvcParam {
+	var value []byte
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			value = append(value, ip4...)
+		}
+	}
+	return SvcParam{Key: SvcParamIPv4Hint, Value: value}
+}
+
+// NewECHParam creates an ech parameter holding an ECHConfigList
+func NewECHParam(configList []byte) SvcParam {
+	return SvcParam{Key: SvcParamECH, Value: slices.Clone(configList)}
+}
+
+// NewIPv6HintParam creates an ipv6hint parameter. Invalid addresses are skipped
+func NewIPv6HintParam(ips ...net.IP) SvcParam {
+	var value []byte
+	for _, ip := range ips {
+		if ip16 := ip.To16(); ip16 != nil {
+			value = append(value, ip16...)
+		}
+	}
+	return SvcParam{Key: SvcParamIPv6Hint, Value: value}
+}
+
+// MandatoryKeys returns the keys listed by a mandatory parameter
+func (p SvcParam) MandatoryKeys() []SvcParamKey {
+	keys := make([]SvcParamKey, 0, len(p.Value)/2)
+	for i := 0; i+1 < len(p.Value); i += 2 {
+		keys = append(keys, SvcParamKey(uint16(p.Value[i])<<8|uint16(p.Value[i+1])))
+	}
+	return keys
+}
+
+// ALPN returns the protocol IDs listed by an alpn parameter
+func (p SvcParam) ALPN() []string {
+	var protocols []string
+	for value := p.Value; len(value) > 0 && len(value) > int(value[0]); value = value[1+value[0]:] {
+		protocols = append(protocols, string(value[1:1+value[0]]))
+	}
+	return protocols
+}
+
+// Port returns the port of a port parameter
+func (p SvcParam) Port() uint16 {
+	if len(p.Value) != 2 {
+		return 0
+	}
+	return uint16(p.Value[0])<<8 | uint16(p.Value[1])
+}
+
+// IPHints returns the addresses listed by an ipv4hint or ipv6hint parameter
+func (p SvcParam) IPHints() []net.IP {
+	size := net.IPv4len
+	if p.Key == SvcParamIPv6Hint {
+		size = net.IPv6len
+	}
+
+	ips := make([]net.IP, 0, len(p.Value)/size)
+	for i := 0; i+size <= len(p.Value); i += size {
+		ips = append(ips, slices.Clone(net.IP(p.Value[i:i+size])))
+	}
+	return ips
+}
+
+// String returns the presentation form of the parameter, e.g. alpn="h2,h3"
+func (p SvcParam) String() string {
+	switch p.Key {
+	case SvcParamMandatory:
+		keys := make([]string, 0, len(p.Value)/2)
+		for _, key := range p.MandatoryKeys() {
+			keys = append(keys, key.String())
+		}
+		return fmt.Sprintf("%s=%s", p.Key, strings.Join(keys, ","))
+	case SvcParamALPN:
+		return fmt.Sprintf("%s=%q", p.Key, strings.Join(p.ALPN(), ","))
+	case SvcParamNoDefaultALPN:
+		return p.Key.String()
+	case SvcParamPort:
+		return fmt.Sprintf("%s=%d", p.Key, p.Port())
+	case SvcParamIPv4Hint, SvcParamIPv6Hint:
+		ips := make([]string, 0, len(p.Value))
+		for _, ip := range p.IPHints() {
+			ips = append(ips, ip.String())
+		}
+		return fmt.Sprintf("%s=%s", p.Key, strings.Join(ips, ","))
+	case SvcParamECH:
+		return fmt.Sprintf("%s=%s", p.Key, base64.StdEncoding.EncodeToString(p.Value))
+	default:
+		return fmt.Sprintf("%s=%q", p.Key, p.Value)
+	}
+}
+
+// DecodeSvcParams parses the SvcParams part of SVCB RDATA. Keys must appear
+// in strictly increasing order and the values of known keys must be well
+// formed (RFC 9460 §2.2, §7)
+func DecodeSvcParams(data []byte) ([]SvcParam, error) {
+	var params []SvcParam
+
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("truncated SvcParam header: %d bytes", len(data))
+		}
+
+		key := SvcParamKey(uint16(data[0])<<8 | uint16(data[1]))
+		length := int(data[2])<<8 | int(data[3])
+		if len(data[4:]) < length {
+			return nil, fmt.Errorf("SvcParam %s needs %d bytes, have %d", key, length, len(data[4:]))
+		}
+
+		if len(params) > 0 && key <= params[len(params)-1].Key {
+			return nil, fmt.Errorf("SvcParam %s out of order after %s", key, params[len(params)-1].Key)
+		}
+
+		param := SvcParam{Key: key, Value: slices.Clone(data[4 : 4+length])}
+		if err := validateSvcParam(param); err != nil {
+			return nil, err
+		}
+
+		params = append(params, param)
+		data = data[4+length:]
+	}
+
+	return params, nil
+}
+
+// validateSvcParam checks the value format of the keys defined by RFC 9460
+func validateSvcParam(param SvcParam) error {
+	value := param.Value
+
+	switch param.Key {
+	case SvcParamMandatory:
+		if len(value) == 0 || len(value)%2 != 0 {
+			return fmt.Errorf("invalid mandatory value length %d", len(value))
+		}
+		keys := param.MandatoryKeys()
+		for i, key := range keys {
+			if key == SvcParamMandatory || i > 0 && key <= keys[i-1] {
+				return fmt.Errorf("invalid mandatory key list")
+			}
+		}
+	case SvcParamALPN:
+		if len(value) == 0 {
+			return fmt.Errorf("empty alpn value")
+		}
+		for len(value) > 0 {
+			length := int(value[0])
+			if length == 0 || len(value[1:]) < length {
+				return fmt.Errorf("invalid alpn protocol ID")
+			}
+			value = value[1+length:]
+		}
+	case SvcParamNoDefaultALPN:
+		if len(value) != 0 {
+			return fmt.Errorf("no-default-alpn must have an empty value")
+		}
+	case SvcParamPort:
+		if len(value) != 2 {
+			return fmt.Errorf("invalid port value length %d", len(value))
+		}
+	case SvcParamIPv4Hint:
+		if len(value) == 0 || len(value)%net.IPv4len != 0 {
+			return fmt.Errorf("invalid ipv4hint value length %d", len(value))
+		}
+	case SvcParamIPv6Hint:
+		if len(value) == 0 || len(value)%net.IPv6len != 0 {
+			return fmt.Errorf("invalid ipv6hint value length %d", len(value))
+		}
+	}
+
+	return nil
+}
+
+// SVCBRecord represents an SVCB record (service binding, RFC 9460)
+type SVCBRecord struct {
+	BaseRecord
+	priority   uint16     // 0 for AliasMode, otherwise ServiceMode priority
+	targetName string     // Alias target or service endpoint, "." for the owner
+	params     []SvcParam // Service parameters ordered by key
+}
+
+// NewSVCBRecord creates a new SVCB record. Parameters are stored ordered by key
+func NewSVCBRecord(name, targetName string, priority uint16, params []SvcParam, ttl uint32) *SVCBRecord {
+	return &SVCBRecord{
+		BaseRecord: NewBaseRecord(name, types.CLASS_IN, ttl),
+		priority:   priority,
+		targetName: targetName,
+		params:     sortSvcParams(params),
+	}
+}
+
+// Type returns the DNS record type
+func (r *SVCBRecord) Type() types.DNSType {
+	return types.TYPE_SVCB
+}
+
+// Priority returns the record priority; 0 means AliasMode
+func (r *SVCBRecord) Priority() uint16 {
+	return r.priority
+}
+
+// TargetName returns the target name
+func (r *SVCBRecord) TargetName() string {
+	return r.targetName
+}
+
+// Params returns the service parameters ordered by key
+func (r *SVCBRecord) Params() []SvcParam {
+	return r.params
+}
+
+// Param returns the parameter with the given key
+func (r *SVCBRecord) Param(key SvcParamKey) (SvcParam, bool) {
+	for _, param := range r.params {
+		if param.Key == key {
+			return param, true
+		}
+	}
+	return SvcParam{}, false
+}
+
+// Data returns the priority, target name and parameters as bytes
+func (r *SVCBRecord) Data() []byte {
+	// Format: 2 bytes priority + uncompressed target name + SvcParams, each
+	// being 2 bytes key + 2 bytes value length + value
+	data := []byte{byte(r.priority >> 8), byte(r.priority & 0xFF)}
+	data = append(data, encodeName(r.targetName)...)
+
+	for _, param := range r.params {
+		data = append(data, byte(param.Key>>8), byte(param.Key&0xFF))
+		data = append(data, byte(len(param.Value)>>8), byte(len(param.Value)&0xFF))
+		data = append(data, param.Value...)
+	}
+
+	return data
+}
+
+// String returns a string representation of the SVCB record
+func (r *SVCBRecord) String() string {
+	return r.format("SVCB")
+}
+
+// format renders the record in presentation form using the given type name
+func (r *SVCBRecord) format(typeName string) string {
+	fields := []string{fmt.Sprintf("%s %d IN %s %d %s", r.name, r.ttl, typeName, r.priority, r.targetName)}
+	for _, param := range r.params {
+		fields = append(fields, param.String())
+	}
+	return strings.Join(fields, " ")
+}
+
+// sortSvcParams returns a copy of params ordered by key
+func sortSvcParams(params []SvcParam) []SvcParam {
+	sorted := slices.Clone(params)
+	slices.SortStableFunc(sorted, func(a, b SvcParam) int {
+		return int(a.Key) - int(b.Key)
+	})
+	return sorted
+}
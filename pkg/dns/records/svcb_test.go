@@ -0,0 +1,162 @@
+package records
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+func TestSVCBRecordData(t *testing.T) {
+	tests := []struct {
+		name     string
+		record   DNSRecord
+		expected []byte
+	}{
+		{
+			// RFC 9460 Appendix D.1
+			name:   "alias mode",
+			record: NewSVCBRecord("example.com", "foo.example.com", 0, nil, 300),
+			expected: []byte{
+				0x00, 0x00,
+				0x03, 'f', 'o', 'o', 0x07, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0x03, 'c', 'o', 'm', 0x00,
+			},
+		},
+		{
+			name:   "alpn and port",
+			record: NewHTTPSRecord("example.com", ".", 1, []SvcParam{NewPortParam(443), NewALPNParam("h2", "h3")}, 300),
+			expected: []byte{
+				0x00, 0x01,
+				0x00,
+				0x00, 0x01, 0x00, 0x06, 0x02, 'h', '2', 0x02, 'h', '3',
+				0x00, 0x03, 0x00, 0x02, 0x01, 0xBB,
+			},
+		},
+		{
+			// Combines the key667 and ipv6hint vectors of RFC 9460 Appendix D.2
+			name: "hints and unknown key",
+			record: NewSVCBRecord("example.com", "foo.example.org", 1, []SvcParam{
+				{Key: 667, Value: []byte("hello")},
+				NewIPv6HintParam(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::53:1")),
+			}, 300),
+			expected: []byte{
+				0x00, 0x01,
+				0x03, 'f', 'o', 'o', 0x07, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0x03, 'o', 'r', 'g', 0x00,
+				0x00, 0x06, 0x00, 0x20,
+				0x20, 0x01, 0x0D, 0xB8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x00, 0x01,
+				0x20, 0x01, 0x0D, 0xB8, 0, 0, 0, 0, 0, 0, 0, 0, 0x00, 0x53, 0x00, 0x01,
+				0x02, 0x9B, 0x00, 0x05, 'h', 'e', 'l', 'l', 'o',
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.record.Data()
+			if !bytes.Equal(data, tt.expected) {
+				t.Fatalf("Data() mismatch:\ngot:  %v\nwant: %v", data, tt.expected)
+			}
+
+			// Skip priority and target name, then decode the parameters back
+			svcb := tt.record.(interface {
+				TargetName() string
+				Params() []SvcParam
+			})
+			params, err := DecodeSvcParams(data[2+len(encodeName(svcb.TargetName())):])
+			if err != nil {
+				t.Fatalf("DecodeSvcParams failed: %v", err)
+			}
+			if !reflect.DeepEqual(params, svcb.Params()) {
+				t.Errorf("decoded %v, want %v", params, svcb.Params())
+			}
+		})
+	}
+}
+
+func TestHTTPSRecordParams(t *testing.T) {
+	record := NewHTTPSRecord("example.com", ".", 1, []SvcParam{
+		NewIPv4HintParam(net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2")),
+		NewALPNParam("h2", "h3"),
+		NewPortParam(443),
+		NewMandatoryParam(SvcParamPort, SvcParamALPN),
+		NewNoDefaultALPNParam(),
+		NewECHParam([]byte{0xFE, 0x0D}),
+	}, 300)
+
+	if record.Type() != types.TYPE_HTTPS {
+		t.Errorf("Type() = %s, want HTTPS", record.Type())
+	}
+
+	keys := make([]SvcParamKey, 0, len(record.Params()))
+	for _, param := range record.Params() {
+		keys = append(keys, param.Key)
+	}
+	expectedKeys := []SvcParamKey{
+		SvcParamMandatory, SvcParamALPN, SvcParamNoDefaultALPN, SvcParamPort, SvcParamIPv4Hint, SvcParamECH,
+	}
+	if !reflect.DeepEqual(keys, expectedKeys) {
+		t.Errorf("params ordered as %v, want %v", keys, expectedKeys)
+	}
+
+	if alpn, ok := record.Param(SvcParamALPN); !ok || !reflect.DeepEqual(alpn.ALPN(), []string{"h2", "h3"}) {
+		t.Errorf("alpn = %v, want [h2 h3]", alpn.ALPN())
+	}
+	if port, ok := record.Param(SvcParamPort); !ok || port.Port() != 443 {
+		t.Errorf("port = %d, want 443", port.Port())
+	}
+	if hints, _ := record.Param(SvcParamIPv4Hint); len(hints.IPHints()) != 2 || !hints.IPHints()[1].Equal(net.ParseIP("192.0.2.2")) {
+		t.Errorf("ipv4hint = %v", hints.IPHints())
+	}
+	if _, ok := record.Param(SvcParamIPv6Hint); ok {
+		t.Error("unexpected ipv6hint parameter")
+	}
+
+	expected := `example.com. 300 IN HTTPS 1 . mandatory=alpn,port alpn="h2,h3" no-default-alpn port=443 ipv4hint=192.0.2.1,192.0.2.2 ech=/g0=`
+	if record.String() != expected {
+		t.Errorf("String() = %q\nwant %q", record.String(), expected)
+	}
+
+	if _, err := DecodeSvcParams(record.Data()[3:]); err != nil {
+		t.Errorf("encoded parameters do not decode: %v", err)
+	}
+}
+
+func TestDecodeSvcParamsErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		expectedErr string
+	}{
+		{name: "truncated header", data: []byte{0x00, 0x01, 0x00}, expectedErr: "truncated SvcParam header"},
+		{name: "truncated value", data: []byte{0x00, 0x03, 0x00, 0x02, 0x01}, expectedErr: "needs 2 bytes, have 1"},
+		{
+			name:        "keys out of order",
+			data:        []byte{0x00, 0x03, 0x00, 0x02, 0x01, 0xBB, 0x00, 0x01, 0x00, 0x03, 0x02, 'h', '2'},
+			expectedErr: "alpn out of order after port",
+		},
+		{
+			name:        "duplicate key",
+			data:        []byte{0x00, 0x03, 0x00, 0x02, 0x01, 0xBB, 0x00, 0x03, 0x00, 0x02, 0x01, 0xBB},
+			expectedErr: "port out of order after port",
+		},
+		{name: "empty alpn", data: []byte{0x00, 0x01, 0x00, 0x00}, expectedErr: "empty alpn value"},
+		{name: "overrunning alpn ID", data: []byte{0x00, 0x01, 0x00, 0x02, 0x05, 'h'}, expectedErr: "invalid alpn protocol ID"},
+		{name: "no-default-alpn with value", data: []byte{0x00, 0x02, 0x00, 0x01, 0x00}, expectedErr: "must have an empty value"},
+		{name: "short port", data: []byte{0x00, 0x03, 0x00, 0x01, 0x01}, expectedErr: "invalid port value length 1"},
+		{name: "partial ipv4hint", data: []byte{0x00, 0x04, 0x00, 0x03, 192, 0, 2}, expectedErr: "invalid ipv4hint value length 3"},
+		{name: "partial ipv6hint", data: []byte{0x00, 0x06, 0x00, 0x04, 0x20, 0x01, 0x0D, 0xB8}, expectedErr: "invalid ipv6hint value length 4"},
+		{name: "mandatory lists itself", data: []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00}, expectedErr: "invalid mandatory key list"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeSvcParams(tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("got error %v, want one containing %q", err, tt.expectedErr)
+			}
+		})
+	}
+}
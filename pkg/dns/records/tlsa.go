@@ -0,0 +1,152 @@
+package records
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"fmt"
+	"slices"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// TLSA certificate usages (RFC 6698 §2.1.1, acronyms from RFC 7218)
+const (
+	TLSAUsagePKIXTA uint8 = 0 // CA constraint, validated against public trust anchors
+	TLSAUsagePKIXEE uint8 = 1 // Service certificate constraint, publicly validated
+	TLSAUsageDANETA uint8 = 2 // Trust anchor assertion, e.g. a private CA
+	TLSAUsageDANEEE uint8 = 3 // Domain-issued certificate, no PKIX validation
+)
+
+// TLSA selectors (RFC 6698 §2.1.2)
+const (
+	TLSASelectorCert uint8 = 0 // Full certificate
+	TLSASelectorSPKI uint8 = 1 // SubjectPublicKeyInfo
+)
+
+// TLSA matching types (RFC 6698 §2.1.3)
+const (
+	TLSAMatchingFull   uint8 = 0 // Exact match on the selected content
+	TLSAMatchingSHA256 uint8 = 1 // SHA-256 hash of the selected content
+	TLSAMatchingSHA512 uint8 = 2 // SHA-512 hash of the selected content
+)
+
+// TLSARecord represents a TLSA record (TLS certificate association, DANE)
+type TLSARecord struct {
+	BaseRecord
+	certUsage     uint8  // How the association is used to verify the certificate
+	selector      uint8  // Which part of the certificate is matched
+	matchingType  uint8  // How the selected content is presented
+	certAssocData []byte // Selected content or its hash
+}
+
+// NewTLSARecord creates a new TLSA record. Hashed association data must have
+// the digest length of its matching type
+func NewTLSARecord(name string, certUsage, selector, matchingType uint8, certAssocData []byte, ttl uint32) (*TLSARecord, error) {
+	switch {
+	case len(certAssocData) == 0:
+		return nil, fmt.Errorf("empty TLSA certificate association data")
+	case matchingType == TLSAMatchingSHA256 && len(certAssocData) != sha256.Size:
+		return nil, fmt.Errorf("SHA-256 TLSA data must be %d bytes, got %d", sha256.Size, len(certAssocData))
+	case matchingType == TLSAMatchingSHA512 && len(certAssocData) != sha512.Size:
+		return nil, fmt.Errorf("SHA-512 TLSA data must be %d bytes, got %d", sha512.Size, len(certAssocData))
+	}
+
+	return &TLSARecord{
+		BaseRecord:    NewBaseRecord(name, types.CLASS_IN, ttl),
+		certUsage:     certUsage,
+		selector:      selector,
+		matchingType:  matchingType,
+		certAssocData: slices.Clone(certAssocData),
+	}, nil
+}
+
+// NewTLSARecordFromCertificate creates a TLSA record associating name with
+// cert, selecting and hashing the certificate as requested
+func NewTLSARecordFromCertificate(name string, cert *x509.Certificate, certUsage, selector, matchingType uint8, ttl uint32) (*TLSARecord, error) {
+	data, err := tlsaAssociationData(cert, selector, matchingType)
+	if err != nil {
+		return nil, err
+	}
+	return NewTLSARecord(name, certUsage, selector, matchingType, data, ttl)
+}
+
+// tlsaAssociationData returns the association data of cert for the given
+// selector and matching type
+func tlsaAssociationData(cert *x509.Certificate, selector, matchingType uint8) ([]byte, error) {
+	if cert == nil {
+		return nil, fmt.Errorf("no certificate")
+	}
+
+	var selected []byte
+	switch selector {
+	case TLSASelectorCert:
+		selected = cert.Raw
+	case TLSASelectorSPKI:
+		selected = cert.RawSubjectPublicKeyInfo
+	default:
+		return nil, fmt.Errorf("unknown TLSA selector %d", selector)
+	}
+
+	switch matchingType {
+	case TLSAMatchingFull:
+		return slices.Clone(selected), nil
+	case TLSAMatchingSHA256:
+		sum := sha256.Sum256(selected)
+		return sum[:], nil
+	case TLSAMatchingSHA512:
+		sum := sha512.Sum512(selected)
+		return sum[:], nil
+	default:
+		return nil, fmt.Errorf("unknown TLSA matching type %d", matchingType)
+	}
+}
+
+// Type returns the DNS record type
+func (r *TLSARecord) Type() types.DNSType {
+	return types.TYPE_TLSA
+}
+
+// CertUsage returns the certificate usage
+func (r *TLSARecord) CertUsage() uint8 {
+	return r.certUsage
+}
+
+// Selector returns the selector
+func (r *TLSARecord) Selector() uint8 {
+	return r.selector
+}
+
+// MatchingType returns the matching type
+func (r *TLSARecord) MatchingType() uint8 {
+	return r.matchingType
+}
+
+// CertAssocData returns the certificate association data
+func (r *TLSARecord) CertAssocData() []byte {
+	return r.certAssocData
+}
+
+// Matches reports whether cert matches the association data. Only the
+// selector and matching type are checked; the certificate usage decides how
+// the caller validates the chain
+func (r *TLSARecord) Matches(cert *x509.Certificate) bool {
+	data, err := tlsaAssociationData(cert, r.selector, r.matchingType)
+	return err == nil && bytes.Equal(data, r.certAssocData)
+}
+
+// Data returns the usage, selector, matching type and association data as bytes
+func (r *TLSARecord) Data() []byte {
+	// Format: 1 byte usage + 1 byte selector + 1 byte matching type + data
+	data := make([]byte, 0, 3+len(r.certAssocData))
+	data = append(data, r.certUsage, r.selector, r.matchingType)
+	data = append(data, r.certAssocData...)
+	return data
+}
+
+// String returns a string representation of the TLSA record
+func (r *TLSARecord) String() string {
+	return fmt.Sprintf("%s %d IN TLSA %d %d %d %X",
+		r.name, r.ttl, r.certUsage, r.selector, r.matchingType, r.certAssocData)
+}
@@ -0,0 +1,141 @@
+package records
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// newTestCertificate creates a self-signed certificate for name
+func newTestCertificate(t *testing.T, name string) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	return cert
+}
+
+func TestNewTLSARecord(t *testing.T) {
+	tests := []struct {
+		name         string
+		matchingType uint8
+		data         []byte
+		expectedErr  string
+	}{
+		{name: "full data", matchingType: TLSAMatchingFull, data: []byte{0x30, 0x82}},
+		{name: "SHA-256", matchingType: TLSAMatchingSHA256, data: make([]byte, sha256.Size)},
+		{name: "SHA-512", matchingType: TLSAMatchingSHA512, data: make([]byte, sha512.Size)},
+		{name: "private matching type", matchingType: 255, data: []byte{0x01}},
+		{name: "empty data", matchingType: TLSAMatchingFull, data: nil, expectedErr: "empty TLSA certificate association data"},
+		{name: "short SHA-256", matchingType: TLSAMatchingSHA256, data: make([]byte, 20), expectedErr: "SHA-256 TLSA data must be 32 bytes, got 20"},
+		{name: "SHA-256 length for SHA-512", matchingType: TLSAMatchingSHA512, data: make([]byte, sha256.Size), expectedErr: "SHA-512 TLSA data must be 64 bytes, got 32"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := NewTLSARecord("_443._tcp.example.com.", TLSAUsageDANEEE, TLSASelectorSPKI, tt.matchingType, tt.data, 300)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("got error %v, want one containing %q", err, tt.expectedErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewTLSARecord failed: %v", err)
+			}
+
+			expected := append([]byte{TLSAUsageDANEEE, TLSASelectorSPKI, tt.matchingType}, tt.data...)
+			if !bytes.Equal(record.Data(), expected) {
+				t.Errorf("Data() = %v, want %v", record.Data(), expected)
+			}
+			if record.Type() != types.TYPE_TLSA {
+				t.Errorf("Type() = %s, want TLSA", record.Type())
+			}
+		})
+	}
+}
+
+func TestNewTLSARecordFromCertificate(t *testing.T) {
+	cert := newTestCertificate(t, "mail.example.com")
+	other := newTestCertificate(t, "mail.example.com")
+
+	tests := []struct {
+		name         string
+		selector     uint8
+		matchingType uint8
+		expectedData []byte
+	}{
+		{name: "full certificate", selector: TLSASelectorCert, matchingType: TLSAMatchingFull, expectedData: cert.Raw},
+		{name: "certificate SHA-512", selector: TLSASelectorCert, matchingType: TLSAMatchingSHA512},
+		{name: "public key SHA-256", selector: TLSASelectorSPKI, matchingType: TLSAMatchingSHA256},
+		{name: "full public key", selector: TLSASelectorSPKI, matchingType: TLSAMatchingFull, expectedData: cert.RawSubjectPublicKeyInfo},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := NewTLSARecordFromCertificate("_25._tcp.mail.example.com.", cert, TLSAUsageDANEEE, tt.selector, tt.matchingType, 3600)
+			if err != nil {
+				t.Fatalf("NewTLSARecordFromCertificate failed: %v", err)
+			}
+
+			if tt.expectedData != nil && !bytes.Equal(record.CertAssocData(), tt.expectedData) {
+				t.Errorf("association data does not hold the selected content")
+			}
+			if !record.Matches(cert) {
+				t.Error("record does not match its own certificate")
+			}
+			if record.Matches(other) {
+				t.Error("record matches a certificate with another key")
+			}
+		})
+	}
+
+	spkiHash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	record, err := NewTLSARecordFromCertificate("_25._tcp.mail.example.com.", cert, TLSAUsageDANEEE, TLSASelectorSPKI, TLSAMatchingSHA256, 3600)
+	if err != nil {
+		t.Fatalf("NewTLSARecordFromCertificate failed: %v", err)
+	}
+	expected := "_25._tcp.mail.example.com. 3600 IN TLSA 3 1 1 " + strings.ToUpper(hex.EncodeToString(spkiHash[:]))
+	if record.String() != expected {
+		t.Errorf("String() = %q, want %q", record.String(), expected)
+	}
+
+	if _, err := NewTLSARecordFromCertificate("_25._tcp.mail.example.com.", cert, TLSAUsageDANEEE, 2, TLSAMatchingSHA256, 3600); err == nil {
+		t.Error("expected an error for an unknown selector")
+	}
+	if _, err := NewTLSARecordFromCertificate("_25._tcp.mail.example.com.", nil, TLSAUsageDANEEE, TLSASelectorSPKI, TLSAMatchingSHA256, 3600); err == nil {
+		t.Error("expected an error for a missing certificate")
+	}
+}
@@ -7,13 +7,18 @@ import (
 	"github.com/vadim-su/dnska/pkg/dns/types"
 )
 
+// MaxCharacterStringLength is the size limit of a <character-string>, whose
+// length is written in a single byte (RFC 1035 §3.3)
+const MaxCharacterStringLength = 255
+
 // TXTRecord represents a TXT record (text strings)
 type TXTRecord struct {
 	BaseRecord
 	texts []string // List of text strings
 }
 
-// NewTXTRecord creates a new TXT record
+// NewTXTRecord creates a new TXT record. Texts longer than a character
+// string are split when encoded
 func NewTXTRecord(name string, texts []string, ttl uint32) *TXTRecord {
 	return &TXTRecord{
 		BaseRecord: NewBaseRecord(name, types.CLASS_IN, ttl),
@@ -21,11 +26,20 @@ func NewTXTRecord(name string, texts []string, ttl uint32) *TXTRecord {
 	}
 }
 
-// NewTXTRecordFromString creates a new TXT record from a single text string
+// NewTXTRecordFromString creates a new TXT record from a single text string.
+// A text over 255 bytes, e.g. a long SPF policy, is encoded as consecutive
+// character strings which TXT consumers join back together (RFC 7208 §3.3)
 func NewTXTRecordFromString(name, text string, ttl uint32) *TXTRecord {
 	return NewTXTRecord(name, []string{text}, ttl)
 }
 
+// NewTXTRecordFromStrings creates a new TXT record for callers that chunk
+// the text themselves, each part becoming one character string. Parts over
+// 255 bytes are still split when encoded
+func NewTXTRecordFromStrings(name string, parts []string, ttl uint32) *TXTRecord {
+	return NewTXTRecord(name, parts, ttl)
+}
+
 // Type returns the DNS record type
 func (r *TXTRecord) Type() types.DNSType {
 	return types.TYPE_TXT
@@ -36,23 +50,141 @@ func (r *TXTRecord) Texts() []string {
 	return r.texts
 }
 
-// Data returns the text strings as bytes
+// Strings returns the character strings of the record as encoded, texts
+// over 255 bytes split into 255-byte chunks
+func (r *TXTRecord) Strings() []string {
+	strs := make([]string, 0, len(r.texts))
+	for _, text := range r.texts {
+		for len(text) > MaxCharacterStringLength {
+			strs = append(strs, text[:MaxCharacterStringLength])
+			text = text[MaxCharacterStringLength:]
+		}
+		strs = append(strs, text)
+	}
+	return strs
+}
+
+// Text returns the texts joined together, the value of records whose text
+// was chunked into several character strings
+func (r *TXTRecord) Text() string {
+	return strings.Join(r.texts, "")
+}
+
+// Data returns the character strings as bytes
 func (r *TXTRecord) Data() []byte {
 	var data []byte
-	for _, text := range r.texts {
-		// Each text string is prefixed with its length (1 byte)
-		textBytes := []byte(text)
-		data = append(data, byte(len(textBytes)))
-		data = append(data, textBytes...)
+	for _, str := range r.Strings() {
+		// Each character string is prefixed with its length (1 byte)
+		data = append(data, byte(len(str)))
+		data = append(data, str...)
 	}
 	return data
 }
 
-// String returns a string representation of the TXT record
+// String returns the record in presentation format
 func (r *TXTRecord) String() string {
-	quotedTexts := make([]string, len(r.texts))
-	for i, text := range r.texts {
-		quotedTexts[i] = fmt.Sprintf("\"%s\"", text)
+	return r.Presentation()
+}
+
+// QuoteCharacterString returns s as a quoted character string in
+// presentation format, escaping quotes and backslashes and writing bytes
+// outside printable ASCII as \DDD (RFC 1035 §5.1)
+func QuoteCharacterString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c > 0x7E:
+			fmt.Fprintf(&b, `\%03d`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// ParseCharacterStrings parses character strings in presentation format as
+// they follow the type of a TXT record in a zone file. Strings are separated
+// by spaces unless quoted, so `"a b"` is one string and `a b` two. Within
+// a string "\X" is the character X, e.g. \" or \\, and "\DDD" the byte with
+// decimal value DDD. Strings over 255 bytes are accepted, TXTRecord splits
+// them when encoded
+func ParseCharacterStrings(s string) ([]string, error) {
+	var strs []string
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			break
+		}
+
+		quoted := s[0] == '"'
+		if quoted {
+			s = s[1:]
+		}
+
+		var b strings.Builder
+		closed := false
+		for s != "" && !closed {
+			c := s[0]
+			switch {
+			case c == '\\':
+				value, size, err := parseEscape(s)
+				if err != nil {
+					return nil, err
+				}
+				b.WriteByte(value)
+				s = s[size:]
+				continue
+			case quoted && c == '"':
+				closed = true
+			case !quoted && (c == ' ' || c == '\t'):
+				closed = true
+				continue
+			case !quoted && c == '"':
+				return nil, fmt.Errorf("unexpected quote in character string %q", b.String())
+			default:
+				b.WriteByte(c)
+			}
+			s = s[1:]
+		}
+		if quoted && !closed {
+			return nil, fmt.Errorf("unterminated character string %q", b.String())
+		}
+		strs = append(strs, b.String())
+	}
+
+	if len(strs) == 0 {
+		return nil, fmt.Errorf("no character strings")
+	}
+	return strs, nil
+}
+
+// parseEscape decodes the escape sequence s starts with, returning the byte
+// it stands for and the length of the sequence
+func parseEscape(s string) (byte, int, error) {
+	if len(s) < 2 {
+		return 0, 0, fmt.Errorf("dangling escape at the end of a character string")
+	}
+	if s[1] < '0' || s[1] > '9' {
+		return s[1], 2, nil
+	}
+
+	if len(s) < 4 {
+		return 0, 0, fmt.Errorf("invalid escape %q", s)
+	}
+	value := 0
+	for _, digit := range s[1:4] {
+		if digit < '0' || digit > '9' {
+			return 0, 0, fmt.Errorf("invalid escape %q", s[:4])
+		}
+		value = value*10 + int(digit-'0')
+	}
+	if value > 0xFF {
+		return 0, 0, fmt.Errorf("escape %q is not a byte", s[:4])
 	}
-	return fmt.Sprintf("%s %d IN TXT %s", r.name, r.ttl, strings.Join(quotedTexts, " "))
+	return byte(value), 4, nil
 }
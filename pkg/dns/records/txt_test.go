@@ -0,0 +1,113 @@
+package records
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTXTRecord_Strings(t *testing.T) {
+	long := strings.Repeat("a", 255) + strings.Repeat("b", 255) + strings.Repeat("c", 190)
+
+	tests := []struct {
+		name     string
+		texts    []string
+		expected []string
+	}{
+		{name: "short", texts: []string{"v=spf1 -all"}, expected: []string{"v=spf1 -all"}},
+		{name: "empty string", texts: []string{""}, expected: []string{""}},
+		{name: "exactly 255 bytes", texts: []string{strings.Repeat("x", 255)}, expected: []string{strings.Repeat("x", 255)}},
+		{
+			name:     "700 bytes",
+			texts:    []string{long},
+			expected: []string{strings.Repeat("a", 255), strings.Repeat("b", 255), strings.Repeat("c", 190)},
+		},
+		{
+			name:     "chunked by the caller",
+			texts:    []string{"v=spf1 ", "-all", strings.Repeat("d", 256)},
+			expected: []string{"v=spf1 ", "-all", strings.Repeat("d", 255), "d"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := NewTXTRecordFromStrings("example.com", tt.texts, 300)
+			if strs := record.Strings(); !slices.Equal(strs, tt.expected) {
+				t.Errorf("Strings() = %q, expected %q", strs, tt.expected)
+			}
+			if text := record.Text(); text != strings.Join(tt.texts, "") {
+				t.Errorf("Text() = %q, expected the texts joined", text)
+			}
+
+			var expected []byte
+			for _, str := range tt.expected {
+				expected = append(append(expected, byte(len(str))), str...)
+			}
+			if data := record.Data(); !bytes.Equal(data, expected) {
+				t.Errorf("Data() = %q, expected %q", data, expected)
+			}
+		})
+	}
+}
+
+func TestParseCharacterStrings(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    []string
+		expectedErr string
+	}{
+		{name: "quoted", input: `"a b"`, expected: []string{"a b"}},
+		{name: "unquoted", input: `a b`, expected: []string{"a", "b"}},
+		{name: "several quoted", input: `"v=spf1 " "-all"`, expected: []string{"v=spf1 ", "-all"}},
+		{name: "mixed", input: "one \t\"two three\"  four", expected: []string{"one", "two three", "four"}},
+		{name: "empty quoted", input: `""`, expected: []string{""}},
+		{name: "escaped quote", input: `"say \"hi\""`, expected: []string{`say "hi"`}},
+		{name: "escaped backslash", input: `"C:\\dns"`, expected: []string{`C:\dns`}},
+		{name: "decimal escape", input: `"tab\009end\255"`, expected: []string{"tab\tend\xff"}},
+		{name: "escaped space unquoted", input: `a\ b`, expected: []string{"a b"}},
+		{name: "long string", input: `"` + strings.Repeat("x", 300) + `"`, expected: []string{strings.Repeat("x", 300)}},
+		{name: "empty", input: "  ", expectedErr: "no character strings"},
+		{name: "unterminated", input: `"abc`, expectedErr: "unterminated character string"},
+		{name: "quote inside unquoted", input: `ab"c"`, expectedErr: "unexpected quote"},
+		{name: "dangling escape", input: `"abc\`, expectedErr: "dangling escape"},
+		{name: "short decimal escape", input: `"\12"`, expectedErr: "invalid escape"},
+		{name: "escape over 255", input: `"\256"`, expectedErr: "is not a byte"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strs, err := ParseCharacterStrings(tt.input)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("ParseCharacterStrings() error = %v, expected %q", err, tt.expectedErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCharacterStrings() returned error: %v", err)
+			}
+			if !slices.Equal(strs, tt.expected) {
+				t.Errorf("ParseCharacterStrings() = %q, expected %q", strs, tt.expected)
+			}
+		})
+	}
+}
+
+func TestQuoteCharacterString_RoundTrip(t *testing.T) {
+	for _, text := range []string{
+		`v=DKIM1; k=rsa; p="quoted"`,
+		`back\slash`,
+		"control\x00and\x7fhigh\xc3\xa9",
+		strings.Repeat(`"\`, 100),
+	} {
+		strs, err := ParseCharacterStrings(QuoteCharacterString(text))
+		if err != nil {
+			t.Fatalf("ParseCharacterStrings(%s) returned error: %v", QuoteCharacterString(text), err)
+		}
+		if len(strs) != 1 || strs[0] != text {
+			t.Errorf("round trip of %q = %q", text, strs)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+// Package tsig implements transaction signatures (TSIG, RFC 8945, which
+// obsoletes RFC 2845): HMAC signatures of whole DNS messages with a secret
+// key shared by the two parties, as used to authenticate zone transfers and
+// dynamic updates
+package tsig
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// TSIGAlgorithm is the name of a MAC algorithm, in canonical form with a
+// trailing dot
+type TSIGAlgorithm string
+
+// Supported algorithms (RFC 4635)
+const (
+	HMACSHA256 TSIGAlgorithm = "hmac-sha256."
+	HMACSHA512 TSIGAlgorithm = "hmac-sha512."
+)
+
+// DefaultFudge is the clock skew in seconds tolerated between the signer and
+// the verifier, as recommended by RFC 8945 §10
+const DefaultFudge = 300
+
+// TSIG error codes, carried in the Error field of a TSIG record
+const (
+	ERROR_BADSIG  uint16 = 16 // MAC verification failed
+	ERROR_BADKEY  uint16 = 17 // Key not recognized
+	ERROR_BADTIME uint16 = 18 // Time signed outside the fudge
+)
+
+// Verification errors
+var (
+	ErrUnsigned = errors.New("message is not signed")
+	ErrBadKey   = errors.New("TSIG key not recognized")
+	ErrBadSig   = errors.New("TSIG MAC verification failed")
+	ErrBadTime  = errors.New("TSIG time signed outside the fudge")
+)
+
+// ErrorCode returns the TSIG error code reporting err, or 0 for errors
+// without one
+func ErrorCode(err error) uint16 {
+	switch {
+	case errors.Is(err, ErrBadKey):
+		return ERROR_BADKEY
+	case errors.Is(err, ErrBadSig):
+		return ERROR_BADSIG
+	case errors.Is(err, ErrBadTime):
+		return ERROR_BADTIME
+	default:
+		return 0
+	}
+}
+
+// ParseAlgorithm returns the supported algorithm named name, given with or
+// without the trailing dot and in any case
+func ParseAlgorithm(name string) (TSIGAlgorithm, error) {
+	algorithm := TSIGAlgorithm(strings.ToLower(name))
+	if !strings.HasSuffix(string(algorithm), ".") {
+		algorithm += "."
+	}
+
+	switch algorithm {
+	case HMACSHA256, HMACSHA512:
+		return algorithm, nil
+	default:
+		return "", fmt.Errorf("unsupported TSIG algorithm %q", name)
+	}
+}
+
+// hash returns the hash function of the algorithm
+func (a TSIGAlgorithm) hash() (func() hash.Hash, error) {
+	switch a {
+	case HMACSHA256:
+		return sha256.New, nil
+	case HMACSHA512:
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported TSIG algorithm %q", string(a))
+	}
+}
+
+// TSIGRecord is the TSIG pseudo-record ending a signed message. Its owner is
+// the name of the key, its class ANY and its TTL 0
+type TSIGRecord struct {
+	KeyName    utils.DomainName
+	Algorithm  TSIGAlgorithm
+	TimeSigned uint64 // Seconds since the epoch, 48 bits
+	Fudge      uint16 // Seconds of clock skew tolerated
+	MAC        []byte
+	OriginalID uint16 // ID of the message when it was signed
+	Error      uint16 // One of the TSIG error codes or an RCODE
+	OtherData  []byte
+}
+
+// NewTSIGRecord returns the TSIG record of a message with ID originalID
+// signed at timeSigned with the key keyName. The key data is only checked to
+// be usable with algorithm, the record does not keep it; mac may be nil for a
+// record that is still to be signed
+func NewTSIGRecord(keyName string, algorithm TSIGAlgorithm, keyData []byte, timeSigned uint64, fudge uint16, mac []byte, originalID uint16) (*TSIGRecord, error) {
+	name, err := utils.ParseDomainName(keyName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TSIG key name: %w", err)
+	}
+	if algorithm, err = ParseAlgorithm(string(algorithm)); err != nil {
+		return nil, err
+	}
+	if len(keyData) == 0 {
+		return nil, errors.New("TSIG key is empty")
+	}
+	if timeSigned >= 1<<48 {
+		return nil, fmt.Errorf("TSIG time signed %d does not fit in 48 bits", timeSigned)
+	}
+
+	return &TSIGRecord{
+		KeyName:    name,
+		Algorithm:  algorithm,
+		TimeSigned: timeSigned,
+		Fudge:      fudge,
+		MAC:        mac,
+		OriginalID: originalID,
+	}, nil
+}
+
+// RData returns the wire form of the record data (RFC 8945 §4.2). The
+// algorithm name is never compressed
+func (r *TSIGRecord) RData() []byte {
+	algorithm := mustAlgorithmName(r.Algorithm)
+
+	data := make([]byte, 0, len(algorithm)+16+len(r.MAC)+len(r.OtherData))
+	data = append(data, algorithm...)
+	data = appendUint48(data, r.TimeSigned)
+	data = binary.BigEndian.AppendUint16(data, r.Fudge)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(r.MAC)))
+	data = append(data, r.MAC...)
+	data = binary.BigEndian.AppendUint16(data, r.OriginalID)
+	data = binary.BigEndian.AppendUint16(data, r.Error)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(r.OtherData)))
+	return append(data, r.OtherData...)
+}
+
+// ParseTSIGRecord decodes the data of a TSIG record owned by keyName
+func ParseTSIGRecord(keyName utils.DomainName, data []byte) (*TSIGRecord, error) {
+	algorithm, size, err := utils.NewDomainName(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TSIG algorithm name: %w", err)
+	}
+	data = data[size:]
+
+	if len(data) < 10 {
+		return nil, errors.New("TSIG record too short")
+	}
+	record := &TSIGRecord{
+		KeyName:    keyName,
+		Algorithm:  TSIGAlgorithm(algorithm.CanonicalString()),
+		TimeSigned: uint64(binary.BigEndian.Uint16(data))<<32 | uint64(binary.BigEndian.Uint32(data[2:])),
+		Fudge:      binary.BigEndian.Uint16(data[6:]),
+	}
+	macSize := int(binary.BigEndian.Uint16(data[8:]))
+	data = data[10:]
+
+	if len(data) < macSize+6 {
+		return nil, errors.New("TSIG record too short")
+	}
+	record.MAC = data[:macSize]
+	data = data[macSize:]
+
+	record.OriginalID = binary.BigEndian.Uint16(data)
+	record.Error = binary.BigEndian.Uint16(data[2:])
+	otherSize := int(binary.BigEndian.Uint16(data[4:]))
+	data = data[6:]
+
+	if len(data) != otherSize {
+		return nil, errors.New("TSIG other data length mismatch")
+	}
+	record.OtherData = data
+
+	return record, nil
+}
+
+// ComputeMAC returns the MAC of message, a signed message without its TSIG
+// record, carrying the original ID and the additional count without the
+// TSIG record (RFC 8945 §4.3). priorMAC is the MAC of the request a response
+// answers, or of the previous signed message of a multi-message response.
+// Messages following the first one of a response cover the timers only
+func (r *TSIGRecord) ComputeMAC(key, priorMAC, message []byte, timersOnly bool) ([]byte, error) {
+	newHash, err := r.Algorithm.hash()
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(newHash, key)
+	if priorMAC != nil {
+		binary.Write(mac, binary.BigEndian, uint16(len(priorMAC)))
+		mac.Write(priorMAC)
+	}
+	mac.Write(message)
+	mac.Write(r.variables(timersOnly))
+	return mac.Sum(nil), nil
+}
+
+// variables returns the TSIG variables covered by the MAC besides the
+// message: all fields but the MAC and the original ID, names in canonical
+// form, or the timers only
+func (r *TSIGRecord) variables(timersOnly bool) []byte {
+	var data []byte
+	if !timersOnly {
+		data = append(data, canonicalName(r.KeyName)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(types.CLASS_ANY))
+		data = binary.BigEndian.AppendUint32(data, 0) // TTL
+		data = append(data, mustAlgorithmName(r.Algorithm)...)
+	}
+
+	data = appendUint48(data, r.TimeSigned)
+	data = binary.BigEndian.AppendUint16(data, r.Fudge)
+	if timersOnly {
+		return data
+	}
+
+	data = binary.BigEndian.AppendUint16(data, r.Error)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(r.OtherData)))
+	return append(data, r.OtherData...)
+}
+
+// verifyMAC checks the MAC of the record against the one computed with key.
+// MACs truncated to no less than half the hash and 10 bytes are accepted
+// (RFC 8945 §5.2.2.1)
+func (r *TSIGRecord) verifyMAC(key, priorMAC, message []byte, timersOnly bool) error {
+	expected, err := r.ComputeMAC(key, priorMAC, message, timersOnly)
+	if err != nil {
+		return err
+	}
+
+	size := len(r.MAC)
+	if size > len(expected) || size < max(10, len(expected)/2) {
+		return fmt.Errorf("%w: MAC of %d bytes", ErrBadSig, size)
+	}
+	if !hmac.Equal(r.MAC, expected[:size]) {
+		return ErrBadSig
+	}
+	return nil
+}
+
+// verifyTime checks that the record was signed within its fudge of now
+func (r *TSIGRecord) verifyTime(now time.Time) error {
+	signed := int64(r.TimeSigned)
+	if skew := now.Unix() - signed; skew > int64(r.Fudge) || -skew > int64(r.Fudge) {
+		return fmt.Errorf("%w: signed %s", ErrBadTime, time.Unix(signed, 0).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// canonicalName returns the uncompressed wire form of name with its ASCII
+// letters lowercased
+func canonicalName(name utils.DomainName) []byte {
+	canonical, err := utils.ParseDomainName(name.CanonicalString())
+	if err != nil {
+		return name.ToBytes()
+	}
+	return canonical.ToBytes()
+}
+
+// mustAlgorithmName returns the wire form of the algorithm name
+func mustAlgorithmName(algorithm TSIGAlgorithm) []byte {
+	name, err := utils.ParseDomainName(string(algorithm))
+	if err != nil {
+		return []byte{0}
+	}
+	return name.ToBytes()
+}
+
+// appendUint48 appends the low 48 bits of value in network order
+func appendUint48(data []byte, value uint64) []byte {
+	return append(data, byte(value>>40), byte(value>>32), byte(value>>24), byte(value>>16), byte(value>>8), byte(value))
+}
@@ -0,0 +1,292 @@
+package tsig
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+)
+
+var (
+	testKey        = []byte("secret-key-for-testing-12345678")
+	testKeyring    = map[string][]byte{"transfer-key.": testKey}
+	testTimeSigned = uint64(1700000000)
+	testNow        = time.Unix(int64(testTimeSigned), 0)
+)
+
+// MACs computed independently of this package, over the AXFR query for
+// example.org. with ID 0x1234 and the response to it
+const (
+	testQuery         = "123400000001000000000000076578616d706c65036f72670000fc0001"
+	testResponse      = "123484000001000000000000076578616d706c65036f72670000fc0001"
+	testQueryMAC256   = "a5b6d5934f64730d8f3a62ba5e6e9f07d62942254ec192a27338e2e91663a863"
+	testQueryMAC512   = "c435c6ffeb4349bd1ddd6a97499aee01932dbf6ca9effbee20dcf5064dbeb0e634a76c560493e8b030bec129dc12cab8a6f6398f6f6ce62a465fb60f7014d1c1"
+	testResponseMAC   = "d0d7f0a7c404097da6ad28d64d510dfb55ea82c991037bb68b08237bc7aab2f2"
+	testTimersOnlyMAC = "511d25446262176b49d768630e871aa967eb9cca6b2aa8ee57f57d7758ae3202"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("invalid hex %q: %v", s, err)
+	}
+	return data
+}
+
+func newTestRecord(t *testing.T, algorithm TSIGAlgorithm, timeSigned uint64) *TSIGRecord {
+	t.Helper()
+	record, err := NewTSIGRecord("Transfer-Key", algorithm, testKey, timeSigned, DefaultFudge, nil, 0x1234)
+	if err != nil {
+		t.Fatalf("NewTSIGRecord() returned error: %v", err)
+	}
+	return record
+}
+
+// sign computes the MAC of record over msg and returns msg with the record
+// appended
+func sign(t *testing.T, msg []byte, record *TSIGRecord, priorMAC []byte, timersOnly bool) []byte {
+	t.Helper()
+	mac, err := record.ComputeMAC(testKey, priorMAC, msg, timersOnly)
+	if err != nil {
+		t.Fatalf("ComputeMAC() returned error: %v", err)
+	}
+	record.MAC = mac
+	return appendRecord(msg, record)
+}
+
+func appendRecord(msg []byte, record *TSIGRecord) []byte {
+	signed := append([]byte(nil), msg...)
+	binary.BigEndian.PutUint16(signed[10:], binary.BigEndian.Uint16(msg[10:])+1)
+	signed = append(signed, record.KeyName.ToBytes()...)
+	signed = binary.BigEndian.AppendUint16(signed, 250)
+	signed = binary.BigEndian.AppendUint16(signed, 255)
+	signed = binary.BigEndian.AppendUint32(signed, 0)
+	rdata := record.RData()
+	signed = binary.BigEndian.AppendUint16(signed, uint16(len(rdata)))
+	return append(signed, rdata...)
+}
+
+func TestComputeMAC(t *testing.T) {
+	tests := []struct {
+		name       string
+		algorithm  TSIGAlgorithm
+		priorMAC   string
+		message    string
+		timersOnly bool
+		expected   string
+	}{
+		{name: "hmac-sha256 request", algorithm: HMACSHA256, message: testQuery, expected: testQueryMAC256},
+		{name: "hmac-sha512 request", algorithm: HMACSHA512, message: testQuery, expected: testQueryMAC512},
+		{name: "response", algorithm: HMACSHA256, priorMAC: testQueryMAC256, message: testResponse, expected: testResponseMAC},
+		{name: "timers only", algorithm: HMACSHA256, priorMAC: testQueryMAC256, message: testResponse, timersOnly: true, expected: testTimersOnlyMAC},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := newTestRecord(t, tt.algorithm, testTimeSigned)
+
+			var priorMAC []byte
+			if tt.priorMAC != "" {
+				priorMAC = mustHex(t, tt.priorMAC)
+			}
+			mac, err := record.ComputeMAC(testKey, priorMAC, mustHex(t, tt.message), tt.timersOnly)
+			if err != nil {
+				t.Fatalf("ComputeMAC() returned error: %v", err)
+			}
+			if got := hex.EncodeToString(mac); got != tt.expected {
+				t.Errorf("MAC = %s, expected %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewTSIGRecord(t *testing.T) {
+	tests := []struct {
+		name       string
+		keyName    string
+		algorithm  TSIGAlgorithm
+		key        []byte
+		timeSigned uint64
+		wantErr    bool
+	}{
+		{name: "valid", keyName: "transfer-key", algorithm: HMACSHA512, key: testKey, timeSigned: testTimeSigned},
+		{name: "algorithm without dot", keyName: "transfer-key", algorithm: "HMAC-SHA256", key: testKey, timeSigned: testTimeSigned},
+		{name: "unsupported algorithm", keyName: "transfer-key", algorithm: "hmac-md5.sig-alg.reg.int.", key: testKey, wantErr: true},
+		{name: "empty key", keyName: "transfer-key", algorithm: HMACSHA256, wantErr: true},
+		{name: "time beyond 48 bits", keyName: "transfer-key", algorithm: HMACSHA256, key: testKey, timeSigned: 1 << 48, wantErr: true},
+		{name: "invalid key name", keyName: "transfer..key", algorithm: HMACSHA256, key: testKey, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := NewTSIGRecord(tt.keyName, tt.algorithm, tt.key, tt.timeSigned, DefaultFudge, nil, 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewTSIGRecord() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			parsed, err := ParseTSIGRecord(record.KeyName, record.RData())
+			if err != nil {
+				t.Fatalf("ParseTSIGRecord() returned error: %v", err)
+			}
+			if parsed.Algorithm != record.Algorithm || parsed.TimeSigned != tt.timeSigned || parsed.Fudge != DefaultFudge || parsed.OriginalID != 1 {
+				t.Errorf("parsed record %+v does not match %+v", parsed, record)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	query := mustHex(t, testQuery)
+	signed := sign(t, query, newTestRecord(t, HMACSHA256, testTimeSigned), nil, false)
+
+	tampered := append([]byte(nil), signed...)
+	tampered[len(query)-3] = 0x06 // Question for SOA
+
+	truncated := newTestRecord(t, HMACSHA256, testTimeSigned)
+	truncated.MAC = mustHex(t, testQueryMAC256)[:16]
+
+	tooShort := newTestRecord(t, HMACSHA256, testTimeSigned)
+	tooShort.MAC = mustHex(t, testQueryMAC256)[:8]
+
+	// A different ID, as when a forwarder changed it, is covered by the
+	// original ID
+	forwarded := append([]byte(nil), signed...)
+	binary.BigEndian.PutUint16(forwarded, 0x4321)
+
+	tests := []struct {
+		name    string
+		msg     []byte
+		keyring map[string][]byte
+		now     time.Time
+		wantErr error
+	}{
+		{name: "valid", msg: signed, keyring: testKeyring, now: testNow},
+		{name: "key name in another case", msg: signed, keyring: map[string][]byte{"TRANSFER-KEY": testKey}, now: testNow},
+		{name: "within the fudge", msg: signed, keyring: testKeyring, now: testNow.Add(-DefaultFudge * time.Second)},
+		{name: "changed ID", msg: forwarded, keyring: testKeyring, now: testNow},
+		{name: "truncated MAC", msg: appendRecord(query, truncated), keyring: testKeyring, now: testNow},
+		{name: "unsigned", msg: query, keyring: testKeyring, now: testNow, wantErr: ErrUnsigned},
+		{name: "unknown key", msg: signed, keyring: map[string][]byte{"other-key.": testKey}, now: testNow, wantErr: ErrBadKey},
+		{name: "wrong key", msg: signed, keyring: map[string][]byte{"transfer-key.": []byte("another-secret")}, now: testNow, wantErr: ErrBadSig},
+		{name: "tampered message", msg: tampered, keyring: testKeyring, now: testNow, wantErr: ErrBadSig},
+		{name: "MAC truncated too much", msg: appendRecord(query, tooShort), keyring: testKeyring, now: testNow, wantErr: ErrBadSig},
+		{name: "outside the fudge", msg: signed, keyring: testKeyring, now: testNow.Add(301 * time.Second), wantErr: ErrBadTime},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := Verify(tt.msg, tt.keyring, nil, tt.now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, expected %v", err, tt.wantErr)
+			}
+			if err == nil && !record.KeyName.Equal(newTestRecord(t, HMACSHA256, 0).KeyName) {
+				t.Errorf("record key name = %s", record.KeyName.String())
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	query := mustHex(t, testQuery)
+	signed := sign(t, query, newTestRecord(t, HMACSHA512, testTimeSigned), nil, false)
+	binary.BigEndian.PutUint16(signed, 0x4321)
+
+	unsigned, record, err := Split(signed)
+	if err != nil {
+		t.Fatalf("Split() returned error: %v", err)
+	}
+	if !bytes.Equal(unsigned, query) {
+		t.Errorf("unsigned message = %x, expected %x", unsigned, query)
+	}
+	if record.Algorithm != HMACSHA512 || hex.EncodeToString(record.MAC) != testQueryMAC512 {
+		t.Errorf("unexpected record %+v", record)
+	}
+
+	if _, _, err := Split(signed[:len(signed)-1]); err == nil {
+		t.Error("Split() accepted a truncated record")
+	}
+}
+
+func TestStream(t *testing.T) {
+	response := mustHex(t, testResponse)
+	requestMAC := mustHex(t, testQueryMAC256)
+
+	first := sign(t, response, newTestRecord(t, HMACSHA256, testTimeSigned), requestMAC, false)
+	firstMAC := newTestRecord(t, HMACSHA256, testTimeSigned)
+	firstMAC.MAC, _ = firstMAC.ComputeMAC(testKey, requestMAC, response, false)
+
+	// The third message covers the unsigned second one with the timers only
+	covered := append(append([]byte(nil), response...), response...)
+	thirdRecord := newTestRecord(t, HMACSHA256, testTimeSigned)
+	mac, err := thirdRecord.ComputeMAC(testKey, firstMAC.MAC, covered, true)
+	if err != nil {
+		t.Fatalf("ComputeMAC() returned error: %v", err)
+	}
+	thirdRecord.MAC = mac
+	third := appendRecord(response, thirdRecord)
+
+	tests := []struct {
+		name       string
+		messages   [][]byte
+		wantErr    bool
+		wantDone   bool
+		requestMAC []byte
+	}{
+		{name: "single message", messages: [][]byte{first}, wantDone: true},
+		{name: "unsigned message in between", messages: [][]byte{first, response, third}, wantDone: true},
+		{name: "unsigned last message", messages: [][]byte{first, response}},
+		{name: "unsigned first message", messages: [][]byte{response}, wantErr: true},
+		{name: "messages out of order", messages: [][]byte{first, third}, wantErr: true},
+		{name: "other request", messages: [][]byte{first}, requestMAC: mustHex(t, testQueryMAC512)[:32], wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mac := requestMAC
+			if tt.requestMAC != nil {
+				mac = tt.requestMAC
+			}
+			stream := NewStream(testKeyring, mac)
+
+			var err error
+			for _, msg := range tt.messages {
+				if err = stream.Verify(msg, testNow); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if done := stream.Done() == nil; done != tt.wantDone {
+				t.Errorf("Done() succeeded = %v, expected %v", done, tt.wantDone)
+			}
+		})
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected uint16
+	}{
+		{err: ErrBadSig, expected: ERROR_BADSIG},
+		{err: ErrBadKey, expected: ERROR_BADKEY},
+		{err: ErrBadTime, expected: ERROR_BADTIME},
+		{err: ErrUnsigned, expected: 0},
+	}
+
+	for _, tt := range tests {
+		if got := ErrorCode(tt.err); got != tt.expected {
+			t.Errorf("ErrorCode(%v) = %d, expected %d", tt.err, got, tt.expected)
+		}
+	}
+}
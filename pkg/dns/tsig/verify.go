@@ -0,0 +1,184 @@
+package tsig
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// maxUnsignedMessages is the number of consecutive messages of a response
+// that may go unsigned (RFC 8945 §5.3.1)
+const maxUnsignedMessages = 99
+
+// Split separates a signed message into the message the MAC covers, with
+// the original ID and without the TSIG record, and that record. A message
+// whose last additional record is not a TSIG record yields ErrUnsigned
+func Split(msg []byte) ([]byte, *TSIGRecord, error) {
+	if len(msg) < 12 {
+		return nil, nil, errors.New("message too short")
+	}
+
+	questions := int(binary.BigEndian.Uint16(msg[4:]))
+	additional := int(binary.BigEndian.Uint16(msg[10:]))
+	records := int(binary.BigEndian.Uint16(msg[6:])) + int(binary.BigEndian.Uint16(msg[8:])) + additional
+	if additional == 0 {
+		return nil, nil, ErrUnsigned
+	}
+
+	offset := 12
+	for range questions {
+		size, err := nameSize(msg, offset)
+		if err != nil {
+			return nil, nil, err
+		}
+		offset += size + 4
+	}
+
+	var start int
+	var owner *utils.DomainName
+	var recordType types.DNSType
+	var data []byte
+	for range records {
+		start = offset
+		name, size, err := utils.NewDomainNameWithDecompression(msg[offset:], msg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid record name: %w", err)
+		}
+		offset += int(size)
+		if offset+10 > len(msg) {
+			return nil, nil, errors.New("record truncated")
+		}
+
+		length := int(binary.BigEndian.Uint16(msg[offset+8:]))
+		if offset+10+length > len(msg) {
+			return nil, nil, errors.New("record data truncated")
+		}
+		owner, recordType = name, types.DNSType(binary.BigEndian.Uint16(msg[offset:]))
+		data = msg[offset+10 : offset+10+length]
+		offset += 10 + length
+	}
+
+	if recordType != types.TYPE_TSIG {
+		return nil, nil, ErrUnsigned
+	}
+	record, err := ParseTSIGRecord(*owner, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	unsigned := append([]byte(nil), msg[:start]...)
+	binary.BigEndian.PutUint16(unsigned, record.OriginalID)
+	binary.BigEndian.PutUint16(unsigned[10:], uint16(additional-1))
+	return unsigned, record, nil
+}
+
+// nameSize returns the length of the possibly compressed name at offset
+func nameSize(msg []byte, offset int) (int, error) {
+	if offset >= len(msg) {
+		return 0, errors.New("message truncated")
+	}
+	_, size, err := utils.NewDomainNameWithDecompression(msg[offset:], msg)
+	if err != nil {
+		return 0, fmt.Errorf("invalid name: %w", err)
+	}
+	return int(size), nil
+}
+
+// Verify checks the TSIG record of msg with the key of the same name in
+// keyring and returns the record. requestMAC is the MAC of the request when
+// msg is a response to a signed request, nil otherwise. Key names in the
+// keyring are matched case-insensitively
+func Verify(msg []byte, keyring map[string][]byte, requestMAC []byte, now time.Time) (*TSIGRecord, error) {
+	unsigned, record, err := Split(msg)
+	if err != nil {
+		return nil, err
+	}
+	return record, verify(unsigned, record, keyring, requestMAC, false, now)
+}
+
+// verify checks record, found in a message that is unsigned without it
+func verify(unsigned []byte, record *TSIGRecord, keyring map[string][]byte, priorMAC []byte, timersOnly bool, now time.Time) error {
+	key, ok := lookupKey(keyring, record.KeyName)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrBadKey, record.KeyName.String())
+	}
+	if _, err := record.Algorithm.hash(); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadKey, err)
+	}
+
+	if err := record.verifyMAC(key, priorMAC, unsigned, timersOnly); err != nil {
+		return err
+	}
+	return record.verifyTime(now)
+}
+
+// lookupKey returns the key named name in keyring
+func lookupKey(keyring map[string][]byte, name utils.DomainName) ([]byte, bool) {
+	if key, ok := keyring[name.CanonicalString()]; ok {
+		return key, true
+	}
+	for keyName, key := range keyring {
+		if parsed, err := utils.ParseDomainName(keyName); err == nil && parsed.Equal(name) {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// Stream verifies the messages of a response to a signed request that may
+// span several messages, such as a zone transfer. Messages after the first
+// one may go unsigned, up to 99 in a row, and are then covered by the MAC of
+// the next signed message (RFC 8945 §5.3.1)
+type Stream struct {
+	keyring  map[string][]byte
+	priorMAC []byte
+	pending  []byte // Unsigned messages since the last signed one
+	unsigned int
+	signed   int
+}
+
+// NewStream returns a Stream verifying the response to a request signed
+// with requestMAC
+func NewStream(keyring map[string][]byte, requestMAC []byte) *Stream {
+	return &Stream{keyring: keyring, priorMAC: requestMAC}
+}
+
+// Verify checks the next message of the response
+func (s *Stream) Verify(msg []byte, now time.Time) error {
+	unsigned, record, err := Split(msg)
+	if errors.Is(err, ErrUnsigned) {
+		if s.signed == 0 {
+			return fmt.Errorf("first message of the response: %w", err)
+		}
+		if s.unsigned++; s.unsigned > maxUnsignedMessages {
+			return fmt.Errorf("more than %d consecutive messages: %w", maxUnsignedMessages, err)
+		}
+		s.pending = append(s.pending, msg...)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	covered := append(s.pending, unsigned...)
+	if err := verify(covered, record, s.keyring, s.priorMAC, s.signed > 0, now); err != nil {
+		return err
+	}
+
+	s.priorMAC = record.MAC
+	s.pending, s.unsigned = nil, 0
+	s.signed++
+	return nil
+}
+
+// Done reports an error unless the last message of the response was signed
+func (s *Stream) Done() error {
+	if s.signed == 0 || s.unsigned > 0 {
+		return fmt.Errorf("last message of the response: %w", ErrUnsigned)
+	}
+	return nil
+}
@@ -9,6 +9,9 @@ const (
 	CLASS_CS DNSClass = 2 // the CSNET class (Obsolete - used only for examples in some obsolete RFCs)
 	CLASS_CH DNSClass = 3 // the CHAOS class
 	CLASS_HS DNSClass = 4 // Hesiod [Dyer 87]
+
+	CLASS_NONE DNSClass = 254 // no class, used by dynamic updates to delete records (RFC 2136)
+	CLASS_ANY  DNSClass = 255 // any class, the class of TSIG records (RFC 8945)
 )
 
 // String returns the string representation of a DNS class
@@ -22,6 +25,10 @@ func (c DNSClass) String() string {
 		return "CH"
 	case CLASS_HS:
 		return "HS"
+	case CLASS_NONE:
+		return "NONE"
+	case CLASS_ANY:
+		return "ANY"
 	default:
 		return "UNKNOWN"
 	}
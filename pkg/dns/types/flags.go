@@ -0,0 +1,176 @@
+package types
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	flagOpcodeMask = DNSFlag(0xF << BIT_OPCODE_START)
+	flagRcodeMask  = DNSFlag(0xF << BIT_RCODE_START)
+)
+
+// IsQuery reports whether the QR bit marks the message as a query
+func (f DNSFlag) IsQuery() bool {
+	return f&FLAG_QR_RESPONSE == 0
+}
+
+// IsResponse reports whether the QR bit marks the message as a response
+func (f DNSFlag) IsResponse() bool {
+	return f&FLAG_QR_RESPONSE != 0
+}
+
+// GetOpcode returns the 4-bit OPCODE field
+func (f DNSFlag) GetOpcode() uint8 {
+	return uint8((f & flagOpcodeMask) >> BIT_OPCODE_START)
+}
+
+// GetRcode returns the 4-bit RCODE field
+func (f DNSFlag) GetRcode() uint8 {
+	return uint8((f & flagRcodeMask) >> BIT_RCODE_START)
+}
+
+// Opcode returns the OPCODE field
+func (f DNSFlag) Opcode() DNSOpcode {
+	return DNSOpcode(f.GetOpcode())
+}
+
+// Rcode returns the RCODE field
+func (f DNSFlag) Rcode() DNSRCode {
+	return DNSRCode(f.GetRcode())
+}
+
+// SetRcode returns the flags with the RCODE field replaced by rcode
+func (f DNSFlag) SetRcode(rcode DNSRCode) DNSFlag {
+	return NewFlagBuilder(f).SetRcode(uint8(rcode)).Build()
+}
+
+// String renders the flags like dig does, e.g. "QR RD RA RCODE=NOERROR".
+// The opcode is shown unless it is QUERY; unknown codes are shown as numbers
+func (f DNSFlag) String() string {
+	var parts []string
+	for _, bit := range []struct {
+		flag DNSFlag
+		name string
+	}{
+		{FLAG_QR_RESPONSE, "QR"},
+		{FLAG_AA_AUTHORITATIVE, "AA"},
+		{FLAG_TC_TRUNCATED, "TC"},
+		{FLAG_RD_RECURSION_DESIRED, "RD"},
+		{FLAG_RA_RECURSION_AVAILABLE, "RA"},
+		{FLAG_AD_AUTHENTIC_DATA, "AD"},
+		{FLAG_CD_CHECKING_DISABLED, "CD"},
+	} {
+		if f&bit.flag != 0 {
+			parts = append(parts, bit.name)
+		}
+	}
+
+	if opcode := f.Opcode(); opcode != OPCODE_QUERY {
+		name := opcode.String()
+		if name == "UNKNOWN" {
+			name = strconv.Itoa(int(opcode))
+		}
+		parts = append(parts, "OPCODE="+name)
+	}
+
+	rcode := f.Rcode().String()
+	if rcode == "UNKNOWN" {
+		rcode = strconv.Itoa(int(f.Rcode()))
+	}
+	parts = append(parts, "RCODE="+rcode)
+
+	return strings.Join(parts, " ")
+}
+
+// IsRecursionDesired reports whether the RD bit is set
+func (f DNSFlag) IsRecursionDesired() bool {
+	return f&FLAG_RD_RECURSION_DESIRED != 0
+}
+
+// IsRecursionAvailable reports whether the RA bit is set
+func (f DNSFlag) IsRecursionAvailable() bool {
+	return f&FLAG_RA_RECURSION_AVAILABLE != 0
+}
+
+// IsTruncated reports whether the TC bit is set
+func (f DNSFlag) IsTruncated() bool {
+	return f&FLAG_TC_TRUNCATED != 0
+}
+
+// IsAuthoritative reports whether the AA bit is set
+func (f DNSFlag) IsAuthoritative() bool {
+	return f&FLAG_AA_AUTHORITATIVE != 0
+}
+
+// IsAuthenticData reports whether the AD bit is set
+func (f DNSFlag) IsAuthenticData() bool {
+	return f&FLAG_AD_AUTHENTIC_DATA != 0
+}
+
+// IsCheckingDisabled reports whether the CD bit is set
+func (f DNSFlag) IsCheckingDisabled() bool {
+	return f&FLAG_CD_CHECKING_DISABLED != 0
+}
+
+// FlagBuilder builds DNS header flags without manual bit manipulation
+type FlagBuilder struct {
+	flags DNSFlag
+}
+
+// NewFlagBuilder creates a builder starting from the given flags
+func NewFlagBuilder(flags DNSFlag) *FlagBuilder {
+	return &FlagBuilder{flags: flags}
+}
+
+// SetQR marks the message as a response (true) or a query (false)
+func (b *FlagBuilder) SetQR(response bool) *FlagBuilder {
+	return b.setBit(FLAG_QR_RESPONSE, response)
+}
+
+// SetOpcode sets the 4-bit OPCODE field
+func (b *FlagBuilder) SetOpcode(opcode uint8) *FlagBuilder {
+	b.flags = (b.flags &^ flagOpcodeMask) | (DNSFlag(opcode&0xF) << BIT_OPCODE_START)
+	return b
+}
+
+// SetRcode sets the 4-bit RCODE field
+func (b *FlagBuilder) SetRcode(rcode uint8) *FlagBuilder {
+	b.flags = (b.flags &^ flagRcodeMask) | (DNSFlag(rcode&0xF) << BIT_RCODE_START)
+	return b
+}
+
+// SetRD sets or clears the Recursion Desired bit
+func (b *FlagBuilder) SetRD(enabled bool) *FlagBuilder {
+	return b.setBit(FLAG_RD_RECURSION_DESIRED, enabled)
+}
+
+// SetRA sets or clears the Recursion Available bit
+func (b *FlagBuilder) SetRA(enabled bool) *FlagBuilder {
+	return b.setBit(FLAG_RA_RECURSION_AVAILABLE, enabled)
+}
+
+// SetAA sets or clears the Authoritative Answer bit
+func (b *FlagBuilder) SetAA(enabled bool) *FlagBuilder {
+	return b.setBit(FLAG_AA_AUTHORITATIVE, enabled)
+}
+
+// SetTC sets or clears the Truncated bit
+func (b *FlagBuilder) SetTC(enabled bool) *FlagBuilder {
+	return b.setBit(FLAG_TC_TRUNCATED, enabled)
+}
+
+// Build returns the assembled flags
+func (b *FlagBuilder) Build() DNSFlag {
+	return b.flags
+}
+
+// setBit sets or clears a single flag bit
+func (b *FlagBuilder) setBit(bit DNSFlag, enabled bool) *FlagBuilder {
+	if enabled {
+		b.flags |= bit
+	} else {
+		b.flags &^= bit
+	}
+	return b
+}
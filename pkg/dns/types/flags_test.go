@@ -0,0 +1,212 @@
+package types
+
+import "testing"
+
+func TestDNSFlagBooleanAccessors(t *testing.T) {
+	tests := []struct {
+		name     string
+		accessor func(DNSFlag) bool
+		bit      DNSFlag
+	}{
+		{"IsResponse", DNSFlag.IsResponse, FLAG_QR_RESPONSE},
+		{"IsRecursionDesired", DNSFlag.IsRecursionDesired, FLAG_RD_RECURSION_DESIRED},
+		{"IsRecursionAvailable", DNSFlag.IsRecursionAvailable, FLAG_RA_RECURSION_AVAILABLE},
+		{"IsTruncated", DNSFlag.IsTruncated, FLAG_TC_TRUNCATED},
+		{"IsAuthoritative", DNSFlag.IsAuthoritative, FLAG_AA_AUTHORITATIVE},
+		{"IsAuthenticData", DNSFlag.IsAuthenticData, FLAG_AD_AUTHENTIC_DATA},
+		{"IsCheckingDisabled", DNSFlag.IsCheckingDisabled, FLAG_CD_CHECKING_DISABLED},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.accessor(0) {
+				t.Errorf("%s(0) = true, want false", tt.name)
+			}
+			if !tt.accessor(tt.bit) {
+				t.Errorf("%s(0x%04x) = false, want true", tt.name, uint16(tt.bit))
+			}
+			if !tt.accessor(0xFFFF) {
+				t.Errorf("%s(0xFFFF) = false, want true", tt.name)
+			}
+			if tt.accessor(0xFFFF &^ tt.bit) {
+				t.Errorf("%s with only its bit cleared = true, want false", tt.name)
+			}
+		})
+	}
+}
+
+func TestDNSFlagIsQuery(t *testing.T) {
+	if !DNSFlag(0).IsQuery() {
+		t.Error("IsQuery(0) = false, want true")
+	}
+	if FLAG_QR_RESPONSE.IsQuery() {
+		t.Error("IsQuery(QR) = true, want false")
+	}
+	if DNSFlag(0xFFFF&^FLAG_QR_RESPONSE).IsQuery() != true {
+		t.Error("IsQuery should only depend on the QR bit")
+	}
+}
+
+func TestDNSFlagGetOpcode(t *testing.T) {
+	tests := []struct {
+		name     string
+		flags    DNSFlag
+		expected uint8
+	}{
+		{"standard", FLAG_OPCODE_STANDARD, 0},
+		{"inverse", FLAG_OPCODE_INVERSE, 1},
+		{"status", FLAG_OPCODE_STATUS | FLAG_QR_RESPONSE | FLAG_RD_RECURSION_DESIRED, 2},
+		{"update", DNSFlag(OPCODE_UPDATE) << BIT_OPCODE_START, 5},
+		{"maximum", DNSFlag(0xF << BIT_OPCODE_START), 15},
+		{"all bits set", DNSFlag(0xFFFF), 15},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.flags.GetOpcode(); got != tt.expected {
+				t.Errorf("GetOpcode() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDNSFlagGetRcode(t *testing.T) {
+	tests := []struct {
+		name     string
+		flags    DNSFlag
+		expected uint8
+	}{
+		{"no error", FLAG_RCODE_NO_ERROR, 0},
+		{"nxdomain", FLAG_QR_RESPONSE | FLAG_RCODE_NAME_ERROR, 3},
+		{"refused with flags", FLAG_QR_RESPONSE | FLAG_AA_AUTHORITATIVE | FLAG_RCODE_REFUSED, 5},
+		{"all bits set", DNSFlag(0xFFFF), 15},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.flags.GetRcode(); got != tt.expected {
+				t.Errorf("GetRcode() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFlagBuilder(t *testing.T) {
+	tests := []struct {
+		name     string
+		build    func() DNSFlag
+		expected DNSFlag
+	}{
+		{
+			name:     "empty",
+			build:    func() DNSFlag { return NewFlagBuilder(0).Build() },
+			expected: 0,
+		},
+		{
+			name: "standard query",
+			build: func() DNSFlag {
+				return NewFlagBuilder(0).SetQR(false).SetOpcode(uint8(OPCODE_QUERY)).SetRD(true).Build()
+			},
+			expected: FLAG_QR_QUERY | FLAG_OPCODE_STANDARD | FLAG_RD_RECURSION_DESIRED,
+		},
+		{
+			name: "authoritative response",
+			build: func() DNSFlag {
+				return NewFlagBuilder(0).SetQR(true).SetAA(true).SetRA(true).SetRcode(uint8(RCODE_NAME_ERROR)).Build()
+			},
+			expected: FLAG_QR_RESPONSE | FLAG_AA_AUTHORITATIVE | FLAG_RA_RECURSION_AVAILABLE | FLAG_RCODE_NAME_ERROR,
+		},
+		{
+			name: "truncated",
+			build: func() DNSFlag {
+				return NewFlagBuilder(FLAG_QR_RESPONSE).SetTC(true).Build()
+			},
+			expected: FLAG_QR_RESPONSE | FLAG_TC_TRUNCATED,
+		},
+		{
+			name: "clear bits",
+			build: func() DNSFlag {
+				return NewFlagBuilder(0xFFFF).SetQR(false).SetRD(false).SetRA(false).SetAA(false).SetTC(false).Build()
+			},
+			expected: DNSFlag(0xFFFF) &^ (FLAG_QR_RESPONSE | FLAG_RD_RECURSION_DESIRED | FLAG_RA_RECURSION_AVAILABLE | FLAG_AA_AUTHORITATIVE | FLAG_TC_TRUNCATED),
+		},
+		{
+			name: "opcode and rcode replaced",
+			build: func() DNSFlag {
+				return NewFlagBuilder(0xFFFF).SetOpcode(uint8(OPCODE_NOTIFY)).SetRcode(uint8(RCODE_REFUSED)).Build()
+			},
+			expected: DNSFlag(0xFFFF)&^(0xF<<BIT_OPCODE_START|0xF) | DNSFlag(OPCODE_NOTIFY)<<BIT_OPCODE_START | FLAG_RCODE_REFUSED,
+		},
+		{
+			name: "out of range values are masked",
+			build: func() DNSFlag {
+				return NewFlagBuilder(0).SetOpcode(0xFF).SetRcode(0xFF).Build()
+			},
+			expected: DNSFlag(0xF<<BIT_OPCODE_START | 0xF),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.build(); got != tt.expected {
+				t.Errorf("Build() = 0x%04x, want 0x%04x", uint16(got), uint16(tt.expected))
+			}
+		})
+	}
+}
+
+func TestFlagBuilderRoundTrip(t *testing.T) {
+	for opcode := range uint8(16) {
+		for rcode := range uint8(16) {
+			flags := NewFlagBuilder(0).SetQR(true).SetOpcode(opcode).SetRcode(rcode).SetRD(true).Build()
+			if flags.GetOpcode() != opcode || flags.GetRcode() != rcode {
+				t.Fatalf("round trip of opcode %d rcode %d got opcode %d rcode %d",
+					opcode, rcode, flags.GetOpcode(), flags.GetRcode())
+			}
+			if !flags.IsResponse() || !flags.IsRecursionDesired() {
+				t.Fatalf("flag bits lost for opcode %d rcode %d", opcode, rcode)
+			}
+		}
+	}
+}
+
+func TestDNSFlagTypedCodes(t *testing.T) {
+	flags := FLAG_QR_RESPONSE | DNSFlag(OPCODE_NOTIFY)<<BIT_OPCODE_START | FLAG_RCODE_REFUSED
+	if got := flags.Opcode(); got != OPCODE_NOTIFY {
+		t.Errorf("Opcode() = %s, want NOTIFY", got)
+	}
+	if got := flags.Rcode(); got != RCODE_REFUSED {
+		t.Errorf("Rcode() = %s, want REFUSED", got)
+	}
+
+	// SetRcode clears the previous code and keeps the other bits
+	flags = DNSFlag(0xFFFF).SetRcode(RCODE_NAME_ERROR)
+	if flags != 0xFFF0|FLAG_RCODE_NAME_ERROR {
+		t.Errorf("SetRcode(NXDOMAIN) = 0x%04x, want 0x%04x", uint16(flags), uint16(0xFFF0|FLAG_RCODE_NAME_ERROR))
+	}
+	if flags = flags.SetRcode(RCODE_NO_ERROR); flags.Rcode() != RCODE_NO_ERROR {
+		t.Errorf("SetRcode(NOERROR) left RCODE %s", flags.Rcode())
+	}
+}
+
+func TestDNSFlagString(t *testing.T) {
+	tests := []struct {
+		name     string
+		flags    DNSFlag
+		expected string
+	}{
+		{"query", 0, "RCODE=NOERROR"},
+		{"recursive response", FLAG_QR_RESPONSE | FLAG_RD_RECURSION_DESIRED | FLAG_RA_RECURSION_AVAILABLE, "QR RD RA RCODE=NOERROR"},
+		{"authoritative nxdomain", FLAG_QR_RESPONSE | FLAG_AA_AUTHORITATIVE | FLAG_RCODE_NAME_ERROR, "QR AA RCODE=NXDOMAIN"},
+		{"notify", FLAG_AA_AUTHORITATIVE | DNSFlag(OPCODE_NOTIFY)<<BIT_OPCODE_START, "AA OPCODE=NOTIFY RCODE=NOERROR"},
+		{"all bits set", DNSFlag(0xFFFF), "QR AA TC RD RA AD CD OPCODE=15 RCODE=15"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.flags.String(); got != tt.expected {
+				t.Errorf("String() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -16,6 +16,10 @@ const (
 	RCODE_NXRRSET         DNSRCode = 8  // RR set that should exist does not
 	RCODE_NOT_AUTH        DNSRCode = 9  // Server not authoritative for zone
 	RCODE_NOT_ZONE        DNSRCode = 10 // Name not contained in zone
+
+	// Extended response codes, whose upper 8 bits are carried in the OPT
+	// record (RFC 6891 §6.1.3)
+	RCODE_BADCOOKIE DNSRCode = 23 // Bad or missing server cookie (RFC 7873)
 )
 
 // String returns the string representation of a DNS response code
@@ -43,6 +47,8 @@ func (r DNSRCode) String() string {
 		return "NOTAUTH"
 	case RCODE_NOT_ZONE:
 		return "NOTZONE"
+	case RCODE_BADCOOKIE:
+		return "BADCOOKIE"
 	default:
 		return "UNKNOWN"
 	}
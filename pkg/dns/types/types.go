@@ -1,27 +1,47 @@
 package types
 
+import (
+	"strconv"
+	"strings"
+)
+
 // DNSType represents a DNS record type
 type DNSType uint16
 
 // DNS Type constants
 const (
-	TYPE_A     DNSType = 1  // a host address
-	TYPE_NS    DNSType = 2  // an authoritative name server
-	TYPE_MD    DNSType = 3  // a mail destination (Obsolete - use MX)
-	TYPE_MF    DNSType = 4  // a mail forwarder (Obsolete - use MX)
-	TYPE_CNAME DNSType = 5  // the canonical name for an alias
-	TYPE_SOA   DNSType = 6  // marks the start of a zone of authority
-	TYPE_MB    DNSType = 7  // a mailbox domain name (EXPERIMENTAL)
-	TYPE_MG    DNSType = 8  // a mail group member (EXPERIMENTAL)
-	TYPE_MR    DNSType = 9  // a mail rename domain name (EXPERIMENTAL)
-	TYPE_NULL  DNSType = 10 // a null RR (EXPERIMENTAL)
-	TYPE_WKS   DNSType = 11 // a well known service description
-	TYPE_PTR   DNSType = 12 // a domain name pointer
-	TYPE_HINFO DNSType = 13 // host information
-	TYPE_MINFO DNSType = 14 // mailbox or mail list information
-	TYPE_MX    DNSType = 15 // mail exchange
-	TYPE_TXT   DNSType = 16 // text strings
-	TYPE_AAAA  DNSType = 28 // IPv6 host address
+	TYPE_A      DNSType = 1   // a host address
+	TYPE_NS     DNSType = 2   // an authoritative name server
+	TYPE_MD     DNSType = 3   // a mail destination (Obsolete - use MX)
+	TYPE_MF     DNSType = 4   // a mail forwarder (Obsolete - use MX)
+	TYPE_CNAME  DNSType = 5   // the canonical name for an alias
+	TYPE_SOA    DNSType = 6   // marks the start of a zone of authority
+	TYPE_MB     DNSType = 7   // a mailbox domain name (EXPERIMENTAL)
+	TYPE_MG     DNSType = 8   // a mail group member (EXPERIMENTAL)
+	TYPE_MR     DNSType = 9   // a mail rename domain name (EXPERIMENTAL)
+	TYPE_NULL   DNSType = 10  // a null RR (EXPERIMENTAL)
+	TYPE_WKS    DNSType = 11  // a well known service description
+	TYPE_PTR    DNSType = 12  // a domain name pointer
+	TYPE_HINFO  DNSType = 13  // host information
+	TYPE_MINFO  DNSType = 14  // mailbox or mail list information
+	TYPE_MX     DNSType = 15  // mail exchange
+	TYPE_TXT    DNSType = 16  // text strings
+	TYPE_AAAA   DNSType = 28  // IPv6 host address
+	TYPE_SRV    DNSType = 33  // service location (RFC 2782)
+	TYPE_NAPTR  DNSType = 35  // naming authority pointer (RFC 2915)
+	TYPE_DNAME  DNSType = 39  // redirection of a subtree of names (RFC 6672)
+	TYPE_OPT    DNSType = 41  // EDNS(0) pseudo-record (RFC 6891)
+	TYPE_DS     DNSType = 43  // delegation signer (RFC 4034)
+	TYPE_RRSIG  DNSType = 46  // signature over an RRset (RFC 4034)
+	TYPE_NSEC   DNSType = 47  // next secure record, authenticated denial (RFC 4034)
+	TYPE_DNSKEY DNSType = 48  // public key of a zone (RFC 4034)
+	TYPE_TLSA   DNSType = 52  // TLS certificate association (RFC 6698)
+	TYPE_SVCB   DNSType = 64  // general purpose service binding (RFC 9460)
+	TYPE_HTTPS  DNSType = 65  // service binding for HTTPS (RFC 9460)
+	TYPE_TSIG   DNSType = 250 // transaction signature (RFC 8945)
+	TYPE_AXFR   DNSType = 252 // a request for a transfer of an entire zone
+	TYPE_ANY    DNSType = 255 // a request for all records (also written "*")
+	TYPE_CAA    DNSType = 257 // certification authority authorization (RFC 8659)
 )
 
 // DNS Header flag constants
@@ -54,7 +74,10 @@ const (
 	FLAG_RA_RECURSION_AVAILABLE     = DNSFlag(1 << 7) // Recursion Available
 	FLAG_RA_RECURSION_NOT_AVAILABLE = DNSFlag(0 << 7) // Recursion Not Available
 
-	FLAG_Z_RESERVED = DNSFlag(0 << 4) // Reserved (3 bits)
+	FLAG_Z_RESERVED = DNSFlag(0 << 6) // Reserved (1 bit)
+
+	FLAG_AD_AUTHENTIC_DATA    = DNSFlag(1 << 5) // Authentic Data (RFC 4035)
+	FLAG_CD_CHECKING_DISABLED = DNSFlag(1 << 4) // Checking Disabled (RFC 4035)
 
 	FLAG_RCODE_NO_ERROR        = DNSFlag(0) // No error
 	FLAG_RCODE_FORMAT_ERROR    = DNSFlag(1) // Format error
@@ -101,12 +124,74 @@ func (t DNSType) String() string {
 		return "TXT"
 	case TYPE_AAAA:
 		return "AAAA"
+	case TYPE_SRV:
+		return "SRV"
+	case TYPE_NAPTR:
+		return "NAPTR"
+	case TYPE_DNAME:
+		return "DNAME"
+	case TYPE_OPT:
+		return "OPT"
+	case TYPE_DS:
+		return "DS"
+	case TYPE_RRSIG:
+		return "RRSIG"
+	case TYPE_NSEC:
+		return "NSEC"
+	case TYPE_DNSKEY:
+		return "DNSKEY"
+	case TYPE_TLSA:
+		return "TLSA"
+	case TYPE_SVCB:
+		return "SVCB"
+	case TYPE_HTTPS:
+		return "HTTPS"
+	case TYPE_TSIG:
+		return "TSIG"
+	case TYPE_AXFR:
+		return "AXFR"
+	case TYPE_ANY:
+		return "ANY"
+	case TYPE_CAA:
+		return "CAA"
 	default:
 		return "UNKNOWN"
 	}
 }
 
+// Mnemonic returns the name of the type as String does, or the generic
+// TYPEnnn form of RFC 3597 for types without one
+func (t DNSType) Mnemonic() string {
+	if name := t.String(); name != "UNKNOWN" {
+		return name
+	}
+	return "TYPE" + strconv.Itoa(int(t))
+}
+
+// ParseDNSType returns the type named by a mnemonic such as "AAAA", or by
+// the generic TYPEnnn form. It is the inverse of Mnemonic, ignoring case
+func ParseDNSType(name string) (DNSType, bool) {
+	name = strings.ToUpper(name)
+	if number, ok := strings.CutPrefix(name, "TYPE"); ok {
+		value, err := strconv.ParseUint(number, 10, 16)
+		return DNSType(value), err == nil
+	}
+
+	for t := TYPE_A; t <= TYPE_CAA; t++ {
+		if t.String() == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
 // Helper function to convert uint16-based types to [2]byte
 func DnsTypeClassToBytes[T ~uint16](value T) [2]byte {
 	return [2]byte{byte(value >> 8), byte(value & 0xFF)}
 }
+
+// DnsTypeClassFromBytes converts [2]byte in network order back to a
+// uint16-based type, the inverse of DnsTypeClassToBytes
+func DnsTypeClassFromBytes[T ~uint16](data [2]byte) T {
+	return T(uint16(data[0])<<8 | uint16(data[1]))
+}
@@ -4,6 +4,7 @@ package utils
 type CompressionMap struct {
 	nameToOffset map[string]uint16
 	message      []byte
+	presentation []byte // Scratch space for the names looked up
 }
 
 // NewCompressionMap creates a new CompressionMap instance.
@@ -14,9 +15,26 @@ func NewCompressionMap() *CompressionMap {
 	}
 }
 
+// Reset forgets every name registered so far, so the map can serve another
+// message. The storage of the map is kept for reuse, and a zero
+// CompressionMap is ready for use once reset
+func (cm *CompressionMap) Reset() {
+	if cm.nameToOffset == nil {
+		cm.nameToOffset = make(map[string]uint16)
+	}
+	clear(cm.nameToOffset)
+	cm.message = cm.message[:0]
+}
+
 // AddDomainName adds a domain name to the compression map if it doesn't already exist.
 const COMPRESSION_POINTER_MASK = 0xC000 // 11 followed by 14 bits
 
+// MAX_COMPRESSION_OFFSET is the largest offset a compression pointer can address
+const MAX_COMPRESSION_OFFSET = 0x3FFF
+
+// MAX_COMPRESSION_POINTERS caps the number of pointers followed while reading one name
+const MAX_COMPRESSION_POINTERS = 32
+
 // AddDomainName adds a domain name to the compression map if it doesn't already exist.
 func CreateCompressionPointer(offset uint16) []byte {
 	pointer := COMPRESSION_POINTER_MASK | offset
@@ -33,6 +51,32 @@ func ExtractCompressionOffset(data []byte) uint16 {
 	return uint16(data[0]&0x3F)<<8 | uint16(data[1])
 }
 
+// presentationOf returns the presentation format of name in the scratch
+// space of the map, valid until the next call
+func (cm *CompressionMap) presentationOf(name *DomainName) []byte {
+	cm.presentation = name.appendPresentation(cm.presentation[:0], false)
+	return cm.presentation
+}
+
+// lookup returns the offset of a previously written name if it can be
+// addressed by a compression pointer
+func (cm *CompressionMap) lookup(name []byte) (uint16, bool) {
+	offset, exists := cm.nameToOffset[string(name)]
+	if !exists || offset > MAX_COMPRESSION_OFFSET {
+		return 0, false
+	}
+	return offset, true
+}
+
+// register records the offset of a written name unless it is out of the
+// range a compression pointer can address
+func (cm *CompressionMap) register(name string, offset int) {
+	if offset > MAX_COMPRESSION_OFFSET {
+		return
+	}
+	cm.nameToOffset[name] = uint16(offset)
+}
+
 // GetNameToOffset returns a copy of the nameToOffset map for testing
 func (cm *CompressionMap) GetNameToOffset() map[string]uint16 {
 	result := make(map[string]uint16)
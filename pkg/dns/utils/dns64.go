@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+)
+
+// DNS64_WELL_KNOWN_PREFIX is the prefix reserved for IPv4/IPv6 translation
+// (RFC 6052 §2.1)
+const DNS64_WELL_KNOWN_PREFIX = "64:ff9b::/96"
+
+// ParseDNS64Prefix parses an IPv6 prefix to embed IPv4 addresses in. Only
+// the prefix lengths defined by RFC 6052 §2.2 are accepted
+func ParseDNS64Prefix(prefix string) (*net.IPNet, error) {
+	ip, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DNS64 prefix: %w", err)
+	}
+	if ip.To4() != nil {
+		return nil, fmt.Errorf("invalid DNS64 prefix %s: not an IPv6 prefix", prefix)
+	}
+
+	if !isDNS64PrefixLength(network) {
+		return nil, fmt.Errorf("invalid DNS64 prefix %s: length must be 32, 40, 48, 56, 64 or 96", prefix)
+	}
+
+	return network, nil
+}
+
+// EmbedIPv4 builds the IPv4-embedded IPv6 address of ipv4 under prefix
+// (RFC 6052 §2.2). The octets of the IPv4 address follow the prefix, skipping
+// bits 64 to 71 which must be zero; the remaining suffix bits are zero
+func EmbedIPv4(prefix *net.IPNet, ipv4 net.IP) (net.IP, error) {
+	v4 := ipv4.To4()
+	if v4 == nil {
+		return nil, fmt.Errorf("%s is not an IPv4 address", ipv4)
+	}
+
+	if !isDNS64PrefixLength(prefix) {
+		return nil, fmt.Errorf("unsupported DNS64 prefix %s", prefix)
+	}
+
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, prefix.IP.To16().Mask(prefix.Mask))
+
+	ones, _ := prefix.Mask.Size()
+	pos := ones / 8
+	for _, octet := range v4 {
+		if pos == 8 {
+			pos++ // Skip the "u" octet
+		}
+		ip[pos] = octet
+		pos++
+	}
+
+	return ip, nil
+}
+
+// isDNS64PrefixLength reports whether prefix is an IPv6 prefix of a length
+// defined by RFC 6052 §2.2
+func isDNS64PrefixLength(prefix *net.IPNet) bool {
+	ones, bits := prefix.Mask.Size()
+	if bits != 8*net.IPv6len {
+		return false
+	}
+
+	switch ones {
+	case 32, 40, 48, 56, 64, 96:
+		return true
+	default:
+		return false
+	}
+}
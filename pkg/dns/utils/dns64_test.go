@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"net"
+	"testing"
+)
+
+func TestEmbedIPv4(t *testing.T) {
+	// Examples from RFC 6052 §2.4
+	tests := []struct {
+		name     string
+		prefix   string
+		ipv4     string
+		expected string
+	}{
+		{name: "well-known prefix", prefix: "64:ff9b::/96", ipv4: "192.0.2.33", expected: "64:ff9b::c000:221"},
+		{name: "length 32", prefix: "2001:db8::/32", ipv4: "192.0.2.33", expected: "2001:db8:c000:221::"},
+		{name: "length 40", prefix: "2001:db8:100::/40", ipv4: "192.0.2.33", expected: "2001:db8:1c0:2:21::"},
+		{name: "length 48", prefix: "2001:db8:122::/48", ipv4: "192.0.2.33", expected: "2001:db8:122:c000:2:2100::"},
+		{name: "length 56", prefix: "2001:db8:122:300::/56", ipv4: "192.0.2.33", expected: "2001:db8:122:3c0:0:221::"},
+		{name: "length 64", prefix: "2001:db8:122:344::/64", ipv4: "192.0.2.33", expected: "2001:db8:122:344:c0:2:2100:0"},
+		{name: "length 96", prefix: "2001:db8:122:344::/96", ipv4: "192.0.2.33", expected: "2001:db8:122:344::192.0.2.33"},
+		{name: "host bits of prefix are ignored", prefix: "64:ff9b::1:2/96", ipv4: "10.0.0.1", expected: "64:ff9b::a00:1"},
+		{name: "ipv4 in ipv6 form", prefix: "64:ff9b::/96", ipv4: "::ffff:198.51.100.7", expected: "64:ff9b::c633:6407"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix, err := ParseDNS64Prefix(tt.prefix)
+			if err != nil {
+				t.Fatalf("ParseDNS64Prefix(%q) returned error: %v", tt.prefix, err)
+			}
+
+			result, err := EmbedIPv4(prefix, net.ParseIP(tt.ipv4))
+			if err != nil {
+				t.Fatalf("EmbedIPv4() returned error: %v", err)
+			}
+			if !result.Equal(net.ParseIP(tt.expected)) {
+				t.Errorf("EmbedIPv4() = %s, expected %s", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEmbedIPv4RejectsIPv6(t *testing.T) {
+	prefix, err := ParseDNS64Prefix(DNS64_WELL_KNOWN_PREFIX)
+	if err != nil {
+		t.Fatalf("ParseDNS64Prefix() returned error: %v", err)
+	}
+
+	if _, err := EmbedIPv4(prefix, net.ParseIP("2001:db8::1")); err == nil {
+		t.Error("EmbedIPv4() expected error for an IPv6 address")
+	}
+}
+
+func TestParseDNS64Prefix(t *testing.T) {
+	tests := []struct {
+		name        string
+		prefix      string
+		expectedErr bool
+	}{
+		{name: "well-known prefix", prefix: "64:ff9b::/96"},
+		{name: "network specific prefix", prefix: "2001:db8:122:344::/64"},
+		{name: "unsupported length", prefix: "64:ff9b::/80", expectedErr: true},
+		{name: "ipv4 prefix", prefix: "192.0.2.0/24", expectedErr: true},
+		{name: "ipv4-mapped prefix", prefix: "::ffff:0.0.0.0/96", expectedErr: true},
+		{name: "missing length", prefix: "64:ff9b::", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDNS64Prefix(tt.prefix)
+			if (err != nil) != tt.expectedErr {
+				t.Errorf("ParseDNS64Prefix(%q) error = %v, expectedErr %v", tt.prefix, err, tt.expectedErr)
+			}
+		})
+	}
+}
@@ -1,9 +1,21 @@
 package utils
 
-import "fmt"
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"slices"
+	"unicode/utf8"
+)
 
 const (
 	NULL_BYTE = byte('\x00')
+
+	// MAX_LABEL_LENGTH is the maximum length of a single label (RFC 1035 §2.3.4)
+	MAX_LABEL_LENGTH = 63
+	// MAX_DOMAIN_NAME_LENGTH is the maximum length of an encoded domain name,
+	// including length bytes and the terminal zero (RFC 1035 §2.3.4)
+	MAX_DOMAIN_NAME_LENGTH = 255
 )
 
 // Label represents a single label in a domain name
@@ -27,7 +39,8 @@ type DomainName struct {
 	Labels []Label
 }
 
-// NewDomainName creates a new DomainName from raw byte data
+// NewDomainName creates a new DomainName from raw byte data. Labels with
+// non-ASCII bytes must be UTF-8 text with an ACE form (see ToACE)
 func NewDomainName(data []byte) (*DomainName, uint16, error) {
 	var length uint8
 	var labels []Label
@@ -45,98 +58,404 @@ func NewDomainName(data []byte) (*DomainName, uint16, error) {
 			return &DomainName{labels}, size, nil
 		}
 
+		if length > MAX_LABEL_LENGTH {
+			return nil, 0, fmt.Errorf("label too long: %d bytes", length)
+		}
+
+		// size already includes this label's length byte, +1 for the terminal zero
+		if encoded := int(size) + int(length) + 1; encoded > MAX_DOMAIN_NAME_LENGTH {
+			return nil, 0, fmt.Errorf("domain name too long: %d bytes", encoded)
+		}
+
 		if len(data[1:]) < int(length) {
 			return nil, 0, fmt.Errorf("not enough bytes in name's label")
 		}
 
-		labels = append(labels, Label{length, data[1 : length+1]})
+		content := data[1 : length+1]
+		if !validLabelEncoding(content) {
+			return nil, 0, fmt.Errorf("invalid label encoding: %q", content)
+		}
+
+		labels = append(labels, Label{length, content})
 		size += uint16(length) // Count the label content bytes
 		data = data[length+1:] // Move past length byte + label content
 	}
 }
 
-// ToBytes converts the DomainName to its byte representation
-func (d *DomainName) ToBytes() []byte {
-	totalBytes := uint(1) // Count ending byte
+// NewDomainNameFromString creates a DomainName from its presentation form,
+// e.g. "www.example.com." or "www.example.com", as ParseDomainName does.
+// "" and "." denote the root
+func NewDomainNameFromString(name string) (*DomainName, error) {
+	if name == "" {
+		return &DomainName{Labels: []Label{}}, nil
+	}
 
-	for _, label := range d.Labels {
-		totalBytes += uint(label.Length) + 1
+	domainName, err := ParseDomainName(name)
+	if err != nil {
+		return nil, err
 	}
+	return &domainName, nil
+}
 
-	domainName := make([]byte, totalBytes)
+// ParseDomainName parses a domain name in presentation format (RFC 1035
+// §5.1). The trailing dot is optional and "." denotes the root. Within a
+// label "\." is a literal dot, "\DDD" the byte with decimal value DDD and
+// "\X" the character X. Labels written in Unicode, e.g. "münchen", are
+// converted to their A-label "xn--mnchen-3ya" (see ToACE); bytes written as
+// escapes are kept as they are. Empty labels, labels over 63 bytes and names
+// over 255 bytes encoded, counted in A-labels, are rejected
+func ParseDomainName(name string) (DomainName, error) {
+	if name == "" {
+		return DomainName{}, fmt.Errorf("domain name can't be empty")
+	}
+	if name == "." {
+		return DomainName{Labels: []Label{}}, nil
+	}
 
-	cursor := uint(0)
-	for _, label := range d.Labels {
-		labelBytes := label.ToBytes()
-		copy(domainName[cursor:cursor+uint(len(labelBytes))], labelBytes)
-		cursor += uint(len(labelBytes)) // THIS LINE WAS MISSING!
+	var labels []Label
+	var label []byte
+	unicode := false // The label holds unescaped non-ASCII characters
+	encoded := 1     // Terminal zero
+
+	endLabel := func() error {
+		if len(label) == 0 {
+			return fmt.Errorf("empty label in domain name %q", name)
+		}
+		if unicode {
+			ace, err := labelToACE(label)
+			if err != nil {
+				return err
+			}
+			label, unicode = ace, false
+		}
+		if len(label) > MAX_LABEL_LENGTH {
+			return fmt.Errorf("label too long: %d bytes", len(label))
+		}
+		encoded += len(label) + 1
+		labels = append(labels, Label{Length: uint8(len(label)), Content: label})
+		label = nil
+		return nil
+	}
+
+	for i := 0; i < len(name); i++ {
+		switch char := name[i]; {
+		case char == '.':
+			if err := endLabel(); err != nil {
+				return DomainName{}, err
+			}
+		case char == '\\':
+			i++
+			if i == len(name) {
+				return DomainName{}, fmt.Errorf("dangling escape in domain name %q", name)
+			}
+			if !isDigit(name[i]) {
+				label = append(label, name[i])
+				continue
+			}
+			if i+2 >= len(name) || !isDigit(name[i+1]) || !isDigit(name[i+2]) {
+				return DomainName{}, fmt.Errorf("invalid decimal escape in domain name %q", name)
+			}
+			value := int(name[i]-'0')*100 + int(name[i+1]-'0')*10 + int(name[i+2]-'0')
+			if value > 255 {
+				return DomainName{}, fmt.Errorf("invalid decimal escape in domain name %q", name)
+			}
+			label = append(label, byte(value))
+			i += 2
+		default:
+			unicode = unicode || char >= utf8.RuneSelf
+			label = append(label, char)
+		}
 	}
+	// Without a trailing dot the last label is still open
+	if len(label) > 0 || name[len(name)-1] != '.' {
+		if err := endLabel(); err != nil {
+			return DomainName{}, err
+		}
+	}
+
+	if encoded > MAX_DOMAIN_NAME_LENGTH {
+		return DomainName{}, fmt.Errorf("domain name too long: %d bytes", encoded)
+	}
+
+	return DomainName{Labels: labels}, nil
+}
 
-	domainName[len(domainName)-1] = NULL_BYTE
+// isDigit reports whether char is an ASCII decimal digit
+func isDigit(char byte) bool {
+	return '0' <= char && char <= '9'
+}
 
-	return domainName
+// ToBytes converts the DomainName to its byte representation
+func (d *DomainName) ToBytes() []byte {
+	return d.AppendBytes(make([]byte, 0, d.EncodedLength()))
 }
 
-// ToBytesWithCompression converts the DomainName to bytes using DNS name compression
+// AppendBytes appends the uncompressed wire form of the name to dst
+func (d *DomainName) AppendBytes(dst []byte) []byte {
+	for _, label := range d.Labels {
+		dst = append(dst, label.Length)
+		dst = append(dst, label.Content...)
+	}
+	return append(dst, NULL_BYTE)
+}
+
+// ToBytesWithCompression converts the DomainName to bytes using DNS name compression.
+// Offsets beyond the 14-bit range of a compression pointer are never
+// registered or referenced; such names are written as literal labels
 func (d *DomainName) ToBytesWithCompression(
 	compressionMap *CompressionMap,
 	currentOffset uint16,
 ) []byte {
-	// Try to find existing occurrence of this domain name
-	domainString := d.String()
+	return d.AppendBytesWithCompression(nil, compressionMap, currentOffset)
+}
+
+// AppendBytesWithCompression appends the name to dst like
+// ToBytesWithCompression, currentOffset being the message offset at which
+// the name starts
+func (d *DomainName) AppendBytesWithCompression(
+	dst []byte,
+	compressionMap *CompressionMap,
+	currentOffset uint16,
+) []byte {
+	// The presentation of every suffix is a tail of the presentation of the
+	// whole name, so the suffixes are looked up without building new strings
+	key := compressionMap.presentationOf(d)
+	suffixStarts := make([]int, 0, 8)
+	start := 0
+	for _, label := range d.Labels {
+		suffixStarts = append(suffixStarts, start)
+		start += len(label.Content) + 1
+		for _, char := range label.Content {
+			if char == '.' || char == '\\' {
+				start++
+			}
+		}
+	}
 
 	// Check if we can use a pointer for the entire domain
-	if offset, exists := compressionMap.nameToOffset[domainString]; exists {
-		return CreateCompressionPointer(offset)
+	if offset, exists := compressionMap.lookup(key); exists {
+		return append(dst, CreateCompressionPointer(offset)...)
 	}
 
-	result := make([]byte, 0)
-
 	// Check for suffix compression
-	for labelIndex := 0; labelIndex < len(d.Labels); labelIndex++ {
-		suffix := d.getSuffixFrom(labelIndex)
-		suffixString := suffix.String()
-
-		if offset, exists := compressionMap.nameToOffset[suffixString]; exists {
-			// Add the labels before the suffix
-			for idx := range labelIndex {
-				labelBytes := d.Labels[idx].ToBytes()
-				result = append(result, labelBytes...)
+	for labelIndex := 1; labelIndex < len(d.Labels); labelIndex++ {
+		if offset, exists := compressionMap.lookup(key[suffixStarts[labelIndex]:]); exists {
+			// Add the labels before the suffix, then the pointer to it
+			for _, label := range d.Labels[:labelIndex] {
+				dst = append(dst, label.Length)
+				dst = append(dst, label.Content...)
 			}
-			// Add compression pointer
-			pointer := CreateCompressionPointer(offset)
-			result = append(result, pointer...)
-			return result
+			return append(dst, CreateCompressionPointer(offset)...)
 		}
 	}
 
-	// No compression possible, store full domain name
-	fullBytes := d.ToBytes()
-
-	// Store this domain name for future compression
-	compressionMap.nameToOffset[domainString] = currentOffset
-
-	// Also store all suffixes for future compression
+	// No compression possible: store this name and all its suffixes for
+	// future compression
+	domainString := string(key)
+	compressionMap.register(domainString, int(currentOffset))
 	for labelIndex := 1; labelIndex < len(d.Labels); labelIndex++ {
-		suffix := d.getSuffixFrom(labelIndex)
-		suffixOffset := currentOffset + d.getBytesUpToLabel(labelIndex)
-		compressionMap.nameToOffset[suffix.String()] = suffixOffset
+		suffixOffset := int(currentOffset) + int(d.getBytesUpToLabel(labelIndex))
+		compressionMap.register(domainString[suffixStarts[labelIndex]:], suffixOffset)
 	}
 
-	return fullBytes
+	return d.AppendBytes(dst)
 }
 
-// String converts the DomainName to its string representation
+// String converts the DomainName to its presentation form with a trailing
+// dot. Dots and backslashes within labels are escaped, so ParseDomainName
+// reads the result back into the same name; non-ASCII bytes are written as
+// they are and read back in A-label form
 func (d *DomainName) String() string {
+	return d.presentation(false)
+}
+
+// CanonicalString returns the presentation form of the name with ASCII
+// letters lowercased (RFC 4034 §6.2), so names differing in case only share
+// one canonical form
+func (d *DomainName) CanonicalString() string {
+	return d.presentation(true)
+}
+
+// UnicodeString returns the presentation form of the name with A-labels
+// rendered as their Unicode U-labels, e.g. "münchen.de." for
+// "xn--mnchen-3ya.de.". Labels that are not valid A-labels are written as
+// String writes them
+func (d *DomainName) UnicodeString() string {
 	if len(d.Labels) == 0 {
 		return "."
 	}
 
-	result := ""
+	var buffer [MAX_DOMAIN_NAME_LENGTH + 1]byte
+	dst := buffer[:0]
+	for _, label := range d.Labels {
+		if unicode, ok := labelFromACE(label.Content); ok {
+			dst = append(dst, unicode...)
+		} else {
+			dst = appendLabel(dst, label.Content, false)
+		}
+		dst = append(dst, '.')
+	}
+	return string(dst)
+}
+
+// presentation writes the name in presentation format, optionally lowercased
+func (d *DomainName) presentation(lower bool) string {
+	var buffer [MAX_DOMAIN_NAME_LENGTH + 1]byte
+	return string(d.appendPresentation(buffer[:0], lower))
+}
+
+// appendPresentation appends the presentation format of the name to dst,
+// optionally lowercased
+func (d *DomainName) appendPresentation(dst []byte, lower bool) []byte {
+	if len(d.Labels) == 0 {
+		return append(dst, '.')
+	}
+
+	for _, label := range d.Labels {
+		dst = appendLabel(dst, label.Content, lower)
+		dst = append(dst, '.')
+	}
+	return dst
+}
+
+// appendLabel appends the presentation format of a label's content to dst,
+// escaping dots and backslashes and optionally lowercasing ASCII letters
+func appendLabel(dst, content []byte, lower bool) []byte {
+	for _, char := range content {
+		switch {
+		case char == '.' || char == '\\':
+			dst = append(dst, '\\')
+		case lower && 'A' <= char && char <= 'Z':
+			char += 'a' - 'A'
+		}
+		dst = append(dst, char)
+	}
+	return dst
+}
+
+// Equal reports whether two domain names are equal. Labels are compared
+// case-insensitively for ASCII letters only, as required by RFC 4343;
+// other bytes must match exactly
+func (d DomainName) Equal(other DomainName) bool {
+	if len(d.Labels) != len(other.Labels) {
+		return false
+	}
+
+	for idx := range d.Labels {
+		if !equalFoldASCII(d.Labels[idx].Content, other.Labels[idx].Content) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// equalFoldASCII reports whether a and b are equal when ASCII letters are
+// compared case-insensitively
+func equalFoldASCII(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		x, y := a[i], b[i]
+		if 'A' <= x && x <= 'Z' {
+			x += 'a' - 'A'
+		}
+		if 'A' <= y && y <= 'Z' {
+			y += 'a' - 'A'
+		}
+		if x != y {
+			return false
+		}
+	}
+
+	return true
+}
+
+// EqualExact reports whether two domain names are equal byte for byte,
+// including the case of every letter
+func (d DomainName) EqualExact(other DomainName) bool {
+	if len(d.Labels) != len(other.Labels) {
+		return false
+	}
+
+	for idx := range d.Labels {
+		if !bytes.Equal(d.Labels[idx].Content, other.Labels[idx].Content) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Clone returns a copy of the domain name that shares no memory with d, e.g.
+// to keep a name parsed from a buffer that is about to be reused
+func (d DomainName) Clone() DomainName {
+	content := make([]byte, 0, d.EncodedLength())
+	labels := make([]Label, len(d.Labels))
+	for idx, label := range d.Labels {
+		start := len(content)
+		content = append(content, label.Content...)
+		labels[idx] = Label{Length: label.Length, Content: content[start:len(content):len(content)]}
+	}
+	return DomainName{Labels: labels}
+}
+
+// RandomizeCase returns a copy of the domain name with the case of every
+// ASCII letter chosen at random (DNS 0x20 encoding). Non-letter bytes are
+// kept as they are and d itself is not modified
+func (d DomainName) RandomizeCase() DomainName {
+	labels := make([]Label, len(d.Labels))
+
+	for idx, label := range d.Labels {
+		content := make([]byte, len(label.Content))
+		rand.Read(content)
+
+		for i, char := range label.Content {
+			switch {
+			case 'a' <= char && char <= 'z', 'A' <= char && char <= 'Z':
+				content[i] = char&^0x20 | content[i]&0x20
+			default:
+				content[i] = char
+			}
+		}
+
+		labels[idx] = Label{Length: label.Length, Content: content}
+	}
+
+	return DomainName{Labels: labels}
+}
+
+// IsSubdomainOf reports whether d lies within zone, i.e. the labels of zone
+// are a suffix of the labels of d. A name is a subdomain of itself and every
+// name is a subdomain of the root
+func (d DomainName) IsSubdomainOf(zone DomainName) bool {
+	offset := len(d.Labels) - len(zone.Labels)
+	if offset < 0 {
+		return false
+	}
+
+	return DomainName{Labels: d.Labels[offset:]}.Equal(zone)
+}
+
+// Parent returns the domain name with the leftmost label removed.
+// The parent of the root domain is the root itself
+func (d DomainName) Parent() DomainName {
+	if len(d.Labels) == 0 {
+		return d
+	}
+	return DomainName{Labels: d.Labels[1:]}
+}
+
+// LabelStrings returns the labels of the domain name as strings, leftmost
+// first. The root domain has no labels
+func (d DomainName) LabelStrings() []string {
+	labels := make([]string, 0, len(d.Labels))
 	for _, label := range d.Labels {
-		result += string(label.Content) + "."
+		labels = append(labels, string(label.Content))
 	}
-	return result
+	return labels
 }
 
 // getSuffixFrom returns a new DomainName starting from the specified label index
@@ -147,6 +466,11 @@ func (d *DomainName) getSuffixFrom(startIndex int) *DomainName {
 	return &DomainName{Labels: d.Labels[startIndex:]}
 }
 
+// EncodedLength returns the length of the uncompressed wire encoding of the name
+func (d *DomainName) EncodedLength() int {
+	return int(d.getBytesUpToLabel(len(d.Labels))) + 1
+}
+
 // getBytesUpToLabel calculates the byte size of the domain name up to the specified label index
 func (d *DomainName) getBytesUpToLabel(labelIndex int) uint16 {
 	size := uint16(0)
@@ -156,13 +480,21 @@ func (d *DomainName) getBytesUpToLabel(labelIndex int) uint16 {
 	return size
 }
 
-// NewDomainNameWithDecompression creates a DomainName from raw byte data, handling DNS name compression
+// NewDomainNameWithDecompression creates a DomainName from raw byte data, handling DNS name compression.
+// Compression pointers must point strictly backwards, before the start of the
+// labels they continue, which rules out loops; the number of pointers per name
+// is capped and revisiting an offset is reported as a loop as well
 func NewDomainNameWithDecompression(
 	data []byte,
 	originalMessage []byte,
 ) (*DomainName, uint16, error) {
 	var labels []Label
-	var size uint16
+	var size uint16 // Bytes consumed before the first compression pointer
+
+	encoded := 1 // Terminal zero
+	jumped := false
+	visited := make([]uint16, 0, 4)
+	segmentStart := messageOffset(data, originalMessage)
 
 	for {
 		if len(data) == 0 {
@@ -179,40 +511,82 @@ func NewDomainNameWithDecompression(
 
 			// Extract offset from pointer
 			offset := ExtractCompressionOffset(data)
-			size += 2 // Compression pointer is 2 bytes
+			if !jumped {
+				size += 2 // Compression pointer is 2 bytes
+				jumped = true
+			}
+
+			if len(visited) >= MAX_COMPRESSION_POINTERS {
+				return nil, 0, fmt.Errorf("too many compression pointers in domain name")
+			}
+
+			if slices.Contains(visited, offset) {
+				return nil, 0, fmt.Errorf("compression pointer loop detected at offset %d", offset)
+			}
+			visited = append(visited, offset)
 
 			// Follow the pointer to get remaining labels
 			if int(offset) >= len(originalMessage) {
 				return nil, 0, fmt.Errorf("invalid compression offset")
 			}
 
-			remainingDomain, _, err := NewDomainNameWithDecompression(
-				originalMessage[offset:],
-				originalMessage,
-			)
-			if err != nil {
-				return nil, 0, err
+			if int(offset) >= segmentStart {
+				return nil, 0, fmt.Errorf(
+					"compression pointer to offset %d does not point before offset %d", offset, segmentStart)
 			}
 
-			// Combine current labels with pointed domain
-			labels = append(labels, remainingDomain.Labels...)
-			return &DomainName{Labels: labels}, size, nil
+			segmentStart = int(offset)
+			data = originalMessage[offset:]
+			continue
 		}
 
 		// Regular label processing
 		length := firstByte
-		size += 1
+		if !jumped {
+			size += 1
+		}
 
 		if length == NULL_BYTE {
 			return &DomainName{Labels: labels}, size, nil
 		}
 
+		if length > MAX_LABEL_LENGTH {
+			return nil, 0, fmt.Errorf("label too long: %d bytes", length)
+		}
+
+		encoded += int(length) + 1
+		if encoded > MAX_DOMAIN_NAME_LENGTH {
+			return nil, 0, fmt.Errorf("domain name too long: %d bytes", encoded)
+		}
+
 		if len(data[1:]) < int(length) {
 			return nil, 0, fmt.Errorf("not enough bytes in name's label")
 		}
 
 		labels = append(labels, Label{Length: length, Content: data[1 : length+1]})
-		size += uint16(length)
+		if !jumped {
+			size += uint16(length)
+		}
 		data = data[length+1:]
 	}
 }
+
+// messageOffset returns the offset at which data starts inside
+// originalMessage. Data that does not share the message's memory is treated
+// as following the whole message
+func messageOffset(data, originalMessage []byte) int {
+	if len(data) == 0 || len(originalMessage) == 0 {
+		return len(originalMessage)
+	}
+
+	// Compare the ends of the underlying arrays to locate data in the message
+	dataFull := data[:cap(data)]
+	messageFull := originalMessage[:cap(originalMessage)]
+
+	start := len(messageFull) - len(dataFull)
+	if start >= 0 && start < len(originalMessage) && &messageFull[start] == &dataFull[0] {
+		return start
+	}
+
+	return len(originalMessage)
+}
@@ -3,7 +3,9 @@ package utils
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewDomainName(t *testing.T) {
@@ -90,6 +92,25 @@ func TestNewDomainName(t *testing.T) {
 			expected:    nil,
 			expectedErr: true,
 		},
+		{
+			name:  "unicode label",
+			input: []byte{0x06, 'm', 0xc3, 0xbc, 'n', 'c', 'h', 0x00},
+			expected: &DomainName{
+				Labels: []Label{
+					{
+						Length:  6,
+						Content: []byte("m\xc3\xbcnch"),
+					},
+				},
+			},
+			expectedErr: false,
+		},
+		{
+			name:        "non-ASCII label that is not UTF-8",
+			input:       []byte{0x04, 't', 0xff, 's', 't', 0x00},
+			expected:    nil,
+			expectedErr: true,
+		},
 	}
 
 	for _, test := range tests {
@@ -480,6 +501,29 @@ func TestDomainNameToBytesWithCompression(t *testing.T) {
 	}
 }
 
+func TestCompressionMapReset(t *testing.T) {
+	domain, err := NewDomainNameFromString("www.test.com.")
+	if err != nil {
+		t.Fatalf("failed to create domain name: %v", err)
+	}
+	want := []byte{0x03, 'w', 'w', 'w', 0x04, 't', 'e', 's', 't', 0x03, 'c', 'o', 'm', 0x00}
+
+	var cm CompressionMap
+	cm.Reset()
+	for round := range 3 {
+		if got := domain.ToBytesWithCompression(&cm, 12); !reflect.DeepEqual(got, want) {
+			t.Fatalf("round %d: got %v, want %v", round, got, want)
+		}
+		if got := len(cm.nameToOffset); got != 3 {
+			t.Fatalf("round %d: compression map size: got %d, want 3", round, got)
+		}
+		cm.Reset()
+		if got := len(cm.nameToOffset); got != 0 {
+			t.Fatalf("round %d: compression map size after reset: got %d, want 0", round, got)
+		}
+	}
+}
+
 func TestCompressionEdgeCases(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -509,11 +553,29 @@ func TestCompressionEdgeCases(t *testing.T) {
 		},
 		{
 			name:           "nested compression (should handle gracefully)",
-			data:           []byte{0xC0, 0x02},                                                         // pointer to offset 2
-			originalMsg:    []byte{0x00, 0x00, 0xC0, 0x06, 0x00, 0x00, 0x04, 't', 'e', 's', 't', 0x00}, // offset 2 points to offset 6
+			data:           []byte{0xC0, 0x08},                                                         // pointer to offset 8
+			originalMsg:    []byte{0x00, 0x00, 0x04, 't', 'e', 's', 't', 0x00, 0xC0, 0x02, 0x00, 0x00}, // offset 8 points to offset 2
 			expectedLabels: 1,
 			expectedErr:    false,
 		},
+		{
+			name:        "forward pointer",
+			data:        []byte{0xC0, 0x02},                                                         // pointer to offset 2
+			originalMsg: []byte{0x00, 0x00, 0xC0, 0x06, 0x00, 0x00, 0x04, 't', 'e', 's', 't', 0x00}, // offset 2 points forward to offset 6
+			expectedErr: true,
+		},
+		{
+			name:        "pointer loop",
+			data:        []byte{0xC0, 0x02},             // pointer to offset 2
+			originalMsg: []byte{0xC0, 0x02, 0xC0, 0x00}, // offsets 0 and 2 point at each other
+			expectedErr: true,
+		},
+		{
+			name:        "self pointer",
+			data:        []byte{0xC0, 0x00},
+			originalMsg: []byte{0xC0, 0x00},
+			expectedErr: true,
+		},
 	}
 
 	for _, test := range tests {
@@ -539,6 +601,79 @@ func TestCompressionEdgeCases(t *testing.T) {
 	}
 }
 
+// pointerChain returns a message holding the root name at offset 0 followed
+// by n pointers, each pointing at the one before it
+func pointerChain(n int) []byte {
+	message := []byte{0x00}
+	for i := range n {
+		offset := 0
+		if i > 0 {
+			offset = 1 + 2*(i-1)
+		}
+		message = append(message, 0xC0, byte(offset))
+	}
+	return message
+}
+
+func TestDecompressionPointerLoops(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  []byte
+		start    int
+		expected string
+	}{
+		{
+			// Pointer A at offset 2 points to pointer B at offset 0, which
+			// points back to A
+			name:     "pointers referencing each other",
+			message:  []byte{0xC0, 0x02, 0xC0, 0x00},
+			start:    2,
+			expected: "does not point before",
+		},
+		{
+			name:     "pointer to itself",
+			message:  []byte{0x01, 'x', 0xC0, 0x02},
+			start:    2,
+			expected: "does not point before",
+		},
+		{
+			name:     "chain longer than the pointer limit",
+			message:  pointerChain(MAX_COMPRESSION_POINTERS + 1),
+			start:    1 + 2*MAX_COMPRESSION_POINTERS,
+			expected: "too many compression pointers",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan error, 1)
+			go func() {
+				_, _, err := NewDomainNameWithDecompression(tt.message[tt.start:], tt.message)
+				done <- err
+			}()
+
+			select {
+			case err := <-done:
+				if err == nil || !strings.Contains(err.Error(), tt.expected) {
+					t.Errorf("NewDomainNameWithDecompression() error = %v, want it to contain %q", err, tt.expected)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("NewDomainNameWithDecompression() did not return")
+			}
+		})
+	}
+
+	// A chain at the limit is still followed
+	message := pointerChain(MAX_COMPRESSION_POINTERS)
+	name, _, err := NewDomainNameWithDecompression(message[len(message)-2:], message)
+	if err != nil {
+		t.Fatalf("NewDomainNameWithDecompression() returned error for %d pointers: %v", MAX_COMPRESSION_POINTERS, err)
+	}
+	if name.String() != "." {
+		t.Errorf("NewDomainNameWithDecompression() = %s, want .", name)
+	}
+}
+
 func TestDomainNameStringRepresentation(t *testing.T) {
 	tests := []struct {
 		input    []byte
@@ -650,6 +785,21 @@ func TestInvalidDomainNames(t *testing.T) {
 			input:       []byte{0x10, 't', 'e', 's', 't', 0x00},
 			expectedErr: "not enough bytes in name's label",
 		},
+		{
+			name:        "label of 64 bytes",
+			input:       encodeLabels(strings.Repeat("a", 64)),
+			expectedErr: "label too long: 64 bytes",
+		},
+		{
+			name:        "label of 200 bytes",
+			input:       encodeLabels(strings.Repeat("a", 200)),
+			expectedErr: "label too long: 200 bytes",
+		},
+		{
+			name:        "name of 256 bytes",
+			input:       encodeLabels(strings.Repeat("a", 63), strings.Repeat("b", 63), strings.Repeat("c", 63), strings.Repeat("d", 62)),
+			expectedErr: "domain name too long: 256 bytes",
+		},
 	}
 
 	for _, test := range tests {
@@ -752,3 +902,476 @@ func TestNewDomainNameWithDecompression(t *testing.T) {
 		})
 	}
 }
+
+// domainFromString builds a DomainName from a dotted string for tests
+func domainFromString(name string) DomainName {
+	var labels []Label
+	for _, part := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if part == "" {
+			continue
+		}
+		labels = append(labels, Label{Length: uint8(len(part)), Content: []byte(part)})
+	}
+	return DomainName{Labels: labels}
+}
+
+func TestDomainNameEqual(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		expected bool
+	}{
+		{name: "identical names", a: "example.com.", b: "example.com.", expected: true},
+		{name: "mixed case", a: "Example.COM.", b: "example.com.", expected: true},
+		{name: "all upper case", a: "WWW.EXAMPLE.COM.", b: "www.example.com.", expected: true},
+		{name: "root domains", a: ".", b: ".", expected: true},
+		{name: "different labels", a: "example.com.", b: "example.org.", expected: false},
+		{name: "different label count", a: "www.example.com.", b: "example.com.", expected: false},
+		{name: "root versus name", a: ".", b: "com.", expected: false},
+		{name: "same letters different split", a: "ab.c.", b: "a.bc.", expected: false},
+		{name: "digits and hyphens", a: "A-1.Example.com.", b: "a-1.example.COM.", expected: true},
+		// RFC 4343 folds ASCII letters only
+		{name: "non-ASCII bytes differing in case", a: "\xc3\x89t\xc3\xa9.com.", b: "\xc3\xa9t\xc3\xa9.com.", expected: false},
+		{name: "non-ASCII bytes with mixed-case letters", a: "\xc3\xa9T\xc3\xa9.com.", b: "\xc3\xa9t\xc3\xa9.COM.", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := domainFromString(tt.a)
+			b := domainFromString(tt.b)
+
+			if got := a.Equal(b); got != tt.expected {
+				t.Errorf("%q.Equal(%q) = %v, want %v", tt.a, tt.b, got, tt.expected)
+			}
+			// Comparison must be symmetric
+			if got := b.Equal(a); got != tt.expected {
+				t.Errorf("%q.Equal(%q) = %v, want %v", tt.b, tt.a, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseDomainName(t *testing.T) {
+	maxLabel := strings.Repeat("a", 63)
+	// 3*(63+1) + (61+1) + 1 = 255 bytes encoded
+	maxName := strings.Join([]string{maxLabel, maxLabel, maxLabel, strings.Repeat("b", 61)}, ".")
+
+	tests := []struct {
+		name           string
+		input          string
+		expectedLabels []string
+		expectedErr    string
+	}{
+		{name: "trailing dot", input: "www.example.com.", expectedLabels: []string{"www", "example", "com"}},
+		{name: "no trailing dot", input: "www.example.com", expectedLabels: []string{"www", "example", "com"}},
+		{name: "mixed case kept", input: "WwW.Example.COM", expectedLabels: []string{"WwW", "Example", "COM"}},
+		{name: "root", input: ".", expectedLabels: []string{}},
+		{name: "escaped dot", input: "first\\.last.example.com.", expectedLabels: []string{"first.last", "example", "com"}},
+		{name: "escaped dot ending a label", input: "a\\..com", expectedLabels: []string{"a.", "com"}},
+		{name: "escaped backslash", input: "back\\\\slash.com", expectedLabels: []string{"back\\slash", "com"}},
+		{name: "decimal escape", input: "\\065\\032b.com", expectedLabels: []string{"A b", "com"}},
+		{name: "unicode label", input: "www.münchen.example", expectedLabels: []string{"www", "xn--mnchen-3ya", "example"}},
+		{name: "uppercase unicode label", input: "MÜNCHEN.Example", expectedLabels: []string{"xn--mnchen-3ya", "Example"}},
+		{name: "mixed script label", input: "中文-english.example", expectedLabels: []string{"xn---english-kd0mm24q", "example"}},
+		{name: "label of 63 bytes", input: maxLabel + ".com", expectedLabels: []string{maxLabel, "com"}},
+		{name: "name of 255 bytes", input: maxName + ".", expectedLabels: strings.Split(maxName, ".")},
+		{name: "empty", input: "", expectedErr: "domain name can't be empty"},
+		{name: "empty label", input: "www..com", expectedErr: `empty label in domain name "www..com"`},
+		{name: "leading dot", input: ".com", expectedErr: `empty label in domain name ".com"`},
+		{name: "label of 64 bytes", input: strings.Repeat("a", 64) + ".com", expectedErr: "label too long: 64 bytes"},
+		{name: "escaped label of 64 bytes", input: strings.Repeat("a", 62) + "\\.\\..com", expectedErr: "label too long: 64 bytes"},
+		{name: "name of 256 bytes", input: maxName + "b", expectedErr: "domain name too long: 256 bytes"},
+		{name: "A-label of 64 bytes", input: strings.Repeat("a", 56) + "ü.com", expectedErr: "label too long: 64 bytes"},
+		{name: "name of 256 bytes in A-labels", input: strings.Join([]string{maxLabel, maxLabel, maxLabel, strings.Repeat("a", 54) + "ü"}, "."), expectedErr: "domain name too long: 256 bytes"},
+		{name: "right-to-left label mixed with latin", input: "עברית-abc.example", expectedErr: `invalid internationalized label "עברית-abc": idna: invalid label "עברית-abc"`},
+		{name: "escaped dot in unicode label", input: "a\\.ü.example", expectedErr: `invalid internationalized label "a.ü": label separator in label`},
+		{name: "dangling escape", input: "www.com\\", expectedErr: `dangling escape in domain name "www.com\\"`},
+		{name: "short decimal escape", input: "a\\06.com", expectedErr: `invalid decimal escape in domain name "a\\06.com"`},
+		{name: "decimal escape over 255", input: "a\\256.com", expectedErr: `invalid decimal escape in domain name "a\\256.com"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			domainName, err := ParseDomainName(tt.input)
+			if tt.expectedErr != "" {
+				if err == nil || err.Error() != tt.expectedErr {
+					t.Fatalf("ParseDomainName(%q) error = %v, want %q", tt.input, err, tt.expectedErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDomainName(%q) returned error: %v", tt.input, err)
+			}
+
+			if labels := domainName.LabelStrings(); !reflect.DeepEqual(labels, tt.expectedLabels) {
+				t.Errorf("ParseDomainName(%q) labels = %q, want %q", tt.input, labels, tt.expectedLabels)
+			}
+
+			// The presentation form reads back into the same name
+			reparsed, err := ParseDomainName(domainName.String())
+			if err != nil || !reparsed.EqualExact(domainName) {
+				t.Errorf("ParseDomainName(%q) = %q, %v, want the name back", domainName.String(), reparsed.LabelStrings(), err)
+			}
+		})
+	}
+}
+
+func TestDomainNameCanonicalString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "www.example.com", expected: "www.example.com."},
+		{input: "WWW.Example.COM.", expected: "www.example.com."},
+		{input: ".", expected: "."},
+		{input: "First\\.Last.Example.com", expected: "first\\.last.example.com."},
+		{input: "\\065-1.com", expected: "a-1.com."},
+		{input: "\\195\\137T\\195\\137.com", expected: "\xc3\x89t\xc3\x89.com."},
+		{input: "\xc3\x89T\xc3\x89.com", expected: "xn--t-9fab.com."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			domainName, err := ParseDomainName(tt.input)
+			if err != nil {
+				t.Fatalf("ParseDomainName(%q) returned error: %v", tt.input, err)
+			}
+			if got := domainName.CanonicalString(); got != tt.expected {
+				t.Errorf("CanonicalString() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDomainNameUnicodeString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "xn--mnchen-3ya.example", expected: "münchen.example."},
+		{input: "WWW.XN--MNCHEN-3YA.Example", expected: "WWW.münchen.Example."},
+		{input: "MÜNCHEN.example", expected: "münchen.example."},
+		{input: "中文-english.example", expected: "中文-english.example."},
+		{input: "xn--zz.example", expected: "xn--zz.example."},
+		{input: "first\\.last.example", expected: "first\\.last.example."},
+		{input: ".", expected: "."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			domainName, err := ParseDomainName(tt.input)
+			if err != nil {
+				t.Fatalf("ParseDomainName(%q) returned error: %v", tt.input, err)
+			}
+			got := domainName.UnicodeString()
+			if got != tt.expected {
+				t.Errorf("UnicodeString() = %q, want %q", got, tt.expected)
+			}
+
+			// The Unicode form reads back into the A-labels
+			reparsed, err := ParseDomainName(got)
+			if err != nil || !reparsed.Equal(domainName) {
+				t.Errorf("ParseDomainName(%q) = %q, %v, want %q", got, reparsed.String(), err, domainName.String())
+			}
+		})
+	}
+}
+
+func TestDomainNameEqualTransitive(t *testing.T) {
+	names := []DomainName{
+		domainFromString("Example.COM."),
+		domainFromString("example.com."),
+		domainFromString("EXAMPLE.com."),
+	}
+
+	for i := range names {
+		if !names[i].Equal(names[i]) {
+			t.Errorf("name %d is not equal to itself", i)
+		}
+	}
+
+	if !names[0].Equal(names[1]) || !names[1].Equal(names[2]) {
+		t.Fatal("expected all names to be pairwise equal")
+	}
+	if !names[0].Equal(names[2]) {
+		t.Error("equality is not transitive")
+	}
+}
+
+func TestDomainNameEqualExact(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		expected bool
+	}{
+		{name: "identical names", a: "example.com.", b: "example.com.", expected: true},
+		{name: "identical mixed case", a: "ExAmPlE.cOm.", b: "ExAmPlE.cOm.", expected: true},
+		{name: "case differs", a: "Example.com.", b: "example.com.", expected: false},
+		{name: "root domains", a: ".", b: ".", expected: true},
+		{name: "different label count", a: "www.example.com.", b: "example.com.", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := domainFromString(tt.a)
+			b := domainFromString(tt.b)
+
+			if got := a.EqualExact(b); got != tt.expected {
+				t.Errorf("%q.EqualExact(%q) = %v, want %v", tt.a, tt.b, got, tt.expected)
+			}
+			if got := b.EqualExact(a); got != tt.expected {
+				t.Errorf("%q.EqualExact(%q) = %v, want %v", tt.b, tt.a, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDomainNameRandomizeCase(t *testing.T) {
+	original := domainFromString("www.a-1_b.example-domain.com.")
+	before := original.String()
+
+	sawUpper, sawLower := false, false
+	for range 64 {
+		randomized := original.RandomizeCase()
+
+		if !randomized.Equal(original) {
+			t.Fatalf("%s is not case-insensitively equal to %s", randomized.String(), before)
+		}
+		if randomized.String() != strings.ToLower(randomized.String()) {
+			sawUpper = true
+		}
+		if randomized.String() != strings.ToUpper(randomized.String()) {
+			sawLower = true
+		}
+	}
+
+	// 64 draws of 24 letters each all coming out in one case is practically impossible
+	if !sawUpper || !sawLower {
+		t.Errorf("case is not randomized: saw upper %v, saw lower %v", sawUpper, sawLower)
+	}
+	if original.String() != before {
+		t.Errorf("original name modified: got %s, want %s", original.String(), before)
+	}
+
+	root := domainFromString(".")
+	if randomized := root.RandomizeCase(); !randomized.EqualExact(root) {
+		t.Errorf("root randomized to %s", randomized.String())
+	}
+}
+
+func TestDomainNameIsSubdomainOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		domain   string
+		zone     string
+		expected bool
+	}{
+		{"direct child", "www.example.com.", "example.com.", true},
+		{"deep child", "a.b.c.example.com.", "example.com.", true},
+		{"same name", "example.com.", "example.com.", true},
+		{"case insensitive", "WWW.Example.COM.", "example.com.", true},
+		{"different zone", "www.example.org.", "example.com.", false},
+		{"partial label match", "www.notexample.com.", "example.com.", false},
+		{"zone longer than name", "com.", "example.com.", false},
+		{"anything under root", "www.example.com.", ".", true},
+		{"root under root", ".", ".", true},
+		{"root under non-root", ".", "com.", false},
+		{"single label", "localhost.", "localhost.", true},
+		{"single label under other", "localhost.", "com.", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := domainFromString(tt.domain).IsSubdomainOf(domainFromString(tt.zone))
+			if result != tt.expected {
+				t.Errorf("%q.IsSubdomainOf(%q) = %v, want %v", tt.domain, tt.zone, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDomainNameParent(t *testing.T) {
+	tests := []struct {
+		name     string
+		domain   string
+		expected string
+	}{
+		{"multi label", "www.example.com.", "example.com."},
+		{"two labels", "example.com.", "com."},
+		{"single label", "com.", "."},
+		{"root", ".", "."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := domainFromString(tt.domain).Parent()
+			if parent.String() != tt.expected {
+				t.Errorf("%q.Parent() = %q, want %q", tt.domain, parent.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestDomainNameLabelStrings(t *testing.T) {
+	tests := []struct {
+		name     string
+		domain   string
+		expected []string
+	}{
+		{"multi label", "www.example.com.", []string{"www", "example", "com"}},
+		{"single label", "localhost.", []string{"localhost"}},
+		{"root", ".", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := domainFromString(tt.domain).LabelStrings()
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("%q.LabelStrings() = %v, want %v", tt.domain, result, tt.expected)
+			}
+		})
+	}
+}
+
+// encodeLabels builds an uncompressed wire-format name from the given labels
+func encodeLabels(labels ...string) []byte {
+	var result []byte
+	for _, label := range labels {
+		result = append(result, byte(len(label)))
+		result = append(result, label...)
+	}
+	return append(result, 0x00)
+}
+
+func TestDomainNameLengthLimits(t *testing.T) {
+	maxLabel := strings.Repeat("a", 63)
+	// 4*(63+1) - 2 + 1 = 255 bytes
+	maxName := encodeLabels(maxLabel, maxLabel, maxLabel, strings.Repeat("b", 61))
+
+	tests := []struct {
+		name        string
+		data        []byte
+		original    []byte
+		expectedErr string
+	}{
+		{
+			name: "label of 63 bytes",
+			data: encodeLabels(maxLabel, "com"),
+		},
+		{
+			name: "name of 255 bytes",
+			data: maxName,
+		},
+		{
+			name:        "label of 64 bytes",
+			data:        encodeLabels(strings.Repeat("a", 64)),
+			expectedErr: "label too long: 64 bytes",
+		},
+		{
+			name:        "label length with reserved 01 prefix",
+			data:        []byte{0x41, 'a', 0x00},
+			expectedErr: "label too long: 65 bytes",
+		},
+		{
+			name:        "name of 256 bytes",
+			data:        encodeLabels(maxLabel, maxLabel, maxLabel, strings.Repeat("b", 62)),
+			expectedErr: "domain name too long: 256 bytes",
+		},
+		{
+			// "x" followed by a pointer to the 255 byte name at offset 0
+			name:        "pointer target makes name too long",
+			data:        []byte{0x01, 'x', 0xC0, 0x00},
+			original:    maxName,
+			expectedErr: "domain name too long: 257 bytes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := tt.original
+			if original == nil {
+				original = tt.data
+			}
+
+			_, _, decompressionErr := NewDomainNameWithDecompression(tt.data, original)
+
+			if tt.original == nil {
+				_, _, err := NewDomainName(tt.data)
+				checkLengthError(t, "NewDomainName", err, tt.expectedErr)
+			}
+			checkLengthError(t, "NewDomainNameWithDecompression", decompressionErr, tt.expectedErr)
+		})
+	}
+}
+
+func checkLengthError(t *testing.T, fn string, err error, expectedErr string) {
+	t.Helper()
+
+	if expectedErr == "" {
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", fn, err)
+		}
+		return
+	}
+
+	if err == nil {
+		t.Errorf("%s: expected error %q but got none", fn, expectedErr)
+		return
+	}
+
+	if err.Error() != expectedErr {
+		t.Errorf("%s: got error %q, want %q", fn, err.Error(), expectedErr)
+	}
+}
+
+func FuzzNewDomainNameWithDecompression(f *testing.F) {
+	seedData := []struct {
+		message []byte
+		start   uint16
+	}{
+		{encodeLabels("www", "example", "com"), 0},
+		{encodeLabels(strings.Repeat("a", 63)), 0},
+		{encodeLabels(strings.Repeat("a", 64)), 0},
+		{encodeLabels(strings.Repeat("a", 63), strings.Repeat("b", 63), strings.Repeat("c", 63), strings.Repeat("d", 62)), 0},
+		{[]byte{0xFF, 0x00}, 0},
+		{[]byte{}, 0},
+		{append(encodeLabels("example", "com"), 0x03, 'w', 'w', 'w', 0xC0, 0x00), 13},
+		// Self pointer
+		{[]byte{0x01, 'x', 0xC0, 0x00}, 0},
+		// Two pointers referencing each other
+		{[]byte{0xC0, 0x02, 0xC0, 0x00}, 2},
+		// Chain of backward pointers longer than the pointer limit
+		{pointerChain(MAX_COMPRESSION_POINTERS + 1), 1 + 2*MAX_COMPRESSION_POINTERS},
+		// Pointer continuing a name past 255 bytes
+		{append(
+			append(encodeLabels(strings.Repeat("a", 63), strings.Repeat("b", 63), strings.Repeat("c", 63)), 0x3F),
+			append([]byte(strings.Repeat("d", 63)), 0xC0, 0x00)...), 193},
+	}
+
+	for _, seed := range seedData {
+		f.Add(seed.message, seed.start)
+	}
+
+	f.Fuzz(func(t *testing.T, message []byte, start uint16) {
+		if int(start) > len(message) {
+			return
+		}
+
+		domainName, _, err := NewDomainNameWithDecompression(message[start:], message)
+		if err != nil {
+			return
+		}
+
+		for _, label := range domainName.Labels {
+			if len(label.Content) > MAX_LABEL_LENGTH {
+				t.Errorf("accepted label of %d bytes", len(label.Content))
+			}
+		}
+
+		if encoded := len(domainName.ToBytes()); encoded > MAX_DOMAIN_NAME_LENGTH {
+			t.Errorf("accepted domain name of %d bytes", encoded)
+		}
+	})
+}
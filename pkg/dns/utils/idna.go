@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// acePrefix starts every A-label (RFC 5890 §2.3.2.1)
+const acePrefix = "xn--"
+
+// idnaProfile maps and validates names for lookup (RFC 5891 §5) but, unlike
+// idna.Lookup, accepts the underscores of service labels such as _dmarc
+var idnaProfile = idna.New(
+	idna.MapForLookup(),
+	idna.BidiRule(),
+	idna.StrictDomainName(false),
+)
+
+// ToACE converts a domain name with Unicode labels, e.g. "münchen.de", to
+// its ASCII compatible encoding "xn--mnchen-3ya.de". Labels are mapped for
+// lookup, so ASCII letters are lowercased. A trailing dot is kept
+func ToACE(input string) (string, error) {
+	ace, err := idnaProfile.ToASCII(input)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert %q to ACE: %w", input, err)
+	}
+	return ace, nil
+}
+
+// FromACE converts a domain name with ACE labels, e.g. "xn--mnchen-3ya.de",
+// to its Unicode form "münchen.de". Labels without the "xn--" prefix are
+// kept as they are
+func FromACE(ace string) (string, error) {
+	unicode, err := idnaProfile.ToUnicode(ace)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert %q from ACE: %w", ace, err)
+	}
+	return unicode, nil
+}
+
+// validLabelEncoding reports whether a label with non-ASCII bytes is UTF-8
+// text that has an ACE form. ASCII labels are always valid
+func validLabelEncoding(content []byte) bool {
+	for _, char := range content {
+		if char >= utf8.RuneSelf {
+			if !utf8.Valid(content) {
+				return false
+			}
+			_, err := idnaProfile.ToASCII(string(content))
+			return err == nil
+		}
+	}
+	return true
+}
+
+// labelToACE converts a single label with Unicode characters to its A-label
+func labelToACE(label []byte) ([]byte, error) {
+	if !utf8.Valid(label) {
+		return nil, fmt.Errorf("invalid label encoding: %q", label)
+	}
+	ace, err := idnaProfile.ToASCII(string(label))
+	if err != nil {
+		return nil, fmt.Errorf("invalid internationalized label %q: %w", label, err)
+	}
+	// Label separators other than "." are mapped to dots for lookup
+	if strings.Contains(ace, ".") {
+		return nil, fmt.Errorf("invalid internationalized label %q: label separator in label", label)
+	}
+	return []byte(ace), nil
+}
+
+// labelFromACE returns the U-label of an A-label, reporting false for
+// labels that are not A-labels or do not decode
+func labelFromACE(label []byte) (string, bool) {
+	if len(label) < len(acePrefix) || !strings.EqualFold(string(label[:len(acePrefix)]), acePrefix) {
+		return "", false
+	}
+	unicode, err := idnaProfile.ToUnicode(string(label))
+	if err != nil || strings.Contains(unicode, ".") {
+		return "", false
+	}
+	return unicode, true
+}
@@ -0,0 +1,105 @@
+package utils
+
+import "testing"
+
+func TestToACE(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    string
+		expectedErr bool
+	}{
+		{input: "münchen.de", expected: "xn--mnchen-3ya.de"},
+		{input: "münchen.de.", expected: "xn--mnchen-3ya.de."},
+		{input: "MÜNCHEN.DE", expected: "xn--mnchen-3ya.de"},
+		{input: "www.bücher.example", expected: "www.xn--bcher-kva.example"},
+		{input: "日本語.jp", expected: "xn--wgv71a119e.jp"},
+		{input: "_dmarc.example.com.", expected: "_dmarc.example.com."},
+		{input: "xn--mnchen-3ya.de", expected: "xn--mnchen-3ya.de"},
+		{input: "xn--zz.de", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ToACE(tt.input)
+			if tt.expectedErr {
+				if err == nil {
+					t.Errorf("ToACE(%q) = %q, expected an error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ToACE(%q) returned error: %v", tt.input, err)
+			}
+			if got != tt.expected {
+				t.Errorf("ToACE(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFromACE(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    string
+		expectedErr bool
+	}{
+		{input: "xn--mnchen-3ya.de", expected: "münchen.de"},
+		{input: "www.xn--bcher-kva.example.", expected: "www.bücher.example."},
+		{input: "xn--wgv71a119e.jp", expected: "日本語.jp"},
+		{input: "www.example.com", expected: "www.example.com"},
+		{input: "xn--zz.de", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := FromACE(tt.input)
+			if tt.expectedErr {
+				if err == nil {
+					t.Errorf("FromACE(%q) = %q, expected an error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FromACE(%q) returned error: %v", tt.input, err)
+			}
+			if got != tt.expected {
+				t.Errorf("FromACE(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestACERoundTrip(t *testing.T) {
+	for _, name := range []string{"münchen.de.", "www.bücher.example.", "日本語.jp.", "пример.испытание.", "www.example.com."} {
+		ace, err := ToACE(name)
+		if err != nil {
+			t.Fatalf("ToACE(%q) returned error: %v", name, err)
+		}
+
+		// The ACE form is plain ASCII and survives the wire encoding
+		for _, char := range []byte(ace) {
+			if char >= 0x80 {
+				t.Fatalf("ToACE(%q) = %q, expected ASCII", name, ace)
+			}
+		}
+		domainName, err := ParseDomainName(ace)
+		if err != nil {
+			t.Fatalf("ParseDomainName(%q) returned error: %v", ace, err)
+		}
+		decoded, _, err := NewDomainName(domainName.ToBytes())
+		if err != nil {
+			t.Fatalf("NewDomainName() of %q returned error: %v", ace, err)
+		}
+
+		unicode, err := FromACE(decoded.String())
+		if err != nil {
+			t.Fatalf("FromACE(%q) returned error: %v", decoded.String(), err)
+		}
+		if unicode != name {
+			t.Errorf("round trip of %q gave %q", name, unicode)
+		}
+		if again, _ := ToACE(unicode); again != ace {
+			t.Errorf("ToACE(%q) = %q after the round trip, want %q", unicode, again, ace)
+		}
+	}
+}
@@ -2,12 +2,15 @@ package integration
 
 import (
 	"context"
+	"net"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/vadim-su/dnska/internal/config"
 	"github.com/vadim-su/dnska/internal/storage"
 	"github.com/vadim-su/dnska/pkg/dns/records"
 	"github.com/vadim-su/dnska/pkg/dns/types"
@@ -36,7 +39,7 @@ func TestConfigLoadingAndStorageInit(t *testing.T) {
 
 		// Test that configuration is applied
 		// Try to add allowed record type
-		aRecord := mustCreateARecord(t, "test.example.com", "192.168.1.1", 300)
+		aRecord := mustBuild(t, records.NewBuilder().Name("test.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1")))
 		err = s.PutRecord(ctx, aRecord)
 		assert.NoError(t, err)
 
@@ -108,6 +111,10 @@ validation:
 			{
 				Type: storage.StorageTypeMemory,
 			},
+			{
+				Type:             storage.StorageTypeSQLite,
+				ConnectionString: filepath.Join(t.TempDir(), "records.db"),
+			},
 			// SurrealDB config would go here if we want to test it
 			// But it requires external database
 		}
@@ -115,11 +122,9 @@ validation:
 		ctx := context.Background()
 		for _, config := range configs {
 			s, err := storage.NewStorage(ctx, &config)
-			if config.Type == storage.StorageTypeMemory {
-				assert.NoError(t, err)
-				assert.NotNil(t, s)
-				s.Close()
-			}
+			assert.NoError(t, err, "storage type %s", config.Type)
+			assert.NotNil(t, s)
+			s.Close()
 		}
 	})
 }
@@ -236,7 +241,7 @@ $TTL 300
 		ctx := context.Background()
 
 		// Add initial record
-		record1 := mustCreateARecord(t, "dynamic.com", "192.168.1.1", 300)
+		record1 := mustBuild(t, records.NewBuilder().Name("dynamic.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1")))
 		require.NoError(t, s.PutRecord(ctx, record1))
 
 		// Update zone file
@@ -256,7 +261,7 @@ www     IN      A       192.168.1.2
 		// 4. Not lose runtime updates
 
 		// Add the new record manually for now
-		record2 := mustCreateARecord(t, "www.dynamic.com", "192.168.1.2", 300)
+		record2 := mustBuild(t, records.NewBuilder().Name("www.dynamic.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.2")))
 		require.NoError(t, s.PutRecord(ctx, record2))
 
 		// Verify both records exist
@@ -284,7 +289,7 @@ func TestConfigReload(t *testing.T) {
 		require.NoError(t, err)
 
 		// Add some records
-		record := mustCreateARecord(t, "test.example.com", "192.168.1.1", 300)
+		record := mustBuild(t, records.NewBuilder().Name("test.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1")))
 		require.NoError(t, s1.PutRecord(ctx, record))
 
 		// Close first storage
@@ -305,12 +310,12 @@ func TestConfigReload(t *testing.T) {
 		defer s2.Close()
 
 		// New storage starts empty (data not persisted in memory storage)
-		records, err := s2.ListRecords(ctx)
+		stored, err := s2.ListRecords(ctx)
 		assert.NoError(t, err)
-		assert.Len(t, records, 0)
+		assert.Len(t, stored, 0)
 
 		// Test new validation rules
-		shortTTLRecord := mustCreateARecord(t, "test.example.com", "192.168.1.1", 60)
+		shortTTLRecord := mustBuild(t, records.NewBuilder().Name("test.example.com").Type(types.TYPE_A).TTL(60).IPv4(net.ParseIP("192.168.1.1")))
 		err = s2.PutRecord(ctx, shortTTLRecord)
 		assert.Error(t, err, "Should reject TTL below new minimum")
 	})
@@ -369,8 +374,8 @@ func TestMultiStorageBackends(t *testing.T) {
 
 		// Add test data
 		testRecords := []records.DNSRecord{
-			mustCreateARecord(t, "example.com", "192.168.1.1", 300),
-			mustCreateAAAARecord(t, "example.com", "2001:db8::1", 300),
+			mustBuild(t, records.NewBuilder().Name("example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1"))),
+			mustBuild(t, records.NewBuilder().Name("example.com").Type(types.TYPE_AAAA).TTL(300).IPv6(net.ParseIP("2001:db8::1"))),
 			records.NewMXRecord("example.com", "mail.example.com", 10, 300),
 			records.NewCNAMERecord("www.example.com", "example.com", 300),
 		}
@@ -429,7 +434,7 @@ func TestMultiStorageBackends(t *testing.T) {
 			}
 
 			// All backends should support basic operations
-			testRecord := mustCreateARecord(t, "test.example.com", "192.168.1.1", 300)
+			testRecord := mustBuild(t, records.NewBuilder().Name("test.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1")))
 
 			// Put
 			err = s.PutRecord(ctx, testRecord)
@@ -453,3 +458,136 @@ func TestMultiStorageBackends(t *testing.T) {
 		}
 	})
 }
+
+// TestServerListenAddressesConfig tests the single address and address list
+// forms of the server listen configuration
+func TestServerListenAddressesConfig(t *testing.T) {
+	tests := []struct {
+		name              string
+		yaml              string
+		env               string
+		expectedAddress   string
+		expectedAddresses []string
+	}{
+		{
+			name:              "single address",
+			yaml:              "server:\n  address: \"127.0.0.1:5353\"\n",
+			expectedAddress:   "127.0.0.1:5353",
+			expectedAddresses: []string{"127.0.0.1:5353"},
+		},
+		{
+			name:              "address list",
+			yaml:              "server:\n  addresses:\n    - \"127.0.0.1:5353\"\n    - \"[::1]:5353\"\n",
+			expectedAddress:   "127.0.0.1:5353",
+			expectedAddresses: []string{"127.0.0.1:5353", "[::1]:5353"},
+		},
+		{
+			name:              "address list from environment",
+			yaml:              "server:\n  address: \"127.0.0.1:5353\"\n",
+			env:               "127.0.0.1:5354, [::1]:5354",
+			expectedAddress:   "127.0.0.1:5354",
+			expectedAddresses: []string{"127.0.0.1:5354", "[::1]:5354"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "dnska.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tt.yaml), 0o600))
+			if tt.env != "" {
+				t.Setenv("DNSKA_SERVER_ADDRESSES", tt.env)
+			}
+
+			cfg, err := config.NewLoader().LoadFromPath(path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedAddress, cfg.Server.Address)
+			assert.Equal(t, tt.expectedAddresses, cfg.Server.ListenAddresses())
+			assert.NoError(t, config.NewValidator().ValidateServerConfig(&cfg.Server))
+		})
+	}
+}
+
+func TestConfigStrictLoading(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dnska.yaml")
+
+	typos := "server:\n  adress: \"127.0.0.1:5353\"\n  parsing:\n    strikt: true\nzones:\n  files:\n    - zone: example.org\n      fil: example.org.zone\n"
+	require.NoError(t, os.WriteFile(path, []byte(typos), 0o600))
+
+	// The lenient loader ignores the typos, the strict one reports each
+	_, err := config.LoadFromFile(path)
+	require.NoError(t, err)
+	_, err = config.LoadFromFileStrict(path)
+	require.Error(t, err)
+	for _, key := range []string{"server.adress", "server.parsing.strikt", "zones.files[0].fil"} {
+		assert.Contains(t, err.Error(), key)
+	}
+
+	valid := "server:\n  address: \"127.0.0.1:5353\"\n  parsing:\n    strict: true\n"
+	require.NoError(t, os.WriteFile(path, []byte(valid), 0o600))
+	cfg, err := config.LoadFromFileStrict(path)
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:5353", cfg.Server.Address)
+	assert.True(t, cfg.Server.Parsing.Strict)
+	assert.Empty(t, config.NewValidator().CheckConfig(cfg))
+}
+
+func TestConfigCheckReportsEveryProblem(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.AllowQuery = []string{"10.0.0.0/33"}
+	cfg.Resolver.ForwardServers = []string{"8.8.8.8"}
+	cfg.Responses.MinTTL = 2 * time.Hour
+	cfg.Responses.MaxTTL = time.Hour
+	cfg.Storage.Type = "postgres"
+	cfg.Hosts.Files = []string{filepath.Join(t.TempDir(), "missing.hosts")}
+
+	problems := config.NewValidator().CheckConfig(cfg)
+	require.Len(t, problems, 5)
+	for i, section := range []string{"server", "resolver", "storage", "responses", "hosts file"} {
+		assert.Contains(t, problems[i].Error(), section)
+	}
+
+	// ValidateConfig stops at the first of them
+	err := config.NewValidator().ValidateConfig(cfg)
+	require.Error(t, err)
+	assert.Equal(t, problems[0].Error(), err.Error())
+}
+
+func TestConfigAdminAPIsRequireLoopback(t *testing.T) {
+	tests := []struct {
+		name          string
+		healthAddress string
+		valid         bool
+	}{
+		{name: "IPv4 loopback", healthAddress: "127.0.0.1:8053", valid: true},
+		{name: "IPv6 loopback", healthAddress: "[::1]:8053", valid: true},
+		{name: "localhost", healthAddress: "localhost:8053", valid: true},
+		{name: "no health address", healthAddress: "", valid: true},
+		{name: "all interfaces", healthAddress: ":8053", valid: false},
+		{name: "public address", healthAddress: "192.0.2.1:8053", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, api := range []struct {
+				enable func(cfg *config.Config)
+				err    string
+			}{
+				{enable: func(cfg *config.Config) { cfg.Server.RecordImport = true }, err: "record import requires a loopback health address"},
+				{enable: func(cfg *config.Config) { cfg.Server.ZoneSnapshots = true }, err: "zone snapshots require a loopback health address"},
+			} {
+				cfg := config.DefaultConfig()
+				cfg.Server.HealthAddress = tt.healthAddress
+				api.enable(cfg)
+
+				for _, err := range []error{cfg.Validate(), config.NewValidator().ValidateServerConfig(&cfg.Server)} {
+					if tt.valid {
+						assert.NoError(t, err)
+					} else {
+						assert.ErrorContains(t, err, api.err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package integration
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/pkg/dns/edns"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+var testClientCookie = []byte{1, 2, 3, 4, 5, 6, 7, 8}
+
+// sendCookieQuery sends an A query for domain carrying cookie over UDP
+func sendCookieQuery(t *testing.T, address, domain string, cookie *edns.Cookie) *message.DNSResponse {
+	t.Helper()
+
+	name, err := utils.ParseDomainName(domain)
+	if err != nil {
+		t.Fatalf("Failed to parse name: %v", err)
+	}
+	query := message.GenerateDNSQuery(uint16(time.Now().UnixNano()&0xFFFF),
+		[]message.DNSQuestion{message.NewDNSQuestion(name, types.TYPE_A, types.CLASS_IN)})
+	query.AddCookie(cookie)
+
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	if _, err := conn.Write(query.ToBytesWithCompression()); err != nil {
+		t.Fatalf("Failed to send query: %v", err)
+	}
+	buffer := make([]byte, message.MaxUDPPayloadSize)
+	n, err := conn.Read(buffer)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	response, err := message.NewDNSResponse(buffer[:n])
+	if err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	return response
+}
+
+// TestDNSCookies tests that the server issues a cookie for the client one
+// and accepts it back
+func TestDNSCookies(t *testing.T) {
+	helper := StartTestServer(t)
+	defer helper.Stop(t)
+
+	helper.AddRecord(t, records.NewARecord("cookie.local", net.IPv4(192, 0, 2, 90), 300))
+
+	response := sendCookieQuery(t, helper.Address, "cookie.local.", &edns.Cookie{Client: testClientCookie})
+	if len(response.Answers) != 1 {
+		t.Fatalf("Expected 1 answer, got %d", len(response.Answers))
+	}
+	issued := response.Cookie()
+	if issued == nil {
+		t.Fatal("Expected a cookie in the response")
+	}
+	if !bytes.Equal(issued.Client, testClientCookie) || len(issued.Server) != 16 {
+		t.Fatalf("Expected the client cookie with a 16-byte server cookie, got %s", issued)
+	}
+
+	// The issued cookie is accepted and issued again for the same client
+	response = sendCookieQuery(t, helper.Address, "cookie.local.", issued)
+	if response.Rcode() != types.RCODE_NO_ERROR || len(response.Answers) != 1 {
+		t.Errorf("Expected an answer for the issued cookie, got %s", response.Rcode())
+	}
+	if cookie := response.Cookie(); cookie == nil || cookie.String() != issued.String() {
+		t.Errorf("Expected cookie %s, got %s", issued, cookie)
+	}
+
+	// Queries without a cookie are answered without one
+	plain := helper.SendDNSQuery(t, "cookie.local", types.TYPE_A)
+	if plain.Cookie() != nil || len(plain.Answers) != 1 {
+		t.Errorf("Expected a plain answer without a cookie, got %d answers", len(plain.Answers))
+	}
+}
+
+// TestDNSCookiesEnforced tests that UDP queries without a valid server
+// cookie get BADCOOKIE along with a fresh one
+func TestDNSCookiesEnforced(t *testing.T) {
+	helper := StartTestServerWithConfig(t, func(cfg *config.Config) {
+		cfg.EDNS.Cookies.Enforce = true
+	})
+	defer helper.Stop(t)
+
+	helper.AddRecord(t, records.NewARecord("cookie.local", net.IPv4(192, 0, 2, 90), 300))
+
+	forged := &edns.Cookie{Client: testClientCookie, Server: bytes.Repeat([]byte{0xAB}, 16)}
+	for _, cookie := range []*edns.Cookie{{Client: testClientCookie}, forged} {
+		response := sendCookieQuery(t, helper.Address, "cookie.local.", cookie)
+		if response.Rcode() != types.RCODE_BADCOOKIE {
+			t.Errorf("Cookie %s: expected BADCOOKIE, got %s", cookie, response.Rcode())
+		}
+		if len(response.Answers) != 0 {
+			t.Errorf("Cookie %s: expected no answers, got %d", cookie, len(response.Answers))
+		}
+		if issued := response.Cookie(); issued == nil || len(issued.Server) != 16 {
+			t.Errorf("Cookie %s: expected a fresh server cookie, got %s", cookie, issued)
+		}
+	}
+
+	issued := sendCookieQuery(t, helper.Address, "cookie.local.", &edns.Cookie{Client: testClientCookie}).Cookie()
+	response := sendCookieQuery(t, helper.Address, "cookie.local.", issued)
+	if response.Rcode() != types.RCODE_NO_ERROR || len(response.Answers) != 1 {
+		t.Errorf("Expected an answer on retry with the issued cookie, got %s", response.Rcode())
+	}
+}
+
+// TestDNSCookiesRateLimiting tests that responses to clients with a valid
+// server cookie are exempt from rate limiting
+func TestDNSCookiesRateLimiting(t *testing.T) {
+	helper := StartTestServerWithConfig(t, func(cfg *config.Config) {
+		cfg.RRL.ResponsesPerSecond = 1
+		cfg.RRL.SlipRate = 1
+		cfg.RRL.WindowSize = 2 * time.Second
+	})
+	defer helper.Stop(t)
+
+	helper.AddRecord(t, records.NewARecord("limited.local", net.IPv4(192, 0, 2, 80), 300))
+
+	// A client cookie alone does not prove the source address
+	client := &edns.Cookie{Client: testClientCookie}
+	var issued *edns.Cookie
+	for i, truncated := range []bool{false, false, true} {
+		response := sendCookieQuery(t, helper.Address, "limited.local.", client)
+		if response.Header.Flags.IsTruncated() != truncated {
+			t.Errorf("Query %d: expected truncated %v, got %v", i+1, truncated, response.Header.Flags.IsTruncated())
+		}
+		if issued == nil {
+			issued = response.Cookie()
+		}
+	}
+	if issued == nil {
+		t.Fatal("Expected a cookie in the response")
+	}
+
+	for i := range 4 {
+		response := sendCookieQuery(t, helper.Address, "limited.local.", issued)
+		if response.Header.Flags.IsTruncated() || len(response.Answers) != 1 {
+			t.Errorf("Query %d with a valid cookie: expected the full answer, got %d answers", i+1, len(response.Answers))
+		}
+	}
+}
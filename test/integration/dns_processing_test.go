@@ -2,6 +2,7 @@ package integration
 
 import (
 	"context"
+	"net"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -66,8 +67,8 @@ func TestDNSQueryProcessingPipeline(t *testing.T) {
 	t.Run("multiple record types for same name", func(t *testing.T) {
 		// Add multiple record types for same domain
 		multiRecords := []records.DNSRecord{
-			mustCreateARecord(t, "multi.example.com", "192.168.1.20", 300),
-			mustCreateAAAARecord(t, "multi.example.com", "2001:db8::20", 300),
+			mustBuild(t, records.NewBuilder().Name("multi.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.20"))),
+			mustBuild(t, records.NewBuilder().Name("multi.example.com").Type(types.TYPE_AAAA).TTL(300).IPv6(net.ParseIP("2001:db8::20"))),
 			records.NewMXRecord("multi.example.com", "mail.example.com", 10, 300),
 			records.NewTXTRecordFromString("multi.example.com", "v=spf1 +all", 300),
 		}
@@ -98,9 +99,9 @@ func TestDNSResponseBuilding(t *testing.T) {
 	t.Run("answer section population", func(t *testing.T) {
 		// Add multiple A records for round-robin
 		rrRecords := []records.DNSRecord{
-			mustCreateARecord(t, "lb.example.com", "192.168.1.1", 300),
-			mustCreateARecord(t, "lb.example.com", "192.168.1.2", 300),
-			mustCreateARecord(t, "lb.example.com", "192.168.1.3", 300),
+			mustBuild(t, records.NewBuilder().Name("lb.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1"))),
+			mustBuild(t, records.NewBuilder().Name("lb.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.2"))),
+			mustBuild(t, records.NewBuilder().Name("lb.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.3"))),
 		}
 
 		for _, r := range rrRecords {
@@ -143,8 +144,8 @@ func TestDNSResponseBuilding(t *testing.T) {
 		nsRecords := []records.DNSRecord{
 			records.NewNSRecord("example.com", "ns1.example.com", 300),
 			records.NewNSRecord("example.com", "ns2.example.com", 300),
-			mustCreateARecord(t, "ns1.example.com", "192.168.1.53", 300),
-			mustCreateARecord(t, "ns2.example.com", "192.168.1.54", 300),
+			mustBuild(t, records.NewBuilder().Name("ns1.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.53"))),
+			mustBuild(t, records.NewBuilder().Name("ns2.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.54"))),
 		}
 
 		for _, r := range nsRecords {
@@ -217,7 +218,7 @@ func TestDNSErrorHandling(t *testing.T) {
 	t.Run("NODATA for domain without requested type", func(t *testing.T) {
 		// Add only A record
 		require.NoError(t, s.PutRecord(ctx,
-			mustCreateARecord(t, "nodata.example.com", "192.168.1.1", 300)))
+			mustBuild(t, records.NewBuilder().Name("nodata.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1")))))
 
 		// Query for AAAA record
 		results, err := s.GetRecords(ctx, "nodata.example.com", types.TYPE_AAAA)
@@ -248,7 +249,7 @@ func TestDNSErrorHandling(t *testing.T) {
 		_, err := s.GetRecords(ctx, "example.com", types.TYPE_A)
 		assert.ErrorIs(t, err, storage.ErrStorageClosed)
 
-		err = s.PutRecord(ctx, mustCreateARecord(t, "test.example.com", "192.168.1.1", 300))
+		err = s.PutRecord(ctx, mustBuild(t, records.NewBuilder().Name("test.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1"))))
 		assert.ErrorIs(t, err, storage.ErrStorageClosed)
 	})
 }
@@ -262,18 +263,18 @@ func TestRecordTypesProcessing(t *testing.T) {
 
 	t.Run("A and AAAA records", func(t *testing.T) {
 		// IPv4
-		aRecord := mustCreateARecord(t, "ipv4.example.com", "192.168.1.1", 300)
+		aRecord := mustBuild(t, records.NewBuilder().Name("ipv4.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1")))
 		require.NoError(t, s.PutRecord(ctx, aRecord))
 
 		// IPv6
-		aaaaRecord := mustCreateAAAARecord(t, "ipv6.example.com", "2001:db8::1", 300)
+		aaaaRecord := mustBuild(t, records.NewBuilder().Name("ipv6.example.com").Type(types.TYPE_AAAA).TTL(300).IPv6(net.ParseIP("2001:db8::1")))
 		require.NoError(t, s.PutRecord(ctx, aaaaRecord))
 
 		// Dual stack
 		require.NoError(t, s.PutRecord(ctx,
-			mustCreateARecord(t, "dual.example.com", "192.168.1.2", 300)))
+			mustBuild(t, records.NewBuilder().Name("dual.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.2")))))
 		require.NoError(t, s.PutRecord(ctx,
-			mustCreateAAAARecord(t, "dual.example.com", "2001:db8::2", 300)))
+			mustBuild(t, records.NewBuilder().Name("dual.example.com").Type(types.TYPE_AAAA).TTL(300).IPv6(net.ParseIP("2001:db8::2")))))
 
 		// Verify retrieval
 		a, err := s.GetRecord(ctx, "ipv4.example.com", types.TYPE_A)
@@ -292,7 +293,7 @@ func TestRecordTypesProcessing(t *testing.T) {
 
 		// CNAME conflicts - can't have other records at same name
 		// This should ideally be prevented by validation
-		aRecord := mustCreateARecord(t, "www.example.com", "192.168.1.1", 300)
+		aRecord := mustBuild(t, records.NewBuilder().Name("www.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1")))
 		err := s.PutRecord(ctx, aRecord)
 		// Current implementation allows this, but real DNS servers shouldn't
 		assert.NoError(t, err) // This is a limitation of current implementation
@@ -349,11 +350,11 @@ func TestZoneTransferData(t *testing.T) {
 		records.NewNSRecord("example.com", "ns2.example.com", 300),
 
 		// A records
-		mustCreateARecord(t, "example.com", "192.168.1.1", 300),
-		mustCreateARecord(t, "www.example.com", "192.168.1.10", 300),
-		mustCreateARecord(t, "mail.example.com", "192.168.1.20", 300),
-		mustCreateARecord(t, "ns1.example.com", "192.168.1.53", 300),
-		mustCreateARecord(t, "ns2.example.com", "192.168.1.54", 300),
+		mustBuild(t, records.NewBuilder().Name("example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1"))),
+		mustBuild(t, records.NewBuilder().Name("www.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.10"))),
+		mustBuild(t, records.NewBuilder().Name("mail.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.20"))),
+		mustBuild(t, records.NewBuilder().Name("ns1.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.53"))),
+		mustBuild(t, records.NewBuilder().Name("ns2.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.54"))),
 
 		// MX records
 		records.NewMXRecord("example.com", "mail.example.com", 10, 300),
@@ -396,7 +397,7 @@ func TestZoneTransferData(t *testing.T) {
 		assert.Equal(t, uint32(2024010101), originalSerial)
 
 		// Add new record (would trigger serial increment in real implementation)
-		newRecord := mustCreateARecord(t, "new.example.com", "192.168.1.100", 300)
+		newRecord := mustBuild(t, records.NewBuilder().Name("new.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.100")))
 		require.NoError(t, s.PutRecord(ctx, newRecord))
 
 		// In real implementation, SOA serial should be incremented
@@ -418,9 +419,9 @@ func setupTestStorage(t *testing.T) storage.Storage {
 
 	// Add some basic test records
 	baseRecords := []records.DNSRecord{
-		mustCreateARecord(t, "example.com", "192.168.1.1", 300),
-		mustCreateARecord(t, "www.example.com", "192.168.1.10", 300),
-		mustCreateAAAARecord(t, "example.com", "2001:db8::1", 300),
+		mustBuild(t, records.NewBuilder().Name("example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1"))),
+		mustBuild(t, records.NewBuilder().Name("www.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.10"))),
+		mustBuild(t, records.NewBuilder().Name("example.com").Type(types.TYPE_AAAA).TTL(300).IPv6(net.ParseIP("2001:db8::1"))),
 		records.NewMXRecord("example.com", "mail.example.com", 10, 300),
 		records.NewNSRecord("example.com", "ns1.example.com", 300),
 	}
@@ -0,0 +1,71 @@
+package integration
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+)
+
+// TestHostsFile tests that names listed in a hosts file are answered
+// authoritatively over UDP, ahead of stored records
+func TestHostsFile(t *testing.T) {
+	helper := StartTestServerWithConfig(t, func(cfg *config.Config) {
+		cfg.Hosts.Files = []string{filepath.Join("testdata", "hosts")}
+		cfg.Hosts.TTL = 30 * time.Second
+	})
+	defer helper.Stop(t)
+
+	helper.AddRecord(t, records.NewARecord("stored.example.test.", net.ParseIP("192.0.2.99"), 300))
+	helper.AddRecord(t, records.NewARecord("other.example.test.", net.ParseIP("192.0.2.98"), 300))
+
+	tests := []struct {
+		name       string
+		domain     string
+		recordType types.DNSType
+		expected   []string
+		rcode      types.DNSRCode
+		hosts      bool
+	}{
+		{name: "multiple addresses", domain: "app.example.test", recordType: types.TYPE_A, expected: []string{"192.0.2.10", "192.0.2.11"}, hosts: true},
+		{name: "IPv6 address", domain: "APP.example.test", recordType: types.TYPE_AAAA, expected: []string{"2001:db8::10"}, hosts: true},
+		{name: "no address of the family", domain: "app", recordType: types.TYPE_AAAA, expected: []string{}, hosts: true},
+		{name: "precedence over storage", domain: "stored.example.test", recordType: types.TYPE_A, expected: []string{"198.51.100.7"}, hosts: true},
+		{name: "unlisted name", domain: "other.example.test", recordType: types.TYPE_A, expected: []string{"192.0.2.98"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := helper.SendDNSQuery(t, tt.domain, tt.recordType)
+			if rcode := response.Header.Flags.Rcode(); rcode != types.RCODE_NO_ERROR {
+				t.Fatalf("Expected NOERROR, got %s", rcode)
+			}
+			if authoritative := response.Header.Flags.IsAuthoritative(); authoritative != tt.hosts {
+				t.Errorf("Expected authoritative %v, got %v", tt.hosts, authoritative)
+			}
+
+			got := make([]string, 0, len(response.Answers))
+			for _, answer := range response.Answers {
+				if answer.Type() != tt.recordType {
+					t.Errorf("Expected %s answers, got %s", tt.recordType, answer.Type())
+				}
+				if tt.hosts && answer.TTL() != 30 {
+					t.Errorf("Expected TTL 30, got %d", answer.TTL())
+				}
+				got = append(got, net.IP(answer.Data()).String())
+			}
+			if len(got) != len(tt.expected) {
+				t.Fatalf("Expected answers %v, got %v", tt.expected, got)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("Expected answers %v, got %v", tt.expected, got)
+				}
+			}
+		})
+	}
+}
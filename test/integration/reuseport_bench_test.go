@@ -0,0 +1,67 @@
+//go:build linux && amd64
+
+package integration
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// BenchmarkUDPWorkers compares UDP query throughput of a single receive
+// socket against one SO_REUSEPORT socket per CPU
+func BenchmarkUDPWorkers(b *testing.B) {
+	for _, workers := range []int{1, max(runtime.NumCPU(), 2)} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			cfg := config.DefaultConfig()
+			cfg.Server.Address = "127.0.0.1:0"
+			cfg.Server.NumWorkers = workers
+			cfg.Server.EnableTCP = false
+
+			srv, _ := startServerOnBoundPorts(b, cfg)
+			defer srv.Close()
+
+			address := srv.ListenAddresses()[0]
+			if err := srv.AddRecord(records.NewARecord("bench.local", net.IPv4(192, 168, 1, 9), 300)); err != nil {
+				b.Fatalf("Failed to add record: %v", err)
+			}
+
+			domainName, _, err := utils.NewDomainName(encodeDomainName("bench.local"))
+			if err != nil {
+				b.Fatalf("Failed to create domain name: %v", err)
+			}
+			query := message.GenerateDNSQuery(4321, []message.DNSQuestion{message.NewDNSQuestion(*domainName, types.TYPE_A, types.CLASS_IN)}).ToBytesWithCompression()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				conn, err := net.Dial("udp", address)
+				if err != nil {
+					b.Errorf("Failed to connect to server: %v", err)
+					return
+				}
+				defer conn.Close()
+
+				buf := make([]byte, 512)
+				for pb.Next() {
+					conn.SetDeadline(time.Now().Add(2 * time.Second))
+					if _, err := conn.Write(query); err != nil {
+						b.Errorf("Failed to send query: %v", err)
+						return
+					}
+					if _, err := conn.Read(buf); err != nil {
+						b.Errorf("Failed to read response: %v", err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
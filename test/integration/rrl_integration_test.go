@@ -0,0 +1,50 @@
+package integration
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// TestResponseRateLimiting tests that UDP responses over the rate are sent
+// truncated, while TCP responses are not limited
+func TestResponseRateLimiting(t *testing.T) {
+	helper := StartTestServerWithConfig(t, func(cfg *config.Config) {
+		cfg.RRL.ResponsesPerSecond = 1
+		cfg.RRL.SlipRate = 1
+		cfg.RRL.WindowSize = 2 * time.Second
+	})
+	defer helper.Stop(t)
+
+	helper.AddRecord(t, records.NewARecord("limited.local", net.IPv4(192, 0, 2, 80), 300))
+
+	// A burst of two responses is allowed, the rest slip
+	for i, truncated := range []bool{false, false, true, true} {
+		response := helper.SendDNSQuery(t, "limited.local", types.TYPE_A)
+		if response.Header.Flags.IsTruncated() != truncated {
+			t.Errorf("Query %d: expected truncated %v, got %v", i+1, truncated, response.Header.Flags.IsTruncated())
+		}
+		if answered := len(response.Answers) > 0; answered == truncated {
+			t.Errorf("Query %d: got %d answers", i+1, len(response.Answers))
+		}
+	}
+
+	name, err := utils.ParseDomainName("limited.local.")
+	if err != nil {
+		t.Fatalf("Failed to parse name: %v", err)
+	}
+	query := message.GenerateDNSQuery(4321, []message.DNSQuestion{message.NewDNSQuestion(name, types.TYPE_A, types.CLASS_IN)})
+	response, err := message.NewDNSResponse(exchangeTCP(t, helper.Address, query.ToBytes()))
+	if err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.Header.Flags.IsTruncated() || len(response.Answers) != 1 {
+		t.Errorf("Expected the full answer over TCP, got %d answers", len(response.Answers))
+	}
+}
@@ -1,13 +1,21 @@
 package integration
 
 import (
+	"encoding/binary"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/vadim-su/dnska/internal/config"
 	"github.com/vadim-su/dnska/internal/server"
+	"github.com/vadim-su/dnska/internal/storage"
 	"github.com/vadim-su/dnska/pkg/dns/message"
 	"github.com/vadim-su/dnska/pkg/dns/records"
 	"github.com/vadim-su/dnska/pkg/dns/types"
@@ -24,6 +32,13 @@ type TestServerHelper struct {
 // StartTestServer starts a DNS server on a random port for testing
 func StartTestServer(t *testing.T) *TestServerHelper {
 	t.Helper()
+	return StartTestServerWithConfig(t, nil)
+}
+
+// StartTestServerWithConfig starts a test server after letting configure
+// adjust the test configuration
+func StartTestServerWithConfig(t *testing.T, configure func(cfg *config.Config)) *TestServerHelper {
+	t.Helper()
 
 	// Find a free port
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
@@ -43,6 +58,10 @@ func StartTestServer(t *testing.T) *TestServerHelper {
 	cfg.Resolver.Timeout = 1 * time.Second
 	cfg.Cache.TTL = 10 * time.Second
 
+	if configure != nil {
+		configure(cfg)
+	}
+
 	// Create and start server
 	srv, err := server.New(cfg)
 	if err != nil {
@@ -101,6 +120,15 @@ func (h *TestServerHelper) AddRecord(t *testing.T, record records.DNSRecord) {
 func (h *TestServerHelper) SendDNSQuery(t *testing.T, domain string, recordType types.DNSType) *message.DNSResponse {
 	t.Helper()
 
+	response, _ := h.SendDNSQueryRaw(t, domain, recordType)
+	return response
+}
+
+// SendDNSQueryRaw sends a query and returns the parsed response together with
+// the raw response bytes
+func (h *TestServerHelper) SendDNSQueryRaw(t *testing.T, domain string, recordType types.DNSType) (*message.DNSResponse, []byte) {
+	t.Helper()
+
 	// Create DNS question
 	domainBytes := encodeDomainName(domain)
 	domainName, _, err := utils.NewDomainName(domainBytes)
@@ -108,11 +136,7 @@ func (h *TestServerHelper) SendDNSQuery(t *testing.T, domain string, recordType
 		t.Fatalf("Failed to create domain name: %v", err)
 	}
 
-	question := message.DNSQuestion{
-		Name:  *domainName,
-		Type:  types.DnsTypeClassToBytes(recordType),
-		Class: types.DnsTypeClassToBytes(types.CLASS_IN),
-	}
+	question := message.NewDNSQuestion(*domainName, recordType, types.CLASS_IN)
 
 	// Create DNS query
 	query := message.GenerateDNSQuery(1234, []message.DNSQuestion{question})
@@ -147,7 +171,7 @@ func (h *TestServerHelper) SendDNSQuery(t *testing.T, domain string, recordType
 		t.Fatalf("Failed to parse response: %v", err)
 	}
 
-	return response
+	return response, buffer[:n]
 }
 
 // encodeDomainName converts a domain string to DNS wire format
@@ -182,6 +206,12 @@ func encodeDomainName(domain string) []byte {
 
 // sendDNSQuerySafe sends a DNS query without using testing.T (safe for goroutines)
 func sendDNSQuerySafe(address, domain string, recordType types.DNSType) (*message.DNSResponse, error) {
+	return sendDNSQueryFrom(nil, address, domain, recordType)
+}
+
+// sendDNSQueryFrom sends a DNS query from the local address source, or from
+// any address when source is nil
+func sendDNSQueryFrom(source net.Addr, address, domain string, recordType types.DNSType) (*message.DNSResponse, error) {
 	// Create DNS question
 	domainBytes := encodeDomainName(domain)
 	domainName, _, err := utils.NewDomainName(domainBytes)
@@ -189,18 +219,15 @@ func sendDNSQuerySafe(address, domain string, recordType types.DNSType) (*messag
 		return nil, fmt.Errorf("failed to create domain name: %v", err)
 	}
 
-	question := message.DNSQuestion{
-		Name:  *domainName,
-		Type:  types.DnsTypeClassToBytes(recordType),
-		Class: types.DnsTypeClassToBytes(types.CLASS_IN),
-	}
+	question := message.NewDNSQuestion(*domainName, recordType, types.CLASS_IN)
 
 	// Create DNS query
 	query := message.GenerateDNSQuery(uint16(time.Now().UnixNano()&0xFFFF), []message.DNSQuestion{question})
 	queryBytes := query.ToBytesWithCompression()
 
 	// Send query via UDP
-	conn, err := net.Dial("udp", address)
+	dialer := net.Dialer{LocalAddr: source}
+	conn, err := dialer.Dial("udp", address)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to server: %v", err)
 	}
@@ -268,8 +295,131 @@ func TestDNSQuery(t *testing.T) {
 	}
 
 	// Check response code
-	if response.Header.Flags&0xF != 0 {
-		t.Errorf("Expected NOERROR response code, got %d", response.Header.Flags&0xF)
+	if rcode := response.Header.Flags.Rcode(); rcode != types.RCODE_NO_ERROR {
+		t.Errorf("Expected NOERROR response code, got %s", rcode)
+	}
+
+	answer := response.Answers[0]
+	if answer.Type() != types.TYPE_A || answer.Class() != types.CLASS_IN {
+		t.Errorf("Expected IN A answer, got class %d type %d", answer.Class(), answer.Type())
+	}
+	if answer.TTL() != 300 {
+		t.Errorf("Expected TTL 300, got %d", answer.TTL())
+	}
+	if !net.IP(answer.Data()).Equal(net.IPv4(192, 168, 1, 1)) {
+		t.Errorf("Expected 192.168.1.1, got %v", net.IP(answer.Data()))
+	}
+}
+
+// TestAAAAQuery tests that AAAA answers carry the right type and address
+func TestAAAAQuery(t *testing.T) {
+	helper := StartTestServer(t)
+	defer helper.Stop(t)
+
+	aaaaRecord := records.NewAAAARecord("v6.local", net.ParseIP("2001:db8::1"), 600)
+	helper.AddRecord(t, aaaaRecord)
+
+	response := helper.SendDNSQuery(t, "v6.local", types.TYPE_AAAA)
+
+	if len(response.Answers) != 1 {
+		t.Fatalf("Expected 1 answer, got %d", len(response.Answers))
+	}
+
+	answer := response.Answers[0]
+	if answer.Type() != types.TYPE_AAAA || answer.Class() != types.CLASS_IN {
+		t.Errorf("Expected IN AAAA answer, got class %d type %d", answer.Class(), answer.Type())
+	}
+	if answer.TTL() != 600 {
+		t.Errorf("Expected TTL 600, got %d", answer.TTL())
+	}
+	if !net.IP(answer.Data()).Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("Expected 2001:db8::1, got %v", net.IP(answer.Data()))
+	}
+}
+
+// TestDNS64Synthesis tests that AAAA queries for names with only A records
+// are answered with synthesized addresses
+func TestDNS64Synthesis(t *testing.T) {
+	helper := StartTestServerWithConfig(t, func(cfg *config.Config) {
+		cfg.DNS64.Prefix = "64:ff9b::/96"
+		cfg.DNS64.MaxTTL = 60 * time.Second
+	})
+	defer helper.Stop(t)
+
+	helper.AddRecord(t, records.NewARecord("v4only.local", net.ParseIP("192.0.2.33"), 300))
+	helper.AddRecord(t, records.NewARecord("dual.local", net.ParseIP("192.0.2.34"), 300))
+	helper.AddRecord(t, records.NewAAAARecord("dual.local", net.ParseIP("2001:db8::1"), 300))
+
+	response := helper.SendDNSQuery(t, "v4only.local", types.TYPE_AAAA)
+	if len(response.Answers) != 1 {
+		t.Fatalf("Expected 1 synthesized answer, got %d", len(response.Answers))
+	}
+	answer := response.Answers[0]
+	if answer.Type() != types.TYPE_AAAA {
+		t.Errorf("Expected AAAA answer, got type %d", answer.Type())
+	}
+	if !net.IP(answer.Data()).Equal(net.ParseIP("64:ff9b::c000:221")) {
+		t.Errorf("Expected 64:ff9b::c000:221, got %v", net.IP(answer.Data()))
+	}
+	if answer.TTL() != 60 {
+		t.Errorf("Expected TTL bounded to 60, got %d", answer.TTL())
+	}
+
+	// Existing AAAA records are never replaced
+	response = helper.SendDNSQuery(t, "dual.local", types.TYPE_AAAA)
+	if len(response.Answers) != 1 || !net.IP(response.Answers[0].Data()).Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("Expected the stored AAAA record, got %v", response.Answers)
+	}
+
+	// A queries are answered as usual
+	response = helper.SendDNSQuery(t, "v4only.local", types.TYPE_A)
+	if len(response.Answers) != 1 || response.Answers[0].Type() != types.TYPE_A {
+		t.Errorf("Expected the stored A record, got %v", response.Answers)
+	}
+}
+
+// TestSplitHorizonViews tests that clients are answered from the view
+// matching their address and fall back to the default view
+func TestSplitHorizonViews(t *testing.T) {
+	helper := StartTestServerWithConfig(t, func(cfg *config.Config) {
+		cfg.Views = []config.ViewConfig{
+			{Name: "lan", Networks: []string{"127.0.0.2/32"}, Priority: 10},
+			{Name: "lab", Networks: []string{"127.0.0.0/24"}, Priority: 20},
+		}
+	})
+	defer helper.Stop(t)
+
+	helper.AddRecord(t, records.NewARecord("split.local", net.ParseIP("203.0.113.10"), 300))
+	helper.AddRecord(t, storage.NewViewRecord(records.NewARecord("split.local", net.ParseIP("192.168.1.10"), 300), "lan"))
+	helper.AddRecord(t, records.NewARecord("public-only.local", net.ParseIP("203.0.113.20"), 300))
+
+	tests := []struct {
+		name     string
+		source   string
+		domain   string
+		expected string
+	}{
+		{name: "view client", source: "127.0.0.2", domain: "split.local", expected: "192.168.1.10"},
+		{name: "client of a view without the name", source: "127.0.0.3", domain: "split.local", expected: "203.0.113.10"},
+		{name: "client outside every view", source: "127.0.1.1", domain: "split.local", expected: "203.0.113.10"},
+		{name: "view falls back to the default view", source: "127.0.0.2", domain: "public-only.local", expected: "203.0.113.20"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &net.UDPAddr{IP: net.ParseIP(tt.source)}
+			response, err := sendDNSQueryFrom(source, helper.Address, tt.domain, types.TYPE_A)
+			if err != nil {
+				t.Skipf("Cannot query from %s: %v", tt.source, err)
+			}
+
+			if len(response.Answers) != 1 {
+				t.Fatalf("Expected 1 answer, got %d", len(response.Answers))
+			}
+			if ip := net.IP(response.Answers[0].Data()); !ip.Equal(net.ParseIP(tt.expected)) {
+				t.Errorf("Expected %s, got %s", tt.expected, ip)
+			}
+		})
 	}
 }
 
@@ -316,6 +466,124 @@ func TestCNAMERecord(t *testing.T) {
 	}
 }
 
+// TestWildcardRecords tests that names without records are answered from
+// the closest matching wildcard
+func TestWildcardRecords(t *testing.T) {
+	addRecords := func(helper *TestServerHelper) {
+		helper.AddRecord(t, records.NewARecord("*.wild.local", net.ParseIP("192.0.2.1"), 300))
+		helper.AddRecord(t, records.NewARecord("*.sub.wild.local", net.ParseIP("192.0.2.2"), 300))
+		helper.AddRecord(t, records.NewARecord("exact.wild.local", net.ParseIP("192.0.2.3"), 300))
+		helper.AddRecord(t, records.NewCNAMERecord("alias.wild.local", "exact.wild.local", 300))
+		helper.AddRecord(t, records.NewCNAMERecord("*.cdn.wild.local", "edge.example.net", 300))
+		helper.AddRecord(t, records.NewARecord("host.stop.wild.local", net.ParseIP("192.0.2.4"), 300))
+	}
+
+	helper := StartTestServer(t)
+	defer helper.Stop(t)
+	addRecords(helper)
+
+	tests := []struct {
+		name       string
+		domain     string
+		recordType types.DNSType
+		expected   string // Answer in presentation format, empty for none
+	}{
+		{name: "single label", domain: "www.wild.local", recordType: types.TYPE_A, expected: "www.wild.local. 300 IN A 192.0.2.1"},
+		{name: "multiple labels", domain: "a.b.wild.local", recordType: types.TYPE_A, expected: "a.b.wild.local. 300 IN A 192.0.2.1"},
+		{name: "closest wildcard wins", domain: "x.sub.wild.local", recordType: types.TYPE_A, expected: "x.sub.wild.local. 300 IN A 192.0.2.2"},
+		{name: "exact record wins", domain: "exact.wild.local", recordType: types.TYPE_A, expected: "exact.wild.local. 300 IN A 192.0.2.3"},
+		{name: "wildcard CNAME", domain: "img.cdn.wild.local", recordType: types.TYPE_CNAME, expected: "img.cdn.wild.local. 300 IN CNAME edge.example.net."},
+		{name: "CNAME owner not covered", domain: "alias.wild.local", recordType: types.TYPE_A},
+		{name: "wildcard without the type", domain: "img.cdn.wild.local", recordType: types.TYPE_A},
+		{name: "existing ancestor stops the search", domain: "other.host.stop.wild.local", recordType: types.TYPE_A},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, raw := helper.SendDNSQueryRaw(t, tt.domain, tt.recordType)
+
+			if tt.expected == "" {
+				if len(response.Answers) != 0 {
+					t.Fatalf("Expected no answers, got %d", len(response.Answers))
+				}
+				return
+			}
+
+			if len(response.Answers) != 1 {
+				t.Fatalf("Expected 1 answer, got %d", len(response.Answers))
+			}
+			record, err := message.DecodeRData(response.Answers[0], raw)
+			if err != nil {
+				t.Fatalf("Failed to decode answer: %v", err)
+			}
+			if record.String() != tt.expected {
+				t.Errorf("Expected answer %q, got %q", tt.expected, record.String())
+			}
+		})
+	}
+
+	disabled := StartTestServerWithConfig(t, func(cfg *config.Config) {
+		cfg.Responses.WildcardExpansion = false
+	})
+	defer disabled.Stop(t)
+	addRecords(disabled)
+
+	if response := disabled.SendDNSQuery(t, "www.wild.local", types.TYPE_A); len(response.Answers) != 0 {
+		t.Errorf("Expected no answers without wildcard expansion, got %d", len(response.Answers))
+	}
+}
+
+// TestUDPTruncation tests that oversized UDP answers are truncated with the TC bit
+func TestUDPTruncation(t *testing.T) {
+	helper := StartTestServer(t)
+	defer helper.Stop(t)
+
+	for idx := range 30 {
+		text := fmt.Sprintf("record-%02d-%s", idx, strings.Repeat("x", 40))
+		helper.AddRecord(t, records.NewTXTRecordFromString("big.local", text, 300))
+	}
+
+	conn, err := net.Dial("udp", helper.Address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	domainName, _, err := utils.NewDomainName(encodeDomainName("big.local"))
+	if err != nil {
+		t.Fatalf("Failed to create domain name: %v", err)
+	}
+	query := message.GenerateDNSQuery(4321, []message.DNSQuestion{message.NewDNSQuestion(*domainName, types.TYPE_TXT, types.CLASS_IN)})
+
+	if _, err := conn.Write(query.ToBytesWithCompression()); err != nil {
+		t.Fatalf("Failed to send query: %v", err)
+	}
+
+	// Read with a large buffer so an oversized datagram would be noticed
+	buffer := make([]byte, 65535)
+	n, err := conn.Read(buffer)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+
+	if n > message.DefaultUDPPayloadSize {
+		t.Errorf("Expected response of at most %d bytes, got %d", message.DefaultUDPPayloadSize, n)
+	}
+
+	response, err := message.NewDNSResponse(buffer[:n])
+	if err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	if !response.Header.Flags.IsTruncated() {
+		t.Error("Expected TC bit to be set")
+	}
+	if len(response.Answers) >= 30 {
+		t.Errorf("Expected fewer than 30 answers in truncated response, got %d", len(response.Answers))
+	}
+}
+
 // TestNXDOMAIN tests non-existent domain response
 func TestNXDOMAIN(t *testing.T) {
 	helper := StartTestServer(t)
@@ -325,9 +593,451 @@ func TestNXDOMAIN(t *testing.T) {
 	response := helper.SendDNSQuery(t, "nonexistent.local", types.TYPE_A)
 
 	// Should get NXDOMAIN response (RCODE 3)
-	rcode := types.DNSFlag(response.Header.Flags & 0xF)
-	if rcode != types.DNSFlag(types.RCODE_NAME_ERROR) {
-		t.Errorf("Expected NXDOMAIN (3), got RCODE %d", rcode)
+	if rcode := response.Header.Flags.Rcode(); rcode != types.RCODE_NAME_ERROR {
+		t.Errorf("Expected NXDOMAIN, got RCODE %s", rcode)
+	}
+}
+
+// TestNegativeAuthority tests that NODATA and NXDOMAIN responses for names
+// in a stored zone carry its SOA, with the TTL negative answers are cached for
+func TestNegativeAuthority(t *testing.T) {
+	helper := StartTestServer(t)
+	defer helper.Stop(t)
+
+	helper.AddRecord(t, records.NewSOARecord("neg.local", "ns1.neg.local", "admin.neg.local",
+		1, time.Hour, 10*time.Minute, 24*time.Hour, 5*time.Minute, 3600))
+	helper.AddRecord(t, records.NewARecord("www.neg.local", net.ParseIP("192.0.2.80"), 300))
+
+	checkSOA := func(t *testing.T, response *message.DNSResponse) {
+		t.Helper()
+		if len(response.Answers) != 0 {
+			t.Errorf("Expected no answers, got %v", response.Answers)
+		}
+		if len(response.AuthorityRecords) != 1 || response.AuthorityRecords[0].Type() != types.TYPE_SOA {
+			t.Fatalf("Expected the zone SOA in the authority section, got %v", response.AuthorityRecords)
+		}
+		// The lower of the SOA TTL and its minimum
+		if ttl := response.AuthorityRecords[0].TTL(); ttl != 300 {
+			t.Errorf("Expected the SOA with TTL 300, got %d", ttl)
+		}
+	}
+
+	t.Run("NODATA", func(t *testing.T) {
+		response := helper.SendDNSQuery(t, "www.neg.local", types.TYPE_MX)
+		if rcode := response.Header.Flags.Rcode(); rcode != types.RCODE_NO_ERROR {
+			t.Errorf("Expected NOERROR, got %s", rcode)
+		}
+		checkSOA(t, response)
+	})
+
+	t.Run("NXDOMAIN", func(t *testing.T) {
+		response := helper.SendDNSQuery(t, "missing.neg.local", types.TYPE_A)
+		if rcode := response.Header.Flags.Rcode(); rcode != types.RCODE_NAME_ERROR {
+			t.Errorf("Expected NXDOMAIN, got %s", rcode)
+		}
+		checkSOA(t, response)
+	})
+}
+
+// TestMinimalResponses tests that minimal responses drop the authority and
+// additional sections of positive answers but keep the SOA of negative ones
+func TestMinimalResponses(t *testing.T) {
+	addZone := func(helper *TestServerHelper) {
+		helper.AddRecord(t, records.NewSOARecord("zone.local", "ns1.zone.local", "admin.zone.local",
+			1, time.Hour, 10*time.Minute, 24*time.Hour, 5*time.Minute, 300))
+		helper.AddRecord(t, records.NewNSRecord("zone.local", "ns1.zone.local", 300))
+		helper.AddRecord(t, records.NewNSRecord("zone.local", "ns2.zone.local", 300))
+		helper.AddRecord(t, records.NewARecord("ns1.zone.local", net.ParseIP("192.0.2.53"), 300))
+		helper.AddRecord(t, records.NewARecord("ns2.zone.local", net.ParseIP("192.0.2.54"), 300))
+		helper.AddRecord(t, records.NewARecord("www.zone.local", net.ParseIP("192.0.2.80"), 300))
+	}
+
+	full := StartTestServer(t)
+	defer full.Stop(t)
+	addZone(full)
+
+	minimal := StartTestServerWithConfig(t, func(cfg *config.Config) {
+		cfg.Responses.Minimal = true
+	})
+	defer minimal.Stop(t)
+	addZone(minimal)
+
+	fullResponse, fullBytes := full.SendDNSQueryRaw(t, "www.zone.local", types.TYPE_A)
+	minimalResponse, minimalBytes := minimal.SendDNSQueryRaw(t, "www.zone.local", types.TYPE_A)
+
+	if len(fullResponse.AuthorityRecords) != 2 || len(fullResponse.AdditionalRecords) != 2 {
+		t.Errorf("Expected 2 NS and 2 glue records without minimal responses, got %d and %d",
+			len(fullResponse.AuthorityRecords), len(fullResponse.AdditionalRecords))
+	}
+
+	if len(minimalResponse.Answers) != 1 || !net.IP(minimalResponse.Answers[0].Data()).Equal(net.ParseIP("192.0.2.80")) {
+		t.Fatalf("Expected the A answer in the minimal response, got %v", minimalResponse.Answers)
+	}
+	if len(minimalResponse.AuthorityRecords) != 0 || len(minimalResponse.AdditionalRecords) != 0 {
+		t.Errorf("Expected no authority or additional records with minimal responses, got %d and %d",
+			len(minimalResponse.AuthorityRecords), len(minimalResponse.AdditionalRecords))
+	}
+
+	if len(minimalBytes) >= len(fullBytes) {
+		t.Errorf("Expected the minimal response to be smaller: %d bytes, full response %d bytes", len(minimalBytes), len(fullBytes))
+	}
+
+	// Negative answers keep the SOA
+	negative := minimal.SendDNSQuery(t, "missing.zone.local", types.TYPE_A)
+	if rcode := negative.Header.Flags.Rcode(); rcode != types.RCODE_NAME_ERROR {
+		t.Errorf("Expected NXDOMAIN, got RCODE %s", rcode)
+	}
+	if len(negative.AuthorityRecords) != 1 || negative.AuthorityRecords[0].Type() != types.TYPE_SOA {
+		t.Errorf("Expected the zone SOA in the authority section, got %v", negative.AuthorityRecords)
+	}
+}
+
+// TestIDNANormalize tests that query names with Unicode labels are answered
+// from the records stored under their ACE form when IDNA normalization is on
+func TestIDNANormalize(t *testing.T) {
+	helper := StartTestServerWithConfig(t, func(cfg *config.Config) {
+		cfg.IDNA.Normalize = true
+	})
+	defer helper.Stop(t)
+
+	helper.AddRecord(t, records.NewARecord("xn--mnchen-3ya.local", net.ParseIP("192.0.2.49"), 300))
+
+	for _, name := range []string{"münchen.local", "MÜNCHEN.local", "xn--mnchen-3ya.local"} {
+		response := helper.SendDNSQuery(t, name, types.TYPE_A)
+		if len(response.Answers) != 1 || !net.IP(response.Answers[0].Data()).Equal(net.ParseIP("192.0.2.49")) {
+			t.Errorf("Expected the address stored under the ACE name for %s, got %v", name, response.Answers)
+			continue
+		}
+		// Answers are owned by the name asked for
+		if owner := response.Answers[0].Name(); !owner.Equal(response.Questions[0].Name) {
+			t.Errorf("Expected the answer owned by %s, got %s", response.Questions[0].Name.String(), owner.String())
+		}
+	}
+}
+
+// TestDNAMERedirection tests that names below a DNAME owner are answered with
+// the DNAME, a CNAME synthesized to the same name below the target and the
+// records of that name
+func TestDNAMERedirection(t *testing.T) {
+	helper := StartTestServer(t)
+	defer helper.Stop(t)
+
+	helper.AddRecord(t, records.NewDNAMERecord("old.example.com", "new.example.com", 600))
+	helper.AddRecord(t, records.NewARecord("www.new.example.com", net.ParseIP("192.0.2.39"), 300))
+
+	response, raw := helper.SendDNSQueryRaw(t, "www.old.example.com", types.TYPE_A)
+	if len(response.Answers) != 3 {
+		t.Fatalf("Expected the DNAME, the synthesized CNAME and the A record, got %v", response.Answers)
+	}
+
+	expected := []struct {
+		owner      string
+		recordType types.DNSType
+	}{
+		{"old.example.com.", types.TYPE_DNAME},
+		{"www.old.example.com.", types.TYPE_CNAME},
+		{"www.new.example.com.", types.TYPE_A},
+	}
+	for i, want := range expected {
+		answer := response.Answers[i]
+		if owner := answer.Name(); owner.String() != want.owner || answer.Type() != want.recordType {
+			t.Errorf("Expected answer %d to be %s %s, got %s %s", i, want.owner, want.recordType, owner.String(), answer.Type())
+		}
+	}
+
+	cname, err := message.DecodeRData(response.Answers[1], raw)
+	if err != nil {
+		t.Fatalf("Failed to decode the synthesized CNAME: %v", err)
+	}
+	if target := cname.(*records.CNAMERecord).Target(); target != "www.new.example.com." {
+		t.Errorf("Expected the CNAME to point to www.new.example.com., got %s", target)
+	}
+	if cname.TTL() != 600 {
+		t.Errorf("Expected the synthesized CNAME to have the TTL of the DNAME, got %d", cname.TTL())
+	}
+	if !net.IP(response.Answers[2].Data()).Equal(net.ParseIP("192.0.2.39")) {
+		t.Errorf("Expected the address of www.new.example.com., got %v", response.Answers[2].Data())
+	}
+
+	// A CNAME query is answered by the synthesized CNAME
+	response = helper.SendDNSQuery(t, "mail.old.example.com", types.TYPE_CNAME)
+	if len(response.Answers) != 2 || response.Answers[1].Type() != types.TYPE_CNAME {
+		t.Errorf("Expected the DNAME and the synthesized CNAME, got %v", response.Answers)
+	}
+
+	// The owner itself is not redirected
+	response = helper.SendDNSQuery(t, "old.example.com", types.TYPE_DNAME)
+	if len(response.Answers) != 1 || response.Answers[0].Type() != types.TYPE_DNAME {
+		t.Errorf("Expected the DNAME record of the owner, got %v", response.Answers)
+	}
+}
+
+// TestGlueRecords tests that MX and NS answers carry the addresses of their
+// targets in the additional section
+func TestGlueRecords(t *testing.T) {
+	helper := StartTestServer(t)
+	defer helper.Stop(t)
+
+	helper.AddRecord(t, mustBuild(t, records.NewBuilder().Name("mail.local").Type(types.TYPE_MX).TTL(300).Priority(10).Target("mx1.mail.local")))
+	helper.AddRecord(t, mustBuild(t, records.NewBuilder().Name("mx1.mail.local").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.0.2.25"))))
+	helper.AddRecord(t, mustBuild(t, records.NewBuilder().Name("mx1.mail.local").Type(types.TYPE_AAAA).TTL(300).IPv6(net.ParseIP("2001:db8::25"))))
+
+	response := helper.SendDNSQuery(t, "mail.local", types.TYPE_MX)
+	if len(response.Answers) != 1 || response.Answers[0].Type() != types.TYPE_MX {
+		t.Fatalf("Expected the MX answer, got %v", response.Answers)
+	}
+
+	glue := make(map[types.DNSType]net.IP)
+	for _, record := range response.AdditionalRecords {
+		if name := record.Name(); name.String() != "mx1.mail.local." {
+			t.Errorf("Unexpected additional record owned by %s", name.String())
+		}
+		glue[record.Type()] = net.IP(record.Data())
+	}
+	if !glue[types.TYPE_A].Equal(net.ParseIP("192.0.2.25")) || !glue[types.TYPE_AAAA].Equal(net.ParseIP("2001:db8::25")) {
+		t.Errorf("Expected the A and AAAA records of mx1.mail.local. as glue, got %v", response.AdditionalRecords)
+	}
+
+	// NS addresses already added for the zone are not repeated
+	helper.AddRecord(t, records.NewSOARecord("zone.local", "ns1.zone.local", "admin.zone.local",
+		1, time.Hour, 10*time.Minute, 24*time.Hour, 5*time.Minute, 300))
+	helper.AddRecord(t, records.NewNSRecord("zone.local", "ns1.zone.local", 300))
+	helper.AddRecord(t, records.NewARecord("ns1.zone.local", net.ParseIP("192.0.2.53"), 300))
+
+	response = helper.SendDNSQuery(t, "zone.local", types.TYPE_NS)
+	if len(response.AdditionalRecords) != 1 {
+		t.Errorf("Expected the address of ns1.zone.local. once, got %d additional records", len(response.AdditionalRecords))
+	}
+}
+
+// TestTTLClamping tests that the TTLs of stored and forwarded answers are
+// clamped to the configured response bounds
+func TestTTLClamping(t *testing.T) {
+	upstream, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start stub upstream: %v", err)
+	}
+	defer upstream.Close()
+
+	// Answer every query with an A record valid for a day
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := upstream.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			query := buf[:n]
+			reply := []byte{query[0], query[1], 0x81, 0x80, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}
+			reply = append(reply, query[12:]...)
+			reply = append(reply, 0xC0, 0x0C, 0x00, 0x01, 0x00, 0x01, 0x00, 0x01, 0x51, 0x80, 0x00, 0x04, 192, 0, 2, 1)
+
+			upstream.WriteTo(reply, addr)
+		}
+	}()
+
+	helper := StartTestServerWithConfig(t, func(cfg *config.Config) {
+		cfg.Resolver.ForwardServers = []string{upstream.LocalAddr().String()}
+		cfg.Responses.MinTTL = time.Minute
+		cfg.Responses.MaxTTL = time.Hour
+	})
+	defer helper.Stop(t)
+
+	helper.AddRecord(t, records.NewARecord("short.local", net.ParseIP("192.0.2.5"), 5))
+
+	tests := []struct {
+		name        string
+		domain      string
+		expectedTTL uint32
+	}{
+		{name: "stored TTL raised to the minimum", domain: "short.local", expectedTTL: 60},
+		{name: "forwarded TTL lowered to the maximum", domain: "long.example", expectedTTL: 3600},
+		{name: "cached TTL lowered to the maximum", domain: "long.example", expectedTTL: 3600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := helper.SendDNSQuery(t, tt.domain, types.TYPE_A)
+			if len(response.Answers) != 1 {
+				t.Fatalf("Expected 1 answer, got %d", len(response.Answers))
+			}
+			if ttl := response.Answers[0].TTL(); ttl != tt.expectedTTL {
+				t.Errorf("Expected TTL %d, got %d", tt.expectedTTL, ttl)
+			}
+		})
+	}
+}
+
+// TestBlocklist tests that listed names are answered from the blocklist
+// while other names resolve as usual
+func TestBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte("0.0.0.0 ads.local\n*.tracker.local\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write blocklist: %v", err)
+	}
+
+	for _, response := range []string{"nxdomain", "sink"} {
+		t.Run(response, func(t *testing.T) {
+			helper := StartTestServerWithConfig(t, func(cfg *config.Config) {
+				cfg.Blocklist.Files = []string{path}
+				cfg.Blocklist.Response = response
+				cfg.Blocklist.TTL = 30 * time.Second
+			})
+			defer helper.Stop(t)
+
+			// Blocking takes precedence over stored records
+			helper.AddRecord(t, records.NewARecord("ads.local", net.ParseIP("192.0.2.1"), 300))
+			helper.AddRecord(t, records.NewARecord("www.local", net.ParseIP("192.0.2.2"), 300))
+
+			for _, name := range []string{"ads.local", "ADS.local.", "cdn.tracker.local"} {
+				result := helper.SendDNSQuery(t, name, types.TYPE_A)
+				rcode := result.Header.Flags.Rcode()
+
+				if response == "nxdomain" {
+					if rcode != types.RCODE_NAME_ERROR || len(result.Answers) != 0 {
+						t.Errorf("Expected NXDOMAIN for %s, got RCODE %s with %d answers", name, rcode, len(result.Answers))
+					}
+					continue
+				}
+
+				if len(result.Answers) != 1 {
+					t.Fatalf("Expected 1 sink answer for %s, got %d", name, len(result.Answers))
+				}
+				if ip := net.IP(result.Answers[0].Data()); !ip.Equal(net.IPv4zero) {
+					t.Errorf("Expected sink address 0.0.0.0 for %s, got %s", name, ip)
+				}
+				if result.Answers[0].TTL() != 30 {
+					t.Errorf("Expected sink TTL 30, got %d", result.Answers[0].TTL())
+				}
+			}
+
+			if response == "sink" {
+				result := helper.SendDNSQuery(t, "ads.local", types.TYPE_AAAA)
+				if len(result.Answers) != 1 || !net.IP(result.Answers[0].Data()).Equal(net.IPv6unspecified) {
+					t.Errorf("Expected sink address :: for AAAA, got %v", result.Answers)
+				}
+			}
+
+			result := helper.SendDNSQuery(t, "www.local", types.TYPE_A)
+			if len(result.Answers) != 1 || !net.IP(result.Answers[0].Data()).Equal(net.ParseIP("192.0.2.2")) {
+				t.Errorf("Expected unlisted name to resolve, got %v", result.Answers)
+			}
+
+			if blocked := helper.Server.GetStats().BlockedQueries; blocked == 0 {
+				t.Error("Expected blocked queries to be counted")
+			}
+		})
+	}
+}
+
+// TestBlocklistReload tests that a changed blocklist file is picked up
+// without restarting the server
+func TestBlocklistReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte("old.local\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write blocklist: %v", err)
+	}
+
+	helper := StartTestServerWithConfig(t, func(cfg *config.Config) {
+		cfg.Blocklist.Files = []string{path}
+		cfg.Blocklist.Response = "sink"
+		cfg.Blocklist.ReloadInterval = 10 * time.Millisecond
+	})
+	defer helper.Stop(t)
+
+	helper.AddRecord(t, records.NewARecord("new.local", net.ParseIP("192.0.2.3"), 300))
+
+	isBlocked := func(name string) bool {
+		result := helper.SendDNSQuery(t, name, types.TYPE_A)
+		return len(result.Answers) == 1 && net.IP(result.Answers[0].Data()).Equal(net.IPv4zero)
+	}
+
+	if isBlocked("new.local") {
+		t.Fatal("new.local is blocked before being listed")
+	}
+
+	if err := os.WriteFile(path, []byte("new.local\n"), 0o600); err != nil {
+		t.Fatalf("Failed to update blocklist: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("Failed to touch blocklist: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !isBlocked("new.local") {
+		if time.Now().After(deadline) {
+			t.Fatal("Changed blocklist was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestServerReload tests that Reload applies changed sections to the
+// running server and keeps the previous configuration when one fails
+func TestServerReload(t *testing.T) {
+	dir := t.TempDir()
+	blocklistPath := filepath.Join(dir, "blocklist.txt")
+	if err := os.WriteFile(blocklistPath, []byte("ads.local\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write blocklist: %v", err)
+	}
+
+	helper := StartTestServer(t)
+	defer helper.Stop(t)
+
+	helper.AddRecord(t, records.NewARecord("www.local", net.ParseIP("192.0.2.1"), 300))
+	helper.AddRecord(t, records.NewARecord("ads.local", net.ParseIP("192.0.2.2"), 300))
+
+	answers := func(name string) int {
+		return len(helper.SendDNSQuery(t, name, types.TYPE_A).Answers)
+	}
+
+	// Switch to an empty file storage
+	cfg := *helper.Server.GetConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.DSN = filepath.Join(dir, "records.yaml")
+	if err := helper.Server.Reload(&cfg); err != nil {
+		t.Fatalf("Reload() returned error: %v", err)
+	}
+
+	if answers("www.local") != 0 {
+		t.Error("Expected records of the previous storage to be gone")
+	}
+	helper.AddRecord(t, records.NewARecord("ads.local", net.ParseIP("192.0.2.3"), 300))
+	if answers("ads.local") != 1 {
+		t.Error("Expected a record added to the new storage to resolve")
+	}
+
+	// A failing section keeps the whole previous configuration
+	failing := cfg
+	failing.Storage.Type = "memory"
+	failing.Blocklist.Files = []string{filepath.Join(dir, "missing.txt")}
+	if err := helper.Server.Reload(&failing); err == nil {
+		t.Fatal("Reload() expected error for a missing blocklist")
+	}
+
+	if helper.Server.GetConfig().Storage.Type != "file" {
+		t.Error("Expected the storage section to be rolled back")
+	}
+	if answers("ads.local") != 1 {
+		t.Error("Expected the previous storage to keep answering")
+	}
+
+	// Only the blocklist changes; the file storage is kept
+	fileStorage := helper.Server.GetStorage()
+	blocking := cfg
+	blocking.Blocklist.Files = []string{blocklistPath}
+	if err := helper.Server.Reload(&blocking); err != nil {
+		t.Fatalf("Reload() returned error: %v", err)
+	}
+
+	result := helper.SendDNSQuery(t, "ads.local", types.TYPE_A)
+	if rcode := result.Header.Flags.Rcode(); rcode != types.RCODE_NAME_ERROR {
+		t.Errorf("Expected NXDOMAIN for a newly blocked name, got RCODE %s", rcode)
+	}
+	if helper.Server.GetStorage() != fileStorage {
+		t.Error("Expected the unchanged storage to be kept")
 	}
 }
 
@@ -395,11 +1105,7 @@ func TestTCPQuery(t *testing.T) {
 		t.Fatalf("Failed to create domain name: %v", err)
 	}
 
-	question := message.DNSQuestion{
-		Name:  *domainName,
-		Type:  types.DnsTypeClassToBytes(types.TYPE_A),
-		Class: types.DnsTypeClassToBytes(types.CLASS_IN),
-	}
+	question := message.NewDNSQuestion(*domainName, types.TYPE_A, types.CLASS_IN)
 
 	// Create DNS query
 	query := message.GenerateDNSQuery(5678, []message.DNSQuestion{question})
@@ -457,6 +1163,515 @@ func TestTCPQuery(t *testing.T) {
 	}
 }
 
+// TestTCPQueryPooledBuffers tests DNS over TCP with queries read into pooled
+// buffers, which later queries reuse
+func TestTCPQueryPooledBuffers(t *testing.T) {
+	helper := StartTestServerWithConfig(t, func(cfg *config.Config) {
+		cfg.Server.UseBufferPool = true
+	})
+	defer helper.Stop(t)
+
+	for i := range 5 {
+		name := fmt.Sprintf("pooled%d.local", i)
+		helper.AddRecord(t, records.NewARecord(name, net.IPv4(192, 168, 2, byte(i+1)), 300))
+	}
+
+	for i := range 5 {
+		name := fmt.Sprintf("pooled%d.local", i)
+		domainName, err := utils.ParseDomainName(name)
+		if err != nil {
+			t.Fatalf("Failed to parse domain name: %v", err)
+		}
+		question := message.NewDNSQuestion(domainName, types.TYPE_A, types.CLASS_IN)
+		query := message.GenerateDNSQuery(uint16(1000+i), []message.DNSQuestion{question})
+
+		response, err := message.NewDNSResponse(exchangeTCP(t, helper.Address, query.ToBytesWithCompression()))
+		if err != nil {
+			t.Fatalf("%s: failed to parse TCP response: %v", name, err)
+		}
+		if response.Header.ID != uint16(1000+i) {
+			t.Errorf("%s: response ID = %d, want %d", name, response.Header.ID, 1000+i)
+		}
+		if len(response.Answers) != 1 || !net.IP(response.Answers[0].Data()).Equal(net.IPv4(192, 168, 2, byte(i+1))) {
+			t.Errorf("%s: answers = %v, want 192.168.2.%d", name, response.Answers, i+1)
+		}
+	}
+}
+
+// TestTCPQueryInFragments tests that a TCP query arriving in several
+// segments is answered once all of it is read, with pooled buffers holding
+// the bytes of earlier queries
+func TestTCPQueryInFragments(t *testing.T) {
+	helper := StartTestServerWithConfig(t, func(cfg *config.Config) {
+		cfg.Server.UseBufferPool = true
+	})
+	defer helper.Stop(t)
+
+	helper.AddRecord(t, records.NewARecord("fragments.local", net.IPv4(192, 168, 3, 1), 300))
+	domainName, err := utils.ParseDomainName("fragments.local")
+	if err != nil {
+		t.Fatalf("Failed to parse domain name: %v", err)
+	}
+	query := message.GenerateDNSQuery(0x2468, []message.DNSQuestion{
+		message.NewDNSQuestion(domainName, types.TYPE_A, types.CLASS_IN),
+	}).ToBytesWithCompression()
+
+	// Fill the pooled buffers with an earlier query
+	exchangeTCP(t, helper.Address, query)
+
+	conn, err := net.Dial("tcp", helper.Address)
+	if err != nil {
+		t.Fatalf("Failed to connect via TCP: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	frame := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+	frame = append(frame, query...)
+	for _, fragment := range [][]byte{frame[:1], frame[1:8], frame[8:]} {
+		if _, err := conn.Write(fragment); err != nil {
+			t.Fatalf("Failed to send TCP query: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	var length uint16
+	if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+		t.Fatalf("Failed to read response length: %v", err)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(conn, data); err != nil {
+		t.Fatalf("Failed to read TCP response: %v", err)
+	}
+	response, err := message.NewDNSResponse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse TCP response: %v", err)
+	}
+	if response.Header.ID != 0x2468 {
+		t.Errorf("response ID = %#04x, want 0x2468", response.Header.ID)
+	}
+	if len(response.Answers) != 1 || !net.IP(response.Answers[0].Data()).Equal(net.IPv4(192, 168, 3, 1)) {
+		t.Errorf("answers = %v, want 192.168.3.1", response.Answers)
+	}
+}
+
+// startServerOnBoundPorts starts a server whose configuration asks for any
+// port and waits until every configured address is bound. The returned
+// channel receives the result of Start
+func startServerOnBoundPorts(tb testing.TB, cfg *config.Config) (*server.Server, <-chan error) {
+	tb.Helper()
+
+	srv, err := server.New(cfg)
+	if err != nil {
+		tb.Fatalf("Failed to create server: %v", err)
+	}
+
+	serverStarted := make(chan error, 1)
+	go func() {
+		serverStarted <- srv.Start()
+	}()
+
+	expected := len(cfg.Server.ListenAddresses())
+	deadline := time.Now().Add(2 * time.Second)
+	for len(srv.ListenAddresses()) < expected && time.Now().Before(deadline) {
+		select {
+		case err := <-serverStarted:
+			tb.Fatalf("Server failed to start: %v", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	return srv, serverStarted
+}
+
+// TestMultipleListenAddresses tests that every configured address answers
+// over UDP and TCP and that Close shuts all of them down
+func TestMultipleListenAddresses(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.Addresses = []string{"127.0.0.1:0", "127.0.0.1:0"}
+	cfg.Server.ReadTimeout = 2 * time.Second
+	cfg.Server.WriteTimeout = 2 * time.Second
+
+	srv, serverStarted := startServerOnBoundPorts(t, cfg)
+	addresses := srv.ListenAddresses()
+	if len(addresses) != 2 {
+		srv.Close()
+		t.Fatalf("Expected 2 listen addresses, got %v", addresses)
+	}
+	if addresses[0] == addresses[1] {
+		t.Errorf("Expected distinct listen addresses, got %v", addresses)
+	}
+
+	aRecord := records.NewARecord("multi.local", net.IPv4(192, 168, 1, 7), 300)
+	if err := srv.AddRecord(aRecord); err != nil {
+		t.Fatalf("Failed to add record: %v", err)
+	}
+
+	for _, address := range addresses {
+		helper := &TestServerHelper{Server: srv, Address: address}
+		response := helper.SendDNSQuery(t, "multi.local", types.TYPE_A)
+		if len(response.Answers) != 1 {
+			t.Errorf("Expected 1 UDP answer from %s, got %d", address, len(response.Answers))
+		}
+
+		conn, err := net.DialTimeout("tcp", address, time.Second)
+		if err != nil {
+			t.Errorf("Failed to connect via TCP to %s: %v", address, err)
+			continue
+		}
+		conn.Close()
+	}
+
+	if err := srv.Close(); err != nil {
+		t.Fatalf("Failed to stop server: %v", err)
+	}
+	if err := <-serverStarted; err != nil {
+		t.Errorf("Start returned error: %v", err)
+	}
+
+	if remaining := srv.ListenAddresses(); len(remaining) != 0 {
+		t.Errorf("Expected no listen addresses after Close, got %v", remaining)
+	}
+	for _, address := range addresses {
+		if conn, err := net.DialTimeout("tcp", address, time.Second); err == nil {
+			conn.Close()
+			t.Errorf("TCP listener on %s still accepts connections after Close", address)
+		}
+	}
+}
+
+// TestListenBindFailure tests that an address that cannot be bound stops
+// the server by default and is skipped with the warn bind failure mode
+func TestListenBindFailure(t *testing.T) {
+	busy, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to occupy a port: %v", err)
+	}
+	defer busy.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.Server.Addresses = []string{busy.LocalAddr().String(), "127.0.0.1:0"}
+
+	srv, err := server.New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	if err := srv.Start(); err == nil {
+		srv.Close()
+		t.Fatal("Expected Start to fail on the busy address")
+	}
+	if addresses := srv.ListenAddresses(); len(addresses) != 0 {
+		t.Errorf("Expected no sockets left open after the failed start, got %v", addresses)
+	}
+	srv.Close()
+
+	cfg.Server.BindFailure = "warn"
+	srv, err = server.New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer srv.Close()
+
+	serverStarted := make(chan error, 1)
+	go func() {
+		serverStarted <- srv.Start()
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(srv.ListenAddresses()) < 1 && time.Now().Before(deadline) {
+		select {
+		case err := <-serverStarted:
+			t.Fatalf("Server failed to start: %v", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	addresses := srv.ListenAddresses()
+	if len(addresses) != 1 || addresses[0] == busy.LocalAddr().String() {
+		t.Fatalf("Expected to listen on the free address only, got %v", addresses)
+	}
+
+	aRecord := records.NewARecord("bind.local", net.IPv4(192, 168, 1, 8), 300)
+	if err := srv.AddRecord(aRecord); err != nil {
+		t.Fatalf("Failed to add record: %v", err)
+	}
+	helper := &TestServerHelper{Server: srv, Address: addresses[0]}
+	if response := helper.SendDNSQuery(t, "bind.local", types.TYPE_A); len(response.Answers) != 1 {
+		t.Errorf("Expected 1 answer from %s, got %d", addresses[0], len(response.Answers))
+	}
+}
+
+// TestUDPWorkers tests that a server with several UDP workers answers
+// queries from many clients on a single address
+func TestUDPWorkers(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.Address = "127.0.0.1:0"
+	cfg.Server.NumWorkers = 4
+
+	srv, _ := startServerOnBoundPorts(t, cfg)
+	defer srv.Close()
+
+	addresses := srv.ListenAddresses()
+	if len(addresses) != 1 {
+		t.Fatalf("Expected 1 listen address, got %v", addresses)
+	}
+
+	helper := &TestServerHelper{Server: srv, Address: addresses[0]}
+	helper.AddRecord(t, records.NewARecord("workers.local", net.IPv4(192, 168, 1, 8), 300))
+
+	// Every client uses its own source port, so the kernel spreads them
+	// across the worker sockets
+	for i := 0; i < 16; i++ {
+		response := helper.SendDNSQuery(t, "workers.local", types.TYPE_A)
+		if len(response.Answers) != 1 {
+			t.Fatalf("Expected 1 answer, got %d", len(response.Answers))
+		}
+	}
+}
+
+// getHealth requests a health endpoint and decodes its JSON report
+func getHealth(t *testing.T, address, path string) (int, map[string]any) {
+	t.Helper()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("http://" + address + path)
+	if err != nil {
+		t.Fatalf("Failed to request %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	var report map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode %s report: %v", path, err)
+	}
+	return resp.StatusCode, report
+}
+
+// TestHealthEndpoints tests that /readyz fails once the storage is closed
+// while /healthz keeps reporting the server alive
+func TestHealthEndpoints(t *testing.T) {
+	helper := StartTestServerWithConfig(t, func(cfg *config.Config) {
+		cfg.Server.HealthAddress = "127.0.0.1:0"
+	})
+	defer helper.Stop(t)
+
+	address := helper.Server.HealthAddress()
+	if address == "" {
+		t.Fatal("Health endpoints are not served")
+	}
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		if status, report := getHealth(t, address, path); status != http.StatusOK {
+			t.Errorf("Expected %s to return 200, got %d: %v", path, status, report)
+		}
+	}
+
+	if err := helper.Server.GetStorage().Close(); err != nil {
+		t.Fatalf("Failed to close storage: %v", err)
+	}
+
+	status, report := getHealth(t, address, "/readyz")
+	if status != http.StatusServiceUnavailable {
+		t.Errorf("Expected /readyz to return 503 with closed storage, got %d", status)
+	}
+	if failing, _ := report["failing"].([]any); len(failing) != 1 || failing[0] != "storage" {
+		t.Errorf("Expected storage to be reported failing, got %v", report["failing"])
+	}
+
+	if status, report := getHealth(t, address, "/healthz"); status != http.StatusOK {
+		t.Errorf("Expected /healthz to stay 200 with closed storage, got %d: %v", status, report)
+	}
+}
+
+// TestStatsEndpoint tests that /stats reports the answered queries along
+// with the storage statistics
+func TestStatsEndpoint(t *testing.T) {
+	helper := StartTestServerWithConfig(t, func(cfg *config.Config) {
+		cfg.Server.HealthAddress = "127.0.0.1:0"
+	})
+	defer helper.Stop(t)
+
+	helper.AddRecord(t, mustBuild(t, records.NewBuilder().Name("stats.local").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.0.2.10"))))
+	for range 2 {
+		helper.SendDNSQuery(t, "stats.local", types.TYPE_A)
+	}
+
+	status, report := getHealth(t, helper.Server.HealthAddress(), "/stats")
+	if status != http.StatusOK {
+		t.Fatalf("Expected /stats to return 200, got %d: %v", status, report)
+	}
+
+	queries, _ := report["queries"].(map[string]any)
+	if queries["queries"] != float64(2) {
+		t.Errorf("Expected 2 queries, got %v", queries["queries"])
+	}
+	if byType, _ := queries["by_type"].(map[string]any); byType["A"] != float64(2) {
+		t.Errorf("Expected 2 A questions, got %v", queries["by_type"])
+	}
+	if byRcode, _ := queries["by_rcode"].(map[string]any); byRcode["NOERROR"] != float64(2) {
+		t.Errorf("Expected 2 NOERROR responses, got %v", queries["by_rcode"])
+	}
+	topNames, _ := queries["top_names"].([]any)
+	if len(topNames) != 1 || topNames[0].(map[string]any)["key"] != "stats.local." {
+		t.Errorf("Expected stats.local. as the only top name, got %v", queries["top_names"])
+	}
+	topClients, _ := queries["top_clients"].([]any)
+	if len(topClients) != 1 || topClients[0].(map[string]any)["key"] != "127.0.0.1" {
+		t.Errorf("Expected 127.0.0.1 as the only top client, got %v", queries["top_clients"])
+	}
+
+	if storageStats, _ := report["storage"].(map[string]any); storageStats["total_records"] != float64(1) {
+		t.Errorf("Expected 1 stored record, got %v", report["storage"])
+	}
+
+	resolverStats, _ := report["resolver"].(map[string]any)
+	upstreams, _ := resolverStats["upstreams"].([]any)
+	if len(upstreams) != len(config.DefaultConfig().Resolver.ForwardServers) {
+		t.Fatalf("Expected the health of every forward server, got %v", resolverStats["upstreams"])
+	}
+	for _, upstream := range upstreams {
+		if upstream.(map[string]any)["state"] != "up" {
+			t.Errorf("Expected unqueried forward servers to be up, got %v", upstream)
+		}
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Post("http://"+helper.Server.HealthAddress()+"/stats", "application/json", nil)
+	if err != nil {
+		t.Fatalf("Failed to post to /stats: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected POST /stats to return 405, got %d", resp.StatusCode)
+	}
+}
+
+// TestHealthUpstreamProbe tests that /readyz reports an upstream that does
+// not answer
+func TestHealthUpstreamProbe(t *testing.T) {
+	// Upstream that answers every query by echoing it as a response
+	answering, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start stub upstream: %v", err)
+	}
+	defer answering.Close()
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := answering.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			buf[2] |= 0x80 // QR
+			answering.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	// Upstream that swallows every query
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start stub upstream: %v", err)
+	}
+	defer silent.Close()
+
+	tests := []struct {
+		name           string
+		upstream       net.PacketConn
+		expectedStatus int
+	}{
+		{name: "answering upstream", upstream: answering, expectedStatus: http.StatusOK},
+		{name: "silent upstream", upstream: silent, expectedStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			helper := StartTestServerWithConfig(t, func(cfg *config.Config) {
+				cfg.Server.HealthAddress = "127.0.0.1:0"
+				cfg.Server.HealthUpstream = true
+				cfg.Resolver.ForwardServers = []string{tt.upstream.LocalAddr().String()}
+			})
+			defer helper.Stop(t)
+
+			status, report := getHealth(t, helper.Server.HealthAddress(), "/readyz")
+			if status != tt.expectedStatus {
+				t.Errorf("Expected /readyz to return %d, got %d: %v", tt.expectedStatus, status, report)
+			}
+
+			checks, _ := report["checks"].(map[string]any)
+			if _, ok := checks["upstream"]; !ok {
+				t.Errorf("Expected an upstream check, got %v", report["checks"])
+			}
+		})
+	}
+}
+
+// TestIPv6DualStack tests that a server bound to 0.0.0.0 also answers
+// IPv6 clients over UDP and TCP
+func TestIPv6DualStack(t *testing.T) {
+	probe, err := net.ListenPacket("udp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	}
+	probe.Close()
+
+	tests := []struct {
+		name              string
+		enableIPv6        bool
+		expectedAddresses int
+	}{
+		{name: "IPv6 enabled", enableIPv6: true, expectedAddresses: 2},
+		{name: "IPv6 disabled", enableIPv6: false, expectedAddresses: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.Server.Address = "0.0.0.0:0"
+			cfg.Server.EnableIPv6 = tt.enableIPv6
+			cfg.Server.NumWorkers = 2
+
+			srv, _ := startServerOnBoundPorts(t, cfg)
+			defer srv.Close()
+
+			addresses := srv.ListenAddresses()
+			if len(addresses) != tt.expectedAddresses {
+				t.Fatalf("Expected %d listen addresses, got %v", tt.expectedAddresses, addresses)
+			}
+			_, port, _ := net.SplitHostPort(addresses[0])
+
+			helper := &TestServerHelper{Server: srv}
+			helper.AddRecord(t, records.NewARecord("dual.local", net.IPv4(192, 168, 1, 10), 300))
+
+			// IPv4 clients are served either way
+			helper.Address = net.JoinHostPort("127.0.0.1", port)
+			if response := helper.SendDNSQuery(t, "dual.local", types.TYPE_A); len(response.Answers) != 1 {
+				t.Errorf("Expected 1 answer over IPv4, got %d", len(response.Answers))
+			}
+
+			ipv6Address := net.JoinHostPort("::1", port)
+			if !tt.enableIPv6 {
+				if conn, err := net.DialTimeout("tcp", ipv6Address, time.Second); err == nil {
+					conn.Close()
+					t.Errorf("Expected no IPv6 listener with IPv6 disabled")
+				}
+				return
+			}
+
+			helper.Address = ipv6Address
+			if response := helper.SendDNSQuery(t, "dual.local", types.TYPE_A); len(response.Answers) != 1 {
+				t.Errorf("Expected 1 answer over IPv6, got %d", len(response.Answers))
+			}
+
+			conn, err := net.DialTimeout("tcp", ipv6Address, time.Second)
+			if err != nil {
+				t.Fatalf("Failed to connect via TCP over IPv6: %v", err)
+			}
+			conn.Close()
+		})
+	}
+}
+
 // TestForwardResolution tests that forward resolution works
 func TestForwardResolution(t *testing.T) {
 	if testing.Short() {
@@ -476,8 +1691,105 @@ func TestForwardResolution(t *testing.T) {
 	}
 
 	// Check response code is NOERROR
-	if response.Header.Flags&0xF != 0 {
-		t.Errorf("Expected NOERROR response code, got %d", response.Header.Flags&0xF)
+	if rcode := response.Header.Flags.Rcode(); rcode != types.RCODE_NO_ERROR {
+		t.Errorf("Expected NOERROR response code, got %s", rcode)
+	}
+}
+
+// TestQueryDeadlineServfail tests that a query forwarded to an upstream that
+// never replies is answered with SERVFAIL once the query timeout expires
+func TestQueryDeadlineServfail(t *testing.T) {
+	// Upstream that swallows every query
+	upstream, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start stub upstream: %v", err)
+	}
+	defer upstream.Close()
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			if _, _, err := upstream.ReadFrom(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	queryTimeout := 300 * time.Millisecond
+	helper := StartTestServerWithConfig(t, func(cfg *config.Config) {
+		cfg.Server.QueryTimeout = queryTimeout
+		cfg.Resolver.Timeout = 10 * time.Second
+		cfg.Resolver.ForwardServers = []string{upstream.LocalAddr().String()}
+	})
+	defer helper.Stop(t)
+
+	start := time.Now()
+	response := helper.SendDNSQuery(t, "never-answered.example", types.TYPE_A)
+	elapsed := time.Since(start)
+
+	if rcode := response.Header.Flags.Rcode(); rcode != types.RCODE_SERVER_FAILURE {
+		t.Errorf("Expected SERVFAIL response code, got %s", rcode)
+	}
+
+	if elapsed > queryTimeout+500*time.Millisecond {
+		t.Errorf("Expected response within query timeout %v, took %v", queryTimeout, elapsed)
+	}
+}
+
+// TestForwardedRDataCompression tests that names compressed inside upstream
+// RDATA are re-encoded against the server's own response
+func TestForwardedRDataCompression(t *testing.T) {
+	upstream, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start stub upstream: %v", err)
+	}
+	defer upstream.Close()
+
+	// Answer with an uncompressed owner name and an MX exchange that points
+	// into it, so the pointer is only valid inside this message
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := upstream.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			query := buf[:n]
+			reply := []byte{query[0], query[1], 0x81, 0x80, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}
+			reply = append(reply, query[12:]...)
+
+			ownerOffset := len(reply)
+			reply = append(reply, encodeDomainName("mx.example")...)
+			reply = append(reply, 0x00, 0x0F, 0x00, 0x01, 0x00, 0x00, 0x01, 0x2C)
+
+			// "example" starts after the 3-byte "mx" label of the owner
+			exampleOffset := ownerOffset + 3
+			rdata := []byte{0x00, 0x0A, 0x04, 'm', 'a', 'i', 'l', 0xC0 | byte(exampleOffset>>8), byte(exampleOffset)}
+			reply = append(reply, 0x00, byte(len(rdata)))
+			reply = append(reply, rdata...)
+
+			upstream.WriteTo(reply, addr)
+		}
+	}()
+
+	helper := StartTestServerWithConfig(t, func(cfg *config.Config) {
+		cfg.Resolver.ForwardServers = []string{upstream.LocalAddr().String()}
+	})
+	defer helper.Stop(t)
+
+	response, raw := helper.SendDNSQueryRaw(t, "mx.example", types.TYPE_MX)
+	if len(response.Answers) != 1 {
+		t.Fatalf("Expected 1 answer, got %d", len(response.Answers))
+	}
+
+	preference, exchange, err := response.Answers[0].ParseMXRecord(raw)
+	if err != nil {
+		t.Fatalf("Failed to parse forwarded MX record: %v", err)
+	}
+
+	if preference != 10 || exchange.String() != "mail.example." {
+		t.Errorf("Expected MX 10 mail.example., got %d %s", preference, exchange.String())
 	}
 }
 
@@ -2,6 +2,7 @@ package integration
 
 import (
 	"context"
+	"net"
 	"sync"
 	"testing"
 	"time"
@@ -30,7 +31,7 @@ func TestStorageValidatorIntegration(t *testing.T) {
 				MinTTL:          60,
 				MaxTTL:          86400,
 			},
-			record:    mustCreateARecord(t, "example.com", "192.168.1.1", 300),
+			record:    mustBuild(t, records.NewBuilder().Name("example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1"))),
 			wantError: false,
 		},
 		{
@@ -40,7 +41,7 @@ func TestStorageValidatorIntegration(t *testing.T) {
 				MinTTL:  60,
 				MaxTTL:  86400,
 			},
-			record:    mustCreateARecord(t, "example.com", "192.168.1.1", 30),
+			record:    mustBuild(t, records.NewBuilder().Name("example.com").Type(types.TYPE_A).TTL(30).IPv4(net.ParseIP("192.168.1.1"))),
 			wantError: true,
 			errorMsg:  "TTL",
 		},
@@ -51,7 +52,7 @@ func TestStorageValidatorIntegration(t *testing.T) {
 				MinTTL:  60,
 				MaxTTL:  86400,
 			},
-			record:    mustCreateARecord(t, "example.com", "192.168.1.1", 100000),
+			record:    mustBuild(t, records.NewBuilder().Name("example.com").Type(types.TYPE_A).TTL(100000).IPv4(net.ParseIP("192.168.1.1"))),
 			wantError: true,
 			errorMsg:  "TTL",
 		},
@@ -61,7 +62,7 @@ func TestStorageValidatorIntegration(t *testing.T) {
 				Enabled:         true,
 				AllowUnderscore: false,
 			},
-			record:    mustCreateTXTRecord(t, "_dmarc.example.com", "v=DMARC1; p=none"),
+			record:    mustBuild(t, records.NewBuilder().Name("_dmarc.example.com").Type(types.TYPE_TXT).TTL(300).Text("v=DMARC1; p=none")),
 			wantError: true,
 			errorMsg:  "invalid",
 		},
@@ -71,7 +72,7 @@ func TestStorageValidatorIntegration(t *testing.T) {
 				Enabled:         true,
 				AllowUnderscore: true,
 			},
-			record:    mustCreateTXTRecord(t, "_dmarc.example.com", "v=DMARC1; p=none"),
+			record:    mustBuild(t, records.NewBuilder().Name("_dmarc.example.com").Type(types.TYPE_TXT).TTL(300).Text("v=DMARC1; p=none")),
 			wantError: false,
 		},
 		{
@@ -130,11 +131,11 @@ func TestStorageZoneManagementIntegration(t *testing.T) {
 
 	// Add records in different zones
 	records := []records.DNSRecord{
-		mustCreateARecord(t, "host1.subdomain.example.com", "192.168.1.1", 300),
-		mustCreateARecord(t, "host2.subdomain.example.com", "192.168.1.2", 300),
-		mustCreateARecord(t, "www.example.com", "192.168.1.3", 300),
-		mustCreateARecord(t, "example.org", "192.168.2.1", 300),
-		mustCreateARecord(t, "deep.nested.subdomain.example.com", "192.168.1.4", 300),
+		mustBuild(t, records.NewBuilder().Name("host1.subdomain.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1"))),
+		mustBuild(t, records.NewBuilder().Name("host2.subdomain.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.2"))),
+		mustBuild(t, records.NewBuilder().Name("www.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.3"))),
+		mustBuild(t, records.NewBuilder().Name("example.org").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.2.1"))),
+		mustBuild(t, records.NewBuilder().Name("deep.nested.subdomain.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.4"))),
 	}
 
 	// Add all records
@@ -296,9 +297,9 @@ func TestStorageBatchOperationsIntegration(t *testing.T) {
 
 	t.Run("batch put with validation", func(t *testing.T) {
 		batchRecords := []records.DNSRecord{
-			mustCreateARecord(t, "batch1.example.com", "192.168.1.1", 300),
-			mustCreateARecord(t, "batch2.example.com", "192.168.1.2", 300),
-			mustCreateAAAARecord(t, "batch1.example.com", "2001:db8::1", 300),
+			mustBuild(t, records.NewBuilder().Name("batch1.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1"))),
+			mustBuild(t, records.NewBuilder().Name("batch2.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.2"))),
+			mustBuild(t, records.NewBuilder().Name("batch1.example.com").Type(types.TYPE_AAAA).TTL(300).IPv6(net.ParseIP("2001:db8::1"))),
 			records.NewMXRecord("example.com", "mail.example.com", 10, 300),
 			records.NewCNAMERecord("www.example.com", "example.com", 300),
 		}
@@ -321,7 +322,7 @@ func TestStorageBatchOperationsIntegration(t *testing.T) {
 			s.DeleteRecord(ctx, r.Name(), r.Type())
 		}
 
-		validRecord := mustCreateARecord(t, "valid.example.com", "192.168.1.1", 300)
+		validRecord := mustBuild(t, records.NewBuilder().Name("valid.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1")))
 		invalidRecord := &testRecord{
 			name:       "", // Invalid: empty name
 			recordType: types.TYPE_A,
@@ -341,9 +342,9 @@ func TestStorageBatchOperationsIntegration(t *testing.T) {
 	t.Run("batch delete operations", func(t *testing.T) {
 		// Setup: Add some records
 		setupRecords := []records.DNSRecord{
-			mustCreateARecord(t, "delete1.example.com", "192.168.1.1", 300),
-			mustCreateARecord(t, "delete2.example.com", "192.168.1.2", 300),
-			mustCreateARecord(t, "keep.example.com", "192.168.1.3", 300),
+			mustBuild(t, records.NewBuilder().Name("delete1.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1"))),
+			mustBuild(t, records.NewBuilder().Name("delete2.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.2"))),
+			mustBuild(t, records.NewBuilder().Name("keep.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.3"))),
 		}
 
 		for _, r := range setupRecords {
@@ -368,6 +369,107 @@ func TestStorageBatchOperationsIntegration(t *testing.T) {
 	})
 }
 
+// TestStorageReplaceRRSetIntegration verifies that concurrent readers never
+// observe a partially replaced RRset
+func TestStorageReplaceRRSetIntegration(t *testing.T) {
+	s, err := storage.NewMemoryStorage(&storage.ValidationConfig{Enabled: true})
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx := context.Background()
+	const name = "lb.example.com"
+
+	oldSet := []records.DNSRecord{
+		mustBuild(t, records.NewBuilder().Name(name).Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.1"))),
+		mustBuild(t, records.NewBuilder().Name(name).Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.2"))),
+		mustBuild(t, records.NewBuilder().Name(name).Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.3"))),
+	}
+	newSet := []records.DNSRecord{
+		mustBuild(t, records.NewBuilder().Name(name).Type(types.TYPE_A).TTL(60).IPv4(net.ParseIP("10.0.0.1"))),
+		mustBuild(t, records.NewBuilder().Name(name).Type(types.TYPE_A).TTL(60).IPv4(net.ParseIP("10.0.0.2"))),
+		mustBuild(t, records.NewBuilder().Name(name).Type(types.TYPE_A).TTL(60).IPv4(net.ParseIP("10.0.0.3"))),
+		mustBuild(t, records.NewBuilder().Name(name).Type(types.TYPE_A).TTL(60).IPv4(net.ParseIP("10.0.0.4"))),
+	}
+	require.NoError(t, s.ReplaceRRSet(ctx, name, types.TYPE_A, oldSet))
+
+	// setKind classifies an observed RRset as "old", "new" or "mixed"
+	setKind := func(observed []records.DNSRecord) string {
+		oldCount, newCount := 0, 0
+		for _, record := range observed {
+			if record.TTL() == 300 {
+				oldCount++
+			} else {
+				newCount++
+			}
+		}
+		switch {
+		case oldCount == len(oldSet) && newCount == 0:
+			return "old"
+		case newCount == len(newSet) && oldCount == 0:
+			return "new"
+		default:
+			return "mixed"
+		}
+	}
+
+	stop := make(chan struct{})
+	partial := make(chan []records.DNSRecord, 1)
+	var wg sync.WaitGroup
+
+	for r := 0; r < 8; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				observed, err := s.GetRecords(ctx, name, types.TYPE_A)
+				if err != nil {
+					continue
+				}
+				if setKind(observed) == "mixed" {
+					select {
+					case partial <- observed:
+					default:
+					}
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 200; i++ {
+		set := newSet
+		if i%2 == 1 {
+			set = oldSet
+		}
+		require.NoError(t, s.ReplaceRRSet(ctx, name, types.TYPE_A, set))
+	}
+
+	close(stop)
+	wg.Wait()
+
+	select {
+	case observed := <-partial:
+		t.Fatalf("Reader observed a partial RRset with %d records", len(observed))
+	default:
+	}
+
+	final, err := s.GetRecords(ctx, name, types.TYPE_A)
+	require.NoError(t, err)
+	assert.Equal(t, "old", setKind(final))
+
+	// Replacing with an empty set deletes the RRset
+	require.NoError(t, s.ReplaceRRSet(ctx, name, types.TYPE_A, nil))
+	final, err = s.GetRecords(ctx, name, types.TYPE_A)
+	require.NoError(t, err)
+	assert.Empty(t, final)
+}
+
 // TestStorageQueryIntegration tests complex query operations
 func TestStorageQueryIntegration(t *testing.T) {
 	s, err := storage.NewMemoryStorage(&storage.ValidationConfig{Enabled: true})
@@ -378,16 +480,16 @@ func TestStorageQueryIntegration(t *testing.T) {
 
 	// Setup diverse test data
 	testData := []records.DNSRecord{
-		mustCreateARecord(t, "alpha.example.com", "192.168.1.1", 100),
-		mustCreateARecord(t, "beta.example.com", "192.168.1.2", 200),
-		mustCreateARecord(t, "gamma.example.com", "192.168.1.3", 300),
-		mustCreateAAAARecord(t, "alpha.example.com", "2001:db8::1", 400),
-		mustCreateAAAARecord(t, "beta.example.com", "2001:db8::2", 500),
+		mustBuild(t, records.NewBuilder().Name("alpha.example.com").Type(types.TYPE_A).TTL(100).IPv4(net.ParseIP("192.168.1.1"))),
+		mustBuild(t, records.NewBuilder().Name("beta.example.com").Type(types.TYPE_A).TTL(200).IPv4(net.ParseIP("192.168.1.2"))),
+		mustBuild(t, records.NewBuilder().Name("gamma.example.com").Type(types.TYPE_A).TTL(300).IPv4(net.ParseIP("192.168.1.3"))),
+		mustBuild(t, records.NewBuilder().Name("alpha.example.com").Type(types.TYPE_AAAA).TTL(400).IPv6(net.ParseIP("2001:db8::1"))),
+		mustBuild(t, records.NewBuilder().Name("beta.example.com").Type(types.TYPE_AAAA).TTL(500).IPv6(net.ParseIP("2001:db8::2"))),
 		records.NewCNAMERecord("www.example.com", "example.com", 600),
 		records.NewMXRecord("example.com", "mail.example.com", 10, 700),
 		records.NewMXRecord("example.com", "mail2.example.com", 20, 700),
-		mustCreateARecord(t, "alpha.example.org", "192.168.2.1", 100),
-		mustCreateARecord(t, "beta.example.org", "192.168.2.2", 200),
+		mustBuild(t, records.NewBuilder().Name("alpha.example.org").Type(types.TYPE_A).TTL(100).IPv4(net.ParseIP("192.168.2.1"))),
+		mustBuild(t, records.NewBuilder().Name("beta.example.org").Type(types.TYPE_A).TTL(200).IPv4(net.ParseIP("192.168.2.2"))),
 	}
 
 	for _, record := range testData {
@@ -395,9 +497,9 @@ func TestStorageQueryIntegration(t *testing.T) {
 	}
 
 	t.Run("filter by record type", func(t *testing.T) {
-		results, err := s.QueryRecords(ctx, storage.QueryOptions{
-			RecordType: types.TYPE_A,
-		})
+		query, err := storage.NewQuery().WithType(types.TYPE_A).Build()
+		require.NoError(t, err)
+		results, err := s.QueryRecords(ctx, query)
 		assert.NoError(t, err)
 		assert.Len(t, results, 5) // 5 A records
 
@@ -407,9 +509,9 @@ func TestStorageQueryIntegration(t *testing.T) {
 	})
 
 	t.Run("filter by zone", func(t *testing.T) {
-		results, err := s.QueryRecords(ctx, storage.QueryOptions{
-			Zone: "example.com",
-		})
+		query, err := storage.NewQuery().InZone("example.com").Build()
+		require.NoError(t, err)
+		results, err := s.QueryRecords(ctx, query)
 		assert.NoError(t, err)
 		assert.Len(t, results, 8) // All .example.com records
 
@@ -419,9 +521,9 @@ func TestStorageQueryIntegration(t *testing.T) {
 	})
 
 	t.Run("filter by name prefix", func(t *testing.T) {
-		results, err := s.QueryRecords(ctx, storage.QueryOptions{
-			NamePrefix: "alpha",
-		})
+		query, err := storage.NewQuery().WithPrefix("alpha").Build()
+		require.NoError(t, err)
+		results, err := s.QueryRecords(ctx, query)
 		assert.NoError(t, err)
 		assert.Len(t, results, 3) // alpha.example.com (A+AAAA) + alpha.example.org (A)
 
@@ -432,18 +534,16 @@ func TestStorageQueryIntegration(t *testing.T) {
 
 	t.Run("pagination", func(t *testing.T) {
 		// Get first page
-		page1, err := s.QueryRecords(ctx, storage.QueryOptions{
-			Limit:  3,
-			Offset: 0,
-		})
+		query, err := storage.NewQuery().Limit(3).Offset(0).Build()
+		require.NoError(t, err)
+		page1, err := s.QueryRecords(ctx, query)
 		assert.NoError(t, err)
 		assert.Len(t, page1, 3)
 
 		// Get second page
-		page2, err := s.QueryRecords(ctx, storage.QueryOptions{
-			Limit:  3,
-			Offset: 3,
-		})
+		query, err = storage.NewQuery().Limit(3).Offset(3).Build()
+		require.NoError(t, err)
+		page2, err := s.QueryRecords(ctx, query)
 		assert.NoError(t, err)
 		assert.Len(t, page2, 3)
 
@@ -462,11 +562,9 @@ func TestStorageQueryIntegration(t *testing.T) {
 
 	t.Run("sorting", func(t *testing.T) {
 		// Sort by TTL ascending
-		results, err := s.QueryRecords(ctx, storage.QueryOptions{
-			RecordType: types.TYPE_A,
-			SortBy:     "ttl",
-			SortOrder:  "asc",
-		})
+		query, err := storage.NewQuery().WithType(types.TYPE_A).SortBy("ttl", "asc").Build()
+		require.NoError(t, err)
+		results, err := s.QueryRecords(ctx, query)
 		assert.NoError(t, err)
 
 		// Verify ordering
@@ -475,10 +573,9 @@ func TestStorageQueryIntegration(t *testing.T) {
 		}
 
 		// Sort by name descending
-		results, err = s.QueryRecords(ctx, storage.QueryOptions{
-			SortBy:    "name",
-			SortOrder: "desc",
-		})
+		query, err = storage.NewQuery().SortBy("name", "desc").Build()
+		require.NoError(t, err)
+		results, err = s.QueryRecords(ctx, query)
 		assert.NoError(t, err)
 
 		// Verify ordering
@@ -489,13 +586,14 @@ func TestStorageQueryIntegration(t *testing.T) {
 
 	t.Run("complex query", func(t *testing.T) {
 		// Combine multiple filters
-		results, err := s.QueryRecords(ctx, storage.QueryOptions{
-			Zone:       "example.com",
-			RecordType: types.TYPE_A,
-			SortBy:     "name",
-			SortOrder:  "asc",
-			Limit:      2,
-		})
+		query, err := storage.NewQuery().
+			InZone("example.com").
+			WithType(types.TYPE_A).
+			SortBy("name", "asc").
+			Limit(2).
+			Build()
+		require.NoError(t, err)
+		results, err := s.QueryRecords(ctx, query)
 		assert.NoError(t, err)
 		assert.Len(t, results, 2)
 		assert.Equal(t, types.TYPE_A, results[0].Type())
@@ -512,20 +610,12 @@ func generateDomain(writerID, recordID int) string {
 		".example.com"
 }
 
-func mustCreateARecord(t *testing.T, name, ip string, ttl uint32) records.DNSRecord {
-	r, err := records.NewARecordFromString(name, ip, ttl)
-	require.NoError(t, err)
-	return r
-}
+func mustBuild(t *testing.T, builder *records.Builder) records.DNSRecord {
+	t.Helper()
 
-func mustCreateAAAARecord(t *testing.T, name, ip string, ttl uint32) records.DNSRecord {
-	r, err := records.NewAAAARecordFromString(name, ip, ttl)
+	record, err := builder.Build()
 	require.NoError(t, err)
-	return r
-}
-
-func mustCreateTXTRecord(t *testing.T, name, text string) records.DNSRecord {
-	return records.NewTXTRecordFromString(name, text, 300)
+	return record
 }
 
 // testRecord is a minimal DNSRecord implementation for testing
@@ -0,0 +1,207 @@
+package integration
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/records"
+	"github.com/vadim-su/dnska/pkg/dns/tsig"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+var transferSecret = []byte("secret-key-for-testing-12345678")
+
+// signedAXFR is the AXFR query for example.org. with ID 0x1234 signed with
+// transferSecret, named transfer-key., using HMAC-SHA256 at 1700000000
+// (2023-11-14T22:13:20Z). The MAC was computed independently of the tsig
+// package
+const signedAXFR = "123400000001000000000001076578616d706c65036f72670000fc0001" +
+	"0c7472616e736665722d6b65790000fa00ff00000000003d" +
+	"0b686d61632d7368613235360000006553f100012c0020" +
+	"a5b6d5934f64730d8f3a62ba5e6e9f07d62942254ec192a27338e2e91663a863" +
+	"123400000000"
+
+func configureTransfers(cfg *config.Config) {
+	cfg.TSIG.Keys = []config.TSIGKeyConfig{{
+		Name:      "transfer-key",
+		Algorithm: "hmac-sha256",
+		Secret:    base64.StdEncoding.EncodeToString(transferSecret),
+	}}
+	cfg.Transfer.Enabled = true
+	cfg.Transfer.Key = "transfer-key"
+}
+
+func addTransferZone(t *testing.T, helper *TestServerHelper) {
+	t.Helper()
+	helper.AddRecord(t, records.NewSOARecord("example.org.", "ns1.example.org.", "hostmaster.example.org.",
+		2024010101, time.Hour, 10*time.Minute, 24*time.Hour, 5*time.Minute, 3600))
+	helper.AddRecord(t, records.NewNSRecord("example.org.", "ns1.example.org.", 3600))
+	helper.AddRecord(t, records.NewARecord("www.example.org.", net.ParseIP("192.0.2.80"), 300))
+	helper.AddRecord(t, records.NewARecord("www.example.com.", net.ParseIP("198.51.100.80"), 300))
+}
+
+// exchangeTCP sends a message over TCP and returns the raw response
+func exchangeTCP(t *testing.T, address string, query []byte) []byte {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("Failed to connect via TCP: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	frame := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+	if _, err := conn.Write(append(frame, query...)); err != nil {
+		t.Fatalf("Failed to send TCP query: %v", err)
+	}
+
+	var length uint16
+	if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+		t.Fatalf("Failed to read response length: %v", err)
+	}
+	response := make([]byte, length)
+	if _, err := io.ReadFull(conn, response); err != nil {
+		t.Fatalf("Failed to read TCP response: %v", err)
+	}
+	return response
+}
+
+func axfrQuery(t *testing.T, zone string) *message.DNSResponse {
+	t.Helper()
+	name, err := utils.ParseDomainName(zone)
+	if err != nil {
+		t.Fatalf("Failed to parse zone name: %v", err)
+	}
+	return message.GenerateDNSQuery(0x4321, []message.DNSQuestion{message.NewDNSQuestion(name, types.TYPE_AXFR, types.CLASS_IN)})
+}
+
+// TestZoneTransferTSIG tests that zone transfers are only served to requests
+// signed with the transfer key, and are signed in turn
+func TestZoneTransferTSIG(t *testing.T) {
+	helper := StartTestServerWithConfig(t, configureTransfers)
+	defer helper.Stop(t)
+	addTransferZone(t, helper)
+
+	precomputed, err := hex.DecodeString(signedAXFR)
+	if err != nil {
+		t.Fatalf("Invalid precomputed query: %v", err)
+	}
+	tampered := append([]byte(nil), precomputed...)
+	tampered[len(tampered)-7] ^= 0xFF // Last byte of the MAC
+
+	otherKey := axfrQuery(t, "example.org")
+	if err := otherKey.Sign("other-key", transferSecret); err != nil {
+		t.Fatalf("Sign() returned error: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		query     []byte
+		rcode     types.DNSRCode
+		tsigError uint16
+	}{
+		{name: "unsigned", query: axfrQuery(t, "example.org").ToBytes(), rcode: types.RCODE_REFUSED},
+		// The MAC verifies, the time it was signed at does not
+		{name: "signed long ago", query: precomputed, rcode: types.RCODE_NOT_AUTH, tsigError: tsig.ERROR_BADTIME},
+		{name: "tampered MAC", query: tampered, rcode: types.RCODE_NOT_AUTH, tsigError: tsig.ERROR_BADSIG},
+		{name: "unknown key", query: otherKey.ToBytesWithCompression(), rcode: types.RCODE_NOT_AUTH, tsigError: tsig.ERROR_BADKEY},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := message.NewDNSResponse(exchangeTCP(t, helper.Address, tt.query))
+			if err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			if rcode := response.Header.Flags.Rcode(); rcode != tt.rcode {
+				t.Errorf("Expected %s, got %s", tt.rcode, rcode)
+			}
+			if len(response.Answers) != 0 {
+				t.Errorf("Expected no records, got %d", len(response.Answers))
+			}
+
+			record := response.TSIG()
+			if tt.tsigError == 0 {
+				if record != nil {
+					t.Errorf("Expected no TSIG record, got %+v", record)
+				}
+				return
+			}
+			if record == nil || record.Error != tt.tsigError {
+				t.Errorf("Expected TSIG error %d, got %+v", tt.tsigError, record)
+			}
+		})
+	}
+
+	t.Run("signed", func(t *testing.T) {
+		query := axfrQuery(t, "Example.org")
+		if err := query.Sign("transfer-key", transferSecret); err != nil {
+			t.Fatalf("Sign() returned error: %v", err)
+		}
+
+		data := exchangeTCP(t, helper.Address, query.ToBytesWithCompression())
+		keyring := map[string][]byte{"transfer-key.": transferSecret}
+		if _, err := tsig.Verify(data, keyring, query.TSIG().MAC, time.Now()); err != nil {
+			t.Fatalf("Transfer signature does not verify: %v", err)
+		}
+
+		response, err := message.NewDNSResponse(data)
+		if err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if rcode := response.Header.Flags.Rcode(); rcode != types.RCODE_NO_ERROR || !response.Header.Flags.IsAuthoritative() {
+			t.Fatalf("Expected an authoritative NOERROR, got %s", response.Header.Flags)
+		}
+
+		answers := response.Answers
+		if len(answers) != 4 {
+			t.Fatalf("Expected SOA, NS, A and SOA, got %d records", len(answers))
+		}
+		if answers[0].Type() != types.TYPE_SOA || answers[len(answers)-1].Type() != types.TYPE_SOA {
+			t.Errorf("Expected the transfer to start and end with the SOA, got %s and %s", answers[0].Type(), answers[len(answers)-1].Type())
+		}
+	})
+
+	t.Run("over UDP", func(t *testing.T) {
+		response := helper.SendDNSQuery(t, "example.org", types.TYPE_AXFR)
+		if rcode := response.Header.Flags.Rcode(); rcode != types.RCODE_REFUSED {
+			t.Errorf("Expected REFUSED, got %s", rcode)
+		}
+	})
+}
+
+// TestSecondaryZoneTSIG tests that a secondary transfers a zone from a
+// primary requiring signed transfers
+func TestSecondaryZoneTSIG(t *testing.T) {
+	primary := StartTestServerWithConfig(t, configureTransfers)
+	defer primary.Stop(t)
+	addTransferZone(t, primary)
+
+	secondary := StartTestServerWithConfig(t, func(cfg *config.Config) {
+		configureTransfers(cfg)
+		cfg.Secondary.Zones = []config.SecondaryZoneConfig{{Zone: "example.org", Primary: primary.Address, Key: "transfer-key"}}
+		cfg.Secondary.Timeout = time.Second
+	})
+	defer secondary.Stop(t)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		response := secondary.SendDNSQuery(t, "www.example.org", types.TYPE_A)
+		if len(response.Answers) == 1 && net.IP(response.Answers[0].Data()).Equal(net.ParseIP("192.0.2.80")) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Zone was not transferred, last response: %s", response.Header.Flags)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
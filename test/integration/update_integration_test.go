@@ -0,0 +1,109 @@
+package integration
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/vadim-su/dnska/internal/config"
+	"github.com/vadim-su/dnska/pkg/dns/message"
+	"github.com/vadim-su/dnska/pkg/dns/tsig"
+	"github.com/vadim-su/dnska/pkg/dns/types"
+	"github.com/vadim-su/dnska/pkg/dns/utils"
+)
+
+// addressUpdate returns an update of example.org. adding an address for
+// new.example.org. provided the name is not in use yet
+func addressUpdate(t *testing.T, ip string) *message.DNSResponse {
+	t.Helper()
+	zone, err := utils.ParseDomainName("example.org.")
+	if err != nil {
+		t.Fatalf("Failed to parse zone name: %v", err)
+	}
+	name, err := utils.ParseDomainName("new.example.org.")
+	if err != nil {
+		t.Fatalf("Failed to parse name: %v", err)
+	}
+
+	prerequisites := []message.DNSAnswer{*message.NewDNSAnswerFromParts(name, types.TYPE_ANY, types.CLASS_NONE, 0, nil)}
+	updates := []message.DNSAnswer{*message.NewDNSAnswerFromParts(name, types.TYPE_A, types.CLASS_IN, 300, net.ParseIP(ip).To4())}
+	return message.GenerateUpdate(0x2468, zone, prerequisites, updates)
+}
+
+// TestDynamicUpdateTSIG tests that dynamic updates are only applied when
+// signed with the update key, and that their prerequisites are checked
+func TestDynamicUpdateTSIG(t *testing.T) {
+	helper := StartTestServerWithConfig(t, func(cfg *config.Config) {
+		configureTransfers(cfg)
+		cfg.Update.Enabled = true
+		cfg.Update.Key = "transfer-key"
+	})
+	defer helper.Stop(t)
+	addTransferZone(t, helper)
+
+	send := func(t *testing.T, update *message.DNSResponse) *message.DNSResponse {
+		t.Helper()
+		data := exchangeTCP(t, helper.Address, update.ToBytesWithCompression())
+		if signed := update.TSIG(); signed != nil {
+			keyring := map[string][]byte{"transfer-key.": transferSecret}
+			if _, err := tsig.Verify(data, keyring, signed.MAC, time.Now()); err != nil {
+				t.Fatalf("Update response signature does not verify: %v", err)
+			}
+		}
+		response, err := message.NewDNSResponse(data)
+		if err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if opcode := response.Header.Flags.Opcode(); opcode != types.OPCODE_UPDATE {
+			t.Errorf("Expected an UPDATE response, got opcode %s", opcode)
+		}
+		return response
+	}
+
+	t.Run("unsigned", func(t *testing.T) {
+		response := send(t, addressUpdate(t, "192.0.2.10"))
+		if rcode := response.Header.Flags.Rcode(); rcode != types.RCODE_REFUSED {
+			t.Errorf("Expected REFUSED, got %s", rcode)
+		}
+		if answers := helper.SendDNSQuery(t, "new.example.org", types.TYPE_A).Answers; len(answers) != 0 {
+			t.Errorf("Unsigned update was applied: %d records", len(answers))
+		}
+	})
+
+	t.Run("signed", func(t *testing.T) {
+		update := addressUpdate(t, "192.0.2.10")
+		if err := update.Sign("transfer-key", transferSecret); err != nil {
+			t.Fatalf("Sign() returned error: %v", err)
+		}
+		response := send(t, update)
+		if rcode := response.Header.Flags.Rcode(); rcode != types.RCODE_NO_ERROR {
+			t.Fatalf("Expected NOERROR, got %s", rcode)
+		}
+
+		answers := helper.SendDNSQuery(t, "new.example.org", types.TYPE_A).Answers
+		if len(answers) != 1 || !net.IP(answers[0].Data()).Equal(net.ParseIP("192.0.2.10")) {
+			t.Errorf("Expected the added address, got %d records", len(answers))
+		}
+		soa := helper.SendDNSQuery(t, "example.org", types.TYPE_SOA).Answers
+		if len(soa) != 1 {
+			t.Fatalf("Expected the SOA record, got %d records", len(soa))
+		}
+		// The serial leads the five 32-bit fields ending the RDATA
+		data := soa[0].Data()
+		if serial := binary.BigEndian.Uint32(data[len(data)-20:]); serial != 2024010102 {
+			t.Errorf("Expected the serial to be incremented to 2024010102, got %d", serial)
+		}
+	})
+
+	t.Run("prerequisite fails", func(t *testing.T) {
+		update := addressUpdate(t, "192.0.2.11")
+		if err := update.Sign("transfer-key", transferSecret); err != nil {
+			t.Fatalf("Sign() returned error: %v", err)
+		}
+		response := send(t, update)
+		if rcode := response.Header.Flags.Rcode(); rcode != types.RCODE_YXDOMAIN {
+			t.Errorf("Expected YXDOMAIN, got %s", rcode)
+		}
+	})
+}